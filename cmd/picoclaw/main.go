@@ -25,6 +25,8 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/events"
+	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/migrate"
@@ -33,6 +35,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
@@ -490,6 +493,7 @@ func agentCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	enableFileLogging(cfg)
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -498,7 +502,11 @@ func agentCmd() {
 	}
 
 	msgBus := bus.NewMessageBus()
-	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	agentLoop, err := agent.NewAgentLoop(cfg, msgBus, provider)
+	if err != nil {
+		fmt.Printf("Error creating agent: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Print agent startup info (only for interactive mode)
 	startupInfo := agentLoop.GetStartupInfo()
@@ -516,7 +524,7 @@ func agentCmd() {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("\n%s %s\n", logo, response)
+		fmt.Printf("\n%s %s\n", logo, channels.PlainTextFormatter.Format(response))
 	} else {
 		fmt.Printf("%s Interactive mode (Ctrl+C to exit)\n\n", logo)
 		interactiveMode(agentLoop, sessionKey)
@@ -570,7 +578,7 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 			continue
 		}
 
-		fmt.Printf("\n%s %s\n\n", logo, response)
+		fmt.Printf("\n%s %s\n\n", logo, channels.PlainTextFormatter.Format(response))
 	}
 }
 
@@ -605,7 +613,7 @@ func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 			continue
 		}
 
-		fmt.Printf("\n%s %s\n\n", logo, response)
+		fmt.Printf("\n%s %s\n\n", logo, channels.PlainTextFormatter.Format(response))
 	}
 }
 
@@ -625,6 +633,7 @@ func gatewayCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	enableFileLogging(cfg)
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -633,7 +642,11 @@ func gatewayCmd() {
 	}
 
 	msgBus := bus.NewMessageBus()
-	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	agentLoop, err := agent.NewAgentLoop(cfg, msgBus, provider)
+	if err != nil {
+		fmt.Printf("Error creating agent: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Print agent startup info
 	fmt.Println("\n📦 Agent Status:")
@@ -716,29 +729,44 @@ func gatewayCmd() {
 		os.Exit(1)
 	}
 
-	var transcriber *voice.GroqTranscriber
-	if cfg.Providers.Groq.APIKey != "" {
-		transcriber = voice.NewGroqTranscriber(cfg.Providers.Groq.APIKey)
-		logger.InfoC("voice", "Groq voice transcription enabled")
-	}
-
-	if transcriber != nil {
+	if transcriber, backend := newTranscriber(cfg); transcriber != nil {
+		logger.InfoCF("voice", "Voice transcription enabled", map[string]interface{}{"backend": backend})
 		if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
 			if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
 				tc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Telegram channel")
+				logger.InfoC("voice", "Transcription attached to Telegram channel")
 			}
 		}
 		if discordChannel, ok := channelManager.GetChannel("discord"); ok {
 			if dc, ok := discordChannel.(*channels.DiscordChannel); ok {
 				dc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Discord channel")
+				logger.InfoC("voice", "Transcription attached to Discord channel")
 			}
 		}
 		if slackChannel, ok := channelManager.GetChannel("slack"); ok {
 			if sc, ok := slackChannel.(*channels.SlackChannel); ok {
 				sc.SetTranscriber(transcriber)
-				logger.InfoC("voice", "Groq transcription attached to Slack channel")
+				logger.InfoC("voice", "Transcription attached to Slack channel")
+			}
+		}
+	}
+
+	if ttsCfg := cfg.Tools.TTS; ttsCfg.Enabled {
+		ttsAPIKey := strings.TrimSpace(ttsCfg.APIKey)
+		if ttsAPIKey == "" {
+			ttsAPIKey = strings.TrimSpace(cfg.Providers.OpenAI.APIKey)
+		}
+		ttsAPIBase := strings.TrimSpace(ttsCfg.APIBase)
+		if ttsAPIBase == "" {
+			ttsAPIBase = strings.TrimSpace(cfg.Providers.OpenAI.APIBase)
+		}
+		synthesizer := voice.NewOpenAISynthesizer(ttsAPIKey, ttsAPIBase, ttsCfg.Model, ttsCfg.Voice, time.Duration(ttsCfg.TimeoutSeconds)*time.Second)
+		if !synthesizer.IsAvailable() {
+			logger.WarnC("voice", "TTS enabled but no API key configured; voice replies disabled")
+		} else if telegramChannel, ok := channelManager.GetChannel("telegram"); ok {
+			if tc, ok := telegramChannel.(*channels.TelegramChannel); ok {
+				tc.SetSynthesizer(synthesizer)
+				logger.InfoC("voice", "Voice replies attached to Telegram channel")
 			}
 		}
 	}
@@ -756,6 +784,10 @@ func gatewayCmd() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	mediaDir := cfg.MediaDirPath()
+	utils.SetMediaDir(mediaDir)
+	utils.StartMediaSweeper(ctx, mediaDir, time.Duration(cfg.Gateway.MediaMaxAgeMinutes)*time.Minute, utils.MediaSweepInterval)
+
 	if err := cronService.Start(); err != nil {
 		fmt.Printf("Error starting cron service: %v\n", err)
 	}
@@ -770,6 +802,29 @@ func gatewayCmd() {
 		fmt.Printf("Error starting channels: %v\n", err)
 	}
 
+	var selfCheck *health.SelfCheckReport
+	if cfg.Gateway.StartupCheck || cfg.Gateway.StrictStartup {
+		report := startupSelfCheck(ctx, cfg, provider, channelManager, cronService, agentLoop)
+		selfCheck = &report
+		if !report.OK() && cfg.Gateway.StrictStartup {
+			fmt.Println("✗ Startup check failed and gateway.strict_startup is set; stopping")
+			heartbeatService.Stop()
+			cronService.Stop()
+			channelManager.StopAll(ctx)
+			os.Exit(1)
+		}
+	}
+
+	if path := cfg.EventsSocketPath(); path != "" {
+		eventBus := events.NewBus()
+		if err := eventBus.ServeUnix(ctx, path); err != nil {
+			fmt.Printf("Error starting event stream: %v\n", err)
+		} else {
+			agentLoop.SetEventBus(eventBus)
+			fmt.Printf("✓ Event stream on %s\n", path)
+		}
+	}
+
 	go agentLoop.Run(ctx)
 
 	if err := notifyService.Start(); err != nil {
@@ -778,12 +833,44 @@ func gatewayCmd() {
 		fmt.Println("✓ Local notify service started")
 	}
 
+	var healthServer *health.Server
+	if addr := strings.TrimSpace(cfg.Gateway.HealthAddr); addr != "" {
+		sources := health.Sources{
+			Channels:  channelManager,
+			Cron:      cronService,
+			Subagents: agentLoop.SubagentManager(),
+		}
+		if store := agentLoop.MemoryStore(); store != nil {
+			sources.Memory = store
+		}
+		healthServer = health.NewServer(sources)
+		if selfCheck != nil {
+			healthServer.SetSelfCheck(*selfCheck)
+		}
+		if ch, ok := channelManager.GetChannel("telegram"); ok {
+			if tc, ok := ch.(*channels.TelegramChannel); ok && tc.WebhookEnabled() && strings.TrimSpace(cfg.Channels.Telegram.WebhookListen) == "" {
+				healthServer.Mount(tc.WebhookPath(), tc.WebhookHandler())
+			}
+		}
+		if err := healthServer.Start(addr); err != nil {
+			fmt.Printf("Error starting health server: %v\n", err)
+			healthServer = nil
+		} else {
+			fmt.Printf("✓ Health server listening on %s\n", addr)
+		}
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt)
 	<-sigChan
 
 	fmt.Println("\nShutting down...")
 	cancel()
+	if healthServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		healthServer.Stop(shutdownCtx)
+		shutdownCancel()
+	}
 	notifyService.Stop()
 	heartbeatService.Stop()
 	cronService.Stop()
@@ -792,6 +879,88 @@ func gatewayCmd() {
 	fmt.Println("✓ Gateway stopped")
 }
 
+// newTranscriber builds the voice transcriber selected by
+// tools.transcription, returning nil when transcription is off or the
+// backend is missing credentials.
+func newTranscriber(cfg *config.Config) (voice.Transcriber, string) {
+	tc := cfg.Tools.Transcription
+	backend := strings.TrimSpace(tc.Backend)
+	if backend == config.TranscriptionBackendAuto {
+		if cfg.Providers.Groq.APIKey == "" {
+			return nil, ""
+		}
+		backend = config.TranscriptionBackendGroq
+	}
+
+	var transcriber voice.Transcriber
+	switch backend {
+	case config.TranscriptionBackendGroq:
+		apiKey := strings.TrimSpace(tc.APIKey)
+		if apiKey == "" {
+			apiKey = cfg.Providers.Groq.APIKey
+		}
+		transcriber = voice.NewGroqTranscriber(apiKey)
+	case config.TranscriptionBackendOpenAI:
+		apiKey := strings.TrimSpace(tc.APIKey)
+		if apiKey == "" {
+			apiKey = strings.TrimSpace(cfg.Providers.OpenAI.APIKey)
+		}
+		apiBase := strings.TrimSpace(tc.APIBase)
+		if apiBase == "" {
+			apiBase = strings.TrimSpace(cfg.Providers.OpenAI.APIBase)
+		}
+		transcriber = voice.NewOpenAITranscriber(apiKey, apiBase, tc.Model)
+	case config.TranscriptionBackendCommand:
+		transcriber = voice.NewCommandTranscriber(tc.Command)
+	default:
+		return nil, ""
+	}
+	if !transcriber.IsAvailable() {
+		logger.WarnCF("voice", "Transcription backend not usable (missing API key or command); voice messages stay untranscribed",
+			map[string]interface{}{"backend": backend})
+		return nil, ""
+	}
+	return transcriber, backend
+}
+
+// startupSelfCheckTimeout bounds each startup check, mainly the provider
+// ping.
+const startupSelfCheckTimeout = 30 * time.Second
+
+// startupSelfCheck verifies the provider, channels, memory DB and cron store
+// and logs the outcome of each.
+func startupSelfCheck(ctx context.Context, cfg *config.Config, provider providers.LLMProvider, channelManager *channels.Manager, cronService *cron.CronService, agentLoop *agent.AgentLoop) health.SelfCheckReport {
+	checks := []health.Check{health.ProviderCheck(provider, cfg.Agents.Defaults.Model)}
+	checks = append(checks, health.ChannelChecks(channelManager)...)
+	var memorySource health.MemoryStatsSource
+	if store := agentLoop.MemoryStore(); store != nil {
+		memorySource = store
+	}
+	checks = append(checks, health.MemoryCheck(memorySource), health.CronCheck(cronService))
+
+	report := health.RunSelfCheck(ctx, checks, startupSelfCheckTimeout)
+	for _, res := range report.Results {
+		if res.OK {
+			logger.InfoCF("health", "Startup check passed", map[string]interface{}{
+				"check":       res.Name,
+				"duration_ms": res.DurationMS,
+			})
+			continue
+		}
+		logger.ErrorCF("health", "Startup check failed", map[string]interface{}{
+			"check":       res.Name,
+			"error":       res.Error,
+			"duration_ms": res.DurationMS,
+		})
+	}
+	if report.OK() {
+		fmt.Println("✓ Startup check passed")
+	} else {
+		fmt.Printf("⚠ Startup check: %s\n", report.Summary())
+	}
+	return report
+}
+
 func heartbeatSuppressesDelivery(result string) bool {
 	return strings.Contains(strings.ToUpper(result), "HEARTBEAT_OK")
 }
@@ -1099,6 +1268,7 @@ func setupCronTool(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, workspace
 	lastTargetPath := cron.LastTargetPath(workspace)
 	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, lastTargetPath)
 	agentLoop.RegisterTool(cronTool)
+	agentLoop.RegisterTool(tools.NewRemindTool(cronService))
 
 	// Set the onJob handler
 	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
@@ -1113,6 +1283,19 @@ func loadConfig() (*config.Config, error) {
 	return config.LoadConfig(getConfigPath())
 }
 
+// enableFileLogging starts the JSON-lines log file from config.Logging, if a
+// path is set. Failure only disables the file; stdout logging continues.
+func enableFileLogging(cfg *config.Config) {
+	path := cfg.LogPath()
+	if path == "" {
+		return
+	}
+	maxSize := int64(cfg.Logging.MaxSizeMB) * 1024 * 1024
+	if err := logger.EnableRotatingFileLogging(path, maxSize, cfg.Logging.MaxFiles); err != nil {
+		fmt.Printf("Warning: file logging disabled: %v\n", err)
+	}
+}
+
 func cronCmd() {
 	if len(os.Args) < 3 {
 		cronHelp()
@@ -1167,6 +1350,8 @@ func cronHelp() {
 	fmt.Println("  -d, --deliver     Deliver response to channel")
 	fmt.Println("  --to             Recipient for delivery")
 	fmt.Println("  --channel        Channel for delivery")
+	fmt.Println("  --session        Run in this chat session (needs --channel and --to)")
+	fmt.Println("  --prompt-prefix  Instructions added to the system prompt on every run")
 }
 
 func cronListCmd(storePath string) {
@@ -1216,6 +1401,8 @@ func cronAddCmd(storePath string) {
 	deliver := false
 	channel := ""
 	to := ""
+	sessionKey := ""
+	promptPrefix := ""
 
 	args := os.Args[3:]
 	for i := 0; i < len(args); i++ {
@@ -1254,6 +1441,16 @@ func cronAddCmd(storePath string) {
 				channel = args[i+1]
 				i++
 			}
+		case "--session":
+			if i+1 < len(args) {
+				sessionKey = args[i+1]
+				i++
+			}
+		case "--prompt-prefix":
+			if i+1 < len(args) {
+				promptPrefix = args[i+1]
+				i++
+			}
 		}
 	}
 
@@ -1272,6 +1469,11 @@ func cronAddCmd(storePath string) {
 		return
 	}
 
+	if sessionKey != "" && (channel == "" || to == "" || (sessionKey != channel+":"+to && !strings.HasPrefix(sessionKey, channel+":"+to+":"))) {
+		fmt.Println("Error: --session must be the session of the chat set with --channel and --to")
+		return
+	}
+
 	var schedule cron.CronSchedule
 	if everySec != nil {
 		everyMS := *everySec * 1000
@@ -1287,7 +1489,14 @@ func cronAddCmd(storePath string) {
 	}
 
 	cs := cron.NewCronService(storePath, nil)
-	job, err := cs.AddJob(name, schedule, message, deliver, channel, to)
+	job, err := cs.AddAgentJob(name, schedule, cron.CronPayload{
+		Message:      message,
+		Deliver:      deliver,
+		Channel:      channel,
+		To:           to,
+		SessionKey:   sessionKey,
+		PromptPrefix: promptPrefix,
+	})
 	if err != nil {
 		fmt.Printf("Error adding job: %v\n", err)
 		return
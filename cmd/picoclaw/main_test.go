@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
 
 func TestHeartbeatSuppressesDelivery(t *testing.T) {
 	tests := []struct {
@@ -23,3 +27,35 @@ func TestHeartbeatSuppressesDelivery(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTranscriberSelectsBackend(t *testing.T) {
+	cfg := config.DefaultConfig()
+	if tr, _ := newTranscriber(cfg); tr != nil {
+		t.Fatalf("expected no transcriber without a Groq key, got %T", tr)
+	}
+
+	cfg.Providers.Groq.APIKey = "gsk"
+	if _, backend := newTranscriber(cfg); backend != config.TranscriptionBackendGroq {
+		t.Fatalf("expected auto to pick groq, got %q", backend)
+	}
+
+	cfg.Tools.Transcription.Backend = config.TranscriptionBackendNone
+	if tr, _ := newTranscriber(cfg); tr != nil {
+		t.Fatalf("expected none to disable transcription, got %T", tr)
+	}
+
+	cfg.Tools.Transcription.Backend = config.TranscriptionBackendOpenAI
+	if tr, _ := newTranscriber(cfg); tr != nil {
+		t.Fatalf("expected openai without a key to be skipped, got %T", tr)
+	}
+	cfg.Tools.Transcription.APIBase = "http://localhost:8080/v1"
+	if _, backend := newTranscriber(cfg); backend != config.TranscriptionBackendOpenAI {
+		t.Fatalf("expected keyless self-hosted openai backend, got %q", backend)
+	}
+
+	cfg.Tools.Transcription.Backend = config.TranscriptionBackendCommand
+	cfg.Tools.Transcription.Command = []string{"whisper-cli", "-f", "{file}"}
+	if _, backend := newTranscriber(cfg); backend != config.TranscriptionBackendCommand {
+		t.Fatalf("expected command backend, got %q", backend)
+	}
+}
@@ -16,6 +16,7 @@ require (
 	github.com/openai/openai-go/v3 v3.21.0
 	github.com/slack-go/slack v0.17.3
 	github.com/tencent-connect/botgo v0.2.1
+	golang.org/x/image v0.36.0
 	golang.org/x/oauth2 v0.35.0
 	modernc.org/sqlite v1.45.0
 )
@@ -46,7 +47,6 @@ require (
 	golang.org/x/arch v0.24.0 // indirect
 	golang.org/x/crypto v0.48.0 // indirect
 	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
-	golang.org/x/image v0.36.0 // indirect
 	golang.org/x/net v0.50.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
 	golang.org/x/sys v0.41.0 // indirect
@@ -0,0 +1,164 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// toolAuditMaxBytes is the size at which tools.jsonl is rotated to
+	// tools.jsonl.1 (replacing any previous rotation).
+	toolAuditMaxBytes = 10 * 1024 * 1024
+	// toolAuditMaxOutputChars bounds the recorded tool output per entry.
+	toolAuditMaxOutputChars = 2000
+)
+
+// toolAuditEntry is one line of the tool audit log.
+type toolAuditEntry struct {
+	Time       time.Time              `json:"ts"`
+	Tool       string                 `json:"tool"`
+	Args       map[string]interface{} `json:"args,omitempty"`
+	Channel    string                 `json:"channel,omitempty"`
+	ChatID     string                 `json:"chat_id,omitempty"`
+	SessionKey string                 `json:"session_key,omitempty"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	Subagent   string                 `json:"subagent_task,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Success    bool                   `json:"success"`
+	Error      string                 `json:"error,omitempty"`
+	Output     string                 `json:"output,omitempty"`
+}
+
+// toolAuditLog appends tool executions to a JSONL file. Writes are
+// best-effort: failures are logged and never surface to the tool call.
+type toolAuditLog struct {
+	path     string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+func newToolAuditLog(workspace string) *toolAuditLog {
+	return &toolAuditLog{
+		path:     filepath.Join(workspace, "logs", "tools.jsonl"),
+		maxBytes: toolAuditMaxBytes,
+	}
+}
+
+func (a *toolAuditLog) record(call providers.ToolCall, result providers.Message, err error, duration time.Duration, opts processOptions) {
+	if a == nil {
+		return
+	}
+	a.write(newToolAuditEntry(call, result, err, duration, opts))
+}
+
+// recordSubagent records a tool call made by subagent task taskID.
+func (a *toolAuditLog) recordSubagent(taskID string, exec tools.ExecuteToolCallsOptions, call providers.ToolCall, result providers.Message, err error, duration time.Duration) {
+	if a == nil {
+		return
+	}
+	entry := newToolAuditEntry(call, result, err, duration, processOptions{
+		Channel:    exec.Channel,
+		ChatID:     exec.ChatID,
+		SessionKey: exec.SessionKey,
+		TraceID:    exec.TraceID,
+	})
+	entry.Subagent = taskID
+	a.write(entry)
+}
+
+func newToolAuditEntry(call providers.ToolCall, result providers.Message, err error, duration time.Duration, opts processOptions) toolAuditEntry {
+	entry := toolAuditEntry{
+		Time:       time.Now().UTC(),
+		Tool:       call.Name,
+		Args:       redactAuditArgs(call.Arguments),
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SessionKey: opts.SessionKey,
+		TraceID:    opts.TraceID,
+		DurationMS: duration.Milliseconds(),
+		Success:    true,
+		Output:     utils.Truncate(redactSensitive(result.Content), toolAuditMaxOutputChars),
+	}
+	// Tools usually report failures in their content with a nil error.
+	if err != nil {
+		entry.Success = false
+		entry.Error = redactSensitive(err.Error())
+	} else if strings.HasPrefix(strings.ToLower(strings.TrimSpace(result.Content)), "error") {
+		entry.Success = false
+		entry.Error = utils.Truncate(redactSensitive(strings.TrimSpace(result.Content)), 200)
+	}
+	return entry
+}
+
+func (a *toolAuditLog) write(entry toolAuditEntry) {
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		logger.WarnCF("agent", "Failed to encode tool audit entry", map[string]interface{}{"error": marshalErr.Error()})
+		return
+	}
+
+	if writeErr := a.append(append(line, '\n')); writeErr != nil {
+		logger.WarnCF("agent", "Failed to write tool audit log", map[string]interface{}{
+			"path":  a.path,
+			"error": writeErr.Error(),
+		})
+	}
+}
+
+func (a *toolAuditLog) append(line []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	if info, err := os.Stat(a.path); err == nil && a.maxBytes > 0 && info.Size()+int64(len(line)) > a.maxBytes {
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(line)
+	return err
+}
+
+// redactAuditArgs drops internal __context_* args, masks exec env values and
+// applies the secret patterns used for tool-call echoes.
+func redactAuditArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(args))
+	for k, v := range redactSensitiveArgs(args) {
+		if strings.HasPrefix(k, "__context_") {
+			continue
+		}
+		if env, ok := v.(map[string]interface{}); ok && k == "env" {
+			masked := make(map[string]interface{}, len(env))
+			for name := range env {
+				masked[name] = "[REDACTED]"
+			}
+			out[k] = masked
+			continue
+		}
+		if s, ok := v.(string); ok {
+			v = redactSensitive(s)
+		}
+		out[k] = v
+	}
+	return out
+}
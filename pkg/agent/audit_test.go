@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type auditProbeTool struct{}
+
+func (t *auditProbeTool) Name() string        { return "audit_probe" }
+func (t *auditProbeTool) Description() string { return "audit probe tool" }
+func (t *auditProbeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *auditProbeTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	if args["fail"] == true {
+		return "Error: probe failed", nil
+	}
+	return "probe output", nil
+}
+
+func readAuditEntries(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line is not JSON: %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestExecuteToolsConcurrently_WritesToolAuditLog(t *testing.T) {
+	workspace := t.TempDir()
+	reg := tools.NewToolRegistry()
+	reg.Register(&auditProbeTool{})
+
+	al := &AgentLoop{
+		tools:     reg,
+		provider:  &providers.HTTPProvider{},
+		model:     "gpt-4o",
+		workspace: workspace,
+		toolAudit: newToolAuditLog(workspace),
+		// Sequential execution keeps the audit lines in call order.
+		maxParallelTools: 1,
+	}
+
+	al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "audit_probe", Arguments: map[string]interface{}{"query": "x", "api_key": "sk-very-secret-value"}},
+		{ID: "tc2", Name: "audit_probe", Arguments: map[string]interface{}{"fail": true}},
+	}, 1, processOptions{SessionKey: "telegram:42", Channel: "telegram", ChatID: "42", TraceID: "trace-1"})
+
+	entries := readAuditEntries(t, filepath.Join(workspace, "logs", "tools.jsonl"))
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+
+	ok := entries[0]
+	for _, field := range []string{"ts", "tool", "args", "channel", "chat_id", "duration_ms", "success", "output"} {
+		if _, exists := ok[field]; !exists {
+			t.Fatalf("audit entry missing %q: %+v", field, ok)
+		}
+	}
+	if ok["tool"] != "audit_probe" || ok["channel"] != "telegram" || ok["chat_id"] != "42" || ok["success"] != true {
+		t.Fatalf("unexpected audit entry: %+v", ok)
+	}
+	if ok["output"] != "probe output" {
+		t.Fatalf("output = %v", ok["output"])
+	}
+	args := ok["args"].(map[string]interface{})
+	if args["api_key"] != "[REDACTED]" || args["query"] != "x" {
+		t.Fatalf("args not redacted as expected: %+v", args)
+	}
+	if _, leaked := args["__context_session_key"]; leaked {
+		t.Fatalf("internal context args should be dropped: %+v", args)
+	}
+
+	failed := entries[1]
+	if failed["success"] != false || !strings.Contains(failed["error"].(string), "probe failed") {
+		t.Fatalf("expected failed entry, got %+v", failed)
+	}
+}
+
+func TestToolAuditLog_RecordSubagentMarksTask(t *testing.T) {
+	workspace := t.TempDir()
+	audit := newToolAuditLog(workspace)
+	audit.recordSubagent("subagent-1", tools.ExecuteToolCallsOptions{Channel: "telegram", ChatID: "42", SessionKey: "telegram:42", TraceID: "trace-1"},
+		providers.ToolCall{ID: "tc1", Name: "audit_probe"}, providers.ToolResultMessage("tc1", "probe output"), nil, 0)
+
+	entries := readAuditEntries(t, filepath.Join(workspace, "logs", "tools.jsonl"))
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry["subagent_task"] != "subagent-1" || entry["session_key"] != "telegram:42" || entry["trace_id"] != "trace-1" || entry["tool"] != "audit_probe" {
+		t.Fatalf("unexpected subagent audit entry: %+v", entry)
+	}
+}
+
+func TestToolAuditLog_RotatesAtSizeCap(t *testing.T) {
+	workspace := t.TempDir()
+	audit := newToolAuditLog(workspace)
+	audit.maxBytes = 300
+
+	call := providers.ToolCall{Name: "audit_probe"}
+	result := providers.Message{Content: strings.Repeat("x", 150)}
+	for i := 0; i < 3; i++ {
+		audit.record(call, result, nil, 0, processOptions{})
+	}
+
+	if _, err := os.Stat(audit.path + ".1"); err != nil {
+		t.Fatalf("expected rotated file: %v", err)
+	}
+	info, err := os.Stat(audit.path)
+	if err != nil {
+		t.Fatalf("stat audit log: %v", err)
+	}
+	if info.Size() > audit.maxBytes {
+		t.Fatalf("audit log size %d exceeds cap %d", info.Size(), audit.maxBytes)
+	}
+}
+
+func TestToolAuditLog_WriteFailureIsBestEffort(t *testing.T) {
+	workspace := t.TempDir()
+	// A file where the logs directory should be makes every write fail.
+	if err := os.WriteFile(filepath.Join(workspace, "logs"), []byte("not a dir"), 0644); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+
+	reg := tools.NewToolRegistry()
+	reg.Register(&auditProbeTool{})
+	al := &AgentLoop{
+		tools:     reg,
+		provider:  &providers.HTTPProvider{},
+		model:     "gpt-4o",
+		workspace: workspace,
+		toolAudit: newToolAuditLog(workspace),
+	}
+
+	results := al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "audit_probe", Arguments: map[string]interface{}{}},
+	}, 1, processOptions{Channel: "cli", ChatID: "direct"})
+	if len(results) != 1 || results[0].Content != "probe output" {
+		t.Fatalf("tool result affected by audit failure: %+v", results)
+	}
+}
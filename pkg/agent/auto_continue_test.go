@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func noopToolCall(id string) mockResponse {
+	return mockResponse{ToolCalls: []providers.ToolCall{{ID: id, Name: "noop", Arguments: map[string]interface{}{}}}}
+}
+
+func TestProcessDirect_CronRunAutoContinuesAfterIterationLimit(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		noopToolCall("tc1"),
+		noopToolCall("tc2"),
+		// Resumed with "continue".
+		noopToolCall("tc3"),
+		{Content: "all done"},
+	}}
+	al := newTestAgentLoop(t, prov, 2, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	defer al.bus.Close()
+	al.autoContinueMax = 2
+
+	response, err := al.ProcessDirectWithChannel(context.Background(), "long job", "cron-job1", "telegram", "42")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+	if response != "all done" {
+		t.Fatalf("response = %q, want %q", response, "all done")
+	}
+
+	calls := prov.getCalls()
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 provider calls, got %d", len(calls))
+	}
+	resumed := calls[2].Messages
+	if last := resumed[len(resumed)-1]; last.Role != "user" || last.Content != autoContinuePrompt {
+		t.Fatalf("expected resumed call to end with a continue turn, got %+v", last)
+	}
+	if len(calls[3].Tools) == 0 {
+		t.Fatal("expected resumed run to keep its tools (no summary call)")
+	}
+
+	history := al.sessions.GetHistory("cron-job1")
+	found := false
+	for _, m := range history {
+		if m.Role == "user" && m.Content == autoContinuePrompt {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected the continue turn to be recorded in the session")
+	}
+}
+
+func TestRunLLMIteration_AutoContinueStopsAtCap(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		noopToolCall("tc1"),
+		noopToolCall("tc2"),
+		noopToolCall("tc3"),
+		{Content: "summary of progress"},
+	}}
+	al := newTestAgentLoop(t, prov, 1, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	defer al.bus.Close()
+	al.autoContinueMax = 2
+
+	content, iterations, _, _, err := al.runLLMIteration(context.Background(), []providers.Message{
+		{Role: "system", Content: "You are a test bot."},
+		{Role: "user", Content: "Do stuff"},
+	}, processOptions{SessionKey: "cron-job2", Channel: "telegram", ChatID: "42", Unattended: true})
+	if err != nil {
+		t.Fatalf("runLLMIteration: %v", err)
+	}
+	if iterations != 3 {
+		t.Fatalf("iterations = %d, want 3 (1 + 2 resumes)", iterations)
+	}
+	if content != "summary of progress" {
+		t.Fatalf("content = %q, want summary after the cap", content)
+	}
+	calls := prov.getCalls()
+	if len(calls) != 4 || len(calls[3].Tools) != 0 {
+		t.Fatalf("expected 3 tool iterations and a tool-less summary call, got %d calls", len(calls))
+	}
+}
+
+func TestRunLLMIteration_InteractiveRunsDoNotAutoContinue(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		noopToolCall("tc1"),
+		{Content: "summary of progress"},
+	}}
+	al := newTestAgentLoop(t, prov, 1, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	defer al.bus.Close()
+	al.autoContinueMax = 2
+
+	content, _, _, _, err := al.runLLMIteration(context.Background(), []providers.Message{
+		{Role: "system", Content: "You are a test bot."},
+		{Role: "user", Content: "Do stuff"},
+	}, processOptions{SessionKey: "telegram:42", Channel: "telegram", ChatID: "42"})
+	if err != nil {
+		t.Fatalf("runLLMIteration: %v", err)
+	}
+	if content != "summary of progress" || len(prov.getCalls()) != 2 {
+		t.Fatalf("expected summary without resuming, got %q after %d calls", content, len(prov.getCalls()))
+	}
+
+	al.autoContinueAll = true
+	if got := al.autoContinueLimit(processOptions{}); got != 2 {
+		t.Fatalf("autoContinueLimit with auto_continue_interactive = %d, want 2", got)
+	}
+	al.autoContinueMax = 1000
+	if got := al.autoContinueLimit(processOptions{Unattended: true}); got != 10 {
+		t.Fatalf("autoContinueLimit = %d, want hard cap 10", got)
+	}
+}
@@ -0,0 +1,283 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// ChatCommand is a slash command answered directly, without an LLM call.
+type ChatCommand struct {
+	Name        string // without the leading slash, lowercase
+	Args        string // usage hint shown by /help, e.g. "<name>"
+	Description string
+	// Run returns the reply for the chat. args is the text after the command.
+	Run func(ctx context.Context, al *AgentLoop, msg bus.InboundMessage, args string) string
+}
+
+type commandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]ChatCommand
+}
+
+func newCommandRegistry() *commandRegistry {
+	r := &commandRegistry{commands: make(map[string]ChatCommand)}
+	for _, cmd := range builtinChatCommands() {
+		r.register(cmd)
+	}
+	return r
+}
+
+func (r *commandRegistry) register(cmd ChatCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+func (r *commandRegistry) get(name string) (ChatCommand, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+func (r *commandRegistry) list() []ChatCommand {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ChatCommand, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		out = append(out, cmd)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// RegisterCommand adds or replaces a chat command.
+func (al *AgentLoop) RegisterCommand(cmd ChatCommand) {
+	al.chatCommands().register(cmd)
+}
+
+func (al *AgentLoop) chatCommands() *commandRegistry {
+	al.commandsOnce.Do(func() {
+		if al.commands == nil {
+			al.commands = newCommandRegistry()
+		}
+	})
+	return al.commands
+}
+
+var commandNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// parseChatCommand splits "/name args" into its parts. Telegram-style
+// "/name@botname" is accepted. Paths like "/usr/bin" are not commands.
+func parseChatCommand(content string) (name, args string, ok bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "/") {
+		return "", "", false
+	}
+	head, rest, _ := strings.Cut(content[1:], " ")
+	if i := strings.IndexAny(head, "\n\t"); i >= 0 {
+		rest = head[i+1:] + " " + rest
+		head = head[:i]
+	}
+	head, _, _ = strings.Cut(head, "@")
+	head = strings.ToLower(head)
+	if !commandNamePattern.MatchString(head) {
+		return "", "", false
+	}
+	return head, strings.TrimSpace(rest), true
+}
+
+// handleChatCommand runs a registered slash command. ok is false when the
+// message is not a known command and should go to the LLM.
+func (al *AgentLoop) handleChatCommand(ctx context.Context, msg bus.InboundMessage) (reply string, ok bool) {
+	name, args, isCommand := parseChatCommand(msg.Content)
+	if !isCommand {
+		return "", false
+	}
+	cmd, found := al.chatCommands().get(name)
+	if !found || cmd.Run == nil {
+		return "", false
+	}
+
+	logger.InfoCF("agent", "Handling chat command",
+		map[string]interface{}{
+			"command":     name,
+			"session_key": msg.SessionKey,
+			"channel":     msg.Channel,
+			"chat_id":     msg.ChatID,
+		})
+	return cmd.Run(ctx, al, msg, args), true
+}
+
+func builtinChatCommands() []ChatCommand {
+	return []ChatCommand{
+		{
+			Name:        "help",
+			Description: "List available commands",
+			Run: func(_ context.Context, al *AgentLoop, _ bus.InboundMessage, _ string) string {
+				var sb strings.Builder
+				sb.WriteString("Available commands:\n")
+				for _, cmd := range al.chatCommands().list() {
+					usage := "/" + cmd.Name
+					if cmd.Args != "" {
+						usage += " " + cmd.Args
+					}
+					sb.WriteString(fmt.Sprintf("%s - %s\n", usage, cmd.Description))
+				}
+				return strings.TrimSpace(sb.String())
+			},
+		},
+		{
+			Name:        "reset",
+			Description: "Clear this chat's conversation history and summary",
+			Run: func(_ context.Context, al *AgentLoop, msg bus.InboundMessage, _ string) string {
+				al.sessions.Reset(msg.SessionKey)
+				_ = al.sessions.Save(al.sessions.GetOrCreate(msg.SessionKey))
+				return "Conversation history cleared."
+			},
+		},
+		{
+			Name:        "summary",
+			Description: "Show the current conversation summary",
+			Run: func(_ context.Context, al *AgentLoop, msg bus.InboundMessage, _ string) string {
+				summary := strings.TrimSpace(al.sessions.GetSummary(msg.SessionKey))
+				if summary == "" {
+					return "No summary yet. The conversation is summarized once it gets long."
+				}
+				return "Conversation summary:\n" + summary
+			},
+		},
+		{
+			Name:        "usage",
+			Description: "Show this chat's history size and context usage",
+			Run: func(_ context.Context, al *AgentLoop, msg bus.InboundMessage, _ string) string {
+				history := al.sessions.GetHistory(msg.SessionKey)
+				model := al.sessionModel(msg.SessionKey)
+				tokens := al.countTokens(msg.SessionKey, history) + len(al.sessions.GetSummary(msg.SessionKey))/4
+
+				lines := []string{
+					fmt.Sprintf("Model: %s", model),
+					fmt.Sprintf("Messages in history: %d", len(history)),
+				}
+				if window := al.contextWindowFor(model); window > 0 {
+					lines = append(lines, fmt.Sprintf("Estimated context: ~%d / %d tokens (%d%%)", tokens, window, tokens*100/window))
+				} else {
+					lines = append(lines, fmt.Sprintf("Estimated context: ~%d tokens", tokens))
+				}
+				return strings.Join(lines, "\n")
+			},
+		},
+		{
+			Name:        "model",
+			Args:        "[name|default]",
+			Description: "Show or change the model used in this chat",
+			Run: func(_ context.Context, al *AgentLoop, msg bus.InboundMessage, args string) string {
+				switch strings.ToLower(args) {
+				case "":
+					if override := al.sessions.GetModel(msg.SessionKey); override != "" {
+						return fmt.Sprintf("This chat uses %s (default: %s).", override, al.model)
+					}
+					return fmt.Sprintf("This chat uses the default model %s.", al.model)
+				case "default", "reset":
+					al.sessions.SetModel(msg.SessionKey, "")
+					_ = al.sessions.Save(al.sessions.GetOrCreate(msg.SessionKey))
+					return fmt.Sprintf("This chat now uses the default model %s.", al.model)
+				}
+				if _, err := al.providerForModel(args); err != nil {
+					return fmt.Sprintf("Cannot use %s: %v", args, err)
+				}
+				al.sessions.SetModel(msg.SessionKey, args)
+				_ = al.sessions.Save(al.sessions.GetOrCreate(msg.SessionKey))
+				return fmt.Sprintf("This chat now uses %s.", args)
+			},
+		},
+		{
+			Name:        "dryrun",
+			Args:        "[on|off]",
+			Description: "Show or change whether commands are only described, not run",
+			Run: func(_ context.Context, al *AgentLoop, msg bus.InboundMessage, args string) string {
+				switch strings.ToLower(args) {
+				case "":
+					return al.dryRunStatus(msg.SessionKey)
+				case "on":
+					al.sessions.SetExecDryRun(msg.SessionKey, true)
+				case "off":
+					al.sessions.SetExecDryRun(msg.SessionKey, false)
+				default:
+					return "Usage: /dryrun [on|off]"
+				}
+				_ = al.sessions.Save(al.sessions.GetOrCreate(msg.SessionKey))
+				return al.dryRunStatus(msg.SessionKey)
+			},
+		},
+	}
+}
+
+// dryRunStatus describes whether exec runs commands in a session, which
+// tools.exec.dry_run forces for every session.
+func (al *AgentLoop) dryRunStatus(sessionKey string) string {
+	sessionDryRun := al.sessions.GetExecDryRun(sessionKey)
+	switch {
+	case al.execDryRun && !sessionDryRun:
+		return "Dry-run is off for this chat, but tools.exec.dry_run keeps it on everywhere: commands are described, not run."
+	case al.execDryRun:
+		return "Dry-run is on: commands in this chat are described, not run. tools.exec.dry_run also keeps it on everywhere."
+	case sessionDryRun:
+		return "Dry-run is on: commands in this chat are described, not run."
+	default:
+		return "Dry-run is off: commands in this chat are run."
+	}
+}
+
+// sessionModel returns the model for a session: its override or the default.
+func (al *AgentLoop) sessionModel(sessionKey string) string {
+	if override := al.sessions.GetModel(sessionKey); override != "" {
+		return override
+	}
+	return al.model
+}
+
+// providerForModel returns the provider serving model. The default model uses
+// al.provider; overrides get a provider built from config, cached per model.
+func (al *AgentLoop) providerForModel(model string) (providers.LLMProvider, error) {
+	if al.modelProvider == nil || strings.EqualFold(model, al.model) {
+		return al.provider, nil
+	}
+	if cached, ok := al.modelProviders.Load(model); ok {
+		return cached.(providers.LLMProvider), nil
+	}
+	provider, err := al.modelProvider(model)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := al.modelProviders.LoadOrStore(model, provider)
+	return actual.(providers.LLMProvider), nil
+}
+
+// resolveSessionModel picks the model and provider for a turn. An override
+// that no longer resolves (e.g. its API key was removed) falls back to the
+// default model.
+func (al *AgentLoop) resolveSessionModel(sessionKey, traceID string) (string, providers.LLMProvider) {
+	model := al.sessionModel(sessionKey)
+	provider, err := al.providerForModel(model)
+	if err != nil {
+		logger.WarnCF("agent", "Session model unavailable, using default",
+			map[string]interface{}{
+				"trace_id":      traceID,
+				"session_key":   sessionKey,
+				"model":         model,
+				"default_model": al.model,
+				"error":         err.Error(),
+			})
+		return al.model, al.provider
+	}
+	return model, provider
+}
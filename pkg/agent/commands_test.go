@@ -0,0 +1,303 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestParseChatCommand(t *testing.T) {
+	cases := []struct {
+		in        string
+		name      string
+		args      string
+		isCommand bool
+	}{
+		{"/help", "help", "", true},
+		{"  /Model  gpt-4o ", "model", "gpt-4o", true},
+		{"/reset@picoclaw_bot", "reset", "", true},
+		{"/model\nclaude-sonnet-4", "model", "claude-sonnet-4", true},
+		{"/usr/bin/env python", "", "", false},
+		{"/", "", "", false},
+		{"hello /help", "", "", false},
+	}
+	for _, tc := range cases {
+		name, args, ok := parseChatCommand(tc.in)
+		if ok != tc.isCommand || name != tc.name || args != tc.args {
+			t.Fatalf("parseChatCommand(%q) = (%q, %q, %v), want (%q, %q, %v)", tc.in, name, args, ok, tc.name, tc.args, tc.isCommand)
+		}
+	}
+}
+
+func TestProcessMessage_ResetClearsHistoryWithoutLLM(t *testing.T) {
+	prov := &mockProvider{}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+
+	al.sessions.AddMessage("telegram:42", "user", "remember the code 1234")
+	al.sessions.AddMessage("telegram:42", "assistant", "noted")
+	al.sessions.SetSummary("telegram:42", "earlier chat")
+
+	out, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "telegram", ChatID: "42", SenderID: "u1", SessionKey: "telegram:42", Content: "/reset",
+	})
+	if err != nil || out != "" {
+		t.Fatalf("processMessage = (%q, %v), want reply delivered via bus", out, err)
+	}
+
+	if got := al.sessions.GetHistory("telegram:42"); len(got) != 0 {
+		t.Fatalf("expected empty history after /reset, got %d messages", len(got))
+	}
+	if got := al.sessions.GetSummary("telegram:42"); got != "" {
+		t.Fatalf("expected summary cleared, got %q", got)
+	}
+	if len(prov.calls) != 0 {
+		t.Fatalf("expected no LLM calls for a command, got %d", len(prov.calls))
+	}
+	got := collectOutbound(t, al.bus, 1, time.Second)
+	if len(got) != 1 || got[0] != "Conversation history cleared." {
+		t.Fatalf("unexpected command reply: %v", got)
+	}
+}
+
+func TestProcessMessage_ModelPersistsSessionOverride(t *testing.T) {
+	al := newTestAgentLoop(t, &mockProvider{}, 3, nil)
+	defer al.bus.Close()
+
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SenderID: "user", SessionKey: "cli:direct", Content: "/model claude-sonnet-4",
+	})
+	if err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if !strings.Contains(reply, "claude-sonnet-4") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	if got := al.sessions.GetModel("cli:direct"); got != "claude-sonnet-4" {
+		t.Fatalf("session model = %q, want claude-sonnet-4", got)
+	}
+
+	reloaded := session.NewSessionManager(filepath.Join(al.workspace, "sessions"))
+	if got := reloaded.GetModel("cli:direct"); got != "claude-sonnet-4" {
+		t.Fatalf("model override not persisted, got %q", got)
+	}
+
+	reply, _ = al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/model",
+	})
+	if !strings.Contains(reply, "claude-sonnet-4") || !strings.Contains(reply, "test-model") {
+		t.Fatalf("expected override and default in reply, got %q", reply)
+	}
+
+	_, _ = al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/model default",
+	})
+	if got := al.sessions.GetModel("cli:direct"); got != "" {
+		t.Fatalf("expected override cleared, got %q", got)
+	}
+}
+
+func TestProcessMessage_UnknownCommandFallsThroughToLLM(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "llm answer"}}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/frobnicate now",
+	})
+	if err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if reply != "llm answer" || len(prov.calls) != 1 {
+		t.Fatalf("expected LLM to handle unknown command, got reply %q after %d calls", reply, len(prov.calls))
+	}
+}
+
+func TestRegisterCommand_CustomCommandListedInHelp(t *testing.T) {
+	al := newTestAgentLoop(t, &mockProvider{}, 3, nil)
+	defer al.bus.Close()
+
+	al.RegisterCommand(ChatCommand{
+		Name:        "ping",
+		Description: "Check the bot is alive",
+		Run: func(_ context.Context, _ *AgentLoop, _ bus.InboundMessage, args string) string {
+			return "pong " + args
+		},
+	})
+
+	reply, _ := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/ping there",
+	})
+	if reply != "pong there" {
+		t.Fatalf("custom command reply = %q", reply)
+	}
+
+	help, _ := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/help",
+	})
+	for _, want := range []string{"/ping - Check the bot is alive", "/reset", "/summary", "/usage", "/model [name|default]"} {
+		if !strings.Contains(help, want) {
+			t.Fatalf("help missing %q:\n%s", want, help)
+		}
+	}
+}
+
+func TestProcessMessage_SessionModelOverrideSelectsModel(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "default reply"}}}
+	cheap := &mockProvider{responses: []mockResponse{{Content: "cheap reply"}}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.modelProvider = func(model string) (providers.LLMProvider, error) {
+		if model != "cheap-model" {
+			return nil, fmt.Errorf("no API key configured for model: %s", model)
+		}
+		return cheap, nil
+	}
+
+	al.sessions.SetModel("cli:casual", "cheap-model")
+	for _, key := range []string{"cli:casual", "cli:work"} {
+		if _, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel: "cli", ChatID: key, SenderID: "user", SessionKey: key, Content: "hello",
+		}); err != nil {
+			t.Fatalf("processMessage(%s): %v", key, err)
+		}
+	}
+
+	if len(cheap.calls) != 1 || cheap.calls[0].Model != "cheap-model" {
+		t.Fatalf("override session calls = %+v, want one call with cheap-model", cheap.calls)
+	}
+	if len(prov.calls) != 1 || prov.calls[0].Model != "test-model" {
+		t.Fatalf("default session calls = %+v, want one call with test-model", prov.calls)
+	}
+}
+
+func TestModelCommand_RejectsUnconfiguredModel(t *testing.T) {
+	prov := &mockProvider{}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.modelProvider = func(model string) (providers.LLMProvider, error) {
+		return nil, fmt.Errorf("no API key configured for model: %s", model)
+	}
+
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", Content: "/model gemini-2.5-pro",
+	})
+	if err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if !strings.Contains(reply, "Cannot use gemini-2.5-pro") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	if got := al.sessions.GetModel("cli:direct"); got != "" {
+		t.Fatalf("rejected model stored as override: %q", got)
+	}
+
+	// An override that stops resolving later falls back to the default.
+	al.sessions.SetModel("cli:direct", "gemini-2.5-pro")
+	if _, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "cli", ChatID: "direct", SenderID: "user", SessionKey: "cli:direct", Content: "hi",
+	}); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if len(prov.calls) != 1 || prov.calls[0].Model != "test-model" {
+		t.Fatalf("calls = %+v, want fallback to test-model", prov.calls)
+	}
+}
+
+func TestDryRunCommand_ExecDescribesInsteadOfRunning(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "exec", Arguments: map[string]interface{}{"command": "touch ran.txt"}}}},
+		{Content: "done"},
+	}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.tools.Register(tools.NewExecTool(al.workspace))
+
+	msg := bus.InboundMessage{Channel: "cli", ChatID: "direct", SenderID: "user", SessionKey: "cli:direct"}
+	msg.Content = "/dryrun on"
+	reply, err := al.processMessage(context.Background(), msg)
+	if err != nil || !strings.Contains(reply, "Dry-run is on") {
+		t.Fatalf("processMessage(/dryrun on) = (%q, %v)", reply, err)
+	}
+	if !al.sessions.GetExecDryRun("cli:direct") {
+		t.Fatal("expected dry-run stored on the session")
+	}
+
+	msg.Content = "create the marker file"
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(al.workspace, "ran.txt")); !os.IsNotExist(err) {
+		t.Fatal("exec ran a command while dry-run was on")
+	}
+	calls := prov.getCalls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 LLM calls, got %d", len(calls))
+	}
+	last := calls[1].Messages[len(calls[1].Messages)-1]
+	if last.Role != "tool" || !strings.Contains(last.Content, "Would run: touch ran.txt") || !strings.Contains(last.Content, "[dry-run: not executed]") {
+		t.Fatalf("unexpected tool result: %+v", last)
+	}
+
+	msg.Content = "/dryrun off"
+	if reply, _ := al.processMessage(context.Background(), msg); !strings.Contains(reply, "Dry-run is off") {
+		t.Fatalf("unexpected /dryrun off reply %q", reply)
+	}
+	if al.sessions.GetExecDryRun("cli:direct") {
+		t.Fatal("expected dry-run cleared")
+	}
+}
+
+func TestDryRunCommand_ReportsGlobalDryRun(t *testing.T) {
+	al := newTestAgentLoop(t, &mockProvider{}, 1, nil)
+	defer al.bus.Close()
+	al.execDryRun = true
+
+	msg := bus.InboundMessage{Channel: "cli", ChatID: "direct", SenderID: "user", SessionKey: "cli:direct"}
+	for _, content := range []string{"/dryrun", "/dryrun off"} {
+		msg.Content = content
+		reply, err := al.processMessage(context.Background(), msg)
+		if err != nil || !strings.Contains(reply, "tools.exec.dry_run keeps it on") || !strings.Contains(reply, "not run") {
+			t.Fatalf("processMessage(%s) = (%q, %v), want the global flag reported", content, reply, err)
+		}
+	}
+}
+
+func TestSummarizeSession_UsesSessionModelOverride(t *testing.T) {
+	defaultProv := &mockProvider{}
+	overrideProv := &mockProvider{responses: []mockResponse{{Content: "override summary"}}}
+	al := newTestAgentLoop(t, defaultProv, 3, nil)
+	defer al.bus.Close()
+	al.contextWindow = 100000
+	al.modelProvider = func(model string) (providers.LLMProvider, error) {
+		return overrideProv, nil
+	}
+
+	key := "cli:direct"
+	al.sessions.SetModel(key, "claude-sonnet-4")
+	for i := 0; i < 6; i++ {
+		al.sessions.AddMessage(key, "user", fmt.Sprintf("u%d", i))
+		al.sessions.AddMessage(key, "assistant", fmt.Sprintf("a%d", i))
+	}
+
+	al.summarizeSession(key)
+
+	if len(defaultProv.calls) != 0 {
+		t.Fatalf("default provider used for an overridden session: %+v", defaultProv.calls)
+	}
+	if len(overrideProv.calls) == 0 || overrideProv.calls[0].Model != "claude-sonnet-4" {
+		t.Fatalf("override calls = %+v, want the session model", overrideProv.calls)
+	}
+	if got := al.sessions.GetSummary(key); got != "override summary" {
+		t.Fatalf("summary = %q", got)
+	}
+}
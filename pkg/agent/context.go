@@ -6,12 +6,15 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ContextBuilder struct {
@@ -20,6 +23,19 @@ type ContextBuilder struct {
 	memory                 *MemoryStore
 	tools                  *tools.ToolRegistry // Direct reference to tool registry
 	unsafeApprovalRequired bool
+	channelPrompts         map[string]config.ChannelPromptConfig // lowercased channel -> override
+
+	includeMu      sync.Mutex
+	promptIncludes []string
+	includeCache   map[string]promptInclude // workspace-relative path -> last read
+}
+
+// promptInclude is a cached prompt include file, valid while the file's
+// mtime and size are unchanged.
+type promptInclude struct {
+	modTime time.Time
+	size    int64
+	content string
 }
 
 func getGlobalConfigDir() string {
@@ -54,6 +70,111 @@ func (cb *ContextBuilder) SetUnsafeApprovalRequired(required bool) {
 	cb.unsafeApprovalRequired = required
 }
 
+// SetChannelPrompts configures per-channel system prompt overrides. By default
+// a channel prompt is appended to the base prompt; with Replace it is used
+// instead of the base prompt. Session info, delivery constraints and the
+// conversation summary are added in either case.
+func (cb *ContextBuilder) SetChannelPrompts(prompts map[string]config.ChannelPromptConfig) {
+	cb.channelPrompts = make(map[string]config.ChannelPromptConfig, len(prompts))
+	for channel, prompt := range prompts {
+		key := strings.ToLower(strings.TrimSpace(channel))
+		if key == "" || strings.TrimSpace(prompt.Prompt) == "" {
+			continue
+		}
+		cb.channelPrompts[key] = prompt
+	}
+}
+
+// SetPromptIncludes sets the workspace files, in order, whose contents are
+// added to the system prompt after the bootstrap files.
+func (cb *ContextBuilder) SetPromptIncludes(paths []string) {
+	cb.includeMu.Lock()
+	defer cb.includeMu.Unlock()
+	cb.promptIncludes = nil
+	cb.includeCache = make(map[string]promptInclude)
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p != "" {
+			cb.promptIncludes = append(cb.promptIncludes, p)
+		}
+	}
+}
+
+// LoadPromptIncludes returns the concatenated contents of the configured
+// include files. A file is only re-read when its mtime or size changes.
+// Missing files and paths outside the workspace are skipped.
+func (cb *ContextBuilder) LoadPromptIncludes() string {
+	cb.includeMu.Lock()
+	defer cb.includeMu.Unlock()
+
+	var parts []string
+	for _, rel := range cb.promptIncludes {
+		path, err := cb.resolvePromptInclude(rel)
+		if err != nil {
+			logger.WarnCF("agent", "Skipping prompt include",
+				map[string]interface{}{"path": rel, "error": err.Error()})
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			delete(cb.includeCache, rel)
+			continue
+		}
+		cached, ok := cb.includeCache[rel]
+		if !ok || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				logger.WarnCF("agent", "Failed to read prompt include",
+					map[string]interface{}{"path": rel, "error": err.Error()})
+				continue
+			}
+			cached = promptInclude{modTime: info.ModTime(), size: info.Size(), content: strings.TrimSpace(string(data))}
+			cb.includeCache[rel] = cached
+		}
+		if cached.content != "" {
+			parts = append(parts, cached.content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// resolvePromptInclude maps a workspace-relative include path to a file
+// path, rejecting paths that leave the workspace directly or via symlinks.
+func (cb *ContextBuilder) resolvePromptInclude(rel string) (string, error) {
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path must be relative and inside the workspace")
+	}
+	path := filepath.Join(cb.workspace, rel)
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(cb.workspace)
+	if err != nil {
+		return "", err
+	}
+	if within, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(within) {
+		return "", fmt.Errorf("path resolves outside the workspace")
+	}
+	return resolved, nil
+}
+
+// systemPromptForChannel applies the channel override, if any, to the base
+// system prompt.
+func (cb *ContextBuilder) systemPromptForChannel(channel string) string {
+	override, ok := cb.channelPrompts[strings.ToLower(strings.TrimSpace(channel))]
+	if !ok {
+		return cb.BuildSystemPrompt()
+	}
+	prompt := strings.TrimSpace(override.Prompt)
+	if override.Replace {
+		return prompt
+	}
+	return cb.BuildSystemPrompt() + "\n\n---\n\n# Channel Instructions\n\n" + prompt
+}
+
 func (cb *ContextBuilder) getIdentity() string {
 	today := time.Now().Format("2006-01-02 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
@@ -140,12 +261,17 @@ func (cb *ContextBuilder) BuildSystemPrompt() string {
 		parts = append(parts, bootstrapContent)
 	}
 
-	// Skills - show summary, AI can read full content with read_file tool
+	// Prompt includes configured by the user
+	if includes := cb.LoadPromptIncludes(); includes != "" {
+		parts = append(parts, includes)
+	}
+
+	// Skills - show summary, AI can read full content with the skills tool
 	skillsSummary := cb.skillsLoader.BuildSkillsSummary()
 	if skillsSummary != "" {
 		parts = append(parts, fmt.Sprintf(`# Skills
 
-The following skills extend your capabilities. To use a skill, read its SKILL.md file using the read_file tool.
+The following skills extend your capabilities. To use a skill, load its instructions with the skills tool (action=read).
 
 %s`, skillsSummary))
 	}
@@ -183,7 +309,7 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary string, currentMessage string, media []string, channel, chatID string) []providers.Message {
 	messages := []providers.Message{}
 
-	systemPrompt := cb.BuildSystemPrompt()
+	systemPrompt := cb.systemPromptForChannel(channel)
 
 	// Add Current Session info if provided
 	if channel != "" && chatID != "" {
@@ -204,10 +330,7 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 		})
 
 	// Log preview of system prompt (avoid logging huge content)
-	preview := systemPrompt
-	if len(preview) > 500 {
-		preview = preview[:500] + "... (truncated)"
-	}
+	preview := utils.Truncate(systemPrompt, 500)
 	logger.DebugCF("agent", "System prompt preview",
 		map[string]interface{}{
 			"preview": preview,
@@ -2,9 +2,13 @@ package agent
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -81,3 +85,100 @@ func TestBuildSystemPrompt_OmitsUnsafeApprovalRuleWhenSafeguardsDisabled(t *test
 		t.Fatalf("expected prompt to mention disabled safeguards")
 	}
 }
+
+func TestBuildMessages_AppendsChannelPromptOnlyForThatChannel(t *testing.T) {
+	cb := NewContextBuilder(t.TempDir())
+	cb.SetChannelPrompts(map[string]config.ChannelPromptConfig{
+		"Telegram": {Prompt: "Use rich formatting for Telegram."},
+	})
+
+	tg := cb.BuildMessages(nil, "", "hi", nil, "telegram", "123")
+	if !strings.Contains(tg[0].Content, "Use rich formatting for Telegram.") {
+		t.Fatalf("expected telegram addendum in system prompt")
+	}
+	if !strings.Contains(tg[0].Content, "## Current Date") {
+		t.Fatalf("expected base prompt to be kept when appending")
+	}
+
+	cli := cb.BuildMessages(nil, "", "hi", nil, "cli", "direct")
+	if strings.Contains(cli[0].Content, "Use rich formatting for Telegram.") {
+		t.Fatalf("did not expect telegram addendum for cli")
+	}
+}
+
+func TestBuildMessages_ChannelPromptReplacesBasePrompt(t *testing.T) {
+	cb := NewContextBuilder(t.TempDir())
+	cb.SetChannelPrompts(map[string]config.ChannelPromptConfig{
+		"sms": {Prompt: "Be terse.", Replace: true},
+	})
+
+	msgs := cb.BuildMessages(nil, "earlier", "hi", nil, "sms", "555")
+	content := msgs[0].Content
+	if !strings.HasPrefix(content, "Be terse.") {
+		t.Fatalf("expected channel prompt to replace base prompt, got:\n%s", content)
+	}
+	if strings.Contains(content, "## Current Date") {
+		t.Fatalf("did not expect base prompt when replace is set")
+	}
+	if !strings.Contains(content, "Chat ID: 555") || !strings.Contains(content, "earlier") {
+		t.Fatalf("expected session info and summary to be kept")
+	}
+}
+
+func TestBuildSystemPrompt_ReloadsEditedPromptInclude(t *testing.T) {
+	workspace := t.TempDir()
+	persona := filepath.Join(workspace, "PERSONA.md")
+	if err := os.WriteFile(persona, []byte("You are a pirate."), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(workspace, "prompts"), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "prompts", "RULES.md"), []byte("Never use emoji."), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	cb := NewContextBuilder(workspace)
+	cb.SetPromptIncludes([]string{"PERSONA.md", "missing.md", "prompts/RULES.md"})
+
+	prompt := cb.BuildSystemPrompt()
+	pirate := strings.Index(prompt, "You are a pirate.")
+	rules := strings.Index(prompt, "Never use emoji.")
+	if pirate < 0 || rules < 0 || pirate > rules {
+		t.Fatalf("expected includes in order, got:\n%s", prompt)
+	}
+
+	if err := os.WriteFile(persona, []byte("You are a robot."), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(persona, later, later); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+
+	prompt = cb.BuildSystemPrompt()
+	if strings.Contains(prompt, "You are a pirate.") || !strings.Contains(prompt, "You are a robot.") {
+		t.Fatalf("expected edited include in prompt, got:\n%s", prompt)
+	}
+}
+
+func TestLoadPromptIncludes_RejectsPathsOutsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "workspace")
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	secret := filepath.Join(root, "secret.md")
+	if err := os.WriteFile(secret, []byte("token=1"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Symlink(secret, filepath.Join(workspace, "link.md")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	cb := NewContextBuilder(workspace)
+	cb.SetPromptIncludes([]string{"../secret.md", secret, "link.md"})
+	if got := cb.LoadPromptIncludes(); got != "" {
+		t.Fatalf("expected no content from outside the workspace, got %q", got)
+	}
+}
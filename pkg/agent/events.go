@@ -0,0 +1,19 @@
+package agent
+
+import "github.com/sipeed/picoclaw/pkg/events"
+
+// SetEventBus publishes lifecycle events (messages, LLM and tool calls,
+// subagents, summarization) to b. Call it before Run; nil disables events.
+func (al *AgentLoop) SetEventBus(b *events.Bus) {
+	al.events = b
+	if al.subagents != nil {
+		al.subagents.ConfigureEvents(b)
+	}
+}
+
+func (al *AgentLoop) publishEvent(eventType, sessionKey, traceID string, data map[string]interface{}) {
+	if al.events == nil {
+		return
+	}
+	al.events.Publish(events.Event{Type: eventType, SessionKey: sessionKey, TraceID: traceID, Data: data})
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/events"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestProcessMessage_EmitsOrderedLifecycleEvents(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{
+			ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "noop", Arguments: map[string]interface{}{}}},
+			Usage:     &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 5, TotalTokens: 105},
+		},
+		{Content: "done", Usage: &providers.UsageInfo{PromptTokens: 120, CompletionTokens: 3, TotalTokens: 123}},
+	}}
+	al := newTestAgentLoop(t, prov, 5, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	defer al.bus.Close()
+
+	eventBus := events.NewBus()
+	sub, unsubscribe := eventBus.Subscribe(64)
+	defer unsubscribe()
+	al.SetEventBus(eventBus)
+
+	if _, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "telegram", ChatID: "42", SenderID: "user", SessionKey: "telegram:42", Content: "hello",
+	}); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+
+	// Events are published synchronously, so the turn's events are all queued.
+	var got []events.Event
+drain:
+	for {
+		select {
+		case e := <-sub:
+			got = append(got, e)
+		default:
+			break drain
+		}
+	}
+
+	types := make([]string, 0, len(got))
+	for _, e := range got {
+		types = append(types, e.Type)
+	}
+	want := []string{
+		events.MessageReceived,
+		events.LLMCallStart, events.LLMCallEnd,
+		events.ToolCallStart, events.ToolCallEnd,
+		events.LLMCallStart, events.LLMCallEnd,
+	}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("event types = %v, want %v", types, want)
+	}
+
+	traceID := got[0].TraceID
+	for _, e := range got {
+		if e.SessionKey != "telegram:42" || e.TraceID != traceID || traceID == "" {
+			t.Fatalf("event %s has session %q trace %q, want telegram:42 / %q", e.Type, e.SessionKey, e.TraceID, traceID)
+		}
+	}
+	if got[0].Data["content_chars"] != len("hello") {
+		t.Errorf("message_received data = %v", got[0].Data)
+	}
+	if got[2].Data["total_tokens"] != 105 || got[2].Data["tool_calls"] != 1 {
+		t.Errorf("first llm_call_end data = %v", got[2].Data)
+	}
+	if got[4].Data["tool"] != "noop" || got[4].Data["result_chars"] != 2 {
+		t.Errorf("tool_call_end data = %v", got[4].Data)
+	}
+	if got[6].Data["total_tokens"] != 123 {
+		t.Errorf("last llm_call_end data = %v", got[6].Data)
+	}
+}
@@ -2,12 +2,14 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -60,8 +62,8 @@ CONVERSATION:
 // extractAndStoreMemories asks the LLM to extract notable memories from
 // a set of messages and stores them in the memory DB. This is called
 // during session summarization so that important information survives
-// history compaction.
-func (al *AgentLoop) extractAndStoreMemories(ctx context.Context, messages []providers.Message) {
+// history compaction. Each memory records the session it came from.
+func (al *AgentLoop) extractAndStoreMemories(ctx context.Context, sessionKey string, messages []providers.Message) {
 	if al.memoryStore == nil {
 		return
 	}
@@ -98,9 +100,18 @@ func (al *AgentLoop) extractAndStoreMemories(ctx context.Context, messages []pro
 		return
 	}
 
+	nowFn := al.timeNow
+	if nowFn == nil {
+		nowFn = time.Now
+	}
+	metadata := memoryProvenance(sessionKey, nowFn())
+
 	stored := 0
 	for _, mem := range memories {
-		_, err := al.memoryStore.Store(mem.Content, mem.Category, "summarization", nil)
+		_, err := al.memoryStore.Store(mem.Content, mem.Category, "summarization", metadata)
+		if errors.Is(err, memory.ErrMemoryUnavailable) {
+			break // the store already logged that it is read-only
+		}
 		if err != nil {
 			logger.WarnCF("agent", "Failed to store extracted memory",
 				map[string]interface{}{
@@ -118,3 +129,21 @@ func (al *AgentLoop) extractAndStoreMemories(ctx context.Context, messages []pro
 			"stored":    stored,
 		})
 }
+
+// memoryProvenance describes where an extracted memory came from: the
+// session key, its channel and chat when the key has the usual
+// "channel:chat" form, and the date of extraction.
+func memoryProvenance(sessionKey string, now time.Time) map[string]string {
+	if sessionKey == "" {
+		return nil
+	}
+	metadata := map[string]string{
+		"session_key": sessionKey,
+		"date":        now.Format("2006-01-02"),
+	}
+	if channel, chatID, ok := strings.Cut(sessionKey, ":"); ok && channel != "" {
+		metadata["channel"] = channel
+		metadata["chat_id"] = chatID
+	}
+	return metadata
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// sendGroupMessage delivers content from sender in a Telegram group through
+// a channel with the given group session scope, as the gateway would.
+func sendGroupMessage(t *testing.T, al *AgentLoop, scope, senderID, content string) string {
+	t.Helper()
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+	ch := channels.NewBaseChannel("telegram", nil, mb, nil)
+	ch.SetGroupSessionScope(scope)
+	ch.HandleMessage(senderID, "-100123", content, nil, map[string]string{"is_group": "true"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	return msg.SessionKey
+}
+
+func userContents(history []providers.Message) []string {
+	var out []string
+	for _, m := range history {
+		if m.Role == "user" {
+			out = append(out, m.Content)
+		}
+	}
+	return out
+}
+
+func TestGroupSessionScope_SenderKeepsHistoriesApart(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "hi alice"}, {Content: "hi bob"}}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+
+	aliceKey := sendGroupMessage(t, al, "sender", "alice", "my password hint is blue")
+	bobKey := sendGroupMessage(t, al, "sender", "bob", "what did alice say?")
+
+	if aliceKey == bobKey {
+		t.Fatalf("expected separate sessions, both got %q", aliceKey)
+	}
+	if got := userContents(al.sessions.GetHistory(bobKey)); len(got) != 1 || strings.Contains(strings.Join(got, " "), "blue") {
+		t.Fatalf("bob's history leaks alice's message: %q", got)
+	}
+	if got := userContents(al.sessions.GetHistory(aliceKey)); len(got) != 1 || !strings.Contains(got[0], "blue") {
+		t.Fatalf("alice's history = %q", got)
+	}
+
+	// The second call sees only bob's own conversation.
+	calls := prov.getCalls()
+	for _, m := range calls[1].Messages {
+		if strings.Contains(m.Content, "password hint") {
+			t.Fatalf("bob's request included alice's message: %q", m.Content)
+		}
+	}
+}
+
+func TestGroupSessionScope_ChatSharesHistory(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "hi alice"}, {Content: "hi bob"}}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+
+	aliceKey := sendGroupMessage(t, al, "chat", "alice", "my password hint is blue")
+	bobKey := sendGroupMessage(t, al, "chat", "bob", "what did alice say?")
+
+	if aliceKey != "telegram:-100123" || bobKey != aliceKey {
+		t.Fatalf("expected one shared chat session, got %q and %q", aliceKey, bobKey)
+	}
+	if got := userContents(al.sessions.GetHistory(aliceKey)); len(got) != 2 {
+		t.Fatalf("expected both messages in the shared history, got %q", got)
+	}
+}
+
+func TestProcessSystemMessage_SubagentReportUsesSenderSession(t *testing.T) {
+	prov := &mockProvider{}
+	al := newTestAgentLoop(t, prov, 5, nil)
+
+	_, err := al.processSystemMessage(context.Background(), bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "subagent:subagent-1",
+		ChatID:   "telegram:-100123",
+		Content:  "halfway done",
+		Metadata: map[string]string{
+			"subagent_event":     "progress",
+			"origin_session_key": "telegram:-100123:alice",
+		},
+	}, "trace-1")
+	if err != nil {
+		t.Fatalf("processSystemMessage: %v", err)
+	}
+
+	if got := al.sessions.GetHistory("telegram:-100123:alice"); len(got) != 1 || !strings.Contains(got[0].Content, "halfway done") {
+		t.Fatalf("expected the update in alice's session, got %+v", got)
+	}
+	if got := al.sessions.GetHistory("telegram:-100123"); len(got) != 0 {
+		t.Fatalf("expected the chat session untouched, got %+v", got)
+	}
+}
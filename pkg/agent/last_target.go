@@ -22,6 +22,10 @@ func (al *AgentLoop) recordLastActiveTarget(msg bus.InboundMessage) {
 	if strings.HasPrefix(strings.TrimSpace(msg.SessionKey), "cron-") {
 		return
 	}
+	// Cron jobs may run in a chat's own session; they are still not activity.
+	if msg.SenderID == "cron" {
+		return
+	}
 
 	path := cron.LastTargetPath(al.workspace)
 	if err := cron.SaveLastTarget(path, cron.LastTarget{Channel: channel, ChatID: chatID}); err != nil {
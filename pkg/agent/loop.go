@@ -15,9 +15,11 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/events"
 	"github.com/sipeed/picoclaw/pkg/llmloop"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/memory"
@@ -30,38 +32,69 @@ import (
 )
 
 type AgentLoop struct {
-	bus                *bus.MessageBus
-	provider           providers.LLMProvider
-	workspace          string
-	model              string
-	contextWindow      int                   // Maximum context window size in tokens
-	chatOptions        providers.ChatOptions // Standard chat response options
-	compactOptions     providers.ChatOptions // Summarization/extraction options
-	messageBudget      providers.MessageBudget
-	maxIterations      int
-	llmTimeout         time.Duration // Per-LLM-call timeout (0 = disabled)
-	toolTimeout        time.Duration // Per-tool-call timeout (0 = disabled)
-	maxParallelTools   int           // Max concurrent tools per iteration (<=0 = unlimited)
-	sessions           *session.SessionManager
-	contextBuilder     *ContextBuilder
-	tools              *tools.ToolRegistry
-	unsafeGate         *tools.UnsafeToolGate
-	traceSeq           atomic.Uint64
-	running            atomic.Bool
-	summarizing        sync.Map            // Tracks which sessions are currently being summarized
-	progressTrackers   sync.Map            // Run-scoped DeltaChat tool progress trackers
-	memoryStore        *memory.MemoryStore // Searchable memory DB (nil = disabled)
-	modelCapabilities  providers.ModelCapabilities
-	visionAnalyzer     imageAnalyzer
-	echoToolCalls      bool // Echo tool calls to chat channel
-	safeguardsDisabled bool // Global tool safeguards disabled by config
-	timeContextMu      sync.Mutex
-	lastTimeContext    map[string]time.Time
-	timeContextEvery   time.Duration
-	timeNow            func() time.Time
+	bus                   *bus.MessageBus
+	provider              providers.LLMProvider
+	workspace             string
+	model                 string
+	contextWindow         int                   // Context window for models without a known size
+	contextWindows        map[string]int        // Per-model context window overrides
+	chatOptions           providers.ChatOptions // Standard chat response options
+	compactOptions        providers.ChatOptions // Summarization/extraction options
+	messageBudget         providers.MessageBudget
+	maxIterations         int
+	toolLoopThreshold     int           // Identical tool calls before a loop warning (<2 = disabled)
+	autoContinueMax       int           // Automatic "continue" resumes after iteration exhaustion (0 = disabled)
+	autoContinueAll       bool          // Also auto-continue runs a user is waiting on, not only unattended ones
+	bestOfN               int           // Final-answer samples to choose from (<2 = disabled)
+	bestOfNJudgeModel     string        // Model that picks among samples ("" = majority vote)
+	maxConcurrentMessages int           // Sessions processed at once by Run (<=1 = one at a time)
+	messagePreviewChars   int           // Inbound message chars quoted in logs (<=0 = default)
+	responsePreviewChars  int           // Final answer chars quoted in logs (<=0 = default)
+	llmTimeout            time.Duration // Per-LLM-call timeout (0 = disabled)
+	turnMaxRetries        int           // Provider retries shared by one turn (0 = unlimited)
+	turnMaxRetryWait      time.Duration // Cumulative retry backoff per turn (0 = unlimited)
+	toolTimeout           time.Duration // Per-tool-call timeout (0 = disabled)
+	maxParallelTools      int           // Max concurrent tools per iteration (<=0 = unlimited)
+	toolSummarizeOver     int           // Tool result bytes above which it is summarized (0 = disabled)
+	subagentEvents        map[string]string
+	workspaceIsolation    tools.WorkspaceIsolation // Per-chat root for file tools and saved tool output
+	sessions              *session.SessionManager
+	contextBuilder        *ContextBuilder
+	tools                 *tools.ToolRegistry
+	unsafeGate            *tools.UnsafeToolGate
+	traceSeq              atomic.Uint64
+	running               atomic.Bool
+	summarizing           sync.Map            // Tracks which sessions are currently being summarized
+	progressTrackers      sync.Map            // Run-scoped DeltaChat tool progress trackers
+	memoryStore           *memory.MemoryStore // Searchable memory DB (nil = disabled)
+	subagents             *tools.SubagentManager
+	modelCapabilities     providers.ModelCapabilities
+	visionAnalyzer        imageAnalyzer
+	echoToolCalls         bool          // Echo tool calls to chat channel
+	echoInterimText       bool          // Echo prose returned alongside tool calls to chat channel
+	toolAudit             *toolAuditLog // nil unless agents.defaults.audit_tools is set
+	events                *events.Bus   // Lifecycle events for integrations (nil = disabled)
+	tokenCounts           tokenCountCache
+	statusDelay           time.Duration // "Still working" status message cadence (0 = disabled)
+	statusMessages        []string      // Rotating status phrases (empty = built-in defaults)
+	toolProgressInterval  time.Duration // Min gap between forwarded progress lines per tool call (0 = disabled)
+	emptyResponse         string        // Reply when the model returns nothing, even after a nudge
+	safeguardsDisabled    bool          // Global tool safeguards disabled by config
+	execDryRun            bool          // tools.exec.dry_run: exec only describes commands in every session
+	commands              *commandRegistry
+	modelProvider         func(model string) (providers.LLMProvider, error) // nil = every model uses provider
+	modelProviders        sync.Map                                          // Cached providers for session model overrides
+	commandsOnce          sync.Once
+	sessionRuns           sessionLocks // One run at a time per session, across all callers
+	timeContextMu         sync.Mutex
+	lastTimeContext       map[string]time.Time
+	timeContextEvery      time.Duration
+	timeNow               func() time.Time
 }
 
 const (
+	defaultEmptyResponse       = "I've completed processing but have no response to give."
+	emptyResponseNudge         = "Your last reply was empty. Please give your final answer to the user now."
 	defaultTimeContextInterval = 30 * time.Minute
 	timeContextPruneAfter      = 24 * time.Hour
 	timeContextPruneThreshold  = 2048
@@ -72,10 +105,14 @@ type processOptions struct {
 	SessionKey      string // Session identifier for history/context
 	Channel         string // Target channel for tool execution
 	ChatID          string // Target chat ID for tool execution
+	MessageID       string // Inbound message being answered ("message_id" metadata), if any
 	TraceID         string // Correlation ID for logs across one processing flow
 	UserMessage     string // User message content (may include prefix)
 	UserMedia       []string
+	SystemContext   string // Added to this turn's system prompt, not saved to history
 	DefaultResponse string // Response when LLM returns empty
+	NudgeOnEmpty    bool   // Ask the LLM once more before falling back to DefaultResponse
+	Unattended      bool   // No user is waiting (cron, heartbeat, system messages)
 	EnableSummary   bool   // Whether to trigger summarization
 	SendResponse    bool   // Deprecated: user-visible replies must use message tool
 }
@@ -88,7 +125,9 @@ type processTaskResult struct {
 	interrupted bool
 }
 
-func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
+// NewAgentLoop builds the agent from cfg. It fails when a tools.exec pattern
+// does not compile.
+func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) (*AgentLoop, error) {
 	workspace := cfg.WorkspacePath()
 	os.MkdirAll(workspace, 0755)
 	provider = providers.NewUsageTrackingProvider(provider, workspace)
@@ -103,7 +142,13 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		unsafeGate = tools.NewUnsafeToolGate(10 * time.Minute)
 		toolsRegistry.SetUnsafeToolGate(unsafeGate)
 	}
-	tools.RegisterCoreTools(toolsRegistry, workspace, tools.WebSearchToolConfig{
+	toolsRegistry.SetArgValidation(!cfg.Tools.ArgValidation.Disabled)
+	toolsRegistry.SetPreviewChars(cfg.Logging.ToolPreviewChars)
+	toolsRegistry.SetResultLimit(cfg.Tools.Results.MaxBytes, cfg.Tools.Results.PerTool)
+	if ttl := cfg.Tools.Cache.TTLSeconds; ttl > 0 {
+		toolsRegistry.EnableResultCache(cfg.Tools.Cache.MaxEntries, time.Duration(ttl)*time.Second)
+	}
+	webSearchToolCfg := tools.WebSearchToolConfig{
 		BraveAPIKey:     webSearchCfg.APIKey,
 		MaxResults:      webSearchCfg.MaxResults,
 		Provider:        webSearchCfg.Provider,
@@ -112,7 +157,27 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		ZAIMCPURL:       webSearchCfg.ZAIMCPURL,
 		ZAILocation:     webSearchCfg.ZAILocation,
 		ZAISearchEngine: webSearchCfg.ZAISearchEngine,
-	}, tools.CoreToolsOptions{DisableSafeguards: safeguardsDisabled})
+	}
+	// LoadConfig rejects unknown modes; code-built configs fall back to shared.
+	workspaceIsolation, err := tools.ParseWorkspaceIsolation(cfg.Agents.Defaults.WorkspaceIsolation)
+	if err != nil {
+		logger.WarnCF("agent", "Workspace isolation disabled", map[string]interface{}{"error": err.Error()})
+	}
+	coreToolsOpts := tools.CoreToolsOptions{
+		DisableSafeguards:   safeguardsDisabled,
+		ExecMaxOutputBytes:  cfg.Tools.Exec.MaxOutputBytes,
+		ExecDenyPatterns:    cfg.Tools.Exec.DenyPatterns,
+		ExecAllowPatterns:   cfg.Tools.Exec.AllowPatterns,
+		ExecConfirmPatterns: cfg.Tools.Exec.ConfirmPatterns,
+		ExecConfirmTTL:      time.Duration(cfg.Tools.Exec.ConfirmTTLSeconds) * time.Second,
+		ExecDryRun:          cfg.Tools.Exec.DryRun,
+		WorkspaceIsolation:  workspaceIsolation,
+	}
+	// LoadConfig already rejects bad patterns; this catches configs built
+	// in code.
+	if err := tools.RegisterCoreTools(toolsRegistry, workspace, webSearchToolCfg, coreToolsOpts); err != nil {
+		return nil, fmt.Errorf("invalid tools.exec configuration: %w", err)
+	}
 
 	policyEnabled := !safeguardsDisabled && (cfg.Tools.Policy.Enabled || cfg.Tools.Policy.SafeMode || len(cfg.Tools.Policy.Allow) > 0 || len(cfg.Tools.Policy.Deny) > 0)
 	denyTools := append([]string{}, cfg.Tools.Policy.Deny...)
@@ -121,6 +186,7 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 			"exec",
 			"write_file",
 			"edit_file",
+			"patch_file",
 			"unsafe_exec",
 			"unsafe_read_file",
 			"unsafe_write_file",
@@ -136,6 +202,7 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Register spawn tool
 	subagentManager := tools.NewSubagentManager(provider, cfg.Agents.Defaults.Model, workspace, msgBus)
 	subagentManager.ConfigureDisableToolSafeguards(safeguardsDisabled)
+	subagentManager.ConfigureCoreTools(coreToolsOpts)
 	subagentManager.ConfigureExecution(
 		time.Duration(cfg.Agents.Defaults.LLMTimeoutSeconds)*time.Second,
 		time.Duration(cfg.Agents.Defaults.ToolTimeoutSeconds)*time.Second,
@@ -147,13 +214,24 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		cfg.Agents.Defaults.SubagentMaxTasks,
 		time.Duration(cfg.Agents.Defaults.SubagentCompletedTTLSeconds)*time.Second,
 	)
+	subagentManager.ConfigureMaxDepth(cfg.Agents.Defaults.SubagentMaxDepth)
+	subagentManager.ConfigureConcurrency(cfg.Agents.Defaults.SubagentMaxConcurrent)
+	subagentManager.ConfigureToolLoopThreshold(cfg.Agents.Defaults.ToolLoopThreshold)
+	subagentManager.ConfigureArgValidation(!cfg.Tools.ArgValidation.Disabled)
+	subagentManager.ConfigureToolResultLimit(cfg.Tools.Results.MaxBytes, cfg.Tools.Results.PerTool)
 	spawnTool := tools.NewSpawnTool(subagentManager)
 	toolsRegistry.Register(spawnTool)
 	subagentManager.ConfigureUnsafeToolGate(unsafeGate)
 
 	// Register memory tools (graceful degradation if SQLite init fails)
 	memoryDBPath := filepath.Join(workspace, "memory", "memory.db")
-	memoryDB, err := memory.NewMemoryStore(memoryDBPath, workspace)
+	memoryDB, err := memory.NewMemoryStoreWithOptions(memoryDBPath, workspace, memory.StoreOptions{
+		Tokenizer:        cfg.Tools.Memory.FTSTokenizer,
+		MarkdownTargets:  cfg.Tools.Memory.MarkdownTargets,
+		DisableMarkdown:  cfg.Tools.Memory.DBOnly,
+		ReindexWorkers:   cfg.Tools.Memory.ReindexWorkers,
+		ReindexBatchSize: cfg.Tools.Memory.ReindexBatchSize,
+	})
 	if err != nil {
 		logger.WarnCF("agent", "Memory DB unavailable, memory tools disabled", map[string]interface{}{"error": err.Error()})
 	} else {
@@ -163,16 +241,26 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		}
 		toolsRegistry.Register(tools.NewMemorySearchTool(memoryDB))
 		toolsRegistry.Register(tools.NewMemoryStoreTool(memoryDB))
+		toolsRegistry.Register(tools.NewMemoryUpdateTool(memoryDB))
+		toolsRegistry.Register(tools.NewMemoryStatsTool(memoryDB))
+		toolsRegistry.Register(tools.NewMemoryListTool(memoryDB))
+		memoryExport := tools.NewMemoryExportTool(memoryDB, workspace)
+		memoryExport.SetWorkspaceIsolation(workspaceIsolation)
+		toolsRegistry.Register(memoryExport)
 	}
 
 	// memoryDB may be nil — that's fine, extractAndStoreMemories handles it
 
 	sessionsManager := session.NewSessionManager(filepath.Join(workspace, "sessions"))
+	sessionsManager.SetEviction(cfg.Agents.Defaults.SessionMaxInMemory,
+		time.Duration(cfg.Agents.Defaults.SessionIdleTTLSeconds)*time.Second)
 
 	// Create context builder and set tools registry
 	contextBuilder := NewContextBuilder(workspace)
 	contextBuilder.SetToolsRegistry(toolsRegistry)
 	contextBuilder.SetUnsafeApprovalRequired(!safeguardsDisabled)
+	contextBuilder.SetChannelPrompts(cfg.Agents.Defaults.ChannelPrompts)
+	contextBuilder.SetPromptIncludes(cfg.Agents.Defaults.PromptIncludes)
 
 	if safeguardsDisabled {
 		logger.WarnCF("agent", "Tool safeguards are DISABLED by configuration",
@@ -185,6 +273,7 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	}
 
 	outputMaxTokens, contextWindow := resolveTokenLimits(cfg.Agents.Defaults)
+	subagentManager.ConfigureContextWindows(cfg.Agents.Defaults.ContextWindows, contextWindow)
 	anthropicCacheTTL := strings.TrimSpace(cfg.Agents.Defaults.AnthropicCacheTTL)
 	subagentManager.ConfigureCache(cfg.Agents.Defaults.AnthropicCache, anthropicCacheTTL)
 
@@ -237,43 +326,85 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		))
 	}
 
+	var toolAudit *toolAuditLog
+	if cfg.Agents.Defaults.AuditTools {
+		toolAudit = newToolAuditLog(workspace)
+		subagentManager.ConfigureToolAudit(toolAudit.recordSubagent)
+	}
+
+	// Session model overrides (/model) get their own provider, so a chat can
+	// switch to a model served by a different configured provider. It keeps
+	// the configured fallback models, like the default provider.
+	modelProvider := func(model string) (providers.LLMProvider, error) {
+		p, err := providers.CreateProviderWithFallbacks(cfg, model)
+		if err != nil {
+			return nil, err
+		}
+		return providers.NewUsageTrackingProvider(p, workspace), nil
+	}
+
 	return &AgentLoop{
-		bus:           msgBus,
-		provider:      provider,
-		workspace:     workspace,
-		model:         cfg.Agents.Defaults.Model,
-		contextWindow: contextWindow,
+		bus:            msgBus,
+		provider:       provider,
+		workspace:      workspace,
+		model:          cfg.Agents.Defaults.Model,
+		contextWindow:  contextWindow,
+		contextWindows: cfg.Agents.Defaults.ContextWindows,
 		chatOptions: providers.ChatOptions{
 			MaxTokens:         outputMaxTokens,
 			Temperature:       chatTemperature,
 			AnthropicCache:    cfg.Agents.Defaults.AnthropicCache,
 			AnthropicCacheTTL: anthropicCacheTTL,
+			Seed:              cfg.Agents.Defaults.Seed,
 		},
 		compactOptions: providers.ChatOptions{
 			MaxTokens:         1024,
 			Temperature:       0.3,
 			AnthropicCache:    cfg.Agents.Defaults.AnthropicCache,
 			AnthropicCacheTTL: anthropicCacheTTL,
+			Seed:              cfg.Agents.Defaults.Seed,
 		},
-		messageBudget:      messageBudget,
-		maxIterations:      cfg.Agents.Defaults.MaxToolIterations,
-		llmTimeout:         time.Duration(cfg.Agents.Defaults.LLMTimeoutSeconds) * time.Second,
-		toolTimeout:        time.Duration(cfg.Agents.Defaults.ToolTimeoutSeconds) * time.Second,
-		maxParallelTools:   cfg.Agents.Defaults.MaxParallelToolCalls,
-		sessions:           sessionsManager,
-		contextBuilder:     contextBuilder,
-		tools:              toolsRegistry,
-		unsafeGate:         unsafeGate,
-		summarizing:        sync.Map{},
-		memoryStore:        memoryDB,
-		modelCapabilities:  modelCaps,
-		visionAnalyzer:     visionAnalyzer,
-		echoToolCalls:      cfg.Agents.Defaults.EchoToolCalls,
-		safeguardsDisabled: safeguardsDisabled,
-		lastTimeContext:    make(map[string]time.Time),
-		timeContextEvery:   defaultTimeContextInterval,
-		timeNow:            time.Now,
-	}
+		messageBudget:         messageBudget,
+		maxIterations:         cfg.Agents.Defaults.MaxToolIterations,
+		toolLoopThreshold:     cfg.Agents.Defaults.ToolLoopThreshold,
+		autoContinueMax:       cfg.Agents.Defaults.AutoContinueMax,
+		autoContinueAll:       cfg.Agents.Defaults.AutoContinueInteractive,
+		bestOfN:               cfg.Agents.Defaults.BestOfN,
+		maxConcurrentMessages: cfg.Agents.Defaults.MaxConcurrentMessages,
+		messagePreviewChars:   cfg.Logging.MessagePreviewChars,
+		responsePreviewChars:  cfg.Logging.ResponsePreviewChars,
+		bestOfNJudgeModel:     strings.TrimSpace(cfg.Agents.Defaults.BestOfNJudgeModel),
+		llmTimeout:            time.Duration(cfg.Agents.Defaults.LLMTimeoutSeconds) * time.Second,
+		turnMaxRetries:        cfg.Agents.Defaults.LLMTurnMaxRetries,
+		turnMaxRetryWait:      time.Duration(cfg.Agents.Defaults.LLMTurnMaxRetryWaitSeconds) * time.Second,
+		toolTimeout:           time.Duration(cfg.Agents.Defaults.ToolTimeoutSeconds) * time.Second,
+		maxParallelTools:      cfg.Agents.Defaults.MaxParallelToolCalls,
+		toolSummarizeOver:     cfg.Tools.Results.SummarizeOverBytes,
+		workspaceIsolation:    workspaceIsolation,
+		subagentEvents:        cfg.Agents.Defaults.SubagentEvents,
+		sessions:              sessionsManager,
+		contextBuilder:        contextBuilder,
+		tools:                 toolsRegistry,
+		unsafeGate:            unsafeGate,
+		summarizing:           sync.Map{},
+		memoryStore:           memoryDB,
+		subagents:             subagentManager,
+		modelCapabilities:     modelCaps,
+		visionAnalyzer:        visionAnalyzer,
+		echoToolCalls:         cfg.Agents.Defaults.EchoToolCalls,
+		echoInterimText:       cfg.Agents.Defaults.EchoInterimText,
+		toolAudit:             toolAudit,
+		statusDelay:           time.Duration(cfg.Agents.Defaults.StatusDelaySeconds) * time.Second,
+		statusMessages:        cfg.Agents.Defaults.StatusMessages,
+		toolProgressInterval:  time.Duration(cfg.Agents.Defaults.ToolProgressIntervalSeconds) * time.Second,
+		emptyResponse:         cfg.Agents.Defaults.EmptyResponse,
+		safeguardsDisabled:    safeguardsDisabled,
+		execDryRun:            cfg.Tools.Exec.DryRun,
+		modelProvider:         modelProvider,
+		lastTimeContext:       make(map[string]time.Time),
+		timeContextEvery:      defaultTimeContextInterval,
+		timeNow:               time.Now,
+	}, nil
 }
 
 func resolveZAISearchCredentials(webCfg config.WebSearchConfig, providersCfg config.ProvidersConfig) (string, string) {
@@ -359,71 +490,22 @@ func resolvePrimaryVisionAnalyzer(cfg *config.Config) (*vision.Client, string) {
 }
 
 func resolveOpenAICompatibleProviderForModel(cfg *config.Config, model string) (apiKey, apiBase string, ok bool) {
-	lowerModel := strings.ToLower(strings.TrimSpace(model))
-
-	switch {
-	case strings.HasPrefix(model, "openrouter/") || strings.HasPrefix(model, "anthropic/") || strings.HasPrefix(model, "openai/") || strings.HasPrefix(model, "meta-llama/") || strings.HasPrefix(model, "deepseek/") || strings.HasPrefix(model, "google/"):
-		apiKey = strings.TrimSpace(cfg.Providers.OpenRouter.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.OpenRouter.APIBase)
-		if apiBase == "" {
-			apiBase = "https://openrouter.ai/api/v1"
-		}
-		return apiKey, apiBase, apiKey != ""
-
-	case (strings.Contains(lowerModel, "claude") || strings.HasPrefix(model, "anthropic/")) && (cfg.Providers.Anthropic.APIKey != "" || cfg.Providers.Anthropic.AuthMethod != ""):
+	route, err := cfg.ResolveModel(model)
+	if err != nil {
 		return "", "", false
-
-	case (strings.Contains(lowerModel, "gpt") || strings.HasPrefix(model, "openai/")) && (cfg.Providers.OpenAI.APIKey != "" || cfg.Providers.OpenAI.AuthMethod != ""):
-		apiKey = strings.TrimSpace(cfg.Providers.OpenAI.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.OpenAI.APIBase)
-		if apiBase == "" {
-			apiBase = "https://api.openai.com/v1"
-		}
-		return apiKey, apiBase, apiKey != ""
-
-	case (strings.Contains(lowerModel, "gemini") || strings.HasPrefix(model, "google/")) && cfg.Providers.Gemini.APIKey != "":
+	}
+	switch route.Provider {
+	case config.ProviderAnthropic, config.ProviderGemini:
+		// Native APIs, not OpenAI-compatible.
 		return "", "", false
-
-	case (strings.Contains(lowerModel, "glm") || strings.Contains(lowerModel, "zhipu") || strings.Contains(lowerModel, "zai")) && cfg.Providers.Zhipu.APIKey != "":
-		apiKey = strings.TrimSpace(cfg.Providers.Zhipu.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.Zhipu.APIBase)
-		if apiBase == "" {
-			apiBase = "https://open.bigmodel.cn/api/paas/v4"
-		}
-		return apiKey, apiBase, apiKey != ""
-
-	case (strings.Contains(lowerModel, "groq") || strings.HasPrefix(model, "groq/")) && cfg.Providers.Groq.APIKey != "":
-		apiKey = strings.TrimSpace(cfg.Providers.Groq.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.Groq.APIBase)
-		if apiBase == "" {
-			apiBase = "https://api.groq.com/openai/v1"
-		}
-		return apiKey, apiBase, apiKey != ""
-
-	case (strings.Contains(lowerModel, "glm-5") || strings.HasPrefix(lowerModel, "zai-org/")) && cfg.Providers.Modal.APIKey != "":
-		apiKey = strings.TrimSpace(cfg.Providers.Modal.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.Modal.APIBase)
-		if apiBase == "" {
-			apiBase = "https://api.us-west-2.modal.direct/v1"
-		}
-		return apiKey, apiBase, apiKey != ""
-
-	case strings.TrimSpace(cfg.Providers.VLLM.APIBase) != "":
-		apiKey = strings.TrimSpace(cfg.Providers.VLLM.APIKey)
-		apiBase = strings.TrimSpace(cfg.Providers.VLLM.APIBase)
+	}
+	providerCfg, _ := cfg.ProviderByName(route.Provider)
+	apiKey = strings.TrimSpace(providerCfg.APIKey)
+	apiBase = strings.TrimSpace(route.APIBase)
+	if route.Provider == config.ProviderVLLM {
 		return apiKey, apiBase, true
-
-	default:
-		if cfg.Providers.OpenRouter.APIKey != "" {
-			apiKey = strings.TrimSpace(cfg.Providers.OpenRouter.APIKey)
-			apiBase = strings.TrimSpace(cfg.Providers.OpenRouter.APIBase)
-			if apiBase == "" {
-				apiBase = "https://openrouter.ai/api/v1"
-			}
-			return apiKey, apiBase, true
-		}
-		return "", "", false
 	}
+	return apiKey, apiBase, apiKey != ""
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
@@ -449,36 +531,47 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	pendingBySession := make(map[string]bus.InboundMessage)
 	pendingOrder := make([]string, 0)
 
-	var activeDone <-chan processTaskResult
-	var activeCancel context.CancelFunc
-	activeSessionKey := ""
+	// Up to maxConcurrent sessions run at once, but never two runs of the
+	// same session: a session's next message waits until its active run
+	// finishes, so history is appended in order.
+	maxConcurrent := al.maxConcurrentMessages
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	activeRuns := make(map[string]context.CancelFunc)
+	done := make(chan processTaskResult, maxConcurrent)
 
-	startNext := func() {
-		if activeDone != nil || len(pendingOrder) == 0 {
-			return
+	cancelActive := func() {
+		for _, cancel := range activeRuns {
+			cancel()
 		}
+	}
 
-		sessionKey := pendingOrder[0]
-		pendingOrder = pendingOrder[1:]
-		msg := pendingBySession[sessionKey]
-		delete(pendingBySession, sessionKey)
-
-		procCtx, cancel := context.WithCancel(ctx)
-		done := make(chan processTaskResult, 1)
-		activeCancel = cancel
-		activeDone = done
-		activeSessionKey = sessionKey
-
-		go func(procCtx context.Context, msg bus.InboundMessage, sessionKey string, done chan<- processTaskResult) {
-			response, err := al.processMessage(procCtx, msg)
-			done <- processTaskResult{
-				message:     msg,
-				sessionKey:  sessionKey,
-				response:    response,
-				err:         err,
-				interrupted: procCtx.Err() != nil,
+	startNext := func() {
+		for i := 0; i < len(pendingOrder) && len(activeRuns) < maxConcurrent; {
+			sessionKey := pendingOrder[i]
+			if _, busy := activeRuns[sessionKey]; busy {
+				i++
+				continue
 			}
-		}(procCtx, msg, sessionKey, done)
+			pendingOrder = append(pendingOrder[:i], pendingOrder[i+1:]...)
+			msg := pendingBySession[sessionKey]
+			delete(pendingBySession, sessionKey)
+
+			procCtx, cancel := context.WithCancel(ctx)
+			activeRuns[sessionKey] = cancel
+
+			go func(procCtx context.Context, msg bus.InboundMessage, sessionKey string) {
+				response, err := al.processMessage(procCtx, msg)
+				done <- processTaskResult{
+					message:     msg,
+					sessionKey:  sessionKey,
+					response:    response,
+					err:         err,
+					interrupted: procCtx.Err() != nil,
+				}
+			}(procCtx, msg, sessionKey)
+		}
 	}
 
 	for al.running.Load() {
@@ -486,22 +579,18 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 
 		select {
 		case <-ctx.Done():
-			if activeCancel != nil {
-				activeCancel()
-			}
+			cancelActive()
 			return nil
 		case msg, ok := <-inboundCh:
 			if !ok {
-				if activeCancel != nil {
-					activeCancel()
-				}
+				cancelActive()
 				return nil
 			}
 
 			sessionKey := inboundSessionKey(msg)
 			msg.SessionKey = sessionKey
 
-			if shouldInterruptActiveRun(msg) && activeDone != nil && activeSessionKey == sessionKey && activeCancel != nil {
+			if activeCancel, active := activeRuns[sessionKey]; active && shouldInterruptActiveRun(msg) {
 				logger.InfoCF("agent", "Interrupting active run due to newer user message",
 					map[string]interface{}{
 						"session_key": sessionKey,
@@ -515,10 +604,11 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				pendingOrder = append(pendingOrder, sessionKey)
 			}
 			pendingBySession[sessionKey] = msg
-		case res := <-activeDone:
-			activeDone = nil
-			activeCancel = nil
-			activeSessionKey = ""
+		case res := <-done:
+			if cancel, ok := activeRuns[res.sessionKey]; ok {
+				cancel()
+				delete(activeRuns, res.sessionKey)
+			}
 
 			if res.interrupted {
 				logger.InfoCF("agent", "Message processing interrupted",
@@ -537,7 +627,9 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 						"channel":     res.message.Channel,
 						"chat_id":     res.message.ChatID,
 						"error":       res.err.Error(),
+						"error_kind":  string(providers.ProviderErrorKindOf(res.err)),
 					})
+				al.reportProcessingError(res.message, res.err)
 				continue
 			}
 
@@ -553,13 +645,24 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 		}
 	}
 
-	if activeCancel != nil {
-		activeCancel()
-	}
+	cancelActive()
 
 	return nil
 }
 
+// Log preview lengths used when logging.*_preview_chars is unset.
+const (
+	defaultMessagePreviewChars  = 80
+	defaultResponsePreviewChars = 120
+)
+
+func previewLimit(configured, fallback int) int {
+	if configured > 0 {
+		return configured
+	}
+	return fallback
+}
+
 func inboundSessionKey(msg bus.InboundMessage) string {
 	if sessionKey := strings.TrimSpace(msg.SessionKey); sessionKey != "" {
 		return sessionKey
@@ -589,6 +692,13 @@ func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey stri
 }
 
 func (al *AgentLoop) ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error) {
+	return al.ProcessDirectWithSystemContext(ctx, content, "", sessionKey, channel, chatID)
+}
+
+// ProcessDirectWithSystemContext runs a turn like ProcessDirectWithChannel,
+// with systemContext added to the system prompt of this turn only. It is
+// never saved to the session.
+func (al *AgentLoop) ProcessDirectWithSystemContext(ctx context.Context, content, systemContext, sessionKey, channel, chatID string) (string, error) {
 	msg := bus.InboundMessage{
 		Channel:    channel,
 		SenderID:   "cron",
@@ -597,10 +707,14 @@ func (al *AgentLoop) ProcessDirectWithChannel(ctx context.Context, content, sess
 		SessionKey: sessionKey,
 	}
 
-	return al.processMessage(ctx, msg)
+	return al.processInbound(ctx, msg, systemContext)
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	return al.processInbound(ctx, msg, "")
+}
+
+func (al *AgentLoop) processInbound(ctx context.Context, msg bus.InboundMessage, systemContext string) (string, error) {
 	traceID := ""
 	if msg.Metadata != nil {
 		traceID = msg.Metadata["trace_id"]
@@ -614,7 +728,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	al.recordLastActiveTarget(msg)
 
 	// Add message preview to log
-	preview := utils.Truncate(msg.Content, 80)
+	preview := utils.Truncate(msg.Content, previewLimit(al.messagePreviewChars, defaultMessagePreviewChars))
 	logFields := map[string]interface{}{
 		"channel":     msg.Channel,
 		"chat_id":     msg.ChatID,
@@ -634,6 +748,13 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 
 	logger.InfoCF("agent", fmt.Sprintf("Processing message from %s:%s: %s", msg.Channel, msg.SenderID, preview),
 		logFields)
+	al.publishEvent(events.MessageReceived, msg.SessionKey, traceID, map[string]interface{}{
+		"channel":       msg.Channel,
+		"chat_id":       msg.ChatID,
+		"sender_id":     msg.SenderID,
+		"content_chars": len(msg.Content),
+		"media_count":   len(msg.Media),
+	})
 
 	// Route system messages to processSystemMessage
 	if msg.Channel == "system" {
@@ -661,6 +782,23 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		}
 	}
 
+	// Slash commands are answered directly; unknown ones go to the LLM.
+	commandMsg := msg
+	commandMsg.SessionKey = normalizeSessionKey(msg.SessionKey, msg.Channel, msg.ChatID)
+	if reply, ok := al.handleChatCommand(ctx, commandMsg); ok {
+		if msg.Channel == "cli" || msg.ChatID == "" {
+			return reply, nil
+		}
+		// Implicit responses are not delivered (see Run), so send it here.
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Content: reply,
+			ReplyTo: msg.Metadata["message_id"],
+		})
+		return "", nil
+	}
+
 	userMessage := msg.Content
 	var userMedia []string
 	if len(msg.Media) > 0 {
@@ -672,10 +810,14 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		SessionKey:      msg.SessionKey,
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
+		MessageID:       msg.Metadata["message_id"],
 		TraceID:         traceID,
 		UserMessage:     userMessage,
 		UserMedia:       userMedia,
-		DefaultResponse: "I've completed processing but have no response to give.",
+		SystemContext:   strings.TrimSpace(systemContext),
+		DefaultResponse: al.defaultResponse(),
+		NudgeOnEmpty:    true,
+		Unattended:      msg.SenderID == "cron" || routing.IsBackgroundSessionKey(msg.SessionKey),
 		EnableSummary:   true,
 		SendResponse:    false,
 	})
@@ -727,8 +869,12 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		originChatID = msg.ChatID
 	}
 
-	// Use the origin session for context
+	// Use the origin session for context: the chat's, or a sender's own
+	// session within it when the channel scopes group sessions by sender.
 	sessionKey := fmt.Sprintf("%s:%s", originChannel, originChatID)
+	if origin := strings.TrimSpace(msg.Metadata["origin_session_key"]); strings.HasPrefix(origin, sessionKey+":") {
+		sessionKey = origin
+	}
 
 	// Heartbeat-spawned subagents should report back to the heartbeat session
 	// only. They must not inject system messages into the user's active chat.
@@ -753,24 +899,38 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		return "", nil
 	}
 
-	// Subagent internal reports should not be forwarded to the end user.
-	// They can be stored as internal notes for later integration.
+	// Subagent reports follow agents.defaults.subagent_events: by default
+	// updates are stored as internal notes and final reports go through the
+	// agent, which decides what to tell the user.
 	if strings.HasPrefix(msg.SenderID, "subagent:") {
 		event := ""
 		if msg.Metadata != nil {
 			event = msg.Metadata["subagent_event"]
 		}
 
-		// Progress-like events are internal only: store and return no user response.
-		switch event {
-		case "progress", "note", "warning", "cancelled":
+		policy := al.subagentEventPolicy(event)
+		if policy == config.SubagentEventSuppress {
+			logger.InfoCF("agent", "Dropped subagent update (suppressed)",
+				map[string]interface{}{
+					"session_key": sessionKey,
+					"event":       event,
+					"sender_id":   msg.SenderID,
+					"trace_id":    traceID,
+				})
+			return "", nil
+		}
+		if policy == config.SubagentEventInternal || isSubagentUpdateEvent(event) {
 			internal := fmt.Sprintf("[Internal: %s] %s", msg.SenderID, msg.Content)
 			al.sessions.AddMessage(sessionKey, "assistant", internal)
 			_ = al.sessions.Save(al.sessions.GetOrCreate(sessionKey))
-			logger.InfoCF("agent", "Stored subagent update (internal)",
+			if policy == config.SubagentEventForward {
+				al.forwardSubagentUpdate(originChannel, originChatID, msg)
+			}
+			logger.InfoCF("agent", "Stored subagent update",
 				map[string]interface{}{
 					"session_key": sessionKey,
 					"event":       event,
+					"policy":      policy,
 					"sender_id":   msg.SenderID,
 					"trace_id":    traceID,
 				})
@@ -786,6 +946,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		TraceID:         traceID,
 		UserMessage:     fmt.Sprintf("[System: %s] %s", msg.SenderID, msg.Content),
 		DefaultResponse: "",
+		Unattended:      true,
 		EnableSummary:   false,
 		SendResponse:    false,
 	})
@@ -807,7 +968,19 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 	sessionKey := normalizeSessionKey(opts.SessionKey, opts.Channel, opts.ChatID)
 	runOpts := opts
 	runOpts.SessionKey = sessionKey
+	// Runs that bypass Run's scheduler (cron, heartbeat, system messages)
+	// still take their turn on the session's history.
+	if sessionKey != "" {
+		release, err := al.sessionRuns.acquire(ctx, sessionKey)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+	}
 	defer al.clearAgentProgressTracker(runOpts)
+	if al.sessions.GetExecDryRun(sessionKey) {
+		ctx = tools.WithExecDryRun(ctx)
+	}
 
 	// 1. Build messages
 	history := al.sessions.GetHistory(sessionKey)
@@ -822,6 +995,9 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 		runOpts.Channel,
 		runOpts.ChatID,
 	)
+	if runOpts.SystemContext != "" && len(messages) > 0 && messages[0].Role == "system" {
+		messages[0].Content += "\n\n## Additional Instructions\n\n" + runOpts.SystemContext
+	}
 	if strings.TrimSpace(timeContext) != "" {
 		messages = insertMessageBeforeLastUser(messages, providers.Message{Role: "user", Content: timeContext})
 	}
@@ -831,7 +1007,10 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 	_ = al.sessions.Save(al.sessions.GetOrCreate(sessionKey))
 
 	// 3. Run LLM iteration loop
-	finalContent, iteration, promptTokens, deliveredViaMessageTool, err := al.runLLMIteration(ctx, messages, runOpts)
+	status := newStatusNotifier(al.bus, runOpts.Channel, runOpts.ChatID, al.statusDelay, al.statusMessages)
+	status.start()
+	finalContent, iteration, usage, deliveredViaMessageTool, err := al.runLLMIteration(ctx, messages, runOpts)
+	status.stop()
 	if err != nil {
 		currentHistory := al.sessions.GetHistory(sessionKey)
 		if len(currentHistory) == historyLen+1 {
@@ -871,12 +1050,12 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 
 	// 6. Optional: summarization
 	if runOpts.EnableSummary {
-		al.maybeSummarize(sessionKey, promptTokens)
+		al.maybeSummarize(sessionKey, usage)
 	}
 
 	// 7. Log response
 	if finalContent != "" {
-		responsePreview := utils.Truncate(finalContent, 120)
+		responsePreview := utils.Truncate(finalContent, previewLimit(al.responsePreviewChars, defaultResponsePreviewChars))
 		logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
 			map[string]interface{}{
 				"session_key":  sessionKey,
@@ -1012,9 +1191,17 @@ func (al *AgentLoop) pruneTimeContextLocked(now time.Time) {
 	}
 }
 
+// turnUsage is what the LLM calls of one turn report about its context.
+type turnUsage struct {
+	promptTokens int    // largest prompt reported, 0 if unknown
+	model        string // model that served the latest call, "" if none did
+}
+
 type tokenUsageTrackingProvider struct {
 	inner           providers.LLMProvider
+	mu              sync.Mutex // best-of-N sampling calls Chat concurrently
 	maxPromptTokens int
+	servedModel     string
 }
 
 func (p *tokenUsageTrackingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
@@ -1022,16 +1209,32 @@ func (p *tokenUsageTrackingProvider) Chat(ctx context.Context, messages []provid
 	if err != nil {
 		return nil, err
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if resp != nil && resp.Usage != nil && resp.Usage.PromptTokens > p.maxPromptTokens {
 		p.maxPromptTokens = resp.Usage.PromptTokens
 	}
+	p.servedModel = model
+	if resp != nil && resp.Model != "" {
+		p.servedModel = resp.Model
+	}
 	return resp, nil
 }
 
+func (p *tokenUsageTrackingProvider) usage() turnUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return turnUsage{promptTokens: p.maxPromptTokens, model: p.servedModel}
+}
+
 func (p *tokenUsageTrackingProvider) GetDefaultModel() string {
 	return p.inner.GetDefaultModel()
 }
 
+func (p *tokenUsageTrackingProvider) Unwrap() providers.LLMProvider {
+	return p.inner
+}
+
 func deliveredMessageToolToTarget(channel, chatID string, toolCalls []providers.ToolCall, results []providers.Message) bool {
 	channel = strings.TrimSpace(channel)
 	chatID = strings.TrimSpace(chatID)
@@ -1059,21 +1262,48 @@ func deliveredMessageToolToTarget(channel, chatID string, toolCalls []providers.
 	return false
 }
 
+// bestOfNSelector returns the judge for best-of-N sampling, or nil (majority
+// vote) when no judge model is configured or it cannot be resolved.
+func (al *AgentLoop) bestOfNSelector(traceID string) llmloop.Selector {
+	if al.bestOfN < 2 || al.bestOfNJudgeModel == "" {
+		return nil
+	}
+	judge, err := al.providerForModel(al.bestOfNJudgeModel)
+	if err != nil {
+		logger.WarnCF("agent", "Best-of-N judge model unavailable, using majority vote",
+			map[string]interface{}{
+				"trace_id": traceID,
+				"model":    al.bestOfNJudgeModel,
+				"error":    err.Error(),
+			})
+		return nil
+	}
+	return llmloop.JudgeSelector(judge, al.bestOfNJudgeModel, al.llmTimeout)
+}
+
 // runLLMIteration executes the LLM call loop with tool handling.
 // Returns the final content, iteration count, and any error.
-func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.Message, opts processOptions) (string, int, int, bool, error) {
+func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.Message, opts processOptions) (string, int, turnUsage, bool, error) {
 	chatOptions := al.chatOptions.ToMap()
-	trackingProvider := &tokenUsageTrackingProvider{inner: al.provider}
+	model, provider := al.resolveSessionModel(opts.SessionKey, opts.TraceID)
+	trackingProvider := &tokenUsageTrackingProvider{inner: provider}
 	deliveredViaMessageTool := false
+	turnRetryBudget := providers.NewRetryBudget(al.turnMaxRetries, al.turnMaxRetryWait)
+	selectBest := al.bestOfNSelector(opts.TraceID)
 	runWithMessages := func(startMessages []providers.Message, maxIterations int) (llmloop.RunResult, error) {
 		return llmloop.Run(ctx, llmloop.RunOptions{
 			Provider:      trackingProvider,
-			Model:         al.model,
+			Model:         model,
 			MaxIterations: maxIterations,
 			LLMTimeout:    al.llmTimeout,
+			RetryBudget:   turnRetryBudget,
 			ChatOptions:   chatOptions,
 			MessageBudget: al.messageBudget,
 			Messages:      startMessages,
+			LoopThreshold: al.toolLoopThreshold,
+			BestOfN:       al.bestOfN,
+			SelectBest:    selectBest,
+			DeliveryTool:  "message",
 			BuildToolDefs: func(iteration int, _ []providers.Message) []providers.ToolDefinition {
 				return al.tools.GetProviderDefinitions()
 			},
@@ -1115,7 +1345,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 						map[string]interface{}{
 							"trace_id":          opts.TraceID,
 							"iteration":         iteration,
-							"model":             al.model,
+							"model":             model,
 							"messages_count":    len(currentMessages),
 							"tools_count":       len(toolDefs),
 							"max_tokens":        al.chatOptions.MaxTokens,
@@ -1135,10 +1365,16 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 						map[string]interface{}{
 							"trace_id":       opts.TraceID,
 							"iteration":      iteration,
-							"model":          al.model,
+							"model":          model,
 							"messages_count": len(currentMessages),
 							"tools_count":    len(toolDefs),
 						})
+					al.publishEvent(events.LLMCallStart, opts.SessionKey, opts.TraceID, map[string]interface{}{
+						"iteration":      iteration,
+						"model":          model,
+						"messages_count": len(currentMessages),
+						"tools_count":    len(toolDefs),
+					})
 				},
 				LLMCallFailed: func(iteration int, err error) {
 					logger.ErrorCF("agent", "LLM call failed",
@@ -1147,6 +1383,26 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 							"iteration": iteration,
 							"error":     err.Error(),
 						})
+					al.publishEvent(events.LLMCallEnd, opts.SessionKey, opts.TraceID, map[string]interface{}{
+						"iteration": iteration,
+						"model":     model,
+						"error":     err.Error(),
+					})
+				},
+				AfterLLMCall: func(iteration int, resp *providers.LLMResponse, duration time.Duration) {
+					data := map[string]interface{}{
+						"iteration":     iteration,
+						"model":         model,
+						"duration_ms":   duration.Milliseconds(),
+						"tool_calls":    len(resp.ToolCalls),
+						"finish_reason": resp.FinishReason,
+					}
+					if resp.Usage != nil {
+						data["prompt_tokens"] = resp.Usage.PromptTokens
+						data["completion_tokens"] = resp.Usage.CompletionTokens
+						data["total_tokens"] = resp.Usage.TotalTokens
+					}
+					al.publishEvent(events.LLMCallEnd, opts.SessionKey, opts.TraceID, data)
 				},
 				ToolCallsRequested: func(iteration int, toolCalls []providers.ToolCall) {
 					toolNames := make([]string, 0, len(toolCalls))
@@ -1173,10 +1429,39 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 					al.sessions.AddFullMessage(opts.SessionKey, msg)
 					_ = al.sessions.Save(al.sessions.GetOrCreate(opts.SessionKey))
 				},
+				InterimContent: func(iteration int, content string) {
+					logger.DebugCF("agent", "LLM returned interim text alongside tool calls",
+						map[string]interface{}{
+							"trace_id":      opts.TraceID,
+							"iteration":     iteration,
+							"content_chars": len(content),
+						})
+					al.maybeEchoInterimText(content, opts)
+				},
 				ToolResultMessage: func(_ int, msg providers.Message) {
 					al.sessions.AddFullMessage(opts.SessionKey, msg)
 					_ = al.sessions.Save(al.sessions.GetOrCreate(opts.SessionKey))
 				},
+				LoopDetected: func(iteration int, toolName string, count int, stopped bool) {
+					logger.WarnCF("agent", "Detected repeated tool call loop",
+						map[string]interface{}{
+							"trace_id":     opts.TraceID,
+							"iteration":    iteration,
+							"tool":         toolName,
+							"repeat_count": count,
+							"stopped":      stopped,
+						})
+				},
+				BestOfNSelected: func(iteration int, candidates int, chosen int) {
+					logger.InfoCF("agent", "Selected best-of-N final answer",
+						map[string]interface{}{
+							"trace_id":   opts.TraceID,
+							"iteration":  iteration,
+							"candidates": candidates,
+							"chosen":     chosen,
+							"judge":      al.bestOfNJudgeModel,
+						})
+				},
 			},
 		})
 	}
@@ -1219,28 +1504,57 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 		}
 	}
 	if err != nil {
-		return "", loopRes.Iterations, trackingProvider.maxPromptTokens, deliveredViaMessageTool, fmt.Errorf("LLM call failed: %w", err)
+		return "", loopRes.Iterations, trackingProvider.usage(), deliveredViaMessageTool, fmt.Errorf("LLM call failed: %w", err)
 	}
 
 	iteration := loopRes.Iterations
+
+	// Unattended runs have nobody to say "continue", so resume on their
+	// behalf a bounded number of times before asking for a summary. A run
+	// stopped for looping is not resumed; it would only loop again.
+	for resumes := 0; loopRes.Exhausted && !loopRes.LoopStopped && resumes < al.autoContinueLimit(opts); resumes++ {
+		logger.InfoCF("agent", "Tool iteration limit reached, auto-continuing",
+			map[string]interface{}{
+				"trace_id":    opts.TraceID,
+				"session_key": opts.SessionKey,
+				"resume":      resumes + 1,
+				"iterations":  iteration,
+			})
+		resume := providers.Message{Role: "user", Content: autoContinuePrompt}
+		al.sessions.AddFullMessage(opts.SessionKey, resume)
+		_ = al.sessions.Save(al.sessions.GetOrCreate(opts.SessionKey))
+
+		loopRes, err = runWithMessages(append(loopRes.Messages, resume), al.maxIterations)
+		iteration += loopRes.Iterations
+		if err != nil {
+			return "", iteration, trackingProvider.usage(), deliveredViaMessageTool, fmt.Errorf("LLM call failed: %w", err)
+		}
+	}
+
 	finalContent := loopRes.FinalContent
 	exhausted := loopRes.Exhausted
 	messages = loopRes.Messages
 
-	// If the loop exhausted all iterations without a direct answer,
-	// make one final LLM call with no tools to get a progress summary.
-	// The user can then say "continue" to resume.
+	// If the loop exhausted all iterations (or was stopped for repeating the
+	// same tool call) without a direct answer, make one final LLM call with
+	// no tools to get a progress summary. The user can then say "continue"
+	// to resume.
 	if exhausted {
-		logger.WarnCF("agent", "Tool iteration limit reached, requesting summary",
+		summaryPrompt := "You've reached your tool call iteration limit. Please summarize what you've accomplished so far and what still needs to be done. The user can tell you to continue."
+		if loopRes.LoopStopped {
+			summaryPrompt = "You kept repeating the same tool call without making progress, so tool use was stopped. Please summarize what you've accomplished so far, what went wrong, and what still needs to be done."
+		}
+		logger.WarnCF("agent", "Tool loop ended without an answer, requesting summary",
 			map[string]interface{}{
-				"trace_id":   opts.TraceID,
-				"iterations": iteration,
-				"max":        al.maxIterations,
+				"trace_id":     opts.TraceID,
+				"iterations":   iteration,
+				"max":          al.maxIterations,
+				"loop_stopped": loopRes.LoopStopped,
 			})
 
 		messages = append(messages, providers.Message{
 			Role:    "user",
-			Content: "You've reached your tool call iteration limit. Please summarize what you've accomplished so far and what still needs to be done. The user can tell you to continue.",
+			Content: summaryPrompt,
 		})
 
 		summaryMessages, summaryBudgetStats := providers.ApplyMessageBudget(messages, al.messageBudget)
@@ -1257,20 +1571,67 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 				})
 		}
 
-		response, err := providers.ChatWithTimeout(ctx, al.llmTimeout, al.provider, summaryMessages, nil, al.model, al.chatOptions.ToMap())
+		response, err := providers.ChatWithTimeout(ctx, al.llmTimeout, trackingProvider, summaryMessages, nil, model, al.chatOptions.ToMap())
 		if err != nil {
 			logger.ErrorCF("agent", "Summary call failed after iteration limit",
 				map[string]interface{}{"error": err.Error(), "trace_id": opts.TraceID})
 			finalContent = fmt.Sprintf("I reached my tool call limit (%d iterations) before finishing. Ask me to continue and I'll pick up where I left off.", al.maxIterations)
 		} else {
 			finalContent = response.Content
-			if response.Usage != nil && response.Usage.PromptTokens > trackingProvider.maxPromptTokens {
-				trackingProvider.maxPromptTokens = response.Usage.PromptTokens
-			}
 		}
 	}
 
-	return finalContent, iteration, trackingProvider.maxPromptTokens, deliveredViaMessageTool, nil
+	// An empty answer usually means the model stopped after its tool calls
+	// without writing a reply. Ask once more before the canned fallback.
+	if opts.NudgeOnEmpty && strings.TrimSpace(finalContent) == "" && !deliveredViaMessageTool {
+		logger.WarnCF("agent", "LLM returned an empty response, nudging for an answer",
+			map[string]interface{}{
+				"trace_id":   opts.TraceID,
+				"iterations": iteration,
+			})
+
+		nudgeMessages, _ := providers.ApplyMessageBudget(append(messages, providers.Message{
+			Role:    "user",
+			Content: emptyResponseNudge,
+		}), al.messageBudget)
+
+		response, err := providers.ChatWithTimeout(ctx, al.llmTimeout, trackingProvider, nudgeMessages, nil, model, al.chatOptions.ToMap())
+		if err != nil {
+			logger.WarnCF("agent", "Empty-response nudge failed",
+				map[string]interface{}{"error": err.Error(), "trace_id": opts.TraceID})
+		} else {
+			finalContent = response.Content
+		}
+	}
+
+	return finalContent, iteration, trackingProvider.usage(), deliveredViaMessageTool, nil
+}
+
+// autoContinuePrompt is fed back as the user turn when a run is resumed
+// automatically after hitting the iteration limit.
+const autoContinuePrompt = "continue"
+
+// autoContinueLimit returns how many times a run that hits the iteration
+// limit is resumed automatically: autoContinueMax (capped at
+// config.MaxAutoContinue) for unattended runs, or for every run with
+// auto_continue_interactive.
+func (al *AgentLoop) autoContinueLimit(opts processOptions) int {
+	if !opts.Unattended && !al.autoContinueAll {
+		return 0
+	}
+	if al.autoContinueMax > config.MaxAutoContinue {
+		return config.MaxAutoContinue
+	}
+	return al.autoContinueMax
+}
+
+// defaultResponse returns the configured reply for turns that end without
+// an answer.
+func (al *AgentLoop) defaultResponse() string {
+	if strings.TrimSpace(al.emptyResponse) == "" {
+		return defaultEmptyResponse
+	}
+	return al.emptyResponse
 }
 
 func isPromptTooLongError(err error) bool {
@@ -1331,19 +1692,36 @@ func messageBudgetFromDefaults(d config.AgentDefaults) providers.MessageBudget {
 	return budget
 }
 
+// contextWindowFor returns the context window used for budgeting model:
+// configured overrides, then the built-in table, then the configured default.
+// With no configured default the window stays unknown (0).
+func (al *AgentLoop) contextWindowFor(model string) int {
+	if al.contextWindow <= 0 {
+		return 0
+	}
+	return providers.ContextWindowFor(model, al.contextWindows, al.contextWindow)
+}
+
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 // When contextWindow is configured, compaction triggers at 75% token usage.
-// Otherwise, falls back to a message count heuristic.
-func (al *AgentLoop) maybeSummarize(sessionKey string, promptTokens int) {
+// Otherwise, falls back to a message count heuristic. The window is that of
+// the model that served the turn (a fallback model may have), else the
+// session's model.
+func (al *AgentLoop) maybeSummarize(sessionKey string, usage turnUsage) {
 	newHistory := al.sessions.GetHistory(sessionKey)
 
+	model := usage.model
+	if model == "" {
+		model = al.sessionModel(sessionKey)
+	}
+
 	var shouldSummarize bool
-	if al.contextWindow > 0 {
-		tokenEstimate := promptTokens
+	if contextWindow := al.contextWindowFor(model); contextWindow > 0 {
+		tokenEstimate := usage.promptTokens
 		if tokenEstimate <= 0 {
-			tokenEstimate = al.estimateTokens(newHistory)
+			tokenEstimate = al.countTokens(sessionKey, newHistory)
 		}
-		threshold := al.contextWindow * 75 / 100
+		threshold := contextWindow * 75 / 100
 		shouldSummarize = tokenEstimate > threshold
 	} else {
 		shouldSummarize = len(newHistory) > 20
@@ -1359,6 +1737,16 @@ func (al *AgentLoop) maybeSummarize(sessionKey string, promptTokens int) {
 	}
 }
 
+// SubagentManager returns the manager tracking spawned background tasks.
+func (al *AgentLoop) SubagentManager() *tools.SubagentManager {
+	return al.subagents
+}
+
+// MemoryStore returns the searchable memory DB, or nil when disabled.
+func (al *AgentLoop) MemoryStore() *memory.MemoryStore {
+	return al.memoryStore
+}
+
 // GetStartupInfo returns information about loaded tools and skills for logging.
 func (al *AgentLoop) GetStartupInfo() map[string]interface{} {
 	info := make(map[string]interface{})
@@ -1436,16 +1824,23 @@ func (al *AgentLoop) summarizeSession(sessionKey string) {
 	history := al.sessions.GetHistory(sessionKey)
 	summary := al.sessions.GetSummary(sessionKey)
 
-	// Keep last 4 messages for continuity
-	if len(history) <= 4 {
+	// Keep last 4 messages for continuity, widened so a tool-call/result
+	// group is never split across the summary boundary.
+	keep := session.SafeKeepCount(history, 4)
+	if len(history) <= keep {
 		return
 	}
 
-	toSummarize := history[:len(history)-4]
+	toSummarize := history[:len(history)-keep]
+
+	// A session with a /model override is compacted by that model.
+	model, provider := al.resolveSessionModel(sessionKey, "")
+	llm := summaryLLM{provider: provider, model: model, options: al.compactOptions.ToMap()}
 
 	// Oversized Message Guard
-	// Skip messages larger than 50% of context window to prevent summarizer overflow
-	maxMessageTokens := al.contextWindow / 2
+	// A message that could not fit in a single summarize prompt is condensed
+	// on its own first; it is only omitted if that fails.
+	budget := al.summarizeBudgetFor(model)
 	validMessages := make([]providers.Message, 0)
 	omitted := false
 
@@ -1453,11 +1848,20 @@ func (al *AgentLoop) summarizeSession(sessionKey string) {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		// Estimate tokens for this message
-		msgTokens := len(m.Content) / 4
-		if msgTokens > maxMessageTokens {
-			omitted = true
-			continue
+		if budget > 0 && summaryPromptTokens(m) > budget {
+			condensed, err := al.condenseOversizedMessage(ctx, llm, m, budget)
+			if err != nil {
+				logger.WarnCF("agent", "Failed to condense oversized message, omitting it from the summary",
+					map[string]interface{}{
+						"session_key": sessionKey,
+						"role":        m.Role,
+						"chars":       len(m.Content),
+						"error":       err.Error(),
+					})
+				omitted = true
+				continue
+			}
+			m = condensed
 		}
 		validMessages = append(validMessages, m)
 	}
@@ -1467,26 +1871,38 @@ func (al *AgentLoop) summarizeSession(sessionKey string) {
 	}
 
 	// Multi-Part Summarization
-	// Split into two parts if history is significant
+	// Split into as many parts as the token estimate requires, then merge
+	// the partial summaries hierarchically.
+	// A chunk that fails to summarize aborts compaction: truncating the
+	// history to a summary that lacks it would lose those messages.
 	var finalSummary string
-	if len(validMessages) > 10 {
-		mid := len(validMessages) / 2
-		part1 := validMessages[:mid]
-		part2 := validMessages[mid:]
-
-		s1, _ := al.summarizeBatch(ctx, part1, "")
-		s2, _ := al.summarizeBatch(ctx, part2, "")
-
-		// Merge them
-		mergePrompt := fmt.Sprintf("Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s", s1, s2)
-		resp, err := al.provider.Chat(ctx, []providers.Message{{Role: "user", Content: mergePrompt}}, nil, al.model, al.compactOptions.ToMap())
-		if err == nil {
-			finalSummary = resp.Content
-		} else {
-			finalSummary = s1 + " " + s2
+	chunks := splitSummaryChunks(validMessages, summary, budget)
+	if len(chunks) == 1 {
+		s, err := al.summarizeBatch(ctx, llm, validMessages, summary)
+		if err != nil {
+			al.logCompactionAborted(sessionKey, 1, len(chunks), err)
+			return
 		}
+		finalSummary = s
 	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, validMessages, summary)
+		// The existing summary is merged as the oldest part rather than fed
+		// into the first chunk, so it cannot push that chunk over budget.
+		partials := make([]string, 0, len(chunks)+1)
+		if summary != "" {
+			partials = append(partials, summary)
+		}
+		for i, chunk := range chunks {
+			s, err := al.summarizeBatch(ctx, llm, chunk, "")
+			if err == nil && strings.TrimSpace(s) == "" {
+				err = fmt.Errorf("empty summary")
+			}
+			if err != nil {
+				al.logCompactionAborted(sessionKey, i+1, len(chunks), err)
+				return
+			}
+			partials = append(partials, s)
+		}
+		finalSummary = al.mergeSummaries(ctx, llm, partials, budget)
 	}
 
 	if omitted && finalSummary != "" {
@@ -1494,17 +1910,49 @@ func (al *AgentLoop) summarizeSession(sessionKey string) {
 	}
 
 	if finalSummary != "" {
-		al.sessions.SetSummary(sessionKey, finalSummary)
-		al.sessions.TruncateHistory(sessionKey, 4)
-		al.sessions.Save(al.sessions.GetOrCreate(sessionKey))
+		al.publishEvent(events.SessionSummarized, sessionKey, "", map[string]interface{}{
+			"messages_summarized": len(toSummarize),
+			"messages_kept":       keep,
+			"summary_chars":       len(finalSummary),
+		})
+		if err := al.sessions.Compact(sessionKey, finalSummary, keep); err != nil {
+			logger.WarnCF("agent", "Failed to save compacted session", map[string]interface{}{
+				"session_key": sessionKey,
+				"error":       err.Error(),
+			})
+		}
 
 		// Extract and store notable memories from the compacted messages
-		al.extractAndStoreMemories(ctx, toSummarize)
+		al.extractAndStoreMemories(ctx, sessionKey, toSummarize)
 	}
 }
 
+// logCompactionAborted records that a session keeps its full history because
+// summarizing one of its chunks failed.
+func (al *AgentLoop) logCompactionAborted(sessionKey string, chunk, chunks int, err error) {
+	logger.WarnCF("agent", "Compaction aborted: summarizing the history failed",
+		map[string]interface{}{
+			"session_key": sessionKey,
+			"chunk":       chunk,
+			"chunks":      chunks,
+			"error":       err.Error(),
+		})
+}
+
+// summaryLLM is the model that summarizes a session, with the options of
+// compaction calls.
+type summaryLLM struct {
+	provider providers.LLMProvider
+	model    string
+	options  map[string]interface{}
+}
+
+func (s summaryLLM) chat(ctx context.Context, prompt string) (*providers.LLMResponse, error) {
+	return s.provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, s.model, s.options)
+}
+
 // summarizeBatch summarizes a batch of messages.
-func (al *AgentLoop) summarizeBatch(ctx context.Context, batch []providers.Message, existingSummary string) (string, error) {
+func (al *AgentLoop) summarizeBatch(ctx context.Context, llm summaryLLM, batch []providers.Message, existingSummary string) (string, error) {
 	prompt := "Provide a concise summary of this conversation segment, preserving core context and key points.\n"
 	if existingSummary != "" {
 		prompt += "Existing context: " + existingSummary + "\n"
@@ -1514,13 +1962,192 @@ func (al *AgentLoop) summarizeBatch(ctx context.Context, batch []providers.Messa
 		prompt += fmt.Sprintf("%s: %s\n", m.Role, m.Content)
 	}
 
-	response, err := al.provider.Chat(ctx, []providers.Message{{Role: "user", Content: prompt}}, nil, al.model, al.compactOptions.ToMap())
+	response, err := llm.chat(ctx, prompt)
 	if err != nil {
 		return "", err
 	}
 	return response.Content, nil
 }
 
+// condenseOversizedMessage summarizes a single message too large for a
+// summarize prompt, so its gist can be summarized with the rest of the
+// conversation. Content that does not fit one prompt either is summarized
+// in parts, which are then merged.
+func (al *AgentLoop) condenseOversizedMessage(ctx context.Context, llm summaryLLM, m providers.Message, budget int) (providers.Message, error) {
+	parts := splitTextByBytes(m.Content, budget*4)
+	partials := make([]string, 0, len(parts))
+	for i, part := range parts {
+		prompt := fmt.Sprintf("This is one %s message from a conversation, too long to summarize with the rest of it. "+
+			"Summarize it concisely, keeping facts, decisions, names, numbers and errors.\n", m.Role)
+		if len(parts) > 1 {
+			prompt = fmt.Sprintf("This is part %d of %d of one %s message from a conversation, too long to summarize with the rest of it. "+
+				"Summarize this part concisely, keeping facts, decisions, names, numbers and errors.\n", i+1, len(parts), m.Role)
+		}
+		prompt += "\nMESSAGE:\n" + part
+
+		response, err := llm.chat(ctx, prompt)
+		if err != nil {
+			return m, err
+		}
+		gist := strings.TrimSpace(response.Content)
+		if gist == "" {
+			return m, fmt.Errorf("empty summary")
+		}
+		partials = append(partials, gist)
+	}
+
+	gist := al.mergeSummaries(ctx, llm, partials, budget)
+	return providers.Message{
+		Role:    m.Role,
+		Content: fmt.Sprintf("[Condensed from a %d-character message] %s", len(m.Content), gist),
+	}, nil
+}
+
+// splitTextByBytes cuts s into consecutive parts of at most maxBytes bytes,
+// on rune boundaries and preferably after a newline.
+func splitTextByBytes(s string, maxBytes int) []string {
+	if maxBytes < utf8.UTFMax {
+		maxBytes = utf8.UTFMax
+	}
+	var parts []string
+	for len(s) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if nl := strings.LastIndexByte(s[:cut], '\n'); nl >= cut/2 {
+			cut = nl + 1
+		}
+		parts = append(parts, s[:cut])
+		s = s[cut:]
+	}
+	return append(parts, s)
+}
+
+// mergeSummaries combines partial summaries in order. Groups are sized to fit
+// the summarize budget, so very long histories merge over several rounds.
+func (al *AgentLoop) mergeSummaries(ctx context.Context, llm summaryLLM, summaries []string, budget int) string {
+	for len(summaries) > 1 {
+		next := make([]string, 0, len(summaries)/2+1)
+		for _, group := range groupSummaries(summaries, budget) {
+			if len(group) == 1 {
+				next = append(next, group[0])
+				continue
+			}
+			next = append(next, al.mergeSummaryGroup(ctx, llm, group))
+		}
+		summaries = next
+	}
+	if len(summaries) == 0 {
+		return ""
+	}
+	return summaries[0]
+}
+
+// mergeSummaryGroup asks the model to merge one group of summaries, falling
+// back to concatenation if the call fails.
+func (al *AgentLoop) mergeSummaryGroup(ctx context.Context, llm summaryLLM, group []string) string {
+	var sb strings.Builder
+	sb.WriteString("Merge these conversation summaries (oldest first) into one cohesive summary:")
+	for i, s := range group {
+		sb.WriteString(fmt.Sprintf("\n\n%d: %s", i+1, s))
+	}
+	resp, err := llm.chat(ctx, sb.String())
+	if err != nil || resp.Content == "" {
+		return strings.Join(group, " ")
+	}
+	return resp.Content
+}
+
+// summarizePromptOverheadTokens reserves room for the summarize/merge
+// instructions around the conversation text.
+const summarizePromptOverheadTokens = 64
+
+// summarizeBudget is the estimated token size one summarize or merge prompt
+// may use: half the context window minus the instruction overhead (never less
+// than a quarter of the window), leaving room for the reply. Zero means
+// the context window is unknown and prompts are not size-bounded.
+func (al *AgentLoop) summarizeBudget() int {
+	return al.summarizeBudgetFor(al.model)
+}
+
+// summarizeBudgetFor is summarizeBudget for the context window of model.
+func (al *AgentLoop) summarizeBudgetFor(model string) int {
+	contextWindow := al.contextWindowFor(model)
+	if contextWindow <= 0 {
+		return 0
+	}
+	budget := contextWindow/2 - summarizePromptOverheadTokens
+	if budget < contextWindow/4 {
+		budget = contextWindow / 4
+	}
+	if budget < 1 {
+		budget = 1
+	}
+	return budget
+}
+
+// summaryPromptTokens estimates the tokens a message adds to a summarize
+// prompt ("role: content\n").
+func summaryPromptTokens(m providers.Message) int {
+	return (len(m.Role) + len(m.Content) + 3) / 4
+}
+
+// splitSummaryChunks splits messages into consecutive chunks whose estimated
+// prompt size stays within budget. A history that fits in one prompt is still
+// split in two once it is long, matching the previous two-part behavior.
+func splitSummaryChunks(messages []providers.Message, existingSummary string, budget int) [][]providers.Message {
+	total := len(existingSummary) / 4
+	for _, m := range messages {
+		total += summaryPromptTokens(m)
+	}
+
+	if budget <= 0 || total <= budget {
+		if len(messages) > 10 {
+			mid := len(messages) / 2
+			return [][]providers.Message{messages[:mid], messages[mid:]}
+		}
+		return [][]providers.Message{messages}
+	}
+
+	// Aim for evenly sized parts rather than filling each to the limit, so
+	// the last chunk is not a tiny remainder.
+	parts := (total + budget - 1) / budget
+	target := (total + parts - 1) / parts
+
+	var chunks [][]providers.Message
+	start, used := 0, 0
+	for i, m := range messages {
+		tokens := summaryPromptTokens(m)
+		if i > start && used+tokens > target {
+			chunks = append(chunks, messages[start:i])
+			start, used = i, 0
+		}
+		used += tokens
+	}
+	return append(chunks, messages[start:])
+}
+
+// groupSummaries packs consecutive summaries into merge groups that fit the
+// budget. Every group except possibly the last holds at least two summaries
+// so each merge round makes progress.
+func groupSummaries(summaries []string, budget int) [][]string {
+	if budget <= 0 {
+		return [][]string{summaries}
+	}
+	var groups [][]string
+	start, used := 0, 0
+	for i, s := range summaries {
+		tokens := len(s)/4 + 1
+		if i-start >= 2 && used+tokens > budget {
+			groups = append(groups, summaries[start:i])
+			start, used = i, 0
+		}
+		used += tokens
+	}
+	return append(groups, summaries[start:])
+}
+
 // estimateTokens estimates the number of tokens in a message list.
 func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
 	total := 0
@@ -11,6 +11,7 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -31,6 +32,7 @@ type mockProvider struct {
 type mockProviderCall struct {
 	Messages []providers.Message
 	Tools    []providers.ToolDefinition
+	Model    string
 }
 
 type mockResponse struct {
@@ -103,13 +105,14 @@ func (t *waitTool) Execute(ctx context.Context, _ map[string]interface{}) (strin
 	return "", ctx.Err()
 }
 
-func (m *mockProvider) Chat(_ context.Context, messages []providers.Message, tdefs []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+func (m *mockProvider) Chat(_ context.Context, messages []providers.Message, tdefs []providers.ToolDefinition, model string, _ map[string]interface{}) (*providers.LLMResponse, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.calls = append(m.calls, mockProviderCall{
 		Messages: messages,
 		Tools:    tdefs,
+		Model:    model,
 	})
 
 	if len(m.responses) == 0 {
@@ -237,6 +240,141 @@ func TestRun_InterruptsActiveSessionOnNewUserMessage(t *testing.T) {
 	}
 }
 
+// gatedProvider blocks each Chat call until its message is released. Calls
+// are keyed by the "msg-<session><n>" tag in the last user message; it
+// records whether two calls for one session were ever in flight together.
+type gatedProvider struct {
+	mu       sync.Mutex
+	entered  chan string
+	gates    map[string]chan struct{}
+	inflight map[string]int
+	overlap  atomic.Bool
+}
+
+func newGatedProvider() *gatedProvider {
+	return &gatedProvider{
+		entered:  make(chan string, 10),
+		gates:    make(map[string]chan struct{}),
+		inflight: make(map[string]int),
+	}
+}
+
+func (p *gatedProvider) gate(tag string) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gates[tag] == nil {
+		p.gates[tag] = make(chan struct{})
+	}
+	return p.gates[tag]
+}
+
+func (p *gatedProvider) Chat(_ context.Context, messages []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	tag := ""
+	for i := len(messages) - 1; i >= 0 && tag == ""; i-- {
+		if messages[i].Role == "user" {
+			if idx := strings.Index(messages[i].Content, "msg-"); idx >= 0 {
+				tag = messages[i].Content[idx : idx+6]
+			}
+		}
+	}
+	session := tag[:5]
+
+	p.mu.Lock()
+	p.inflight[session]++
+	if p.inflight[session] > 1 {
+		p.overlap.Store(true)
+	}
+	p.mu.Unlock()
+
+	p.entered <- tag
+	<-p.gate(tag) // ignores cancellation so an interrupted run stays active until released
+
+	p.mu.Lock()
+	p.inflight[session]--
+	p.mu.Unlock()
+	return &providers.LLMResponse{Content: "done " + tag}, nil
+}
+
+func (p *gatedProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRun_ProcessesSessionsConcurrentlyButSerializesEachSession(t *testing.T) {
+	provider := newGatedProvider()
+	al := newTestAgentLoop(t, provider, 5, nil)
+	al.maxConcurrentMessages = 2
+	al.sessions = session.NewSessionManager("") // runs may still be saving when the test ends
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	runDone := make(chan error, 1)
+	go func() {
+		runDone <- al.Run(runCtx)
+	}()
+	defer func() {
+		al.Stop()
+		runCancel()
+		select {
+		case <-runDone:
+		case <-time.After(2 * time.Second):
+			t.Fatal("agent loop did not stop")
+		}
+		al.bus.Close()
+	}()
+
+	publish := func(chatID, content string) {
+		al.bus.PublishInbound(bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "user-" + chatID,
+			ChatID:   chatID,
+			Content:  content,
+		})
+	}
+	waitEntered := func(want string) {
+		t.Helper()
+		select {
+		case got := <-provider.entered:
+			if got != want {
+				t.Fatalf("entered %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s never reached the provider", want)
+		}
+	}
+
+	// Two sessions run at the same time: B starts while A is still blocked.
+	publish("A", "msg-A1")
+	waitEntered("msg-A1")
+	publish("B", "msg-B1")
+	waitEntered("msg-B1")
+
+	// A second message for A waits for A's active run to finish.
+	publish("A", "msg-A2")
+	select {
+	case got := <-provider.entered:
+		t.Fatalf("%s started while session A was still running", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(provider.gate("msg-A1"))
+	waitEntered("msg-A2")
+	close(provider.gate("msg-A2"))
+	close(provider.gate("msg-B1"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !historyEndsWith(al, "telegram:A", "done msg-A2") || !historyEndsWith(al, "telegram:B", "done msg-B1") {
+		if time.Now().After(deadline) {
+			t.Fatal("runs did not finish after release")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if provider.overlap.Load() {
+		t.Fatal("two runs of one session overlapped")
+	}
+}
+
+func historyEndsWith(al *AgentLoop, sessionKey, content string) bool {
+	history := al.sessions.GetHistory(sessionKey)
+	return len(history) > 0 && history[len(history)-1].Content == content
+}
+
 func TestRunLLMIteration_FinalSummaryOnMaxIterations(t *testing.T) {
 	// Provider always returns a tool call, except the very last call
 	// (which should be made with no tools) returns a summary.
@@ -520,6 +658,172 @@ func TestRunAgentLoop_SuppressesDefaultResponseAfterMessageTool(t *testing.T) {
 	}
 }
 
+func TestRunAgentLoop_NudgesOnceOnEmptyResponse(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{Content: ""},
+		{Content: "here is the answer"},
+	}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+	defer al.bus.Close()
+
+	got, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:      "telegram:chat1",
+		Channel:         "telegram",
+		ChatID:          "chat1",
+		UserMessage:     "question",
+		DefaultResponse: "default",
+		NudgeOnEmpty:    true,
+	})
+	if err != nil {
+		t.Fatalf("runAgentLoop() error: %v", err)
+	}
+	if got != "here is the answer" {
+		t.Fatalf("response = %q, want nudged answer", got)
+	}
+
+	calls := prov.getCalls()
+	if len(calls) != 2 {
+		t.Fatalf("provider calls = %d, want 2", len(calls))
+	}
+	nudge := calls[1].Messages[len(calls[1].Messages)-1]
+	if nudge.Role != "user" || nudge.Content != emptyResponseNudge {
+		t.Fatalf("last message of nudge call = %#v, want nudge prompt", nudge)
+	}
+	if len(calls[1].Tools) != 0 {
+		t.Fatalf("nudge call offered %d tools, want none", len(calls[1].Tools))
+	}
+}
+
+func TestRunAgentLoop_BestOfNMajorityVote(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{Content: "Lyon"},
+		{Content: "Paris"},
+		{Content: "paris"},
+	}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+	al.bestOfN = 3
+	defer al.bus.Close()
+
+	got, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:  "cli:chat1",
+		Channel:     "cli",
+		ChatID:      "chat1",
+		UserMessage: "capital of France?",
+	})
+	if err != nil {
+		t.Fatalf("runAgentLoop() error: %v", err)
+	}
+	if !strings.EqualFold(got, "paris") {
+		t.Fatalf("response = %q, want the majority answer", got)
+	}
+	if calls := prov.getCalls(); len(calls) != 3 {
+		t.Fatalf("provider calls = %d, want 3", len(calls))
+	}
+}
+
+func TestRunAgentLoop_BestOfNUsesJudgeModel(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{Content: "Lyon"},
+		{Content: "Paris"},
+		{Content: "Paris"},
+	}}
+	judge := &mockProvider{responses: []mockResponse{{Content: "1"}}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+	al.bestOfN = 3
+	al.bestOfNJudgeModel = "judge-model"
+	al.modelProvider = func(model string) (providers.LLMProvider, error) {
+		if model != "judge-model" {
+			return nil, fmt.Errorf("unexpected model %q", model)
+		}
+		return judge, nil
+	}
+	defer al.bus.Close()
+
+	got, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:  "cli:chat1",
+		Channel:     "cli",
+		ChatID:      "chat1",
+		UserMessage: "capital of France?",
+	})
+	if err != nil {
+		t.Fatalf("runAgentLoop() error: %v", err)
+	}
+	if got != "Lyon" {
+		t.Fatalf("response = %q, want the judge's pick", got)
+	}
+	calls := judge.getCalls()
+	if len(calls) != 1 || calls[0].Model != "judge-model" {
+		t.Fatalf("judge calls = %#v, want one call to judge-model", calls)
+	}
+}
+
+func TestRunAgentLoop_FallsBackToDefaultWhenNudgeIsEmpty(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: ""}, {Content: "  "}}}
+	al := newTestAgentLoop(t, prov, 5, nil)
+	al.emptyResponse = "Nothing to say."
+	defer al.bus.Close()
+
+	got, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:      "telegram:chat1",
+		Channel:         "telegram",
+		ChatID:          "chat1",
+		UserMessage:     "question",
+		DefaultResponse: al.defaultResponse(),
+		NudgeOnEmpty:    true,
+	})
+	if err != nil {
+		t.Fatalf("runAgentLoop() error: %v", err)
+	}
+	if got != "Nothing to say." {
+		t.Fatalf("response = %q, want configured empty response", got)
+	}
+	if calls := len(prov.getCalls()); calls != 2 {
+		t.Fatalf("provider calls = %d, want 2 (one nudge only)", calls)
+	}
+}
+
+func TestRunAgentLoop_BreaksRepeatedToolCallLoop(t *testing.T) {
+	call := func() mockResponse {
+		return mockResponse{ToolCalls: []providers.ToolCall{{ID: "tc", Name: "status", Arguments: map[string]interface{}{"job": "42"}}}}
+	}
+	prov := &mockProvider{responses: []mockResponse{call(), call(), call(), call(), {Content: "The job never finished; I stopped polling."}}}
+	al := newTestAgentLoop(t, prov, 20, []tools.Tool{&noopTool{name: "status", result: "pending"}})
+	al.toolLoopThreshold = 3
+	defer al.bus.Close()
+
+	got, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:      "telegram:chat1",
+		Channel:         "telegram",
+		ChatID:          "chat1",
+		UserMessage:     "wait for job 42",
+		DefaultResponse: "default",
+	})
+	if err != nil {
+		t.Fatalf("runAgentLoop() error: %v", err)
+	}
+	if got != "The job never finished; I stopped polling." {
+		t.Fatalf("response = %q", got)
+	}
+
+	calls := prov.getCalls()
+	if len(calls) != 5 {
+		t.Fatalf("provider calls = %d, want 4 looping calls plus a summary call", len(calls))
+	}
+	sawWarning := false
+	for _, msg := range calls[3].Messages {
+		if msg.Role == "system" && strings.Contains(msg.Content, "same arguments 3 times") {
+			sawWarning = true
+		}
+	}
+	if !sawWarning {
+		t.Fatal("expected loop warning in the request after the third identical call")
+	}
+	summary := calls[4]
+	if len(summary.Tools) != 0 || !strings.Contains(summary.Messages[len(summary.Messages)-1].Content, "kept repeating the same tool call") {
+		t.Fatalf("expected a tool-less summary request about the loop, got %#v", summary.Messages[len(summary.Messages)-1])
+	}
+}
+
 func TestRunAgentLoop_DoesNotPersistUserMessageWhenInitialLLMCallFails(t *testing.T) {
 	prov := &mockProvider{responses: []mockResponse{{Err: fmt.Errorf("claude API call: POST \"https://api.anthropic.com/v1/messages\": 400 Bad Request")}}}
 	al := newTestAgentLoop(t, prov, 5, nil)
@@ -1009,7 +1313,7 @@ func TestExtractAndStoreMemories_StoresExtractedMemories(t *testing.T) {
 		{Role: "assistant", Content: "Noted! You like cats and live in Tokyo."},
 	}
 
-	al.extractAndStoreMemories(context.Background(), messages)
+	al.extractAndStoreMemories(context.Background(), "telegram:chat1", messages)
 
 	// Verify memories were stored
 	results, err := memDB.Search("cats", 5, "")
@@ -1029,12 +1333,48 @@ func TestExtractAndStoreMemories_StoresExtractedMemories(t *testing.T) {
 	}
 }
 
+func TestExtractAndStoreMemories_RecordsProvenance(t *testing.T) {
+	prov := &mockProvider{
+		responses: []mockResponse{{Content: "MEMORY(fact): User lives in Tokyo"}},
+	}
+	al := newTestAgentLoop(t, prov, 5, nil)
+	defer al.bus.Close()
+	al.timeNow = func() time.Time { return time.Date(2026, time.February, 20, 9, 0, 0, 0, time.UTC) }
+
+	memDB, err := newTestMemoryStore(t)
+	if err != nil {
+		t.Fatalf("failed to create test memory store: %v", err)
+	}
+	al.memoryStore = memDB
+
+	al.extractAndStoreMemories(context.Background(), "telegram:chat1", []providers.Message{
+		{Role: "user", Content: "I live in Tokyo."},
+	})
+
+	results, err := memDB.Search("Tokyo", 5, "")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("search = %v, %v; want one memory", results, err)
+	}
+	md := results[0].Metadata
+	if md["session_key"] != "telegram:chat1" || md["channel"] != "telegram" || md["chat_id"] != "chat1" || md["date"] != "2026-02-20" {
+		t.Fatalf("metadata = %v, want session provenance", md)
+	}
+
+	out, err := tools.NewMemorySearchTool(memDB).Execute(context.Background(), map[string]interface{}{"query": "Tokyo"})
+	if err != nil {
+		t.Fatalf("memory_search failed: %v", err)
+	}
+	if !strings.Contains(out, "from telegram chat on 2026-02-20") {
+		t.Fatalf("memory_search output lacks provenance:\n%s", out)
+	}
+}
+
 func TestExtractAndStoreMemories_NilMemoryStoreIsNoop(t *testing.T) {
 	prov := &mockProvider{}
 	al := newTestAgentLoop(t, prov, 5, nil)
 	defer al.bus.Close()
 	// al.memoryStore is nil — should not panic or call the provider
-	al.extractAndStoreMemories(context.Background(), []providers.Message{
+	al.extractAndStoreMemories(context.Background(), "telegram:chat1", []providers.Message{
 		{Role: "user", Content: "hello"},
 	})
 
@@ -1065,7 +1405,7 @@ func TestExtractAndStoreMemories_NoneResponse(t *testing.T) {
 		{Role: "assistant", Content: "It's 3pm."},
 	}
 
-	al.extractAndStoreMemories(context.Background(), messages)
+	al.extractAndStoreMemories(context.Background(), "telegram:chat1", messages)
 
 	// Should not store anything
 	results, err := memDB.Search("time", 5, "")
@@ -1655,13 +1995,26 @@ func TestMessageBudgetFromDefaults_DefaultsDisabled(t *testing.T) {
 	}
 }
 
+func TestNewAgentLoop_InvalidExecPatternFails(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Tools.Exec.DenyPatterns = []string{"rm (-rf"}
+
+	if _, err := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{}); err == nil || !strings.Contains(err.Error(), "invalid deny pattern") {
+		t.Fatalf("NewAgentLoop error = %v, want the invalid deny pattern", err)
+	}
+}
+
 func TestNewAgentLoop_PropagatesAnthropicCacheDefaults(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Agents.Defaults.Workspace = t.TempDir()
 	cfg.Agents.Defaults.AnthropicCache = true
 	cfg.Agents.Defaults.AnthropicCacheTTL = "1h"
 
-	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	al, err := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	if err != nil {
+		t.Fatalf("NewAgentLoop: %v", err)
+	}
 	defer al.bus.Close()
 
 	if !al.chatOptions.AnthropicCache {
@@ -1686,7 +2039,10 @@ func TestNewAgentLoop_ToolSafeguardsDisabled_DisablesPolicyAndGuards(t *testing.
 	cfg.Tools.Policy.Deny = []string{"exec", "read_file"}
 	cfg.Tools.Safeguards.Disabled = true
 
-	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	al, err := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	if err != nil {
+		t.Fatalf("NewAgentLoop: %v", err)
+	}
 	defer al.bus.Close()
 
 	if al.unsafeGate != nil {
@@ -1735,3 +2091,375 @@ func TestNewAgentLoop_ToolSafeguardsDisabled_DisablesPolicyAndGuards(t *testing.
 		t.Fatalf("expected safeguards_disabled=true in startup info")
 	}
 }
+
+func TestSummarizeSession_KeepsToolCallWithResults(t *testing.T) {
+	provider := &mockProvider{responses: []mockResponse{{Content: "earlier summary"}}}
+	al := newTestAgentLoop(t, provider, 5, nil)
+	al.contextWindow = 100000
+
+	key := "test:summarize"
+	al.sessions.AddMessage(key, "user", "u1")
+	al.sessions.AddMessage(key, "assistant", "a1")
+	al.sessions.AddMessage(key, "user", "u2")
+	al.sessions.AddFullMessage(key, providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{
+			{ID: "c1", Name: "exec", Arguments: map[string]interface{}{"command": "ls"}},
+			{ID: "c2", Name: "exec", Arguments: map[string]interface{}{"command": "pwd"}},
+		},
+	})
+	al.sessions.AddFullMessage(key, providers.Message{Role: "tool", Content: "out1", ToolCallID: "c1"})
+	al.sessions.AddFullMessage(key, providers.Message{Role: "tool", Content: "out2", ToolCallID: "c2"})
+	al.sessions.AddMessage(key, "assistant", "done")
+	al.sessions.AddMessage(key, "user", "next")
+
+	al.summarizeSession(key)
+
+	history := al.sessions.GetHistory(key)
+	if len(history) != 5 {
+		t.Fatalf("len(history) = %d, want 5: %+v", len(history), history)
+	}
+	if history[0].Role != "assistant" || len(history[0].ToolCalls) != 2 {
+		t.Fatalf("history[0] = %+v, want assistant with tool calls", history[0])
+	}
+	if history[1].ToolCallID != "c1" || history[2].ToolCallID != "c2" {
+		t.Fatalf("tool results not kept with their call: %+v", history[1:3])
+	}
+	if got := al.sessions.GetSummary(key); got != "earlier summary" {
+		t.Fatalf("summary = %q", got)
+	}
+}
+
+func TestRunLLMIteration_PreservesInterimContentWithToolCalls(t *testing.T) {
+	prov := &mockProvider{
+		responses: []mockResponse{
+			{Content: "Let me check...", ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "noop", Arguments: map[string]interface{}{}}}},
+			{Content: "All good."},
+		},
+	}
+
+	al := newTestAgentLoop(t, prov, 3, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	defer al.bus.Close()
+
+	opts := processOptions{SessionKey: "telegram:chat1", Channel: "telegram", ChatID: "chat1"}
+	content, _, _, _, err := al.runLLMIteration(context.Background(), []providers.Message{
+		{Role: "system", Content: "You are a test bot."},
+		{Role: "user", Content: "check things"},
+	}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "All good." {
+		t.Fatalf("content = %q, want final answer", content)
+	}
+
+	history := al.sessions.GetHistory(opts.SessionKey)
+	if len(history) < 1 || history[0].Role != "assistant" {
+		t.Fatalf("expected saved assistant message, got %+v", history)
+	}
+	if history[0].Content != "Let me check..." || len(history[0].ToolCalls) != 1 {
+		t.Fatalf("saved assistant message = %+v, want interim content with tool call", history[0])
+	}
+
+	// Echo is off by default, so nothing is sent to the chat.
+	outCtx, outCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer outCancel()
+	if out, ok := al.bus.SubscribeOutbound(outCtx); ok {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+}
+
+func TestRunLLMIteration_EchoesInterimContentWhenEnabled(t *testing.T) {
+	prov := &mockProvider{
+		responses: []mockResponse{
+			{Content: "Let me check...", ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "noop", Arguments: map[string]interface{}{}}}},
+			{Content: "All good."},
+		},
+	}
+
+	al := newTestAgentLoop(t, prov, 3, []tools.Tool{&noopTool{name: "noop", result: "ok"}})
+	al.echoInterimText = true
+	defer al.bus.Close()
+
+	opts := processOptions{SessionKey: "telegram:chat1", Channel: "telegram", ChatID: "chat1"}
+	if _, _, _, _, err := al.runLLMIteration(context.Background(), []providers.Message{
+		{Role: "user", Content: "check things"},
+	}, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	out, ok := al.bus.SubscribeOutbound(outCtx)
+	if !ok {
+		t.Fatal("expected interim narration to be echoed")
+	}
+	if out.Channel != "telegram" || out.ChatID != "chat1" || out.Content != "Let me check..." {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+}
+
+// promptRecordingProvider records every prompt and answers with a short summary.
+type promptRecordingProvider struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *promptRecordingProvider) Chat(_ context.Context, messages []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, messages[len(messages)-1].Content)
+	return &providers.LLMResponse{Content: fmt.Sprintf("summary %d", len(p.prompts))}, nil
+}
+
+func (p *promptRecordingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestSummarizeSession_ChunksLongHistoryWithinContextWindow(t *testing.T) {
+	provider := &promptRecordingProvider{}
+	al := newTestAgentLoop(t, provider, 5, nil)
+	al.contextWindow = 2000
+
+	key := "test:summarize-long"
+	for i := 0; i < 300; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		al.sessions.AddMessage(key, role, fmt.Sprintf("message %03d %s", i, strings.Repeat("x", 400)))
+	}
+	al.sessions.SetSummary(key, "older summary")
+
+	al.summarizeSession(key)
+
+	limit := al.contextWindow / 2
+	summarizeCalls := 0
+	for i, prompt := range provider.prompts {
+		if tokens := len(prompt) / 4; tokens > limit {
+			t.Fatalf("prompt %d is ~%d tokens, exceeds bound %d", i, tokens, limit)
+		}
+		if strings.Contains(prompt, "CONVERSATION:") {
+			summarizeCalls++
+		}
+	}
+	if summarizeCalls <= 2 {
+		t.Fatalf("expected more than two summarize chunks, got %d", summarizeCalls)
+	}
+
+	// Every summarized message must appear in exactly one chunk.
+	joined := strings.Join(provider.prompts, "\n")
+	for _, i := range []int{0, 150, 295} {
+		if n := strings.Count(joined, fmt.Sprintf("message %03d ", i)); n != 1 {
+			t.Fatalf("message %03d summarized %d times", i, n)
+		}
+	}
+
+	final := provider.prompts[len(provider.prompts)-1]
+	if !strings.HasPrefix(final, "Merge these conversation summaries") {
+		t.Fatalf("expected final call to merge summaries, got %.80q", final)
+	}
+	if !strings.Contains(strings.Join(provider.prompts, ""), "older summary") {
+		t.Fatal("existing summary was not merged")
+	}
+	if got := al.sessions.GetSummary(key); got != fmt.Sprintf("summary %d", len(provider.prompts)) {
+		t.Fatalf("summary = %q", got)
+	}
+	if got := len(al.sessions.GetHistory(key)); got != 4 {
+		t.Fatalf("history len = %d, want 4", got)
+	}
+}
+
+// failingChunkProvider fails the summarize prompt of one chunk and answers
+// every other prompt.
+type failingChunkProvider struct {
+	mu      sync.Mutex
+	failOn  string
+	prompts int
+}
+
+func (p *failingChunkProvider) Chat(_ context.Context, messages []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts++
+	if strings.Contains(messages[len(messages)-1].Content, p.failOn) {
+		return nil, fmt.Errorf("HTTP 503 service unavailable")
+	}
+	return &providers.LLMResponse{Content: "partial summary"}, nil
+}
+
+func (p *failingChunkProvider) GetDefaultModel() string { return "test-model" }
+
+func TestSummarizeSession_AbortsWhenAChunkFails(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		contextWindow int
+		messages      int
+	}{
+		{"single batch", 100000, 10},
+		{"one of many chunks", 2000, 300},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &failingChunkProvider{failOn: "message 002 "}
+			al := newTestAgentLoop(t, provider, 5, nil)
+			al.contextWindow = tc.contextWindow
+
+			key := "test:summarize-fail"
+			for i := 0; i < tc.messages; i++ {
+				role := "user"
+				if i%2 == 1 {
+					role = "assistant"
+				}
+				al.sessions.AddMessage(key, role, fmt.Sprintf("message %03d %s", i, strings.Repeat("x", 400)))
+			}
+			al.sessions.SetSummary(key, "older summary")
+
+			al.summarizeSession(key)
+
+			if provider.prompts == 0 {
+				t.Fatal("expected summarize calls")
+			}
+			if got := len(al.sessions.GetHistory(key)); got != tc.messages {
+				t.Fatalf("history len = %d, want all %d messages kept", got, tc.messages)
+			}
+			if got := al.sessions.GetSummary(key); got != "older summary" {
+				t.Fatalf("summary = %q, want the old summary untouched", got)
+			}
+		})
+	}
+}
+
+func TestSplitSummaryChunks(t *testing.T) {
+	short := []providers.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	if chunks := splitSummaryChunks(short, "", 100); len(chunks) != 1 {
+		t.Fatalf("short history split into %d chunks", len(chunks))
+	}
+
+	var long []providers.Message
+	for i := 0; i < 12; i++ {
+		long = append(long, providers.Message{Role: "user", Content: "x"})
+	}
+	if chunks := splitSummaryChunks(long, "", 100); len(chunks) != 2 {
+		t.Fatalf("long history within budget should split in two, got %d", len(chunks))
+	}
+
+	var big []providers.Message
+	for i := 0; i < 20; i++ {
+		big = append(big, providers.Message{Role: "user", Content: strings.Repeat("y", 197)}) // 50 tokens each
+	}
+	chunks := splitSummaryChunks(big, "", 120)
+	if len(chunks) < 9 {
+		t.Fatalf("expected at least 9 chunks, got %d", len(chunks))
+	}
+	count := 0
+	for _, chunk := range chunks {
+		size := 0
+		for _, m := range chunk {
+			size += summaryPromptTokens(m)
+		}
+		if size > 120 {
+			t.Fatalf("chunk of %d tokens exceeds budget", size)
+		}
+		count += len(chunk)
+	}
+	if count != len(big) {
+		t.Fatalf("chunks cover %d messages, want %d", count, len(big))
+	}
+}
+
+// condensingProvider answers single-message condense prompts with a fixed
+// gist, merges them keeping that fact, and echoes conversation prompts so
+// the final summary shows what was summarized.
+type condensingProvider struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *condensingProvider) Chat(_ context.Context, messages []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prompt := messages[len(messages)-1].Content
+	p.prompts = append(p.prompts, prompt)
+	switch {
+	case strings.Contains(prompt, "MESSAGE:"):
+		return &providers.LLMResponse{Content: fmt.Sprintf("gist %d: build failed on missing libfoo", len(p.prompts))}, nil
+	case strings.Contains(prompt, "CONVERSATION:"):
+		_, conversation, _ := strings.Cut(prompt, "CONVERSATION:")
+		return &providers.LLMResponse{Content: "summary of:" + conversation}, nil
+	}
+	return &providers.LLMResponse{Content: "merged: build failed on missing libfoo"}, nil
+}
+
+func (p *condensingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestSummarizeSession_CondensesOversizedMessage(t *testing.T) {
+	provider := &condensingProvider{}
+	al := newTestAgentLoop(t, provider, 5, nil)
+	al.contextWindow = 2000
+
+	key := "test:summarize-oversized"
+	al.sessions.AddMessage(key, "user", "why does the build fail?")
+	al.sessions.AddMessage(key, "assistant", strings.Repeat("build log line\n", 700))
+	al.sessions.AddMessage(key, "user", "thanks")
+	for i := 0; i < 4; i++ {
+		al.sessions.AddMessage(key, "assistant", fmt.Sprintf("kept %d", i))
+	}
+
+	al.summarizeSession(key)
+
+	summary := al.sessions.GetSummary(key)
+	if strings.Contains(summary, "omitted") {
+		t.Fatalf("expected no omission note, got %q", summary)
+	}
+	if !strings.Contains(summary, "build failed on missing libfoo") || !strings.Contains(summary, "[Condensed from a 10500-character message]") {
+		t.Fatalf("expected the oversized message's gist in the summary, got %q", summary)
+	}
+
+	// The message was condensed in parts that each fit one prompt.
+	limit := al.contextWindow / 2
+	condenseCalls := 0
+	for i, prompt := range provider.prompts {
+		if tokens := len(prompt) / 4; tokens > limit {
+			t.Fatalf("prompt %d is ~%d tokens, exceeds bound %d", i, tokens, limit)
+		}
+		if strings.Contains(prompt, "MESSAGE:") {
+			condenseCalls++
+		}
+	}
+	if condenseCalls < 2 {
+		t.Fatalf("expected the message to be condensed in several parts, got %d calls", condenseCalls)
+	}
+}
+
+func TestSummarizeSession_OmitsOversizedMessageWhenCondenseFails(t *testing.T) {
+	provider := &mockProvider{responses: []mockResponse{
+		{Err: fmt.Errorf("provider down")},
+		{Content: "short summary"},
+	}}
+	al := newTestAgentLoop(t, provider, 5, nil)
+	al.contextWindow = 100000
+
+	key := "test:summarize-omit"
+	al.sessions.AddMessage(key, "user", "hello")
+	al.sessions.AddMessage(key, "assistant", strings.Repeat("x", 250000))
+	for i := 0; i < 4; i++ {
+		al.sessions.AddMessage(key, "user", fmt.Sprintf("kept %d", i))
+	}
+
+	al.summarizeSession(key)
+
+	if got := al.sessions.GetSummary(key); !strings.HasPrefix(got, "short summary") || !strings.Contains(got, "omitted") {
+		t.Fatalf("summary = %q, want the omission note after a failed condense", got)
+	}
+}
+
+func TestSplitTextByBytes(t *testing.T) {
+	text := strings.Repeat("é", 10) + "\n" + strings.Repeat("ab", 10)
+	parts := splitTextByBytes(text, 16)
+	if strings.Join(parts, "") != text {
+		t.Fatalf("parts do not rejoin to the input: %q", parts)
+	}
+	for _, p := range parts {
+		if len(p) > 16 || !utf8.ValidString(p) {
+			t.Fatalf("bad part %q", p)
+		}
+	}
+}
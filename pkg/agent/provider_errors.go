@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// userFacingError turns a failed turn into a short, actionable chat reply.
+// Permanent failures point at configuration; transient ones suggest retrying.
+func userFacingError(err error) string {
+	switch providers.ProviderErrorKindOf(err) {
+	case providers.ProviderErrorAuth:
+		return "I can't reach the AI provider: my API key seems invalid or lacks access. Please check the provider credentials in the config."
+	case providers.ProviderErrorBadRequest:
+		return "The AI provider rejected my request (bad request). This is usually a model or configuration problem; please check the logs."
+	case providers.ProviderErrorRateLimited:
+		return "The AI provider is rate limiting me right now. Please try again in a minute."
+	case providers.ProviderErrorRefused:
+		return "That request was refused by the model provider's content policy, so I can't answer it. Rephrasing it may help."
+	case providers.ProviderErrorTransient:
+		return "The AI provider is busy or unreachable right now. Please try again shortly."
+	default:
+		return "Sorry, something went wrong while processing your message. Please try again."
+	}
+}
+
+// reportProcessingError tells the user a turn failed. Internal system
+// messages (subagent reports, etc.) have no waiting user and are only logged.
+func (al *AgentLoop) reportProcessingError(msg bus.InboundMessage, err error) {
+	if msg.Channel == "system" || msg.Channel == "cli" || msg.ChatID == "" {
+		return
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: msg.Channel,
+		ChatID:  msg.ChatID,
+		Content: userFacingError(err),
+		ReplyTo: msg.Metadata["message_id"],
+	})
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestUserFacingError_ByKind(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&providers.ProviderError{Kind: providers.ProviderErrorAuth, Err: errors.New("401")}, "API key"},
+		{&providers.ProviderError{Kind: providers.ProviderErrorBadRequest, Err: errors.New("400")}, "rejected"},
+		{&providers.ProviderError{Kind: providers.ProviderErrorRateLimited, Err: errors.New("429")}, "rate limiting"},
+		{&providers.ProviderError{Kind: providers.ProviderErrorRefused, Err: errors.New("content_filter")}, "refused by the model provider"},
+		{fmt.Errorf("LLM call failed: %w", &providers.ProviderError{Kind: providers.ProviderErrorTransient, Err: errors.New("503")}), "busy"},
+		{errors.New("boom"), "something went wrong"},
+	}
+	for _, tc := range cases {
+		if got := userFacingError(tc.err); !strings.Contains(got, tc.want) {
+			t.Fatalf("userFacingError(%v) = %q, want it to mention %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRun_ReportsProviderAuthFailureToUser(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{
+		Err: &providers.ProviderError{Kind: providers.ProviderErrorAuth, StatusCode: 401, Err: errors.New("API error (HTTP 401): invalid key")},
+	}}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	runDone := make(chan error, 1)
+	go func() { runDone <- al.Run(runCtx) }()
+	defer func() {
+		al.Stop()
+		runCancel()
+		<-runDone
+		al.bus.Close()
+	}()
+
+	al.bus.PublishInbound(bus.InboundMessage{
+		Channel:    "telegram",
+		SenderID:   "user-1",
+		ChatID:     "chat-1",
+		Content:    "hello",
+		SessionKey: "telegram:chat-1",
+	})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer outCancel()
+	out, ok := al.bus.SubscribeOutbound(outCtx)
+	if !ok {
+		t.Fatal("expected an error reply to the user")
+	}
+	if out.Channel != "telegram" || out.ChatID != "chat-1" || !strings.Contains(out.Content, "API key") {
+		t.Fatalf("unexpected error reply: %+v", out)
+	}
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"sync"
+)
+
+// sessionLocks serializes runs per session key. Run never starts two runs of
+// one session, but cron jobs, heartbeats and system messages reach
+// runAgentLoop without going through Run's scheduler. The zero value is ready
+// to use.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	held chan struct{}
+	refs int
+}
+
+// acquire waits until no other run holds sessionKey, or ctx is done.
+func (s *sessionLocks) acquire(ctx context.Context, sessionKey string) (func(), error) {
+	s.mu.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]*sessionLock)
+	}
+	l, ok := s.locks[sessionKey]
+	if !ok {
+		l = &sessionLock{held: make(chan struct{}, 1)}
+		s.locks[sessionKey] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	select {
+	case l.held <- struct{}{}:
+		return func() {
+			<-l.held
+			s.put(sessionKey, l)
+		}, nil
+	case <-ctx.Done():
+		s.put(sessionKey, l)
+		return nil, ctx.Err()
+	}
+}
+
+// put drops a reference and forgets the lock once nobody uses it.
+func (s *sessionLocks) put(sessionKey string, l *sessionLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(s.locks, sessionKey)
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionLocks_SerializeRunsPerSession(t *testing.T) {
+	var locks sessionLocks
+	release, err := locks.acquire(context.Background(), "telegram:42")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// Another session is not held up.
+	other, err := locks.acquire(context.Background(), "telegram:7")
+	if err != nil {
+		t.Fatalf("acquire other session: %v", err)
+	}
+	other()
+
+	acquired := make(chan func())
+	go func() {
+		next, _ := locks.acquire(context.Background(), "telegram:42")
+		acquired <- next
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second run of the session started while the first was active")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case next := <-acquired:
+		next()
+	case <-time.After(time.Second):
+		t.Fatal("second run did not start after the first finished")
+	}
+	if len(locks.locks) != 0 {
+		t.Fatalf("expected unused locks to be dropped, have %d", len(locks.locks))
+	}
+}
+
+func TestSessionLocks_WaitEndsWithContext(t *testing.T) {
+	var locks sessionLocks
+	release, _ := locks.acquire(context.Background(), "telegram:42")
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := locks.acquire(ctx, "telegram:42"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+func TestProcessDirectWithSystemContext_AddsInstructionsForTheTurnOnly(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "standup posted"}}}
+	al := newTestAgentLoop(t, prov, 2, nil)
+	defer al.bus.Close()
+
+	const persona = "You are the team's standup facilitator."
+	if _, err := al.ProcessDirectWithSystemContext(context.Background(), "Continue our standup thread.", persona, "telegram:42", "telegram", "42"); err != nil {
+		t.Fatalf("ProcessDirectWithSystemContext: %v", err)
+	}
+
+	calls := prov.getCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 provider call, got %d", len(calls))
+	}
+	messages := calls[0].Messages
+	if messages[0].Role != "system" || !strings.Contains(messages[0].Content, persona) {
+		t.Fatalf("expected the persona in the system prompt, got %q", messages[0].Content)
+	}
+	if last := messages[len(messages)-1]; last.Content != "Continue our standup thread." {
+		t.Fatalf("user message = %q, want the job message only", last.Content)
+	}
+	for _, m := range al.sessions.GetHistory("telegram:42") {
+		if strings.Contains(m.Content, persona) {
+			t.Fatalf("persona saved to history: %+v", m)
+		}
+	}
+}
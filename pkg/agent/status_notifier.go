@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// defaultStatusMessages rotate when agents.defaults.status_messages is empty.
+var defaultStatusMessages = []string{
+	"Still working on it...",
+	"This is taking a bit longer, hang tight...",
+	"Still on it, almost there...",
+}
+
+// statusNotifier sends a generic "still working" line to the chat when a turn
+// runs longer than delay, repeating every delay until stopped. Phrases are
+// static config text, so tool names and arguments are never exposed.
+type statusNotifier struct {
+	bus      *bus.MessageBus
+	channel  string
+	chatID   string
+	delay    time.Duration
+	messages []string
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	fired   int
+	stopped bool
+}
+
+// newStatusNotifier returns nil when notifications are disabled (delay <= 0)
+// or the run has no user-facing chat; all methods are nil-safe.
+func newStatusNotifier(msgBus *bus.MessageBus, channel, chatID string, delay time.Duration, messages []string) *statusNotifier {
+	if msgBus == nil || delay <= 0 {
+		return nil
+	}
+	channel = strings.TrimSpace(channel)
+	chatID = strings.TrimSpace(chatID)
+	if channel == "" || chatID == "" || channel == "cli" || channel == "system" {
+		return nil
+	}
+
+	phrases := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if m = strings.TrimSpace(m); m != "" {
+			phrases = append(phrases, m)
+		}
+	}
+	if len(phrases) == 0 {
+		phrases = defaultStatusMessages
+	}
+
+	return &statusNotifier{
+		bus:      msgBus,
+		channel:  channel,
+		chatID:   chatID,
+		delay:    delay,
+		messages: phrases,
+	}
+}
+
+func (n *statusNotifier) start() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped || n.timer != nil {
+		return
+	}
+	n.timer = time.AfterFunc(n.delay, n.fire)
+}
+
+func (n *statusNotifier) stop() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stopped = true
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+}
+
+// fire publishes the next phrase and re-arms the timer. Publishing happens
+// under the lock (the bus never blocks) so nothing is sent after stop returns.
+func (n *statusNotifier) fire() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped {
+		return
+	}
+	content := n.messages[n.fired%len(n.messages)]
+	n.fired++
+	n.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: n.channel,
+		ChatID:  n.chatID,
+		Content: content,
+	})
+	n.timer.Reset(n.delay)
+}
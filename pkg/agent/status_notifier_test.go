@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func collectOutbound(t *testing.T, msgBus *bus.MessageBus, n int, timeout time.Duration) []string {
+	t.Helper()
+	var got []string
+	deadline := time.Now().Add(timeout)
+	for len(got) < n {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		cancel()
+		if !ok {
+			break
+		}
+		got = append(got, msg.Content)
+	}
+	return got
+}
+
+func TestStatusNotifier_RotatesConfiguredPhrasesInOrder(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	n := newStatusNotifier(msgBus, "telegram", "42", 20*time.Millisecond, []string{"one", " ", "two", "three"})
+	n.start()
+	got := collectOutbound(t, msgBus, 5, 2*time.Second)
+	n.stop()
+
+	want := []string{"one", "two", "three", "one", "two"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("status messages = %v, want %v", got, want)
+	}
+}
+
+func TestStatusNotifier_StopPreventsFurtherMessages(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	n := newStatusNotifier(msgBus, "telegram", "42", 20*time.Millisecond, nil)
+	n.start()
+	n.stop()
+
+	if got := collectOutbound(t, msgBus, 1, 100*time.Millisecond); len(got) != 0 {
+		t.Fatalf("expected no status messages after stop, got %v", got)
+	}
+}
+
+func TestNewStatusNotifier_Disabled(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	if n := newStatusNotifier(msgBus, "telegram", "42", 0, []string{"x"}); n != nil {
+		t.Fatal("expected nil notifier when delay is 0")
+	}
+	if n := newStatusNotifier(msgBus, "cli", "direct", time.Second, nil); n != nil {
+		t.Fatal("expected nil notifier for cli")
+	}
+	if n := newStatusNotifier(msgBus, "telegram", "42", time.Second, nil); n == nil || n.messages[0] != defaultStatusMessages[0] {
+		t.Fatal("expected default phrases when none configured")
+	}
+}
+
+type slowSecretTool struct{}
+
+func (t *slowSecretTool) Name() string        { return "secret_internal_tool" }
+func (t *slowSecretTool) Description() string { return "slow tool" }
+func (t *slowSecretTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *slowSecretTool) Execute(ctx context.Context, _ map[string]interface{}) (string, error) {
+	select {
+	case <-time.After(150 * time.Millisecond):
+	case <-ctx.Done():
+	}
+	return "done", nil
+}
+
+func TestRunAgentLoop_StatusMessagesDoNotLeakToolName(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "secret_internal_tool", Arguments: map[string]interface{}{}}}},
+		{Content: "finished"},
+	}}
+	al := newTestAgentLoop(t, prov, 3, []tools.Tool{&slowSecretTool{}})
+	defer al.bus.Close()
+	al.statusDelay = 40 * time.Millisecond
+	al.statusMessages = []string{"working A", "working B"}
+
+	if _, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:  "telegram:42",
+		Channel:     "telegram",
+		ChatID:      "42",
+		UserMessage: "go",
+	}); err != nil {
+		t.Fatalf("runAgentLoop: %v", err)
+	}
+
+	got := collectOutbound(t, al.bus, 10, 100*time.Millisecond)
+	if len(got) == 0 || got[0] != "working A" {
+		t.Fatalf("expected status messages starting with configured phrase, got %v", got)
+	}
+	for _, content := range got {
+		if strings.Contains(content, "secret_internal_tool") {
+			t.Fatalf("status message leaked tool name: %q", content)
+		}
+	}
+}
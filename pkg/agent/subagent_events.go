@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// subagentEventPolicy returns how a subagent event reaches the user: the
+// configured agents.defaults.subagent_events policy, else internal for
+// updates and cancellations and forward for final reports.
+func (al *AgentLoop) subagentEventPolicy(event string) string {
+	if policy, ok := al.subagentEvents[event]; ok {
+		return policy
+	}
+	switch event {
+	case "progress", "note", "warning", "cancelled":
+		return config.SubagentEventInternal
+	}
+	return config.SubagentEventForward
+}
+
+// isSubagentUpdateEvent reports whether event is an update sent while a
+// subagent runs, as opposed to its final report. Forwarded updates go
+// straight to the chat instead of through the agent.
+func isSubagentUpdateEvent(event string) bool {
+	switch event {
+	case "progress", "note", "warning":
+		return true
+	}
+	return false
+}
+
+// forwardSubagentUpdate sends a subagent update to the origin chat as-is.
+func (al *AgentLoop) forwardSubagentUpdate(channel, chatID string, msg bus.InboundMessage) {
+	name := strings.TrimPrefix(msg.SenderID, "subagent:")
+	if label := strings.TrimSpace(msg.Metadata["subagent_label"]); label != "" {
+		name = label
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: fmt.Sprintf("[%s] %s", name, msg.Content),
+	})
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func subagentEventMessage(event, content string) bus.InboundMessage {
+	return bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "subagent:subagent-1",
+		ChatID:   "telegram:chat1",
+		Content:  content,
+		Metadata: map[string]string{"subagent_event": event, "subagent_label": "crawler"},
+	}
+}
+
+func TestProcessSystemMessage_SubagentProgress_ForwardedWhenConfigured(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "unused"}}}
+	al := newTestAgentLoop(t, prov, 1, nil)
+	defer al.bus.Close()
+	al.subagentEvents = map[string]string{"progress": config.SubagentEventForward}
+
+	resp, err := al.processSystemMessage(context.Background(), subagentEventMessage("progress", "fetched 3 of 10 pages"), "trace-fwd")
+	if err != nil {
+		t.Fatalf("processSystemMessage error: %v", err)
+	}
+	if resp != "" {
+		t.Errorf("response = %q, want empty", resp)
+	}
+
+	outCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := al.bus.SubscribeOutbound(outCtx)
+	if !ok {
+		t.Fatal("expected the progress update to be forwarded")
+	}
+	if out.Channel != "telegram" || out.ChatID != "chat1" || out.Content != "[crawler] fetched 3 of 10 pages" {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+
+	// The agent still sees the update, and no LLM call is made for it.
+	history := al.sessions.GetHistory("telegram:chat1")
+	if len(history) != 1 || !containsStr(history[0].Content, "Internal") {
+		t.Fatalf("expected an internal note in history, got %+v", history)
+	}
+	if calls := prov.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected no provider calls, got %d", len(calls))
+	}
+}
+
+func TestProcessSystemMessage_SubagentProgress_InternalByDefault(t *testing.T) {
+	al := newTestAgentLoop(t, &mockProvider{}, 1, nil)
+	defer al.bus.Close()
+
+	if _, err := al.processSystemMessage(context.Background(), subagentEventMessage("progress", "step 1"), "trace-int"); err != nil {
+		t.Fatalf("processSystemMessage error: %v", err)
+	}
+
+	outCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if out, ok := al.bus.SubscribeOutbound(outCtx); ok {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+	if history := al.sessions.GetHistory("telegram:chat1"); len(history) != 1 {
+		t.Fatalf("history len = %d, want 1", len(history))
+	}
+}
+
+func TestProcessSystemMessage_SubagentEventSuppressed(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "unused"}}}
+	al := newTestAgentLoop(t, prov, 1, nil)
+	defer al.bus.Close()
+	al.subagentEvents = map[string]string{
+		"warning":  config.SubagentEventSuppress,
+		"complete": config.SubagentEventSuppress,
+	}
+
+	for _, event := range []string{"warning", "complete"} {
+		if _, err := al.processSystemMessage(context.Background(), subagentEventMessage(event, "ignored"), "trace-sup"); err != nil {
+			t.Fatalf("processSystemMessage(%s) error: %v", event, err)
+		}
+	}
+
+	outCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if out, ok := al.bus.SubscribeOutbound(outCtx); ok {
+		t.Fatalf("unexpected outbound message: %+v", out)
+	}
+	if history := al.sessions.GetHistory("telegram:chat1"); len(history) != 0 {
+		t.Fatalf("expected nothing stored, got %+v", history)
+	}
+	if calls := prov.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected no provider calls, got %d", len(calls))
+	}
+}
+
+func TestProcessSystemMessage_SubagentCompleteInternalWhenConfigured(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{{Content: "unused"}}}
+	al := newTestAgentLoop(t, prov, 1, nil)
+	defer al.bus.Close()
+	al.subagentEvents = map[string]string{"complete": config.SubagentEventInternal}
+
+	if _, err := al.processSystemMessage(context.Background(), subagentEventMessage("complete", "all done"), "trace-done"); err != nil {
+		t.Fatalf("processSystemMessage error: %v", err)
+	}
+	if calls := prov.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected the report to be stored without an agent run, got %d calls", len(calls))
+	}
+	if history := al.sessions.GetHistory("telegram:chat1"); len(history) != 1 || !containsStr(history[0].Content, "all done") {
+		t.Fatalf("expected the report as an internal note, got %+v", history)
+	}
+}
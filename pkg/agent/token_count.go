@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// tokenCountRefreshTokens is how much (estimated) history may be appended
+	// to an exactly counted prefix before the provider is asked again; smaller
+	// growth is added to the cached count as an estimate.
+	tokenCountRefreshTokens = 2000
+	// tokenCountCacheSize bounds the cached prefix counts across sessions.
+	tokenCountCacheSize = 256
+	tokenCountTimeout   = 15 * time.Second
+)
+
+type tokenCountKey struct {
+	model  string
+	prefix uint64
+}
+
+// tokenCountCache remembers exact token counts by message-list prefix, so
+// a history that only grew by a few messages is not recounted. The zero
+// value is ready to use.
+type tokenCountCache struct {
+	mu          sync.Mutex
+	counts      map[tokenCountKey]int
+	order       []tokenCountKey     // insertion order for eviction
+	unsupported map[string]struct{} // models whose provider cannot count
+}
+
+// lookup returns the length and count of the longest cached prefix of
+// messages whose prefix hashes are hashes.
+func (c *tokenCountCache) lookup(model string, hashes []uint64) (int, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(hashes); i > 0; i-- {
+		if n, ok := c.counts[tokenCountKey{model: model, prefix: hashes[i-1]}]; ok {
+			return i, n, true
+		}
+	}
+	return 0, 0, false
+}
+
+func (c *tokenCountCache) store(model string, prefix uint64, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[tokenCountKey]int)
+	}
+	key := tokenCountKey{model: model, prefix: prefix}
+	if _, exists := c.counts[key]; !exists {
+		c.order = append(c.order, key)
+	}
+	c.counts[key] = count
+	for len(c.order) > tokenCountCacheSize {
+		delete(c.counts, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *tokenCountCache) isUnsupported(model string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.unsupported[model]
+	return ok
+}
+
+func (c *tokenCountCache) markUnsupported(model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unsupported == nil {
+		c.unsupported = make(map[string]struct{})
+	}
+	c.unsupported[model] = struct{}{}
+}
+
+// messagePrefixHashes returns one hash per prefix: hashes[i] covers
+// messages[:i+1].
+func messagePrefixHashes(messages []providers.Message) []uint64 {
+	h := fnv.New64a()
+	hashes := make([]uint64, len(messages))
+	for i, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			data = []byte(m.Role + "\x00" + m.Content)
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+		hashes[i] = h.Sum64()
+	}
+	return hashes
+}
+
+// countTokens returns the token count of messages for the session's model.
+// Providers with a token-counting endpoint give exact counts (cached by
+// prefix); everything else uses the estimateTokens heuristic.
+func (al *AgentLoop) countTokens(sessionKey string, messages []providers.Message) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	model, provider := al.resolveSessionModel(sessionKey, "")
+	if provider == nil || al.tokenCounts.isUnsupported(model) {
+		return al.estimateTokens(messages)
+	}
+
+	hashes := messagePrefixHashes(messages)
+	cachedLen, cached, ok := al.tokenCounts.lookup(model, hashes)
+	if ok && cachedLen == len(messages) {
+		return cached
+	}
+	if ok {
+		if growth := al.estimateTokens(messages[cachedLen:]); growth < tokenCountRefreshTokens {
+			return cached + growth
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCountTimeout)
+	defer cancel()
+	n, err := providers.CountTokens(ctx, provider, messages, model)
+	if err != nil {
+		if errors.Is(err, providers.ErrTokenCountUnsupported) {
+			al.tokenCounts.markUnsupported(model)
+		} else {
+			logger.WarnCF("agent", "Token count failed, using estimate", map[string]interface{}{
+				"session_key": sessionKey,
+				"model":       model,
+				"error":       err.Error(),
+			})
+		}
+		if ok {
+			return cached + al.estimateTokens(messages[cachedLen:])
+		}
+		return al.estimateTokens(messages)
+	}
+	al.tokenCounts.store(model, hashes[len(hashes)-1], n)
+	return n
+}
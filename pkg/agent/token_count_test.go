@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// countingProvider is a mockProvider with a token-counting endpoint that
+// reports a fixed count.
+type countingProvider struct {
+	mockProvider
+	mu     sync.Mutex
+	tokens int
+	counts int
+}
+
+func (p *countingProvider) CountTokens(_ context.Context, _ []providers.Message, _ string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts++
+	return p.tokens, nil
+}
+
+func (p *countingProvider) countCalls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts
+}
+
+func addTurns(al *AgentLoop, sessionKey string, n int, content string) {
+	for i := 0; i < n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		al.sessions.AddMessage(sessionKey, role, content)
+	}
+}
+
+func TestMaybeSummarize_UsesExactTokenCount(t *testing.T) {
+	prov := &countingProvider{tokens: 5000}
+	prov.responses = []mockResponse{{Content: "condensed"}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.contextWindow = 1000
+
+	// Six short messages estimate to a handful of tokens; only the real
+	// count crosses the 75% threshold.
+	addTurns(al, "s1", 6, "short")
+	al.maybeSummarize("s1", turnUsage{})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for al.sessions.GetSummary("s1") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected summarization triggered by the exact token count")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for {
+		if _, busy := al.summarizing.Load("s1"); !busy {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if prov.countCalls() == 0 {
+		t.Fatal("expected the provider's token counter to be used")
+	}
+}
+
+func TestMaybeSummarize_UsesWindowOfModelThatServedTheTurn(t *testing.T) {
+	prov := &countingProvider{tokens: 100}
+	prov.responses = []mockResponse{{Content: "condensed"}}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.contextWindow = 1000000
+	al.contextWindows = map[string]int{"small-fallback": 1000}
+
+	// 900 prompt tokens fit the configured model's window but not that of
+	// the fallback model that actually answered.
+	addTurns(al, "s1", 6, "short")
+	al.maybeSummarize("s1", turnUsage{promptTokens: 900, model: "small-fallback"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for al.sessions.GetSummary("s1") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected summarization against the fallback model's window")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for {
+		if _, busy := al.summarizing.Load("s1"); !busy {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMaybeSummarize_ExactCountBelowThresholdSkipsSummary(t *testing.T) {
+	prov := &countingProvider{tokens: 100}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+	al.contextWindow = 1000
+
+	// The len/4 heuristic puts this at ~1500 tokens, well past the threshold.
+	addTurns(al, "s1", 6, strings.Repeat("x", 1000))
+	al.maybeSummarize("s1", turnUsage{})
+
+	if _, busy := al.summarizing.Load("s1"); busy {
+		t.Fatal("summarization started although the exact count is below the threshold")
+	}
+	if calls := prov.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected no LLM calls, got %d", len(calls))
+	}
+}
+
+func TestCountTokens_CachesByPrefix(t *testing.T) {
+	prov := &countingProvider{tokens: 700}
+	al := newTestAgentLoop(t, prov, 3, nil)
+	defer al.bus.Close()
+
+	addTurns(al, "s1", 4, "hello")
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 700 {
+		t.Fatalf("first count = %d, want 700", got)
+	}
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 700 || prov.countCalls() != 1 {
+		t.Fatalf("unchanged history: count = %d after %d calls, want 700 after 1", got, prov.countCalls())
+	}
+
+	// A small addition is estimated on top of the cached prefix.
+	al.sessions.AddMessage("s1", "user", strings.Repeat("y", 40))
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 710 || prov.countCalls() != 1 {
+		t.Fatalf("small growth: count = %d after %d calls, want 710 after 1", got, prov.countCalls())
+	}
+
+	// Enough new content triggers a fresh exact count.
+	prov.mu.Lock()
+	prov.tokens = 4000
+	prov.mu.Unlock()
+	al.sessions.AddMessage("s1", "assistant", strings.Repeat("z", 4*tokenCountRefreshTokens))
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 4000 || prov.countCalls() != 2 {
+		t.Fatalf("large growth: count = %d after %d calls, want 4000 after 2", got, prov.countCalls())
+	}
+}
+
+func TestCountTokens_ThroughNewAgentLoop(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	prov := &countingProvider{tokens: 700}
+
+	// NewAgentLoop wraps the provider for usage tracking; the counter must
+	// still be found behind the wrapper.
+	al, err := NewAgentLoop(cfg, bus.NewMessageBus(), prov)
+	if err != nil {
+		t.Fatalf("NewAgentLoop: %v", err)
+	}
+	defer al.bus.Close()
+
+	addTurns(al, "s1", 4, "hello")
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 700 || prov.countCalls() != 1 {
+		t.Fatalf("count = %d after %d calls, want 700 from the provider", got, prov.countCalls())
+	}
+}
+
+func TestCountTokens_FallsBackToEstimate(t *testing.T) {
+	al := newTestAgentLoop(t, &mockProvider{}, 3, nil)
+	defer al.bus.Close()
+
+	addTurns(al, "s1", 2, strings.Repeat("a", 400))
+	if got := al.countTokens("s1", al.sessions.GetHistory("s1")); got != 200 {
+		t.Fatalf("count = %d, want heuristic 200", got)
+	}
+	if !al.tokenCounts.isUnsupported("test-model") {
+		t.Fatal("model without a token counter should be remembered")
+	}
+}
@@ -45,3 +45,34 @@ func TestResolveTokenLimits_ZeroValuesUseSafeDefaults(t *testing.T) {
 		t.Fatalf("ctx = %d, want 8192", ctx)
 	}
 }
+
+func TestContextWindowFor_PerModel(t *testing.T) {
+	al := &AgentLoop{
+		contextWindow:  8192,
+		contextWindows: map[string]int{"llama3:8b": 4096},
+	}
+
+	cases := map[string]int{
+		"anthropic/claude-sonnet-4": 200000,
+		"llama3:8b":                 4096,
+		"my-local-model":            8192,
+	}
+	for model, want := range cases {
+		if got := al.contextWindowFor(model); got != want {
+			t.Fatalf("contextWindowFor(%q) = %d, want %d", model, got, want)
+		}
+	}
+
+	al.model = "claude-sonnet-4"
+	if got, want := al.summarizeBudget(), 200000/2-summarizePromptOverheadTokens; got != want {
+		t.Fatalf("summarizeBudget for claude = %d, want %d", got, want)
+	}
+	al.model = "llama3:8b"
+	if got, want := al.summarizeBudget(), 4096/2-summarizePromptOverheadTokens; got != want {
+		t.Fatalf("summarizeBudget for llama3:8b = %d, want %d", got, want)
+	}
+
+	if got := (&AgentLoop{}).contextWindowFor("claude-sonnet-4"); got != 0 {
+		t.Fatalf("expected unknown window when no default is configured, got %d", got)
+	}
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// toolProgressMaxChars caps one forwarded progress line.
+const toolProgressMaxChars = 200
+
+// toolProgressForwarder relays progress lines that tools report while they
+// run to the chat. Each tool call may send at most one line per interval;
+// extra lines in between are dropped. Unlike statusNotifier the text comes
+// from the tool, so it is redacted before sending.
+type toolProgressForwarder struct {
+	bus      *bus.MessageBus
+	channel  string
+	chatID   string
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	last map[int]time.Time // by index in the tool call batch
+}
+
+// newToolProgressForwarder returns nil when forwarding is disabled
+// (interval <= 0) or the run has no user-facing chat.
+func newToolProgressForwarder(msgBus *bus.MessageBus, opts processOptions, interval time.Duration) *toolProgressForwarder {
+	if msgBus == nil || interval <= 0 {
+		return nil
+	}
+	channel := strings.TrimSpace(opts.Channel)
+	chatID := strings.TrimSpace(opts.ChatID)
+	if channel == "" || chatID == "" || channel == "cli" || channel == "system" {
+		return nil
+	}
+	if !shouldEchoToolCallsForSession(opts.SessionKey) {
+		return nil
+	}
+	return &toolProgressForwarder{
+		bus:      msgBus,
+		channel:  channel,
+		chatID:   chatID,
+		interval: interval,
+		now:      time.Now,
+		last:     make(map[int]time.Time),
+	}
+}
+
+func (f *toolProgressForwarder) forward(index int, call providers.ToolCall, message string) {
+	message = strings.TrimSpace(message)
+	if f == nil || message == "" {
+		return
+	}
+	f.mu.Lock()
+	now := f.now()
+	if last, ok := f.last[index]; ok && now.Sub(last) < f.interval {
+		f.mu.Unlock()
+		return
+	}
+	f.last[index] = now
+	f.mu.Unlock()
+
+	message = utils.Truncate(redactSensitive(message), toolProgressMaxChars)
+	f.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: f.channel,
+		ChatID:  f.chatID,
+		Content: "⏳ " + call.Name + ": " + message,
+	})
+}
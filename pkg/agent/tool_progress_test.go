@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// progressTool reports each of its lines through the context reporter.
+type progressTool struct {
+	lines []string
+}
+
+func (t *progressTool) Name() string        { return "build" }
+func (t *progressTool) Description() string { return "reports progress" }
+func (t *progressTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *progressTool) Execute(ctx context.Context, _ map[string]interface{}) (string, error) {
+	for _, line := range t.lines {
+		tools.ReportProgress(ctx, line)
+	}
+	return "built", nil
+}
+
+func TestRunAgentLoop_ForwardsToolProgress(t *testing.T) {
+	prov := &mockProvider{responses: []mockResponse{
+		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "build", Arguments: map[string]interface{}{}}}},
+		{Content: "finished"},
+	}}
+	al := newTestAgentLoop(t, prov, 3, []tools.Tool{&progressTool{lines: []string{
+		"compiling 3/10 with token=abcdef1234567890",
+		"compiling 7/10",
+	}}})
+	defer al.bus.Close()
+	al.toolProgressInterval = time.Hour
+
+	if _, err := al.runAgentLoop(context.Background(), processOptions{
+		SessionKey:  "telegram:42",
+		Channel:     "telegram",
+		ChatID:      "42",
+		UserMessage: "build it",
+	}); err != nil {
+		t.Fatalf("runAgentLoop: %v", err)
+	}
+
+	got := collectOutbound(t, al.bus, 10, 100*time.Millisecond)
+	var progress []string
+	for _, content := range got {
+		if strings.HasPrefix(content, "⏳ ") {
+			progress = append(progress, content)
+		}
+	}
+	// The second line falls inside the rate-limit interval and is dropped.
+	if len(progress) != 1 {
+		t.Fatalf("expected 1 progress message, got %v", got)
+	}
+	if !strings.HasPrefix(progress[0], "⏳ build: compiling 3/10") {
+		t.Fatalf("unexpected progress message %q", progress[0])
+	}
+	if strings.Contains(progress[0], "abcdef1234567890") {
+		t.Fatalf("progress message leaked a secret: %q", progress[0])
+	}
+}
+
+func TestToolProgressForwarder_RateLimitsPerCall(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	f := newToolProgressForwarder(msgBus, processOptions{Channel: "telegram", ChatID: "42"}, 10*time.Second)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	call := providers.ToolCall{Name: "exec"}
+
+	f.forward(0, call, "first")
+	f.forward(1, call, "other call")
+	f.forward(0, call, "too soon")
+	now = now.Add(10 * time.Second)
+	f.forward(0, call, "later")
+
+	got := collectOutbound(t, msgBus, 4, 100*time.Millisecond)
+	want := []string{"⏳ exec: first", "⏳ exec: other call", "⏳ exec: later"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("progress messages = %v, want %v", got, want)
+	}
+}
+
+func TestNewToolProgressForwarder_Disabled(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	if f := newToolProgressForwarder(msgBus, processOptions{Channel: "telegram", ChatID: "42"}, 0); f != nil {
+		t.Fatal("expected nil forwarder when interval is 0")
+	}
+	if f := newToolProgressForwarder(msgBus, processOptions{Channel: "cli", ChatID: "direct"}, time.Second); f != nil {
+		t.Fatal("expected nil forwarder for cli")
+	}
+	if f := newToolProgressForwarder(msgBus, processOptions{Channel: "telegram", ChatID: "42", SessionKey: "heartbeat"}, time.Second); f != nil {
+		t.Fatal("expected nil forwarder for background sessions")
+	}
+}
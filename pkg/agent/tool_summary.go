@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// toolSummaryMaxInputBytes bounds the output sent to the summarizer when
+	// the context window is unknown; longer output keeps its head and tail.
+	toolSummaryMaxInputBytes = 256 * 1024
+	// toolOutputMaxAge is how long saved full outputs are kept.
+	toolOutputMaxAge = 24 * time.Hour
+)
+
+// toolOutputDir is where full copies of summarized tool results are saved,
+// inside the caller's workspace root so read_file can reach them.
+func (al *AgentLoop) toolOutputDir(opts processOptions) (string, error) {
+	root, err := tools.ScopedWorkspace(al.workspace, al.workspaceIsolation, opts.Channel, opts.ChatID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "tmp", "tool-output"), nil
+}
+
+// summarizeToolResult replaces an oversized tool result with a model-written
+// summary and a note pointing at a saved copy of the full output. If the
+// summary fails, the note is prepended to the original content, which the
+// result limit then truncates as usual.
+func (al *AgentLoop) summarizeToolResult(ctx context.Context, call providers.ToolCall, content string, opts processOptions) string {
+	savedPath, saveErr := al.saveToolOutput(call, content, opts)
+	if saveErr != nil {
+		logger.WarnCF("agent", "Failed to save full tool output", map[string]interface{}{
+			"tool":  call.Name,
+			"error": saveErr.Error(),
+		})
+	}
+	where := "The full output could not be saved."
+	if savedPath != "" {
+		where = fmt.Sprintf("The full output is saved at %s; use read_file to read the parts you need.", savedPath)
+	}
+
+	summary, err := al.summarizeToolOutput(ctx, call, content)
+	if err != nil {
+		logger.WarnCF("agent", "Tool output summarization failed, truncating instead", map[string]interface{}{
+			"tool":  call.Name,
+			"bytes": len(content),
+			"error": err.Error(),
+		})
+		return fmt.Sprintf("[Output of %s was %d bytes and could not be summarized. %s]\n\n%s",
+			call.Name, len(content), where, content)
+	}
+	return fmt.Sprintf("[Output of %s was %d bytes, too large to include, so this is a summary. %s]\n\n%s",
+		call.Name, len(content), where, summary)
+}
+
+// summarizeToolOutput asks the model for a summary of content, keeping its
+// head and tail when it does not fit one summarize prompt.
+func (al *AgentLoop) summarizeToolOutput(ctx context.Context, call providers.ToolCall, content string) (string, error) {
+	maxInput := toolSummaryMaxInputBytes
+	if budget := al.summarizeBudget(); budget > 0 && budget*4 < maxInput {
+		maxInput = budget * 4
+	}
+	prompt := fmt.Sprintf("The %s tool returned the output below, which is too large to read in full. "+
+		"Summarize it for an agent continuing its task: keep errors, warnings, counts, names, paths, "+
+		"identifiers and any values likely to matter, and say what kind of output it is.\n\nOUTPUT:\n%s",
+		call.Name, utils.TruncateMiddle(content, maxInput))
+
+	response, err := providers.ChatWithTimeout(ctx, al.llmTimeout, al.provider,
+		[]providers.Message{{Role: "user", Content: prompt}}, nil, al.model, al.compactOptions.ToMap())
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
+
+// saveToolOutput writes content to the tool output directory, first
+// removing copies older than toolOutputMaxAge, and returns its path.
+func (al *AgentLoop) saveToolOutput(call providers.ToolCall, content string, opts processOptions) (string, error) {
+	dir, err := al.toolOutputDir(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if _, err := utils.SweepStaleFiles(dir, toolOutputMaxAge, time.Now()); err != nil {
+		logger.DebugCF("agent", "Failed to sweep tool output dir", map[string]interface{}{
+			"dir":   dir,
+			"error": err.Error(),
+		})
+	}
+
+	id := strings.TrimSpace(call.ID)
+	if id == "" {
+		id = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	path := filepath.Join(dir, utils.SanitizeFilename(call.Name+"-"+id+".txt"))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
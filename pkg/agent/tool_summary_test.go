@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+var savedToolOutputPattern = regexp.MustCompile(`saved at (\S+);`)
+
+func TestExecuteToolsConcurrently_SummarizesOversizedResult(t *testing.T) {
+	output := strings.Repeat("line of build output\n", 500)
+	prov := &mockProvider{responses: []mockResponse{{Content: "Build log: 500 identical lines, no errors."}}}
+	al := newTestAgentLoop(t, prov, 5, []tools.Tool{&noopTool{name: "build", result: output}})
+	al.toolSummarizeOver = 4096
+
+	results := al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "build", Arguments: map[string]interface{}{}},
+	}, 1, processOptions{Channel: "cli", ChatID: "direct"})
+
+	if len(results) != 1 {
+		t.Fatalf("expected one result, got %d", len(results))
+	}
+	content := results[0].Content
+	if !strings.Contains(content, "Build log: 500 identical lines, no errors.") {
+		t.Fatalf("expected summary in result, got %q", content)
+	}
+	if strings.Contains(content, "line of build output") {
+		t.Fatalf("expected raw output to be replaced, got %q", content)
+	}
+	m := savedToolOutputPattern.FindStringSubmatch(content)
+	if m == nil {
+		t.Fatalf("expected a saved file reference, got %q", content)
+	}
+	saved, err := os.ReadFile(m[1])
+	if err != nil {
+		t.Fatalf("read saved output: %v", err)
+	}
+	if string(saved) != output {
+		t.Fatalf("saved output differs from the tool result (%d vs %d bytes)", len(saved), len(output))
+	}
+
+	calls := prov.getCalls()
+	if len(calls) != 1 || !strings.Contains(calls[0].Messages[0].Content, "line of build output") {
+		t.Fatalf("expected one summarize call over the output, got %+v", calls)
+	}
+}
+
+func TestExecuteToolsConcurrently_SmallResultNotSummarized(t *testing.T) {
+	prov := &mockProvider{}
+	al := newTestAgentLoop(t, prov, 5, []tools.Tool{&noopTool{name: "build", result: "ok"}})
+	al.toolSummarizeOver = 4096
+
+	results := al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "build", Arguments: map[string]interface{}{}},
+	}, 1, processOptions{Channel: "cli", ChatID: "direct"})
+
+	if len(results) != 1 || results[0].Content != "ok" {
+		t.Fatalf("expected small result unchanged, got %+v", results)
+	}
+	if calls := prov.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected no summarize call, got %d", len(calls))
+	}
+}
+
+func TestExecuteToolsConcurrently_SummaryFailureKeepsOutput(t *testing.T) {
+	output := strings.Repeat("x", 5000)
+	prov := &mockProvider{responses: []mockResponse{{Err: errors.New("provider down")}}}
+	al := newTestAgentLoop(t, prov, 5, []tools.Tool{&noopTool{name: "build", result: output}})
+	al.toolSummarizeOver = 4096
+
+	results := al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "build", Arguments: map[string]interface{}{}},
+	}, 1, processOptions{Channel: "cli", ChatID: "direct"})
+
+	content := results[0].Content
+	if !strings.Contains(content, "could not be summarized") || !strings.HasSuffix(content, output) {
+		t.Fatalf("expected note plus original output, got %q", content[:200])
+	}
+	if savedToolOutputPattern.FindStringSubmatch(content) == nil {
+		t.Fatalf("expected a saved file reference, got %q", content[:200])
+	}
+}
+
+func TestExecuteToolsConcurrently_SavesOutputInIsolatedWorkspace(t *testing.T) {
+	output := strings.Repeat("y", 5000)
+	prov := &mockProvider{responses: []mockResponse{{Content: "5000 y's."}}}
+	al := newTestAgentLoop(t, prov, 5, []tools.Tool{&noopTool{name: "build", result: output}})
+	al.toolSummarizeOver = 4096
+	al.workspaceIsolation = tools.WorkspacePerChat
+
+	results := al.executeToolsConcurrently(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "build", Arguments: map[string]interface{}{}},
+	}, 1, processOptions{Channel: "telegram", ChatID: "42"})
+
+	m := savedToolOutputPattern.FindStringSubmatch(results[0].Content)
+	if m == nil {
+		t.Fatalf("expected a saved file reference, got %q", results[0].Content)
+	}
+	if want := filepath.Join(al.workspace, "telegram", "42", "tmp", "tool-output") + string(filepath.Separator); !strings.HasPrefix(m[1], want) {
+		t.Fatalf("saved output at %s, want it under %s", m[1], want)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/events"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
@@ -34,6 +37,7 @@ func redactSensitive(s string) string {
 var toolsToEcho = map[string]bool{
 	"exec":          true,
 	"edit_file":     true,
+	"patch_file":    true,
 	"write_file":    true,
 	"read_file":     true,
 	"list_dir":      true,
@@ -42,13 +46,79 @@ var toolsToEcho = map[string]bool{
 	"image_inspect": true,
 	"spawn":         true,
 	"memory_store":  true,
+	"memory_update": true,
 	"memory_search": true,
+	"memory_list":   true,
+	"memory_export": true,
 	"compact":       true,
 }
 
+// sensitiveArgKeyPattern matches argument names whose values are never echoed.
+var sensitiveArgKeyPattern = regexp.MustCompile(`(?i)api[_-]?key|token|password|passwd|secret`)
+
+// redactSensitiveArgs returns a copy of args with values of sensitive keys
+// replaced. Nested maps are redacted recursively.
+func redactSensitiveArgs(args map[string]interface{}) map[string]interface{} {
+	if len(args) == 0 {
+		return args
+	}
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if sensitiveArgKeyPattern.MatchString(k) {
+			out[k] = "[REDACTED]"
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			out[k] = redactSensitiveArgs(nested)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// compactArgsSummary renders up to three scalar arguments as key=value pairs,
+// for tools without a dedicated key parameter. Internal __context_* args are
+// skipped.
+func compactArgsSummary(args map[string]interface{}) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		if strings.HasPrefix(k, "__context_") || k == "description" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		var value string
+		switch v := args[k].(type) {
+		case string:
+			value = v
+		case float64, int, int64, bool:
+			value = fmt.Sprint(v)
+		default:
+			continue
+		}
+		if len(value) > 40 {
+			value = value[:37] + "..."
+		}
+		parts = append(parts, k+"="+value)
+		if len(parts) == 3 {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 func formatToolCallSummary(tc providers.ToolCall) string {
+	tc.Arguments = redactSensitiveArgs(tc.Arguments)
 	description := redactSensitive(extractToolCallDescription(tc))
 	keyParam := extractKeyParam(tc.Name, tc.Arguments)
+	if keyParam == "" {
+		keyParam = compactArgsSummary(tc.Arguments)
+	}
 	keyParam = redactSensitive(keyParam)
 
 	if description != "" && keyParam != "" {
@@ -96,7 +166,7 @@ func extractKeyParam(toolName string, args map[string]interface{}) string {
 			}
 			return cmd
 		}
-	case "edit_file", "read_file", "write_file", "list_dir":
+	case "edit_file", "patch_file", "read_file", "write_file", "list_dir":
 		if path, ok := args["path"].(string); ok {
 			return path
 		}
@@ -130,7 +200,7 @@ func extractKeyParam(toolName string, args map[string]interface{}) string {
 			}
 			return task
 		}
-	case "memory_store", "memory_search":
+	case "memory_store", "memory_update", "memory_search":
 		if content, ok := args["content"].(string); ok {
 			if len(content) > 50 {
 				return content[:47] + "..."
@@ -143,6 +213,14 @@ func extractKeyParam(toolName string, args map[string]interface{}) string {
 			}
 			return query
 		}
+	case "memory_list":
+		if category, ok := args["category"].(string); ok {
+			return category
+		}
+	case "memory_export":
+		if path, ok := args["path"].(string); ok {
+			return path
+		}
 	case "compact":
 		if mode, ok := args["mode"].(string); ok {
 			return mode
@@ -175,6 +253,22 @@ func (al *AgentLoop) maybeEchoToolCalls(toolCalls []providers.ToolCall, channel,
 	})
 }
 
+// maybeEchoInterimText forwards narration the model wrote alongside tool
+// calls (e.g. "Let me check...") to the chat while the tools run.
+func (al *AgentLoop) maybeEchoInterimText(content string, opts processOptions) {
+	if !al.echoInterimText || opts.Channel == "" || opts.Channel == "system" || opts.ChatID == "" {
+		return
+	}
+	if !shouldEchoToolCallsForSession(opts.SessionKey) {
+		return
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: opts.Channel,
+		ChatID:  opts.ChatID,
+		Content: content,
+	})
+}
+
 func shouldEchoToolCallsForSession(sessionKey string) bool {
 	sessionKey = strings.TrimSpace(sessionKey)
 	if sessionKey == "" {
@@ -261,6 +355,17 @@ func (al *AgentLoop) executeToolsConcurrently(
 			}
 		}
 	}
+	// The message tool marks replies to the chat with the message they answer.
+	if strings.TrimSpace(opts.MessageID) != "" {
+		for i := range toolCalls {
+			if toolCalls[i].Arguments == nil {
+				toolCalls[i].Arguments = map[string]interface{}{}
+			}
+			if _, exists := toolCalls[i].Arguments["__context_message_id"]; !exists {
+				toolCalls[i].Arguments["__context_message_id"] = opts.MessageID
+			}
+		}
+	}
 
 	inlineVision := al.modelCapabilities.SupportsVision && al.modelCapabilities.SupportsInlineVision
 	if inlineVision {
@@ -291,6 +396,14 @@ func (al *AgentLoop) executeToolsConcurrently(
 		al.maybeEchoToolCalls(toolCalls, opts.Channel, opts.ChatID)
 	}
 
+	var onToolProgress func(int, providers.ToolCall, string)
+	if forwarder := newToolProgressForwarder(al.bus, opts, al.toolProgressInterval); forwarder != nil {
+		onToolProgress = forwarder.forward
+	}
+	summarizeResult := func(ctx context.Context, call providers.ToolCall, content string) string {
+		return al.summarizeToolResult(ctx, call, content, opts)
+	}
+
 	results := al.tools.ExecuteToolCalls(ctx, toolCalls, tools.ExecuteToolCallsOptions{
 		Channel:      opts.Channel,
 		ChatID:       opts.ChatID,
@@ -300,7 +413,13 @@ func (al *AgentLoop) executeToolsConcurrently(
 		MaxParallel:  al.maxParallelTools,
 		LogComponent: "agent",
 		Iteration:    iteration,
-		OnToolStart: func(_ int, _ int, _ int, call providers.ToolCall) {
+		OnToolStart: func(_ int, _ int, index int, call providers.ToolCall) {
+			al.publishEvent(events.ToolCallStart, opts.SessionKey, opts.TraceID, map[string]interface{}{
+				"tool":         call.Name,
+				"tool_call_id": call.ID,
+				"iteration":    iteration,
+				"index":        index,
+			})
 			if progress != nil {
 				progress.onToolStart(call)
 			}
@@ -317,6 +436,23 @@ func (al *AgentLoop) executeToolsConcurrently(
 				progress.onToolComplete(call, result)
 			}
 		},
+		OnToolFinished: func(_ int, call providers.ToolCall, result providers.Message, err error, duration time.Duration) {
+			al.toolAudit.record(call, result, err, duration, opts)
+			data := map[string]interface{}{
+				"tool":         call.Name,
+				"tool_call_id": call.ID,
+				"iteration":    iteration,
+				"duration_ms":  duration.Milliseconds(),
+				"result_chars": len(result.Content),
+			}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			al.publishEvent(events.ToolCallEnd, opts.SessionKey, opts.TraceID, data)
+		},
+		OnToolProgress:  onToolProgress,
+		SummarizeOver:   al.toolSummarizeOver,
+		SummarizeResult: summarizeResult,
 	})
 
 	// If the message tool sent user-facing output to a different session
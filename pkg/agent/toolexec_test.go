@@ -640,7 +640,7 @@ func TestExecuteToolsConcurrently_MirrorsMessageToolSendToTargetSession(t *testi
 	tmpDir := t.TempDir()
 	registry := tools.NewToolRegistry()
 	msgTool := tools.NewMessageTool()
-	msgTool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	msgTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return nil
 	})
 	registry.Register(msgTool)
@@ -687,11 +687,45 @@ func TestExecuteToolsConcurrently_MirrorsMessageToolSendToTargetSession(t *testi
 	}
 }
 
+func TestExecuteToolsConcurrently_MessageToolRepliesToInboundMessage(t *testing.T) {
+	tmpDir := t.TempDir()
+	registry := tools.NewToolRegistry()
+	msgTool := tools.NewMessageTool()
+	var gotReplyTo string
+	msgTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
+		gotReplyTo = replyTo
+		return nil
+	})
+	registry.Register(msgTool)
+
+	al := &AgentLoop{
+		bus:           bus.NewMessageBus(),
+		workspace:     tmpDir,
+		model:         "test-model",
+		maxIterations: 5,
+		sessions:      session.NewSessionManager(filepath.Join(tmpDir, "sessions")),
+		tools:         registry,
+	}
+	defer al.bus.Close()
+
+	opts := processOptions{SessionKey: "telegram:chat1", Channel: "telegram", ChatID: "chat1", MessageID: "77"}
+	toolCalls := []providers.ToolCall{{
+		ID:        "tc1",
+		Name:      "message",
+		Arguments: map[string]interface{}{"content": "the answer"},
+	}}
+	al.executeToolsConcurrently(context.Background(), toolCalls, 1, opts)
+
+	if gotReplyTo != "77" {
+		t.Fatalf("replyTo = %q, want the inbound message ID 77", gotReplyTo)
+	}
+}
+
 func TestExecuteToolsConcurrently_DoesNotMirrorMessageToolWhenSameSession(t *testing.T) {
 	tmpDir := t.TempDir()
 	registry := tools.NewToolRegistry()
 	msgTool := tools.NewMessageTool()
-	msgTool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	msgTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return nil
 	})
 	registry.Register(msgTool)
@@ -727,7 +761,7 @@ func TestExecuteToolsConcurrently_DoesNotMirrorMessageToolOnSendFailure(t *testi
 	tmpDir := t.TempDir()
 	registry := tools.NewToolRegistry()
 	msgTool := tools.NewMessageTool()
-	msgTool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	msgTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return errors.New("send failed")
 	})
 	registry.Register(msgTool)
@@ -763,7 +797,7 @@ func TestExecuteToolsConcurrently_MirrorsMessageToolSend_UsesAliasArgs(t *testin
 	tmpDir := t.TempDir()
 	registry := tools.NewToolRegistry()
 	msgTool := tools.NewMessageTool()
-	msgTool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	msgTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return nil
 	})
 	registry.Register(msgTool)
@@ -817,3 +851,33 @@ func TestFormatToolCallSummary_RedactsSecrets(t *testing.T) {
 		t.Errorf("formatToolCallSummary() = %q, should contain [REDACTED]", got)
 	}
 }
+
+func TestFormatToolCallSummary_RedactsSensitiveArgKeys(t *testing.T) {
+	tc := providers.ToolCall{
+		Name: "compact",
+		Arguments: map[string]interface{}{
+			"api_key":  "sk-live-abcdef",
+			"password": "hunter2",
+			"target":   "session",
+		},
+	}
+	got := formatToolCallSummary(tc)
+	if strings.Contains(got, "sk-live-abcdef") || strings.Contains(got, "hunter2") {
+		t.Errorf("formatToolCallSummary() = %q, should not contain secret values", got)
+	}
+	if !strings.Contains(got, "api_key=[REDACTED]") || !strings.Contains(got, "target=session") {
+		t.Errorf("formatToolCallSummary() = %q, want redacted key args", got)
+	}
+}
+
+func TestFormatToolCallSummary_SkipsContextArgs(t *testing.T) {
+	tc := providers.ToolCall{
+		Name: "compact",
+		Arguments: map[string]interface{}{
+			"__context_session_key": "telegram:1",
+		},
+	}
+	if got := formatToolCallSummary(tc); got != "compact" {
+		t.Errorf("formatToolCallSummary() = %q, want %q", got, "compact")
+	}
+}
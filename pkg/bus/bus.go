@@ -6,8 +6,13 @@ import (
 	"sync"
 )
 
+// MessageBus routes messages between channels and the agent. Inbound traffic
+// uses two lanes so a flood of user messages cannot starve system/cron
+// deliveries: each lane has its own buffer and drops on overflow, and
+// ConsumeInbound drains the priority lane first.
 type MessageBus struct {
 	inbound   chan InboundMessage
+	priority  chan InboundMessage
 	outbound  chan OutboundMessage
 	handlers  map[string]MessageHandler
 	closed    bool
@@ -19,12 +24,19 @@ type MessageBus struct {
 func NewMessageBus() *MessageBus {
 	return &MessageBus{
 		inbound:  make(chan InboundMessage, 100),
+		priority: make(chan InboundMessage, 100),
 		outbound: make(chan OutboundMessage, 100),
 		handlers: make(map[string]MessageHandler),
 		done:     make(chan struct{}),
 	}
 }
 
+// isPriorityInbound reports whether msg belongs on the high-priority lane:
+// internal system messages (subagent results, etc.) and cron-originated runs.
+func isPriorityInbound(msg InboundMessage) bool {
+	return msg.Channel == "system" || msg.SenderID == "cron" || msg.Metadata["source"] == "cron"
+}
+
 func (mb *MessageBus) PublishInbound(msg InboundMessage) {
 	mb.mu.RLock()
 	defer mb.mu.RUnlock()
@@ -32,10 +44,15 @@ func (mb *MessageBus) PublishInbound(msg InboundMessage) {
 		return
 	}
 
+	lane, name := mb.inbound, "inbound"
+	if isPriorityInbound(msg) {
+		lane, name = mb.priority, "priority inbound"
+	}
+
 	select {
-	case mb.inbound <- msg:
+	case lane <- msg:
 	default:
-		log.Printf("[WARN] bus: inbound channel full, dropping message from %s:%s", msg.Channel, msg.ChatID)
+		log.Printf("[WARN] bus: %s channel full, dropping message from %s:%s", name, msg.Channel, msg.ChatID)
 	}
 }
 
@@ -47,7 +64,17 @@ func (mb *MessageBus) ConsumeInbound(ctx context.Context) (InboundMessage, bool)
 		return InboundMessage{}, false
 	}
 
+	// Prefer the priority lane whenever it has something queued; the blocking
+	// select below only runs when it is empty.
+	select {
+	case msg := <-mb.priority:
+		return msg, true
+	default:
+	}
+
 	select {
+	case msg := <-mb.priority:
+		return msg, true
 	case msg := <-mb.inbound:
 		return msg, true
 	case <-mb.done:
@@ -182,3 +182,49 @@ func TestConcurrentPublishConsume(t *testing.T) {
 		t.Fatalf("expected %d messages, got %d", n, len(received))
 	}
 }
+
+func TestConsumeInbound_PrefersSystemAndCronMessages(t *testing.T) {
+	mb := NewMessageBus()
+	defer mb.Close()
+
+	for _, c := range []string{"user-1", "user-2"} {
+		mb.PublishInbound(InboundMessage{Channel: "telegram", SenderID: "u", Content: c})
+	}
+	mb.PublishInbound(InboundMessage{Channel: "system", SenderID: "subagent:1", Content: "system"})
+	mb.PublishInbound(InboundMessage{Channel: "telegram", SenderID: "cron", Content: "cron"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		msg, ok := mb.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatalf("expected message %d", i)
+		}
+		got = append(got, msg.Content)
+	}
+	want := []string{"system", "cron", "user-1", "user-2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("consume order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPublishInbound_FullUserLaneDoesNotDropPriority(t *testing.T) {
+	mb := NewMessageBus()
+	defer mb.Close()
+
+	for i := 0; i < cap(mb.inbound)+10; i++ {
+		mb.PublishInbound(InboundMessage{Channel: "telegram", Content: "user"})
+	}
+	mb.PublishInbound(InboundMessage{Channel: "telegram", Metadata: map[string]string{"source": "cron"}, Content: "report"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok || msg.Content != "report" {
+		t.Fatalf("expected cron report first despite full user lane, got %+v ok=%v", msg, ok)
+	}
+}
@@ -15,6 +15,14 @@ type OutboundMessage struct {
 	ChatID  string   `json:"chat_id"`
 	Content string   `json:"content"`
 	Media   []string `json:"media,omitempty"`
+	// ReplyTo is the platform ID of the inbound message this answers (its
+	// "message_id" metadata): set on the agent's reply, command replies and
+	// error notices for that message, empty on status and progress lines.
+	ReplyTo string `json:"reply_to,omitempty"`
+	// Final marks a reply written by the agent (message tool), as opposed
+	// to status, progress, tool echoes and error notices sent while a turn
+	// runs. Channels use it for extras such as voice notes.
+	Final bool `json:"final,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
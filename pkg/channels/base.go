@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 type Channel interface {
@@ -19,11 +21,21 @@ type Channel interface {
 }
 
 type BaseChannel struct {
-	config    interface{}
-	bus       *bus.MessageBus
-	running   atomic.Bool
-	name      string
-	allowList []string
+	config      interface{}
+	bus         *bus.MessageBus
+	running     atomic.Bool
+	name        string
+	allowList   []string
+	allowChats  []string
+	requireBoth bool               // allow_mode "all": sender and chat must both pass
+	rateLimiter *senderRateLimiter // nil = unlimited
+	deduper     *messageDeduper    // nil = no duplicate detection
+	// sessionPerSender gives each sender in a group chat their own session
+	// (group_session_scope "sender").
+	sessionPerSender bool
+	// allowMatch, when set, is an extra way for a sender to match an
+	// allow_from entry, e.g. IRC hostmask wildcards.
+	allowMatch func(senderID, allowed string) bool
 }
 
 func NewBaseChannel(name string, config interface{}, bus *bus.MessageBus, allowList []string) *BaseChannel {
@@ -61,18 +73,116 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		if senderID == allowed || baseID == allowed || (username != "" && username == allowed) {
 			return true
 		}
+		if c.allowMatch != nil && c.allowMatch(senderID, allowed) {
+			return true
+		}
 	}
 
 	return false
 }
 
+// SetChatAllowList restricts which chats the channel serves. With mode "any"
+// (the default) a message passes when its sender is in allow_from OR its chat
+// is in allow_chats; with "all" it must pass both configured lists. An empty
+// list is not consulted.
+func (c *BaseChannel) SetChatAllowList(chats []string, mode string) {
+	c.allowChats = chats
+	c.requireBoth = strings.EqualFold(strings.TrimSpace(mode), "all")
+}
+
+// SetGroupSessionScope sets how group chats map to sessions: "sender" keys
+// each sender's messages to their own session within the chat, "chat" (the
+// default) shares one session per chat. Direct messages always use the chat.
+func (c *BaseChannel) SetGroupSessionScope(scope string) {
+	c.sessionPerSender = strings.EqualFold(strings.TrimSpace(scope), "sender")
+}
+
+// sessionKeyFor returns channel:chatID, or channel:chatID:sender for group
+// messages (metadata is_group "true") under sender scope. The sender part
+// prefers the stable user_id over senderID, which may carry a username.
+func (c *BaseChannel) sessionKeyFor(senderID, chatID string, metadata map[string]string) string {
+	sessionKey := fmt.Sprintf("%s:%s", c.name, chatID)
+	if !c.sessionPerSender || metadata["is_group"] != "true" {
+		return sessionKey
+	}
+	sender := metadata["user_id"]
+	if sender == "" {
+		sender = senderID
+	}
+	if sender == "" {
+		return sessionKey
+	}
+	return sessionKey + ":" + sender
+}
+
+// IsAllowedInChat applies both the sender and the chat allowlist.
+func (c *BaseChannel) IsAllowedInChat(senderID, chatID string) bool {
+	if len(c.allowChats) == 0 {
+		return c.IsAllowed(senderID)
+	}
+
+	chatAllowed := false
+	for _, allowed := range c.allowChats {
+		// Slack thread chats ("channel/thread_ts") match their channel.
+		if chatID == allowed || strings.HasPrefix(chatID, allowed+"/") {
+			chatAllowed = true
+			break
+		}
+	}
+
+	if c.requireBoth {
+		return chatAllowed && c.IsAllowed(senderID)
+	}
+	// Without allow_from, IsAllowed admits everyone; only the chat list counts.
+	return chatAllowed || (len(c.allowList) > 0 && c.IsAllowed(senderID))
+}
+
+// SetRateLimit caps inbound messages per sender to perMinute (token bucket
+// with a burst of perMinute). Zero or negative disables limiting.
+func (c *BaseChannel) SetRateLimit(perMinute int) {
+	c.rateLimiter = newSenderRateLimiter(perMinute)
+}
+
+// SetDedupWindow drops inbound messages whose message_id was already seen in
+// the same chat within the last seconds. Zero or negative disables it.
+func (c *BaseChannel) SetDedupWindow(seconds int) {
+	c.deduper = newMessageDeduper(time.Duration(seconds) * time.Second)
+}
+
 func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []string, metadata map[string]string) {
-	if !c.IsAllowed(senderID) {
+	if !c.IsAllowedInChat(senderID, chatID) {
 		return
 	}
 
-	// Build session key: channel:chatID
-	sessionKey := fmt.Sprintf("%s:%s", c.name, chatID)
+	if messageID := metadata["message_id"]; messageID != "" && c.deduper.duplicate(chatID+"\x00"+messageID) {
+		logger.InfoCF("channels", "Dropping duplicate inbound message", map[string]interface{}{
+			"channel":    c.name,
+			"sender_id":  senderID,
+			"chat_id":    chatID,
+			"message_id": messageID,
+		})
+		return
+	}
+
+	if !c.rateLimitExempt(senderID, metadata) {
+		if allowed, notify := c.rateLimiter.allow(senderID); !allowed {
+			logger.WarnCF("channels", "Inbound message rate limited", map[string]interface{}{
+				"channel":   c.name,
+				"sender_id": senderID,
+				"chat_id":   chatID,
+			})
+			if notify {
+				c.bus.PublishOutbound(bus.OutboundMessage{
+					Channel: c.name,
+					ChatID:  chatID,
+					Content: rateLimitReply,
+				})
+			}
+			return
+		}
+	}
+
+	sessionKey := c.sessionKeyFor(senderID, chatID, metadata)
 
 	msg := bus.InboundMessage{
 		Channel:    c.name,
@@ -87,6 +197,12 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 	c.bus.PublishInbound(msg)
 }
 
+// rateLimitExempt reports whether a message bypasses the per-sender limit:
+// internal system traffic and cron-originated messages are never throttled.
+func (c *BaseChannel) rateLimitExempt(senderID string, metadata map[string]string) bool {
+	return c.name == "system" || senderID == "cron" || metadata["source"] == "cron"
+}
+
 func (c *BaseChannel) setRunning(running bool) {
 	c.running.Store(running)
 }
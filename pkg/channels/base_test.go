@@ -91,3 +91,232 @@ func TestBaseChannel_HandleMessageBlockedSenderDoesNotPublish(t *testing.T) {
 		t.Fatal("expected inbound message to be blocked")
 	}
 }
+
+func TestBaseChannel_ChatAllowList(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	bc := NewBaseChannel("telegram", nil, mb, []string{"alice"})
+	bc.SetChatAllowList([]string{"group-1", "C42"}, "any")
+
+	cases := []struct {
+		sender, chat string
+		want         bool
+	}{
+		{"alice", "dm-alice", true},     // sender allowed
+		{"mallory", "group-1", true},    // chat allowed
+		{"mallory", "C42/1700.1", true}, // Slack thread in an allowed channel
+		{"mallory", "group-2", false},   // both denied
+	}
+	for _, tc := range cases {
+		if got := bc.IsAllowedInChat(tc.sender, tc.chat); got != tc.want {
+			t.Errorf("IsAllowedInChat(%q, %q) = %v, want %v", tc.sender, tc.chat, got, tc.want)
+		}
+	}
+
+	bc.HandleMessage("mallory", "group-2", "hello", nil, nil)
+	bc.HandleMessage("mallory", "group-1", "hello", nil, nil)
+	if msgs := drainInbound(mb); len(msgs) != 1 || msgs[0].ChatID != "group-1" {
+		t.Fatalf("expected only the allowed chat's message, got %+v", msgs)
+	}
+}
+
+func TestBaseChannel_ChatAllowListModes(t *testing.T) {
+	bc := NewBaseChannel("telegram", nil, nil, []string{"alice"})
+	bc.SetChatAllowList([]string{"group-1"}, "all")
+	if !bc.IsAllowedInChat("alice", "group-1") {
+		t.Error("all: expected allowed sender in allowed chat to pass")
+	}
+	if bc.IsAllowedInChat("alice", "group-2") || bc.IsAllowedInChat("mallory", "group-1") {
+		t.Error("all: expected either check failing to reject")
+	}
+
+	// Only allow_chats configured: the chat list alone decides.
+	chatsOnly := NewBaseChannel("telegram", nil, nil, nil)
+	chatsOnly.SetChatAllowList([]string{"group-1"}, "")
+	if !chatsOnly.IsAllowedInChat("anyone", "group-1") || chatsOnly.IsAllowedInChat("anyone", "group-2") {
+		t.Error("expected chats-only allowlist to restrict by chat")
+	}
+	chatsOnly.SetChatAllowList([]string{"group-1"}, "all")
+	if !chatsOnly.IsAllowedInChat("anyone", "group-1") {
+		t.Error("all: an empty allow_from should not reject")
+	}
+
+	// Neither configured: everyone passes, as before.
+	open := NewBaseChannel("telegram", nil, nil, nil)
+	if !open.IsAllowedInChat("anyone", "anywhere") {
+		t.Error("expected open channel to allow everyone")
+	}
+}
+
+func drainInbound(mb *bus.MessageBus) []bus.InboundMessage {
+	var msgs []bus.InboundMessage
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		msg, ok := mb.ConsumeInbound(ctx)
+		cancel()
+		if !ok {
+			return msgs
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestBaseChannel_RateLimitThrottlesOnlyNoisySender(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	bc := NewBaseChannel("telegram", nil, mb, nil)
+	bc.SetRateLimit(3)
+	now := time.Unix(1000, 0)
+	bc.rateLimiter.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		bc.HandleMessage("spammer", "chat-1", "spam", nil, nil)
+	}
+	bc.HandleMessage("quiet", "chat-2", "hello", nil, nil)
+
+	var fromSpammer, fromQuiet int
+	for _, msg := range drainInbound(mb) {
+		switch msg.SenderID {
+		case "spammer":
+			fromSpammer++
+		case "quiet":
+			fromQuiet++
+		}
+	}
+	if fromSpammer != 3 {
+		t.Fatalf("spammer delivered %d messages, want 3", fromSpammer)
+	}
+	if fromQuiet != 1 {
+		t.Fatalf("quiet sender delivered %d messages, want 1", fromQuiet)
+	}
+
+	// Exactly one slow-down reply for the throttled burst.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	reply, ok := mb.SubscribeOutbound(ctx)
+	cancel()
+	if !ok || reply.ChatID != "chat-1" || reply.Content != rateLimitReply {
+		t.Fatalf("expected slow-down reply to chat-1, got %+v (ok=%v)", reply, ok)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	if extra, ok := mb.SubscribeOutbound(ctx); ok {
+		t.Fatalf("expected a single slow-down reply, got another: %+v", extra)
+	}
+	cancel()
+
+	// Tokens refill over time: 20s at 3/min restores one message.
+	now = now.Add(20 * time.Second)
+	bc.HandleMessage("spammer", "chat-1", "later", nil, nil)
+	if got := drainInbound(mb); len(got) != 1 || got[0].Content != "later" {
+		t.Fatalf("expected refilled message to pass, got %+v", got)
+	}
+}
+
+func TestBaseChannel_RateLimitExemptions(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	system := NewBaseChannel("system", nil, mb, nil)
+	system.SetRateLimit(1)
+	bc := NewBaseChannel("telegram", nil, mb, nil)
+	bc.SetRateLimit(1)
+
+	for i := 0; i < 3; i++ {
+		system.HandleMessage("subagent", "chat", "report", nil, nil)
+		bc.HandleMessage("cron", "chat", "tick", nil, nil)
+		bc.HandleMessage("user", "chat", "job", nil, map[string]string{"source": "cron"})
+	}
+	if got := len(drainInbound(mb)); got != 9 {
+		t.Fatalf("expected exempt messages to pass, got %d of 9", got)
+	}
+}
+
+func TestBaseChannel_RateLimitDisabledByDefault(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	bc := NewBaseChannel("telegram", nil, mb, nil)
+	bc.SetRateLimit(0)
+	for i := 0; i < 20; i++ {
+		bc.HandleMessage("user", "chat", "msg", nil, nil)
+	}
+	if got := len(drainInbound(mb)); got != 20 {
+		t.Fatalf("expected all messages without a limit, got %d", got)
+	}
+}
+
+func TestBaseChannel_DedupDropsRedeliveredMessage(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	bc := NewBaseChannel("telegram", nil, mb, nil)
+	bc.SetDedupWindow(60)
+	now := time.Unix(1000, 0)
+	bc.deduper.now = func() time.Time { return now }
+
+	meta := map[string]string{"message_id": "42"}
+	bc.HandleMessage("user", "chat-1", "hello", nil, meta)
+	bc.HandleMessage("user", "chat-1", "hello", nil, meta)
+	if got := drainInbound(mb); len(got) != 1 {
+		t.Fatalf("expected a single publish for a redelivered message, got %d", len(got))
+	}
+
+	// The same ID in another chat, messages without an ID, and the same ID
+	// after the window has passed are all delivered.
+	bc.HandleMessage("user", "chat-2", "hello", nil, meta)
+	bc.HandleMessage("user", "chat-1", "no id", nil, nil)
+	bc.HandleMessage("user", "chat-1", "no id", nil, nil)
+	now = now.Add(61 * time.Second)
+	bc.HandleMessage("user", "chat-1", "hello", nil, meta)
+	if got := len(drainInbound(mb)); got != 4 {
+		t.Fatalf("expected 4 messages to pass, got %d", got)
+	}
+}
+
+func TestBaseChannel_DedupDisabled(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	bc := NewBaseChannel("telegram", nil, mb, nil)
+	bc.SetDedupWindow(0)
+	meta := map[string]string{"message_id": "42"}
+	bc.HandleMessage("user", "chat", "hello", nil, meta)
+	bc.HandleMessage("user", "chat", "hello", nil, meta)
+	if got := len(drainInbound(mb)); got != 2 {
+		t.Fatalf("expected duplicates to pass with dedup disabled, got %d", got)
+	}
+}
+
+func TestBaseChannel_GroupSessionScope(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+
+	tests := []struct {
+		scope    string
+		metadata map[string]string
+		want     string
+	}{
+		{"", map[string]string{"is_group": "true"}, "telegram:-100"},
+		{"chat", map[string]string{"is_group": "true"}, "telegram:-100"},
+		{"sender", map[string]string{"is_group": "true"}, "telegram:-100:42|alice"},
+		{"Sender", map[string]string{"is_group": "true", "user_id": "42"}, "telegram:-100:42"},
+		{"sender", map[string]string{"is_group": "false", "user_id": "42"}, "telegram:-100"},
+		{"sender", nil, "telegram:-100"},
+	}
+	for _, tt := range tests {
+		bc := NewBaseChannel("telegram", nil, mb, nil)
+		bc.SetGroupSessionScope(tt.scope)
+		bc.HandleMessage("42|alice", "-100", "hi", nil, tt.metadata)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		msg, ok := mb.ConsumeInbound(ctx)
+		cancel()
+		if !ok {
+			t.Fatal("expected inbound message to be published")
+		}
+		if msg.SessionKey != tt.want {
+			t.Errorf("scope %q, metadata %v: session key = %q, want %q", tt.scope, tt.metadata, msg.SessionKey, tt.want)
+		}
+	}
+}
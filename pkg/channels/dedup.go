@@ -0,0 +1,61 @@
+package channels
+
+import (
+	"sync"
+	"time"
+)
+
+// dedupPruneThreshold bounds the seen-message map; expired entries are
+// dropped once it grows past this size.
+const dedupPruneThreshold = 4096
+
+// messageDeduper remembers recently seen inbound message IDs so a platform
+// redelivering the same update (e.g. Telegram after a reconnect) is only
+// processed once.
+type messageDeduper struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newMessageDeduper(window time.Duration) *messageDeduper {
+	if window <= 0 {
+		return nil
+	}
+	return &messageDeduper{
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// duplicate records key and reports whether it was already seen within the
+// window. A nil deduper or an empty key never reports a duplicate.
+func (d *messageDeduper) duplicate(key string) bool {
+	if d == nil || key == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if seenAt, ok := d.seen[key]; ok && now.Sub(seenAt) < d.window {
+		return true
+	}
+	if len(d.seen) >= dedupPruneThreshold {
+		d.pruneLocked(now)
+	}
+	d.seen[key] = now
+	return false
+}
+
+func (d *messageDeduper) pruneLocked(now time.Time) {
+	for key, seenAt := range d.seen {
+		if now.Sub(seenAt) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+}
@@ -398,6 +398,10 @@ func (c *DeltaChatChannel) markDisconnected(conn *websocket.Conn) {
 
 func NewDeltaChatChannel(cfg config.DeltaChatConfig, bus *bus.MessageBus) (*DeltaChatChannel, error) {
 	base := NewBaseChannel("deltachat", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 	ackReaction := strings.TrimSpace(cfg.AckReaction)
 	doneReaction := strings.TrimSpace(cfg.DoneReaction)
 	errorReaction := strings.TrimSpace(cfg.ErrorReaction)
@@ -1138,6 +1142,9 @@ func (c *DeltaChatChannel) handleIncomingMessage(msg map[string]interface{}) {
 	if userName, ok := msg["from_name"].(string); ok {
 		metadata["user_name"] = userName
 	}
+	if isGroup, ok := msg["is_group"].(bool); ok {
+		metadata["is_group"] = strconv.FormatBool(isGroup)
+	}
 
 	bridgeReceivedMillis, hasBridgeReceived := parseDeltaTimestampMillis(msg["bridge_received_ms"])
 	if hasBridgeReceived {
@@ -37,6 +37,10 @@ func NewDingTalkChannel(cfg config.DingTalkConfig, messageBus *bus.MessageBus) (
 	}
 
 	base := NewBaseChannel("dingtalk", cfg, messageBus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 
 	return &DingTalkChannel{
 		BaseChannel:  base,
@@ -155,6 +159,7 @@ func (c *DingTalkChannel) onChatBotMessageReceived(ctx context.Context, data *ch
 		"sender_name":       senderNick,
 		"conversation_id":   data.ConversationId,
 		"conversation_type": data.ConversationType,
+		"is_group":          fmt.Sprintf("%t", data.ConversationType == "2"),
 		"platform":          "dingtalk",
 		"session_webhook":   data.SessionWebhook,
 	}
@@ -22,7 +22,7 @@ type DiscordChannel struct {
 	*BaseChannel
 	session     *discordgo.Session
 	config      config.DiscordConfig
-	transcriber *voice.GroqTranscriber
+	transcriber voice.Transcriber
 	ctx         context.Context
 }
 
@@ -33,6 +33,10 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 	}
 
 	base := NewBaseChannel("discord", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 
 	return &DiscordChannel{
 		BaseChannel: base,
@@ -43,7 +47,7 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 	}, nil
 }
 
-func (c *DiscordChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *DiscordChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
@@ -140,9 +144,10 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 	}
 
 	// 检查白名单，避免为被拒绝的用户下载附件和转录
-	if !c.IsAllowed(m.Author.ID) {
+	if !c.IsAllowedInChat(m.Author.ID, m.ChannelID) {
 		logger.DebugCF("discord", "Message rejected by allowlist", map[string]any{
 			"user_id": m.Author.ID,
+			"chat_id": m.ChannelID,
 		})
 		return
 	}
@@ -231,6 +236,7 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"guild_id":     m.GuildID,
 		"channel_id":   m.ChannelID,
 		"is_dm":        fmt.Sprintf("%t", m.GuildID == ""),
+		"is_group":     fmt.Sprintf("%t", m.GuildID != ""),
 	}
 
 	c.HandleMessage(senderID, m.ChannelID, content, mediaPaths, metadata)
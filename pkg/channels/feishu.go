@@ -30,6 +30,10 @@ type FeishuChannel struct {
 
 func NewFeishuChannel(cfg config.FeishuConfig, bus *bus.MessageBus) (*FeishuChannel, error) {
 	base := NewBaseChannel("feishu", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 
 	return &FeishuChannel{
 		BaseChannel: base,
@@ -158,6 +162,7 @@ func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2
 	}
 	if chatType := stringValue(message.ChatType); chatType != "" {
 		metadata["chat_type"] = chatType
+		metadata["is_group"] = fmt.Sprintf("%t", chatType == "group")
 	}
 	if sender != nil && sender.TenantKey != nil {
 		metadata["tenant_key"] = *sender.TenantKey
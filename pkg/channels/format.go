@@ -0,0 +1,201 @@
+package channels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Formatter renders the agent's standard Markdown in a channel's own markup.
+// Channels apply one in Send, so the agent never has to know where its answer
+// is going.
+type Formatter interface {
+	Format(markdown string) string
+}
+
+// FormatterFunc adapts a plain function to Formatter.
+type FormatterFunc func(markdown string) string
+
+func (f FormatterFunc) Format(markdown string) string { return f(markdown) }
+
+var (
+	// TelegramHTMLFormatter renders Telegram's HTML parse mode.
+	TelegramHTMLFormatter Formatter = FormatterFunc(markdownToTelegramHTML)
+	// SlackFormatter renders Slack mrkdwn.
+	SlackFormatter Formatter = FormatterFunc(markdownToSlack)
+	// WhatsAppFormatter renders WhatsApp's *bold* / _italic_ / ~strike~ dialect.
+	WhatsAppFormatter Formatter = FormatterFunc(markdownToWhatsApp)
+	// PlainTextFormatter drops markup for outputs that show text as-is, such
+	// as the CLI.
+	PlainTextFormatter Formatter = FormatterFunc(markdownToPlainText)
+	// IRCFormatter renders bold and italics with mIRC control codes.
+	IRCFormatter Formatter = FormatterFunc(markdownToIRC)
+)
+
+// codeSpans is Markdown with fenced code blocks and inline code swapped for
+// placeholders, so a formatter can rewrite the remaining markup without
+// touching code. restore puts the code back in the formatter's own syntax.
+type codeSpans struct {
+	text   string
+	blocks []string
+	inline []string
+}
+
+func splitCodeSpans(text string) codeSpans {
+	blocks := extractCodeBlocks(text)
+	inline := extractInlineCodes(blocks.text)
+	return codeSpans{text: inline.text, blocks: blocks.codes, inline: inline.codes}
+}
+
+func (c codeSpans) restore(text string, renderBlock, renderInline func(code string) string) string {
+	for i, code := range c.inline {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), renderInline(code))
+	}
+	for i, code := range c.blocks {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00CB%d\x00", i), renderBlock(code))
+	}
+	return text
+}
+
+type codeBlockMatch struct {
+	text  string
+	codes []string
+}
+
+func extractCodeBlocks(text string) codeBlockMatch {
+	re := regexp.MustCompile("```[\\w]*\\n?([\\s\\S]*?)```")
+	matches := re.FindAllStringSubmatch(text, -1)
+
+	codes := make([]string, 0, len(matches))
+	for _, match := range matches {
+		codes = append(codes, match[1])
+	}
+
+	idx := 0
+	text = re.ReplaceAllStringFunc(text, func(m string) string {
+		s := fmt.Sprintf("\x00CB%d\x00", idx)
+		idx++
+		return s
+	})
+
+	return codeBlockMatch{text: text, codes: codes}
+}
+
+type inlineCodeMatch struct {
+	text  string
+	codes []string
+}
+
+func extractInlineCodes(text string) inlineCodeMatch {
+	re := regexp.MustCompile("`([^`]+)`")
+	matches := re.FindAllStringSubmatch(text, -1)
+
+	codes := make([]string, 0, len(matches))
+	for _, match := range matches {
+		codes = append(codes, match[1])
+	}
+
+	idx := 0
+	text = re.ReplaceAllStringFunc(text, func(m string) string {
+		s := fmt.Sprintf("\x00IC%d\x00", idx)
+		idx++
+		return s
+	})
+
+	return inlineCodeMatch{text: text, codes: codes}
+}
+
+func escapeHTML(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	text = strings.ReplaceAll(text, ">", "&gt;")
+	return text
+}
+
+var (
+	mdHeadingRe = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdBoldRe    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	mdStarEmRe  = regexp.MustCompile(`\*([^*\n]+)\*`)
+	// Underscore emphasis must not start or end inside a word, so snake_case
+	// identifiers survive.
+	mdUnderEmRe = regexp.MustCompile(`(^|[^\w])_([^_\n]+)_([^\w]|$)`)
+	mdStrikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+	mdQuoteRe   = regexp.MustCompile(`(?m)^>\s?`)
+)
+
+// markdownToWhatsApp converts common Markdown to WhatsApp formatting. Links
+// become "text (url)" since WhatsApp has no link markup; code keeps its
+// backticks, which WhatsApp renders as monospace.
+func markdownToWhatsApp(text string) string {
+	if text == "" {
+		return ""
+	}
+	spans := splitCodeSpans(text)
+	text = spans.text
+
+	text = mdLinkRe.ReplaceAllString(text, "$1 ($2)")
+
+	// Bold is marked with placeholders so the single-asterisk italic pass
+	// does not re-interpret the WhatsApp bold markers.
+	text = mdHeadingRe.ReplaceAllString(text, "\x00B$1\x00B")
+	text = mdBoldRe.ReplaceAllString(text, "\x00B$1$2\x00B")
+	text = mdStarEmRe.ReplaceAllString(text, "_${1}_")
+	text = strings.ReplaceAll(text, "\x00B", "*")
+
+	text = mdStrikeRe.ReplaceAllString(text, "~$1~")
+
+	return spans.restore(text,
+		func(code string) string { return "```" + strings.TrimSuffix(code, "\n") + "```" },
+		func(code string) string { return "`" + code + "`" })
+}
+
+// markdownToPlainText strips Markdown markup, keeping the words, link
+// targets and code contents.
+func markdownToPlainText(text string) string {
+	if text == "" {
+		return ""
+	}
+	spans := splitCodeSpans(text)
+	text = spans.text
+
+	text = mdLinkRe.ReplaceAllString(text, "$1 ($2)")
+	text = mdHeadingRe.ReplaceAllString(text, "$1")
+	text = mdQuoteRe.ReplaceAllString(text, "")
+	text = mdBoldRe.ReplaceAllString(text, "$1$2")
+	text = mdStarEmRe.ReplaceAllString(text, "$1")
+	text = mdUnderEmRe.ReplaceAllString(text, "$1$2$3")
+	text = mdStrikeRe.ReplaceAllString(text, "$1")
+
+	return spans.restore(text,
+		func(code string) string { return strings.TrimSuffix(code, "\n") },
+		func(code string) string { return code })
+}
+
+// mIRC formatting control codes.
+const (
+	ircBold   = "\x02"
+	ircItalic = "\x1d"
+)
+
+// markdownToIRC is markdownToPlainText with bold (and headings) and italics
+// kept as mIRC control codes.
+func markdownToIRC(text string) string {
+	if text == "" {
+		return ""
+	}
+	spans := splitCodeSpans(text)
+	text = spans.text
+
+	text = mdLinkRe.ReplaceAllString(text, "$1 ($2)")
+	text = mdHeadingRe.ReplaceAllString(text, ircBold+"$1"+ircBold)
+	text = mdQuoteRe.ReplaceAllString(text, "")
+	text = mdBoldRe.ReplaceAllString(text, ircBold+"$1$2"+ircBold)
+	text = mdStarEmRe.ReplaceAllString(text, ircItalic+"$1"+ircItalic)
+	text = mdUnderEmRe.ReplaceAllString(text, "$1"+ircItalic+"$2"+ircItalic+"$3")
+	text = mdStrikeRe.ReplaceAllString(text, "$1")
+
+	return spans.restore(text,
+		func(code string) string { return strings.TrimSuffix(code, "\n") },
+		func(code string) string { return code })
+}
@@ -0,0 +1,90 @@
+package channels
+
+import "testing"
+
+func TestFormatters_RenderSameMarkdownPerChannel(t *testing.T) {
+	input := "This is **important** and ~~old~~, see [docs](https://example.com).\nRun `make **all**` then:\n```sh\necho ~~done~~\n```"
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		want      string
+	}{
+		{
+			name:      "telegram",
+			formatter: TelegramHTMLFormatter,
+			want:      "This is <b>important</b> and <s>old</s>, see <a href=\"https://example.com\">docs</a>.\nRun <code>make **all**</code> then:\n<pre><code>echo ~~done~~\n</code></pre>",
+		},
+		{
+			name:      "slack",
+			formatter: SlackFormatter,
+			want:      "This is *important* and ~old~, see <https://example.com|docs>.\nRun `make **all**` then:\n```\necho ~~done~~\n```",
+		},
+		{
+			name:      "whatsapp",
+			formatter: WhatsAppFormatter,
+			want:      "This is *important* and ~old~, see docs (https://example.com).\nRun `make **all**` then:\n```echo ~~done~~```",
+		},
+		{
+			name:      "plain",
+			formatter: PlainTextFormatter,
+			want:      "This is important and old, see docs (https://example.com).\nRun make **all** then:\necho ~~done~~",
+		},
+		{
+			name:      "irc",
+			formatter: IRCFormatter,
+			want:      "This is \x02important\x02 and old, see docs (https://example.com).\nRun make **all** then:\necho ~~done~~",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.formatter.Format(input); got != tt.want {
+				t.Errorf("Format() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToWhatsApp(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bold", input: "**bold** and __also__", want: "*bold* and *also*"},
+		{name: "italic", input: "*soft* and _kept_", want: "_soft_ and _kept_"},
+		{name: "strike", input: "~~gone~~", want: "~gone~"},
+		{name: "no html escaping", input: "a < b & c", want: "a < b & c"},
+		{name: "bullets kept", input: "- one\n- two", want: "- one\n- two"},
+		{name: "empty", input: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToWhatsApp(tt.input); got != tt.want {
+				t.Errorf("markdownToWhatsApp(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToPlainText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "emphasis", input: "**a** *b* __c__ _d_ ~~e~~", want: "a b c d e"},
+		{name: "snake case survives", input: "set max_tool_iterations to 5", want: "set max_tool_iterations to 5"},
+		{name: "quote", input: "> quoted\nreply", want: "quoted\nreply"},
+		{name: "headings", input: "# One\n### Three", want: "One\nThree"},
+		{name: "code kept verbatim", input: "`a_b_c` and ```\n**x**\n```", want: "a_b_c and **x**"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToPlainText(tt.input); got != tt.want {
+				t.Errorf("markdownToPlainText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
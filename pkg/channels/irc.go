@@ -0,0 +1,603 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	ircReconnectBaseDelay = 2 * time.Second
+	ircReconnectMaxDelay  = 5 * time.Minute
+	ircDialTimeout        = 15 * time.Second
+	ircWriteTimeout       = 10 * time.Second
+	// ircReadTimeout drops a connection that stayed silent this long;
+	// servers PING idle clients every few minutes.
+	ircReadTimeout = 5 * time.Minute
+
+	// ircMaxTextBytes is the most message text sent in one PRIVMSG. The
+	// protocol allows 512 bytes per line, including the command and the
+	// sender prefix the server adds when relaying it.
+	ircMaxTextBytes = 400
+	// ircSendBurst lines go out at once before line_delay_ms spacing applies.
+	ircSendBurst = 4
+)
+
+// IRCChannel connects to one IRC server as a plain client: it joins the
+// configured channels and answers private messages and, in channels,
+// messages addressed to its nick.
+type IRCChannel struct {
+	*BaseChannel
+	config    config.IRCConfig
+	formatter Formatter
+	throttle  *ircThrottle
+
+	mu     sync.Mutex
+	conn   net.Conn
+	nick   string // current nick; differs from config after a collision
+	cancel context.CancelFunc
+
+	writeMu sync.Mutex // one line at a time on the wire
+	sendMu  sync.Mutex // lines of one reply stay together
+
+	reconnectBaseDelay time.Duration
+	reconnectMaxDelay  time.Duration
+}
+
+func NewIRCChannel(cfg config.IRCConfig, bus *bus.MessageBus) (*IRCChannel, error) {
+	if strings.TrimSpace(cfg.Server) == "" || strings.TrimSpace(cfg.Nick) == "" {
+		return nil, fmt.Errorf("irc server and nick are required")
+	}
+
+	base := NewBaseChannel("irc", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
+	base.allowMatch = ircSenderMatches
+
+	formatter := PlainTextFormatter
+	if cfg.MIRCFormatting {
+		formatter = IRCFormatter
+	}
+
+	return &IRCChannel{
+		BaseChannel:        base,
+		config:             cfg,
+		formatter:          formatter,
+		throttle:           newIRCThrottle(time.Duration(cfg.LineDelayMS)*time.Millisecond, ircSendBurst),
+		nick:               cfg.Nick,
+		reconnectBaseDelay: ircReconnectBaseDelay,
+		reconnectMaxDelay:  ircReconnectMaxDelay,
+	}, nil
+}
+
+func (c *IRCChannel) Start(ctx context.Context) error {
+	logger.InfoCF("irc", "Starting IRC channel", map[string]interface{}{
+		"server": c.config.Server,
+		"tls":    c.config.TLS,
+		"nick":   c.config.Nick,
+	})
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to IRC server: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+
+	c.mu.Lock()
+	c.conn = conn
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	c.setRunning(true)
+	go c.listen(runCtx)
+
+	return nil
+}
+
+func (c *IRCChannel) Stop(ctx context.Context) error {
+	logger.InfoCF("irc", "Stopping IRC channel", nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Cancel first so the listener doesn't treat the close as a dropped
+	// connection and start redialing.
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+
+	if c.conn != nil {
+		_ = c.writeLine(c.conn, "QUIT :bye")
+		if err := c.conn.Close(); err != nil {
+			logger.ErrorCF("irc", "Error closing IRC connection", map[string]interface{}{"error": err.Error()})
+		}
+		c.conn = nil
+	}
+
+	c.setRunning(false)
+
+	return nil
+}
+
+// Send posts msg as PRIVMSG lines to its chat (a channel, or the nick of a
+// private chat's "nick!user@host"). Media cannot be sent over IRC; their file
+// names are listed instead.
+func (c *IRCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	target := ircNick(strings.TrimSpace(msg.ChatID))
+	if target == "" || strings.ContainsAny(target, " \r\n") {
+		return fmt.Errorf("invalid irc target %q", msg.ChatID)
+	}
+
+	text := c.formatter.Format(msg.Content)
+	for _, path := range msg.Media {
+		text += fmt.Sprintf("\n[file not sent over IRC: %s]", filepath.Base(path))
+	}
+	lines := splitIRCMessage(text, ircMaxTextBytes)
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	for _, line := range lines {
+		if err := c.throttle.wait(ctx); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn == nil {
+			if c.IsRunning() {
+				return fmt.Errorf("irc server disconnected (reconnecting)")
+			}
+			return fmt.Errorf("irc connection not established")
+		}
+
+		if err := c.writeLine(conn, "PRIVMSG "+target+" :"+line); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// connect dials the server and registers with PASS, NICK and USER. Channels
+// are joined once the server welcomes us (001).
+func (c *IRCChannel) connect(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ircDialTimeout}
+	var conn net.Conn
+	var err error
+	if c.config.TLS {
+		host, _, splitErr := net.SplitHostPort(c.config.Server)
+		if splitErr != nil {
+			host = c.config.Server
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", c.config.Server)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.config.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.nick = c.config.Nick
+	c.mu.Unlock()
+
+	var lines []string
+	if c.config.Password != "" {
+		lines = append(lines, "PASS "+c.config.Password)
+	}
+	lines = append(lines, "NICK "+c.config.Nick, "USER "+c.config.Nick+" 0 * :picoclaw")
+	for _, line := range lines {
+		if err := c.writeLine(conn, line); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *IRCChannel) listen(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+
+		if conn == nil {
+			if !c.reconnect(ctx) {
+				return
+			}
+			continue
+		}
+
+		err := c.readLoop(conn)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("irc", "IRC connection lost", map[string]interface{}{"error": err.Error()})
+		c.dropConn(conn)
+		if !c.reconnect(ctx) {
+			return
+		}
+	}
+}
+
+// readLoop handles lines from conn until it fails or goes silent for
+// ircReadTimeout.
+func (c *IRCChannel) readLoop(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(ircReadTimeout))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		c.handleLine(conn, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// dropConn closes conn and forgets it, unless it has already been replaced.
+func (c *IRCChannel) dropConn(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return
+	}
+	_ = conn.Close()
+	c.conn = nil
+}
+
+// reconnect redials the server with capped exponential backoff until it
+// succeeds or ctx is cancelled. It reports whether a connection was made.
+func (c *IRCChannel) reconnect(ctx context.Context) bool {
+	delay := c.reconnectBaseDelay
+	for attempt := 1; ; attempt++ {
+		logger.InfoCF("irc", "Reconnecting to IRC server", map[string]interface{}{
+			"attempt":  attempt,
+			"delay_ms": delay.Milliseconds(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		conn, err := c.connect(ctx)
+		if err != nil {
+			logger.WarnCF("irc", "IRC reconnect failed", map[string]interface{}{
+				"attempt": attempt,
+				"error":   err.Error(),
+			})
+			delay *= 2
+			if delay > c.reconnectMaxDelay {
+				delay = c.reconnectMaxDelay
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		if ctx.Err() != nil {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return false
+		}
+		c.conn = conn
+		c.mu.Unlock()
+
+		logger.InfoCF("irc", "IRC server reconnected", map[string]interface{}{"attempt": attempt})
+		return true
+	}
+}
+
+func (c *IRCChannel) writeLine(conn net.Conn, line string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(ircWriteTimeout))
+	_, err := conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+func (c *IRCChannel) currentNick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nick
+}
+
+func (c *IRCChannel) handleLine(conn net.Conn, line string) {
+	msg, ok := parseIRCLine(line)
+	if !ok {
+		return
+	}
+
+	switch msg.command {
+	case "PING":
+		_ = c.writeLine(conn, "PONG :"+msg.param(0))
+	case "001":
+		c.mu.Lock()
+		if nick := msg.param(0); nick != "" {
+			c.nick = nick
+		}
+		c.mu.Unlock()
+		for _, channel := range c.config.Channels {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				_ = c.writeLine(conn, "JOIN "+channel)
+			}
+		}
+		logger.InfoCF("irc", "IRC channel connected", map[string]interface{}{
+			"nick":     c.currentNick(),
+			"channels": c.config.Channels,
+		})
+	case "433": // ERR_NICKNAMEINUSE
+		c.mu.Lock()
+		c.nick += "_"
+		nick := c.nick
+		c.mu.Unlock()
+		logger.WarnCF("irc", "IRC nick in use, trying another", map[string]interface{}{"nick": nick})
+		_ = c.writeLine(conn, "NICK "+nick)
+	case "NICK":
+		c.mu.Lock()
+		if strings.EqualFold(ircNick(msg.prefix), c.nick) {
+			c.nick = msg.param(0)
+		}
+		c.mu.Unlock()
+	case "ERROR":
+		logger.WarnCF("irc", "IRC server error", map[string]interface{}{"message": msg.param(0)})
+	case "PRIVMSG":
+		c.handlePrivmsg(msg.prefix, msg.param(0), msg.param(1))
+	}
+}
+
+func (c *IRCChannel) handlePrivmsg(prefix, target, text string) {
+	nick := ircNick(prefix)
+	ownNick := c.currentNick()
+	if nick == "" || strings.EqualFold(nick, ownNick) || target == "" {
+		return
+	}
+	// CTCP requests (VERSION, ACTION, ...) are not chat messages.
+	if strings.HasPrefix(text, "\x01") {
+		return
+	}
+
+	content := stripIRCFormatting(text)
+	// Private chats are keyed by the full hostmask, not the nick: anyone can
+	// take a nick once its owner leaves, and must not inherit their session.
+	chatID := prefix
+	if isIRCChannelName(target) {
+		chatID = target
+		if c.config.RequireMention {
+			var addressed bool
+			if content, addressed = stripIRCMention(content, ownNick); !addressed {
+				return
+			}
+		}
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+
+	metadata := map[string]string{
+		"user_name": nick,
+		"hostmask":  prefix,
+		"is_group":  fmt.Sprintf("%t", isIRCChannelName(target)),
+		"platform":  "irc",
+	}
+
+	logger.DebugCF("irc", "Received message", map[string]interface{}{
+		"sender":  prefix,
+		"chat_id": chatID,
+		"preview": utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(prefix, chatID, content, nil, metadata)
+}
+
+// ircMessage is one parsed protocol line.
+type ircMessage struct {
+	prefix  string
+	command string
+	params  []string
+}
+
+func (m ircMessage) param(i int) string {
+	if i < len(m.params) {
+		return m.params[i]
+	}
+	return ""
+}
+
+// parseIRCLine splits "[@tags] [:prefix] COMMAND params [:trailing]".
+// Message tags are ignored.
+func parseIRCLine(line string) (ircMessage, bool) {
+	var msg ircMessage
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		msg.prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+	line = strings.TrimLeft(line, " ")
+
+	trailing, hasTrailing := "", false
+	if i := strings.Index(line, " :"); i >= 0 {
+		trailing, hasTrailing = line[i+2:], true
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return msg, false
+	}
+	msg.command = strings.ToUpper(fields[0])
+	msg.params = fields[1:]
+	if hasTrailing {
+		msg.params = append(msg.params, trailing)
+	}
+	return msg, true
+}
+
+// ircNick returns the nick of a "nick!user@host" prefix.
+func ircNick(prefix string) string {
+	nick, _, _ := strings.Cut(prefix, "!")
+	return nick
+}
+
+func isIRCChannelName(target string) bool {
+	return target != "" && strings.ContainsRune("#&+!", rune(target[0]))
+}
+
+// stripIRCMention removes a leading "nick:", "nick," or "@nick" address
+// and reports whether the message was addressed to nick.
+func stripIRCMention(text, nick string) (string, bool) {
+	rest := strings.TrimPrefix(strings.TrimSpace(text), "@")
+	if nick == "" || len(rest) < len(nick) || !strings.EqualFold(rest[:len(nick)], nick) {
+		return text, false
+	}
+	rest = rest[len(nick):]
+	if rest == "" {
+		return "", true
+	}
+	switch rest[0] {
+	case ':', ',', ' ':
+		return strings.TrimSpace(rest[1:]), true
+	}
+	return text, false
+}
+
+// ircSenderMatches lets an allow_from entry match a "nick!user@host" sender
+// by bare nick (case-insensitive) or by hostmask with * and ? wildcards,
+// e.g. "*!*@example.org".
+func ircSenderMatches(sender, allowed string) bool {
+	allowed = strings.TrimSpace(allowed)
+	if allowed == "" {
+		return false
+	}
+	if !strings.ContainsAny(allowed, "!@*?") {
+		return strings.EqualFold(ircNick(sender), allowed)
+	}
+	pattern := regexp.QuoteMeta(allowed)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	matched, err := regexp.MatchString("(?i)^"+pattern+"$", sender)
+	return err == nil && matched
+}
+
+var ircColorRe = regexp.MustCompile(`\x03(\d{1,2}(,\d{1,2})?)?`)
+
+// stripIRCFormatting removes mIRC color and style codes from inbound text.
+func stripIRCFormatting(text string) string {
+	text = ircColorRe.ReplaceAllString(text, "")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case 0x02, 0x0f, 0x11, 0x16, 0x1d, 0x1e, 0x1f:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// splitIRCMessage breaks text into PRIVMSG-sized lines: one per text line,
+// with long lines wrapped at a space where possible and blank lines
+// dropped. Cuts never split a UTF-8 sequence.
+func splitIRCMessage(text string, maxBytes int) []string {
+	var out []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		line = strings.TrimRight(line, " \t")
+		for len(line) > maxBytes {
+			cut := maxBytes
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if space := strings.LastIndexByte(line[:cut], ' '); space > maxBytes/2 {
+				cut = space
+			}
+			if cut == 0 {
+				cut = maxBytes
+			}
+			out = append(out, line[:cut])
+			line = strings.TrimLeft(line[cut:], " ")
+		}
+		if strings.TrimSpace(line) != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// ircThrottle lets a burst of lines out at once, then one per interval, so
+// long replies don't get the bot kicked for flooding.
+type ircThrottle struct {
+	interval time.Duration
+	burst    float64
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newIRCThrottle(interval time.Duration, burst int) *ircThrottle {
+	return &ircThrottle{interval: interval, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// reserve takes a slot and returns how long to wait before using it.
+func (t *ircThrottle) reserve() time.Duration {
+	if t.interval <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if !t.last.IsZero() {
+		t.tokens += float64(now.Sub(t.last)) / float64(t.interval)
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+	}
+	t.last = now
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens * float64(t.interval))
+}
+
+func (t *ircThrottle) wait(ctx context.Context) error {
+	delay := t.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,271 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeIRCServer accepts one client and exposes its lines.
+type fakeIRCServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conn  net.Conn
+	lines chan string
+}
+
+func newFakeIRCServer(t *testing.T) *fakeIRCServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &fakeIRCServer{t: t, ln: ln, lines: make(chan string, 100)}
+}
+
+func (s *fakeIRCServer) accept() {
+	s.t.Helper()
+	conn, err := s.ln.Accept()
+	if err != nil {
+		s.t.Fatalf("accept: %v", err)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+	s.conn = conn
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			s.lines <- strings.TrimRight(scanner.Text(), "\r")
+		}
+		close(s.lines)
+	}()
+}
+
+func (s *fakeIRCServer) send(line string) {
+	s.t.Helper()
+	if _, err := s.conn.Write([]byte(line + "\r\n")); err != nil {
+		s.t.Fatalf("write: %v", err)
+	}
+}
+
+// expect waits for a line with the given prefix, skipping others.
+func (s *fakeIRCServer) expect(prefix string) string {
+	s.t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				s.t.Fatalf("connection closed while waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			s.t.Fatalf("timed out waiting for %q", prefix)
+		}
+	}
+}
+
+func newTestIRCChannel(t *testing.T, cfg config.IRCConfig) (*IRCChannel, *bus.MessageBus, *fakeIRCServer) {
+	t.Helper()
+	server := newFakeIRCServer(t)
+	cfg.Enabled = true
+	cfg.Server = server.ln.Addr().String()
+	if cfg.Nick == "" {
+		cfg.Nick = "picoclaw"
+	}
+	msgBus := bus.NewMessageBus()
+	ch, err := NewIRCChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("NewIRCChannel: %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+	server.accept()
+	server.expect("NICK picoclaw")
+	server.expect("USER picoclaw")
+	return ch, msgBus, server
+}
+
+func consumeInbound(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	return msg
+}
+
+func TestIRCChannel_JoinsAndMapsPrivmsg(t *testing.T) {
+	_, msgBus, server := newTestIRCChannel(t, config.IRCConfig{
+		Channels:       []string{"#picoclaw"},
+		RequireMention: true,
+	})
+
+	server.send(":irc.test 001 picoclaw :Welcome")
+	server.expect("JOIN #picoclaw")
+
+	server.send("PING :irc.test")
+	server.expect("PONG :irc.test")
+
+	// Unaddressed channel chatter is ignored; addressed lines are delivered.
+	server.send(":alice!alice@example.org PRIVMSG #picoclaw :just chatting")
+	server.send(":alice!alice@example.org PRIVMSG #picoclaw :picoclaw: \x02what\x02 time is it?")
+	msg := consumeInbound(t, msgBus)
+	if msg.Channel != "irc" || msg.ChatID != "#picoclaw" || msg.SenderID != "alice!alice@example.org" {
+		t.Fatalf("unexpected routing: %+v", msg)
+	}
+	if msg.Content != "what time is it?" {
+		t.Fatalf("Content = %q, want mention and formatting stripped", msg.Content)
+	}
+	if msg.Metadata["user_name"] != "alice" {
+		t.Fatalf("user_name = %q, want alice", msg.Metadata["user_name"])
+	}
+
+	// Private messages need no mention and are keyed by the sender's hostmask.
+	server.send(":bob!b@host PRIVMSG picoclaw :hello there")
+	msg = consumeInbound(t, msgBus)
+	if msg.ChatID != "bob!b@host" || msg.Content != "hello there" {
+		t.Fatalf("unexpected private message: %+v", msg)
+	}
+
+	// The same nick from another host is a different chat.
+	server.send(":bob!b@elsewhere PRIVMSG picoclaw :it's me")
+	msg = consumeInbound(t, msgBus)
+	if msg.ChatID != "bob!b@elsewhere" {
+		t.Fatalf("ChatID = %q, want the spoofed nick kept apart", msg.ChatID)
+	}
+}
+
+func TestIRCChannel_AllowFromByNickAndHostmask(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewIRCChannel(config.IRCConfig{
+		Server:    "irc.test:6667",
+		Nick:      "picoclaw",
+		AllowFrom: []string{"Alice", "*!*@trusted.example.org"},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewIRCChannel: %v", err)
+	}
+
+	tests := []struct {
+		sender string
+		want   bool
+	}{
+		{"alice!a@anywhere.net", true},
+		{"bob!b@trusted.example.org", true},
+		{"bob!b@TRUSTED.example.org", true},
+		{"bob!b@untrusted.example.org", false},
+		{"alicex!a@anywhere.net", false},
+	}
+	for _, tt := range tests {
+		if got := ch.IsAllowed(tt.sender); got != tt.want {
+			t.Errorf("IsAllowed(%q) = %v, want %v", tt.sender, got, tt.want)
+		}
+	}
+}
+
+func TestIRCChannel_SendToPrivateChatTargetsNick(t *testing.T) {
+	ch, _, server := newTestIRCChannel(t, config.IRCConfig{})
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		Channel: "irc",
+		ChatID:  "bob!b@host",
+		Content: "hi bob",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if line := server.expect("PRIVMSG "); line != "PRIVMSG bob :hi bob" {
+		t.Fatalf("line = %q, want the reply sent to the nick", line)
+	}
+}
+
+func TestIRCChannel_SendSplitsLongReplies(t *testing.T) {
+	ch, _, server := newTestIRCChannel(t, config.IRCConfig{})
+
+	long := strings.Repeat("word ", 200)
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		Channel: "irc",
+		ChatID:  "#picoclaw",
+		Content: "**Summary**\n\n" + long,
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	first := server.expect("PRIVMSG #picoclaw :")
+	if first != "PRIVMSG #picoclaw :Summary" {
+		t.Fatalf("first line = %q, want markdown stripped", first)
+	}
+	total := 0
+	for total < len(strings.TrimSpace(long)) {
+		line := strings.TrimPrefix(server.expect("PRIVMSG #picoclaw :"), "PRIVMSG #picoclaw :")
+		if len(line) > ircMaxTextBytes {
+			t.Fatalf("line is %d bytes, over the %d limit", len(line), ircMaxTextBytes)
+		}
+		total += len(line) + 1
+	}
+}
+
+func TestSplitIRCMessage(t *testing.T) {
+	text := "first\n\n" + strings.Repeat("é", 300) + "\nlast line"
+	lines := splitIRCMessage(text, 100)
+	if lines[0] != "first" || lines[len(lines)-1] != "last line" {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	for _, line := range lines {
+		if len(line) > 100 || line == "" || !utf8.ValidString(line) {
+			t.Fatalf("bad line %q", line)
+		}
+	}
+	if got := strings.Join(lines[1:len(lines)-1], ""); got != strings.Repeat("é", 300) {
+		t.Fatal("wrapped text lost or changed characters")
+	}
+}
+
+func TestIRCThrottle_BurstThenInterval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := newIRCThrottle(time.Second, 2)
+	th.now = func() time.Time { return now }
+
+	waits := []time.Duration{th.reserve(), th.reserve(), th.reserve(), th.reserve()}
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Fatalf("waits = %v, want %v", waits, want)
+		}
+	}
+
+	// After a quiet spell the burst is available again.
+	now = now.Add(10 * time.Second)
+	if got := th.reserve(); got != 0 {
+		t.Fatalf("wait after idle = %v, want 0", got)
+	}
+
+	if got := newIRCThrottle(0, 2).reserve(); got != 0 {
+		t.Fatalf("disabled throttle waited %v", got)
+	}
+}
+
+func TestParseIRCLine(t *testing.T) {
+	msg, ok := parseIRCLine("@time=2026-01-01T00:00:00Z :nick!u@h PRIVMSG #chan :hello :world")
+	if !ok || msg.prefix != "nick!u@h" || msg.command != "PRIVMSG" || msg.param(0) != "#chan" || msg.param(1) != "hello :world" {
+		t.Fatalf("unexpected parse: %+v", msg)
+	}
+	if _, ok := parseIRCLine(""); ok {
+		t.Fatal("expected empty line to be rejected")
+	}
+}
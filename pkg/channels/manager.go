@@ -150,6 +150,19 @@ func (m *Manager) initChannels() error {
 		}
 	}
 
+	if m.config.Channels.IRC.Enabled && m.config.Channels.IRC.Server != "" {
+		logger.DebugC("channels", "Attempting to initialize IRC channel")
+		irc, err := NewIRCChannel(m.config.Channels.IRC, m.bus)
+		if err != nil {
+			logger.ErrorCF("channels", "Failed to initialize IRC channel", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else {
+			m.channels["irc"] = irc
+			logger.InfoC("channels", "IRC channel enabled successfully")
+		}
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]interface{}{
 		"enabled_channels": len(m.channels),
 	})
@@ -0,0 +1,95 @@
+package channels
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimitReply is sent once when a sender starts being throttled.
+const rateLimitReply = "You're sending messages too quickly. Please slow down and try again in a moment."
+
+// rateLimiterPruneThreshold bounds the per-sender bucket map; idle (full)
+// buckets are dropped once it grows past this size.
+const rateLimiterPruneThreshold = 4096
+
+// senderRateLimiter is a per-sender token bucket: each sender may burst up to
+// perMinute messages, refilled continuously at perMinute per minute.
+type senderRateLimiter struct {
+	perMinute float64
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	tokens    float64
+	updated   time.Time
+	throttled bool // a slow-down reply was sent for the current burst
+}
+
+func newSenderRateLimiter(perMinute int) *senderRateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &senderRateLimiter{
+		perMinute: float64(perMinute),
+		now:       time.Now,
+		buckets:   make(map[string]*rateBucket),
+	}
+}
+
+// allow consumes a token for senderID. When the message is rejected, notify
+// reports whether this is the first rejection since the sender was last
+// allowed, so the caller replies once instead of once per dropped message.
+func (l *senderRateLimiter) allow(senderID string) (allowed bool, notify bool) {
+	if l == nil {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[senderID]
+	if !ok {
+		if len(l.buckets) >= rateLimiterPruneThreshold {
+			l.pruneLocked(now)
+		}
+		b = &rateBucket{tokens: l.perMinute, updated: now}
+		l.buckets[senderID] = b
+	} else {
+		l.refill(b, now)
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.throttled = false
+		return true, false
+	}
+
+	notify = !b.throttled
+	b.throttled = true
+	return false, notify
+}
+
+func (l *senderRateLimiter) refill(b *rateBucket, now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed.Minutes() * l.perMinute
+	if b.tokens > l.perMinute {
+		b.tokens = l.perMinute
+	}
+	b.updated = now
+}
+
+func (l *senderRateLimiter) pruneLocked(now time.Time) {
+	for id, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.perMinute {
+			delete(l.buckets, id)
+		}
+	}
+}
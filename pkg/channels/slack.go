@@ -3,6 +3,9 @@ package channels
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -24,7 +27,7 @@ type SlackChannel struct {
 	api          *slack.Client
 	socketClient *socketmode.Client
 	botUserID    string
-	transcriber  *voice.GroqTranscriber
+	transcriber  voice.Transcriber
 	ctx          context.Context
 	cancel       context.CancelFunc
 	pendingAcks  sync.Map
@@ -48,6 +51,10 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 	socketClient := socketmode.New(api)
 
 	base := NewBaseChannel("slack", cfg, messageBus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 
 	return &SlackChannel{
 		BaseChannel:  base,
@@ -57,7 +64,7 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 	}, nil
 }
 
-func (c *SlackChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *SlackChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
@@ -116,17 +123,28 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
 	}
 
-	opts := []slack.MsgOption{
-		slack.MsgOptionText(msg.Content, false),
-	}
+	if msg.Content != "" || len(msg.Media) == 0 {
+		opts := []slack.MsgOption{
+			slack.MsgOptionText(SlackFormatter.Format(msg.Content), false),
+		}
 
-	if threadTS != "" {
-		opts = append(opts, slack.MsgOptionTS(threadTS))
+		if threadTS != "" {
+			opts = append(opts, slack.MsgOptionTS(threadTS))
+		}
+
+		_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to send slack message: %w", err)
+		}
 	}
 
-	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
-	if err != nil {
-		return fmt.Errorf("failed to send slack message: %w", err)
+	// Upload every file even if one fails, then report the first failure
+	// so the caller learns the attachment never arrived.
+	var uploadErr error
+	for _, mediaPath := range msg.Media {
+		if err := c.uploadSlackFile(ctx, channelID, threadTS, mediaPath); err != nil && uploadErr == nil {
+			uploadErr = err
+		}
 	}
 
 	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
@@ -137,6 +155,10 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		})
 	}
 
+	if uploadErr != nil {
+		return uploadErr
+	}
+
 	logger.DebugCF("slack", "Message sent", map[string]interface{}{
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
@@ -198,9 +220,10 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 	}
 
 	// 检查白名单，避免为被拒绝的用户下载附件
-	if !c.IsAllowed(ev.User) {
+	if !c.IsAllowedInChat(ev.User, ev.Channel) {
 		logger.DebugCF("slack", "Message rejected by allowlist", map[string]interface{}{
 			"user_id": ev.User,
+			"chat_id": ev.Channel,
 		})
 		return
 	}
@@ -274,6 +297,7 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
 		"platform":   "slack",
+		"is_group":   fmt.Sprintf("%t", !isSlackDM(channelID)),
 	}
 
 	logger.DebugCF("slack", "Received message", map[string]interface{}{
@@ -325,6 +349,7 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		"thread_ts":  threadTS,
 		"platform":   "slack",
 		"is_mention": "true",
+		"is_group":   fmt.Sprintf("%t", !isSlackDM(channelID)),
 	}
 
 	c.HandleMessage(senderID, chatID, content, nil, metadata)
@@ -353,6 +378,7 @@ func (c *SlackChannel) handleSlashCommand(event socketmode.Event) {
 		"channel_id": channelID,
 		"platform":   "slack",
 		"is_command": "true",
+		"is_group":   fmt.Sprintf("%t", !isSlackDM(channelID)),
 		"trigger_id": cmd.TriggerID,
 	}
 
@@ -383,6 +409,33 @@ func (c *SlackChannel) downloadSlackFile(file slack.File) string {
 	})
 }
 
+func (c *SlackChannel) uploadSlackFile(ctx context.Context, channelID, threadTS, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.ErrorCF("slack", "Failed to open media file", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return fmt.Errorf("failed to open slack media file %s: %w", filepath.Base(path), err)
+	}
+
+	_, err = c.api.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		File:            path,
+		FileSize:        int(info.Size()),
+		Filename:        filepath.Base(path),
+		Channel:         channelID,
+		ThreadTimestamp: threadTS,
+	})
+	if err != nil {
+		logger.ErrorCF("slack", "Failed to upload file", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return fmt.Errorf("failed to upload slack file %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
 func (c *SlackChannel) stripBotMention(text string) string {
 	mention := fmt.Sprintf("<@%s>", c.botUserID)
 	text = strings.ReplaceAll(text, mention, "")
@@ -397,3 +450,45 @@ func parseSlackChatID(chatID string) (channelID, threadTS string) {
 	}
 	return
 }
+
+var (
+	slackHeadingRe = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	slackLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	slackBoldRe    = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	slackItalicRe  = regexp.MustCompile(`\*([^*\n]+)\*`)
+	slackStrikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+	slackBulletRe  = regexp.MustCompile(`(?m)^(\s*)[-*]\s+`)
+)
+
+// markdownToSlack converts common Markdown to Slack mrkdwn. Code spans are
+// left verbatim (Slack uses the same backtick syntax) but HTML-escaped.
+func markdownToSlack(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	spans := splitCodeSpans(text)
+	text = escapeHTML(spans.text)
+
+	text = slackLinkRe.ReplaceAllString(text, "<$2|$1>")
+	text = slackBulletRe.ReplaceAllString(text, "$1• ")
+
+	// Bold is marked with placeholders so the single-asterisk italic pass
+	// does not re-interpret the Slack bold markers.
+	text = slackHeadingRe.ReplaceAllString(text, "\x00B$1\x00B")
+	text = slackBoldRe.ReplaceAllString(text, "\x00B$1$2\x00B")
+	text = slackItalicRe.ReplaceAllString(text, "_${1}_")
+	text = strings.ReplaceAll(text, "\x00B", "*")
+
+	text = slackStrikeRe.ReplaceAllString(text, "~$1~")
+
+	return spans.restore(text,
+		func(code string) string { return "```\n" + escapeHTML(code) + "```" },
+		func(code string) string { return "`" + escapeHTML(code) + "`" })
+}
+
+// isSlackDM reports whether channelID is a direct message; Slack DM
+// conversation IDs start with "D".
+func isSlackDM(channelID string) bool {
+	return strings.HasPrefix(channelID, "D")
+}
@@ -1,8 +1,16 @@
 package channels
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/slack-go/slack"
+
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -172,3 +180,72 @@ func TestSlackChannelIsAllowed(t *testing.T) {
 		}
 	})
 }
+
+func TestMarkdownToSlack(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bold", input: "this is **bold**", want: "this is *bold*"},
+		{name: "italic", input: "this is *italic*", want: "this is _italic_"},
+		{name: "strike", input: "~~gone~~", want: "~gone~"},
+		{name: "link", input: "see [docs](https://example.com)", want: "see <https://example.com|docs>"},
+		{name: "heading", input: "## Title\nbody", want: "*Title*\nbody"},
+		{name: "bullets", input: "- one\n- two", want: "• one\n• two"},
+		{name: "escapes", input: "a < b & c", want: "a &lt; b &amp; c"},
+		{name: "inline code untouched", input: "run `**x**`", want: "run `**x**`"},
+		{name: "code block", input: "```go\nfmt.Println(\"*hi*\")\n```", want: "```\nfmt.Println(\"*hi*\")\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToSlack(tt.input); got != tt.want {
+				t.Errorf("markdownToSlack(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackSend_ReportsFailedFileUpload(t *testing.T) {
+	var posted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			posted = true
+			w.Write([]byte(`{"ok":true,"channel":"C123","ts":"1700000000.000100"}`))
+		case "/files.getUploadURLExternal":
+			w.Write([]byte(`{"ok":false,"error":"not_allowed_token_type"}`))
+		default:
+			t.Errorf("unexpected Slack API call %s", r.URL.Path)
+			w.Write([]byte(`{"ok":false,"error":"unknown_method"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ch, err := NewSlackChannel(config.SlackConfig{BotToken: "xoxb-test", AppToken: "xapp-test"}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewSlackChannel: %v", err)
+	}
+	ch.api = slack.New("xoxb-test", slack.OptionAPIURL(srv.URL+"/"))
+	ch.setRunning(true)
+
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+
+	err = ch.Send(context.Background(), bus.OutboundMessage{
+		Channel: "slack",
+		ChatID:  "C123",
+		Content: "here is the report",
+		Media:   []string{path},
+	})
+	if !posted {
+		t.Fatal("expected the text to be posted before the upload")
+	}
+	if err == nil || !strings.Contains(err.Error(), "report.pdf") || !strings.Contains(err.Error(), "not_allowed_token_type") {
+		t.Fatalf("Send() error = %v, want the failed upload reported", err)
+	}
+}
@@ -2,12 +2,15 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -26,6 +29,10 @@ const (
 	// Use a small safety margin to reduce off-by-one and formatting overhead issues.
 	telegramMaxMessageChars = 4096
 	telegramChunkChars      = 4000
+	// Longer replies are sent as text only; TTS APIs cap input around 4096 chars.
+	telegramVoiceMaxChars = 4000
+	// Telegram limit for photo and document captions.
+	telegramMaxCaptionChars = 1024
 )
 
 // telegramBot abstracts the telego.Bot methods used by TelegramChannel,
@@ -36,11 +43,15 @@ type telegramBot interface {
 	UpdatesViaLongPolling(ctx context.Context, params *telego.GetUpdatesParams, options ...telego.LongPollingOption) (<-chan telego.Update, error)
 	SendMessage(ctx context.Context, params *telego.SendMessageParams) (*telego.Message, error)
 	SendChatAction(ctx context.Context, params *telego.SendChatActionParams) error
+	SetMessageReaction(ctx context.Context, params *telego.SetMessageReactionParams) error
 	SendPhoto(ctx context.Context, params *telego.SendPhotoParams) (*telego.Message, error)
 	SendDocument(ctx context.Context, params *telego.SendDocumentParams) (*telego.Message, error)
+	SendVoice(ctx context.Context, params *telego.SendVoiceParams) (*telego.Message, error)
 	EditMessageText(ctx context.Context, params *telego.EditMessageTextParams) (*telego.Message, error)
 	DeleteMessage(ctx context.Context, params *telego.DeleteMessageParams) error
 	GetFile(ctx context.Context, params *telego.GetFileParams) (*telego.File, error)
+	SetWebhook(ctx context.Context, params *telego.SetWebhookParams) error
+	DeleteWebhook(ctx context.Context, params *telego.DeleteWebhookParams) error
 }
 
 type TelegramChannel struct {
@@ -48,12 +59,24 @@ type TelegramChannel struct {
 	bot          telegramBot
 	config       config.TelegramConfig
 	chatIDs      map[string]int64
-	transcriber  *voice.GroqTranscriber
+	transcriber  voice.Transcriber
+	synthesizer  voice.Synthesizer
 	stopThinking sync.Map // chatID -> thinkingCancel
+	voiceChats   sync.Map // chatID -> struct{}; last inbound message was a voice note
+	reactions    sync.Map // "chatID:messageID" -> message ID awaiting the done reaction
+	replyTargets sync.Map // "chatID:messageID" -> message ID whose answer is threaded (thread_replies)
+
+	// voiceReplies tracks voice notes still being synthesized and sent.
+	voiceReplies sync.WaitGroup
 
 	// typingInterval controls how often the typing indicator is refreshed.
 	// Telegram's typing indicator expires after ~5s, so default is 4s.
 	typingInterval time.Duration
+
+	// Webhook mode (see telegram_webhook.go).
+	webhookSecret string
+	webhookCtx    atomic.Value // context.Context passed to Start
+	webhookServer *http.Server // own listener when webhook_listen is set
 }
 
 type thinkingCancel struct {
@@ -73,6 +96,17 @@ func NewTelegramChannel(cfg config.TelegramConfig, bus *bus.MessageBus) (*Telegr
 	}
 
 	base := NewBaseChannel("telegram", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
+
+	secret := cfg.WebhookSecret
+	if secret == "" && strings.EqualFold(strings.TrimSpace(cfg.Mode), TelegramModeWebhook) {
+		if secret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
 
 	return &TelegramChannel{
 		BaseChannel:    base,
@@ -82,16 +116,33 @@ func NewTelegramChannel(cfg config.TelegramConfig, bus *bus.MessageBus) (*Telegr
 		transcriber:    nil,
 		stopThinking:   sync.Map{},
 		typingInterval: 4 * time.Second,
+		webhookSecret:  secret,
 	}, nil
 }
 
-func (c *TelegramChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
+func (c *TelegramChannel) SetTranscriber(transcriber voice.Transcriber) {
 	c.transcriber = transcriber
 }
 
+// SetSynthesizer enables voice replies. Without one, replies are text only.
+func (c *TelegramChannel) SetSynthesizer(synthesizer voice.Synthesizer) {
+	c.synthesizer = synthesizer
+}
+
 func (c *TelegramChannel) Start(ctx context.Context) error {
+	if c.WebhookEnabled() {
+		return c.startWebhook(ctx)
+	}
 	logger.InfoC("telegram", "Starting Telegram bot (polling mode)...")
 
+	// getUpdates fails while a webhook is registered, e.g. after switching
+	// back from webhook mode.
+	if err := c.bot.DeleteWebhook(ctx, &telego.DeleteWebhookParams{}); err != nil {
+		logger.WarnCF("telegram", "Failed to delete webhook before polling", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
 	updates, err := c.bot.UpdatesViaLongPolling(ctx, &telego.GetUpdatesParams{
 		Timeout: 30,
 	})
@@ -127,6 +178,7 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 func (c *TelegramChannel) Stop(ctx context.Context) error {
 	logger.InfoC("telegram", "Stopping Telegram bot...")
 	c.setRunning(false)
+	c.stopWebhookServer(ctx)
 	return nil
 }
 
@@ -148,65 +200,190 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		c.stopThinking.Delete(msg.ChatID)
 	}
 
+	// Replace the ack reaction on the user's message with the done one once
+	// it is answered. Status lines and tool echoes carry no ReplyTo.
+	if msg.ReplyTo != "" {
+		if pending, ok := c.reactions.LoadAndDelete(telegramMessageKey(msg.ChatID, msg.ReplyTo)); ok {
+			c.setReaction(ctx, chatID, pending.(int), strings.TrimSpace(c.config.DoneReaction))
+		}
+	}
+
+	// With thread_replies, the first message of the answer quotes the user's
+	// reply so the thread stays linked.
+	replyTo := 0
+	if msg.ReplyTo != "" {
+		if pending, ok := c.replyTargets.LoadAndDelete(telegramMessageKey(msg.ChatID, msg.ReplyTo)); ok {
+			replyTo = pending.(int)
+		}
+	}
 
 	// If there's no media, send text only
 	if len(msg.Media) == 0 {
-		return c.sendText(ctx, chatID, msg.Content)
+		if err := c.sendText(ctx, chatID, msg.Content, replyTo); err != nil {
+			return err
+		}
+		if msg.Final && c.wantsVoiceReply(msg.ChatID) {
+			// Synthesis can take a while; the text is already out.
+			c.voiceReplies.Add(1)
+			go func() {
+				defer c.voiceReplies.Done()
+				c.sendVoiceReply(context.WithoutCancel(ctx), chatID, msg.ChatID, msg.Content)
+			}()
+		}
+		return nil
 	}
 
-	// Send text content first if present
-	if msg.Content != "" {
-		if textErr := c.sendText(ctx, chatID, msg.Content); textErr != nil {
+	// A short text for a single file goes out as its caption.
+	content := strings.TrimSpace(msg.Content)
+	if len(msg.Media) == 1 && content != "" && utf8.RuneCountInString(content) <= telegramMaxCaptionChars {
+		if err := c.sendMedia(ctx, chatID, msg.Media[0], content, replyTo); err != nil {
+			logger.ErrorCF("telegram", "Failed to send captioned media; sending text alone", map[string]interface{}{
+				"path":  msg.Media[0],
+				"error": err.Error(),
+			})
+			return c.sendText(ctx, chatID, content, replyTo)
+		}
+		return nil
+	}
+
+	// Otherwise send text content first if present
+	if content != "" {
+		if textErr := c.sendText(ctx, chatID, content, replyTo); textErr != nil {
 			logger.ErrorCF("telegram", "Failed to send text before media", map[string]interface{}{
 				"error": textErr.Error(),
 			})
 		}
+		replyTo = 0
 	}
 
 	// Send each media file
 	for _, mediaPath := range msg.Media {
-		file, fileErr := os.Open(mediaPath)
-		if fileErr != nil {
-			logger.ErrorCF("telegram", "Failed to open media file", map[string]interface{}{
+		if err := c.sendMedia(ctx, chatID, mediaPath, "", replyTo); err != nil {
+			logger.ErrorCF("telegram", "Failed to send media", map[string]interface{}{
 				"path":  mediaPath,
-				"error": fileErr.Error(),
+				"error": err.Error(),
 			})
 			continue
 		}
+		replyTo = 0
+	}
 
-		if isImageFile(mediaPath) {
-			photoMsg := tu.Photo(tu.ID(chatID), tu.File(file))
-			if _, sendErr := c.bot.SendPhoto(ctx, photoMsg); sendErr != nil {
-				logger.ErrorCF("telegram", "Failed to send photo", map[string]interface{}{
-					"path":  mediaPath,
-					"error": sendErr.Error(),
-				})
-			}
-		} else {
-			docMsg := tu.Document(tu.ID(chatID), tu.File(file))
-			if _, sendErr := c.bot.SendDocument(ctx, docMsg); sendErr != nil {
-				logger.ErrorCF("telegram", "Failed to send document", map[string]interface{}{
-					"path":  mediaPath,
-					"error": sendErr.Error(),
-				})
+	return nil
+}
+
+// sendMedia sends the file at path as a photo or document with an optional
+// caption, formatted as HTML when possible and as plain text otherwise.
+func (c *TelegramChannel) sendMedia(ctx context.Context, chatID int64, path, caption string, replyTo int) error {
+	if caption != "" {
+		htmlCaption := TelegramHTMLFormatter.Format(caption)
+		if htmlCaption != "" && utf8.RuneCountInString(htmlCaption) <= telegramMaxCaptionChars {
+			err := c.sendMediaFile(ctx, chatID, path, htmlCaption, telego.ModeHTML, replyTo)
+			if err == nil {
+				return nil
 			}
+			logger.WarnCF("telegram", "Failed to send media with HTML caption; retrying with plain text", map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
 		}
+	}
+	return c.sendMediaFile(ctx, chatID, path, caption, "", replyTo)
+}
+
+func (c *TelegramChannel) sendMediaFile(ctx context.Context, chatID int64, path, caption, parseMode string, replyTo int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open media file: %w", err)
+	}
+	defer file.Close()
+
+	if isImageFile(path) {
+		photoMsg := tu.Photo(tu.ID(chatID), tu.File(file))
+		photoMsg.Caption = caption
+		photoMsg.ParseMode = parseMode
+		photoMsg.ReplyParameters = replyParameters(replyTo)
+		_, err = c.bot.SendPhoto(ctx, photoMsg)
+		return err
+	}
+	docMsg := tu.Document(tu.ID(chatID), tu.File(file))
+	docMsg.Caption = caption
+	docMsg.ParseMode = parseMode
+	docMsg.ReplyParameters = replyParameters(replyTo)
+	_, err = c.bot.SendDocument(ctx, docMsg)
+	return err
+}
 
-		file.Close()
+// wantsVoiceReply reports whether replies to chatIDStr should include audio:
+// always when voice_replies is set, otherwise only after a voice note.
+func (c *TelegramChannel) wantsVoiceReply(chatIDStr string) bool {
+	if c.synthesizer == nil || !c.synthesizer.IsAvailable() {
+		return false
+	}
+	if c.config.VoiceReplies {
+		return true
 	}
+	_, ok := c.voiceChats.Load(chatIDStr)
+	return ok
+}
 
-	return nil
+// sendVoiceReply synthesizes the markdown reply content as plain speech and
+// sends it as a voice note after the text reply. Failures are logged; the
+// text has already been delivered.
+func (c *TelegramChannel) sendVoiceReply(ctx context.Context, chatID int64, chatIDStr, content string) {
+	content = strings.TrimSpace(PlainTextFormatter.Format(content))
+	if content == "" {
+		return
+	}
+	if utf8.RuneCountInString(content) > telegramVoiceMaxChars {
+		logger.DebugCF("telegram", "Reply too long for voice; sent text only", map[string]interface{}{
+			"chat_id": chatIDStr,
+			"chars":   utf8.RuneCountInString(content),
+		})
+		return
+	}
+
+	audioPath, err := c.synthesizer.Synthesize(ctx, content)
+	if err != nil {
+		logger.ErrorCF("telegram", "Voice synthesis failed", map[string]interface{}{
+			"chat_id": chatIDStr,
+			"error":   err.Error(),
+		})
+		return
+	}
+	defer os.Remove(audioPath)
+
+	file, err := os.Open(audioPath)
+	if err != nil {
+		logger.ErrorCF("telegram", "Failed to open synthesized audio", map[string]interface{}{
+			"path":  audioPath,
+			"error": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	if _, err := c.bot.SendVoice(ctx, tu.Voice(tu.ID(chatID), tu.File(file))); err != nil {
+		logger.ErrorCF("telegram", "Failed to send voice reply", map[string]interface{}{
+			"chat_id": chatIDStr,
+			"error":   err.Error(),
+		})
+	}
 }
 
-func (c *TelegramChannel) sendText(ctx context.Context, chatID int64, content string) error {
+// sendText sends content in chunks; a non-zero replyTo makes the first chunk
+// a reply to that message.
+func (c *TelegramChannel) sendText(ctx context.Context, chatID int64, content string, replyTo int) error {
 	content = strings.TrimSpace(content)
 	if content == "" {
 		return nil
 	}
 
 	chunks := splitByRuneLimit(content, telegramChunkChars)
-	for _, chunk := range chunks {
-		if err := c.sendTextChunk(ctx, chatID, chunk); err != nil {
+	for i, chunk := range chunks {
+		if i > 0 {
+			replyTo = 0
+		}
+		if err := c.sendTextChunk(ctx, chatID, chunk, replyTo); err != nil {
 			return err
 		}
 	}
@@ -214,23 +391,34 @@ func (c *TelegramChannel) sendText(ctx context.Context, chatID int64, content st
 	return nil
 }
 
-func (c *TelegramChannel) sendTextChunk(ctx context.Context, chatID int64, chunk string) error {
+// replyParameters links an outgoing message to messageID (0 = no reply). The
+// message is still sent if the original has been deleted.
+func replyParameters(messageID int) *telego.ReplyParameters {
+	if messageID == 0 {
+		return nil
+	}
+	return &telego.ReplyParameters{MessageID: messageID, AllowSendingWithoutReply: true}
+}
+
+func (c *TelegramChannel) sendTextChunk(ctx context.Context, chatID int64, chunk string, replyTo int) error {
 	chunk = strings.TrimSpace(chunk)
 	if chunk == "" {
 		return nil
 	}
 
 	// Try HTML first for nicer formatting, but never attempt an oversized payload.
-	htmlContent := markdownToTelegramHTML(chunk)
+	htmlContent := TelegramHTMLFormatter.Format(chunk)
 	if htmlContent != "" && utf8.RuneCountInString(htmlContent) <= telegramMaxMessageChars {
 		tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 		tgMsg.ParseMode = telego.ModeHTML
+		tgMsg.ReplyParameters = replyParameters(replyTo)
 		if _, err := c.bot.SendMessage(ctx, tgMsg); err == nil {
 			return nil
 		} else {
 			// Plain text fallback: send the original chunk (not the HTML string).
 			plainMsg := tu.Message(tu.ID(chatID), chunk)
 			plainMsg.ParseMode = ""
+			plainMsg.ReplyParameters = replyParameters(replyTo)
 			_, plainErr := c.bot.SendMessage(ctx, plainMsg)
 			if plainErr == nil {
 				logger.WarnCF("telegram", "Failed to send HTML message; sent plain text instead", map[string]interface{}{
@@ -244,6 +432,7 @@ func (c *TelegramChannel) sendTextChunk(ctx context.Context, chatID int64, chunk
 
 	plainMsg := tu.Message(tu.ID(chatID), chunk)
 	plainMsg.ParseMode = ""
+	plainMsg.ReplyParameters = replyParameters(replyTo)
 	_, err := c.bot.SendMessage(ctx, plainMsg)
 	return err
 }
@@ -360,6 +549,31 @@ func (c *TelegramChannel) startTypingIndicator(ctx context.Context, cancel conte
 	}()
 }
 
+// setReaction replaces the bot's reaction on a message; an empty emoji clears
+// it. Failures are only logged: some chats disable reactions or restrict the
+// allowed emoji.
+func (c *TelegramChannel) setReaction(ctx context.Context, chatID int64, messageID int, emoji string) {
+	err := c.bot.SetMessageReaction(ctx, &telego.SetMessageReactionParams{
+		ChatID:    tu.ID(chatID),
+		MessageID: messageID,
+		Reaction:  reactionTypes(emoji),
+	})
+	if err != nil {
+		logger.DebugCF("telegram", "Failed to set message reaction", map[string]interface{}{
+			"chat_id":    chatID,
+			"message_id": messageID,
+			"error":      err.Error(),
+		})
+	}
+}
+
+func reactionTypes(emoji string) []telego.ReactionType {
+	if emoji == "" {
+		return nil
+	}
+	return []telego.ReactionType{tu.ReactionEmoji(emoji)}
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Update) {
 	message := update.Message
 	if message == nil {
@@ -376,15 +590,17 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 		senderID = fmt.Sprintf("%d|%s", user.ID, user.Username)
 	}
 
+	chatID := message.Chat.ID
+
 	// 检查白名单，避免为被拒绝的用户下载附件
-	if !c.IsAllowed(senderID) {
+	if !c.IsAllowedInChat(senderID, fmt.Sprintf("%d", chatID)) {
 		logger.DebugCF("telegram", "Message rejected by allowlist", map[string]interface{}{
 			"user_id": senderID,
+			"chat_id": chatID,
 		})
 		return
 	}
 
-	chatID := message.Chat.ID
 	c.chatIDs[senderID] = chatID
 
 	content := ""
@@ -410,9 +626,19 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 		content += message.Caption
 	}
 
+	// addRejected notes an attachment that was refused by the media limits.
+	addRejected := func(err error) {
+		if placeholder := rejectedMediaPlaceholder(err); placeholder != "" {
+			if content != "" {
+				content += "\n"
+			}
+			content += placeholder
+		}
+	}
+
 	if message.Photo != nil && len(message.Photo) > 0 {
 		photo := message.Photo[len(message.Photo)-1]
-		photoPath := c.downloadPhoto(ctx, photo.FileID)
+		photoPath, err := c.downloadPhoto(ctx, photo.FileID)
 		if photoPath != "" {
 			localFiles = append(localFiles, photoPath)
 			mediaPaths = append(mediaPaths, photoPath)
@@ -421,10 +647,12 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 			}
 			content += fmt.Sprintf("[image: photo]")
 		}
+		addRejected(err)
 	}
 
 	if message.Voice != nil {
-		voicePath := c.downloadFile(ctx, message.Voice.FileID, ".ogg")
+		voicePath, err := c.downloadFile(ctx, message.Voice.FileID, ".ogg", mimeOrDefault(message.Voice.MimeType, "audio/ogg"))
+		addRejected(err)
 		if voicePath != "" {
 			localFiles = append(localFiles, voicePath)
 			mediaPaths = append(mediaPaths, voicePath)
@@ -459,7 +687,7 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 	}
 
 	if message.Audio != nil {
-		audioPath := c.downloadFile(ctx, message.Audio.FileID, ".mp3")
+		audioPath, err := c.downloadFile(ctx, message.Audio.FileID, ".mp3", mimeOrDefault(message.Audio.MimeType, "audio/mpeg"))
 		if audioPath != "" {
 			localFiles = append(localFiles, audioPath)
 			mediaPaths = append(mediaPaths, audioPath)
@@ -468,10 +696,11 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 			}
 			content += fmt.Sprintf("[audio]")
 		}
+		addRejected(err)
 	}
 
 	if message.Document != nil {
-		docPath := c.downloadFile(ctx, message.Document.FileID, "")
+		docPath, err := c.downloadFile(ctx, message.Document.FileID, "", message.Document.MimeType)
 		if docPath != "" {
 			localFiles = append(localFiles, docPath)
 			mediaPaths = append(mediaPaths, docPath)
@@ -480,19 +709,26 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 			}
 			content += fmt.Sprintf("[file]")
 		}
+		addRejected(err)
 	}
 
 	if content == "" {
 		content = "[empty message]"
 	}
 
+	replyToID, quoted := replyContext(message)
+	if quoted != "" {
+		content = fmt.Sprintf("[Replying to: %s]\n%s", quoted, content)
+	}
+
 	logger.DebugCF("telegram", "Received message", map[string]interface{}{
 		"sender_id": senderID,
 		"chat_id":   fmt.Sprintf("%d", chatID),
 		"preview":   utils.Truncate(content, 50),
 	})
 
-	// Start typing indicator (repeating "typing..." action until Send cancels it)
+	// Start typing indicator (repeating "typing..." action until Send cancels it),
+	// or acknowledge with a reaction instead when ack_reaction is set.
 	chatIDStr := fmt.Sprintf("%d", chatID)
 	if prevStop, ok := c.stopThinking.Load(chatIDStr); ok {
 		if cf, ok := prevStop.(*thinkingCancel); ok && cf != nil {
@@ -500,8 +736,27 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 		}
 	}
 
-	thinkCtx, thinkCancel := context.WithTimeout(ctx, 5*time.Minute)
-	c.startTypingIndicator(thinkCtx, thinkCancel, chatID, chatIDStr)
+	ackReaction := strings.TrimSpace(c.config.AckReaction)
+	if ackReaction != "" || strings.TrimSpace(c.config.DoneReaction) != "" {
+		c.reactions.Store(telegramMessageKey(chatIDStr, fmt.Sprintf("%d", message.MessageID)), message.MessageID)
+	}
+	if ackReaction != "" {
+		c.setReaction(ctx, chatID, message.MessageID, ackReaction)
+	} else {
+		thinkCtx, thinkCancel := context.WithTimeout(ctx, 5*time.Minute)
+		c.startTypingIndicator(thinkCtx, thinkCancel, chatID, chatIDStr)
+	}
+
+	if c.config.ThreadReplies && replyToID != 0 {
+		c.replyTargets.Store(telegramMessageKey(chatIDStr, fmt.Sprintf("%d", message.MessageID)), message.MessageID)
+	}
+
+	isVoice := message.Voice != nil
+	if isVoice {
+		c.voiceChats.Store(chatIDStr, struct{}{})
+	} else {
+		c.voiceChats.Delete(chatIDStr)
+	}
 
 	metadata := map[string]string{
 		"message_id": fmt.Sprintf("%d", message.MessageID),
@@ -509,26 +764,78 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, update telego.Updat
 		"username":   user.Username,
 		"first_name": user.FirstName,
 		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
+		"is_voice":   fmt.Sprintf("%t", isVoice),
+	}
+	if replyToID != 0 {
+		metadata["reply_to_message_id"] = fmt.Sprintf("%d", replyToID)
+		metadata["reply_to_text"] = quoted
 	}
 
 	c.HandleMessage(senderID, fmt.Sprintf("%d", chatID), content, mediaPaths, metadata)
 }
 
-func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
+// telegramMessageKey identifies one inbound message across chats.
+func telegramMessageKey(chatID, messageID string) string {
+	return chatID + ":" + messageID
+}
+
+// telegramQuoteMaxChars caps the replied-to text prepended to a message.
+const telegramQuoteMaxChars = 500
+
+// replyContext returns the ID and text of the message being replied to, or
+// (0, "") if message is not a reply. A quote (the part of the original the
+// user selected) wins over the full original text.
+func replyContext(message *telego.Message) (int, string) {
+	original := message.ReplyToMessage
+	if original == nil {
+		return 0, ""
+	}
+
+	quoted := ""
+	if message.Quote != nil {
+		quoted = message.Quote.Text
+	}
+	if strings.TrimSpace(quoted) == "" {
+		quoted = original.Text
+	}
+	if strings.TrimSpace(quoted) == "" {
+		quoted = original.Caption
+	}
+	quoted = strings.Join(strings.Fields(quoted), " ")
+	if quoted == "" {
+		quoted = "[non-text message]"
+	}
+	return original.MessageID, utils.Truncate(quoted, telegramQuoteMaxChars)
+}
+
+func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) (string, error) {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
 		logger.ErrorCF("telegram", "Failed to get photo file", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", err
 	}
 
-	return c.downloadFileWithInfo(file, ".jpg")
+	return c.downloadFileWithInfo(file, ".jpg", "image/jpeg")
 }
 
-func (c *TelegramChannel) downloadFileWithInfo(file *telego.File, ext string) string {
+// downloadFileWithInfo downloads file within the configured media limits.
+// contentType is the MIME type reported by Telegram, if any. Files over
+// max_media_size_mb or outside allowed_media_types fail with
+// utils.ErrFileTooLarge or utils.ErrFileTypeNotAllowed.
+func (c *TelegramChannel) downloadFileWithInfo(file *telego.File, ext, contentType string) (string, error) {
 	if file.FilePath == "" {
-		return ""
+		return "", fmt.Errorf("telegram file %s has no download path", file.FileID)
+	}
+
+	maxBytes := int64(c.config.MaxMediaSizeMB) << 20
+	if maxBytes > 0 && file.FileSize > maxBytes {
+		logger.WarnCF("telegram", "Attachment exceeds max_media_size_mb, not downloading", map[string]interface{}{
+			"size":   file.FileSize,
+			"max_mb": c.config.MaxMediaSizeMB,
+		})
+		return "", utils.ErrFileTooLarge
 	}
 
 	url := c.bot.FileDownloadURL(file.FilePath)
@@ -542,21 +849,43 @@ func (c *TelegramChannel) downloadFileWithInfo(file *telego.File, ext string) st
 		}
 		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
 	}
-	return utils.DownloadFile(url, filename, utils.DownloadOptions{
+	return utils.DownloadFileChecked(url, filename, utils.DownloadOptions{
 		LoggerPrefix: "telegram",
+		MaxBytes:     maxBytes,
+		AllowedTypes: c.config.AllowedMediaTypes,
+		ContentType:  contentType,
 	})
 }
 
-func (c *TelegramChannel) downloadFile(ctx context.Context, fileID, ext string) string {
+func (c *TelegramChannel) downloadFile(ctx context.Context, fileID, ext, contentType string) (string, error) {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
 		logger.ErrorCF("telegram", "Failed to get file", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", err
+	}
+
+	return c.downloadFileWithInfo(file, ext, contentType)
+}
+
+// rejectedMediaPlaceholder is the message text standing in for an attachment
+// refused by the media limits, or "" for other download failures.
+func rejectedMediaPlaceholder(err error) string {
+	switch {
+	case errors.Is(err, utils.ErrFileTooLarge):
+		return "[file too large]"
+	case errors.Is(err, utils.ErrFileTypeNotAllowed):
+		return "[file type not allowed]"
 	}
+	return ""
+}
 
-	return c.downloadFileWithInfo(file, ext)
+func mimeOrDefault(mimeType, fallback string) string {
+	if strings.TrimSpace(mimeType) == "" {
+		return fallback
+	}
+	return mimeType
 }
 
 func isImageFile(path string) bool {
@@ -580,11 +909,13 @@ func markdownToTelegramHTML(text string) string {
 		return ""
 	}
 
+	// Tables are pulled out between code blocks and inline code so their
+	// cells keep code spans as plain text.
 	codeBlocks := extractCodeBlocks(text)
-	text = codeBlocks.text
-
-	inlineCodes := extractInlineCodes(text)
-	text = inlineCodes.text
+	tables := extractTables(codeBlocks.text)
+	inlineCodes := extractInlineCodes(tables.text)
+	spans := codeSpans{text: inlineCodes.text, blocks: codeBlocks.codes, inline: inlineCodes.codes}
+	text = spans.text
 
 	text = regexp.MustCompile(`^#{1,6}\s+(.+)$`).ReplaceAllString(text, "$1")
 
@@ -611,70 +942,168 @@ func markdownToTelegramHTML(text string) string {
 
 	text = regexp.MustCompile(`^[-*]\s+`).ReplaceAllString(text, "• ")
 
-	for i, code := range inlineCodes.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), fmt.Sprintf("<code>%s</code>", escaped))
-	}
+	text = spans.restore(text,
+		func(code string) string { return "<pre><code>" + escapeHTML(code) + "</code></pre>" },
+		func(code string) string { return "<code>" + escapeHTML(code) + "</code>" })
 
-	for i, code := range codeBlocks.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(text, fmt.Sprintf("\x00CB%d\x00", i), fmt.Sprintf("<pre><code>%s</code></pre>", escaped))
+	for i, table := range tables.tables {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00TB%d\x00", i), fmt.Sprintf("<pre>%s</pre>", escapeHTML(table)))
 	}
 
 	return text
 }
 
-type codeBlockMatch struct {
-	text  string
-	codes []string
+type tableMatch struct {
+	text   string
+	tables []string
 }
 
-func extractCodeBlocks(text string) codeBlockMatch {
-	re := regexp.MustCompile("```[\\w]*\\n?([\\s\\S]*?)```")
-	matches := re.FindAllStringSubmatch(text, -1)
+var tableSeparatorCellRe = regexp.MustCompile(`^:?-+:?$`)
 
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
+// extractTables replaces GFM pipe tables (header row, separator row, body
+// rows) with placeholders and renders each as padded fixed-width text, since
+// Telegram HTML has no table markup.
+func extractTables(text string) tableMatch {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	var tables []string
 
-	idx := 0
-	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		s := fmt.Sprintf("\x00CB%d\x00", idx)
-		idx++
-		return s
-	})
+	for i := 0; i < len(lines); i++ {
+		if i+1 >= len(lines) || !strings.Contains(lines[i], "|") {
+			out = append(out, lines[i])
+			continue
+		}
+		header := splitTableRow(lines[i])
+		aligns, ok := parseTableSeparator(lines[i+1])
+		if !ok || len(header) != len(aligns) {
+			out = append(out, lines[i])
+			continue
+		}
+
+		rows := [][]string{header}
+		j := i + 2
+		for ; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "" || !strings.Contains(lines[j], "|") {
+				break
+			}
+			rows = append(rows, splitTableRow(lines[j]))
+		}
 
-	return codeBlockMatch{text: text, codes: codes}
+		out = append(out, fmt.Sprintf("\x00TB%d\x00", len(tables)))
+		tables = append(tables, renderTable(rows, aligns))
+		i = j - 1
+	}
+
+	return tableMatch{text: strings.Join(out, "\n"), tables: tables}
 }
 
-type inlineCodeMatch struct {
-	text  string
-	codes []string
+// splitTableRow splits a pipe-delimited row into trimmed cells, honouring
+// escaped pipes and dropping inline bold/code markers that <pre> can't show.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if !strings.HasSuffix(line, "\\|") {
+		line = strings.TrimSuffix(line, "|")
+	}
+	line = strings.ReplaceAll(line, "\\|", "\x00PIPE\x00")
+
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cell = strings.ReplaceAll(cell, "\x00PIPE\x00", "|")
+		cell = strings.ReplaceAll(cell, "**", "")
+		cell = strings.ReplaceAll(cell, "`", "")
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
 }
 
-func extractInlineCodes(text string) inlineCodeMatch {
-	re := regexp.MustCompile("`([^`]+)`")
-	matches := re.FindAllStringSubmatch(text, -1)
+// parseTableSeparator returns per-column alignment ('l', 'c' or 'r') for a
+// separator row such as "|---|:--:|--:|", or false if line isn't one.
+func parseTableSeparator(line string) ([]byte, bool) {
+	if !strings.Contains(line, "-") {
+		return nil, false
+	}
+	cells := splitTableRow(line)
+	aligns := make([]byte, len(cells))
+	for i, cell := range cells {
+		if !tableSeparatorCellRe.MatchString(cell) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns[i] = 'c'
+		case strings.HasSuffix(cell, ":"):
+			aligns[i] = 'r'
+		default:
+			aligns[i] = 'l'
+		}
+	}
+	return aligns, true
+}
 
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
+// renderTable lays rows out in padded columns with a rule under the header.
+// Ragged rows are aligned to the widest row.
+func renderTable(rows [][]string, aligns []byte) string {
+	cols := len(aligns)
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for c, cell := range row {
+			if w := utf8.RuneCountInString(cell); w > widths[c] {
+				widths[c] = w
+			}
+		}
 	}
 
-	idx := 0
-	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		s := fmt.Sprintf("\x00IC%d\x00", idx)
-		idx++
-		return s
-	})
+	var b strings.Builder
+	for r, row := range rows {
+		cells := make([]string, cols)
+		for c := 0; c < cols; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			align := byte('l')
+			if c < len(aligns) {
+				align = aligns[c]
+			}
+			cells[c] = padTableCell(cell, widths[c], align)
+		}
+		// Missing trailing cells are left off rather than drawn empty.
+		if len(row) < cols {
+			cells = cells[:max(len(row), 1)]
+		}
+		b.WriteString(strings.TrimRight(strings.Join(cells, " | "), " "))
+		b.WriteString("\n")
 
-	return inlineCodeMatch{text: text, codes: codes}
+		if r == 0 {
+			rules := make([]string, cols)
+			for c, w := range widths {
+				rules[c] = strings.Repeat("-", w)
+			}
+			b.WriteString(strings.Join(rules, "-+-"))
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
 }
 
-func escapeHTML(text string) string {
-	text = strings.ReplaceAll(text, "&", "&amp;")
-	text = strings.ReplaceAll(text, "<", "&lt;")
-	text = strings.ReplaceAll(text, ">", "&gt;")
-	return text
+func padTableCell(cell string, width int, align byte) string {
+	pad := width - utf8.RuneCountInString(cell)
+	if pad <= 0 {
+		return cell
+	}
+	switch align {
+	case 'r':
+		return strings.Repeat(" ", pad) + cell
+	case 'c':
+		left := pad / 2
+		return strings.Repeat(" ", left) + cell + strings.Repeat(" ", pad-left)
+	default:
+		return cell + strings.Repeat(" ", pad)
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,8 @@ import (
 	"github.com/mymmrac/telego"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 // mockTelegramBot implements telegramBot for testing.
@@ -28,6 +31,11 @@ type mockTelegramBot struct {
 	deleteMessageCalls  []*telego.DeleteMessageParams
 	sendPhotoCalls      []*telego.SendPhotoParams
 	sendDocumentCalls   []*telego.SendDocumentParams
+	sendVoiceCalls      []*telego.SendVoiceParams
+	reactionCalls       []*telego.SetMessageReactionParams
+	reactionErr         error
+	setWebhookCalls     []*telego.SetWebhookParams
+	deleteWebhookCalls  int
 
 	// configurable return for SendMessage
 	sendMessageID int
@@ -72,6 +80,12 @@ func (m *mockTelegramBot) SendChatAction(ctx context.Context, params *telego.Sen
 	m.sendChatActionCalls = append(m.sendChatActionCalls, params)
 	return nil
 }
+func (m *mockTelegramBot) SetMessageReaction(ctx context.Context, params *telego.SetMessageReactionParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reactionCalls = append(m.reactionCalls, params)
+	return m.reactionErr
+}
 func (m *mockTelegramBot) SendPhoto(ctx context.Context, params *telego.SendPhotoParams) (*telego.Message, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -84,6 +98,12 @@ func (m *mockTelegramBot) SendDocument(ctx context.Context, params *telego.SendD
 	m.sendDocumentCalls = append(m.sendDocumentCalls, params)
 	return &telego.Message{MessageID: m.sendMessageID}, nil
 }
+func (m *mockTelegramBot) SendVoice(ctx context.Context, params *telego.SendVoiceParams) (*telego.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sendVoiceCalls = append(m.sendVoiceCalls, params)
+	return &telego.Message{MessageID: m.sendMessageID}, nil
+}
 func (m *mockTelegramBot) EditMessageText(ctx context.Context, params *telego.EditMessageTextParams) (*telego.Message, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -104,6 +124,19 @@ func (m *mockTelegramBot) GetFile(ctx context.Context, params *telego.GetFilePar
 	return &telego.File{FileID: params.FileID, FilePath: path}, nil
 }
 
+func (m *mockTelegramBot) SetWebhook(ctx context.Context, params *telego.SetWebhookParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setWebhookCalls = append(m.setWebhookCalls, params)
+	return nil
+}
+func (m *mockTelegramBot) DeleteWebhook(ctx context.Context, params *telego.DeleteWebhookParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteWebhookCalls++
+	return nil
+}
+
 func (m *mockTelegramBot) getSendMessageCalls() []*telego.SendMessageParams {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -120,6 +153,14 @@ func (m *mockTelegramBot) getSendChatActionCalls() []*telego.SendChatActionParam
 	return cp
 }
 
+func (m *mockTelegramBot) getReactionCalls() []*telego.SetMessageReactionParams {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := make([]*telego.SetMessageReactionParams, len(m.reactionCalls))
+	copy(cp, m.reactionCalls)
+	return cp
+}
+
 func (m *mockTelegramBot) getEditMessageCalls() []*telego.EditMessageTextParams {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -128,6 +169,14 @@ func (m *mockTelegramBot) getEditMessageCalls() []*telego.EditMessageTextParams
 	return cp
 }
 
+func (m *mockTelegramBot) getSendVoiceCalls() []*telego.SendVoiceParams {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := make([]*telego.SendVoiceParams, len(m.sendVoiceCalls))
+	copy(cp, m.sendVoiceCalls)
+	return cp
+}
+
 func (m *mockTelegramBot) getDeleteMessageCalls() []*telego.DeleteMessageParams {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -352,6 +401,44 @@ func TestMarkdownToTelegramHTML(t *testing.T) {
 	}
 }
 
+func TestMarkdownToTelegramHTML_Tables(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "two column table",
+			input: "Results:\n| Name | Score |\n|------|------:|\n| alice | 9 |\n| bob | 10 |\nDone.",
+			want:  "Results:\n<pre>Name  | Score\n------+------\nalice |     9\nbob   |    10</pre>\nDone.",
+		},
+		{
+			name:  "ragged rows padded",
+			input: "a | b | c\n--- | --- | ---\n1 | 2\nx | y | z | extra",
+			want:  "<pre>a | b | c\n--+---+---+------\n1 | 2\nx | y | z | extra</pre>",
+		},
+		{
+			name:  "cells escaped and unformatted",
+			input: "| op | meaning |\n|:--:|---|\n| `<` | **less** \\| than |",
+			want:  "<pre>op | meaning\n---+------------\n&lt;  | less | than</pre>",
+		},
+		{
+			name:  "pipes without separator are not a table",
+			input: "run cat a.txt | grep b\nthen done",
+			want:  "run cat a.txt | grep b\nthen done",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markdownToTelegramHTML(tt.input)
+			if got != tt.want {
+				t.Errorf("markdownToTelegramHTML(%q) =\n%q\nwant\n%q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 // --- Send() tests ---
 
 func TestSend_TextMessage(t *testing.T) {
@@ -553,6 +640,271 @@ func TestHandleMessage_DownloadedPhotoIsRetainedForAgentInspection(t *testing.T)
 	}
 }
 
+func TestHandleMessage_OversizedDocumentBecomesPlaceholder(t *testing.T) {
+	var served atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// No Content-Length: the cap has to be enforced while streaming.
+		w.(http.Flusher).Flush()
+		chunk := make([]byte, 64*1024)
+		for i := 0; i < 32; i++ {
+			n, err := w.Write(chunk)
+			served.Add(int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	mock := newMockBot()
+	mock.fileDownloadBase = srv.URL
+	mock.getFilePath = "documents/huge.bin"
+	ch := newTestTelegramChannel(mock)
+	ch.config.MaxMediaSizeMB = 1
+
+	before, _ := os.ReadDir(utils.MediaDir())
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 1,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Caption:   "see attached",
+		Document:  &telego.Document{FileID: "doc-1", FileName: "huge.bin"},
+	}})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	msg, ok := ch.bus.ConsumeInbound(outCtx)
+	if !ok {
+		t.Fatalf("expected inbound message")
+	}
+	if len(msg.Media) != 0 {
+		t.Fatalf("expected no media paths, got %v", msg.Media)
+	}
+	if msg.Content != "see attached\n[file too large]" {
+		t.Fatalf("content = %q, want placeholder", msg.Content)
+	}
+
+	after, _ := os.ReadDir(utils.MediaDir())
+	for _, entry := range after {
+		if strings.HasSuffix(entry.Name(), "_huge.bin") && !containsDirEntry(before, entry.Name()) {
+			t.Fatalf("oversized download left a local file: %s", entry.Name())
+		}
+	}
+}
+
+func TestHandleMessage_ReportedSizeOverCapSkipsDownload(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	mock := &sizedFileBot{mockTelegramBot: newMockBot(), size: 5 << 20}
+	mock.fileDownloadBase = srv.URL
+	ch := newTestTelegramChannel(mock)
+	ch.config.MaxMediaSizeMB = 1
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 1,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Photo:     []telego.PhotoSize{{FileID: "photo-1"}},
+	}})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	msg, ok := ch.bus.ConsumeInbound(outCtx)
+	if !ok || msg.Content != "[file too large]" {
+		t.Fatalf("message = %+v, want [file too large]", msg)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no download request, got %d", requests.Load())
+	}
+}
+
+func TestHandleMessage_DisallowedMediaTypeBecomesPlaceholder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("disallowed document should not be requested")
+	}))
+	defer srv.Close()
+
+	mock := newMockBot()
+	mock.fileDownloadBase = srv.URL
+	mock.getFilePath = "documents/tool.exe"
+	ch := newTestTelegramChannel(mock)
+	ch.config.AllowedMediaTypes = []string{"image/*", ".pdf"}
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 1,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Document:  &telego.Document{FileID: "doc-1", MimeType: "application/x-msdownload"},
+	}})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	msg, ok := ch.bus.ConsumeInbound(outCtx)
+	if !ok || msg.Content != "[file type not allowed]" {
+		t.Fatalf("message = %+v, want [file type not allowed]", msg)
+	}
+}
+
+// sizedFileBot reports a fixed file size from GetFile.
+type sizedFileBot struct {
+	*mockTelegramBot
+	size int64
+}
+
+func (b *sizedFileBot) GetFile(ctx context.Context, params *telego.GetFileParams) (*telego.File, error) {
+	file, err := b.mockTelegramBot.GetFile(ctx, params)
+	if file != nil {
+		file.FileSize = b.size
+	}
+	return file, err
+}
+
+func containsDirEntry(entries []os.DirEntry, name string) bool {
+	for _, e := range entries {
+		if e.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeSynthesizer writes a small placeholder audio file per call.
+type fakeSynthesizer struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (f *fakeSynthesizer) Synthesize(ctx context.Context, text string) (string, error) {
+	f.mu.Lock()
+	f.texts = append(f.texts, text)
+	f.mu.Unlock()
+	out, err := os.CreateTemp("", "tts-test-*.ogg")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	_, err = out.WriteString("OggS")
+	return out.Name(), err
+}
+
+func (f *fakeSynthesizer) IsAvailable() bool { return true }
+
+func TestSend_VoiceReplyAfterVoiceNote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fake-voice-bytes"))
+	}))
+	defer srv.Close()
+
+	mock := newMockBot()
+	mock.fileDownloadBase = srv.URL
+	mock.getFilePath = "voice/file_3.oga"
+	ch := newTestTelegramChannel(mock)
+	synth := &fakeSynthesizer{}
+	ch.SetSynthesizer(synth)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch.handleMessage(ctx, telego.Update{Message: &telego.Message{
+		MessageID: 1,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Voice:     &telego.Voice{FileID: "voice-1"},
+	}})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	in, ok := ch.bus.ConsumeInbound(outCtx)
+	if !ok {
+		t.Fatalf("expected inbound message")
+	}
+	for _, p := range in.Media {
+		defer os.Remove(p)
+	}
+	if in.Metadata["is_voice"] != "true" {
+		t.Fatalf("expected is_voice metadata, got %q", in.Metadata["is_voice"])
+	}
+
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "123", Content: "**Hello** back", Final: true}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	ch.voiceReplies.Wait()
+	if n := len(mock.getSendMessageCalls()); n != 1 {
+		t.Fatalf("expected text reply to be kept, got %d messages", n)
+	}
+	if n := len(mock.getSendVoiceCalls()); n != 1 {
+		t.Fatalf("expected 1 voice reply, got %d", n)
+	}
+	if len(synth.texts) != 1 || synth.texts[0] != "Hello back" {
+		t.Fatalf("unexpected synthesized texts (want markdown stripped): %v", synth.texts)
+	}
+
+	// A following text message switches back to text-only replies.
+	ch.handleMessage(ctx, telego.Update{Message: &telego.Message{
+		MessageID: 2,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Text:      "thanks",
+	}})
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "123", Content: "You're welcome", Final: true}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	ch.voiceReplies.Wait()
+	if n := len(mock.getSendVoiceCalls()); n != 1 {
+		t.Fatalf("expected no voice reply to text message, got %d voice calls", n)
+	}
+}
+
+func TestSend_VoiceRepliesConfigAlwaysSendsVoice(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.VoiceReplies = true
+	ch.SetSynthesizer(&fakeSynthesizer{})
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "Hi", Final: true}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	ch.voiceReplies.Wait()
+	if n := len(mock.getSendVoiceCalls()); n != 1 {
+		t.Fatalf("expected 1 voice reply, got %d", n)
+	}
+}
+
+func TestSend_NoVoiceForStatusAndToolMessages(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.VoiceReplies = true
+	synth := &fakeSynthesizer{}
+	ch.SetSynthesizer(synth)
+
+	// Status lines, tool echoes and error notices are not marked Final.
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "Still working..."}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	ch.voiceReplies.Wait()
+	if n := len(mock.getSendVoiceCalls()); n != 0 || len(synth.texts) != 0 {
+		t.Fatalf("expected no voice for a non-final message, got %d voice calls", n)
+	}
+}
+
+func TestSend_NoSynthesizerSendsTextOnly(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.VoiceReplies = true
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "Hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if n := len(mock.getSendVoiceCalls()); n != 0 {
+		t.Fatalf("expected no voice reply without synthesizer, got %d", n)
+	}
+}
+
 func TestSend_StopsTypingIndicator(t *testing.T) {
 	mock := newMockBot()
 	ch := newTestTelegramChannel(mock)
@@ -583,6 +935,269 @@ func TestSend_StopsTypingIndicator(t *testing.T) {
 	}
 }
 
+func reactionEmoji(t *testing.T, params *telego.SetMessageReactionParams) string {
+	t.Helper()
+	if len(params.Reaction) != 1 {
+		t.Fatalf("expected one reaction, got %d", len(params.Reaction))
+	}
+	emoji, ok := params.Reaction[0].(*telego.ReactionTypeEmoji)
+	if !ok {
+		t.Fatalf("expected emoji reaction, got %T", params.Reaction[0])
+	}
+	return emoji.Emoji
+}
+
+func TestHandleMessage_AckReaction_ReactsInsteadOfTyping(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.AckReaction = "👀"
+	ch.config.DoneReaction = "👍"
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 77,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "hello",
+	}})
+
+	calls := mock.getReactionCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 reaction call on receipt, got %d", len(calls))
+	}
+	if calls[0].MessageID != 77 || calls[0].ChatID.ID != 12345 {
+		t.Fatalf("reaction targeted chat %d message %d", calls[0].ChatID.ID, calls[0].MessageID)
+	}
+	if got := reactionEmoji(t, calls[0]); got != "👀" {
+		t.Fatalf("receipt reaction = %q, want %q", got, "👀")
+	}
+	if got := mock.getSendChatActionCalls(); len(got) != 0 {
+		t.Fatalf("expected no typing indicator with reactions enabled, got %d chat actions", len(got))
+	}
+
+	// A status line or tool echo leaves the ack in place.
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "Still working..."}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := len(mock.getReactionCalls()); got != 1 {
+		t.Fatalf("expected the ack to stay until the reply, got %d reaction calls", got)
+	}
+	// A reply to another message in the chat does not touch it either.
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "other", ReplyTo: "76"})
+	if got := len(mock.getReactionCalls()); got != 1 {
+		t.Fatalf("expected no reaction for another message's reply, got %d calls", got)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "hi", ReplyTo: "77"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	calls = mock.getReactionCalls()
+	if len(calls) != 2 || calls[1].MessageID != 77 || reactionEmoji(t, calls[1]) != "👍" {
+		t.Fatalf("expected done reaction on message 77 after Send, got %d calls", len(calls))
+	}
+
+	// Only the first reply replaces the reaction.
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "more", ReplyTo: "77"})
+	if got := len(mock.getReactionCalls()); got != 2 {
+		t.Fatalf("expected no further reaction calls, got %d", got)
+	}
+}
+
+func TestHandleMessage_ReactionErrorsAreIgnored(t *testing.T) {
+	mock := newMockBot()
+	mock.reactionErr = errors.New("Bad Request: REACTION_INVALID")
+	ch := newTestTelegramChannel(mock)
+	ch.config.AckReaction = "👀"
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 5,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "hello",
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, ok := ch.bus.ConsumeInbound(ctx); !ok {
+		t.Fatal("expected message to be delivered despite reaction failure")
+	}
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "hi", ReplyTo: "5"}); err != nil {
+		t.Fatalf("Send should ignore reaction errors, got %v", err)
+	}
+}
+
+func TestSend_EmptyDoneReactionClearsAck(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.AckReaction = "👀"
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 9,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "hello",
+	}})
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "hi", ReplyTo: "9"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	calls := mock.getReactionCalls()
+	if len(calls) != 2 || calls[1].MessageID != 9 || len(calls[1].Reaction) != 0 {
+		t.Fatalf("expected ack reaction to be cleared after Send, got %d calls", len(calls))
+	}
+}
+
+func TestHandleMessage_ReactionsDisabledByDefault(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 5,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "hello",
+	}})
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "hi"})
+
+	if got := len(mock.getReactionCalls()); got != 0 {
+		t.Fatalf("expected no reaction calls by default, got %d", got)
+	}
+}
+
+func TestHandleMessage_ReplyIncludesQuotedText(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 20,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "what about this one?",
+		ReplyToMessage: &telego.Message{
+			MessageID: 11,
+			Text:      "Option A: ship on Friday\nOption B: wait a week",
+		},
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := ch.bus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	want := "[Replying to: Option A: ship on Friday Option B: wait a week]\nwhat about this one?"
+	if msg.Content != want {
+		t.Fatalf("content = %q, want %q", msg.Content, want)
+	}
+	if msg.Metadata["reply_to_message_id"] != "11" || msg.Metadata["reply_to_text"] == "" {
+		t.Fatalf("missing reply metadata: %+v", msg.Metadata)
+	}
+
+	// Without thread_replies the answer is a plain message.
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "hi"})
+	if calls := mock.getSendMessageCalls(); len(calls) != 1 || calls[0].ReplyParameters != nil {
+		t.Fatalf("expected an unthreaded answer by default")
+	}
+}
+
+func TestHandleMessage_ReplyPrefersSelectedQuote(t *testing.T) {
+	_, quoted := replyContext(&telego.Message{
+		Text:           "this part",
+		Quote:          &telego.TextQuote{Text: "ship on Friday"},
+		ReplyToMessage: &telego.Message{MessageID: 3, Text: "Option A: ship on Friday"},
+	})
+	if quoted != "ship on Friday" {
+		t.Fatalf("quoted = %q, want selected quote", quoted)
+	}
+	if id, quoted := replyContext(&telego.Message{Text: "hi"}); id != 0 || quoted != "" {
+		t.Fatalf("non-reply returned (%d, %q)", id, quoted)
+	}
+}
+
+func TestHandleMessage_AllowChatsAdmitsGroupRegardlessOfSender(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.allowList = []string{"1"}
+	ch.SetChatAllowList([]string{"-100200"}, "")
+
+	send := func(userID, chatID int64) {
+		ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+			MessageID: int(userID*1000 + chatID%1000),
+			From:      &telego.User{ID: userID},
+			Chat:      telego.Chat{ID: chatID, Type: "group"},
+			Text:      "hello",
+		}})
+	}
+	send(7, -100200) // stranger in the allowed group
+	send(1, -100300) // allowed sender in another group
+	send(7, -100300) // stranger in another group
+
+	msgs := drainInbound(ch.bus)
+	if len(msgs) != 2 {
+		t.Fatalf("got %d inbound messages, want 2: %+v", len(msgs), msgs)
+	}
+	if msgs[0].ChatID != "-100200" || msgs[1].ChatID != "-100300" || msgs[1].SenderID != "1" {
+		t.Fatalf("unexpected messages admitted: %+v", msgs)
+	}
+}
+
+func TestSend_ThreadRepliesAnswersAsReply(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+	ch.config.ThreadReplies = true
+
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID:      20,
+		From:           &telego.User{ID: 1},
+		Chat:           telego.Chat{ID: 12345, Type: "private"},
+		Text:           "and this?",
+		ReplyToMessage: &telego.Message{MessageID: 11, Text: "earlier answer"},
+	}})
+	// Another sender's reply in the same chat keeps its own target.
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID:      22,
+		From:           &telego.User{ID: 2},
+		Chat:           telego.Chat{ID: 12345, Type: "group"},
+		Text:           "me too",
+		ReplyToMessage: &telego.Message{MessageID: 11, Text: "earlier answer"},
+	}})
+	// A progress line before the answer is not threaded and takes nothing.
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "working on it"})
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "threaded", ReplyTo: "20"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "follow-up", ReplyTo: "20"})
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "for 22", ReplyTo: "22"})
+
+	calls := mock.getSendMessageCalls()
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(calls))
+	}
+	if calls[0].ReplyParameters != nil {
+		t.Fatalf("progress line should not be threaded, got %+v", calls[0].ReplyParameters)
+	}
+	if calls[1].ReplyParameters == nil || calls[1].ReplyParameters.MessageID != 20 {
+		t.Fatalf("expected first answer to reply to message 20, got %+v", calls[1].ReplyParameters)
+	}
+	if calls[2].ReplyParameters != nil {
+		t.Fatalf("only the first answer should be threaded, got %+v", calls[2].ReplyParameters)
+	}
+	if calls[3].ReplyParameters == nil || calls[3].ReplyParameters.MessageID != 22 {
+		t.Fatalf("expected the other answer to reply to message 22, got %+v", calls[3].ReplyParameters)
+	}
+
+	// Messages that are not replies are answered normally.
+	ch.handleMessage(context.Background(), telego.Update{Message: &telego.Message{
+		MessageID: 21,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 12345, Type: "private"},
+		Text:      "new topic",
+	}})
+	_ = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "12345", Content: "plain", ReplyTo: "21"})
+	if calls := mock.getSendMessageCalls(); calls[4].ReplyParameters != nil {
+		t.Fatalf("expected plain answer to a non-reply, got %+v", calls[4].ReplyParameters)
+	}
+}
+
 // --- Typing indicator tests ---
 
 func TestStartTypingIndicator_SendsChatAction(t *testing.T) {
@@ -668,3 +1283,174 @@ func TestStartTypingIndicator_RepeatsAction(t *testing.T) {
 		t.Errorf("expected at least 2 SendChatAction calls for repeated typing, got %d", len(actions))
 	}
 }
+
+// fakeTranscriber records the files it was asked to transcribe.
+type fakeTranscriber struct {
+	mu    sync.Mutex
+	paths []string
+	text  string
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*voice.TranscriptionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, audioFilePath)
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, fmt.Errorf("expected a transcription deadline")
+	}
+	return &voice.TranscriptionResponse{Text: f.text}, nil
+}
+
+func (f *fakeTranscriber) IsAvailable() bool { return true }
+
+func TestHandleMessage_UsesInjectedTranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fake-voice-bytes"))
+	}))
+	defer srv.Close()
+
+	mock := newMockBot()
+	mock.fileDownloadBase = srv.URL
+	mock.getFilePath = "voice/file_4.oga"
+	ch := newTestTelegramChannel(mock)
+	transcriber := &fakeTranscriber{text: "turn on the lights"}
+	ch.SetTranscriber(transcriber)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch.handleMessage(ctx, telego.Update{Message: &telego.Message{
+		MessageID: 1,
+		From:      &telego.User{ID: 1},
+		Chat:      telego.Chat{ID: 123, Type: "private"},
+		Voice:     &telego.Voice{FileID: "voice-1"},
+	}})
+
+	outCtx, outCancel := context.WithTimeout(context.Background(), time.Second)
+	defer outCancel()
+	in, ok := ch.bus.ConsumeInbound(outCtx)
+	if !ok {
+		t.Fatalf("expected inbound message")
+	}
+	for _, p := range in.Media {
+		defer os.Remove(p)
+	}
+	if len(transcriber.paths) != 1 {
+		t.Fatalf("expected 1 transcription call, got %d", len(transcriber.paths))
+	}
+	if len(in.Media) != 1 || transcriber.paths[0] != in.Media[0] {
+		t.Fatalf("expected downloaded voice file to be transcribed, got %v (media %v)", transcriber.paths, in.Media)
+	}
+	if !strings.Contains(in.Content, "[voice transcription: turn on the lights]") {
+		t.Fatalf("expected transcript in content, got %q", in.Content)
+	}
+}
+
+func writeTestMedia(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("media"), 0644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	return path
+}
+
+func TestSend_ShortContentWithOneImage_SendsCaptionedPhoto(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "12345",
+		Content: "Here is the **chart**",
+		Media:   []string{writeTestMedia(t, "chart.png")},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if calls := mock.getSendMessageCalls(); len(calls) != 0 {
+		t.Fatalf("expected no separate text message, got %d", len(calls))
+	}
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.sendPhotoCalls) != 1 {
+		t.Fatalf("expected 1 SendPhoto call, got %d", len(mock.sendPhotoCalls))
+	}
+	photo := mock.sendPhotoCalls[0]
+	if photo.Caption != "Here is the <b>chart</b>" || photo.ParseMode != telego.ModeHTML {
+		t.Fatalf("caption = %q (parse mode %q), want HTML caption", photo.Caption, photo.ParseMode)
+	}
+}
+
+func TestSend_ShortContentWithOneDocument_SendsCaptionedDocument(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "12345",
+		Content: "The report",
+		Media:   []string{writeTestMedia(t, "report.pdf")},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.sendMessageCalls) != 0 || len(mock.sendDocumentCalls) != 1 {
+		t.Fatalf("got %d messages and %d documents, want one captioned document",
+			len(mock.sendMessageCalls), len(mock.sendDocumentCalls))
+	}
+	if mock.sendDocumentCalls[0].Caption != "The report" {
+		t.Fatalf("caption = %q", mock.sendDocumentCalls[0].Caption)
+	}
+}
+
+func TestSend_LongContentWithImage_SendsTextThenPhoto(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "12345",
+		Content: strings.Repeat("a", telegramMaxCaptionChars+1),
+		Media:   []string{writeTestMedia(t, "chart.png")},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if calls := mock.getSendMessageCalls(); len(calls) != 1 {
+		t.Fatalf("expected 1 text message, got %d", len(calls))
+	}
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.sendPhotoCalls) != 1 || mock.sendPhotoCalls[0].Caption != "" {
+		t.Fatalf("expected 1 uncaptioned photo, got %+v", mock.sendPhotoCalls)
+	}
+}
+
+func TestSend_MultipleMedia_SendsTextSeparately(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestTelegramChannel(mock)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "12345",
+		Content: "Two files",
+		Media:   []string{writeTestMedia(t, "a.png"), writeTestMedia(t, "b.txt")},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if calls := mock.getSendMessageCalls(); len(calls) != 1 || calls[0].Text != "Two files" {
+		t.Fatalf("expected the text as its own message, got %+v", calls)
+	}
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.sendPhotoCalls) != 1 || len(mock.sendDocumentCalls) != 1 {
+		t.Fatalf("got %d photos and %d documents", len(mock.sendPhotoCalls), len(mock.sendDocumentCalls))
+	}
+	if mock.sendPhotoCalls[0].Caption != "" || mock.sendDocumentCalls[0].Caption != "" {
+		t.Fatal("expected no captions with several media items")
+	}
+}
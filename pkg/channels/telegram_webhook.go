@@ -0,0 +1,155 @@
+package channels
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	TelegramModePolling = "polling"
+	TelegramModeWebhook = "webhook"
+
+	// telegramSecretHeader carries the secret_token set with setWebhook.
+	telegramSecretHeader       = "X-Telegram-Bot-Api-Secret-Token"
+	telegramDefaultWebhookPath = "/telegram/webhook"
+	// Updates are small JSON documents; media is fetched separately.
+	telegramMaxUpdateBytes = 1 << 20
+)
+
+// WebhookEnabled reports whether the channel receives updates by webhook
+// instead of long polling.
+func (c *TelegramChannel) WebhookEnabled() bool {
+	return strings.EqualFold(strings.TrimSpace(c.config.Mode), TelegramModeWebhook)
+}
+
+// WebhookPath is the HTTP path Telegram posts updates to: the path of
+// webhook_url, or /telegram/webhook when the URL has none.
+func (c *TelegramChannel) WebhookPath() string {
+	u, err := url.Parse(strings.TrimSpace(c.config.WebhookURL))
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return telegramDefaultWebhookPath
+	}
+	return u.Path
+}
+
+// WebhookHandler serves Telegram webhook updates. Requests without the
+// configured secret token are rejected before the body is read. Mount it on
+// the gateway health server when webhook_listen is empty.
+func (c *TelegramChannel) WebhookHandler() http.Handler {
+	return http.HandlerFunc(c.serveWebhook)
+}
+
+func (c *TelegramChannel) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get(telegramSecretHeader)
+	if c.webhookSecret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(c.webhookSecret)) != 1 {
+		logger.WarnCF("telegram", "Rejected webhook request with invalid secret token", map[string]interface{}{
+			"remote_addr": r.RemoteAddr,
+		})
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update telego.Update
+	if err := json.NewDecoder(io.LimitReader(r.Body, telegramMaxUpdateBytes)).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+	if update.Message != nil {
+		c.handleMessage(c.runContext(), update)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// runContext is the context the channel was started with, so webhook updates
+// are handled like polled ones rather than with the request's context, which
+// ends when the handler returns.
+func (c *TelegramChannel) runContext() context.Context {
+	if ctx, ok := c.webhookCtx.Load().(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+func (c *TelegramChannel) startWebhook(ctx context.Context) error {
+	logger.InfoC("telegram", "Starting Telegram bot (webhook mode)...")
+	c.webhookCtx.Store(ctx)
+
+	if listen := strings.TrimSpace(c.config.WebhookListen); listen != "" {
+		ln, err := net.Listen("tcp", listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen for telegram webhook on %s: %w", listen, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle(c.WebhookPath(), c.WebhookHandler())
+		c.webhookServer = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func(srv *http.Server) {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.ErrorCF("telegram", "Webhook server stopped", map[string]interface{}{
+					"addr":  listen,
+					"error": err.Error(),
+				})
+			}
+		}(c.webhookServer)
+	}
+
+	err := c.bot.SetWebhook(ctx, &telego.SetWebhookParams{
+		URL:            strings.TrimSpace(c.config.WebhookURL),
+		SecretToken:    c.webhookSecret,
+		AllowedUpdates: []string{"message"},
+	})
+	if err != nil {
+		c.stopWebhookServer(ctx)
+		return fmt.Errorf("failed to register telegram webhook: %w", err)
+	}
+
+	c.setRunning(true)
+	logger.InfoCF("telegram", "Telegram bot connected", map[string]interface{}{
+		"username": c.bot.Username(),
+		"path":     c.WebhookPath(),
+	})
+	return nil
+}
+
+func (c *TelegramChannel) stopWebhookServer(ctx context.Context) {
+	if c.webhookServer == nil {
+		return
+	}
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := c.webhookServer.Shutdown(shutdownCtx); err != nil {
+		logger.WarnCF("telegram", "Webhook server shutdown failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	c.webhookServer = nil
+}
+
+// newWebhookSecret returns a random token in the charset Telegram allows for
+// secret_token.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate telegram webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,129 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const sampleWebhookUpdate = `{
+	"update_id": 1001,
+	"message": {
+		"message_id": 7,
+		"date": 1700000000,
+		"from": {"id": 55, "is_bot": false, "first_name": "Ann", "username": "ann"},
+		"chat": {"id": 123, "type": "private"},
+		"text": "hello over webhook"
+	}
+}`
+
+func newTestWebhookChannel(bot telegramBot) *TelegramChannel {
+	ch := newTestTelegramChannel(bot)
+	ch.config = config.TelegramConfig{
+		Mode:       TelegramModeWebhook,
+		WebhookURL: "https://bot.example.com/hooks/tg",
+	}
+	ch.webhookSecret = "s3cret_token"
+	return ch
+}
+
+func postWebhook(h http.Handler, secret, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/hooks/tg", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(telegramSecretHeader, secret)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTelegramWebhook_PublishesInboundUpdate(t *testing.T) {
+	ch := newTestWebhookChannel(newMockBot())
+
+	rec := postWebhook(ch.WebhookHandler(), "s3cret_token", sampleWebhookUpdate)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := ch.bus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.Channel != "telegram" || msg.ChatID != "123" || msg.Content != "hello over webhook" {
+		t.Fatalf("unexpected inbound message: %+v", msg)
+	}
+}
+
+func TestTelegramWebhook_RejectsBadSecret(t *testing.T) {
+	ch := newTestWebhookChannel(newMockBot())
+	h := ch.WebhookHandler()
+
+	for _, secret := range []string{"", "wrong"} {
+		if rec := postWebhook(h, secret, sampleWebhookUpdate); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("secret %q: status = %d, want 401", secret, rec.Code)
+		}
+	}
+
+	// Without a configured secret nothing is accepted.
+	ch.webhookSecret = ""
+	if rec := postWebhook(h, "", sampleWebhookUpdate); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("empty configured secret: status = %d, want 401", rec.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if msg, ok := ch.bus.ConsumeInbound(ctx); ok {
+		t.Fatalf("rejected request published %+v", msg)
+	}
+}
+
+func TestTelegramWebhook_RejectsMalformedRequests(t *testing.T) {
+	ch := newTestWebhookChannel(newMockBot())
+	h := ch.WebhookHandler()
+
+	if rec := postWebhook(h, "s3cret_token", "{not json"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body: status = %d, want 400", rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hooks/tg", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestTelegramWebhook_StartRegistersWebhook(t *testing.T) {
+	mock := newMockBot()
+	ch := newTestWebhookChannel(mock)
+	ch.setRunning(false)
+
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !ch.IsRunning() {
+		t.Fatal("expected channel to be running")
+	}
+	if got := ch.WebhookPath(); got != "/hooks/tg" {
+		t.Fatalf("WebhookPath() = %q", got)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.setWebhookCalls) != 1 {
+		t.Fatalf("SetWebhook calls = %d, want 1", len(mock.setWebhookCalls))
+	}
+	params := mock.setWebhookCalls[0]
+	if params.URL != "https://bot.example.com/hooks/tg" || params.SecretToken != "s3cret_token" {
+		t.Fatalf("unexpected SetWebhook params: %+v", params)
+	}
+	if mock.deleteWebhookCalls != 0 {
+		t.Fatal("webhook mode must not delete the webhook")
+	}
+}
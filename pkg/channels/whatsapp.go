@@ -2,8 +2,14 @@ package channels
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +21,15 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+const (
+	whatsappReconnectBaseDelay = 1 * time.Second
+	whatsappReconnectMaxDelay  = 60 * time.Second
+
+	// whatsappMaxMediaBytes matches WhatsApp's limit for images and video;
+	// larger files are skipped rather than sent as a huge bridge frame.
+	whatsappMaxMediaBytes = 16 << 20
+)
+
 type WhatsAppChannel struct {
 	*BaseChannel
 	conn      *websocket.Conn
@@ -22,39 +37,49 @@ type WhatsAppChannel struct {
 	url       string
 	mu        sync.Mutex
 	connected bool
+	cancel    context.CancelFunc
+
+	reconnectBaseDelay time.Duration
+	reconnectMaxDelay  time.Duration
 }
 
 func NewWhatsAppChannel(cfg config.WhatsAppConfig, bus *bus.MessageBus) (*WhatsAppChannel, error) {
 	base := NewBaseChannel("whatsapp", cfg, bus, cfg.AllowFrom)
+	base.SetRateLimit(cfg.RateLimitPerMinute)
+	base.SetDedupWindow(cfg.DedupWindowSeconds)
+	base.SetGroupSessionScope(cfg.GroupSessionScope)
+	base.SetChatAllowList(cfg.AllowChats, cfg.AllowMode)
 
 	return &WhatsAppChannel{
-		BaseChannel: base,
-		config:      cfg,
-		url:         cfg.BridgeURL,
-		connected:   false,
+		BaseChannel:        base,
+		config:             cfg,
+		url:                cfg.BridgeURL,
+		connected:          false,
+		reconnectBaseDelay: whatsappReconnectBaseDelay,
+		reconnectMaxDelay:  whatsappReconnectMaxDelay,
 	}, nil
 }
 
 func (c *WhatsAppChannel) Start(ctx context.Context) error {
 	logger.InfoCF("whatsapp", "Starting WhatsApp channel", map[string]interface{}{"url": c.url})
 
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = 10 * time.Second
-
-	conn, _, err := dialer.Dial(c.url, nil)
+	conn, err := c.dial()
 	if err != nil {
 		return fmt.Errorf("failed to connect to WhatsApp bridge: %w", err)
 	}
 
+	runCtx, cancel := context.WithCancel(ctx)
+
 	c.mu.Lock()
 	c.conn = conn
 	c.connected = true
+	c.cancel = cancel
 	c.mu.Unlock()
 
 	c.setRunning(true)
 	logger.InfoCF("whatsapp", "WhatsApp channel connected", nil)
 
-	go c.listen(ctx)
+	go c.listen(runCtx)
 
 	return nil
 }
@@ -65,6 +90,13 @@ func (c *WhatsAppChannel) Stop(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	// Cancel first so the listener doesn't treat the close as a dropped
+	// connection and start redialing.
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+
 	if c.conn != nil {
 		if err := c.conn.Close(); err != nil {
 			logger.ErrorCF("whatsapp", "Error closing WhatsApp connection", map[string]interface{}{"error": err.Error()})
@@ -82,65 +114,192 @@ func (c *WhatsAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.conn == nil {
+	if c.conn == nil || !c.connected {
+		if c.IsRunning() {
+			return fmt.Errorf("whatsapp bridge disconnected (reconnecting)")
+		}
 		return fmt.Errorf("whatsapp connection not established")
 	}
 
-	payload := map[string]interface{}{
-		"type":    "message",
-		"to":      msg.ChatID,
-		"content": msg.Content,
+	if msg.Content != "" || len(msg.Media) == 0 {
+		if err := c.writeJSONLocked(map[string]interface{}{
+			"type":    "message",
+			"to":      msg.ChatID,
+			"content": WhatsAppFormatter.Format(msg.Content),
+		}); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	for _, mediaPath := range msg.Media {
+		payload, err := whatsappMediaPayload(msg.ChatID, mediaPath)
+		if err != nil {
+			logger.ErrorCF("whatsapp", "Failed to prepare media file", map[string]interface{}{
+				"path":  mediaPath,
+				"error": err.Error(),
+			})
+			continue
+		}
+		if err := c.writeJSONLocked(payload); err != nil {
+			return fmt.Errorf("failed to send media: %w", err)
+		}
 	}
 
+	return nil
+}
+
+func (c *WhatsAppChannel) writeJSONLocked(payload map[string]interface{}) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
 
-	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+// whatsappMediaPayload reads path into a bridge "media" frame. Images are sent
+// as photos and everything else as documents, as on Telegram.
+func whatsappMediaPayload(chatID, path string) (map[string]interface{}, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() > whatsappMaxMediaBytes {
+		return nil, fmt.Errorf("file is %d bytes, limit is %d", st.Size(), whatsappMaxMediaBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	mediaType := "document"
+	if isImageFile(path) {
+		mediaType = "image"
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return map[string]interface{}{
+		"type":       "media",
+		"to":         chatID,
+		"media_type": mediaType,
+		"mimetype":   mimeType,
+		"filename":   filepath.Base(path),
+		"data":       base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+func (c *WhatsAppChannel) dial() (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.HandshakeTimeout = 10 * time.Second
+
+	conn, _, err := dialer.Dial(c.url, nil)
+	return conn, err
 }
 
 func (c *WhatsAppChannel) listen(ctx context.Context) {
 	for {
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
 			return
-		default:
-			c.mu.Lock()
-			conn := c.conn
-			c.mu.Unlock()
+		}
 
-			if conn == nil {
-				time.Sleep(1 * time.Second)
-				continue
-			}
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
 
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				logger.ErrorCF("whatsapp", "WhatsApp read error", map[string]interface{}{"error": err.Error()})
-				time.Sleep(2 * time.Second)
-				continue
+		if conn == nil {
+			if !c.reconnect(ctx) {
+				return
 			}
+			continue
+		}
 
-			var msg map[string]interface{}
-			if err := json.Unmarshal(message, &msg); err != nil {
-				logger.ErrorCF("whatsapp", "Failed to unmarshal WhatsApp message", map[string]interface{}{"error": err.Error()})
-				continue
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
-
-			msgType, ok := msg["type"].(string)
-			if !ok {
-				continue
+			logger.WarnCF("whatsapp", "WhatsApp bridge connection lost", map[string]interface{}{"error": err.Error()})
+			c.dropConn(conn)
+			if !c.reconnect(ctx) {
+				return
 			}
+			continue
+		}
 
-			if msgType == "message" {
-				c.handleIncomingMessage(msg)
+		var msg map[string]interface{}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			logger.ErrorCF("whatsapp", "Failed to unmarshal WhatsApp message", map[string]interface{}{"error": err.Error()})
+			continue
+		}
+
+		msgType, ok := msg["type"].(string)
+		if !ok {
+			continue
+		}
+
+		if msgType == "message" {
+			c.handleIncomingMessage(msg)
+		}
+	}
+}
+
+// dropConn closes conn and marks the channel disconnected, unless conn has
+// already been replaced.
+func (c *WhatsAppChannel) dropConn(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return
+	}
+	_ = conn.Close()
+	c.conn = nil
+	c.connected = false
+}
+
+// reconnect redials the bridge with capped exponential backoff until it
+// succeeds or ctx is cancelled. It reports whether a connection was made.
+func (c *WhatsAppChannel) reconnect(ctx context.Context) bool {
+	delay := c.reconnectBaseDelay
+	for attempt := 1; ; attempt++ {
+		logger.InfoCF("whatsapp", "Reconnecting to WhatsApp bridge", map[string]interface{}{
+			"attempt":  attempt,
+			"delay_ms": delay.Milliseconds(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		conn, err := c.dial()
+		if err != nil {
+			logger.WarnCF("whatsapp", "WhatsApp bridge reconnect failed", map[string]interface{}{
+				"attempt": attempt,
+				"error":   err.Error(),
+			})
+			delay *= 2
+			if delay > c.reconnectMaxDelay {
+				delay = c.reconnectMaxDelay
 			}
+			continue
 		}
+
+		c.mu.Lock()
+		if ctx.Err() != nil {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return false
+		}
+		c.conn = conn
+		c.connected = true
+		c.mu.Unlock()
+
+		logger.InfoCF("whatsapp", "WhatsApp bridge reconnected", map[string]interface{}{"attempt": attempt})
+		return true
 	}
 }
 
@@ -177,6 +336,8 @@ func (c *WhatsAppChannel) handleIncomingMessage(msg map[string]interface{}) {
 	if userName, ok := msg["from_name"].(string); ok {
 		metadata["user_name"] = userName
 	}
+	// Group chats have JIDs ending in @g.us.
+	metadata["is_group"] = fmt.Sprintf("%t", strings.HasSuffix(chatID, "@g.us"))
 
 	logger.DebugCF("whatsapp", "Received message", map[string]interface{}{"sender": senderID, "preview": utils.Truncate(content, 50)})
 
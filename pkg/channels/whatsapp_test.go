@@ -0,0 +1,211 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestWhatsAppChannel(t *testing.T, url string) (*WhatsAppChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWhatsAppChannel(config.WhatsAppConfig{Enabled: true, BridgeURL: url}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWhatsAppChannel: %v", err)
+	}
+	ch.reconnectBaseDelay = 10 * time.Millisecond
+	ch.reconnectMaxDelay = 40 * time.Millisecond
+	return ch, msgBus
+}
+
+func TestWhatsAppChannel_ReconnectsAfterBridgeDrop(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var connections atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		n := connections.Add(1)
+		msg := fmt.Sprintf(`{"type":"message","from":"123","chat":"123","content":"message %d"}`, n)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return
+		}
+		if n == 1 {
+			// Drop the first connection right after delivering one message.
+			return
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ch, msgBus := newTestWhatsAppChannel(t, "ws"+strings.TrimPrefix(server.URL, "http"))
+	defer msgBus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop(context.Background())
+
+	for _, want := range []string{"message 1", "message 2"} {
+		recvCtx, recvCancel := context.WithTimeout(context.Background(), 3*time.Second)
+		msg, ok := msgBus.ConsumeInbound(recvCtx)
+		recvCancel()
+		if !ok {
+			t.Fatalf("timed out waiting for %q", want)
+		}
+		if msg.Content != want {
+			t.Fatalf("inbound content = %q, want %q", msg.Content, want)
+		}
+	}
+	if got := connections.Load(); got != 2 {
+		t.Fatalf("expected 2 bridge connections, got %d", got)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "hi"}); err != nil {
+		t.Fatalf("Send after reconnect: %v", err)
+	}
+}
+
+func TestWhatsAppChannel_SendWhileDisconnectedReturnsError(t *testing.T) {
+	ch, msgBus := newTestWhatsAppChannel(t, "ws://127.0.0.1:1")
+	defer msgBus.Close()
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "not established") {
+		t.Fatalf("expected not-established error, got %v", err)
+	}
+
+	ch.setRunning(true)
+	err = ch.Send(context.Background(), bus.OutboundMessage{ChatID: "123", Content: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "disconnected") {
+		t.Fatalf("expected disconnected error, got %v", err)
+	}
+}
+
+func TestWhatsAppChannel_StopEndsReconnectLoop(t *testing.T) {
+	ch, msgBus := newTestWhatsAppChannel(t, "ws://127.0.0.1:1")
+	defer msgBus.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch.cancel = cancel
+
+	done := make(chan struct{})
+	go func() {
+		ch.listen(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	ch.Stop(context.Background())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("listen did not exit after Stop")
+	}
+}
+
+func TestWhatsAppChannel_SendWritesTextThenMediaPayloads(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "chart.png")
+	docPath := filepath.Join(dir, "report.pdf")
+	if err := os.WriteFile(imagePath, []byte("png-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(docPath, []byte("pdf-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	frames := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame map[string]interface{}
+			if err := json.Unmarshal(data, &frame); err != nil {
+				t.Errorf("bridge received invalid JSON: %v", err)
+				return
+			}
+			frames <- frame
+		}
+	}))
+	defer server.Close()
+
+	ch, msgBus := newTestWhatsAppChannel(t, "ws"+strings.TrimPrefix(server.URL, "http"))
+	defer msgBus.Close()
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop(context.Background())
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{
+		ChatID:  "123@s.whatsapp.net",
+		Content: "Here you go",
+		Media:   []string{imagePath, filepath.Join(dir, "missing.txt"), docPath},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var got []map[string]interface{}
+	for len(got) < 3 {
+		select {
+		case f := <-frames:
+			got = append(got, f)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for frames, got %v", got)
+		}
+	}
+
+	if got[0]["type"] != "message" || got[0]["content"] != "Here you go" {
+		t.Fatalf("first frame should be the text, got %v", got[0])
+	}
+	wants := []struct {
+		mediaType, filename, data string
+	}{
+		{"image", "chart.png", "png-bytes"},
+		{"document", "report.pdf", "pdf-bytes"},
+	}
+	for i, want := range wants {
+		f := got[i+1]
+		decoded, _ := base64.StdEncoding.DecodeString(fmt.Sprint(f["data"]))
+		if f["type"] != "media" || f["to"] != "123@s.whatsapp.net" || f["media_type"] != want.mediaType ||
+			f["filename"] != want.filename || string(decoded) != want.data {
+			t.Fatalf("media frame %d = %v, want %+v", i, f, want)
+		}
+	}
+	if got[1]["mimetype"] != "image/png" {
+		t.Fatalf("image mimetype = %v", got[1]["mimetype"])
+	}
+}
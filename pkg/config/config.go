@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/caarlos0/env/v11"
@@ -14,24 +17,106 @@ type Config struct {
 	Channels  ChannelsConfig  `json:"channels"`
 	Providers ProvidersConfig `json:"providers"`
 	Tools     ToolsConfig     `json:"tools"`
+	Gateway   GatewayConfig   `json:"gateway"`
+	Logging   LoggingConfig   `json:"logging"`
 	mu        sync.RWMutex
 }
 
+// GatewayConfig configures the long-running gateway process.
+type GatewayConfig struct {
+	// HealthAddr is the listen address for the /healthz and /status HTTP
+	// endpoints (e.g. "127.0.0.1:18790"). Empty disables the server.
+	HealthAddr string `json:"health_addr" env:"PICOCLAW_GATEWAY_HEALTH_ADDR"`
+	// EventsSocket is a Unix socket path that streams lifecycle events as
+	// JSON lines to connected clients. Empty disables the event stream.
+	EventsSocket string `json:"events_socket" env:"PICOCLAW_GATEWAY_EVENTS_SOCKET"`
+	// StartupCheck pings the provider and checks channels, the memory DB
+	// and the cron store once the gateway has started, logging the results
+	// and reporting them in /status. Off by default: the provider ping is a
+	// billed request on every start.
+	StartupCheck bool `json:"startup_check" env:"PICOCLAW_GATEWAY_STARTUP_CHECK"`
+	// StrictStartup makes a failed startup check stop the gateway.
+	StrictStartup bool `json:"strict_startup" env:"PICOCLAW_GATEWAY_STRICT_STARTUP"`
+	// MediaDir is where channel attachments are downloaded. Empty uses
+	// <workspace>/tmp/media; "~" is expanded.
+	MediaDir string `json:"media_dir" env:"PICOCLAW_GATEWAY_MEDIA_DIR"`
+	// MediaMaxAgeMinutes is how old a file in MediaDir may get before the
+	// periodic sweeper deletes it, catching downloads whose per-message
+	// cleanup never ran (0 = never sweep).
+	MediaMaxAgeMinutes int `json:"media_max_age_minutes" env:"PICOCLAW_GATEWAY_MEDIA_MAX_AGE_MINUTES"`
+}
+
+// LoggingConfig configures the optional JSON-lines log file. Logs always go
+// to stdout as well.
+type LoggingConfig struct {
+	// Path of the log file; empty disables file logging. "~" is expanded.
+	Path string `json:"path" env:"PICOCLAW_LOGGING_PATH"`
+	// Rotate once the file would exceed this size (0 = never rotate).
+	MaxSizeMB int `json:"max_size_mb" env:"PICOCLAW_LOGGING_MAX_SIZE_MB"`
+	// Rotated files kept next to the log as path.1 ... path.N.
+	MaxFiles int `json:"max_files" env:"PICOCLAW_LOGGING_MAX_FILES"`
+	// Characters of content quoted in log lines before it is cut with a
+	// "…(+N more chars)" marker (0 = built-in default).
+	MessagePreviewChars  int `json:"message_preview_chars" env:"PICOCLAW_LOGGING_MESSAGE_PREVIEW_CHARS"`
+	ResponsePreviewChars int `json:"response_preview_chars" env:"PICOCLAW_LOGGING_RESPONSE_PREVIEW_CHARS"`
+	ToolPreviewChars     int `json:"tool_preview_chars" env:"PICOCLAW_LOGGING_TOOL_PREVIEW_CHARS"`
+	// ProviderRecordingDir, when set, saves every LLM request and response
+	// of HTTP providers there as one JSON file per call ("~" is expanded).
+	ProviderRecordingDir string `json:"provider_recording_dir" env:"PICOCLAW_LOGGING_PROVIDER_RECORDING_DIR"`
+}
+
+// EventsSocketPath returns the configured events socket path with "~"
+// expanded.
+func (c *Config) EventsSocketPath() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return expandHome(strings.TrimSpace(c.Gateway.EventsSocket))
+}
+
+// ProviderRecordingPath returns logging.provider_recording_dir with "~"
+// expanded, or "" when recording is off.
+func (c *Config) ProviderRecordingPath() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return expandHome(strings.TrimSpace(c.Logging.ProviderRecordingDir))
+}
+
+// LogPath returns the configured log file path with "~" expanded.
+func (c *Config) LogPath() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return expandHome(c.Logging.Path)
+}
+
+// MaxAutoContinue is the hard cap on agents.defaults.auto_continue_max.
+const MaxAutoContinue = 10
+
 type AgentsConfig struct {
 	Defaults AgentDefaults `json:"defaults"`
 }
 
 type AgentDefaults struct {
 	Workspace                   string   `json:"workspace" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	WorkspaceIsolation          string   `json:"workspace_isolation" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE_ISOLATION"`
 	Model                       string   `json:"model" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
 	FallbackModels              []string `json:"fallback_models" env:"PICOCLAW_AGENTS_DEFAULTS_FALLBACK_MODELS"`
+	FallbackOnRefusal           bool     `json:"fallback_on_refusal" env:"PICOCLAW_AGENTS_DEFAULTS_FALLBACK_ON_REFUSAL"`
 	MaxTokens                   int      `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	ContextWindowTokens         int      `json:"context_window_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_CONTEXT_WINDOW_TOKENS"`
 	Temperature                 float64  `json:"temperature" env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	Seed                        *int     `json:"seed,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_SEED"`
 	AnthropicCache              bool     `json:"anthropic_cache" env:"PICOCLAW_AGENTS_DEFAULTS_ANTHROPIC_CACHE"`
 	AnthropicCacheTTL           string   `json:"anthropic_cache_ttl" env:"PICOCLAW_AGENTS_DEFAULTS_ANTHROPIC_CACHE_TTL"`
 	MaxToolIterations           int      `json:"max_tool_iterations" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	ToolLoopThreshold           int      `json:"tool_loop_threshold" env:"PICOCLAW_AGENTS_DEFAULTS_TOOL_LOOP_THRESHOLD"`
+	AutoContinueMax             int      `json:"auto_continue_max" env:"PICOCLAW_AGENTS_DEFAULTS_AUTO_CONTINUE_MAX"`
+	AutoContinueInteractive     bool     `json:"auto_continue_interactive" env:"PICOCLAW_AGENTS_DEFAULTS_AUTO_CONTINUE_INTERACTIVE"`
+	BestOfN                     int      `json:"best_of_n" env:"PICOCLAW_AGENTS_DEFAULTS_BEST_OF_N"`
+	BestOfNJudgeModel           string   `json:"best_of_n_judge_model" env:"PICOCLAW_AGENTS_DEFAULTS_BEST_OF_N_JUDGE_MODEL"`
+	MaxConcurrentMessages       int      `json:"max_concurrent_messages" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENT_MESSAGES"`
 	LLMTimeoutSeconds           int      `json:"llm_timeout_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_LLM_TIMEOUT_SECONDS"`
+	LLMTurnMaxRetries           int      `json:"llm_turn_max_retries" env:"PICOCLAW_AGENTS_DEFAULTS_LLM_TURN_MAX_RETRIES"`
+	LLMTurnMaxRetryWaitSeconds  int      `json:"llm_turn_max_retry_wait_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_LLM_TURN_MAX_RETRY_WAIT_SECONDS"`
 	ToolTimeoutSeconds          int      `json:"tool_timeout_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_TOOL_TIMEOUT_SECONDS"`
 	MaxParallelToolCalls        int      `json:"max_parallel_tool_calls" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOL_CALLS"`
 	RequestMaxMessages          int      `json:"request_max_messages" env:"PICOCLAW_AGENTS_DEFAULTS_REQUEST_MAX_MESSAGES"`
@@ -40,7 +125,48 @@ type AgentDefaults struct {
 	RequestMaxToolMessageChars  int      `json:"request_max_tool_message_chars" env:"PICOCLAW_AGENTS_DEFAULTS_REQUEST_MAX_TOOL_MESSAGE_CHARS"`
 	SubagentMaxTasks            int      `json:"subagent_max_tasks" env:"PICOCLAW_AGENTS_DEFAULTS_SUBAGENT_MAX_TASKS"`
 	SubagentCompletedTTLSeconds int      `json:"subagent_completed_ttl_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_SUBAGENT_COMPLETED_TTL_SECONDS"`
+	SubagentMaxDepth            int      `json:"subagent_max_depth" env:"PICOCLAW_AGENTS_DEFAULTS_SUBAGENT_MAX_DEPTH"`
+	SubagentMaxConcurrent       int      `json:"subagent_max_concurrent" env:"PICOCLAW_AGENTS_DEFAULTS_SUBAGENT_MAX_CONCURRENT"`
+	SessionMaxInMemory          int      `json:"session_max_in_memory" env:"PICOCLAW_AGENTS_DEFAULTS_SESSION_MAX_IN_MEMORY"`
+	SessionIdleTTLSeconds       int      `json:"session_idle_ttl_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_SESSION_IDLE_TTL_SECONDS"`
 	EchoToolCalls               bool     `json:"echo_tool_calls" env:"PICOCLAW_AGENTS_DEFAULTS_ECHO_TOOL_CALLS"`
+	EchoInterimText             bool     `json:"echo_interim_text" env:"PICOCLAW_AGENTS_DEFAULTS_ECHO_INTERIM_TEXT"`
+	AuditTools                  bool     `json:"audit_tools" env:"PICOCLAW_AGENTS_DEFAULTS_AUDIT_TOOLS"`
+	StatusDelaySeconds          int      `json:"status_delay_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_STATUS_DELAY_SECONDS"`
+	StatusMessages              []string `json:"status_messages" env:"PICOCLAW_AGENTS_DEFAULTS_STATUS_MESSAGES"`
+	ToolProgressIntervalSeconds int      `json:"tool_progress_interval_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_TOOL_PROGRESS_INTERVAL_SECONDS"`
+	EmptyResponse               string   `json:"empty_response" env:"PICOCLAW_AGENTS_DEFAULTS_EMPTY_RESPONSE"`
+	// PromptIncludes lists workspace files, in order, whose contents are added
+	// to the system prompt. Files are re-read when they change.
+	PromptIncludes []string `json:"prompt_includes" env:"PICOCLAW_AGENTS_DEFAULTS_PROMPT_INCLUDES"`
+	// ChannelPrompts adds per-channel system prompt text, keyed by channel name.
+	ChannelPrompts map[string]ChannelPromptConfig `json:"channel_prompts,omitempty"`
+	// ContextWindows overrides the built-in model -> context window table,
+	// keyed by model name or name fragment.
+	ContextWindows map[string]int `json:"context_windows,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_CONTEXT_WINDOWS"`
+	// SubagentEvents overrides how subagent reports reach the user, keyed
+	// by event ("progress", "note", "warning", "cancelled", "complete",
+	// "failed") with a SubagentEvent* policy as value.
+	SubagentEvents map[string]string `json:"subagent_events,omitempty"`
+}
+
+// Subagent event policies for agents.defaults.subagent_events.
+const (
+	// SubagentEventForward shows the event to the user: updates are sent to
+	// the chat as they arrive, and final reports go through the agent, which
+	// answers the user.
+	SubagentEventForward = "forward"
+	// SubagentEventInternal only records the event in the session history.
+	SubagentEventInternal = "internal"
+	// SubagentEventSuppress drops the event.
+	SubagentEventSuppress = "suppress"
+)
+
+// ChannelPromptConfig is appended to the base system prompt for one channel,
+// or replaces it entirely when Replace is set.
+type ChannelPromptConfig struct {
+	Prompt  string `json:"prompt"`
+	Replace bool   `json:"replace"`
 }
 
 type ChannelsConfig struct {
@@ -52,66 +178,158 @@ type ChannelsConfig struct {
 	QQ        QQConfig        `json:"qq"`
 	DingTalk  DingTalkConfig  `json:"dingtalk"`
 	Slack     SlackConfig     `json:"slack"`
+	IRC       IRCConfig       `json:"irc"`
 }
 
 type WhatsAppConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_WHATSAPP_ENABLED"`
-	BridgeURL string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_WHATSAPP_BRIDGE_URL"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_WHATSAPP_ENABLED"`
+	BridgeURL          string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_WHATSAPP_BRIDGE_URL"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_WHATSAPP_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_WHATSAPP_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_WHATSAPP_GROUP_SESSION_SCOPE"`
 }
 
 type DeltaChatConfig struct {
-	Enabled       bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DELTACHAT_ENABLED"`
-	BridgeURL     string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_DELTACHAT_BRIDGE_URL"`
-	AllowFrom     []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DELTACHAT_ALLOW_FROM"`
-	AckReaction   string   `json:"ack_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_ACK_REACTION"`
-	DoneReaction  string   `json:"done_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_DONE_REACTION"`
-	ErrorReaction string   `json:"error_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_ERROR_REACTION"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DELTACHAT_ENABLED"`
+	BridgeURL          string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_DELTACHAT_BRIDGE_URL"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DELTACHAT_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_DELTACHAT_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_DELTACHAT_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_DELTACHAT_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_DELTACHAT_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_DELTACHAT_GROUP_SESSION_SCOPE"`
+	AckReaction        string   `json:"ack_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_ACK_REACTION"`
+	DoneReaction       string   `json:"done_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_DONE_REACTION"`
+	ErrorReaction      string   `json:"error_reaction" env:"PICOCLAW_CHANNELS_DELTACHAT_ERROR_REACTION"`
 	// Forward incoming DeltaChat reactions as synthetic inbound messages.
 	// Disabled by default to avoid response loops when auto-reactions are enabled.
 	ForwardReactions bool `json:"forward_reactions" env:"PICOCLAW_CHANNELS_DELTACHAT_FORWARD_REACTIONS"`
 }
 
 type TelegramConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
-	Token     string   `json:"token" env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
+	Token              string   `json:"token" env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_TELEGRAM_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_TELEGRAM_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_TELEGRAM_GROUP_SESSION_SCOPE"`
+	// Reply with a synthesized voice note for every message, not only when the
+	// user spoke first. Requires tools.tts to be enabled.
+	VoiceReplies bool `json:"voice_replies" env:"PICOCLAW_CHANNELS_TELEGRAM_VOICE_REPLIES"`
+	// Emoji reaction set on incoming messages instead of the typing indicator,
+	// replaced by DoneReaction once the reply is sent. Empty disables each.
+	AckReaction  string `json:"ack_reaction" env:"PICOCLAW_CHANNELS_TELEGRAM_ACK_REACTION"`
+	DoneReaction string `json:"done_reaction" env:"PICOCLAW_CHANNELS_TELEGRAM_DONE_REACTION"`
+	// When the user replies to an earlier message, send the answer as a
+	// Telegram reply to theirs so the thread stays visually linked.
+	ThreadReplies bool `json:"thread_replies" env:"PICOCLAW_CHANNELS_TELEGRAM_THREAD_REPLIES"`
+	// Attachments larger than this are not downloaded; the message gets a
+	// "[file too large]" placeholder instead (0 = no limit).
+	MaxMediaSizeMB int `json:"max_media_size_mb" env:"PICOCLAW_CHANNELS_TELEGRAM_MAX_MEDIA_SIZE_MB"`
+	// Only attachments matching these MIME types ("image/*", "application/pdf")
+	// or extensions (".pdf") are downloaded. Empty allows all.
+	AllowedMediaTypes []string `json:"allowed_media_types" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOWED_MEDIA_TYPES"`
+	// Mode is how updates arrive: "polling" (default) or "webhook". Webhook
+	// mode registers WebhookURL with Telegram and serves it on WebhookListen,
+	// or on the gateway health server when WebhookListen is empty.
+	Mode          string `json:"mode" env:"PICOCLAW_CHANNELS_TELEGRAM_MODE"`
+	WebhookURL    string `json:"webhook_url" env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_URL"`
+	WebhookListen string `json:"webhook_listen" env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_LISTEN"`
+	// WebhookSecret is sent by Telegram with every webhook request. Empty
+	// generates a random secret on each start.
+	WebhookSecret string `json:"webhook_secret" env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_SECRET"`
 }
 
 type FeishuConfig struct {
-	Enabled           bool     `json:"enabled" env:"PICOCLAW_CHANNELS_FEISHU_ENABLED"`
-	AppID             string   `json:"app_id" env:"PICOCLAW_CHANNELS_FEISHU_APP_ID"`
-	AppSecret         string   `json:"app_secret" env:"PICOCLAW_CHANNELS_FEISHU_APP_SECRET"`
-	EncryptKey        string   `json:"encrypt_key" env:"PICOCLAW_CHANNELS_FEISHU_ENCRYPT_KEY"`
-	VerificationToken string   `json:"verification_token" env:"PICOCLAW_CHANNELS_FEISHU_VERIFICATION_TOKEN"`
-	AllowFrom         []string `json:"allow_from" env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_FEISHU_ENABLED"`
+	AppID              string   `json:"app_id" env:"PICOCLAW_CHANNELS_FEISHU_APP_ID"`
+	AppSecret          string   `json:"app_secret" env:"PICOCLAW_CHANNELS_FEISHU_APP_SECRET"`
+	EncryptKey         string   `json:"encrypt_key" env:"PICOCLAW_CHANNELS_FEISHU_ENCRYPT_KEY"`
+	VerificationToken  string   `json:"verification_token" env:"PICOCLAW_CHANNELS_FEISHU_VERIFICATION_TOKEN"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_FEISHU_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_FEISHU_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_FEISHU_GROUP_SESSION_SCOPE"`
 }
 
 type DiscordConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
-	Token     string   `json:"token" env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
+	Token              string   `json:"token" env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_DISCORD_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_DISCORD_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_DISCORD_GROUP_SESSION_SCOPE"`
 }
 
 type QQConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_QQ_ENABLED"`
-	AppID     string   `json:"app_id" env:"PICOCLAW_CHANNELS_QQ_APP_ID"`
-	AppSecret string   `json:"app_secret" env:"PICOCLAW_CHANNELS_QQ_APP_SECRET"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_QQ_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_QQ_ENABLED"`
+	AppID              string   `json:"app_id" env:"PICOCLAW_CHANNELS_QQ_APP_ID"`
+	AppSecret          string   `json:"app_secret" env:"PICOCLAW_CHANNELS_QQ_APP_SECRET"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_QQ_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_QQ_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_QQ_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_QQ_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_QQ_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_QQ_GROUP_SESSION_SCOPE"`
 }
 
 type DingTalkConfig struct {
-	Enabled      bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DINGTALK_ENABLED"`
-	ClientID     string   `json:"client_id" env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_ID"`
-	ClientSecret string   `json:"client_secret" env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_SECRET"`
-	AllowFrom    []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DINGTALK_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DINGTALK_ENABLED"`
+	ClientID           string   `json:"client_id" env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_ID"`
+	ClientSecret       string   `json:"client_secret" env:"PICOCLAW_CHANNELS_DINGTALK_CLIENT_SECRET"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DINGTALK_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_DINGTALK_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_DINGTALK_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_DINGTALK_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_DINGTALK_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_DINGTALK_GROUP_SESSION_SCOPE"`
 }
 
 type SlackConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_SLACK_ENABLED"`
-	BotToken  string   `json:"bot_token" env:"PICOCLAW_CHANNELS_SLACK_BOT_TOKEN"`
-	AppToken  string   `json:"app_token" env:"PICOCLAW_CHANNELS_SLACK_APP_TOKEN"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_FROM"`
+	Enabled            bool     `json:"enabled" env:"PICOCLAW_CHANNELS_SLACK_ENABLED"`
+	BotToken           string   `json:"bot_token" env:"PICOCLAW_CHANNELS_SLACK_BOT_TOKEN"`
+	AppToken           string   `json:"app_token" env:"PICOCLAW_CHANNELS_SLACK_APP_TOKEN"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_SLACK_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_SLACK_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_SLACK_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_SLACK_GROUP_SESSION_SCOPE"`
+}
+
+type IRCConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_CHANNELS_IRC_ENABLED"`
+	Server  string `json:"server" env:"PICOCLAW_CHANNELS_IRC_SERVER"` // host:port
+	TLS     bool   `json:"tls" env:"PICOCLAW_CHANNELS_IRC_TLS"`
+	Nick    string `json:"nick" env:"PICOCLAW_CHANNELS_IRC_NICK"`
+	// Password is sent as the server password (PASS), which most networks
+	// and bouncers also accept for NickServ identification.
+	Password string   `json:"password" env:"PICOCLAW_CHANNELS_IRC_PASSWORD"`
+	Channels []string `json:"channels" env:"PICOCLAW_CHANNELS_IRC_CHANNELS"`
+	// RequireMention makes the bot answer in channels only when a message
+	// starts with its nick ("picoclaw: hi"). Private messages always count.
+	RequireMention bool `json:"require_mention" env:"PICOCLAW_CHANNELS_IRC_REQUIRE_MENTION"`
+	// MIRCFormatting renders bold and italics with mIRC control codes
+	// instead of stripping them.
+	MIRCFormatting bool `json:"mirc_formatting" env:"PICOCLAW_CHANNELS_IRC_MIRC_FORMATTING"`
+	// LineDelayMS spaces out sent lines after a short burst, so long replies
+	// do not trip the server's flood protection.
+	LineDelayMS        int      `json:"line_delay_ms" env:"PICOCLAW_CHANNELS_IRC_LINE_DELAY_MS"`
+	AllowFrom          []string `json:"allow_from" env:"PICOCLAW_CHANNELS_IRC_ALLOW_FROM"`
+	AllowChats         []string `json:"allow_chats" env:"PICOCLAW_CHANNELS_IRC_ALLOW_CHATS"`
+	AllowMode          string   `json:"allow_mode" env:"PICOCLAW_CHANNELS_IRC_ALLOW_MODE"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute" env:"PICOCLAW_CHANNELS_IRC_RATE_LIMIT_PER_MINUTE"`
+	DedupWindowSeconds int      `json:"dedup_window_seconds" env:"PICOCLAW_CHANNELS_IRC_DEDUP_WINDOW_SECONDS"`
+	GroupSessionScope  string   `json:"group_session_scope" env:"PICOCLAW_CHANNELS_IRC_GROUP_SESSION_SCOPE"`
 }
 
 type ProvidersConfig struct {
@@ -130,6 +348,34 @@ type ProviderConfig struct {
 	APIBase    string                 `json:"api_base" env:"PICOCLAW_PROVIDERS_{{.Name}}_API_BASE"`
 	AuthMethod string                 `json:"auth_method,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_AUTH_METHOD"`
 	Routing    map[string]interface{} `json:"routing,omitempty"`
+	// Referer and Title are sent as HTTP-Referer / X-Title (OpenRouter app attribution).
+	Referer string `json:"referer,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_REFERER"`
+	Title   string `json:"title,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_TITLE"`
+	// ExtraHeaders are added to every request to this provider.
+	ExtraHeaders map[string]string `json:"extra_headers,omitempty"`
+	// Retry policy for failed requests. MaxRetries nil keeps the default (5);
+	// wait values of 0 keep the defaults (1s base, 60s cap).
+	MaxRetries      *int `json:"max_retries,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_MAX_RETRIES"`
+	RetryBaseWaitMS int  `json:"retry_base_wait_ms,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_RETRY_BASE_WAIT_MS"`
+	RetryMaxWaitMS  int  `json:"retry_max_wait_ms,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_RETRY_MAX_WAIT_MS"`
+	// RetryStatusCodes are retried in addition to 429 and 5xx.
+	RetryStatusCodes []int `json:"retry_status_codes,omitempty"`
+	// RetryContentPatterns are regexes; a reply without tool calls whose
+	// trimmed content matches one is treated as a glitch and retried.
+	RetryContentPatterns []string `json:"retry_content_patterns,omitempty"`
+	// RetryAfterJitterMS caps the random extra wait added to a server's
+	// Retry-After hint. nil keeps the default (250ms); 0 disables it.
+	RetryAfterJitterMS *int `json:"retry_after_jitter_ms,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_RETRY_AFTER_JITTER_MS"`
+	// Circuit breaker: after CircuitBreakerThreshold consecutive failed
+	// requests within the window, fail fast for the cooldown. 0 disables it;
+	// window and cooldown of 0 keep the defaults (60s, 30s).
+	CircuitBreakerThreshold       int `json:"circuit_breaker_threshold,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_CIRCUIT_BREAKER_THRESHOLD"`
+	CircuitBreakerWindowSeconds   int `json:"circuit_breaker_window_seconds,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_CIRCUIT_BREAKER_WINDOW_SECONDS"`
+	CircuitBreakerCooldownSeconds int `json:"circuit_breaker_cooldown_seconds,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_CIRCUIT_BREAKER_COOLDOWN_SECONDS"`
+	// RequestTimeoutSeconds bounds each request to this provider, separately
+	// from retry waits, and replaces agents.defaults.llm_timeout_seconds for
+	// its calls. 0 keeps the agent-wide timeout.
+	RequestTimeoutSeconds int `json:"request_timeout_seconds,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_REQUEST_TIMEOUT_SECONDS"`
 }
 
 type WebSearchConfig struct {
@@ -156,6 +402,35 @@ type VisionToolsConfig struct {
 	MaxImages      int    `json:"max_images" env:"PICOCLAW_TOOLS_VISION_MAX_IMAGES"`
 }
 
+type TTSToolsConfig struct {
+	Enabled        bool   `json:"enabled" env:"PICOCLAW_TOOLS_TTS_ENABLED"`
+	Model          string `json:"model" env:"PICOCLAW_TOOLS_TTS_MODEL"`
+	Voice          string `json:"voice" env:"PICOCLAW_TOOLS_TTS_VOICE"`
+	APIKey         string `json:"api_key" env:"PICOCLAW_TOOLS_TTS_API_KEY"`
+	APIBase        string `json:"api_base" env:"PICOCLAW_TOOLS_TTS_API_BASE"`
+	TimeoutSeconds int    `json:"timeout_seconds" env:"PICOCLAW_TOOLS_TTS_TIMEOUT_SECONDS"`
+}
+
+// Transcription backends for voice messages.
+const (
+	TranscriptionBackendAuto    = ""        // groq when providers.groq.api_key is set
+	TranscriptionBackendGroq    = "groq"    // Groq whisper-large-v3
+	TranscriptionBackendOpenAI  = "openai"  // OpenAI-compatible /audio/transcriptions
+	TranscriptionBackendCommand = "command" // local program, e.g. whisper.cpp
+	TranscriptionBackendNone    = "none"
+)
+
+// TranscriptionToolsConfig selects how voice messages are transcribed.
+type TranscriptionToolsConfig struct {
+	Backend string `json:"backend" env:"PICOCLAW_TOOLS_TRANSCRIPTION_BACKEND"`
+	Model   string `json:"model" env:"PICOCLAW_TOOLS_TRANSCRIPTION_MODEL"`
+	APIKey  string `json:"api_key" env:"PICOCLAW_TOOLS_TRANSCRIPTION_API_KEY"`
+	APIBase string `json:"api_base" env:"PICOCLAW_TOOLS_TRANSCRIPTION_API_BASE"`
+	// Command is the program and arguments for the command backend; "{file}"
+	// is replaced with the audio path (appended when absent).
+	Command []string `json:"command" env:"PICOCLAW_TOOLS_TRANSCRIPTION_COMMAND"`
+}
+
 type ToolPolicyConfig struct {
 	Enabled  bool     `json:"enabled" env:"PICOCLAW_TOOLS_POLICY_ENABLED"`
 	SafeMode bool     `json:"safe_mode" env:"PICOCLAW_TOOLS_POLICY_SAFE_MODE"`
@@ -163,15 +438,86 @@ type ToolPolicyConfig struct {
 	Deny     []string `json:"deny" env:"PICOCLAW_TOOLS_POLICY_DENY"`
 }
 
+type ExecToolsConfig struct {
+	// MaxOutputBytes caps captured stdout+stderr per command (0 = 1 MiB default).
+	MaxOutputBytes int `json:"max_output_bytes" env:"PICOCLAW_TOOLS_EXEC_MAX_OUTPUT_BYTES"`
+	// DenyPatterns are regexes added to the built-in dangerous-command denies.
+	// The pattern env vars take one regex per line, since regexes use commas.
+	DenyPatterns []string `json:"deny_patterns" env:"PICOCLAW_TOOLS_EXEC_DENY_PATTERNS" envSeparator:"\n"`
+	// AllowPatterns, when non-empty, restrict exec to matching commands.
+	AllowPatterns []string `json:"allow_patterns" env:"PICOCLAW_TOOLS_EXEC_ALLOW_PATTERNS" envSeparator:"\n"`
+	// ConfirmPatterns make matching commands wait for a confirmation token
+	// instead of running; the token expires after ConfirmTTLSeconds.
+	ConfirmPatterns   []string `json:"confirm_patterns" env:"PICOCLAW_TOOLS_EXEC_CONFIRM_PATTERNS" envSeparator:"\n"`
+	ConfirmTTLSeconds int      `json:"confirm_ttl_seconds" env:"PICOCLAW_TOOLS_EXEC_CONFIRM_TTL_SECONDS"`
+	// DryRun makes exec describe each command (after the guard) instead of
+	// running it, in every chat. /dryrun turns it on for one chat.
+	DryRun bool `json:"dry_run" env:"PICOCLAW_TOOLS_EXEC_DRY_RUN"`
+}
+
+// ToolCacheConfig enables result caching for idempotent tools (web_fetch,
+// web_search). TTLSeconds <= 0 disables it.
+type ToolCacheConfig struct {
+	TTLSeconds int `json:"ttl_seconds" env:"PICOCLAW_TOOLS_CACHE_TTL_SECONDS"`
+	MaxEntries int `json:"max_entries" env:"PICOCLAW_TOOLS_CACHE_MAX_ENTRIES"`
+}
+
+// MemoryToolsConfig configures the searchable memory DB.
+type MemoryToolsConfig struct {
+	// FTSTokenizer is the full-text tokenizer: "unicode61" (default) or
+	// "porter" for English stemming. Changing it rebuilds the index.
+	FTSTokenizer string `json:"fts_tokenizer" env:"PICOCLAW_TOOLS_MEMORY_FTS_TOKENIZER"`
+	// MarkdownTargets routes categories to markdown files under memory/,
+	// overriding the defaults (preference/note -> MEMORY.md, the rest ->
+	// daily log). An empty target keeps a category out of markdown.
+	MarkdownTargets map[string]string `json:"markdown_targets,omitempty"`
+	// DBOnly disables markdown write-through: memories are kept only in
+	// the database.
+	DBOnly bool `json:"db_only" env:"PICOCLAW_TOOLS_MEMORY_DB_ONLY"`
+	// ReindexWorkers is how many markdown files are read at once when the
+	// index is rebuilt on startup (0 = number of CPUs, at most 8).
+	ReindexWorkers int `json:"reindex_workers" env:"PICOCLAW_TOOLS_MEMORY_REINDEX_WORKERS"`
+	// ReindexBatchSize is how many lines are checked and inserted per
+	// database statement during reindex (0 = 500, at most 5000).
+	ReindexBatchSize int `json:"reindex_batch_size" env:"PICOCLAW_TOOLS_MEMORY_REINDEX_BATCH_SIZE"`
+}
+
 type ToolSafeguardsConfig struct {
 	Disabled bool `json:"disabled" env:"PICOCLAW_TOOLS_SAFEGUARDS_DISABLED"`
 }
 
+// ToolResultsConfig caps tool output fed back to the model.
+type ToolResultsConfig struct {
+	// MaxBytes is the largest tool result passed to the model; longer ones
+	// keep their head and tail around an elision marker. 0 disables the cap.
+	MaxBytes int `json:"max_bytes" env:"PICOCLAW_TOOLS_RESULTS_MAX_BYTES"`
+	// PerTool overrides MaxBytes by tool name (0 = no cap for that tool).
+	PerTool map[string]int `json:"per_tool,omitempty"`
+	// SummarizeOverBytes makes the agent summarize results longer than this
+	// with the model, saving the full output to a file. 0 disables it.
+	SummarizeOverBytes int `json:"summarize_over_bytes" env:"PICOCLAW_TOOLS_RESULTS_SUMMARIZE_OVER_BYTES"`
+}
+
+// ToolArgValidationConfig controls checking tool call arguments against
+// each tool's declared schema before the tool runs.
+type ToolArgValidationConfig struct {
+	// Disabled passes invalid arguments through to the tool, logging a
+	// warning, instead of returning an "invalid arguments" result.
+	Disabled bool `json:"disabled" env:"PICOCLAW_TOOLS_ARG_VALIDATION_DISABLED"`
+}
+
 type ToolsConfig struct {
-	Web        WebToolsConfig       `json:"web"`
-	Policy     ToolPolicyConfig     `json:"policy"`
-	Safeguards ToolSafeguardsConfig `json:"safeguards"`
-	Vision     VisionToolsConfig    `json:"vision"`
+	Web           WebToolsConfig           `json:"web"`
+	Policy        ToolPolicyConfig         `json:"policy"`
+	Safeguards    ToolSafeguardsConfig     `json:"safeguards"`
+	ArgValidation ToolArgValidationConfig  `json:"arg_validation"`
+	Results       ToolResultsConfig        `json:"results"`
+	Exec          ExecToolsConfig          `json:"exec"`
+	Cache         ToolCacheConfig          `json:"cache"`
+	Vision        VisionToolsConfig        `json:"vision"`
+	TTS           TTSToolsConfig           `json:"tts"`
+	Transcription TranscriptionToolsConfig `json:"transcription"`
+	Memory        MemoryToolsConfig        `json:"memory"`
 }
 
 func DefaultConfig() *Config {
@@ -187,6 +533,9 @@ func DefaultConfig() *Config {
 				AnthropicCache:              false,
 				AnthropicCacheTTL:           "",
 				MaxToolIterations:           20,
+				ToolLoopThreshold:           3,
+				AutoContinueMax:             0,
+				MaxConcurrentMessages:       1,
 				LLMTimeoutSeconds:           120,
 				ToolTimeoutSeconds:          60,
 				MaxParallelToolCalls:        4,
@@ -196,59 +545,87 @@ func DefaultConfig() *Config {
 				RequestMaxToolMessageChars:  0,
 				SubagentMaxTasks:            200,
 				SubagentCompletedTTLSeconds: 86400,
+				SubagentMaxDepth:            1,
 				EchoToolCalls:               false,
+				EchoInterimText:             false,
+				AuditTools:                  false,
+				StatusDelaySeconds:          30,
+				ToolProgressIntervalSeconds: 15,
+				StatusMessages:              []string{},
+				EmptyResponse:               "I've completed processing but have no response to give.",
 			},
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
-				Enabled:   false,
-				BridgeURL: "ws://localhost:3001",
-				AllowFrom: []string{},
+				Enabled:            false,
+				BridgeURL:          "ws://localhost:3001",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 			DeltaChat: DeltaChatConfig{
-				Enabled:          false,
-				BridgeURL:        "ws://localhost:3100",
-				AllowFrom:        []string{},
-				AckReaction:      "\U0001F440",
-				DoneReaction:     "",
-				ErrorReaction:    "",
-				ForwardReactions: false,
+				Enabled:            false,
+				BridgeURL:          "ws://localhost:3100",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
+				AckReaction:        "\U0001F440",
+				DoneReaction:       "",
+				ErrorReaction:      "",
+				ForwardReactions:   false,
 			},
 			Telegram: TelegramConfig{
-				Enabled:   false,
-				Token:     "",
-				AllowFrom: []string{},
+				Enabled:            false,
+				Token:              "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
+				MaxMediaSizeMB:     20,
+				AllowedMediaTypes:  []string{},
 			},
 			Feishu: FeishuConfig{
-				Enabled:           false,
-				AppID:             "",
-				AppSecret:         "",
-				EncryptKey:        "",
-				VerificationToken: "",
-				AllowFrom:         []string{},
+				Enabled:            false,
+				AppID:              "",
+				AppSecret:          "",
+				EncryptKey:         "",
+				VerificationToken:  "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 			Discord: DiscordConfig{
-				Enabled:   false,
-				Token:     "",
-				AllowFrom: []string{},
+				Enabled:            false,
+				Token:              "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 			QQ: QQConfig{
-				Enabled:   false,
-				AppID:     "",
-				AppSecret: "",
-				AllowFrom: []string{},
+				Enabled:            false,
+				AppID:              "",
+				AppSecret:          "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 			DingTalk: DingTalkConfig{
-				Enabled:      false,
-				ClientID:     "",
-				ClientSecret: "",
-				AllowFrom:    []string{},
+				Enabled:            false,
+				ClientID:           "",
+				ClientSecret:       "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 			Slack: SlackConfig{
-				Enabled:   false,
-				BotToken:  "",
-				AppToken:  "",
-				AllowFrom: []string{},
+				Enabled:            false,
+				BotToken:           "",
+				AppToken:           "",
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
+			},
+			IRC: IRCConfig{
+				Enabled:            false,
+				Server:             "irc.libera.chat:6697",
+				TLS:                true,
+				Nick:               "picoclaw",
+				Channels:           []string{},
+				RequireMention:     true,
+				LineDelayMS:        1000,
+				AllowFrom:          []string{},
+				DedupWindowSeconds: 60,
 			},
 		},
 		Providers: ProvidersConfig{
@@ -283,6 +660,20 @@ func DefaultConfig() *Config {
 			Safeguards: ToolSafeguardsConfig{
 				Disabled: false,
 			},
+			Exec: ExecToolsConfig{
+				MaxOutputBytes:    1 << 20,
+				DenyPatterns:      []string{},
+				AllowPatterns:     []string{},
+				ConfirmPatterns:   []string{},
+				ConfirmTTLSeconds: 120,
+			},
+			Cache: ToolCacheConfig{
+				TTLSeconds: 0,
+				MaxEntries: 256,
+			},
+			Results: ToolResultsConfig{
+				MaxBytes: 64 * 1024,
+			},
 			Vision: VisionToolsConfig{
 				Enabled:        true,
 				Model:          "glm-4.6v",
@@ -291,6 +682,32 @@ func DefaultConfig() *Config {
 				TimeoutSeconds: 45,
 				MaxImages:      3,
 			},
+			TTS: TTSToolsConfig{
+				Enabled:        false,
+				Model:          "tts-1",
+				Voice:          "alloy",
+				APIKey:         "",
+				APIBase:        "",
+				TimeoutSeconds: 60,
+			},
+			Transcription: TranscriptionToolsConfig{
+				Command: []string{},
+			},
+			Memory: MemoryToolsConfig{
+				FTSTokenizer: "unicode61",
+			},
+		},
+		Gateway: GatewayConfig{
+			MediaMaxAgeMinutes: 180,
+		},
+		Logging: LoggingConfig{
+			Path:      "",
+			MaxSizeMB: 10,
+			MaxFiles:  5,
+
+			MessagePreviewChars:  80,
+			ResponsePreviewChars: 120,
+			ToolPreviewChars:     200,
 		},
 	}
 }
@@ -314,9 +731,295 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
+// validate rejects settings that would otherwise only fail once the agent
+// starts, such as malformed exec command patterns.
+func (c *Config) validate() error {
+	for _, p := range c.Tools.Exec.DenyPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid tools.exec.deny_patterns entry %q: %w", p, err)
+		}
+	}
+	for _, p := range c.Tools.Exec.AllowPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid tools.exec.allow_patterns entry %q: %w", p, err)
+		}
+	}
+	for _, p := range c.Tools.Exec.ConfirmPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid tools.exec.confirm_patterns entry %q: %w", p, err)
+		}
+	}
+	switch tc := c.Tools.Transcription; tc.Backend {
+	case TranscriptionBackendAuto, TranscriptionBackendGroq, TranscriptionBackendOpenAI, TranscriptionBackendNone:
+	case TranscriptionBackendCommand:
+		if len(tc.Command) == 0 || strings.TrimSpace(tc.Command[0]) == "" {
+			return fmt.Errorf("invalid tools.transcription.command: the command backend needs a program to run")
+		}
+	default:
+		return fmt.Errorf("invalid tools.transcription.backend %q: want groq, openai, command or none", tc.Backend)
+	}
+	switch ttl := strings.TrimSpace(c.Agents.Defaults.AnthropicCacheTTL); ttl {
+	case "", "5m", "1h":
+	default:
+		return fmt.Errorf("invalid agents.defaults.anthropic_cache_ttl %q: want \"5m\" or \"1h\"", ttl)
+	}
+	if c.Tools.Exec.ConfirmTTLSeconds < 0 {
+		return fmt.Errorf("invalid tools.exec.confirm_ttl_seconds %d: must be >= 0", c.Tools.Exec.ConfirmTTLSeconds)
+	}
+	providers := map[string]ProviderConfig{
+		"anthropic":  c.Providers.Anthropic,
+		"openai":     c.Providers.OpenAI,
+		"openrouter": c.Providers.OpenRouter,
+		"groq":       c.Providers.Groq,
+		"modal":      c.Providers.Modal,
+		"zhipu":      c.Providers.Zhipu,
+		"vllm":       c.Providers.VLLM,
+		"gemini":     c.Providers.Gemini,
+	}
+	for name, pc := range providers {
+		if err := pc.validateRetries(); err != nil {
+			return fmt.Errorf("invalid providers.%s: %w", name, err)
+		}
+	}
+	allowModes := map[string]string{
+		"telegram":  c.Channels.Telegram.AllowMode,
+		"discord":   c.Channels.Discord.AllowMode,
+		"whatsapp":  c.Channels.WhatsApp.AllowMode,
+		"deltachat": c.Channels.DeltaChat.AllowMode,
+		"feishu":    c.Channels.Feishu.AllowMode,
+		"qq":        c.Channels.QQ.AllowMode,
+		"dingtalk":  c.Channels.DingTalk.AllowMode,
+		"slack":     c.Channels.Slack.AllowMode,
+		"irc":       c.Channels.IRC.AllowMode,
+	}
+	for name, mode := range allowModes {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "", "any", "all":
+		default:
+			return fmt.Errorf("invalid channels.%s.allow_mode %q: want any or all", name, mode)
+		}
+	}
+	groupSessionScopes := map[string]string{
+		"telegram":  c.Channels.Telegram.GroupSessionScope,
+		"discord":   c.Channels.Discord.GroupSessionScope,
+		"whatsapp":  c.Channels.WhatsApp.GroupSessionScope,
+		"deltachat": c.Channels.DeltaChat.GroupSessionScope,
+		"feishu":    c.Channels.Feishu.GroupSessionScope,
+		"qq":        c.Channels.QQ.GroupSessionScope,
+		"dingtalk":  c.Channels.DingTalk.GroupSessionScope,
+		"slack":     c.Channels.Slack.GroupSessionScope,
+		"irc":       c.Channels.IRC.GroupSessionScope,
+	}
+	for name, scope := range groupSessionScopes {
+		switch strings.ToLower(strings.TrimSpace(scope)) {
+		case "", "chat", "sender":
+		default:
+			return fmt.Errorf("invalid channels.%s.group_session_scope %q: want chat or sender", name, scope)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Agents.Defaults.WorkspaceIsolation)) {
+	case "", "none", "channel", "chat":
+	default:
+		return fmt.Errorf("invalid agents.defaults.workspace_isolation %q: want none, channel or chat", c.Agents.Defaults.WorkspaceIsolation)
+	}
+	if c.Channels.Telegram.MaxMediaSizeMB < 0 {
+		return fmt.Errorf("invalid channels.telegram.max_media_size_mb %d: must not be negative", c.Channels.Telegram.MaxMediaSizeMB)
+	}
+	if err := c.validateTelegramWebhook(); err != nil {
+		return err
+	}
+	if c.Agents.Defaults.MaxConcurrentMessages < 0 {
+		return fmt.Errorf("invalid agents.defaults.max_concurrent_messages %d: must be >= 0", c.Agents.Defaults.MaxConcurrentMessages)
+	}
+	if c.Agents.Defaults.BestOfN < 0 {
+		return fmt.Errorf("invalid agents.defaults.best_of_n %d: must be >= 0", c.Agents.Defaults.BestOfN)
+	}
+	if c.Agents.Defaults.SubagentMaxConcurrent < 0 {
+		return fmt.Errorf("invalid agents.defaults.subagent_max_concurrent %d: must be >= 0", c.Agents.Defaults.SubagentMaxConcurrent)
+	}
+	if c.Agents.Defaults.SessionMaxInMemory < 0 {
+		return fmt.Errorf("invalid agents.defaults.session_max_in_memory %d: must be >= 0", c.Agents.Defaults.SessionMaxInMemory)
+	}
+	if c.Agents.Defaults.SessionIdleTTLSeconds < 0 {
+		return fmt.Errorf("invalid agents.defaults.session_idle_ttl_seconds %d: must be >= 0", c.Agents.Defaults.SessionIdleTTLSeconds)
+	}
+	for _, p := range c.Agents.Defaults.PromptIncludes {
+		if p = strings.TrimSpace(p); p == "" || !filepath.IsLocal(p) {
+			return fmt.Errorf("invalid agents.defaults.prompt_includes entry %q: must be a path inside the workspace", p)
+		}
+	}
+	if t := c.Agents.Defaults.ToolLoopThreshold; t < 0 || t == 1 {
+		return fmt.Errorf("invalid agents.defaults.tool_loop_threshold %d: want 0 (disabled) or at least 2", t)
+	}
+	if err := c.validateModels(); err != nil {
+		return err
+	}
+	if n := c.Agents.Defaults.AutoContinueMax; n < 0 || n > MaxAutoContinue {
+		return fmt.Errorf("invalid agents.defaults.auto_continue_max %d: must be between 0 and %d", n, MaxAutoContinue)
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Tools.Memory.FTSTokenizer)) {
+	case "", "unicode61", "porter":
+	default:
+		return fmt.Errorf("invalid tools.memory.fts_tokenizer %q: want unicode61 or porter", c.Tools.Memory.FTSTokenizer)
+	}
+	if c.Tools.Results.MaxBytes < 0 {
+		return fmt.Errorf("invalid tools.results.max_bytes %d: must be >= 0", c.Tools.Results.MaxBytes)
+	}
+	for name, n := range c.Tools.Results.PerTool {
+		if n < 0 {
+			return fmt.Errorf("invalid tools.results.per_tool[%q] %d: must be >= 0", name, n)
+		}
+	}
+	if c.Tools.Results.SummarizeOverBytes < 0 {
+		return fmt.Errorf("invalid tools.results.summarize_over_bytes %d: must be >= 0", c.Tools.Results.SummarizeOverBytes)
+	}
+	if err := ValidateMemoryMarkdownTargets(c.Tools.Memory.MarkdownTargets); err != nil {
+		return fmt.Errorf("invalid tools.memory.markdown_targets: %w", err)
+	}
+	if c.Tools.Memory.ReindexWorkers < 0 {
+		return fmt.Errorf("invalid tools.memory.reindex_workers %d: must be >= 0", c.Tools.Memory.ReindexWorkers)
+	}
+	if c.Tools.Memory.ReindexBatchSize < 0 {
+		return fmt.Errorf("invalid tools.memory.reindex_batch_size %d: must be >= 0", c.Tools.Memory.ReindexBatchSize)
+	}
+	for event, policy := range c.Agents.Defaults.SubagentEvents {
+		switch policy {
+		case SubagentEventForward, SubagentEventInternal, SubagentEventSuppress:
+		default:
+			return fmt.Errorf("invalid agents.defaults.subagent_events[%q] %q: want forward, internal or suppress", event, policy)
+		}
+	}
+	if c.Channels.IRC.LineDelayMS < 0 {
+		return fmt.Errorf("invalid channels.irc.line_delay_ms %d: must be >= 0", c.Channels.IRC.LineDelayMS)
+	}
+	if nick := c.Channels.IRC.Nick; c.Channels.IRC.Enabled && (nick == "" || strings.ContainsAny(nick, " ,*?!@#:")) {
+		return fmt.Errorf("invalid channels.irc.nick %q: must be a non-empty IRC nickname", nick)
+	}
+	if c.Gateway.MediaMaxAgeMinutes < 0 {
+		return fmt.Errorf("invalid gateway.media_max_age_minutes %d: must be >= 0", c.Gateway.MediaMaxAgeMinutes)
+	}
+	if c.Logging.MaxSizeMB < 0 || c.Logging.MaxFiles < 0 {
+		return fmt.Errorf("invalid logging: max_size_mb and max_files must not be negative")
+	}
+	if c.Logging.MessagePreviewChars < 0 || c.Logging.ResponsePreviewChars < 0 || c.Logging.ToolPreviewChars < 0 {
+		return fmt.Errorf("invalid logging: preview chars must not be negative")
+	}
+	return nil
+}
+
+var telegramWebhookSecretRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+func (c *Config) validateTelegramWebhook() error {
+	tg := c.Channels.Telegram
+	switch strings.ToLower(strings.TrimSpace(tg.Mode)) {
+	case "", "polling":
+		return nil
+	case "webhook":
+	default:
+		return fmt.Errorf("invalid channels.telegram.mode %q: want polling or webhook", tg.Mode)
+	}
+	if secret := tg.WebhookSecret; secret != "" && !telegramWebhookSecretRe.MatchString(secret) {
+		return fmt.Errorf("invalid channels.telegram.webhook_secret: use 1-256 characters from A-Z, a-z, 0-9, _ and -")
+	}
+	if !tg.Enabled {
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(tg.WebhookURL)), "https://") {
+		return fmt.Errorf("invalid channels.telegram.webhook_url %q: webhook mode needs a public https:// URL", tg.WebhookURL)
+	}
+	if strings.TrimSpace(tg.WebhookListen) == "" && strings.TrimSpace(c.Gateway.HealthAddr) == "" {
+		return fmt.Errorf("invalid channels.telegram.webhook_listen: webhook mode needs webhook_listen or gateway.health_addr to serve updates")
+	}
+	return nil
+}
+
+func (pc ProviderConfig) validateRetries() error {
+	if pc.MaxRetries != nil && *pc.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative (got %d)", *pc.MaxRetries)
+	}
+	if pc.RetryBaseWaitMS < 0 {
+		return fmt.Errorf("retry_base_wait_ms must not be negative (got %d)", pc.RetryBaseWaitMS)
+	}
+	if pc.RetryMaxWaitMS < 0 {
+		return fmt.Errorf("retry_max_wait_ms must not be negative (got %d)", pc.RetryMaxWaitMS)
+	}
+	if pc.RetryAfterJitterMS != nil && *pc.RetryAfterJitterMS < 0 {
+		return fmt.Errorf("retry_after_jitter_ms must not be negative (got %d)", *pc.RetryAfterJitterMS)
+	}
+	if pc.CircuitBreakerThreshold < 0 || pc.CircuitBreakerWindowSeconds < 0 || pc.CircuitBreakerCooldownSeconds < 0 {
+		return fmt.Errorf("circuit_breaker_* values must not be negative")
+	}
+	if pc.RequestTimeoutSeconds < 0 {
+		return fmt.Errorf("request_timeout_seconds must not be negative (got %d)", pc.RequestTimeoutSeconds)
+	}
+	for _, code := range pc.RetryStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("retry_status_codes entry %d is not an HTTP status", code)
+		}
+	}
+	for _, pattern := range pc.RetryContentPatterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("retry_content_patterns must not contain empty patterns")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid retry_content_patterns entry %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+var (
+	memoryTargetCategoryRe    = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	memoryTargetPlaceholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+)
+
+// ValidateMemoryMarkdownTargets checks the category names and templates of
+// tools.memory.markdown_targets. A template is a relative .md path inside the
+// memory directory that may use the {yyyy}, {mm} and {dd} placeholders for
+// the current date; empty means "not mirrored".
+func ValidateMemoryMarkdownTargets(targets map[string]string) error {
+	for category, template := range targets {
+		if !memoryTargetCategoryRe.MatchString(category) {
+			return fmt.Errorf("invalid category %q: want lowercase letters, digits, - or _", category)
+		}
+		if err := validateMemoryMarkdownTarget(template); err != nil {
+			return fmt.Errorf("category %q target %q: %w", category, template, err)
+		}
+	}
+	return nil
+}
+
+func validateMemoryMarkdownTarget(template string) error {
+	if template == "" {
+		return nil
+	}
+	for _, p := range memoryTargetPlaceholderRe.FindAllString(template, -1) {
+		switch p {
+		case "{yyyy}", "{mm}", "{dd}":
+		default:
+			return fmt.Errorf("unknown placeholder %s: want {yyyy}, {mm} or {dd}", p)
+		}
+	}
+	expanded := strings.NewReplacer("{yyyy}", "2006", "{mm}", "01", "{dd}", "02").Replace(template)
+	if strings.ContainsAny(expanded, "{}") {
+		return fmt.Errorf("unbalanced braces")
+	}
+	if !filepath.IsLocal(filepath.FromSlash(expanded)) || strings.Contains(expanded, `\`) {
+		return fmt.Errorf("must be a relative path inside the memory directory")
+	}
+	if filepath.Ext(expanded) != ".md" {
+		return fmt.Errorf("must end in .md")
+	}
+	return nil
+}
+
 func SaveConfig(path string, cfg *Config) error {
 	cfg.mu.RLock()
 	defer cfg.mu.RUnlock()
@@ -340,6 +1043,18 @@ func (c *Config) WorkspacePath() string {
 	return expandHome(c.Agents.Defaults.Workspace)
 }
 
+// MediaDirPath returns the directory channel downloads go to: the
+// configured gateway.media_dir with "~" expanded, or <workspace>/tmp/media.
+func (c *Config) MediaDirPath() string {
+	c.mu.RLock()
+	dir := strings.TrimSpace(c.Gateway.MediaDir)
+	c.mu.RUnlock()
+	if dir != "" {
+		return expandHome(dir)
+	}
+	return filepath.Join(c.WorkspacePath(), "tmp", "media")
+}
+
 func (c *Config) GetAPIKey() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_RejectsInvalidExecPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"tools":{"exec":{"deny_patterns":["\\bcurl\\b","[unclosed"]}}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "tools.exec.deny_patterns") || !strings.Contains(err.Error(), "[unclosed") {
+		t.Fatalf("expected clear deny_patterns error, got %v", err)
+	}
+}
+
+func TestLoadConfig_AcceptsExecPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"tools":{"exec":{"deny_patterns":["\\bcurl\\b"],"allow_patterns":["^git\\s"]}}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Tools.Exec.DenyPatterns) != 1 || len(cfg.Tools.Exec.AllowPatterns) != 1 {
+		t.Fatalf("patterns not loaded: %+v", cfg.Tools.Exec)
+	}
+}
+
+func TestLoadConfig_ExecPatternsFromEnvSplitOnNewlines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("PICOCLAW_TOOLS_EXEC_DENY_PATTERNS", "\\brm\\s+-[rf]{1,2}\\b\n\\bcurl\\b")
+	t.Setenv("PICOCLAW_TOOLS_EXEC_CONFIRM_PATTERNS", "^git\\s+push\\b.{0,20}")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	deny := cfg.Tools.Exec.DenyPatterns
+	if len(deny) != 2 || deny[0] != `\brm\s+-[rf]{1,2}\b` || deny[1] != `\bcurl\b` {
+		t.Fatalf("DenyPatterns = %q, want the two regexes intact", deny)
+	}
+	if confirm := cfg.Tools.Exec.ConfirmPatterns; len(confirm) != 1 || confirm[0] != `^git\s+push\b.{0,20}` {
+		t.Fatalf("ConfirmPatterns = %q, want the regex intact", confirm)
+	}
+}
+
+func TestLoadConfig_RejectsNegativeProviderRetries(t *testing.T) {
+	cases := []struct {
+		data string
+		want string
+	}{
+		{`{"providers":{"openai":{"max_retries":-1}}}`, "providers.openai: max_retries"},
+		{`{"providers":{"openai":{"retry_after_jitter_ms":-5}}}`, "providers.openai: retry_after_jitter_ms"},
+		{`{"providers":{"groq":{"circuit_breaker_cooldown_seconds":-1}}}`, "providers.groq: circuit_breaker"},
+		{`{"channels":{"slack":{"allow_mode":"either"}}}`, "channels.slack.allow_mode"},
+		{`{"providers":{"vllm":{"retry_base_wait_ms":-5}}}`, "providers.vllm: retry_base_wait_ms"},
+		{`{"providers":{"groq":{"retry_max_wait_ms":-1}}}`, "providers.groq: retry_max_wait_ms"},
+		{`{"providers":{"openai":{"request_timeout_seconds":-1}}}`, "providers.openai: request_timeout_seconds"},
+		{`{"providers":{"zhipu":{"retry_status_codes":[409,1000]}}}`, "retry_status_codes entry 1000"},
+		{`{"providers":{"zhipu":{"retry_content_patterns":["(bad"]}}}`, "retry_content_patterns"},
+		{`{"logging":{"max_size_mb":-1}}`, "invalid logging"},
+		{`{"agents":{"defaults":{"workspace_isolation":"sender"}}}`, "workspace_isolation"},
+		{`{"tools":{"memory":{"fts_tokenizer":"trigram"}}}`, "fts_tokenizer"},
+		{`{"tools":{"results":{"max_bytes":-1}}}`, "tools.results.max_bytes"},
+		{`{"tools":{"exec":{"confirm_patterns":["(push"]}}}`, "tools.exec.confirm_patterns"},
+		{`{"tools":{"exec":{"confirm_ttl_seconds":-1}}}`, "tools.exec.confirm_ttl_seconds"},
+		{`{"tools":{"memory":{"reindex_workers":-1}}}`, "tools.memory.reindex_workers"},
+		{`{"tools":{"memory":{"reindex_batch_size":-1}}}`, "tools.memory.reindex_batch_size"},
+		{`{"agents":{"defaults":{"session_max_in_memory":-1}}}`, "agents.defaults.session_max_in_memory"},
+		{`{"agents":{"defaults":{"session_idle_ttl_seconds":-5}}}`, "agents.defaults.session_idle_ttl_seconds"},
+		{`{"agents":{"defaults":{"subagent_max_concurrent":-1}}}`, "agents.defaults.subagent_max_concurrent"},
+		{`{"channels":{"telegram":{"group_session_scope":"user"}}}`, "channels.telegram.group_session_scope"},
+		{`{"agents":{"defaults":{"subagent_events":{"progress":"show"}}}}`, "agents.defaults.subagent_events"},
+		{`{"channels":{"irc":{"line_delay_ms":-1}}}`, "channels.irc.line_delay_ms"},
+		{`{"channels":{"irc":{"enabled":true,"nick":"pico claw"}}}`, "channels.irc.nick"},
+		{`{"tools":{"results":{"summarize_over_bytes":-1}}}`, "tools.results.summarize_over_bytes"},
+		{`{"agents":{"defaults":{"auto_continue_max":11}}}`, "agents.defaults.auto_continue_max"},
+		{`{"agents":{"defaults":{"anthropic_cache_ttl":"2h"}}}`, "agents.defaults.anthropic_cache_ttl"},
+		{`{"gateway":{"media_max_age_minutes":-1}}`, "gateway.media_max_age_minutes"},
+		{`{"tools":{"transcription":{"backend":"whisper"}}}`, "tools.transcription.backend"},
+		{`{"tools":{"transcription":{"backend":"command"}}}`, "tools.transcription.command"},
+		{`{"tools":{"results":{"per_tool":{"exec":-1}}}}`, "tools.results.per_tool"},
+		{`{"tools":{"memory":{"markdown_targets":{"project":"../PROJECT.md"}}}}`, "markdown_targets"},
+		{`{"tools":{"memory":{"markdown_targets":{"journal":"{date}.md"}}}}`, "unknown placeholder"},
+		{`{"agents":{"defaults":{"tool_loop_threshold":1}}}`, "tool_loop_threshold"},
+		{`{"agents":{"defaults":{"best_of_n":-1}}}`, "best_of_n"},
+		{`{"agents":{"defaults":{"max_concurrent_messages":-2}}}`, "max_concurrent_messages"},
+		{`{"logging":{"tool_preview_chars":-1}}`, "preview chars"},
+		{`{"agents":{"defaults":{"prompt_includes":["../secrets.md"]}}}`, "prompt_includes"},
+		{`{"agents":{"defaults":{"prompt_includes":["/etc/passwd"]}}}`, "prompt_includes"},
+		{`{"channels":{"telegram":{"max_media_size_mb":-5}}}`, "max_media_size_mb"},
+		{`{"channels":{"telegram":{"mode":"push"}}}`, "channels.telegram.mode"},
+		{`{"channels":{"telegram":{"enabled":true,"mode":"webhook","webhook_url":"http://bot.example.com/tg","webhook_listen":":8443"}}}`, "webhook_url"},
+		{`{"channels":{"telegram":{"enabled":true,"mode":"webhook","webhook_url":"https://bot.example.com/tg"}}}`, "webhook_listen"},
+		{`{"channels":{"telegram":{"mode":"webhook","webhook_secret":"not secret!"}}}`, "webhook_secret"},
+	}
+	for _, tc := range cases {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tc.data), 0600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		_, err := LoadConfig(path)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("LoadConfig(%s) error = %v, want %q", tc.data, err, tc.want)
+		}
+	}
+}
+
+func TestLoadConfig_AcceptsProviderRetries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"providers":{"openai":{"max_retries":0,"retry_base_wait_ms":500,"retry_max_wait_ms":10000,"retry_status_codes":[409]}}}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	pc := cfg.Providers.OpenAI
+	if pc.MaxRetries == nil || *pc.MaxRetries != 0 || pc.RetryBaseWaitMS != 500 || pc.RetryMaxWaitMS != 10000 || len(pc.RetryStatusCodes) != 1 {
+		t.Fatalf("retry settings not loaded: %+v", pc)
+	}
+}
+
+func TestDefaultConfig_AutoContinueIsOptIn(t *testing.T) {
+	if n := DefaultConfig().Agents.Defaults.AutoContinueMax; n != 0 {
+		t.Fatalf("AutoContinueMax = %d, want 0 so unattended runs do not resume unless configured", n)
+	}
+}
+
+func TestValidateMemoryMarkdownTargets(t *testing.T) {
+	valid := map[string]string{
+		"project": "PROJECT.md",
+		"journal": "journal/{yyyy}/{mm}{dd}.md",
+		"secret":  "",
+	}
+	if err := ValidateMemoryMarkdownTargets(valid); err != nil {
+		t.Fatalf("ValidateMemoryMarkdownTargets(valid) = %v", err)
+	}
+
+	for _, tc := range []struct {
+		targets map[string]string
+		want    string
+	}{
+		{map[string]string{"project": "../PROJECT.md"}, "inside the memory directory"},
+		{map[string]string{"project": "/etc/PROJECT.md"}, "inside the memory directory"},
+		{map[string]string{"project": "PROJECT.txt"}, ".md"},
+		{map[string]string{"project": "{date}.md"}, "unknown placeholder {date}"},
+		{map[string]string{"project": "{yyyy.md"}, "unbalanced braces"},
+		{map[string]string{"Project X": "PROJECT.md"}, "invalid category"},
+	} {
+		err := ValidateMemoryMarkdownTargets(tc.targets)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ValidateMemoryMarkdownTargets(%v) = %v, want error containing %q", tc.targets, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider names, as used under "providers" in the config.
+const (
+	ProviderAnthropic  = "anthropic"
+	ProviderOpenAI     = "openai"
+	ProviderOpenRouter = "openrouter"
+	ProviderGroq       = "groq"
+	ProviderModal      = "modal"
+	ProviderZhipu      = "zhipu"
+	ProviderVLLM       = "vllm"
+	ProviderGemini     = "gemini"
+)
+
+// ModelRoute is the provider that serves a model.
+type ModelRoute struct {
+	Provider string // key under "providers", e.g. "zhipu"
+	// APIBase is the provider's api_base, or its default endpoint. It is
+	// empty for Gemini without an api_base; the Gemini provider picks one.
+	APIBase string
+}
+
+// modelProvider describes which model names a provider serves.
+type modelProvider struct {
+	name        string
+	defaultBase string
+	prefixes    []string // explicit "vendor/" prefixes, checked before any family
+	families    []string // substrings identifying models of this provider
+	hint        string   // model names shown in error messages
+}
+
+// openRouterPrefixes always route to OpenRouter, whatever else is configured.
+var openRouterPrefixes = []string{"openrouter/", "anthropic/", "openai/", "meta-llama/", "deepseek/", "google/"}
+
+// modelProviders is the model registry. Within each matching phase
+// (prefixes, then families) the first configured provider wins, so "glm-5"
+// goes to Zhipu when both Zhipu and Modal have keys, while "zai-org/GLM-5"
+// always prefers Modal.
+var modelProviders = []modelProvider{
+	{name: ProviderAnthropic, defaultBase: "https://api.anthropic.com/v1", families: []string{"claude"}, hint: "claude-*"},
+	{name: ProviderOpenAI, defaultBase: "https://api.openai.com/v1", families: []string{"gpt"}, hint: "gpt-*"},
+	{name: ProviderGemini, families: []string{"gemini"}, hint: "gemini-*"},
+	{name: ProviderZhipu, defaultBase: "https://open.bigmodel.cn/api/paas/v4", families: []string{"glm", "zhipu", "zai"}, hint: "glm-*"},
+	{name: ProviderGroq, defaultBase: "https://api.groq.com/openai/v1", prefixes: []string{"groq/"}, families: []string{"groq"}, hint: "groq/*"},
+	{name: ProviderModal, defaultBase: "https://api.us-west-2.modal.direct/v1", prefixes: []string{"zai-org/"}, families: []string{"glm-5"}, hint: "zai-org/*, glm-5"},
+}
+
+const defaultOpenRouterAPIBase = "https://openrouter.ai/api/v1"
+
+// ModelRouteError is returned by ResolveModel when no configured provider
+// can serve a model.
+type ModelRouteError struct {
+	Model string
+	// Provider is the provider the model belongs to, when it is a known
+	// model whose provider lacks credentials. Empty for unknown models.
+	Provider string
+}
+
+func (e *ModelRouteError) Error() string {
+	if e.Provider != "" {
+		return fmt.Sprintf("model %q is served by %s, but providers.%s has no credentials (set providers.%s.api_key)",
+			e.Model, e.Provider, e.Provider, e.Provider)
+	}
+	return fmt.Sprintf("unknown model %q: no provider recognizes it and there is no catch-all "+
+		"(set providers.openrouter.api_key or providers.vllm.api_base). Known providers: %s",
+		e.Model, supportedProvidersHint())
+}
+
+func supportedProvidersHint() string {
+	parts := make([]string, 0, len(modelProviders)+1)
+	for _, p := range modelProviders {
+		parts = append(parts, fmt.Sprintf("%s (%s)", p.name, p.hint))
+	}
+	parts = append(parts, fmt.Sprintf("%s (%s)", ProviderOpenRouter, strings.Join(openRouterPrefixes, "*, ")+"*"))
+	return strings.Join(parts, ", ")
+}
+
+// ResolveModel returns the provider that serves model: an explicit vendor
+// prefix first, then the model family of a configured provider, then vLLM
+// (when providers.vllm.api_base is set), then OpenRouter for anything else.
+func (c *Config) ResolveModel(model string) (ModelRoute, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resolveModel(model)
+}
+
+func (c *Config) resolveModel(model string) (ModelRoute, error) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return ModelRoute{}, fmt.Errorf("model must not be empty")
+	}
+	lower := strings.ToLower(model)
+
+	for _, prefix := range openRouterPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			if strings.TrimSpace(c.Providers.OpenRouter.APIKey) == "" {
+				return ModelRoute{}, &ModelRouteError{Model: model, Provider: ProviderOpenRouter}
+			}
+			return c.route(ProviderOpenRouter, defaultOpenRouterAPIBase), nil
+		}
+	}
+
+	known := ""
+	for _, phase := range []func(modelProvider) bool{
+		func(p modelProvider) bool { return hasAnyPrefix(lower, p.prefixes) },
+		func(p modelProvider) bool { return containsAny(lower, p.families) },
+	} {
+		for _, p := range modelProviders {
+			if !phase(p) {
+				continue
+			}
+			if c.providerConfigured(p.name) {
+				return c.route(p.name, p.defaultBase), nil
+			}
+			if known == "" {
+				known = p.name
+			}
+		}
+	}
+
+	if strings.TrimSpace(c.Providers.VLLM.APIBase) != "" {
+		return c.route(ProviderVLLM, ""), nil
+	}
+	if strings.TrimSpace(c.Providers.OpenRouter.APIKey) != "" {
+		return c.route(ProviderOpenRouter, defaultOpenRouterAPIBase), nil
+	}
+	return ModelRoute{}, &ModelRouteError{Model: model, Provider: known}
+}
+
+// ProviderByName returns the settings of the named provider.
+func (c *Config) ProviderByName(name string) (ProviderConfig, bool) {
+	switch name {
+	case ProviderAnthropic:
+		return c.Providers.Anthropic, true
+	case ProviderOpenAI:
+		return c.Providers.OpenAI, true
+	case ProviderOpenRouter:
+		return c.Providers.OpenRouter, true
+	case ProviderGroq:
+		return c.Providers.Groq, true
+	case ProviderModal:
+		return c.Providers.Modal, true
+	case ProviderZhipu:
+		return c.Providers.Zhipu, true
+	case ProviderVLLM:
+		return c.Providers.VLLM, true
+	case ProviderGemini:
+		return c.Providers.Gemini, true
+	}
+	return ProviderConfig{}, false
+}
+
+// providerConfigured reports whether name has credentials: an API key, or
+// for Anthropic and OpenAI an auth method (OAuth or token login).
+func (c *Config) providerConfigured(name string) bool {
+	pc, _ := c.ProviderByName(name)
+	if pc.APIKey != "" {
+		return true
+	}
+	return (name == ProviderAnthropic || name == ProviderOpenAI) && pc.AuthMethod != ""
+}
+
+func (c *Config) route(name, defaultBase string) ModelRoute {
+	pc, _ := c.ProviderByName(name)
+	base := strings.TrimSpace(pc.APIBase)
+	if base == "" {
+		base = defaultBase
+	}
+	return ModelRoute{Provider: name, APIBase: base}
+}
+
+// validateModels rejects a primary or fallback model that no provider
+// recognizes and no catch-all would take. Known models whose provider
+// has no key yet are accepted; that is reported when the provider is built.
+func (c *Config) validateModels() error {
+	models := append([]string{c.Agents.Defaults.Model}, c.Agents.Defaults.FallbackModels...)
+	for i, model := range models {
+		if strings.TrimSpace(model) == "" {
+			continue
+		}
+		_, err := c.resolveModel(model)
+		if routeErr, ok := err.(*ModelRouteError); ok && routeErr.Provider == "" {
+			key := "agents.defaults.model"
+			if i > 0 {
+				key = "agents.defaults.fallback_models"
+			}
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveModel_KnownModelsAndAliases(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Providers.Anthropic.AuthMethod = "oauth"
+	cfg.Providers.OpenAI.APIKey = "sk"
+	cfg.Providers.Gemini.APIKey = "g"
+	cfg.Providers.Zhipu.APIKey = "z"
+	cfg.Providers.Groq.APIKey = "gq"
+	cfg.Providers.Modal.APIKey = "m"
+	cfg.Providers.OpenRouter.APIKey = "or"
+
+	cases := []struct {
+		model    string
+		provider string
+		base     string
+	}{
+		{"claude-sonnet-4-5", ProviderAnthropic, "https://api.anthropic.com/v1"},
+		{"gpt-4o", ProviderOpenAI, "https://api.openai.com/v1"},
+		{"gemini-2.5-flash", ProviderGemini, ""},
+		{"glm-4.7", ProviderZhipu, "https://open.bigmodel.cn/api/paas/v4"},
+		// Both Zhipu and Modal serve GLM-5; the plain name stays with Zhipu...
+		{"glm-5", ProviderZhipu, "https://open.bigmodel.cn/api/paas/v4"},
+		// ...while Modal's HF-style name goes to Modal even though it contains "zai".
+		{"zai-org/GLM-5-FP8", ProviderModal, "https://api.us-west-2.modal.direct/v1"},
+		{"groq/llama-3.3-70b", ProviderGroq, "https://api.groq.com/openai/v1"},
+		{"anthropic/claude-sonnet-4-5", ProviderOpenRouter, "https://openrouter.ai/api/v1"},
+		{"OpenRouter/auto", ProviderOpenRouter, "https://openrouter.ai/api/v1"},
+		{"mistral-large", ProviderOpenRouter, "https://openrouter.ai/api/v1"},
+	}
+	for _, tc := range cases {
+		route, err := cfg.ResolveModel(tc.model)
+		if err != nil {
+			t.Fatalf("ResolveModel(%q): %v", tc.model, err)
+		}
+		if route.Provider != tc.provider || route.APIBase != tc.base {
+			t.Fatalf("ResolveModel(%q) = %+v, want %s at %q", tc.model, route, tc.provider, tc.base)
+		}
+	}
+}
+
+func TestResolveModel_FallsThroughUnconfiguredProviders(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Providers.Modal.APIKey = "m"
+	if route, _ := cfg.ResolveModel("glm-5"); route.Provider != ProviderModal {
+		t.Fatalf("expected glm-5 to use Modal without a Zhipu key, got %+v", route)
+	}
+
+	cfg.Providers.VLLM.APIBase = "http://localhost:8000/v1"
+	if route, _ := cfg.ResolveModel("claude-sonnet-4-5"); route.Provider != ProviderVLLM || route.APIBase != "http://localhost:8000/v1" {
+		t.Fatalf("expected vLLM catch-all without an Anthropic key, got %+v", route)
+	}
+}
+
+func TestResolveModel_Errors(t *testing.T) {
+	cfg := DefaultConfig()
+
+	_, err := cfg.ResolveModel("glm-4.7")
+	var routeErr *ModelRouteError
+	if !errors.As(err, &routeErr) || routeErr.Provider != ProviderZhipu {
+		t.Fatalf("expected missing-credentials error naming zhipu, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "providers.zhipu.api_key") {
+		t.Fatalf("expected hint to set providers.zhipu.api_key, got %v", err)
+	}
+
+	_, err = cfg.ResolveModel("llama-3-70b")
+	if !errors.As(err, &routeErr) || routeErr.Provider != "" {
+		t.Fatalf("expected unknown-model error, got %v", err)
+	}
+	for _, want := range []string{"llama-3-70b", "providers.openrouter.api_key", "zhipu (glm-*)", "anthropic (claude-*)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q should mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadConfig_ValidatesModels(t *testing.T) {
+	load := func(data string) error {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		_, err := LoadConfig(path)
+		return err
+	}
+
+	err := load(`{"agents":{"defaults":{"model":"llama-3-70b"}}}`)
+	if err == nil || !strings.Contains(err.Error(), "invalid agents.defaults.model") || !strings.Contains(err.Error(), "unknown model") {
+		t.Fatalf("expected unknown model to fail at load, got %v", err)
+	}
+	err = load(`{"agents":{"defaults":{"fallback_models":["mystery-model"]}}}`)
+	if err == nil || !strings.Contains(err.Error(), "agents.defaults.fallback_models") {
+		t.Fatalf("expected unknown fallback model to fail at load, got %v", err)
+	}
+
+	// Known models load before their key is set, and OpenRouter or vLLM
+	// accept any model.
+	for _, data := range []string{
+		`{"agents":{"defaults":{"model":"glm-4.7"}}}`,
+		`{"agents":{"defaults":{"model":"llama-3-70b"}},"providers":{"openrouter":{"api_key":"or"}}}`,
+		`{"agents":{"defaults":{"model":"llama-3-70b"}},"providers":{"vllm":{"api_base":"http://localhost:8000/v1"}}}`,
+	} {
+		if err := load(data); err != nil {
+			t.Fatalf("LoadConfig(%s): %v", data, err)
+		}
+	}
+}
@@ -23,12 +23,26 @@ type CronSchedule struct {
 	TZ      string `json:"tz,omitempty"`
 }
 
+// Payload kinds. An agent_turn job runs its message through the agent; a
+// message job sends its message to Channel/To verbatim, without an agent turn.
+const (
+	PayloadKindAgentTurn = "agent_turn"
+	PayloadKindMessage   = "message"
+)
+
 type CronPayload struct {
 	Kind    string `json:"kind"`
 	Message string `json:"message"`
 	Deliver bool   `json:"deliver"`
 	Channel string `json:"channel,omitempty"`
 	To      string `json:"to,omitempty"`
+	// SessionKey runs an agent_turn job in an existing session (e.g. a
+	// chat's thread) instead of its own "cron-<id>" session. It must be the
+	// session of the Channel/To chat the job is pinned to.
+	SessionKey string `json:"session_key,omitempty"`
+	// PromptPrefix is added to the system prompt on every run, e.g. a
+	// persona. It is not saved to the session.
+	PromptPrefix string `json:"prompt_prefix,omitempty"`
 }
 
 type CronJobState struct {
@@ -53,6 +67,8 @@ type CronJob struct {
 type CronStore struct {
 	Version int       `json:"version"`
 	Jobs    []CronJob `json:"jobs"`
+	// Paused suspends all jobs without touching their Enabled flags.
+	Paused bool `json:"paused,omitempty"`
 }
 
 type JobHandler func(job *CronJob) (string, error)
@@ -110,6 +126,7 @@ func (cs *CronService) Start() error {
 		"store_path":   cs.storePath,
 		"jobs":         len(cs.store.Jobs),
 		"next_wake_ms": nextWake,
+		"paused":       cs.store.Paused,
 	})
 
 	return nil
@@ -148,7 +165,7 @@ func (cs *CronService) runLoop(stopChan <-chan struct{}) {
 func (cs *CronService) checkJobs() {
 	cs.mu.Lock()
 
-	if !cs.running {
+	if !cs.running || cs.store.Paused {
 		cs.mu.Unlock()
 		return
 	}
@@ -372,6 +389,40 @@ func (cs *CronService) saveStoreUnsafe() error {
 }
 
 func (cs *CronService) AddJob(name string, schedule CronSchedule, message string, deliver bool, channel, to string) (*CronJob, error) {
+	return cs.AddAgentJob(name, schedule, CronPayload{
+		Message: message,
+		Deliver: deliver,
+		Channel: channel,
+		To:      to,
+	})
+}
+
+// AddAgentJob schedules an agent_turn job with a fully specified payload,
+// e.g. one carrying a session key or prompt prefix. The payload kind is
+// always set to agent_turn.
+func (cs *CronService) AddAgentJob(name string, schedule CronSchedule, payload CronPayload) (*CronJob, error) {
+	payload.Kind = PayloadKindAgentTurn
+	payload.SessionKey = strings.TrimSpace(payload.SessionKey)
+	payload.PromptPrefix = strings.TrimSpace(payload.PromptPrefix)
+	return cs.addJob(name, schedule, payload)
+}
+
+// AddMessageJob schedules message to be sent to channel/to as-is when the
+// job fires, e.g. a reminder. Both channel and to are required.
+func (cs *CronService) AddMessageJob(name string, schedule CronSchedule, message, channel, to string) (*CronJob, error) {
+	if channel == "" || to == "" {
+		return nil, fmt.Errorf("message job needs a channel and chat")
+	}
+	return cs.addJob(name, schedule, CronPayload{
+		Kind:    PayloadKindMessage,
+		Message: message,
+		Deliver: true,
+		Channel: channel,
+		To:      to,
+	})
+}
+
+func (cs *CronService) addJob(name string, schedule CronSchedule, payload CronPayload) (*CronJob, error) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
@@ -385,13 +436,7 @@ func (cs *CronService) AddJob(name string, schedule CronSchedule, message string
 		Name:     name,
 		Enabled:  true,
 		Schedule: schedule,
-		Payload: CronPayload{
-			Kind:    "agent_turn",
-			Message: message,
-			Deliver: deliver,
-			Channel: channel,
-			To:      to,
-		},
+		Payload:  payload,
 		State: CronJobState{
 			NextRunAtMS: cs.computeNextRun(&schedule, now),
 		},
@@ -406,12 +451,14 @@ func (cs *CronService) AddJob(name string, schedule CronSchedule, message string
 	}
 
 	logger.InfoCF("cron", "Cron job added", map[string]interface{}{
-		"job_id":   job.ID,
-		"name":     job.Name,
-		"schedule": job.Schedule.Kind,
-		"deliver":  job.Payload.Deliver,
-		"channel":  job.Payload.Channel,
-		"to":       job.Payload.To,
+		"job_id":      job.ID,
+		"name":        job.Name,
+		"schedule":    job.Schedule.Kind,
+		"kind":        job.Payload.Kind,
+		"deliver":     job.Payload.Deliver,
+		"channel":     job.Payload.Channel,
+		"to":          job.Payload.To,
+		"session_key": job.Payload.SessionKey,
 	})
 
 	return &job, nil
@@ -472,6 +519,65 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 	return nil
 }
 
+// PauseAll stops every job from firing until ResumeAll is called. Per-job
+// Enabled flags are left untouched and the paused state is persisted.
+func (cs *CronService) PauseAll() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.store.Paused {
+		return nil
+	}
+	cs.store.Paused = true
+	if err := cs.saveStoreUnsafe(); err != nil {
+		return err
+	}
+
+	logger.InfoCF("cron", "Cron jobs paused", map[string]interface{}{"jobs": len(cs.store.Jobs)})
+	return nil
+}
+
+// ResumeAll lifts a PauseAll. Runs missed while paused are not backfilled:
+// overdue recurring jobs are rescheduled from now. A one-shot "at" job that
+// came due while paused has no later run, so it fires once on the next tick.
+func (cs *CronService) ResumeAll() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if !cs.store.Paused {
+		return nil
+	}
+	cs.store.Paused = false
+
+	now := time.Now().UnixMilli()
+	for i := range cs.store.Jobs {
+		job := &cs.store.Jobs[i]
+		if !job.Enabled || job.State.NextRunAtMS == nil || *job.State.NextRunAtMS > now {
+			continue
+		}
+		if job.Schedule.Kind == "at" {
+			due := now
+			job.State.NextRunAtMS = &due
+			continue
+		}
+		job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+	}
+
+	if err := cs.saveStoreUnsafe(); err != nil {
+		return err
+	}
+
+	logger.InfoCF("cron", "Cron jobs resumed", map[string]interface{}{"jobs": len(cs.store.Jobs)})
+	return nil
+}
+
+// IsPaused reports whether all jobs are currently paused.
+func (cs *CronService) IsPaused() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.store.Paused
+}
+
 func (cs *CronService) ListJobs(includeDisabled bool) []CronJob {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
@@ -494,6 +600,20 @@ func (cs *CronService) ListJobs(includeDisabled bool) []CronJob {
 	return enabled
 }
 
+// GetJob returns a copy of the job with jobID, or nil if there is none.
+func (cs *CronService) GetJob(jobID string) *CronJob {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, job := range cs.store.Jobs {
+		if job.ID == jobID {
+			copyJob := cloneCronJob(job)
+			return &copyJob
+		}
+	}
+	return nil
+}
+
 func cloneCronJob(job CronJob) CronJob {
 	copyJob := job
 
@@ -530,6 +650,7 @@ func (cs *CronService) Status() map[string]interface{} {
 
 	return map[string]interface{}{
 		"enabled":      cs.running,
+		"paused":       cs.store.Paused,
 		"jobs":         len(cs.store.Jobs),
 		"nextWakeAtMS": cs.getNextWakeMS(),
 	}
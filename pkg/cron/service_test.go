@@ -55,6 +55,59 @@ func TestAddJob_Every(t *testing.T) {
 	}
 }
 
+func TestAddMessageJob_RequiresTargetAndPersists(t *testing.T) {
+	cs := newTestService(t)
+	future := time.Now().Add(time.Hour).UnixMilli()
+	schedule := CronSchedule{Kind: "at", AtMS: &future}
+
+	if _, err := cs.AddMessageJob("r", schedule, "hi", "telegram", ""); err == nil {
+		t.Fatal("expected error without a chat")
+	}
+
+	job, err := cs.AddMessageJob("r", schedule, "hi", "telegram", "42")
+	if err != nil {
+		t.Fatalf("AddMessageJob failed: %v", err)
+	}
+	if job.Payload.Kind != PayloadKindMessage || !job.Payload.Deliver || !job.DeleteAfterRun {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	reloaded := NewCronService(cs.storePath, nil)
+	if got := reloaded.GetJob(job.ID); got == nil || got.Payload.Kind != PayloadKindMessage {
+		t.Fatalf("reloaded job = %+v", got)
+	}
+}
+
+func TestAddAgentJob_PersistsSessionAndPrefix(t *testing.T) {
+	cs := newTestService(t)
+	every := int64(60000)
+
+	job, err := cs.AddAgentJob("standup", CronSchedule{Kind: "every", EveryMS: &every}, CronPayload{
+		Kind:         PayloadKindMessage,
+		Message:      "continue the standup",
+		SessionKey:   " telegram:42 ",
+		PromptPrefix: "You are the standup facilitator.",
+	})
+	if err != nil {
+		t.Fatalf("AddAgentJob failed: %v", err)
+	}
+	if job.Payload.Kind != PayloadKindAgentTurn {
+		t.Fatalf("kind = %q, want %q", job.Payload.Kind, PayloadKindAgentTurn)
+	}
+
+	reloaded := NewCronService(cs.storePath, nil)
+	got := reloaded.GetJob(job.ID)
+	if got == nil {
+		t.Fatal("job not persisted")
+	}
+	if got.Payload.SessionKey != "telegram:42" {
+		t.Errorf("session key = %q", got.Payload.SessionKey)
+	}
+	if got.Payload.PromptPrefix != "You are the standup facilitator." {
+		t.Errorf("prompt prefix = %q", got.Payload.PromptPrefix)
+	}
+}
+
 func TestAddJob_At(t *testing.T) {
 	cs := newTestService(t)
 	future := time.Now().Add(1 * time.Hour).UnixMilli()
@@ -360,3 +413,103 @@ func TestExecuteJob_ResultErrorStringMarksError(t *testing.T) {
 		t.Fatalf("LastError = %q, want downstream failure text", jobs[0].State.LastError)
 	}
 }
+
+func TestPauseAll_SkipsDueJobsUntilResume(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron.json")
+	var runs int
+	cs := NewCronService(storePath, func(_ *CronJob) (string, error) {
+		runs++
+		return "ok", nil
+	})
+	every := int64(200)
+	job, err := cs.AddJob("tick", CronSchedule{Kind: "every", EveryMS: &every}, "msg", false, "", "")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	cs.running = true
+
+	if err := cs.PauseAll(); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	if cs.Status()["paused"] != true {
+		t.Fatalf("expected paused in status, got %v", cs.Status())
+	}
+
+	// Well past the due time: several runs would have been missed.
+	time.Sleep(450 * time.Millisecond)
+	cs.checkJobs()
+	if runs != 0 {
+		t.Fatalf("job ran %d times while paused", runs)
+	}
+
+	// The paused flag survives a restart.
+	if !NewCronService(storePath, nil).IsPaused() {
+		t.Fatal("expected paused flag to be persisted")
+	}
+
+	if err := cs.ResumeAll(); err != nil {
+		t.Fatalf("ResumeAll failed: %v", err)
+	}
+	next := cs.ListJobs(true)[0].State.NextRunAtMS
+	if next == nil || *next <= time.Now().UnixMilli() {
+		t.Fatalf("expected overdue job rescheduled from now, got %v", next)
+	}
+	cs.checkJobs()
+	if runs != 0 {
+		t.Fatalf("missed runs were backfilled on resume (%d runs)", runs)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	cs.checkJobs()
+	if runs != 1 {
+		t.Fatalf("expected job %s to run once after resume, got %d", job.ID, runs)
+	}
+	if cs.Status()["paused"] != false {
+		t.Fatalf("expected paused=false after resume, got %v", cs.Status())
+	}
+}
+
+func TestResumeAll_RunsOneShotJobThatCameDueWhilePaused(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "cron.json")
+	var runs int
+	cs := NewCronService(storePath, func(_ *CronJob) (string, error) {
+		runs++
+		return "ok", nil
+	})
+	at := time.Now().Add(100 * time.Millisecond).UnixMilli()
+	job, err := cs.AddJob("reminder", CronSchedule{Kind: "at", AtMS: &at}, "msg", false, "", "")
+	if err != nil {
+		t.Fatalf("AddJob failed: %v", err)
+	}
+	cs.running = true
+
+	if err := cs.PauseAll(); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cs.checkJobs()
+	if runs != 0 {
+		t.Fatalf("job ran %d times while paused", runs)
+	}
+
+	if err := cs.ResumeAll(); err != nil {
+		t.Fatalf("ResumeAll failed: %v", err)
+	}
+	got := cs.ListJobs(true)[0]
+	if !got.Enabled || got.State.NextRunAtMS == nil {
+		t.Fatalf("expected one-shot job %s to stay scheduled after resume, got %+v", job.ID, got.State)
+	}
+
+	cs.checkJobs()
+	if runs != 1 {
+		t.Fatalf("expected one-shot job to run once after resume, got %d", runs)
+	}
+	// One-shot jobs are deleted after their run by default.
+	if jobs := cs.ListJobs(true); len(jobs) != 0 {
+		t.Fatalf("expected one-shot job removed after its run, got %+v", jobs)
+	}
+	cs.checkJobs()
+	if runs != 1 {
+		t.Fatalf("one-shot job ran again (%d runs)", runs)
+	}
+}
@@ -0,0 +1,103 @@
+// Package events publishes structured agent lifecycle events (messages, LLM
+// calls, tool calls, subagents, summarization) for external dashboards and
+// other integrations. It is off unless a Bus is configured; a nil *Bus
+// accepts and drops every event.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types.
+const (
+	MessageReceived   = "message_received"
+	LLMCallStart      = "llm_call_start"
+	LLMCallEnd        = "llm_call_end"
+	ToolCallStart     = "tool_call_start"
+	ToolCallEnd       = "tool_call_end"
+	SubagentSpawned   = "subagent_spawned"
+	SubagentCompleted = "subagent_completed"
+	SessionSummarized = "session_summarized"
+)
+
+// DefaultSubscriberBuffer is the queue length of a subscriber created with a
+// non-positive buffer.
+const DefaultSubscriberBuffer = 256
+
+// Event is one lifecycle event. It is serialized as a single JSON object.
+type Event struct {
+	Type       string                 `json:"type"`
+	Time       time.Time              `json:"ts"`
+	SessionKey string                 `json:"session_key,omitempty"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// Bus fans events out to subscribers. Publishing never blocks the agent: a
+// subscriber whose queue is full misses the event, which is counted in
+// Dropped.
+type Bus struct {
+	mu      sync.RWMutex
+	subs    map[int]chan Event
+	nextID  int
+	dropped atomic.Int64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[int]chan Event)}
+}
+
+// Publish delivers e to every subscriber, stamping the time if unset.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// and a function that unsubscribes and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Dropped returns how many events were lost to full subscriber queues.
+func (b *Bus) Dropped() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.dropped.Load()
+}
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBus_DeliversInOrderToEverySubscriber(t *testing.T) {
+	b := NewBus()
+	first, unsubFirst := b.Subscribe(4)
+	defer unsubFirst()
+	second, unsubSecond := b.Subscribe(4)
+
+	b.Publish(Event{Type: MessageReceived})
+	b.Publish(Event{Type: LLMCallStart})
+
+	for _, ch := range []<-chan Event{first, second} {
+		for _, want := range []string{MessageReceived, LLMCallStart} {
+			e := <-ch
+			if e.Type != want {
+				t.Fatalf("got %q, want %q", e.Type, want)
+			}
+			if e.Time.IsZero() {
+				t.Fatal("event time not stamped")
+			}
+		}
+	}
+
+	unsubSecond()
+	if _, ok := <-second; ok {
+		t.Fatal("unsubscribed channel should be closed")
+	}
+	b.Publish(Event{Type: LLMCallEnd})
+	if e := <-first; e.Type != LLMCallEnd {
+		t.Fatalf("remaining subscriber got %q", e.Type)
+	}
+}
+
+func TestBus_FullSubscriberDropsInsteadOfBlocking(t *testing.T) {
+	b := NewBus()
+	_, unsubscribe := b.Subscribe(1)
+	defer unsubscribe()
+
+	b.Publish(Event{Type: ToolCallStart})
+	b.Publish(Event{Type: ToolCallEnd})
+
+	if got := b.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestBus_NilIsNoop(t *testing.T) {
+	var b *Bus
+	b.Publish(Event{Type: MessageReceived})
+	if b.Dropped() != 0 {
+		t.Fatal("nil bus should report no drops")
+	}
+}
+
+func TestServeUnix_StreamsJSONLines(t *testing.T) {
+	// Unix socket paths are length-limited, so avoid the long t.TempDir().
+	dir, err := os.MkdirTemp("", "ev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.sock")
+
+	b := NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := b.ServeUnix(ctx, path); err != nil {
+		t.Fatalf("ServeUnix() error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("socket mode = %o, want 600", perm)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the socket in %s, got %v", dir, entries)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// The subscription is registered asynchronously after accept.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.RLock()
+		n := len(b.subs)
+		b.mu.RUnlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client was never subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	b.Publish(Event{Type: ToolCallEnd, SessionKey: "telegram:1", Data: map[string]interface{}{"tool": "exec"}})
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("decode %q: %v", line, err)
+	}
+	if got.Type != ToolCallEnd || got.SessionKey != "telegram:1" || got.Data["tool"] != "exec" {
+		t.Fatalf("unexpected event %+v", got)
+	}
+
+	cancel()
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket file not removed after shutdown")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
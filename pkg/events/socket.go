@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ServeUnix streams every event as one JSON object per line to each client
+// connected to a Unix socket at path. It returns once the socket is
+// listening; the socket is closed and removed when ctx is done. A stale
+// socket file left by a previous run is replaced.
+func (b *Bus) ServeUnix(ctx context.Context, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return errors.New("events socket path exists and is not a socket: " + path)
+		}
+		_ = os.Remove(path)
+	}
+
+	ln, err := listenPrivateUnix(path)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		defer os.Remove(path)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				wg.Wait()
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.streamTo(ctx, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// listenPrivateUnix listens on a Unix socket at path that only the owner can
+// connect to. Events carry session keys and tool names, so the socket is
+// bound inside a fresh 0700 directory, restricted to 0600 there and only
+// then renamed into place; it is never reachable with the umask's mode.
+func listenPrivateUnix(path string) (net.Listener, error) {
+	// Keep the temporary name short: socket paths are length-limited.
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), ".ev")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, "s")
+	ln, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// The socket file is moved below; ServeUnix removes it at path.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// streamTo writes events to conn until ctx is done or the client goes away.
+func (b *Bus) streamTo(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	events, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+
+	// Clients only listen; a read returning means they disconnected.
+	gone := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(gone)
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gone:
+			return
+		case e := <-events:
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+	}
+}
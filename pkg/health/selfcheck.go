@@ -0,0 +1,145 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Check is one startup self-check. Run returns nil when the component is
+// healthy.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one Check.
+type CheckResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// SelfCheckReport collects the results of a self-check run.
+type SelfCheckReport struct {
+	Time    time.Time     `json:"time"`
+	Results []CheckResult `json:"results"`
+}
+
+// OK reports whether every check passed.
+func (r SelfCheckReport) OK() bool {
+	for _, res := range r.Results {
+		if !res.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary is a one-line description of the results, failures with their
+// error, e.g. "provider: FAILED (invalid key); memory: ok".
+func (r SelfCheckReport) Summary() string {
+	parts := make([]string, 0, len(r.Results))
+	for _, res := range r.Results {
+		if res.OK {
+			parts = append(parts, res.Name+": ok")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: FAILED (%s)", res.Name, res.Error))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// RunSelfCheck runs checks concurrently, each bounded by timeout, and
+// returns their results in the order given.
+func RunSelfCheck(ctx context.Context, checks []Check, timeout time.Duration) SelfCheckReport {
+	report := SelfCheckReport{Time: time.Now().UTC(), Results: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := check.Run(checkCtx)
+			res := CheckResult{Name: check.Name, OK: err == nil, DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			report.Results[i] = res
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// ProviderCheck pings the LLM provider serving model (see providers.Ping).
+func ProviderCheck(provider providers.LLMProvider, model string) Check {
+	return Check{Name: "provider", Run: func(ctx context.Context) error {
+		return providers.Ping(ctx, provider, model)
+	}}
+}
+
+// ChannelChecks returns one check per channel, failing for channels that
+// are enabled but not running.
+func ChannelChecks(src ChannelStatusSource) []Check {
+	status := src.GetStatus()
+	names := make([]string, 0, len(status))
+	for name := range status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, Check{Name: "channel:" + name, Run: func(context.Context) error {
+			state, _ := src.GetStatus()[name].(map[string]interface{})
+			if running, _ := state["running"].(bool); !running {
+				return errors.New("not running")
+			}
+			return nil
+		}})
+	}
+	return checks
+}
+
+// degradedSource is implemented by memory stores that can fall back to
+// read-only operation.
+type degradedSource interface {
+	Degraded() bool
+}
+
+// MemoryCheck checks that the memory DB opened and is writable. A nil src
+// means it failed to open.
+func MemoryCheck(src MemoryStatsSource) Check {
+	return Check{Name: "memory", Run: func(context.Context) error {
+		if src == nil {
+			return errors.New("memory DB unavailable")
+		}
+		if _, err := src.Stats(); err != nil {
+			return err
+		}
+		if d, ok := src.(degradedSource); ok && d.Degraded() {
+			return errors.New("memory DB is read-only")
+		}
+		return nil
+	}}
+}
+
+// CronCheck checks that the scheduler loaded its job store and is running.
+func CronCheck(src CronStatusSource) Check {
+	return Check{Name: "cron", Run: func(context.Context) error {
+		if running, _ := src.Status()["enabled"].(bool); !running {
+			return errors.New("scheduler not running (job store failed to load?)")
+		}
+		return nil
+	}}
+}
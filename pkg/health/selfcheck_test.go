@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type failingProvider struct{ err error }
+
+func (p failingProvider) Chat(context.Context, []providers.Message, []providers.ToolDefinition, string, map[string]interface{}) (*providers.LLMResponse, error) {
+	return nil, p.err
+}
+
+func (failingProvider) GetDefaultModel() string { return "test-model" }
+
+type stoppedChannels struct{}
+
+func (stoppedChannels) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"telegram": map[string]interface{}{"enabled": true, "running": true},
+		"discord":  map[string]interface{}{"enabled": true, "running": false},
+	}
+}
+
+func TestRunSelfCheck_ReportsFailingProviderPing(t *testing.T) {
+	provider := failingProvider{err: &providers.ProviderError{
+		Kind:       providers.ProviderErrorAuth,
+		StatusCode: 401,
+		Err:        errors.New("API error (HTTP 401): invalid key"),
+	}}
+	checks := []Check{ProviderCheck(provider, "test-model"), MemoryCheck(fakeMemory{}), CronCheck(fakeCron{})}
+
+	report := RunSelfCheck(context.Background(), checks, time.Second)
+
+	if report.OK() {
+		t.Fatal("report should fail when the provider ping fails")
+	}
+	want := "provider: FAILED (API error (HTTP 401): invalid key); memory: ok; cron: ok"
+	if got := report.Summary(); got != want {
+		t.Fatalf("Summary() = %q, want %q", got, want)
+	}
+
+	s := NewServer(Sources{})
+	s.SetSelfCheck(report)
+	body := getJSON(t, s.Handler(), "/status")
+	if body["status"] != "degraded" {
+		t.Fatalf("status = %v, want degraded", body["status"])
+	}
+	results := body["self_check"].(map[string]interface{})["results"].([]interface{})
+	provResult := results[0].(map[string]interface{})
+	if provResult["name"] != "provider" || provResult["ok"] != false || !strings.Contains(provResult["error"].(string), "invalid key") {
+		t.Fatalf("provider result = %v", provResult)
+	}
+}
+
+func TestSelfCheck_ComponentChecks(t *testing.T) {
+	checks := ChannelChecks(stoppedChannels{})
+	checks = append(checks, MemoryCheck(nil), MemoryCheck(fakeMemory{err: errors.New("db closed")}))
+
+	report := RunSelfCheck(context.Background(), checks, time.Second)
+
+	want := "channel:discord: FAILED (not running); channel:telegram: ok; memory: FAILED (memory DB unavailable); memory: FAILED (db closed)"
+	if got := report.Summary(); got != want {
+		t.Fatalf("Summary() = %q, want %q", got, want)
+	}
+}
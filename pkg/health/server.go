@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// ChannelStatusSource reports per-channel state (channels.Manager).
+type ChannelStatusSource interface {
+	GetStatus() map[string]interface{}
+}
+
+// CronStatusSource reports scheduler state (cron.CronService).
+type CronStatusSource interface {
+	Status() map[string]interface{}
+}
+
+// SubagentSource lists background tasks (tools.SubagentManager).
+type SubagentSource interface {
+	ListTasks() []*tools.SubagentTask
+}
+
+// MemoryStatsSource reports memory DB counts (memory.MemoryStore).
+type MemoryStatsSource interface {
+	Stats() (*memory.MemoryStats, error)
+}
+
+// Sources are the components summarized by /status. Nil sources are omitted
+// from the response.
+type Sources struct {
+	Channels  ChannelStatusSource
+	Cron      CronStatusSource
+	Subagents SubagentSource
+	Memory    MemoryStatsSource
+}
+
+// Server exposes /healthz (liveness) and /status (component summary) as JSON.
+type Server struct {
+	sources Sources
+	started time.Time
+	now     func() time.Time
+	srv     *http.Server
+	mounts  []mount
+
+	mu        sync.RWMutex
+	selfCheck *SelfCheckReport
+}
+
+type mount struct {
+	pattern string
+	handler http.Handler
+}
+
+func NewServer(sources Sources) *Server {
+	return &Server{
+		sources: sources,
+		started: time.Now(),
+		now:     time.Now,
+	}
+}
+
+// Handler returns the HTTP handler serving both endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/status", s.handleStatus)
+	for _, m := range s.mounts {
+		mux.Handle(m.pattern, m.handler)
+	}
+	return mux
+}
+
+// Mount serves handler at pattern alongside the built-in endpoints, e.g. a
+// channel webhook. Call it before Start.
+func (s *Server) Mount(pattern string, handler http.Handler) {
+	s.mounts = append(s.mounts, mount{pattern: pattern, handler: handler})
+}
+
+// Start listens on addr and serves in the background until Stop is called.
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.srv = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorCF("health", "Health server stopped", map[string]interface{}{
+				"addr":  addr,
+				"error": err.Error(),
+			})
+		}
+	}()
+	logger.InfoCF("health", "Health server listening", map[string]interface{}{"addr": ln.Addr().String()})
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) {
+	if s.srv == nil {
+		return
+	}
+	_ = s.srv.Shutdown(ctx)
+}
+
+// SetSelfCheck publishes the startup self-check results under "self_check"
+// in /status. Failed checks turn the overall status to "degraded".
+func (s *Server) SetSelfCheck(report SelfCheckReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selfCheck = &report
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"status": "ok"})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.status())
+}
+
+func (s *Server) status() map[string]interface{} {
+	uptime := s.now().Sub(s.started)
+	out := map[string]interface{}{
+		"status":         "ok",
+		"uptime_seconds": int64(uptime.Seconds()),
+	}
+
+	s.mu.RLock()
+	if s.selfCheck != nil {
+		out["self_check"] = s.selfCheck
+		if !s.selfCheck.OK() {
+			out["status"] = "degraded"
+		}
+	}
+	s.mu.RUnlock()
+	if s.sources.Channels != nil {
+		out["channels"] = s.sources.Channels.GetStatus()
+	}
+	if s.sources.Cron != nil {
+		out["cron"] = s.sources.Cron.Status()
+	}
+	if s.sources.Subagents != nil {
+		counts := map[string]int{"total": 0}
+		for _, task := range s.sources.Subagents.ListTasks() {
+			counts["total"]++
+			counts[task.Status]++
+		}
+		out["subagents"] = counts
+	}
+	if s.sources.Memory != nil {
+		stats, err := s.sources.Memory.Stats()
+		if err != nil {
+			out["memory"] = map[string]interface{}{"error": err.Error()}
+		} else {
+			out["memory"] = map[string]interface{}{
+				"total":       stats.Total,
+				"by_category": stats.ByCategory,
+			}
+		}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,125 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type fakeChannels struct{}
+
+func (fakeChannels) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"telegram": map[string]interface{}{"enabled": true, "running": true}}
+}
+
+type fakeCron struct{}
+
+func (fakeCron) Status() map[string]interface{} {
+	return map[string]interface{}{"enabled": true, "jobs": 3}
+}
+
+type fakeSubagents struct{}
+
+func (fakeSubagents) ListTasks() []*tools.SubagentTask {
+	return []*tools.SubagentTask{{Status: "running"}, {Status: "completed"}, {Status: "completed"}}
+}
+
+type fakeMemory struct{ err error }
+
+func (m fakeMemory) Stats() (*memory.MemoryStats, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &memory.MemoryStats{Total: 5, ByCategory: map[string]int{"fact": 5}}, nil
+}
+
+func getJSON(t *testing.T, h http.Handler, path string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d", path, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("GET %s content type = %q", path, ct)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s: invalid JSON: %v", path, err)
+	}
+	return body
+}
+
+func TestServer_Healthz(t *testing.T) {
+	body := getJSON(t, NewServer(Sources{}).Handler(), "/healthz")
+	if body["status"] != "ok" {
+		t.Fatalf("healthz = %v", body)
+	}
+}
+
+func TestServer_StatusReportsComponents(t *testing.T) {
+	s := NewServer(Sources{
+		Channels:  fakeChannels{},
+		Cron:      fakeCron{},
+		Subagents: fakeSubagents{},
+		Memory:    fakeMemory{},
+	})
+	s.now = func() time.Time { return s.started.Add(90 * time.Second) }
+
+	body := getJSON(t, s.Handler(), "/status")
+
+	if body["uptime_seconds"] != float64(90) {
+		t.Fatalf("uptime_seconds = %v, want 90", body["uptime_seconds"])
+	}
+	telegram, _ := body["channels"].(map[string]interface{})["telegram"].(map[string]interface{})
+	if telegram["running"] != true {
+		t.Fatalf("channels = %v", body["channels"])
+	}
+	if cron, _ := body["cron"].(map[string]interface{}); cron["jobs"] != float64(3) {
+		t.Fatalf("cron = %v", body["cron"])
+	}
+	subagents, _ := body["subagents"].(map[string]interface{})
+	if subagents["total"] != float64(3) || subagents["running"] != float64(1) || subagents["completed"] != float64(2) {
+		t.Fatalf("subagents = %v", body["subagents"])
+	}
+	mem, _ := body["memory"].(map[string]interface{})
+	if mem["total"] != float64(5) {
+		t.Fatalf("memory = %v", body["memory"])
+	}
+}
+
+func TestServer_StatusOmitsMissingSourcesAndReportsMemoryErrors(t *testing.T) {
+	body := getJSON(t, NewServer(Sources{Memory: fakeMemory{err: errors.New("db closed")}}).Handler(), "/status")
+
+	for _, key := range []string{"channels", "cron", "subagents"} {
+		if _, ok := body[key]; ok {
+			t.Fatalf("expected %q to be omitted, got %v", key, body)
+		}
+	}
+	if mem, _ := body["memory"].(map[string]interface{}); mem["error"] != "db closed" {
+		t.Fatalf("memory = %v", body["memory"])
+	}
+}
+
+func TestServer_MountServesExtraHandler(t *testing.T) {
+	s := NewServer(Sources{})
+	s.Mount("/telegram/webhook", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	h := s.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/telegram/webhook", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("mounted handler status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if body := getJSON(t, h, "/healthz"); body["status"] != "ok" {
+		t.Fatalf("healthz = %v", body)
+	}
+}
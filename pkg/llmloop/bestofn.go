@@ -0,0 +1,176 @@
+package llmloop
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Selector picks the best of several candidate answers and returns its index.
+// messages is the request the candidates answer.
+type Selector func(ctx context.Context, messages []providers.Message, candidates []string) (int, error)
+
+// answerOf returns the answer resp gives the user. With deliveryTool set it
+// is the content of a lone call to that tool; otherwise it is a final
+// (no-tool) reply.
+func answerOf(resp *providers.LLMResponse, deliveryTool string) (string, bool) {
+	if resp == nil {
+		return "", false
+	}
+	if deliveryTool == "" {
+		return resp.Content, len(resp.ToolCalls) == 0
+	}
+	if len(resp.ToolCalls) != 1 {
+		return "", false
+	}
+	call := providers.AssistantMessageFromResponse(resp).ToolCalls[0]
+	if call.Name != deliveryTool {
+		return "", false
+	}
+	content, ok := call.Arguments["content"].(string)
+	return content, ok
+}
+
+// withDeliveryContent returns resp with the content of its lone delivery call
+// replaced. resp is not modified.
+func withDeliveryContent(resp *providers.LLMResponse, content string) *providers.LLMResponse {
+	call := providers.AssistantMessageFromResponse(resp).ToolCalls[0]
+	args := make(map[string]interface{}, len(call.Arguments))
+	for k, v := range call.Arguments {
+		args[k] = v
+	}
+	args["content"] = content
+	call.Arguments = args
+	call.Function = nil // re-encoded from Arguments
+	out := *resp
+	out.ToolCalls = []providers.ToolCall{call}
+	return &out
+}
+
+// sampleCandidates draws extra completions for the request that just produced
+// first and returns the non-empty answers among first and the samples, first
+// leading. Answers are read with answerOf, so samples of the other kind
+// (calling other tools, or not delivering) are dropped, as are failed ones.
+func sampleCandidates(ctx context.Context, opts RunOptions, messages []providers.Message, toolDefs []providers.ToolDefinition, first string, deliveryTool string) []string {
+	extra := make([]string, opts.BestOfN-1)
+	var wg sync.WaitGroup
+	for i := range extra {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := providers.ChatWithTimeout(ctx, opts.LLMTimeout, opts.Provider, messages, toolDefs, opts.Model, opts.ChatOptions)
+			if err != nil {
+				return
+			}
+			if answer, ok := answerOf(resp, deliveryTool); ok {
+				extra[i] = answer
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var candidates []string
+	for _, c := range append([]string{first}, extra...) {
+		if strings.TrimSpace(c) != "" {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// pickBest samples opts.BestOfN answers to messages, counting first, and
+// returns the one the selector picks. Empty answers never win; first is
+// returned when every answer is empty.
+func pickBest(ctx context.Context, opts RunOptions, iteration int, messages []providers.Message, toolDefs []providers.ToolDefinition, first string, deliveryTool string) string {
+	candidates := sampleCandidates(ctx, opts, messages, toolDefs, first, deliveryTool)
+	if len(candidates) == 0 {
+		return first
+	}
+	chosen := selectCandidate(ctx, opts.SelectBest, messages, candidates)
+	if opts.Hooks.BestOfNSelected != nil {
+		opts.Hooks.BestOfNSelected(iteration, len(candidates), chosen)
+	}
+	return candidates[chosen]
+}
+
+// selectCandidate runs the selector, falling back to the first candidate when
+// it fails or returns an index out of range.
+func selectCandidate(ctx context.Context, selector Selector, messages []providers.Message, candidates []string) int {
+	if len(candidates) < 2 {
+		return 0
+	}
+	if selector == nil {
+		selector = MajorityVote
+	}
+	chosen, err := selector(ctx, messages, candidates)
+	if err != nil || chosen < 0 || chosen >= len(candidates) {
+		return 0
+	}
+	return chosen
+}
+
+// MajorityVote picks the answer given most often, comparing answers with
+// case and whitespace normalized. Ties go to the earliest candidate.
+func MajorityVote(_ context.Context, _ []providers.Message, candidates []string) (int, error) {
+	keys := make([]string, len(candidates))
+	counts := make(map[string]int, len(candidates))
+	for i, c := range candidates {
+		keys[i] = strings.ToLower(strings.Join(strings.Fields(c), " "))
+		counts[keys[i]]++
+	}
+	best := 0
+	for i, key := range keys {
+		if counts[key] > counts[keys[best]] {
+			best = i
+		}
+	}
+	return best, nil
+}
+
+var judgeChoiceRe = regexp.MustCompile(`\d+`)
+
+// JudgeSelector asks model to pick the best candidate. It sees the last user
+// message and the numbered candidates, and must reply with a number.
+func JudgeSelector(provider providers.LLMProvider, model string, timeout time.Duration) Selector {
+	return func(ctx context.Context, messages []providers.Message, candidates []string) (int, error) {
+		question := ""
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				question = messages[i].Content
+				break
+			}
+		}
+
+		var sb strings.Builder
+		sb.WriteString("User request:\n")
+		sb.WriteString(question)
+		sb.WriteString("\n\nCandidate answers:\n")
+		for i, c := range candidates {
+			fmt.Fprintf(&sb, "\n[%d]\n%s\n", i+1, c)
+		}
+		sb.WriteString("\nWhich candidate answers the request best (correct, complete, and clear)? Reply with the number only.")
+
+		resp, err := providers.ChatWithTimeout(ctx, timeout, provider, []providers.Message{
+			{Role: "system", Content: "You compare candidate answers and pick the best one."},
+			{Role: "user", Content: sb.String()},
+		}, nil, model, map[string]interface{}{"max_tokens": 16, "temperature": 0.0})
+		if err != nil {
+			return 0, err
+		}
+		match := judgeChoiceRe.FindString(resp.Content)
+		if match == "" {
+			return 0, fmt.Errorf("judge reply has no candidate number: %q", resp.Content)
+		}
+		n, _ := strconv.Atoi(match)
+		if n < 1 || n > len(candidates) {
+			return 0, fmt.Errorf("judge picked candidate %d of %d", n, len(candidates))
+		}
+		return n - 1, nil
+	}
+}
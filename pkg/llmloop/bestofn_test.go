@@ -0,0 +1,211 @@
+package llmloop
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// samplingProvider requests one tool call, then answers every later call
+// with a distinct candidate. It is safe for concurrent use.
+type samplingProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *samplingProvider) Chat(_ context.Context, _ []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls == 1 {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{ID: "c1", Name: "lookup", Arguments: map[string]interface{}{}}}}, nil
+	}
+	return &providers.LLMResponse{Content: fmt.Sprintf("candidate-%d", p.calls-1)}, nil
+}
+
+func (p *samplingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRun_BestOfNSamplesFinalAnswerAndUsesSelector(t *testing.T) {
+	p := &samplingProvider{}
+	var judged []string
+	var hookCandidates, hookChosen int
+
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 3,
+		Messages:      []providers.Message{{Role: "user", Content: "hi"}},
+		BestOfN:       3,
+		SelectBest: func(_ context.Context, _ []providers.Message, candidates []string) (int, error) {
+			judged = append([]string(nil), candidates...)
+			for i, c := range candidates {
+				if c == "candidate-2" {
+					return i, nil
+				}
+			}
+			return 0, fmt.Errorf("candidate-2 missing")
+		},
+		ExecuteTools: func(_ context.Context, toolCalls []providers.ToolCall, _ int) []providers.Message {
+			return []providers.Message{{Role: "tool", Content: "ok", ToolCallID: toolCalls[0].ID}}
+		},
+		Hooks: Hooks{
+			BestOfNSelected: func(_ int, candidates int, chosen int) {
+				hookCandidates, hookChosen = candidates, chosen
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalContent != "candidate-2" {
+		t.Fatalf("FinalContent = %q, want candidate-2", res.FinalContent)
+	}
+	// One call for the tool iteration, three samples for the final answer.
+	if p.calls != 4 {
+		t.Fatalf("provider calls = %d, want 4", p.calls)
+	}
+	if len(judged) != 3 || judged[0] != "candidate-1" {
+		t.Fatalf("selector saw %v, want 3 candidates starting with the first answer", judged)
+	}
+	if hookCandidates != 3 || judged[hookChosen] != "candidate-2" {
+		t.Fatalf("hook = (%d, %d), want 3 candidates and the candidate-2 index", hookCandidates, hookChosen)
+	}
+}
+
+func TestRun_BestOfNFallsBackToFirstWhenSelectorFails(t *testing.T) {
+	p := &mockProvider{responses: []*providers.LLMResponse{{Content: "first"}, {Content: "second"}}}
+
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		MaxIterations: 1,
+		Messages:      []providers.Message{{Role: "user", Content: "hi"}},
+		BestOfN:       2,
+		SelectBest: func(context.Context, []providers.Message, []string) (int, error) {
+			return 0, fmt.Errorf("judge unavailable")
+		},
+	})
+	if err != nil || res.FinalContent != "first" {
+		t.Fatalf("Run() = %q, %v; want the first answer", res.FinalContent, err)
+	}
+}
+
+// deliveringProvider answers with message tool calls carrying the drafts in
+// order, then with an empty final reply once a tool result is in the
+// request. It is safe for concurrent use.
+type deliveringProvider struct {
+	mu     sync.Mutex
+	drafts []string
+	calls  int
+}
+
+func (p *deliveringProvider) Chat(_ context.Context, messages []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if messages[len(messages)-1].Role == "tool" {
+		return &providers.LLMResponse{}, nil
+	}
+	draft := p.drafts[0]
+	p.drafts = p.drafts[1:]
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+		ID:        "m1",
+		Name:      "message",
+		Arguments: map[string]interface{}{"content": draft, "channel": "telegram", "chat_id": "42"},
+	}}}, nil
+}
+
+func (p *deliveringProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRun_BestOfNSelectsMessageToolDelivery(t *testing.T) {
+	p := &deliveringProvider{drafts: []string{"Lyon", "Paris", "paris"}}
+	var delivered []providers.ToolCall
+	var history []providers.Message
+
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		MaxIterations: 3,
+		Messages:      []providers.Message{{Role: "user", Content: "capital of France?"}},
+		BestOfN:       3,
+		DeliveryTool:  "message",
+		ExecuteTools: func(_ context.Context, toolCalls []providers.ToolCall, _ int) []providers.Message {
+			delivered = append(delivered, toolCalls...)
+			return []providers.Message{{Role: "tool", Content: "Message sent to telegram:42", ToolCallID: toolCalls[0].ID}}
+		},
+		Hooks: Hooks{
+			AssistantMessage: func(_ int, msg providers.Message) {
+				history = append(history, msg)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(delivered))
+	}
+	args := delivered[0].Arguments
+	if got := strings.ToLower(args["content"].(string)); got != "paris" {
+		t.Fatalf("delivered %q, want the majority answer", args["content"])
+	}
+	if args["channel"] != "telegram" || args["chat_id"] != "42" {
+		t.Fatalf("delivery target changed: %v", args)
+	}
+	if raw := history[0].ToolCalls[0].Function.Arguments; strings.Contains(raw, "Lyon") {
+		t.Fatalf("history keeps the discarded draft: %s", raw)
+	}
+	// Three samples for the delivery, three for the empty final reply.
+	if p.calls != 6 || res.FinalContent != "" {
+		t.Fatalf("calls = %d, final = %q", p.calls, res.FinalContent)
+	}
+}
+
+func TestRun_BestOfNEmptyAnswerNeverWins(t *testing.T) {
+	p := &mockProvider{responses: []*providers.LLMResponse{{Content: ""}, {Content: "Paris"}}}
+
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		MaxIterations: 1,
+		Messages:      []providers.Message{{Role: "user", Content: "capital of France?"}},
+		BestOfN:       2,
+	})
+	if err != nil || res.FinalContent != "Paris" {
+		t.Fatalf("Run() = %q, %v; want the non-empty answer", res.FinalContent, err)
+	}
+}
+
+func TestMajorityVote(t *testing.T) {
+	cases := []struct {
+		candidates []string
+		want       int
+	}{
+		{[]string{"Lyon", "Paris", "paris ", "Lyon."}, 1},
+		{[]string{"a", "b"}, 0},
+		{[]string{"a", "b", "b"}, 1},
+	}
+	for _, tc := range cases {
+		if got, _ := MajorityVote(context.Background(), nil, tc.candidates); got != tc.want {
+			t.Errorf("MajorityVote(%q) = %d, want %d", tc.candidates, got, tc.want)
+		}
+	}
+}
+
+func TestJudgeSelector_ParsesChoice(t *testing.T) {
+	p := &mockProvider{responses: []*providers.LLMResponse{{Content: "Candidate 2 is best."}, {Content: "7"}}}
+	judge := JudgeSelector(p, "judge-model", 0)
+	messages := []providers.Message{{Role: "user", Content: "capital of France?"}}
+
+	got, err := judge(context.Background(), messages, []string{"Lyon", "Paris"})
+	if err != nil || got != 1 {
+		t.Fatalf("judge = %d, %v; want 1", got, err)
+	}
+	if prompt := p.seenMsgs[0][1].Content; !strings.Contains(prompt, "capital of France?") || !strings.Contains(prompt, "[2]\nParis") {
+		t.Fatalf("judge prompt missing request or candidates:\n%s", prompt)
+	}
+	if _, err := judge(context.Background(), messages, []string{"Lyon", "Paris"}); err == nil {
+		t.Fatal("expected an error for an out-of-range choice")
+	}
+}
@@ -0,0 +1,84 @@
+package llmloop
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// loopDetector spots a model calling the same tool with the same arguments
+// over and over. It keeps a rolling window of recent call signatures; a
+// signature seen threshold times within the window is a loop.
+type loopDetector struct {
+	threshold int
+	window    []string
+	size      int
+	warned    map[string]bool
+}
+
+// newLoopDetector returns nil (disabled) when threshold < 2.
+func newLoopDetector(threshold int) *loopDetector {
+	if threshold < 2 {
+		return nil
+	}
+	return &loopDetector{
+		threshold: threshold,
+		size:      threshold * 2,
+		warned:    make(map[string]bool),
+	}
+}
+
+// repeatedCall describes a detected loop.
+type repeatedCall struct {
+	Name   string
+	Count  int
+	Warned bool // the model was already told it is looping on this call
+}
+
+// observe records one iteration's tool calls and reports a call that has
+// now repeated threshold times within the window.
+func (d *loopDetector) observe(toolCalls []providers.ToolCall) (repeatedCall, bool) {
+	var found repeatedCall
+	detected := false
+	for _, tc := range toolCalls {
+		sig := loopSignature(tc)
+		d.window = append(d.window, sig)
+		if len(d.window) > d.size {
+			d.window = d.window[len(d.window)-d.size:]
+		}
+		count := 0
+		for _, s := range d.window {
+			if s == sig {
+				count++
+			}
+		}
+		if count >= d.threshold && !detected {
+			found = repeatedCall{Name: tc.Name, Count: count, Warned: d.warned[sig]}
+			d.warned[sig] = true
+			detected = true
+		}
+	}
+	return found, detected
+}
+
+// loopSignature identifies a call by tool name and a hash of its arguments.
+// Map keys marshal in sorted order, so argument order does not matter.
+func loopSignature(tc providers.ToolCall) string {
+	name := tc.Name
+	var args []byte
+	if tc.Arguments != nil {
+		args, _ = json.Marshal(tc.Arguments)
+	} else if tc.Function != nil {
+		args = []byte(tc.Function.Arguments)
+	}
+	if name == "" && tc.Function != nil {
+		name = tc.Function.Name
+	}
+	return fmt.Sprintf("%s:%x", name, sha256.Sum256(args))
+}
+
+func loopWarning(call repeatedCall) string {
+	return fmt.Sprintf("NOTE: You have called `%s` with the same arguments %d times and it is not making progress. Do not repeat this call. Try a different approach, or answer the user with what you have so far.", call.Name, call.Count)
+}
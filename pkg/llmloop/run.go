@@ -9,13 +9,26 @@ import (
 )
 
 type Hooks struct {
-	BeforeLLMCall      func(iteration int, messages []providers.Message, toolDefs []providers.ToolDefinition)
-	MessagesBudgeted   func(iteration int, stats providers.MessageBudgetStats)
-	LLMCallFailed      func(iteration int, err error)
+	BeforeLLMCall    func(iteration int, messages []providers.Message, toolDefs []providers.ToolDefinition)
+	MessagesBudgeted func(iteration int, stats providers.MessageBudgetStats)
+	LLMCallFailed    func(iteration int, err error)
+	// AfterLLMCall fires after each successful LLM call with its response
+	// (including token usage) and how long it took.
+	AfterLLMCall       func(iteration int, resp *providers.LLMResponse, duration time.Duration)
 	ToolCallsRequested func(iteration int, toolCalls []providers.ToolCall)
 	DirectResponse     func(iteration int, content string)
 	AssistantMessage   func(iteration int, msg providers.Message)
-	ToolResultMessage  func(iteration int, msg providers.Message)
+	// InterimContent fires when the model returns prose alongside tool calls.
+	// The prose is kept on the assistant message either way.
+	InterimContent    func(iteration int, content string)
+	ToolResultMessage func(iteration int, msg providers.Message)
+	// LoopDetected fires when the same tool call repeats LoopThreshold times.
+	// stopped is true when the run ends because the model kept repeating it
+	// after being warned.
+	LoopDetected func(iteration int, toolName string, count int, stopped bool)
+	// BestOfNSelected fires after the final answer was picked from
+	// candidates samples; chosen is the index of the winner.
+	BestOfNSelected func(iteration int, candidates int, chosen int)
 }
 
 type RunOptions struct {
@@ -23,9 +36,27 @@ type RunOptions struct {
 	Model         string
 	MaxIterations int
 	LLMTimeout    time.Duration
+	// RetryBudget, when set, is shared by every LLM call of this run so that
+	// provider retries across iterations cannot exceed it collectively.
+	RetryBudget   *providers.RetryBudget
 	ChatOptions   map[string]interface{}
 	MessageBudget providers.MessageBudget
 	Messages      []providers.Message
+	// LoopThreshold is how often an identical tool call (same name and
+	// arguments) may repeat within a rolling window before the model is told
+	// it is looping. Repeating it again after that ends the run with
+	// LoopStopped set. Values below 2 disable detection.
+	LoopThreshold int
+	// BestOfN, when above 1, samples the answer N times in parallel and keeps
+	// the one SelectBest picks. The answer is the final (no-tool) reply, or
+	// the content of a lone DeliveryTool call. Other iterations that call
+	// tools are sampled once.
+	BestOfN int
+	// DeliveryTool names the tool whose "content" argument reaches the user
+	// (the message tool), so that best-of-N also applies to it.
+	DeliveryTool string
+	// SelectBest picks among best-of-N candidates; nil uses MajorityVote.
+	SelectBest Selector
 
 	BuildToolDefs func(iteration int, messages []providers.Message) []providers.ToolDefinition
 	ExecuteTools  func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message
@@ -38,6 +69,9 @@ type RunResult struct {
 	FinalContent string
 	Iterations   int
 	Exhausted    bool
+	// LoopStopped is set (with Exhausted) when the run was cut short because
+	// the model kept repeating the same tool call.
+	LoopStopped bool
 }
 
 // Run executes a standard LLM/tool-call iteration loop.
@@ -71,6 +105,9 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 		if err == nil {
 			return false
 		}
+		if providers.ProviderErrorKindOf(err) == providers.ProviderErrorRefused {
+			return true
+		}
 		msg := strings.ToLower(err.Error())
 		patterns := []string{
 			"content policy",
@@ -101,6 +138,9 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 		return result, nil
 	}
 
+	ctx = providers.WithRetryBudget(ctx, opts.RetryBudget)
+	loops := newLoopDetector(opts.LoopThreshold)
+
 	for iteration := 1; iteration <= opts.MaxIterations; iteration++ {
 		result.Iterations = iteration
 		requestMessages := result.Messages
@@ -121,6 +161,7 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 			opts.Hooks.BeforeLLMCall(iteration, requestMessages, toolDefs)
 		}
 
+		callStart := time.Now()
 		resp, err := providers.ChatWithTimeout(
 			ctx,
 			opts.LLMTimeout,
@@ -155,9 +196,15 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 				return result, err
 			}
 		}
+		if opts.Hooks.AfterLLMCall != nil {
+			opts.Hooks.AfterLLMCall(iteration, resp, time.Since(callStart))
+		}
 
 		if len(resp.ToolCalls) == 0 {
 			result.FinalContent = resp.Content
+			if opts.BestOfN > 1 {
+				result.FinalContent = pickBest(ctx, opts, iteration, requestMessages, toolDefs, resp.Content, "")
+			}
 			result.Exhausted = false
 			if opts.Hooks.DirectResponse != nil {
 				opts.Hooks.DirectResponse(iteration, result.FinalContent)
@@ -165,6 +212,12 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 			return result, nil
 		}
 
+		if opts.BestOfN > 1 && opts.DeliveryTool != "" {
+			if content, ok := answerOf(resp, opts.DeliveryTool); ok {
+				resp = withDeliveryContent(resp, pickBest(ctx, opts, iteration, requestMessages, toolDefs, content, opts.DeliveryTool))
+			}
+		}
+
 		if opts.Hooks.ToolCallsRequested != nil {
 			opts.Hooks.ToolCallsRequested(iteration, resp.ToolCalls)
 		}
@@ -174,6 +227,9 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 		if opts.Hooks.AssistantMessage != nil {
 			opts.Hooks.AssistantMessage(iteration, assistantMsg)
 		}
+		if interim := strings.TrimSpace(assistantMsg.Content); interim != "" && opts.Hooks.InterimContent != nil {
+			opts.Hooks.InterimContent(iteration, interim)
+		}
 
 		var toolResults []providers.Message
 		if opts.ExecuteTools != nil {
@@ -185,6 +241,22 @@ func Run(ctx context.Context, opts RunOptions) (RunResult, error) {
 				opts.Hooks.ToolResultMessage(iteration, tr)
 			}
 		}
+
+		if loops != nil {
+			if call, ok := loops.observe(resp.ToolCalls); ok {
+				if opts.Hooks.LoopDetected != nil {
+					opts.Hooks.LoopDetected(iteration, call.Name, call.Count, call.Warned)
+				}
+				if call.Warned {
+					result.LoopStopped = true
+					return result, nil
+				}
+				result.Messages = append(result.Messages, providers.Message{
+					Role:    "system",
+					Content: loopWarning(call),
+				})
+			}
+		}
 	}
 
 	return result, nil
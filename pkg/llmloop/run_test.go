@@ -3,8 +3,10 @@ package llmloop
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
@@ -122,6 +124,44 @@ func TestRun_ToolCallFlow(t *testing.T) {
 	}
 }
 
+func TestRun_KeepsInterimContentWithToolCalls(t *testing.T) {
+	p := &mockProvider{responses: []*providers.LLMResponse{
+		{Content: "Let me check...", ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "tool", Arguments: map[string]interface{}{}}}},
+		{Content: "done"},
+	}}
+
+	var interim []string
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 3,
+		Messages:      []providers.Message{{Role: "user", Content: "run"}},
+		ExecuteTools: func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message {
+			return []providers.Message{providers.ToolResultMessage("tc1", "tool_ok")}
+		},
+		Hooks: Hooks{
+			InterimContent: func(iteration int, content string) {
+				interim = append(interim, content)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.FinalContent != "done" {
+		t.Fatalf("FinalContent = %q, want done", res.FinalContent)
+	}
+	if res.Messages[1].Role != "assistant" || res.Messages[1].Content != "Let me check..." {
+		t.Fatalf("assistant message lost interim content: %+v", res.Messages[1])
+	}
+	if len(res.Messages[1].ToolCalls) != 1 {
+		t.Fatalf("assistant message lost tool calls: %+v", res.Messages[1])
+	}
+	if len(interim) != 1 || interim[0] != "Let me check..." {
+		t.Fatalf("InterimContent hook calls = %v", interim)
+	}
+}
+
 func TestRun_Exhausted(t *testing.T) {
 	p := &mockProvider{responses: []*providers.LLMResponse{
 		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "tool", Arguments: map[string]interface{}{}}}},
@@ -292,3 +332,163 @@ func TestRun_RetriesWithoutImagesOnPolicyError(t *testing.T) {
 		t.Fatal("expected retry attempt to strip image parts")
 	}
 }
+
+type budgetRecordingProvider struct {
+	seen []*providers.RetryBudget
+}
+
+func (p *budgetRecordingProvider) Chat(ctx context.Context, _ []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.seen = append(p.seen, providers.RetryBudgetFromContext(ctx))
+	if len(p.seen) < 3 {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{ID: "tc", Name: "tool"}}}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *budgetRecordingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestRun_SharesRetryBudgetAcrossIterations(t *testing.T) {
+	p := &budgetRecordingProvider{}
+	budget := providers.NewRetryBudget(3, 0)
+
+	_, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 5,
+		RetryBudget:   budget,
+		Messages:      []providers.Message{{Role: "user", Content: "run"}},
+		ExecuteTools: func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message {
+			return []providers.Message{providers.ToolResultMessage("tc", "ok")}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.seen) != 3 {
+		t.Fatalf("expected 3 LLM calls, got %d", len(p.seen))
+	}
+	for i, b := range p.seen {
+		if b != budget {
+			t.Fatalf("call %d did not receive the shared retry budget", i+1)
+		}
+	}
+}
+
+func TestRun_WarnsThenStopsOnRepeatedToolCall(t *testing.T) {
+	repeat := func() *providers.LLMResponse {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID: "tc", Name: "read_file", Arguments: map[string]interface{}{"path": "a.txt", "offset": 0},
+		}}}
+	}
+	p := &mockProvider{responses: []*providers.LLMResponse{repeat(), repeat(), repeat(), repeat(), repeat()}}
+
+	type detection struct {
+		iteration int
+		count     int
+		stopped   bool
+	}
+	var detections []detection
+	executed := 0
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 10,
+		LoopThreshold: 3,
+		Messages:      []providers.Message{{Role: "user", Content: "read it"}},
+		ExecuteTools: func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message {
+			executed++
+			return []providers.Message{providers.ToolResultMessage(toolCalls[0].ID, "same content")}
+		},
+		Hooks: Hooks{
+			LoopDetected: func(iteration int, toolName string, count int, stopped bool) {
+				if toolName != "read_file" {
+					t.Errorf("toolName = %q", toolName)
+				}
+				detections = append(detections, detection{iteration, count, stopped})
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []detection{{3, 3, false}, {4, 4, true}}
+	if len(detections) != len(want) || detections[0] != want[0] || detections[1] != want[1] {
+		t.Fatalf("detections = %+v, want %+v", detections, want)
+	}
+	if !res.LoopStopped || !res.Exhausted || res.Iterations != 4 || executed != 4 {
+		t.Fatalf("result = %+v (executed %d), want loop stop after 4 iterations", res, executed)
+	}
+
+	// The warning reached the model on the iteration after detection.
+	lastSeen := p.seenMsgs[3]
+	warning := lastSeen[len(lastSeen)-1]
+	if warning.Role != "system" || !strings.Contains(warning.Content, "`read_file` with the same arguments 3 times") {
+		t.Fatalf("expected loop warning before 4th call, got %#v", warning)
+	}
+}
+
+func TestRun_DifferentArgumentsAreNotALoop(t *testing.T) {
+	var responses []*providers.LLMResponse
+	for i := 0; i < 4; i++ {
+		responses = append(responses, &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID: "tc", Name: "read_file", Arguments: map[string]interface{}{"offset": i},
+		}}})
+	}
+	responses = append(responses, &providers.LLMResponse{Content: "done"})
+	p := &mockProvider{responses: responses}
+
+	res, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 10,
+		LoopThreshold: 2,
+		Messages:      []providers.Message{{Role: "user", Content: "page through"}},
+		ExecuteTools: func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message {
+			return []providers.Message{providers.ToolResultMessage(toolCalls[0].ID, "page")}
+		},
+		Hooks: Hooks{
+			LoopDetected: func(int, string, int, bool) { t.Fatal("unexpected loop detection") },
+		},
+	})
+	if err != nil || res.FinalContent != "done" || res.LoopStopped {
+		t.Fatalf("Run = %+v, %v; want done", res, err)
+	}
+}
+
+func TestRun_AfterLLMCallReportsEachResponse(t *testing.T) {
+	p := &mockProvider{responses: []*providers.LLMResponse{
+		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "tool", Arguments: map[string]interface{}{}}}, Usage: &providers.UsageInfo{TotalTokens: 10}},
+		{Content: "done", Usage: &providers.UsageInfo{TotalTokens: 15}},
+	}}
+
+	var seen []string
+	_, err := Run(context.Background(), RunOptions{
+		Provider:      p,
+		Model:         "test-model",
+		MaxIterations: 3,
+		Messages:      []providers.Message{{Role: "user", Content: "run"}},
+		ExecuteTools: func(ctx context.Context, toolCalls []providers.ToolCall, iteration int) []providers.Message {
+			seen = append(seen, "tools")
+			return []providers.Message{providers.ToolResultMessage("tc1", "tool_ok")}
+		},
+		Hooks: Hooks{
+			BeforeLLMCall: func(iteration int, _ []providers.Message, _ []providers.ToolDefinition) {
+				seen = append(seen, fmt.Sprintf("before:%d", iteration))
+			},
+			AfterLLMCall: func(iteration int, resp *providers.LLMResponse, duration time.Duration) {
+				if duration < 0 {
+					t.Errorf("negative duration %v", duration)
+				}
+				seen = append(seen, fmt.Sprintf("after:%d:%d", iteration, resp.Usage.TotalTokens))
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "before:1,after:1:10,tools,before:2,after:2:15"
+	if got := strings.Join(seen, ","); got != want {
+		t.Fatalf("hook order = %s, want %s", got, want)
+	}
+}
@@ -37,7 +37,7 @@ var (
 )
 
 type Logger struct {
-	file *os.File
+	file *rotatingFile
 }
 
 type LogEntry struct {
@@ -67,11 +67,27 @@ func GetLevel() LogLevel {
 	return currentLevel
 }
 
+// EnableFileLogging mirrors every log entry to filePath as JSON lines, in
+// addition to stdout. The file grows without limit.
 func EnableFileLogging(filePath string) error {
+	return EnableRotatingFileLogging(filePath, 0, 0)
+}
+
+// EnableRotatingFileLogging is EnableFileLogging with size-based rotation:
+// once the file would exceed maxSizeBytes it is renamed to filePath.1 (older
+// files shift up) and at most maxFiles rotated files are kept. maxSizeBytes
+// <= 0 disables rotation.
+func EnableRotatingFileLogging(filePath string, maxSizeBytes int64, maxFiles int) error {
 	mu.Lock()
 	defer mu.Unlock()
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if maxSizeBytes < 0 {
+		maxSizeBytes = 0
+	}
+	if maxFiles < 0 {
+		maxFiles = 0
+	}
+	file, err := openRotatingFile(filePath, maxSizeBytes, maxFiles)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %w", err)
 	}
@@ -97,7 +113,7 @@ func DisableFileLogging() {
 }
 
 func logMessage(level LogLevel, component string, message string, fields map[string]interface{}) {
-	if level < currentLevel {
+	if level < GetLevel() {
 		return
 	}
 
@@ -116,10 +132,13 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 		}
 	}
 
-	if logger.file != nil {
+	mu.RLock()
+	file := logger.file
+	mu.RUnlock()
+	if file != nil {
 		jsonData, err := json.Marshal(entry)
 		if err == nil {
-			logger.file.WriteString(string(jsonData) + "\n")
+			file.Write(append(jsonData, '\n'))
 		}
 	}
 
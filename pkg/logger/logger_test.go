@@ -1,6 +1,11 @@
 package logger
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -137,3 +142,50 @@ func TestLoggerHelperFunctions(t *testing.T) {
 	DebugC("test", "Debug with component")
 	WarnF("Warning with fields", map[string]interface{}{"key": "value"})
 }
+
+func TestRotatingFileLogging_WritesJSONLinesAndRotates(t *testing.T) {
+	initialLevel := GetLevel()
+	defer SetLevel(initialLevel)
+	SetLevel(INFO)
+
+	path := filepath.Join(t.TempDir(), "logs", "picoclaw.log")
+	if err := EnableRotatingFileLogging(path, 2048, 2); err != nil {
+		t.Fatalf("EnableRotatingFileLogging: %v", err)
+	}
+	defer DisableFileLogging()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				InfoCF("test", "rotation check", map[string]interface{}{"goroutine": g, "i": i})
+			}
+		}(g)
+	}
+	wg.Wait()
+	DisableFileLogging()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", filepath.Base(name), err)
+		}
+		if len(data) > 2048 {
+			t.Fatalf("%s is %d bytes, above the 2048 byte cap", filepath.Base(name), len(data))
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var entry LogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("invalid JSON line in %s: %q: %v", filepath.Base(name), line, err)
+			}
+			if entry.Level != "INFO" || entry.Component != "test" || entry.Message != "rotation check" {
+				t.Fatalf("unexpected entry: %+v", entry)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 rotated files, stat .3 err = %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// rotatingFile is an append-only log file that rolls over to path.1, path.2,
+// ... once it would grow past maxSize, keeping at most maxFiles old files.
+// Writes from concurrent goroutines are serialized, so JSON lines never
+// interleave.
+type rotatingFile struct {
+	path     string
+	maxSize  int64 // 0 = never rotate
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &rotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		file:     file,
+		size:     info.Size(),
+	}, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotateLocked shifts path.N-1 -> path.N ... path -> path.1, drops the oldest
+// file and reopens path empty.
+func (f *rotatingFile) rotateLocked() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxFiles > 0 {
+		_ = os.Remove(f.backupName(f.maxFiles))
+		for i := f.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(f.backupName(i), f.backupName(i+1))
+		}
+		if err := os.Rename(f.path, f.backupName(1)); err != nil {
+			return fmt.Errorf("rotate log file: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("reopen log file: %w", err)
+	}
+	f.file = file
+	f.size = 0
+	return nil
+}
+
+func (f *rotatingFile) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExportSnapshot writes every stored memory to path as one markdown
+// document, grouped by category with the date each was recorded. The
+// output depends only on the stored memories, so successive snapshots diff
+// cleanly. It is a read-only export: the file is not a write-through target
+// and is never reindexed.
+func (s *MemoryStore) ExportSnapshot(path string) error {
+	rows, err := s.db.Query(`
+		SELECT id, content, category, source, metadata, created_at, updated_at
+		FROM memories ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	memories, err := scanMemories(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(renderSnapshot(memories, s.Categories())), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// renderSnapshot formats memories (oldest first) under one heading per
+// category: known categories in their usual order, then any legacy ones
+// alphabetically.
+func renderSnapshot(memories []Memory, known []string) string {
+	byCategory := make(map[string][]Memory)
+	for _, m := range memories {
+		byCategory[m.Category] = append(byCategory[m.Category], m)
+	}
+
+	order := make([]string, 0, len(byCategory))
+	seen := make(map[string]bool)
+	for _, c := range known {
+		if len(byCategory[c]) > 0 {
+			order = append(order, c)
+			seen[c] = true
+		}
+	}
+	var extra []string
+	for c := range byCategory {
+		if !seen[c] {
+			extra = append(extra, c)
+		}
+	}
+	sort.Strings(extra)
+	order = append(order, extra...)
+
+	var sb strings.Builder
+	sb.WriteString("# Memory Snapshot\n\n")
+	if len(memories) == 0 {
+		sb.WriteString("No memories stored.\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("%d memories in %d categories.\n", len(memories), len(order)))
+	for _, c := range order {
+		heading := c
+		if heading == "" {
+			heading = "(uncategorized)"
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", heading))
+		for _, m := range byCategory[c] {
+			date := "unknown date"
+			if !m.CreatedAt.IsZero() {
+				date = m.CreatedAt.Format("2006-01-02")
+			}
+			// Indent continuation lines so multi-line memories stay one item.
+			content := strings.ReplaceAll(strings.TrimSpace(m.Content), "\n", "\n  ")
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", date, content))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSnapshot_GroupsByCategory(t *testing.T) {
+	s := newTestStore(t)
+	s.Store("User prefers dark mode", CategoryPreference, "chat", nil)
+	s.Store("User works at Sipeed", CategoryFact, "chat", nil)
+	s.Store("Shipped v1.2", CategoryEvent, "chat", nil)
+	s.Store("Likes tea\nbut not green tea", CategoryPreference, "chat", nil)
+
+	path := filepath.Join(t.TempDir(), "exports", "snapshot.md")
+	if err := s.ExportSnapshot(path); err != nil {
+		t.Fatalf("ExportSnapshot: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	doc := string(data)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	sections := strings.Split(doc, "\n## ")
+	if len(sections) != 4 {
+		t.Fatalf("expected a heading per category, got:\n%s", doc)
+	}
+	want := []struct {
+		heading string
+		items   []string
+	}{
+		{"preference", []string{"- " + today + ": User prefers dark mode", "- " + today + ": Likes tea\n  but not green tea"}},
+		{"fact", []string{"- " + today + ": User works at Sipeed"}},
+		{"event", []string{"- " + today + ": Shipped v1.2"}},
+	}
+	for i, w := range want {
+		section := sections[i+1]
+		if !strings.HasPrefix(section, w.heading+"\n") {
+			t.Fatalf("section %d = %q, want heading %q", i, section, w.heading)
+		}
+		for _, item := range w.items {
+			if !strings.Contains(section, item) {
+				t.Fatalf("section %q missing %q", w.heading, item)
+			}
+		}
+	}
+	if !strings.HasPrefix(doc, "# Memory Snapshot\n\n4 memories in 3 categories.\n") {
+		t.Fatalf("unexpected header:\n%s", doc)
+	}
+}
+
+func TestExportSnapshot_IsDeterministic(t *testing.T) {
+	s := newTestStore(t)
+	s.Store("first", CategoryNote, "chat", nil)
+	s.Store("second", CategoryNote, "chat", nil)
+	s.Store("third", CategoryGeneral, "chat", nil)
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")
+	if err := s.ExportSnapshot(a); err != nil {
+		t.Fatalf("ExportSnapshot: %v", err)
+	}
+	if err := s.ExportSnapshot(b); err != nil {
+		t.Fatalf("ExportSnapshot: %v", err)
+	}
+	first, _ := os.ReadFile(a)
+	second, _ := os.ReadFile(b)
+	if string(first) != string(second) {
+		t.Fatalf("snapshots differ:\n%s\n---\n%s", first, second)
+	}
+	if strings.Index(string(first), "first") > strings.Index(string(first), "second") {
+		t.Fatalf("expected oldest memory first:\n%s", first)
+	}
+}
+
+func TestRenderSnapshot_LegacyCategoriesSortedLast(t *testing.T) {
+	doc := renderSnapshot([]Memory{
+		{Content: "z", Category: "zeta"},
+		{Content: "a", Category: "alpha"},
+		{Content: "f", Category: CategoryFact},
+	}, Categories)
+
+	fact := strings.Index(doc, "## fact")
+	alpha := strings.Index(doc, "## alpha")
+	zeta := strings.Index(doc, "## zeta")
+	if fact < 0 || !(fact < alpha && alpha < zeta) {
+		t.Fatalf("unexpected category order:\n%s", doc)
+	}
+	if !strings.Contains(doc, "- unknown date: z") {
+		t.Fatalf("expected missing dates to be labelled:\n%s", doc)
+	}
+}
+
+func TestRenderSnapshot_Empty(t *testing.T) {
+	if got := renderSnapshot(nil, Categories); got != "# Memory Snapshot\n\nNo memories stored.\n" {
+		t.Fatalf("renderSnapshot(nil) = %q", got)
+	}
+}
@@ -4,13 +4,23 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // Memory represents a single stored memory entry.
@@ -24,6 +34,71 @@ type Memory struct {
 	UpdatedAt time.Time
 }
 
+// Memory categories accepted at the write boundary. The category column stays
+// free-form TEXT so older rows keep loading; new writes are normalized.
+const (
+	CategoryPreference = "preference"
+	CategoryFact       = "fact"
+	CategoryEvent      = "event"
+	CategoryNote       = "note"
+	CategoryGeneral    = "general"
+)
+
+// Categories lists the valid memory categories.
+var Categories = []string{CategoryPreference, CategoryFact, CategoryEvent, CategoryNote, CategoryGeneral}
+
+// NormalizeCategory trims and lowercases category. Empty maps to "general";
+// unknown categories also map to "general" and report ok=false.
+func NormalizeCategory(category string) (normalized string, ok bool) {
+	c := strings.ToLower(strings.TrimSpace(category))
+	if c == "" {
+		return CategoryGeneral, true
+	}
+	for _, valid := range Categories {
+		if c == valid {
+			return c, true
+		}
+	}
+	return CategoryGeneral, false
+}
+
+// Categories returns the categories this store accepts: the built-in ones
+// plus any extra category given a markdown target. A nil store reports the
+// built-in list.
+func (s *MemoryStore) Categories() []string {
+	if s == nil || len(s.categories) == 0 {
+		return Categories
+	}
+	return s.categories
+}
+
+// NormalizeCategory is the package-level NormalizeCategory, also accepting
+// the store's extra categories.
+func (s *MemoryStore) NormalizeCategory(category string) (normalized string, ok bool) {
+	c := strings.ToLower(strings.TrimSpace(category))
+	if c == "" {
+		return CategoryGeneral, true
+	}
+	for _, valid := range s.Categories() {
+		if c == valid {
+			return c, true
+		}
+	}
+	return CategoryGeneral, false
+}
+
+// normalizeCategoryForWrite normalizes category and warns when an unknown
+// value is folded into "general".
+func (s *MemoryStore) normalizeCategoryForWrite(category string) string {
+	normalized, ok := s.NormalizeCategory(category)
+	if !ok {
+		logger.WarnCF("memory", "Unknown memory category, storing as general", map[string]interface{}{
+			"category": category,
+		})
+	}
+	return normalized
+}
+
 // MemoryStats holds aggregate counts for the memory store.
 type MemoryStats struct {
 	Total      int
@@ -35,9 +110,129 @@ type MemoryStats struct {
 type MemoryStore struct {
 	db        *sql.DB
 	workspace string
+	tokenizer string
+
+	// Markdown write-through routing: category -> target template (see
+	// MarkdownTargets). An empty template means the category is DB-only.
+	targets          map[string]string
+	categories       []string
+	markdownDisabled bool
+	// markdownMu serializes the read-modify-write of mirror files, which
+	// concurrent Store, Update and Delete calls would otherwise interleave.
+	markdownMu sync.Mutex
+
+	reindexWorkers   int
+	reindexBatchSize int
+
+	// Degraded mode: once a write fails because the database is read-only
+	// or the disk is full, writes fail fast with ErrMemoryUnavailable and
+	// one write per probe interval is let through to check for recovery.
+	// Reads are unaffected.
+	writeMu       sync.Mutex
+	degraded      bool
+	lastProbe     time.Time
+	probeInterval time.Duration
+	now           func() time.Time
+}
+
+// ErrMemoryUnavailable is returned by writes while the database cannot be
+// written (read-only file or full disk). Searches keep working.
+var ErrMemoryUnavailable = errors.New("memory temporarily unavailable")
+
+// defaultWriteProbeInterval is how often a degraded store retries a write.
+const defaultWriteProbeInterval = time.Minute
+
+// FTS5 tokenizers selectable for the search index.
+const (
+	TokenizerUnicode61 = "unicode61" // default: case-folded words, no stemming
+	TokenizerPorter    = "porter"    // English stemming on top of unicode61
+)
+
+// SearchMode controls how a search query is turned into an FTS5 query.
+type SearchMode string
+
+const (
+	SearchPrefix SearchMode = "prefix" // every word matches as a prefix (default)
+	SearchExact  SearchMode = "exact"  // every word must match a whole token
+	SearchPhrase SearchMode = "phrase" // the words must appear together in order
+)
+
+// ParseSearchMode maps a mode name to a SearchMode. Empty means SearchPrefix.
+func ParseSearchMode(mode string) (SearchMode, error) {
+	switch SearchMode(strings.ToLower(strings.TrimSpace(mode))) {
+	case "", SearchPrefix:
+		return SearchPrefix, nil
+	case SearchExact:
+		return SearchExact, nil
+	case SearchPhrase:
+		return SearchPhrase, nil
+	}
+	return "", fmt.Errorf("unknown search mode %q: want prefix, exact or phrase", mode)
 }
 
-const schemaVersion = 1
+// StoreOptions configures NewMemoryStoreWithOptions.
+type StoreOptions struct {
+	// Tokenizer is the FTS5 tokenizer for the search index: "unicode61"
+	// (default) or "porter". Changing it rebuilds the index on open.
+	Tokenizer string
+
+	// MarkdownTargets overrides where categories are mirrored, on top of
+	// DefaultMarkdownTargets (see config.ValidateMemoryMarkdownTargets for
+	// the template syntax). An empty template keeps that category out of markdown, and
+	// a category not built in becomes a valid category for this store.
+	MarkdownTargets map[string]string
+
+	// DisableMarkdown turns off markdown write-through entirely: memories
+	// live only in the database.
+	DisableMarkdown bool
+
+	// ReindexWorkers is how many markdown files Reindex reads at once
+	// (0 = number of CPUs, at most 8).
+	ReindexWorkers int
+
+	// ReindexBatchSize is how many lines Reindex checks and inserts per
+	// statement (0 = 500, at most 5000).
+	ReindexBatchSize int
+}
+
+const (
+	defaultReindexBatchSize = 500
+	// maxReindexBatchSize keeps multi-row statements well under SQLite's
+	// limit on bound variables.
+	maxReindexBatchSize = 5000
+)
+
+// DailyMarkdownTarget is the default target for facts, events and general
+// memories: memory/YYYYMM/YYYYMMDD.md.
+const DailyMarkdownTarget = "{yyyy}{mm}/{yyyy}{mm}{dd}.md"
+
+// DefaultMarkdownTargets returns the built-in write-through routing:
+// preferences and notes go to MEMORY.md, everything else to the daily log.
+func DefaultMarkdownTargets() map[string]string {
+	return map[string]string{
+		CategoryPreference: "MEMORY.md",
+		CategoryNote:       "MEMORY.md",
+		CategoryFact:       DailyMarkdownTarget,
+		CategoryEvent:      DailyMarkdownTarget,
+		CategoryGeneral:    DailyMarkdownTarget,
+	}
+}
+
+// expandMarkdownTarget fills the date placeholders of template.
+func expandMarkdownTarget(template string, now time.Time) string {
+	return strings.NewReplacer(
+		"{yyyy}", now.Format("2006"),
+		"{mm}", now.Format("01"),
+		"{dd}", now.Format("02"),
+	).Replace(template)
+}
+
+// isDatedMarkdownTarget reports whether template names a new file per date.
+func isDatedMarkdownTarget(template string) bool {
+	return strings.Contains(template, "{")
+}
+
+const schemaVersion = 2
 
 // MarkdownFileMaxChars bounds each markdown memory file so prompt context does
 // not grow unbounded. Older entries remain available via memory_search (SQLite).
@@ -48,7 +243,43 @@ const markdownTrimNotice = "[... older memory entries omitted from this file; us
 // NewMemoryStore opens or creates a SQLite memory database at dbPath.
 // workspace is the picoclaw workspace root (parent of memory/).
 func NewMemoryStore(dbPath string, workspace string) (*MemoryStore, error) {
-	dir := filepath.Dir(dbPath)
+	return NewMemoryStoreWithOptions(dbPath, workspace, StoreOptions{})
+}
+
+// sqliteFilePath returns the file a SQLite DSN opens: dbPath itself, or the
+// path of a "file:" URI without its scheme and "?query".
+func sqliteFilePath(dbPath string) string {
+	if !strings.HasPrefix(dbPath, "file:") {
+		return dbPath
+	}
+	file, _, _ := strings.Cut(strings.TrimPrefix(dbPath, "file:"), "?")
+	return file
+}
+
+// NewMemoryStoreWithOptions is NewMemoryStore with a configurable search
+// index.
+func NewMemoryStoreWithOptions(dbPath string, workspace string, opts StoreOptions) (*MemoryStore, error) {
+	tokenizer, err := normalizeTokenizer(opts.Tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidateMemoryMarkdownTargets(opts.MarkdownTargets); err != nil {
+		return nil, fmt.Errorf("invalid markdown targets: %w", err)
+	}
+	targets := DefaultMarkdownTargets()
+	categories := append([]string(nil), Categories...)
+	for category, template := range opts.MarkdownTargets {
+		if _, builtin := targets[category]; !builtin {
+			categories = append(categories, category)
+		}
+		if template != "" {
+			template = path.Clean(filepath.ToSlash(template))
+		}
+		targets[category] = template
+	}
+	sort.Strings(categories[len(Categories):])
+
+	dir := filepath.Dir(sqliteFilePath(dbPath))
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create memory directory: %w", err)
 	}
@@ -64,10 +295,31 @@ func NewMemoryStore(dbPath string, workspace string) (*MemoryStore, error) {
 		return nil, fmt.Errorf("failed to set WAL mode: %w", err)
 	}
 
-	s := &MemoryStore{db: db, workspace: workspace}
+	s := &MemoryStore{
+		db:               db,
+		workspace:        workspace,
+		tokenizer:        tokenizer,
+		targets:          targets,
+		categories:       categories,
+		probeInterval:    defaultWriteProbeInterval,
+		markdownDisabled: opts.DisableMarkdown,
+		reindexWorkers:   opts.ReindexWorkers,
+		reindexBatchSize: min(opts.ReindexBatchSize, maxReindexBatchSize),
+		now:              time.Now,
+	}
+	if s.reindexWorkers <= 0 {
+		s.reindexWorkers = min(runtime.NumCPU(), 8)
+	}
+	if s.reindexBatchSize <= 0 {
+		s.reindexBatchSize = defaultReindexBatchSize
+	}
 	if err := s.migrate(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+		// A read-only database with a current schema is still searchable.
+		if version, verr := s.SchemaVersion(); !isWriteUnavailable(err) || verr != nil || version < schemaVersion {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate schema: %w", err)
+		}
+		s.recordWrite(err)
 	}
 
 	return s, nil
@@ -92,34 +344,71 @@ func (s *MemoryStore) migrate() error {
 			metadata TEXT,
 			content_hash TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			source_file TEXT
 		);
 
 		CREATE INDEX IF NOT EXISTS idx_memories_category ON memories(category);
 		CREATE INDEX IF NOT EXISTS idx_memories_content_hash ON memories(content_hash);
+
+		-- Markdown files seen by Reindex, so unchanged files can be skipped.
+		CREATE TABLE IF NOT EXISTS memory_sources (
+			path TEXT PRIMARY KEY,
+			mtime INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			content_hash TEXT NOT NULL
+		);
 	`)
 	if err != nil {
 		return err
 	}
 
+	// v2: imported rows remember their markdown file (relative to memory/).
+	if err := s.addColumnIfMissing("memories", "source_file", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_source_file ON memories(source_file)`); err != nil {
+		return err
+	}
+
 	// Create FTS5 table if it doesn't exist.
 	// FTS5 virtual tables don't support IF NOT EXISTS, so check first.
-	var ftsExists int
+	// An index built with a different tokenizer is dropped and rebuilt.
+	var ftsSQL string
 	err = s.db.QueryRow(`
-		SELECT COUNT(*) FROM sqlite_master
+		SELECT sql FROM sqlite_master
 		WHERE type='table' AND name='memories_fts'
-	`).Scan(&ftsExists)
-	if err != nil {
+	`).Scan(&ftsSQL)
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
+	ftsExists := err == nil
+	rebuild := false
+	if ftsExists {
+		if current := ftsTableTokenizer(ftsSQL); current != s.tokenizer {
+			logger.InfoCF("memory", "FTS tokenizer changed, rebuilding search index",
+				map[string]interface{}{"from": current, "to": s.tokenizer})
+			if _, err := s.db.Exec(`
+				DROP TRIGGER IF EXISTS memories_ai;
+				DROP TRIGGER IF EXISTS memories_ad;
+				DROP TRIGGER IF EXISTS memories_au;
+				DROP TABLE memories_fts;
+			`); err != nil {
+				return err
+			}
+			ftsExists = false
+			rebuild = true
+		}
+	}
 
-	if ftsExists == 0 {
-		_, err = s.db.Exec(`
+	if !ftsExists {
+		_, err = s.db.Exec(fmt.Sprintf(`
 			CREATE VIRTUAL TABLE memories_fts USING fts5(
 				content,
 				category,
 				content='memories',
-				content_rowid='id'
+				content_rowid='id',
+				tokenize='%s'
 			);
 
 			-- Triggers to keep FTS in sync
@@ -139,10 +428,15 @@ func (s *MemoryStore) migrate() error {
 				INSERT INTO memories_fts(rowid, content, category)
 				VALUES (new.id, new.content, new.category);
 			END;
-		`)
+		`, ftsTokenizeSpec(s.tokenizer)))
 		if err != nil {
 			return err
 		}
+		if rebuild {
+			if _, err := s.db.Exec(`INSERT INTO memories_fts(memories_fts) VALUES ('rebuild')`); err != nil {
+				return err
+			}
+		}
 	}
 
 	// Set schema version if not present
@@ -153,14 +447,97 @@ func (s *MemoryStore) migrate() error {
 	}
 	if count == 0 {
 		_, err = s.db.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion)
-		if err != nil {
-			return err
-		}
+	} else {
+		_, err = s.db.Exec("UPDATE schema_version SET version = ? WHERE version < ?", schemaVersion, schemaVersion)
 	}
+	return err
+}
 
+// Degraded reports whether writes are currently refused because the
+// database could not be written.
+func (s *MemoryStore) Degraded() bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.degraded
+}
+
+// beginWrite returns ErrMemoryUnavailable while degraded, except for one
+// write per probe interval, which is let through to test for recovery.
+func (s *MemoryStore) beginWrite() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if !s.degraded {
+		return nil
+	}
+	now := s.now()
+	if now.Sub(s.lastProbe) < s.probeInterval {
+		return ErrMemoryUnavailable
+	}
+	s.lastProbe = now
 	return nil
 }
 
+// recordWrite updates degraded mode from a write's outcome. Errors meaning
+// the database cannot be written are returned wrapped in
+// ErrMemoryUnavailable; other errors are returned unchanged.
+func (s *MemoryStore) recordWrite(err error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err == nil || !isWriteUnavailable(err) {
+		if err == nil && s.degraded {
+			s.degraded = false
+			logger.InfoCF("memory", "Memory database is writable again", nil)
+		}
+		return err
+	}
+	if !s.degraded {
+		s.degraded = true
+		logger.WarnCF("memory", "Memory database is not writable, continuing read-only",
+			map[string]interface{}{
+				"error":       err.Error(),
+				"retry_every": s.probeInterval.String(),
+			})
+	}
+	s.lastProbe = s.now()
+	return fmt.Errorf("%w: %v", ErrMemoryUnavailable, err)
+}
+
+// isWriteUnavailable reports whether err means the database cannot be
+// written at all, as opposed to a problem with one statement.
+func isWriteUnavailable(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_READONLY, sqlite3.SQLITE_FULL, sqlite3.SQLITE_IOERR, sqlite3.SQLITE_CANTOPEN:
+		return true
+	}
+	return false
+}
+
+func (s *MemoryStore) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // SchemaVersion returns the current schema version.
 func (s *MemoryStore) SchemaVersion() (int, error) {
 	var version int
@@ -169,10 +546,15 @@ func (s *MemoryStore) SchemaVersion() (int, error) {
 }
 
 // Store saves a new memory to the database and writes through to markdown.
-// Category determines which markdown file is written:
+// Category determines which markdown file is written; by default:
 //   - "preference", "note" → MEMORY.md
-//   - "fact", "event" → today's daily log
+//   - "fact", "event", "general" → today's daily log
+//
+// StoreOptions.MarkdownTargets changes the routing. Unknown categories are
+// stored as "general" (see NormalizeCategory).
 func (s *MemoryStore) Store(content, category, source string, metadata map[string]string) (int64, error) {
+	category = s.normalizeCategoryForWrite(category)
+
 	var metaJSON *string
 	if metadata != nil {
 		data, err := json.Marshal(metadata)
@@ -185,12 +567,15 @@ func (s *MemoryStore) Store(content, category, source string, metadata map[strin
 
 	hash := contentHash(content)
 
+	if err := s.beginWrite(); err != nil {
+		return 0, err
+	}
 	result, err := s.db.Exec(
 		`INSERT INTO memories (content, category, source, metadata, content_hash)
 		 VALUES (?, ?, ?, ?, ?)`,
 		content, category, source, metaJSON, hash,
 	)
-	if err != nil {
+	if err := s.recordWrite(err); err != nil {
 		return 0, fmt.Errorf("failed to insert memory: %w", err)
 	}
 
@@ -206,8 +591,14 @@ func (s *MemoryStore) Store(content, category, source string, metadata map[strin
 }
 
 // Search performs an FTS5 full-text search, ranked by BM25 relevance.
-// If category is non-empty, results are filtered by category.
+// If category is non-empty, results are filtered by category. Every query
+// word matches as a prefix; see SearchWithMode for exact and phrase search.
 func (s *MemoryStore) Search(query string, limit int, category string) ([]Memory, error) {
+	return s.SearchWithMode(query, limit, category, SearchPrefix)
+}
+
+// SearchWithMode is Search with an explicit query mode.
+func (s *MemoryStore) SearchWithMode(query string, limit int, category string, mode SearchMode) ([]Memory, error) {
 	if strings.TrimSpace(query) == "" {
 		return nil, nil
 	}
@@ -216,8 +607,7 @@ func (s *MemoryStore) Search(query string, limit int, category string) ([]Memory
 		limit = 5
 	}
 
-	// Tokenize query for FTS5 prefix matching
-	ftsQuery := buildFTSQuery(query)
+	ftsQuery := buildFTSQuery(query, mode)
 
 	var rows *sql.Rows
 	var err error
@@ -264,10 +654,60 @@ func (s *MemoryStore) Get(id int64) (*Memory, error) {
 	return mem, nil
 }
 
+// Update rewrites an existing memory's content (and category, when non-empty).
+// The FTS index follows via the update trigger; the markdown mirror is
+// rewritten in place so the stale line does not linger in prompt context.
+func (s *MemoryStore) Update(id int64, content, category string) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("content is required")
+	}
+
+	old, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(category) == "" {
+		category = old.Category
+	} else {
+		category = s.normalizeCategoryForWrite(category)
+	}
+
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`UPDATE memories
+		 SET content = ?, category = ?, content_hash = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		content, category, contentHash(content), id,
+	)
+	if err := s.recordWrite(err); err != nil {
+		return fmt.Errorf("failed to update memory: %w", err)
+	}
+
+	if s.markdownDisabled {
+		return nil
+	}
+	// Rewrite the markdown mirror (best-effort). If the entry moves to a
+	// different target file, or was already trimmed from its file, drop the
+	// old line and append the new one to the right file.
+	if s.markdownTarget(old.Category) == s.markdownTarget(category) &&
+		s.rewriteMarkdownEntry(old, content) {
+		return nil
+	}
+	s.rewriteMarkdownEntry(old, "")
+	s.writeToMarkdown(content, category)
+
+	return nil
+}
+
 // Delete removes a memory by ID.
 func (s *MemoryStore) Delete(id int64) error {
+	if err := s.beginWrite(); err != nil {
+		return err
+	}
 	_, err := s.db.Exec("DELETE FROM memories WHERE id = ?", id)
-	return err
+	return s.recordWrite(err)
 }
 
 // List returns memories, optionally filtered by category.
@@ -326,98 +766,453 @@ func (s *MemoryStore) Stats() (*MemoryStats, error) {
 	return &MemoryStats{Total: total, ByCategory: byCategory}, nil
 }
 
-// Reindex rebuilds the database from markdown files (MEMORY.md + daily logs).
-// Existing DB entries from a prior import are skipped by content hash.
+// Reindex imports markdown files (MEMORY.md + daily logs) into the database.
+// Files whose size and mtime are unchanged since the last run are skipped.
+// In a re-scanned file, new lines are added (skipping content already stored,
+// by hash) and imported rows whose line was removed are deleted.
 func (s *MemoryStore) Reindex() error {
+	_, err := s.reindex()
+	return err
+}
+
+// reindexStats counts what a Reindex run did.
+type reindexStats struct {
+	scanned, skipped, added, removed int
+}
+
+func (s *MemoryStore) reindex() (reindexStats, error) {
+	var stats reindexStats
 	memoryDir := filepath.Join(s.workspace, "memory")
 
-	// Index MEMORY.md
-	memoryFile := filepath.Join(memoryDir, "MEMORY.md")
-	if data, err := os.ReadFile(memoryFile); err == nil {
-		lines := extractMemoryLines(string(data))
-		for _, line := range lines {
-			s.storeIfNew(line, "note", "import")
+	// Relative path -> category for every markdown source present.
+	sources := map[string]string{}
+	if _, err := os.Stat(filepath.Join(memoryDir, "MEMORY.md")); err == nil {
+		sources["MEMORY.md"] = "note"
+	}
+	daily, _ := filepath.Glob(filepath.Join(memoryDir, "[0-9][0-9][0-9][0-9][0-9][0-9]", "*.md"))
+	for _, path := range daily {
+		if rel, err := filepath.Rel(memoryDir, path); err == nil {
+			sources[filepath.ToSlash(rel)] = "event"
+		}
+	}
+	// Fixed files configured as targets import with their category.
+	for _, category := range s.Categories() {
+		template := s.targets[category]
+		if template == "" || template == "MEMORY.md" || isDatedMarkdownTarget(template) {
+			continue
+		}
+		if _, ok := sources[template]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(memoryDir, filepath.FromSlash(template))); err == nil {
+			sources[template] = category
 		}
 	}
 
-	// Index daily logs
-	entries, err := os.ReadDir(memoryDir)
+	known, err := s.indexedSources()
 	if err != nil {
-		return nil
+		return stats, err
+	}
+
+	paths := make([]string, 0, len(sources))
+	for rel := range sources {
+		paths = append(paths, rel)
 	}
+	sort.Strings(paths)
+	files := s.readReindexFiles(memoryDir, paths, sources, known)
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	// All changes go in one transaction: one commit instead of one per row,
+	// and an interrupted run leaves the index as it was.
+	if err := s.beginWrite(); err != nil {
+		return stats, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return stats, s.recordWrite(err)
+	}
+	defer tx.Rollback()
+
+	for _, f := range files {
+		if f.err != nil {
+			logger.WarnCF("memory", "Failed to reindex memory file", map[string]interface{}{
+				"file":  f.rel,
+				"error": f.err.Error(),
+			})
 			continue
 		}
-		// Look for YYYYMM directories
-		if len(entry.Name()) != 6 {
+		if f.skipped {
+			stats.skipped++
 			continue
 		}
-
-		monthDir := filepath.Join(memoryDir, entry.Name())
-		files, err := os.ReadDir(monthDir)
+		added, removed, err := s.reindexFile(tx, f)
 		if err != nil {
+			return stats, s.recordWrite(fmt.Errorf("reindex %s: %w", f.rel, err))
+		}
+		stats.scanned++
+		stats.added += added
+		stats.removed += removed
+	}
+
+	// Files that disappeared: their imported lines are gone too.
+	for rel := range known {
+		if _, ok := sources[rel]; ok {
 			continue
 		}
+		removed, err := removeImportedRows(tx, rel, nil)
+		if err != nil {
+			return stats, s.recordWrite(err)
+		}
+		stats.removed += removed
+		if _, err := tx.Exec("DELETE FROM memory_sources WHERE path = ?", rel); err != nil {
+			return stats, s.recordWrite(err)
+		}
+	}
 
-		for _, f := range files {
-			if f.IsDir() || !strings.HasSuffix(f.Name(), ".md") {
-				continue
-			}
-			data, err := os.ReadFile(filepath.Join(monthDir, f.Name()))
-			if err != nil {
-				continue
+	if err := s.recordWrite(reindexCommit(tx)); err != nil {
+		return stats, err
+	}
+
+	logger.DebugCF("memory", "Memory reindex complete", map[string]interface{}{
+		"scanned": stats.scanned,
+		"skipped": stats.skipped,
+		"added":   stats.added,
+		"removed": stats.removed,
+	})
+	return stats, nil
+}
+
+// reindexCommit commits a reindex transaction. Tests swap it to count
+// commits.
+var reindexCommit = func(tx *sql.Tx) error { return tx.Commit() }
+
+// indexedSource is a memory_sources row.
+type indexedSource struct {
+	mtime int64
+	size  int64
+	hash  string
+}
+
+func (s *MemoryStore) indexedSources() (map[string]*indexedSource, error) {
+	rows, err := s.db.Query("SELECT path, mtime, size, content_hash FROM memory_sources")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := map[string]*indexedSource{}
+	for rows.Next() {
+		var path string
+		src := &indexedSource{}
+		if err := rows.Scan(&path, &src.mtime, &src.size, &src.hash); err != nil {
+			return nil, err
+		}
+		known[path] = src
+	}
+	return known, rows.Err()
+}
+
+// reindexSource is one markdown file as read by Reindex.
+type reindexSource struct {
+	rel, category string
+	prev          *indexedSource
+	mtime, size   int64
+	data          string
+	skipped       bool // unchanged since prev
+	err           error
+}
+
+// readReindexFiles reads the markdown files at paths with up to
+// reindexWorkers files in flight, skipping those unchanged since the last
+// run. Results are in the order of paths.
+func (s *MemoryStore) readReindexFiles(memoryDir string, paths []string, sources map[string]string, known map[string]*indexedSource) []reindexSource {
+	files := make([]reindexSource, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.reindexWorkers, len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				files[i] = readReindexSource(memoryDir, paths[i], sources[paths[i]], known[paths[i]])
 			}
-			lines := extractMemoryLines(string(data))
-			for _, line := range lines {
-				s.storeIfNew(line, "event", "import")
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return files
+}
+
+func readReindexSource(memoryDir, rel, category string, prev *indexedSource) reindexSource {
+	f := reindexSource{rel: rel, category: category, prev: prev}
+	path := filepath.Join(memoryDir, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil {
+		f.err = err
+		return f
+	}
+	f.mtime, f.size = info.ModTime().UnixNano(), info.Size()
+	if prev != nil && prev.mtime == f.mtime && prev.size == f.size {
+		f.skipped = true
+		return f
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		f.err = err
+		return f
+	}
+	f.data = string(data)
+	return f
+}
+
+// reindexFile imports the lines of a changed markdown file and records it in
+// memory_sources.
+func (s *MemoryStore) reindexFile(tx *sql.Tx, f reindexSource) (added, removed int, err error) {
+	fileHash := contentHash(f.data)
+
+	if f.prev == nil || f.prev.hash != fileHash {
+		lines := extractMemoryLines(f.data)
+		current := make(map[string]bool, len(lines))
+		for _, line := range lines {
+			current[contentHash(line)] = true
+		}
+		if added, err = s.storeNewLines(tx, lines, f.category, "import", f.rel); err != nil {
+			return added, 0, err
+		}
+		// A trimmed file lost its oldest lines on purpose (see
+		// enforceMarkdownFileLimit); those rows stay searchable.
+		if !strings.Contains(f.data, markdownTrimNotice) {
+			if removed, err = removeImportedRows(tx, f.rel, current); err != nil {
+				return added, removed, err
 			}
 		}
 	}
 
-	return nil
+	_, err = tx.Exec(
+		`INSERT INTO memory_sources (path, mtime, size, content_hash) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET mtime = excluded.mtime, size = excluded.size, content_hash = excluded.content_hash`,
+		f.rel, f.mtime, f.size, fileHash,
+	)
+	return added, removed, err
+}
+
+// removeImportedRows deletes rows imported from rel whose content hash is not
+// in keep. A nil keep removes all of them.
+func removeImportedRows(tx *sql.Tx, rel string, keep map[string]bool) (int, error) {
+	rows, err := tx.Query(
+		"SELECT id, content_hash FROM memories WHERE source = 'import' AND source_file = ?", rel)
+	if err != nil {
+		return 0, err
+	}
+	var stale []interface{}
+	for rows.Next() {
+		var id int64
+		var hash sql.NullString
+		if err := rows.Scan(&id, &hash); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keep[hash.String] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for start := 0; start < len(stale); start += maxReindexBatchSize {
+		batch := stale[start:min(start+maxReindexBatchSize, len(stale))]
+		if _, err := tx.Exec("DELETE FROM memories WHERE id IN ("+sqlPlaceholders(len(batch))+")", batch...); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
 }
 
 // storeIfNew stores a memory only if its content hash doesn't already exist.
-func (s *MemoryStore) storeIfNew(content, category, source string) {
-	hash := contentHash(content)
-	var exists int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM memories WHERE content_hash = ?", hash).Scan(&exists)
-	if err != nil || exists > 0 {
-		return
+// An existing imported row without a source file is attributed to sourceFile,
+// so rows imported before files were tracked can still be cleaned up.
+func (s *MemoryStore) storeIfNew(content, category, source, sourceFile string) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false
 	}
+	defer tx.Rollback()
 
-	s.db.Exec(
-		`INSERT INTO memories (content, category, source, content_hash) VALUES (?, ?, ?, ?)`,
-		content, category, source, hash,
-	)
+	added, err := s.storeNewLines(tx, []string{content}, category, source, sourceFile)
+	if err != nil || tx.Commit() != nil {
+		return false
+	}
+	return added == 1
 }
 
-// writeToMarkdown appends a memory to the appropriate markdown file.
-func (s *MemoryStore) writeToMarkdown(content, category string) {
-	memoryDir := filepath.Join(s.workspace, "memory")
-	entry := fmt.Sprintf("- %s\n", content)
+// storeNewLines is storeIfNew for many lines: per batch of reindexBatchSize
+// lines, one query finds the hashes already stored and one statement inserts
+// the rest. It returns the number of rows added.
+func (s *MemoryStore) storeNewLines(tx *sql.Tx, lines []string, category, source, sourceFile string) (int, error) {
+	added := 0
+	for start := 0; start < len(lines); start += s.reindexBatchSize {
+		batch := lines[start:min(start+s.reindexBatchSize, len(lines))]
+
+		hashes := make([]interface{}, 0, len(batch))
+		content := make(map[string]string, len(batch))
+		for _, line := range batch {
+			hash := contentHash(line)
+			if _, dup := content[hash]; !dup {
+				content[hash] = line
+				hashes = append(hashes, hash)
+			}
+		}
+
+		existing, err := existingHashes(tx, hashes)
+		if err != nil {
+			return added, err
+		}
+		if len(existing) > 0 && sourceFile != "" {
+			args := append([]interface{}{sourceFile}, existing...)
+			if _, err := tx.Exec(
+				`UPDATE memories SET source_file = ? WHERE source = 'import' AND source_file IS NULL
+				 AND content_hash IN (`+sqlPlaceholders(len(existing))+`)`, args...); err != nil {
+				return added, err
+			}
+		}
+
+		stored := make(map[string]bool, len(existing))
+		for _, hash := range existing {
+			stored[hash.(string)] = true
+		}
+		var values []string
+		var args []interface{}
+		for _, hash := range hashes {
+			if stored[hash.(string)] {
+				continue
+			}
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, content[hash.(string)], category, source, hash, sourceFile)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT INTO memories (content, category, source, content_hash, source_file) VALUES `+
+			strings.Join(values, ", "), args...); err != nil {
+			return added, err
+		}
+		added += len(values)
+	}
+	return added, nil
+}
+
+// existingHashes returns the subset of hashes already stored in memories.
+func existingHashes(tx *sql.Tx, hashes []interface{}) ([]interface{}, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.Query("SELECT DISTINCT content_hash FROM memories WHERE content_hash IN ("+sqlPlaceholders(len(hashes))+")", hashes...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var existing []interface{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		existing = append(existing, hash)
+	}
+	return existing, rows.Err()
+}
 
-	switch category {
-	case "preference", "note":
-		// Append to MEMORY.md
-		memoryFile := filepath.Join(memoryDir, "MEMORY.md")
-		s.appendToFile(memoryFile, entry, "# Memory\n\n")
+// sqlPlaceholders returns n comma-separated "?" placeholders.
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// markdownTarget returns the target template a category is written to, or
+// "" when it is not mirrored. Categories without a target (rows from before
+// a category was configured) follow "general".
+func (s *MemoryStore) markdownTarget(category string) string {
+	if s.markdownDisabled {
+		return ""
+	}
+	if template, ok := s.targets[category]; ok {
+		return template
+	}
+	return s.targets[CategoryGeneral]
+}
+
+// writeToMarkdown appends a memory to the category's markdown file.
+func (s *MemoryStore) writeToMarkdown(content, category string) {
+	template := s.markdownTarget(category)
+	if template == "" {
+		return
+	}
+	now := time.Now()
+	rel := expandMarkdownTarget(template, now)
+	path := filepath.Join(s.workspace, "memory", filepath.FromSlash(rel))
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	var header string
+	switch {
+	case isDatedMarkdownTarget(template):
+		header = fmt.Sprintf("# %s\n\n", now.Format("2006-01-02"))
+	case rel == "MEMORY.md":
+		header = "# Memory\n\n"
 	default:
-		// fact, event, general → daily log
-		today := time.Now().Format("20060102")
-		header := fmt.Sprintf("# %s\n\n", time.Now().Format("2006-01-02"))
-		monthDir := today[:6]
-		dailyDir := filepath.Join(memoryDir, monthDir)
-		os.MkdirAll(dailyDir, 0755)
+		header = fmt.Sprintf("# %s\n\n", strings.TrimSuffix(filepath.Base(rel), ".md"))
+	}
+	s.appendToFile(path, fmt.Sprintf("- %s\n", content), header)
+}
+
+// rewriteMarkdownEntry replaces old's own line, exactly "- old.Content", in
+// the file its category is written to (for dated targets, the file of the day
+// it was created) with newContent. An empty newContent removes the line.
+// Returns false if no line matched.
+func (s *MemoryStore) rewriteMarkdownEntry(old *Memory, newContent string) bool {
+	template := s.markdownTarget(old.Category)
+	if template == "" || strings.TrimSpace(old.Content) == "" {
+		return false
+	}
+	created := old.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+	rel := expandMarkdownTarget(template, created.Local())
+	path := filepath.Join(s.workspace, "memory", filepath.FromSlash(rel))
+
+	s.markdownMu.Lock()
+	defer s.markdownMu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
 
-		dailyFile := filepath.Join(dailyDir, today+".md")
-		s.appendToFile(dailyFile, entry, header)
+	want := "- " + old.Content
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.TrimSuffix(line, "\r") != want {
+			continue
+		}
+		if newContent == "" {
+			lines = append(lines[:i], lines[i+1:]...)
+		} else {
+			lines[i] = "- " + newContent
+		}
+		_ = utils.AtomicWriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+		return true
 	}
+	return false
 }
 
 func (s *MemoryStore) appendToFile(path, content, defaultHeader string) {
+	s.markdownMu.Lock()
+	defer s.markdownMu.Unlock()
+
 	existing := ""
 	if data, err := os.ReadFile(path); err == nil {
 		existing = string(data)
@@ -435,7 +1230,7 @@ func (s *MemoryStore) appendToFile(path, content, defaultHeader string) {
 
 	combined = enforceMarkdownFileLimit(combined)
 
-	_ = os.WriteFile(path, []byte(combined), 0644)
+	_ = utils.AtomicWriteFile(path, []byte(combined), 0644)
 }
 
 func enforceMarkdownFileLimit(content string) string {
@@ -508,22 +1303,61 @@ func extractMemoryLines(content string) []string {
 }
 
 // buildFTSQuery converts a natural language query into an FTS5 query.
-// Each word becomes a prefix token for partial matching.
-func buildFTSQuery(query string) string {
+// In prefix mode each word becomes a prefix token for partial matching,
+// exact mode requires whole tokens, and phrase mode quotes the whole query.
+func buildFTSQuery(query string, mode SearchMode) string {
 	words := strings.Fields(query)
 	if len(words) == 0 {
 		return query
 	}
-	// Use prefix matching: each word gets a * suffix
+	if mode == SearchPhrase {
+		return quoteFTS(strings.Join(words, " "))
+	}
 	var parts []string
 	for _, w := range words {
-		// Escape FTS5 special characters
-		w = strings.ReplaceAll(w, `"`, `""`)
-		parts = append(parts, `"`+w+`"*`)
+		if mode == SearchExact {
+			parts = append(parts, quoteFTS(w))
+		} else {
+			parts = append(parts, quoteFTS(w)+"*")
+		}
 	}
 	return strings.Join(parts, " ")
 }
 
+// quoteFTS wraps s in double quotes, escaping embedded quotes, so FTS5
+// treats it as a string rather than query syntax.
+func quoteFTS(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func normalizeTokenizer(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", TokenizerUnicode61:
+		return TokenizerUnicode61, nil
+	case TokenizerPorter:
+		return TokenizerPorter, nil
+	}
+	return "", fmt.Errorf("unknown FTS tokenizer %q: want unicode61 or porter", name)
+}
+
+// ftsTokenizeSpec returns the FTS5 tokenize option for a tokenizer name.
+// porter wraps unicode61 so non-ASCII text is still folded.
+func ftsTokenizeSpec(tokenizer string) string {
+	if tokenizer == TokenizerPorter {
+		return "porter unicode61"
+	}
+	return TokenizerUnicode61
+}
+
+// ftsTableTokenizer reports the tokenizer an existing memories_fts table was
+// created with. Tables from before the option existed use unicode61.
+func ftsTableTokenizer(createSQL string) string {
+	if strings.Contains(strings.ToLower(createSQL), "porter") {
+		return TokenizerPorter
+	}
+	return TokenizerUnicode61
+}
+
 func contentHash(content string) string {
 	h := sha256.Sum256([]byte(content))
 	return fmt.Sprintf("%x", h[:16]) // 32-char hex, enough for dedup
@@ -1,10 +1,13 @@
 package memory
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -38,8 +41,8 @@ func TestSchemaVersion(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SchemaVersion failed: %v", err)
 	}
-	if version != 1 {
-		t.Errorf("expected schema version 1, got %d", version)
+	if version != schemaVersion {
+		t.Errorf("expected schema version %d, got %d", schemaVersion, version)
 	}
 }
 
@@ -57,6 +60,91 @@ func TestStore(t *testing.T) {
 	}
 }
 
+func TestStore_ValidCategory(t *testing.T) {
+	s := newTestStore(t)
+
+	id, err := s.Store("likes tea", "note", "chat", nil)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	mem, _ := s.Get(id)
+	if mem.Category != "note" {
+		t.Errorf("expected category note, got %q", mem.Category)
+	}
+}
+
+func TestStore_UnknownCategoryNormalizedToGeneral(t *testing.T) {
+	s := newTestStore(t)
+
+	id, err := s.Store("typo bucket", "prefernce", "chat", nil)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	mem, _ := s.Get(id)
+	if mem.Category != "general" {
+		t.Errorf("expected unknown category stored as general, got %q", mem.Category)
+	}
+
+	// general entries are mirrored to the daily log, not MEMORY.md.
+	if data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md")); err == nil && strings.Contains(string(data), "typo bucket") {
+		t.Errorf("general memory should not be written to MEMORY.md")
+	}
+}
+
+func TestStore_CategoryIsCaseInsensitive(t *testing.T) {
+	s := newTestStore(t)
+
+	id, err := s.Store("user likes vim", "  Preference ", "chat", nil)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	mem, _ := s.Get(id)
+	if mem.Category != "preference" {
+		t.Errorf("expected category preference, got %q", mem.Category)
+	}
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if err != nil || !strings.Contains(string(data), "user likes vim") {
+		t.Errorf("expected normalized preference in MEMORY.md, got %q (%v)", string(data), err)
+	}
+
+	results, _ := s.List("preference", 10)
+	if len(results) != 1 {
+		t.Errorf("expected normalized memory to match the preference filter, got %d", len(results))
+	}
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"fact", "fact", true},
+		{"EVENT", "event", true},
+		{"", "general", true},
+		{"misc", "general", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeCategory(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeCategory(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUpdate_NormalizesCategory(t *testing.T) {
+	s := newTestStore(t)
+
+	id, _ := s.Store("deployed v2.0", "event", "chat", nil)
+	if err := s.Update(id, "deployed v2.1", "Fact"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	mem, _ := s.Get(id)
+	if mem.Category != "fact" {
+		t.Errorf("expected category fact, got %q", mem.Category)
+	}
+}
+
 func TestStore_WithMetadata(t *testing.T) {
 	s := newTestStore(t)
 
@@ -115,6 +203,144 @@ func TestStore_WritesToMarkdown_Event(t *testing.T) {
 	}
 }
 
+func newTestStoreWithOptions(t *testing.T, opts StoreOptions) *MemoryStore {
+	t.Helper()
+	workspace := filepath.Join(t.TempDir(), "workspace")
+	s, err := NewMemoryStoreWithOptions(filepath.Join(workspace, "memory", "memory.db"), workspace, opts)
+	if err != nil {
+		t.Fatalf("NewMemoryStoreWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_CustomCategoryRoutedToConfiguredFile(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{
+		MarkdownTargets: map[string]string{"project": "PROJECT.md"},
+	})
+
+	if _, ok := s.NormalizeCategory("Project"); !ok {
+		t.Fatalf("configured category should be valid, got categories %v", s.Categories())
+	}
+	id, err := s.Store("ship the beta in March", "project", "chat", nil)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if mem, _ := s.Get(id); mem == nil || mem.Category != "project" {
+		t.Fatalf("expected category project, got %+v", mem)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "PROJECT.md"))
+	if err != nil {
+		t.Fatalf("failed to read PROJECT.md: %v", err)
+	}
+	if string(data) != "# PROJECT\n\n- ship the beta in March\n" {
+		t.Errorf("unexpected PROJECT.md:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(s.workspace, "memory", "MEMORY.md")); !os.IsNotExist(err) {
+		t.Errorf("MEMORY.md should not be written, stat err = %v", err)
+	}
+
+	// Updates find the line in the configured file.
+	if err := s.Update(id, "ship the beta in April", ""); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(s.workspace, "memory", "PROJECT.md"))
+	if !strings.Contains(string(data), "- ship the beta in April") || strings.Contains(string(data), "March") {
+		t.Errorf("expected the line rewritten in place, got:\n%s", data)
+	}
+}
+
+func TestStore_DatedTargetTemplate(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{
+		MarkdownTargets: map[string]string{CategoryFact: "facts/{yyyy}-{mm}.md"},
+	})
+
+	if _, err := s.Store("the office moved to Berlin", "fact", "chat", nil); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	now := time.Now()
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "facts", now.Format("2006-01")+".md"))
+	if err != nil {
+		t.Fatalf("failed to read dated target: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# "+now.Format("2006-01-02")+"\n") || !strings.Contains(string(data), "- the office moved to Berlin") {
+		t.Errorf("unexpected dated target:\n%s", data)
+	}
+}
+
+func TestStore_DBOnlyWritesNoMarkdown(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{DisableMarkdown: true})
+
+	id, err := s.Store("user likes vim", "preference", "chat", nil)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if _, err := s.Store("deployed v2.0", "event", "chat", nil); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if err := s.Update(id, "user likes helix", "note"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	var markdown []string
+	filepath.WalkDir(filepath.Join(s.workspace, "memory"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".md") {
+			markdown = append(markdown, path)
+		}
+		return nil
+	})
+	if len(markdown) != 0 {
+		t.Fatalf("DB-only mode wrote markdown files: %v", markdown)
+	}
+	if results, _ := s.Search("helix", 5, ""); len(results) != 1 {
+		t.Fatalf("expected the memory in the database, got %d results", len(results))
+	}
+}
+
+func TestStore_EmptyTargetKeepsCategoryOutOfMarkdown(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{
+		MarkdownTargets: map[string]string{CategoryPreference: ""},
+	})
+
+	s.Store("user likes vim", "preference", "chat", nil)
+	s.Store("keep notes short", "note", "chat", nil)
+
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if err != nil {
+		t.Fatalf("failed to read MEMORY.md: %v", err)
+	}
+	if strings.Contains(string(data), "vim") || !strings.Contains(string(data), "keep notes short") {
+		t.Errorf("expected only the note in MEMORY.md, got:\n%s", data)
+	}
+}
+
+func TestNewMemoryStore_RejectsInvalidMarkdownTargets(t *testing.T) {
+	workspace := t.TempDir()
+	_, err := NewMemoryStoreWithOptions(filepath.Join(workspace, "memory.db"), workspace, StoreOptions{
+		MarkdownTargets: map[string]string{"project": "../PROJECT.md"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid markdown targets") {
+		t.Fatalf("expected constructor to reject bad target, got %v", err)
+	}
+}
+
+func TestReindex_ImportsConfiguredTargetFile(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{
+		MarkdownTargets: map[string]string{"project": "PROJECT.md"},
+	})
+	os.MkdirAll(filepath.Join(s.workspace, "memory"), 0755)
+	os.WriteFile(filepath.Join(s.workspace, "memory", "PROJECT.md"), []byte("# PROJECT\n\n- launch is on Friday\n"), 0644)
+
+	if err := s.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	results, _ := s.List("project", 10)
+	if len(results) != 1 || results[0].Content != "launch is on Friday" {
+		t.Fatalf("expected the line imported as project, got %+v", results)
+	}
+}
+
 func TestStore_WritesToMarkdown_CapsMemoryFileSize(t *testing.T) {
 	s := newTestStore(t)
 
@@ -198,6 +424,151 @@ func TestGet_NotFound(t *testing.T) {
 	}
 }
 
+// --- Update ---
+
+func TestUpdate_RewritesRowAndSearchIndex(t *testing.T) {
+	s := newTestStore(t)
+
+	id, _ := s.Store("user lives in Tokyo", "fact", "chat", nil)
+	if err := s.Update(id, "user lives in Osaka", ""); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	mem, err := s.Get(id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if mem.Content != "user lives in Osaka" || mem.Category != "fact" {
+		t.Errorf("unexpected memory after update: %+v", mem)
+	}
+
+	if results, _ := s.Search("Tokyo", 5, ""); len(results) != 0 {
+		t.Errorf("expected stale content to be gone from the index, got %+v", results)
+	}
+	if results, _ := s.Search("Osaka", 5, ""); len(results) != 1 || results[0].ID != id {
+		t.Errorf("expected updated content to be searchable, got %+v", results)
+	}
+}
+
+func TestUpdate_RewritesMarkdownLine(t *testing.T) {
+	s := newTestStore(t)
+
+	s.Store("user likes vim", "preference", "chat", nil)
+	id, _ := s.Store("user likes tabs", "preference", "chat", nil)
+	if err := s.Update(id, "user likes spaces", ""); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if err != nil {
+		t.Fatalf("failed to read MEMORY.md: %v", err)
+	}
+	content := string(data)
+	if strings.Contains(content, "user likes tabs") {
+		t.Errorf("expected stale line to be replaced, got:\n%s", content)
+	}
+	if strings.Count(content, "- user likes spaces") != 1 || !strings.Contains(content, "- user likes vim") {
+		t.Errorf("unexpected MEMORY.md after update:\n%s", content)
+	}
+}
+
+func TestMarkdownMirror_ConcurrentRewritesKeepEveryLine(t *testing.T) {
+	s := newTestStore(t)
+
+	var olds []*Memory
+	for i := 0; i < 10; i++ {
+		id, err := s.Store(fmt.Sprintf("old fact %d", i), "preference", "chat", nil)
+		if err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		old, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		olds = append(olds, old)
+	}
+
+	// Drive the mirror directly: the rows are already in the DB, and the
+	// point is that rewrites and appends to one file do not lose lines.
+	var wg sync.WaitGroup
+	for i, old := range olds {
+		wg.Add(2)
+		go func(i int, old *Memory) {
+			defer wg.Done()
+			if !s.rewriteMarkdownEntry(old, fmt.Sprintf("new fact %d", i)) {
+				t.Errorf("rewriteMarkdownEntry(%q) found no line", old.Content)
+			}
+		}(i, old)
+		go func(i int) {
+			defer wg.Done()
+			s.writeToMarkdown(fmt.Sprintf("extra fact %d", i), "preference")
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if err != nil {
+		t.Fatalf("failed to read MEMORY.md: %v", err)
+	}
+	content := string(data) + "\n"
+	for i := range olds {
+		for _, want := range []string{fmt.Sprintf("- new fact %d\n", i), fmt.Sprintf("- extra fact %d\n", i)} {
+			if strings.Count(content, want) != 1 {
+				t.Errorf("expected exactly one %q in MEMORY.md, got:\n%s", want, content)
+			}
+		}
+		if strings.Contains(content, fmt.Sprintf("old fact %d\n", i)) {
+			t.Errorf("expected old fact %d to be rewritten, got:\n%s", i, content)
+		}
+	}
+}
+
+func TestUpdate_RewritesOnlyItsOwnFile(t *testing.T) {
+	s := newTestStore(t)
+
+	s.Store("standup at 10", "note", "chat", nil)
+	id, _ := s.Store("standup at 10", "event", "chat", nil)
+	if err := s.Update(id, "standup at 11", ""); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	memoryMD, _ := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if !strings.Contains(string(memoryMD), "- standup at 10\n") {
+		t.Errorf("expected the note's line in MEMORY.md untouched, got:\n%s", memoryMD)
+	}
+	today := time.Now().Format("20060102")
+	daily, _ := os.ReadFile(filepath.Join(s.workspace, "memory", today[:6], today+".md"))
+	if strings.Contains(string(daily), "- standup at 10\n") || !strings.Contains(string(daily), "- standup at 11") {
+		t.Errorf("expected the event's line rewritten in the daily log, got:\n%s", daily)
+	}
+}
+
+func TestUpdate_CategoryChangeMovesMarkdownEntry(t *testing.T) {
+	s := newTestStore(t)
+
+	id, _ := s.Store("standup at 10", "event", "chat", nil)
+	if err := s.Update(id, "standup at 10 daily", "note"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	today := time.Now().Format("20060102")
+	daily, _ := os.ReadFile(filepath.Join(s.workspace, "memory", today[:6], today+".md"))
+	if strings.Contains(string(daily), "standup at 10") {
+		t.Errorf("expected entry removed from daily log, got:\n%s", string(daily))
+	}
+	memoryMD, _ := os.ReadFile(filepath.Join(s.workspace, "memory", "MEMORY.md"))
+	if !strings.Contains(string(memoryMD), "- standup at 10 daily") {
+		t.Errorf("expected entry in MEMORY.md, got:\n%s", string(memoryMD))
+	}
+}
+
+func TestUpdate_NotFound(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Update(999, "anything", ""); err == nil {
+		t.Error("expected error for nonexistent memory")
+	}
+}
+
 // --- Delete ---
 
 func TestDelete(t *testing.T) {
@@ -223,6 +594,95 @@ func TestDelete_NotFound(t *testing.T) {
 	}
 }
 
+// --- Degraded mode ---
+
+func TestStore_DegradesWhenDatabaseNotWritableAndRecovers(t *testing.T) {
+	s := newTestStore(t)
+	clock := time.Date(2026, 2, 20, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+	if _, err := s.Store("user prefers dark mode", "preference", "chat", nil); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// One connection so the pragma applies to every statement.
+	s.db.SetMaxOpenConns(1)
+	if _, err := s.db.Exec("PRAGMA query_only = ON"); err != nil {
+		t.Fatalf("pragma failed: %v", err)
+	}
+
+	if _, err := s.Store("user works at Sipeed", "fact", "chat", nil); !errors.Is(err, ErrMemoryUnavailable) {
+		t.Fatalf("expected ErrMemoryUnavailable, got %v", err)
+	}
+	if !s.Degraded() {
+		t.Fatal("expected store to be degraded after a read-only write failure")
+	}
+	if err := s.Update(1, "changed", ""); !errors.Is(err, ErrMemoryUnavailable) {
+		t.Fatalf("expected Update to fail soft, got %v", err)
+	}
+	if err := s.Delete(1); !errors.Is(err, ErrMemoryUnavailable) {
+		t.Fatalf("expected Delete to fail soft, got %v", err)
+	}
+	results, err := s.Search("dark", 5, "")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search while degraded = %v, %v; want 1 result", results, err)
+	}
+
+	// Writable again, but no probe until the interval passes.
+	if _, err := s.db.Exec("PRAGMA query_only = OFF"); err != nil {
+		t.Fatalf("pragma failed: %v", err)
+	}
+	if _, err := s.Store("user works at Sipeed", "fact", "chat", nil); !errors.Is(err, ErrMemoryUnavailable) {
+		t.Fatalf("expected write to fail fast before the probe interval, got %v", err)
+	}
+	clock = clock.Add(defaultWriteProbeInterval)
+	if _, err := s.Store("user works at Sipeed", "fact", "chat", nil); err != nil {
+		t.Fatalf("expected probe write to succeed, got %v", err)
+	}
+	if s.Degraded() {
+		t.Fatal("expected store to recover after a successful write")
+	}
+}
+
+func TestNewMemoryStore_OpensReadOnlyDatabaseDegraded(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "memory.db")
+	s, err := NewMemoryStore(dbPath, dir)
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	s.Store("user prefers dark mode", "preference", "chat", nil)
+	s.Close()
+
+	ro, err := NewMemoryStore("file:"+dbPath+"?mode=ro", dir)
+	if err != nil {
+		t.Fatalf("opening a read-only database should succeed, got %v", err)
+	}
+	defer ro.Close()
+	if !ro.Degraded() {
+		t.Fatal("expected read-only database to open degraded")
+	}
+	if results, err := ro.Search("dark", 5, ""); err != nil || len(results) != 1 {
+		t.Fatalf("Search = %v, %v; want 1 result", results, err)
+	}
+	// The URI names the database; it is not a relative directory to create.
+	if _, err := os.Stat("file:"); !os.IsNotExist(err) {
+		t.Fatalf("expected no file: directory in the working dir, stat err = %v", err)
+	}
+}
+
+func TestSqliteFilePath(t *testing.T) {
+	tests := map[string]string{
+		"/data/memory.db":              "/data/memory.db",
+		"file:/data/memory.db?mode=ro": "/data/memory.db",
+		"file:/data/memory.db":         "/data/memory.db",
+	}
+	for in, want := range tests {
+		if got := sqliteFilePath(in); got != want {
+			t.Errorf("sqliteFilePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
 // --- List ---
 
 func TestList(t *testing.T) {
@@ -333,6 +793,108 @@ func TestSearch_EmptyQuery(t *testing.T) {
 	}
 }
 
+func TestBuildFTSQuery_Modes(t *testing.T) {
+	cases := []struct {
+		mode SearchMode
+		want string
+	}{
+		{SearchPrefix, `"dark"* "mode"*`},
+		{SearchExact, `"dark" "mode"`},
+		{SearchPhrase, `"dark mode"`},
+	}
+	for _, tc := range cases {
+		if got := buildFTSQuery("  dark   mode ", tc.mode); got != tc.want {
+			t.Errorf("%s: buildFTSQuery = %q, want %q", tc.mode, got, tc.want)
+		}
+	}
+	if got := buildFTSQuery(`say "hi"`, SearchPhrase); got != `"say ""hi"""` {
+		t.Errorf("phrase with quotes = %q", got)
+	}
+}
+
+func TestSearchWithMode_ExactAndPhrase(t *testing.T) {
+	s := newTestStore(t)
+	s.Store("user prefers dark mode", "preference", "chat", nil)
+	s.Store("mode of darkness in the room", "note", "chat", nil)
+
+	count := func(query string, mode SearchMode) int {
+		t.Helper()
+		results, err := s.SearchWithMode(query, 5, "", mode)
+		if err != nil {
+			t.Fatalf("SearchWithMode(%q, %s) failed: %v", query, mode, err)
+		}
+		return len(results)
+	}
+
+	if got := count("dark mode", SearchPrefix); got != 2 {
+		t.Errorf("prefix matches = %d, want 2 (dark* also finds darkness)", got)
+	}
+	if got := count("dark mode", SearchExact); got != 1 {
+		t.Errorf("exact matches = %d, want 1", got)
+	}
+	if got := count("mode dark", SearchPhrase); got != 0 {
+		t.Errorf("phrase in wrong order matches = %d, want 0", got)
+	}
+	if got := count("dark mode", SearchPhrase); got != 1 {
+		t.Errorf("phrase matches = %d, want 1", got)
+	}
+}
+
+func TestParseSearchMode(t *testing.T) {
+	if mode, err := ParseSearchMode(""); err != nil || mode != SearchPrefix {
+		t.Errorf("empty mode = %q, %v; want prefix", mode, err)
+	}
+	if mode, err := ParseSearchMode("Phrase"); err != nil || mode != SearchPhrase {
+		t.Errorf("Phrase = %q, %v; want phrase", mode, err)
+	}
+	if _, err := ParseSearchMode("fuzzy"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestSearch_PorterTokenizerStems(t *testing.T) {
+	workspace := t.TempDir()
+	dbPath := filepath.Join(workspace, "memory", "memory.db")
+
+	s, err := NewMemoryStore(dbPath, workspace)
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	s.Store("user goes running every morning", "fact", "chat", nil)
+	if results, _ := s.SearchWithMode("run", 5, "", SearchExact); len(results) != 0 {
+		t.Fatalf("unicode61 should not stem, got %d results", len(results))
+	}
+	s.Close()
+
+	// Reopening with porter rebuilds the index over the existing rows.
+	s, err = NewMemoryStoreWithOptions(dbPath, workspace, StoreOptions{Tokenizer: TokenizerPorter})
+	if err != nil {
+		t.Fatalf("NewMemoryStoreWithOptions failed: %v", err)
+	}
+	defer s.Close()
+
+	results, err := s.SearchWithMode("run", 5, "", SearchExact)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Content, "running") {
+		t.Fatalf("expected porter to match 'running' for 'run', got %v", results)
+	}
+
+	s.Store("she runs the team", "fact", "chat", nil)
+	if results, _ := s.SearchWithMode("run", 5, "", SearchExact); len(results) != 2 {
+		t.Fatalf("expected new rows to be stemmed too, got %d results", len(results))
+	}
+}
+
+func TestNewMemoryStoreWithOptions_RejectsUnknownTokenizer(t *testing.T) {
+	workspace := t.TempDir()
+	_, err := NewMemoryStoreWithOptions(filepath.Join(workspace, "memory.db"), workspace, StoreOptions{Tokenizer: "trigram"})
+	if err == nil || !strings.Contains(err.Error(), "unknown FTS tokenizer") {
+		t.Fatalf("expected unknown tokenizer error, got %v", err)
+	}
+}
+
 // --- Stats ---
 
 func TestStats(t *testing.T) {
@@ -441,3 +1003,226 @@ func TestReindex_Idempotent(t *testing.T) {
 		t.Errorf("reindex created duplicates: %d vs %d", stats1.Total, stats2.Total)
 	}
 }
+
+func TestReindex_SkipsUnchangedFiles(t *testing.T) {
+	s := newTestStore(t)
+	memoryDir := filepath.Join(s.workspace, "memory")
+	os.WriteFile(filepath.Join(memoryDir, "MEMORY.md"), []byte("# Memory\n\n- user likes Go\n"), 0644)
+	os.MkdirAll(filepath.Join(memoryDir, "202602"), 0755)
+	dailyFile := filepath.Join(memoryDir, "202602", "20260212.md")
+	os.WriteFile(dailyFile, []byte("# 2026-02-12\n\n- deployed v2.0\n"), 0644)
+
+	first, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if first.scanned != 2 || first.added != 2 {
+		t.Fatalf("first reindex = %+v, want 2 files scanned, 2 rows added", first)
+	}
+
+	second, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if second.scanned != 0 || second.skipped != 2 || second.added != 0 {
+		t.Fatalf("second reindex = %+v, want both files skipped", second)
+	}
+
+	os.WriteFile(dailyFile, []byte("# 2026-02-12\n\n- deployed v2.0\n- fixed auth bug\n"), 0644)
+	third, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if third.scanned != 1 || third.skipped != 1 || third.added != 1 {
+		t.Fatalf("third reindex = %+v, want only the edited file scanned", third)
+	}
+	if results, _ := s.Search("auth", 5, ""); len(results) != 1 || results[0].Category != "event" {
+		t.Fatalf("expected the new daily line to be indexed as an event, got %+v", results)
+	}
+}
+
+func TestReindex_RemovesRowsForDeletedLines(t *testing.T) {
+	s := newTestStore(t)
+	memoryFile := filepath.Join(s.workspace, "memory", "MEMORY.md")
+	os.WriteFile(memoryFile, []byte("# Memory\n\n- user likes Go\n- user prefers vim\n"), 0644)
+	if err := s.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	// A memory stored from chat is written through to the same file.
+	if _, err := s.Store("user prefers tea", "preference", "chat", nil); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	os.WriteFile(memoryFile, []byte("# Memory\n\n- user likes Go\n"), 0644)
+	stats, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if stats.removed != 1 {
+		t.Fatalf("reindex = %+v, want 1 row removed", stats)
+	}
+
+	if results, _ := s.Search("vim", 5, ""); len(results) != 0 {
+		t.Fatalf("expected the deleted line's row to be gone, got %+v", results)
+	}
+	if results, _ := s.Search("Go", 5, ""); len(results) != 1 {
+		t.Fatalf("expected the kept line to stay indexed, got %+v", results)
+	}
+	if results, _ := s.Search("tea", 5, ""); len(results) != 1 {
+		t.Fatalf("expected chat-stored memories to survive the markdown edit, got %+v", results)
+	}
+}
+
+func TestReindex_KeepsRowsTrimmedFromMarkdown(t *testing.T) {
+	s := newTestStore(t)
+	memoryFile := filepath.Join(s.workspace, "memory", "MEMORY.md")
+	os.WriteFile(memoryFile, []byte("# Memory\n\n- oldest fact about llamas\n- user likes Go\n"), 0644)
+	s.Reindex()
+
+	os.WriteFile(memoryFile, []byte("# Memory\n\n"+markdownTrimNotice+"\n\n- user likes Go\n"), 0644)
+	stats, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if stats.removed != 0 {
+		t.Fatalf("reindex = %+v, want trimmed lines kept", stats)
+	}
+	if results, _ := s.Search("llamas", 5, ""); len(results) != 1 {
+		t.Fatalf("expected the trimmed line to stay searchable, got %+v", results)
+	}
+}
+
+func TestReindex_MigratesVersion1Database(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	memoryDir := filepath.Join(workspace, "memory")
+	os.MkdirAll(memoryDir, 0755)
+	dbPath := filepath.Join(memoryDir, "memory.db")
+
+	// Turn a fresh database back into v1 with a row imported before source
+	// files were tracked.
+	s, err := NewMemoryStore(dbPath, workspace)
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	s.storeIfNew("user prefers vim", "note", "import", "")
+	_, err = s.db.Exec(`
+		DROP TABLE memory_sources;
+		DROP INDEX idx_memories_source_file;
+		ALTER TABLE memories DROP COLUMN source_file;
+		UPDATE schema_version SET version = 1;`)
+	if err != nil {
+		t.Fatalf("downgrade to v1 failed: %v", err)
+	}
+	s.Close()
+
+	memoryFile := filepath.Join(memoryDir, "MEMORY.md")
+	os.WriteFile(memoryFile, []byte("- user prefers vim\n- user likes Go\n"), 0644)
+
+	s, err = NewMemoryStore(dbPath, workspace)
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	defer s.Close()
+	if version, _ := s.SchemaVersion(); version != schemaVersion {
+		t.Fatalf("schema version = %d, want %d", version, schemaVersion)
+	}
+
+	if stats, err := s.reindex(); err != nil || stats.added != 1 {
+		t.Fatalf("reindex = %+v, %v; want 1 new row", stats, err)
+	}
+
+	// The legacy row was attributed to MEMORY.md, so deleting its line removes it.
+	os.WriteFile(memoryFile, []byte("- user likes Go\n"), 0644)
+	if stats, err := s.reindex(); err != nil || stats.removed != 1 {
+		t.Fatalf("reindex = %+v, %v; want the legacy row removed", stats, err)
+	}
+	if results, _ := s.Search("vim", 5, ""); len(results) != 0 {
+		t.Fatalf("expected legacy row to be gone, got %+v", results)
+	}
+}
+
+func TestReindex_LargeCorpusInOneTransaction(t *testing.T) {
+	s := newTestStoreWithOptions(t, StoreOptions{ReindexWorkers: 4, ReindexBatchSize: 100})
+	memoryDir := filepath.Join(s.workspace, "memory")
+
+	const days, linesPerDay = 40, 150
+	for d := 0; d < days; d++ {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# 2026-03-%02d\n\n", d+1)
+		for i := 0; i < linesPerDay; i++ {
+			fmt.Fprintf(&b, "- event %d of day %d\n", i, d)
+		}
+		// A line repeated across files is stored once.
+		b.WriteString("- daily standup\n")
+		dir := filepath.Join(memoryDir, fmt.Sprintf("2026%02d", d/28+3))
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("2026%02d%02d.md", d/28+3, d%28+1)), []byte(b.String()), 0644)
+	}
+
+	commits := 0
+	orig := reindexCommit
+	reindexCommit = func(tx *sql.Tx) error {
+		commits++
+		return orig(tx)
+	}
+	defer func() { reindexCommit = orig }()
+
+	first, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	want := days*linesPerDay + 1
+	if first.scanned != days || first.added != want {
+		t.Fatalf("first reindex = %+v, want %d files scanned and %d rows added", first, days, want)
+	}
+	if commits != 1 {
+		t.Fatalf("first reindex committed %d times, want 1", commits)
+	}
+	if stats, _ := s.Stats(); stats.Total != want {
+		t.Fatalf("Total = %d, want %d", stats.Total, want)
+	}
+
+	// Forget the file fingerprints so every line is checked again.
+	if _, err := s.db.Exec("DELETE FROM memory_sources"); err != nil {
+		t.Fatalf("clear memory_sources: %v", err)
+	}
+	second, err := s.reindex()
+	if err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if second.scanned != days || second.added != 0 || second.removed != 0 {
+		t.Fatalf("second reindex = %+v, want every file rescanned and no rows changed", second)
+	}
+	if stats, _ := s.Stats(); stats.Total != want {
+		t.Fatalf("Total after second reindex = %d, want %d", stats.Total, want)
+	}
+	if results, _ := s.Search("event 149 of day 39", 5, ""); len(results) == 0 {
+		t.Fatal("expected imported lines to be searchable")
+	}
+}
+
+func TestReindex_FailedCommitLeavesIndexUnchanged(t *testing.T) {
+	s := newTestStore(t)
+	os.WriteFile(filepath.Join(s.workspace, "memory", "MEMORY.md"), []byte("- user likes Go\n"), 0644)
+
+	orig := reindexCommit
+	reindexCommit = func(tx *sql.Tx) error {
+		tx.Rollback()
+		return errors.New("commit failed")
+	}
+	_, err := s.reindex()
+	reindexCommit = orig
+	if err == nil {
+		t.Fatal("expected the commit error")
+	}
+	if stats, _ := s.Stats(); stats.Total != 0 {
+		t.Fatalf("Total = %d, want nothing stored", stats.Total)
+	}
+
+	// The next run imports the file, since it was never recorded.
+	if stats, err := s.reindex(); err != nil || stats.added != 1 {
+		t.Fatalf("reindex = %+v, %v; want 1 row added", stats, err)
+	}
+}
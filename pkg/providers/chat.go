@@ -12,8 +12,40 @@ import (
 
 const chatTimeoutRetryAttempts = 2
 
+// RequestTimeouter is implemented by providers that bound each request
+// themselves (see ProviderConfig.RequestTimeoutSeconds).
+type RequestTimeouter interface {
+	RequestTimeout() time.Duration
+}
+
+// providerRequestTimeout returns the per-request limit provider enforces for
+// model, or 0 if it has none. A fallback chain only reports one when every
+// candidate has its own, so no candidate is left unbounded. Wrappers are
+// looked through.
+func providerRequestTimeout(provider LLMProvider, model string) time.Duration {
+	switch p := provider.(type) {
+	case *fallbackProvider:
+		var longest time.Duration
+		for _, c := range p.orderedCandidates(model) {
+			timeout := providerRequestTimeout(c.provider, c.model)
+			if timeout <= 0 {
+				return 0
+			}
+			longest = max(longest, timeout)
+		}
+		return longest
+	case RequestTimeouter:
+		return p.RequestTimeout()
+	case ProviderWrapper:
+		return providerRequestTimeout(p.Unwrap(), model)
+	}
+	return 0
+}
+
 // ChatWithTimeout wraps provider.Chat with an optional per-call timeout.
-// timeout <= 0 means no additional timeout is applied.
+// timeout <= 0 means no additional timeout is applied. A provider with its
+// own request timeout replaces timeout: it bounds each HTTP attempt and
+// retries timed-out attempts itself, so no outer deadline is added.
 func ChatWithTimeout(
 	ctx context.Context,
 	timeout time.Duration,
@@ -23,6 +55,9 @@ func ChatWithTimeout(
 	model string,
 	options map[string]interface{},
 ) (*LLMResponse, error) {
+	if providerRequestTimeout(provider, model) > 0 {
+		timeout = 0
+	}
 	attempts := 1
 	if timeout > 0 {
 		attempts = chatTimeoutRetryAttempts
@@ -44,6 +79,9 @@ func ChatWithTimeout(
 		if attempt == attempts || ctx.Err() != nil || !isTimeoutRetryableError(err) {
 			return nil, err
 		}
+		if !RetryBudgetFromContext(ctx).Allow(0) {
+			return nil, err
+		}
 
 		logger.WarnCF("provider", "Retrying timed-out LLM call",
 			map[string]interface{}{
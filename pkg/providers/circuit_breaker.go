@@ -0,0 +1,167 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultCircuitBreakerWindow   = 60 * time.Second
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the provider while its
+// circuit breaker is open. The model fallback chain treats it as eligible.
+var ErrCircuitOpen = errors.New("provider temporarily unavailable: circuit breaker open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker fails requests fast after threshold consecutive failed
+// requests within window. After cooldown one probe request is let through
+// (half-open): its success closes the circuit, its failure reopens it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state        circuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// newCircuitBreaker returns nil (disabled) when threshold <= 0. Non-positive
+// window and cooldown use the defaults.
+func newCircuitBreaker(name string, threshold int, window, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = defaultCircuitBreakerWindow
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	return &circuitBreaker{
+		name:      name,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether a request may go out. While open it returns an error
+// wrapping ErrCircuitOpen.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return b.openError(remaining)
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		logger.InfoCF("provider", "Circuit breaker half-open, probing provider",
+			map[string]interface{}{"provider": b.name})
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			return b.openError(0)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *circuitBreaker) openError(remaining time.Duration) error {
+	err := fmt.Errorf("%w (%s: %d consecutive failures", ErrCircuitOpen, b.name, b.failures)
+	if remaining > 0 {
+		err = fmt.Errorf("%w, retry in %s)", err, remaining.Round(time.Second))
+	} else {
+		err = fmt.Errorf("%w, recovery probe in progress)", err)
+	}
+	return &ProviderError{Kind: ProviderErrorTransient, Err: err}
+}
+
+// record updates the breaker with a request's outcome. Only failures that
+// suggest the provider is unhealthy count; bad requests, auth errors and
+// cancellation by the caller do not.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+
+	if err != nil && ctx.Err() != nil {
+		return // the caller gave up; says nothing about the provider
+	}
+	if err == nil || !countsAsProviderFailure(err) {
+		if b.state != circuitClosed {
+			logger.InfoCF("provider", "Circuit breaker closed, provider recovered",
+				map[string]interface{}{"provider": b.name})
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	now := b.now()
+	if wasProbe && b.state == circuitHalfOpen {
+		b.open(now, err)
+		return
+	}
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.state == circuitClosed && b.failures >= b.threshold {
+		b.open(now, err)
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time, err error) {
+	b.state = circuitOpen
+	b.openedAt = now
+	logger.WarnCF("provider", "Circuit breaker opened, failing fast",
+		map[string]interface{}{
+			"provider":   b.name,
+			"failures":   b.failures,
+			"cooldown":   b.cooldown.String(),
+			"last_error": err.Error(),
+		})
+}
+
+func countsAsProviderFailure(err error) bool {
+	switch ProviderErrorKindOf(err) {
+	case ProviderErrorAuth, ProviderErrorBadRequest, ProviderErrorRefused:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestCircuitBreaker_OpensAfterThresholdAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error": "overloaded"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("recovered"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	p.maxRetries = 0
+	p.SetCircuitBreaker(3, time.Minute, 30*time.Second)
+	clock := &fakeClock{t: time.Now()}
+	p.breaker.now = clock.now
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); err == nil {
+			t.Fatalf("call %d: expected failure", i+1)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("server calls = %d, want 3", calls.Load())
+	}
+
+	// Open: fail fast without reaching the server, and trigger model fallback.
+	_, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if !isModelFallbackEligibleError(err) || ProviderErrorKindOf(err) != ProviderErrorTransient {
+		t.Fatalf("open circuit error should be a transient, fallback-eligible error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("server called while circuit open: %d calls", calls.Load())
+	}
+
+	// Half-open: the probe fails and the circuit reopens for another cooldown.
+	clock.advance(31 * time.Second)
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the probe to reach the failing server, got %v", err)
+	}
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected circuit to reopen after failed probe, got %v", err)
+	}
+
+	// Recovery: a successful probe closes the circuit.
+	healthy.Store(true)
+	clock.advance(31 * time.Second)
+	resp, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if err != nil || resp.Content != "recovered" {
+		t.Fatalf("probe = %v, %v; want recovered", resp, err)
+	}
+	healthy.Store(false)
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected closed circuit to let requests through after recovery")
+	}
+	if calls.Load() != 6 {
+		t.Fatalf("server calls = %d, want 6", calls.Load())
+	}
+}
+
+func TestCircuitBreaker_FailuresOutsideWindowDoNotOpen(t *testing.T) {
+	b := newCircuitBreaker("test", 2, time.Minute, time.Minute)
+	clock := &fakeClock{t: time.Now()}
+	b.now = clock.now
+	failure := &ProviderError{Kind: ProviderErrorTransient, Err: fmt.Errorf("boom")}
+
+	b.record(context.Background(), failure)
+	clock.advance(2 * time.Minute)
+	b.record(context.Background(), failure)
+	if err := b.allow(); err != nil {
+		t.Fatalf("failures in separate windows opened the circuit: %v", err)
+	}
+
+	b.record(context.Background(), failure)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected two failures within the window to open, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_IgnoresClientErrorsAndCancellation(t *testing.T) {
+	b := newCircuitBreaker("test", 1, time.Minute, time.Minute)
+
+	b.record(context.Background(), &ProviderError{Kind: ProviderErrorBadRequest, Err: fmt.Errorf("bad schema")})
+	b.record(context.Background(), &ProviderError{Kind: ProviderErrorAuth, Err: fmt.Errorf("bad key")})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.record(ctx, fmt.Errorf("context canceled"))
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected circuit to stay closed, got %v", err)
+	}
+	if newCircuitBreaker("off", 0, 0, 0) != nil {
+		t.Fatal("threshold 0 should disable the breaker")
+	}
+}
+
+func TestFallbackProvider_OpenCircuitFallsBackImmediately(t *testing.T) {
+	primary := &scriptedProvider{results: []scriptedResult{{err: (&circuitBreaker{name: "primary", failures: 3}).openError(time.Second)}}}
+	backup := &scriptedProvider{results: []scriptedResult{{resp: &LLMResponse{Content: "from-backup"}}}}
+
+	p := newFallbackProvider("primary-model", []fallbackCandidate{
+		{model: "primary-model", provider: primary},
+		{model: "backup-model", provider: backup},
+	})
+
+	resp, err := p.Chat(context.Background(), nil, nil, "primary-model", nil)
+	if err != nil || resp.Content != "from-backup" {
+		t.Fatalf("Chat() = %v, %v; want backup response", resp, err)
+	}
+}
@@ -3,9 +3,11 @@ package providers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -20,6 +22,7 @@ type ClaudeProvider struct {
 	client      *anthropic.Client
 	token       string
 	tokenSource func() (string, error)
+	breaker     *circuitBreaker // nil = disabled
 }
 
 func NewClaudeProvider(token string) *ClaudeProvider {
@@ -36,6 +39,12 @@ func NewClaudeProviderWithTokenSource(token string, tokenSource func() (string,
 	return p
 }
 
+// SetCircuitBreaker makes the provider fail fast for cooldown after threshold
+// consecutive failed requests within window. threshold <= 0 disables it.
+func (p *ClaudeProvider) SetCircuitBreaker(threshold int, window, cooldown time.Duration) {
+	p.breaker = newCircuitBreaker("anthropic", threshold, window, cooldown)
+}
+
 func isAnthropicOAuthToken(token string) bool {
 	// Anthropic OAuth access tokens use the sk-ant-oat* prefix.
 	// These require the oauth beta header to be accepted by the API.
@@ -82,12 +91,27 @@ func (p *ClaudeProvider) Chat(ctx context.Context, messages []Message, tools []T
 
 	ensureClaudeCodeOAuthSystemPrefix(&params, tok)
 
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
 	resp, err := p.client.Messages.New(ctx, params, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("claude API call: %w", err)
+		status := 0
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) {
+			status = apiErr.StatusCode
+		}
+		err = newStatusProviderError(fmt.Errorf("claude API call: %w", err), status)
+		p.breaker.record(ctx, err)
+		return nil, err
 	}
+	p.breaker.record(ctx, nil)
 
-	return parseClaudeResponse(resp), nil
+	out := parseClaudeResponse(resp)
+	if err := refusalError(out); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func ensureClaudeCodeOAuthSystemPrefix(params *anthropic.MessageNewParams, token string) {
@@ -152,12 +176,18 @@ func buildClaudeParams(messages []Message, tools []ToolDefinition, model string,
 			imageData, err := inlineImageDataFromPart(part)
 			if err != nil {
 				logger.WarnCF("provider", "Skipping inline image part for Claude tool output", map[string]interface{}{
-					"path":  strings.TrimSpace(part.Path),
+					"path":  describeImagePart(part),
 					"error": err.Error(),
 				})
 				continue
 			}
 
+			if imageData.URL != "" {
+				img := anthropic.ImageBlockParam{Source: anthropic.ImageBlockParamSourceUnion{OfURL: &anthropic.URLImageSourceParam{URL: imageData.URL}}}
+				content = append(content, anthropic.ToolResultBlockParamContentUnion{OfImage: &img})
+				continue
+			}
+
 			mediaType, ok := anthropicMediaTypeForImage(imageData.MediaType)
 			if !ok {
 				logger.WarnCF("provider", "Skipping unsupported inline image media type for Claude tool output", map[string]interface{}{
@@ -208,12 +238,17 @@ func buildClaudeParams(messages []Message, tools []ToolDefinition, model string,
 						imageData, err := inlineImageDataFromPart(part)
 						if err != nil {
 							logger.WarnCF("provider", "Skipping inline image part for Claude request", map[string]interface{}{
-								"path":  strings.TrimSpace(part.Path),
+								"path":  describeImagePart(part),
 								"error": err.Error(),
 							})
 							continue
 						}
 
+						if imageData.URL != "" {
+							blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: imageData.URL}))
+							continue
+						}
+
 						mediaType, ok := anthropicMediaTypeForImage(imageData.MediaType)
 						if !ok {
 							logger.WarnCF("provider", "Skipping unsupported inline image media type for Claude", map[string]interface{}{
@@ -487,6 +522,8 @@ func parseClaudeResponse(resp *anthropic.Message) *LLMResponse {
 		finishReason = "length"
 	case anthropic.StopReasonEndTurn:
 		finishReason = "stop"
+	case anthropic.StopReasonRefusal:
+		finishReason = "refusal"
 	}
 
 	logClaudeCacheUsage(resp)
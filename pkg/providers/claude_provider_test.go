@@ -2,13 +2,16 @@ package providers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
@@ -551,6 +554,7 @@ func TestParseClaudeResponse_StopReasons(t *testing.T) {
 		{anthropic.StopReasonEndTurn, "stop"},
 		{anthropic.StopReasonMaxTokens, "length"},
 		{anthropic.StopReasonToolUse, "tool_calls"},
+		{anthropic.StopReasonRefusal, "refusal"},
 	}
 	for _, tt := range tests {
 		resp := &anthropic.Message{
@@ -615,6 +619,39 @@ func TestClaudeProvider_ChatRoundTrip(t *testing.T) {
 	}
 }
 
+func TestClaudeProvider_CircuitBreakerFailsFast(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := NewClaudeProvider("test-token")
+	client := anthropic.NewClient(
+		anthropicoption.WithAuthToken("test-token"),
+		anthropicoption.WithBaseURL(server.URL),
+		anthropicoption.WithMaxRetries(0),
+	)
+	provider.client = &client
+	provider.SetCircuitBreaker(2, time.Minute, time.Minute)
+
+	messages := []Message{{Role: "user", Content: "Hello"}}
+	options := map[string]interface{}{"max_tokens": 1024}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Chat(t.Context(), messages, nil, "claude-sonnet-4-5-20250929", options); err == nil {
+			t.Fatalf("call %d: expected failure", i+1)
+		}
+	}
+	_, err := provider.Chat(t.Context(), messages, nil, "claude-sonnet-4-5-20250929", options)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("server calls = %d, want 2", calls.Load())
+	}
+}
+
 func TestClaudeProvider_AddsOAuthBetaHeaderForOAuthToken(t *testing.T) {
 	oauthToken := "sk-ant-REDACTED"
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -3,8 +3,10 @@ package providers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -17,6 +19,7 @@ type CodexProvider struct {
 	client      *openai.Client
 	accountID   string
 	tokenSource func() (string, string, error)
+	breaker     *circuitBreaker // nil = disabled
 }
 
 func NewCodexProvider(token, accountID string) *CodexProvider {
@@ -40,6 +43,12 @@ func NewCodexProviderWithTokenSource(token, accountID string, tokenSource func()
 	return p
 }
 
+// SetCircuitBreaker makes the provider fail fast for cooldown after threshold
+// consecutive failed requests within window. threshold <= 0 disables it.
+func (p *CodexProvider) SetCircuitBreaker(threshold int, window, cooldown time.Duration) {
+	p.breaker = newCircuitBreaker("codex", threshold, window, cooldown)
+}
+
 func (p *CodexProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
 	var opts []option.RequestOption
 	if p.tokenSource != nil {
@@ -55,10 +64,21 @@ func (p *CodexProvider) Chat(ctx context.Context, messages []Message, tools []To
 
 	params := buildCodexParams(messages, tools, model, options)
 
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
 	resp, err := p.client.Responses.New(ctx, params, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("codex API call: %w", err)
+		status := 0
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) {
+			status = apiErr.StatusCode
+		}
+		err = newStatusProviderError(fmt.Errorf("codex API call: %w", err), status)
+		p.breaker.record(ctx, err)
+		return nil, err
 	}
+	p.breaker.record(ctx, nil)
 
 	return parseCodexResponse(resp), nil
 }
@@ -94,7 +114,7 @@ func buildCodexParams(messages []Message, tools []ToolDefinition, model string,
 						imageData, err := inlineImageDataFromPart(part)
 						if err != nil {
 							logger.WarnCF("provider", "Skipping inline image part for Codex request", map[string]interface{}{
-								"path":  strings.TrimSpace(part.Path),
+								"path":  describeImagePart(part),
 								"error": err.Error(),
 							})
 							continue
@@ -102,7 +122,7 @@ func buildCodexParams(messages []Message, tools []ToolDefinition, model string,
 
 						imagePart := responses.ResponseInputContentParamOfInputImage(responses.ResponseInputImageDetailAuto)
 						if imagePart.OfInputImage != nil {
-							imagePart.OfInputImage.ImageURL = openai.Opt(imageData.DataURL)
+							imagePart.OfInputImage.ImageURL = openai.Opt(imageData.transportURL())
 						}
 						contentParts = append(contentParts, imagePart)
 					}
@@ -164,14 +184,14 @@ func buildCodexParams(messages []Message, tools []ToolDefinition, model string,
 					imageData, err := inlineImageDataFromPart(part)
 					if err != nil {
 						logger.WarnCF("provider", "Skipping inline image part for Codex tool output", map[string]interface{}{
-							"path":  strings.TrimSpace(part.Path),
+							"path":  describeImagePart(part),
 							"error": err.Error(),
 						})
 						continue
 					}
 					items = append(items, responses.ResponseFunctionCallOutputItemUnionParam{
 						OfInputImage: &responses.ResponseInputImageContentParam{
-							ImageURL: openai.Opt(imageData.DataURL),
+							ImageURL: openai.Opt(imageData.transportURL()),
 							Detail:   responses.ResponseInputImageContentDetailAuto,
 						},
 					})
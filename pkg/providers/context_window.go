@@ -0,0 +1,78 @@
+package providers
+
+import "strings"
+
+// knownContextWindows maps model names and name prefixes to context window
+// sizes in tokens.
+var knownContextWindows = map[string]int{
+	"claude":      200000,
+	"gpt-4.1":     1047576,
+	"gpt-4o":      128000,
+	"gpt-4-turbo": 128000,
+	"gpt-5":       400000,
+	"o4-mini":     200000,
+	"o3":          200000,
+	"o1":          200000,
+	"gemini":      1048576,
+	"glm-4.7":     200000,
+	"glm-4.6":     200000,
+	"glm-4.5":     128000,
+	"deepseek":    128000,
+	"llama-3.1":   131072,
+	"llama-3.3":   131072,
+}
+
+// ContextWindowFor returns the context window for model. overrides (model
+// name or name fragment -> tokens) take precedence, then the built-in table;
+// unknown models get fallback. Within each, an exact match wins, then the
+// longest key the name starts with (with or without its "provider/" path),
+// then the longest key found anywhere in the name.
+func ContextWindowFor(model string, overrides map[string]int, fallback int) int {
+	normalized := strings.ToLower(strings.TrimSpace(model))
+	if normalized == "" {
+		return fallback
+	}
+
+	configured := make(map[string]int, len(overrides))
+	for key, tokens := range overrides {
+		if k := strings.ToLower(strings.TrimSpace(key)); k != "" && tokens > 0 {
+			configured[k] = tokens
+		}
+	}
+	if tokens, ok := matchContextWindow(normalized, configured); ok {
+		return tokens
+	}
+	if tokens, ok := matchContextWindow(normalized, knownContextWindows); ok {
+		return tokens
+	}
+	return fallback
+}
+
+// matchContextWindow looks model up in windows as ContextWindowFor
+// describes.
+func matchContextWindow(model string, windows map[string]int) (int, bool) {
+	base := model[strings.LastIndex(model, "/")+1:]
+	if tokens, ok := windows[model]; ok {
+		return tokens, true
+	}
+	if tokens, ok := windows[base]; ok {
+		return tokens, true
+	}
+
+	prefix, fragment := "", ""
+	for key := range windows {
+		if (strings.HasPrefix(model, key) || strings.HasPrefix(base, key)) && len(key) > len(prefix) {
+			prefix = key
+		}
+		if strings.Contains(model, key) && len(key) > len(fragment) {
+			fragment = key
+		}
+	}
+	if prefix != "" {
+		return windows[prefix], true
+	}
+	if fragment != "" {
+		return windows[fragment], true
+	}
+	return 0, false
+}
@@ -0,0 +1,41 @@
+package providers
+
+import "testing"
+
+func TestContextWindowFor(t *testing.T) {
+	overrides := map[string]int{
+		"llama3:8b":    8192,
+		"local/":       4096,
+		"local/big":    32768,
+		"claude-haiku": 100000,
+		"ignored":      0,
+		"qwen":         32768,
+		"coder":        131072,
+	}
+	cases := []struct {
+		model string
+		want  int
+	}{
+		{"anthropic/claude-sonnet-4", 200000},
+		{"claude-haiku-4-5", 100000},
+		{"openai/gpt-4o-mini", 128000},
+		{"gpt-4.1", 1047576},
+		{"gemini-2.5-pro", 1048576},
+		{"GLM-4.7", 200000},
+		{"llama3:8b", 8192},
+		{"ollama/llama3:8b", 8192},
+		{"qwen2.5-coder-32b", 32768},
+		{"deepseek-coder-v2", 131072},
+		{"meta-llama/llama-3.1-70b", 131072},
+		{"local/small", 4096},
+		{"local/big-model", 32768},
+		{"ignored-model", 16000},
+		{"mystery-model", 16000},
+		{"", 16000},
+	}
+	for _, tc := range cases {
+		if got := ContextWindowFor(tc.model, overrides, 16000); got != tc.want {
+			t.Fatalf("ContextWindowFor(%q) = %d, want %d", tc.model, got, tc.want)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package providers
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -29,6 +35,30 @@ func TestCreateProvider_UsesModalForGLM5(t *testing.T) {
 	}
 }
 
+func TestCreateProvider_OpenRouterAttributionAndExtraHeaders(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "openrouter/auto"
+	cfg.Providers.OpenRouter.APIKey = "or-key"
+	cfg.Providers.OpenRouter.Referer = "https://example.com"
+	cfg.Providers.OpenRouter.Title = "My Bot"
+	cfg.Providers.OpenRouter.ExtraHeaders = map[string]string{"X-Gateway": "edge"}
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	hp, ok := p.(*HTTPProvider)
+	if !ok {
+		t.Fatalf("expected HTTPProvider, got %T", p)
+	}
+	want := map[string]string{"HTTP-Referer": "https://example.com", "X-Title": "My Bot", "X-Gateway": "edge"}
+	for k, v := range want {
+		if hp.headers[k] != v {
+			t.Fatalf("headers[%q] = %q, want %q (all: %v)", k, hp.headers[k], v, hp.headers)
+		}
+	}
+}
+
 func TestCreateProvider_UsesModalCustomAPIBase(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Agents.Defaults.Model = "glm-5"
@@ -79,6 +109,30 @@ func TestCreateProvider_WithFallbackModelsBuildsFallbackProvider(t *testing.T) {
 	}
 }
 
+func TestCreateProviderWithFallbacks_OverrideModelKeepsFallbackChain(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "claude-opus-4-6"
+	cfg.Agents.Defaults.FallbackModels = []string{"claude-opus-4-6", "glm-5"}
+	cfg.Providers.Anthropic.APIKey = "anthropic-key"
+	cfg.Providers.Modal.APIKey = "modal-key"
+
+	p, err := CreateProviderWithFallbacks(cfg, "claude-sonnet-4")
+	if err != nil {
+		t.Fatalf("CreateProviderWithFallbacks() error = %v", err)
+	}
+	fp, ok := p.(*fallbackProvider)
+	if !ok {
+		t.Fatalf("expected fallbackProvider, got %T", p)
+	}
+	var models []string
+	for _, c := range fp.candidates {
+		models = append(models, c.model)
+	}
+	if strings.Join(models, ",") != "claude-sonnet-4,claude-opus-4-6,glm-5" {
+		t.Fatalf("candidates = %v, want the override first, then the fallbacks", models)
+	}
+}
+
 func TestCreateProvider_WithInvalidFallbackModelKeepsPrimaryProvider(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Agents.Defaults.Model = "claude-opus-4-6"
@@ -95,3 +149,135 @@ func TestCreateProvider_WithInvalidFallbackModelKeepsPrimaryProvider(t *testing.
 		t.Fatalf("expected primary provider only when fallbacks are invalid, got fallbackProvider")
 	}
 }
+
+func TestCreateProvider_MaxRetriesLimitsAttemptsOnPersistent500(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	maxRetries := 2
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "local-model"
+	cfg.Providers.VLLM.APIBase = srv.URL
+	cfg.Providers.VLLM.APIKey = "key"
+	cfg.Providers.VLLM.MaxRetries = &maxRetries
+	cfg.Providers.VLLM.RetryBaseWaitMS = 1
+	cfg.Providers.VLLM.RetryMaxWaitMS = 5
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "local-model", newTestOptions()); err == nil {
+		t.Fatal("expected error on persistent 500s")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3 (1 + max_retries=2)", got)
+	}
+}
+
+func TestCreateProvider_RetryStatusCodesAndWaits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "glm-4.7"
+	cfg.Providers.Zhipu.APIKey = "key"
+	cfg.Providers.Zhipu.RetryBaseWaitMS = 250
+	cfg.Providers.Zhipu.RetryMaxWaitMS = 4000
+	cfg.Providers.Zhipu.RetryStatusCodes = []int{409}
+	cfg.Providers.Zhipu.RetryContentPatterns = []string{`^Service busy\.$`}
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	hp := p.(*HTTPProvider)
+	if hp.maxRetries != defaultMaxRetries {
+		t.Fatalf("maxRetries = %d, want default %d when unset", hp.maxRetries, defaultMaxRetries)
+	}
+	if hp.retryBaseWait != 250*time.Millisecond || hp.retryMaxWait != 4*time.Second {
+		t.Fatalf("waits = %v/%v, want 250ms/4s", hp.retryBaseWait, hp.retryMaxWait)
+	}
+	if hp.retryAfterJitter != defaultRetryAfterJitter {
+		t.Fatalf("retryAfterJitter = %v, want default %v when unset", hp.retryAfterJitter, defaultRetryAfterJitter)
+	}
+	if !hp.isRetryableStatus(409, nil) || hp.isRetryableStatus(400, nil) || !hp.isRetryableStatus(503, nil) {
+		t.Fatal("expected 409 and 5xx to be retryable, 400 not")
+	}
+	if !hp.shouldRetry(&LLMResponse{Content: "Service busy."}) || hp.shouldRetry(&LLMResponse{Content: "Yes."}) {
+		t.Fatal("expected only the configured stub reply to be retryable")
+	}
+}
+
+func TestCreateProvider_ZeroRetryAfterJitterDisablesIt(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "glm-4.7"
+	cfg.Providers.Zhipu.APIKey = "key"
+	zero := 0
+	cfg.Providers.Zhipu.RetryAfterJitterMS = &zero
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if hp := p.(*HTTPProvider); hp.retryAfterJitter != 0 {
+		t.Fatalf("retryAfterJitter = %v, want 0", hp.retryAfterJitter)
+	}
+}
+
+func TestCreateProvider_ZeroMaxRetriesFailsFast(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	zero := 0
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "local-model"
+	cfg.Providers.VLLM.APIBase = srv.URL
+	cfg.Providers.VLLM.APIKey = "key"
+	cfg.Providers.VLLM.MaxRetries = &zero
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	_, _ = p.Chat(context.Background(), newTestMessages(), nil, "local-model", newTestOptions())
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("attempts = %d, want 1 with max_retries=0", got)
+	}
+}
+
+func TestCreateProvider_ModalPrefixWinsOverZhipuFamily(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "zai-org/GLM-5-FP8"
+	cfg.Providers.Zhipu.APIKey = "zhipu-key"
+	cfg.Providers.Modal.APIKey = "modal-key"
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	hp, ok := p.(*HTTPProvider)
+	if !ok || hp.apiKey != "modal-key" {
+		t.Fatalf("expected Modal HTTPProvider, got %T", p)
+	}
+}
+
+func TestCreateProvider_ExplainsMissingProvider(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "glm-4.7"
+	_, err := CreateProvider(cfg)
+	if err == nil || !strings.Contains(err.Error(), "providers.zhipu.api_key") {
+		t.Fatalf("expected error naming providers.zhipu.api_key, got %v", err)
+	}
+
+	cfg.Agents.Defaults.Model = "llama-3-70b"
+	_, err = CreateProvider(cfg)
+	if err == nil || !strings.Contains(err.Error(), "unknown model") || !strings.Contains(err.Error(), "Known providers") {
+		t.Fatalf("expected unknown model error listing providers, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// ProviderErrorKind classifies LLM call failures so callers can tell the user
+// whether retrying makes sense or the configuration needs fixing.
+type ProviderErrorKind string
+
+const (
+	ProviderErrorUnknown     ProviderErrorKind = "unknown"
+	ProviderErrorAuth        ProviderErrorKind = "auth"
+	ProviderErrorBadRequest  ProviderErrorKind = "bad_request"
+	ProviderErrorRateLimited ProviderErrorKind = "rate_limited"
+	ProviderErrorTransient   ProviderErrorKind = "transient"
+	// ProviderErrorRefused is a response the provider's content policy
+	// blocked. Retrying the same request would be refused again.
+	ProviderErrorRefused ProviderErrorKind = "refused"
+)
+
+// ProviderError wraps a provider failure with its classification. Error()
+// returns the wrapped message unchanged.
+type ProviderError struct {
+	Kind       ProviderErrorKind
+	StatusCode int // HTTP status, 0 for transport failures
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyHTTPError maps a non-200 response to an error kind.
+func ClassifyHTTPError(statusCode int, body []byte) ProviderErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ProviderErrorRateLimited
+	case statusCode == http.StatusRequestTimeout || statusCode >= 500:
+		return ProviderErrorTransient
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		if isRetryableHTTPError(statusCode, body) {
+			// OpenRouter's spurious "User not found." 401.
+			return ProviderErrorTransient
+		}
+		return ProviderErrorAuth
+	case statusCode >= 400 && statusCode < 500:
+		return ProviderErrorBadRequest
+	default:
+		return ProviderErrorUnknown
+	}
+}
+
+// refusalFinishReasons are finish reasons meaning the answer was withheld by
+// a content policy: OpenAI-compatible "content_filter", Anthropic "refusal"
+// and Gemini's safety stops (lowercased by geminiFinishReason).
+var refusalFinishReasons = map[string]bool{
+	"content_filter":     true,
+	"refusal":            true,
+	"safety":             true,
+	"prohibited_content": true,
+	"blocklist":          true,
+	"spii":               true,
+	"image_safety":       true,
+}
+
+// refusalError returns a ProviderErrorRefused error when resp is a
+// content-policy refusal rather than an answer, or nil.
+func refusalError(resp *LLMResponse) error {
+	if resp == nil {
+		return nil
+	}
+	reason := strings.ToLower(strings.TrimSpace(resp.FinishReason))
+	if !refusalFinishReasons[reason] && strings.TrimSpace(resp.Refusal) == "" {
+		return nil
+	}
+	msg := fmt.Sprintf("response refused by provider content policy (finish_reason=%s)", resp.FinishReason)
+	if refusal := strings.TrimSpace(resp.Refusal); refusal != "" {
+		msg += ": " + utils.Truncate(refusal, 200)
+	}
+	return &ProviderError{Kind: ProviderErrorRefused, Err: errors.New(msg)}
+}
+
+// newStatusProviderError classifies an SDK error by its HTTP status, falling
+// back to ProviderErrorKindOf when no status is known.
+func newStatusProviderError(err error, statusCode int) error {
+	kind := ProviderErrorKindOf(err)
+	if statusCode > 0 {
+		kind = ClassifyHTTPError(statusCode, nil)
+	}
+	return &ProviderError{Kind: kind, StatusCode: statusCode, Err: err}
+}
+
+// ProviderErrorKindOf returns the classification of err. Timeouts and network
+// failures without an explicit ProviderError count as transient.
+func ProviderErrorKindOf(err error) ProviderErrorKind {
+	if err == nil {
+		return ProviderErrorUnknown
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ProviderErrorTransient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ProviderErrorTransient
+	}
+	return ProviderErrorUnknown
+}
@@ -0,0 +1,80 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassifyHTTPError(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   ProviderErrorKind
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Invalid API key"}}`, ProviderErrorAuth},
+		{http.StatusForbidden, `{"error":{"message":"model access denied"}}`, ProviderErrorAuth},
+		{http.StatusUnauthorized, `{"error":{"message":"User not found."}}`, ProviderErrorTransient},
+		{http.StatusBadRequest, `{"error":{"message":"context_length_exceeded"}}`, ProviderErrorBadRequest},
+		{http.StatusNotFound, `{"error":{"message":"model not found"}}`, ProviderErrorBadRequest},
+		{http.StatusUnprocessableEntity, `{}`, ProviderErrorBadRequest},
+		{http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, ProviderErrorRateLimited},
+		{http.StatusRequestTimeout, ``, ProviderErrorTransient},
+		{http.StatusInternalServerError, ``, ProviderErrorTransient},
+		{http.StatusServiceUnavailable, `overloaded`, ProviderErrorTransient},
+		{http.StatusFound, ``, ProviderErrorUnknown},
+	}
+	for _, tc := range cases {
+		if got := ClassifyHTTPError(tc.status, []byte(tc.body)); got != tc.want {
+			t.Fatalf("ClassifyHTTPError(%d, %q) = %q, want %q", tc.status, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestProviderErrorKindOf(t *testing.T) {
+	wrapped := fmt.Errorf("LLM call failed: %w", &ProviderError{Kind: ProviderErrorAuth, Err: errors.New("401")})
+	if got := ProviderErrorKindOf(wrapped); got != ProviderErrorAuth {
+		t.Fatalf("wrapped kind = %q, want auth", got)
+	}
+	if got := ProviderErrorKindOf(context.DeadlineExceeded); got != ProviderErrorTransient {
+		t.Fatalf("deadline kind = %q, want transient", got)
+	}
+	if got := ProviderErrorKindOf(errors.New("boom")); got != ProviderErrorUnknown {
+		t.Fatalf("plain error kind = %q, want unknown", got)
+	}
+}
+
+func TestChat_ReturnsClassifiedProviderError(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   ProviderErrorKind
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Invalid API key"}}`, ProviderErrorAuth},
+		{http.StatusBadRequest, `{"error":{"message":"bad tool schema"}}`, ProviderErrorBadRequest},
+		{http.StatusTooManyRequests, `{"error":{"message":"rate limited"}}`, ProviderErrorRateLimited},
+		{http.StatusBadGateway, `upstream down`, ProviderErrorTransient},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			fmt.Fprint(w, tc.body)
+		}))
+
+		p := newTestProvider("test-key", srv.URL)
+		p.maxRetries = 1
+		_, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+		srv.Close()
+
+		var perr *ProviderError
+		if !errors.As(err, &perr) {
+			t.Fatalf("HTTP %d: expected ProviderError, got %v", tc.status, err)
+		}
+		if perr.Kind != tc.want || perr.StatusCode != tc.status {
+			t.Fatalf("HTTP %d: kind=%q status=%d, want %q", tc.status, perr.Kind, perr.StatusCode, tc.want)
+		}
+	}
+}
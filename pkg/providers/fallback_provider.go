@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -17,6 +18,9 @@ type fallbackCandidate struct {
 type fallbackProvider struct {
 	primaryModel string
 	candidates   []fallbackCandidate
+	// fallbackOnRefusal also moves on to the next model when one refuses
+	// on content-policy grounds.
+	fallbackOnRefusal bool
 }
 
 func newFallbackProvider(primaryModel string, candidates []fallbackCandidate) *fallbackProvider {
@@ -33,6 +37,7 @@ func (p *fallbackProvider) Chat(ctx context.Context, messages []Message, tools [
 
 	order := p.orderedCandidates(model)
 	attemptErrors := make([]string, 0, len(order))
+	var lastErr error
 
 	for idx, candidate := range order {
 		resp, err := candidate.provider.Chat(ctx, messages, tools, candidate.model, options)
@@ -45,11 +50,16 @@ func (p *fallbackProvider) Chat(ctx context.Context, messages []Message, tools [
 						"attempt":         idx + 1,
 					})
 			}
+			if resp != nil && resp.Model == "" {
+				resp.Model = candidate.model
+			}
 			return resp, nil
 		}
 
 		attemptErrors = append(attemptErrors, fmt.Sprintf("%s: %v", candidate.model, err))
-		if !isModelFallbackEligibleError(err) {
+		lastErr = err
+		refused := ProviderErrorKindOf(err) == ProviderErrorRefused
+		if !isModelFallbackEligibleError(err) && !(refused && p.fallbackOnRefusal) {
 			return nil, err
 		}
 
@@ -65,7 +75,10 @@ func (p *fallbackProvider) Chat(ctx context.Context, messages []Message, tools [
 		}
 	}
 
-	return nil, fmt.Errorf("all fallback models failed: %s", strings.Join(attemptErrors, " | "))
+	return nil, &ProviderError{
+		Kind: ProviderErrorKindOf(lastErr),
+		Err:  fmt.Errorf("all fallback models failed: %s", strings.Join(attemptErrors, " | ")),
+	}
 }
 
 func (p *fallbackProvider) GetDefaultModel() string {
@@ -114,6 +127,9 @@ func isModelFallbackEligibleError(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
 	msg := strings.ToLower(err.Error())
 	if isOpaqueAnthropicBadRequest(msg) {
 		return true
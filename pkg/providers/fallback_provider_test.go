@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -55,6 +56,9 @@ func TestFallbackProvider_UsesFallbackModelOnAvailabilityErrors(t *testing.T) {
 	if resp == nil || resp.Content != "from-backup" {
 		t.Fatalf("Chat() response = %#v, want backup response", resp)
 	}
+	if resp.Model != "backup-model" {
+		t.Fatalf("Chat() response model = %q, want backup-model", resp.Model)
+	}
 	if len(primary.calls) != 1 || primary.calls[0] != "primary-model" {
 		t.Fatalf("primary calls = %v, want [primary-model]", primary.calls)
 	}
@@ -129,6 +133,31 @@ func TestFallbackProvider_DoesNotFallbackOnNonAvailabilityErrors(t *testing.T) {
 	}
 }
 
+func TestFallbackProvider_RefusalFallsBackOnlyWhenEnabled(t *testing.T) {
+	refused := &ProviderError{Kind: ProviderErrorRefused, Err: errors.New("response refused by provider content policy (finish_reason=content_filter)")}
+
+	for _, enabled := range []bool{false, true} {
+		primary := &scriptedProvider{results: []scriptedResult{{err: refused}}}
+		backup := &scriptedProvider{results: []scriptedResult{{resp: &LLMResponse{Content: "from-backup"}}}}
+		p := newFallbackProvider("primary-model", []fallbackCandidate{
+			{model: "primary-model", provider: primary},
+			{model: "backup-model", provider: backup},
+		})
+		p.fallbackOnRefusal = enabled
+
+		resp, err := p.Chat(context.Background(), nil, nil, "primary-model", nil)
+		if !enabled {
+			if ProviderErrorKindOf(err) != ProviderErrorRefused || len(backup.calls) != 0 {
+				t.Fatalf("disabled: err = %v, backup calls = %v; want the refusal without fallback", err, backup.calls)
+			}
+			continue
+		}
+		if err != nil || resp.Content != "from-backup" {
+			t.Fatalf("enabled: Chat() = %+v, %v; want backup response", resp, err)
+		}
+	}
+}
+
 func TestFallbackProvider_PrioritizesRequestedModelWhenKnown(t *testing.T) {
 	primary := &scriptedProvider{results: []scriptedResult{{resp: &LLMResponse{Content: "from-primary"}}}}
 	backup := &scriptedProvider{results: []scriptedResult{{resp: &LLMResponse{Content: "from-backup"}}}}
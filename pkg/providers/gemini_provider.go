@@ -0,0 +1,440 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const defaultGeminiAPIBase = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider talks to the native Gemini generateContent API. It reuses
+// HTTPProvider's transport and retry/backoff loop and only swaps the request
+// and response formats.
+type GeminiProvider struct {
+	apiKey    string
+	apiBase   string
+	transport *HTTPProvider
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+}
+
+func NewGeminiProvider(apiKey, apiBase string) *GeminiProvider {
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		apiBase = defaultGeminiAPIBase
+	}
+	return &GeminiProvider{
+		apiKey:    apiKey,
+		apiBase:   apiBase,
+		transport: NewHTTPProvider(apiKey, apiBase),
+	}
+}
+
+func (p *GeminiProvider) GetDefaultModel() string {
+	return ""
+}
+
+// RequestTimeout returns the transport's per-request limit.
+func (p *GeminiProvider) RequestTimeout() time.Duration {
+	return p.transport.RequestTimeout()
+}
+
+func (p *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if _, ok := ctx.Deadline(); !ok && p.transport.RequestTimeout() <= 0 {
+		callCtx, cancel := context.WithTimeout(ctx, defaultHTTPTimeout)
+		defer cancel()
+		ctx = callCtx
+	}
+
+	requestBody := buildGeminiRequest(canonicalizeMessages(messages), tools, options)
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.apiBase, url.PathEscape(geminiModelName(model)))
+	send := func(ctx context.Context, jsonData []byte) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if p.apiKey != "" {
+			req.Header.Set("x-goog-api-key", p.apiKey)
+		}
+		p.transport.applyHeaders(req)
+		return p.transport.httpClient.Do(req)
+	}
+
+	return p.transport.sendWithRetry(ctx, jsonData, send, parseGeminiResponse)
+}
+
+// geminiModelName strips routing prefixes so "gemini/gemini-2.5-flash" and
+// "models/gemini-2.5-flash" both address the bare model id.
+func geminiModelName(model string) string {
+	model = strings.TrimSpace(model)
+	model = strings.TrimPrefix(model, "gemini/")
+	return strings.TrimPrefix(model, "models/")
+}
+
+func buildGeminiRequest(messages []Message, tools []ToolDefinition, options map[string]interface{}) geminiRequest {
+	req := geminiRequest{Contents: []geminiContent{}}
+
+	// Gemini function responses are matched by name; remember which tool each
+	// call id referred to.
+	callNames := make(map[string]string)
+	var systemParts []geminiPart
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if text := strings.TrimSpace(msg.Content); text != "" {
+				systemParts = append(systemParts, geminiPart{Text: msg.Content})
+			}
+
+		case "assistant":
+			content := geminiContent{Role: "model"}
+			if strings.TrimSpace(msg.Content) != "" {
+				content.Parts = append(content.Parts, geminiPart{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				name := tc.Name
+				if tc.Function != nil && strings.TrimSpace(tc.Function.Name) != "" {
+					name = tc.Function.Name
+				}
+				args := tc.Arguments
+				if args == nil && tc.Function != nil && strings.TrimSpace(tc.Function.Arguments) != "" {
+					_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+				}
+				callNames[tc.ID] = name
+				content.Parts = append(content.Parts, geminiPart{
+					FunctionCall: &geminiFunctionCall{ID: geminiCallID(tc.ID), Name: name, Args: args},
+				})
+			}
+			if len(content.Parts) > 0 {
+				req.Contents = append(req.Contents, content)
+			}
+
+		case "tool":
+			name := callNames[msg.ToolCallID]
+			if name == "" {
+				name = "tool"
+			}
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{
+				ID:       geminiCallID(msg.ToolCallID),
+				Name:     name,
+				Response: map[string]interface{}{"content": msg.Content},
+			}}
+			// All responses to one model turn go into a single user turn.
+			if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == "user" && geminiIsFunctionResponseTurn(req.Contents[n-1]) {
+				req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, part)
+			} else {
+				req.Contents = append(req.Contents, geminiContent{Role: "user", Parts: []geminiPart{part}})
+			}
+			if len(msg.Parts) > 0 {
+				req.Contents = append(req.Contents, geminiUserContent("[Tool-provided images attached]", msg.Parts))
+			}
+
+		default:
+			content := geminiUserContent(msg.Content, msg.Parts)
+			if len(content.Parts) > 0 {
+				req.Contents = append(req.Contents, content)
+			}
+		}
+	}
+
+	if len(systemParts) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: systemParts}
+	}
+
+	if len(tools) > 0 {
+		decls := make([]geminiFunctionDeclaration, 0, len(tools))
+		for _, tool := range tools {
+			decls = append(decls, geminiFunctionDeclaration{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  geminiSchema(tool.Function.Parameters),
+			})
+		}
+		req.Tools = []geminiTool{{FunctionDeclarations: decls}}
+	}
+
+	genConfig := map[string]interface{}{}
+	if maxTokens, ok := options["max_tokens"].(int); ok {
+		genConfig["maxOutputTokens"] = maxTokens
+	}
+	if temperature, ok := options["temperature"].(float64); ok {
+		genConfig["temperature"] = temperature
+	}
+	if seed, ok := options["seed"].(int); ok {
+		genConfig["seed"] = seed
+	}
+	if len(genConfig) > 0 {
+		req.GenerationConfig = genConfig
+	}
+
+	return req
+}
+
+// geminiCallID drops the synthetic ids we generate for id-less Gemini calls so
+// they are not echoed back as if the API had issued them.
+func geminiCallID(id string) string {
+	if strings.HasPrefix(id, geminiSyntheticCallIDPrefix) {
+		return ""
+	}
+	return id
+}
+
+func geminiIsFunctionResponseTurn(content geminiContent) bool {
+	for _, part := range content.Parts {
+		if part.FunctionResponse == nil {
+			return false
+		}
+	}
+	return len(content.Parts) > 0
+}
+
+func geminiUserContent(text string, parts []MessagePart) geminiContent {
+	content := geminiContent{Role: "user"}
+	if strings.TrimSpace(text) != "" {
+		content.Parts = append(content.Parts, geminiPart{Text: text})
+	}
+	for _, part := range parts {
+		imageData, err := inlineImageDataFromPart(part)
+		if err != nil {
+			logger.WarnCF("provider", "Skipping inline image part for Gemini request", map[string]interface{}{
+				"path":  describeImagePart(part),
+				"error": err.Error(),
+			})
+			continue
+		}
+		if imageData.Base64Data == "" {
+			logger.WarnCF("provider", "Skipping remote image URL for Gemini request; only inline data is supported", map[string]interface{}{
+				"url": imageData.URL,
+			})
+			continue
+		}
+		content.Parts = append(content.Parts, geminiPart{
+			InlineData: &geminiInlineData{MimeType: imageData.MediaType, Data: imageData.Base64Data},
+		})
+	}
+	return content
+}
+
+// geminiSchemaKeys is the subset of JSON Schema accepted by Gemini function
+// declarations; other keywords (e.g. maxLength, additionalProperties) are
+// rejected by the API.
+var geminiSchemaKeys = map[string]bool{
+	"type":        true,
+	"format":      true,
+	"description": true,
+	"nullable":    true,
+	"enum":        true,
+	"properties":  true,
+	"required":    true,
+	"items":       true,
+	"minItems":    true,
+	"maxItems":    true,
+	"minimum":     true,
+	"maximum":     true,
+}
+
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		if !geminiSchemaKeys[key] {
+			continue
+		}
+		switch key {
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			cleaned := make(map[string]interface{}, len(props))
+			for name, prop := range props {
+				if propSchema, ok := prop.(map[string]interface{}); ok {
+					cleaned[name] = geminiSchema(propSchema)
+				}
+			}
+			out[key] = cleaned
+		case "items":
+			if itemSchema, ok := value.(map[string]interface{}); ok {
+				out[key] = geminiSchema(itemSchema)
+			}
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+const geminiSyntheticCallIDPrefix = "gemini_call_"
+
+func parseGeminiResponse(body []byte) (*LLMResponse, error) {
+	var apiResponse struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text         string              `json:"text"`
+					Thought      bool                `json:"thought"`
+					FunctionCall *geminiFunctionCall `json:"functionCall"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback *struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata *struct {
+			PromptTokenCount        int `json:"promptTokenCount"`
+			CandidatesTokenCount    int `json:"candidatesTokenCount"`
+			TotalTokenCount         int `json:"totalTokenCount"`
+			CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		} `json:"usageMetadata"`
+	}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	var usage *UsageInfo
+	if u := apiResponse.UsageMetadata; u != nil {
+		usage = &UsageInfo{
+			Provider:           "gemini",
+			PromptTokens:       u.PromptTokenCount,
+			CompletionTokens:   u.CandidatesTokenCount,
+			TotalTokens:        u.TotalTokenCount,
+			CachedPromptTokens: u.CachedContentTokenCount,
+		}
+	}
+
+	if len(apiResponse.Candidates) == 0 {
+		fields := map[string]interface{}{"body_preview": utils.Truncate(string(body), 500)}
+		if apiResponse.PromptFeedback != nil {
+			fields["block_reason"] = apiResponse.PromptFeedback.BlockReason
+		}
+		logger.WarnCF("provider", "Gemini returned 0 candidates", fields)
+		if apiResponse.PromptFeedback != nil && apiResponse.PromptFeedback.BlockReason != "" {
+			// The prompt itself was blocked.
+			return &LLMResponse{FinishReason: "content_filter", Usage: usage}, nil
+		}
+		return &LLMResponse{FinishReason: "stop", Usage: usage}, nil
+	}
+
+	candidate := apiResponse.Candidates[0]
+	var text strings.Builder
+	toolCalls := []ToolCall{}
+	for _, part := range candidate.Content.Parts {
+		if part.FunctionCall != nil {
+			args := part.FunctionCall.Args
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			rawArgs, _ := json.Marshal(args)
+			id := part.FunctionCall.ID
+			if id == "" {
+				// Unique across turns: the history maps tool results
+				// back to their calls by id.
+				id = geminiSyntheticCallIDPrefix + uuid.NewString()
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				ID:          id,
+				Type:        "function",
+				Description: normalizeToolCallDescription(toolCallDescriptionFromArgs(args)),
+				Function: &FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(rawArgs),
+				},
+				Name:      part.FunctionCall.Name,
+				Arguments: args,
+			})
+			continue
+		}
+		if part.Thought {
+			continue
+		}
+		text.WriteString(part.Text)
+	}
+
+	finishReason := geminiFinishReason(candidate.FinishReason)
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	return &LLMResponse{
+		Content:      text.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Usage:        usage,
+	}, nil
+}
+
+func geminiFinishReason(reason string) string {
+	switch strings.ToUpper(strings.TrimSpace(reason)) {
+	case "", "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	default:
+		return strings.ToLower(reason)
+	}
+}
@@ -0,0 +1,239 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseGeminiResponse_Contract_Text(t *testing.T) {
+	resp, err := parseGeminiResponse(readFixture(t, "gemini_response_text.json"))
+	if err != nil {
+		t.Fatalf("parseGeminiResponse error: %v", err)
+	}
+	if resp.Content != "Hello! How can I help you today?" {
+		t.Fatalf("Content = %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 0 {
+		t.Fatalf("expected no tool calls, got %d", len(resp.ToolCalls))
+	}
+	if resp.FinishReason != "stop" {
+		t.Fatalf("FinishReason = %q, want stop", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 9 || resp.Usage.TotalTokens != 21 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestParseGeminiResponse_BlockedPromptIsRefusal(t *testing.T) {
+	resp, err := parseGeminiResponse([]byte(`{"promptFeedback": {"blockReason": "SAFETY"}}`))
+	if err != nil {
+		t.Fatalf("parseGeminiResponse error: %v", err)
+	}
+	if refusalError(resp) == nil {
+		t.Fatalf("blocked prompt should be a refusal, got %+v", resp)
+	}
+}
+
+func TestParseGeminiResponse_Contract_FunctionCalls(t *testing.T) {
+	resp, err := parseGeminiResponse(readFixture(t, "gemini_response_functioncall.json"))
+	if err != nil {
+		t.Fatalf("parseGeminiResponse error: %v", err)
+	}
+	if resp.Content != "Let me check the directory." {
+		t.Fatalf("Content = %q", resp.Content)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Fatalf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(resp.ToolCalls))
+	}
+
+	tc := resp.ToolCalls[0]
+	if tc.ID == "" || tc.ID == resp.ToolCalls[1].ID {
+		t.Fatalf("expected unique non-empty ids, got %q and %q", tc.ID, resp.ToolCalls[1].ID)
+	}
+	if tc.Type != "function" || tc.Name != "exec" || tc.Function == nil || tc.Function.Name != "exec" {
+		t.Fatalf("unexpected tool call: %+v", tc)
+	}
+	if got, ok := tc.Arguments["command"].(string); !ok || got != "ls -la" {
+		t.Fatalf("unexpected parsed args: %+v", tc.Arguments)
+	}
+	if tc.Function.Arguments != `{"command":"ls -la"}` {
+		t.Fatalf("Function.Arguments = %q", tc.Function.Arguments)
+	}
+	if resp.ToolCalls[1].Name != "read_file" {
+		t.Fatalf("second tool = %q, want read_file", resp.ToolCalls[1].Name)
+	}
+}
+
+func TestParseGeminiResponse_SyntheticIDsDifferAcrossTurns(t *testing.T) {
+	first, err := parseGeminiResponse(readFixture(t, "gemini_response_functioncall.json"))
+	if err != nil {
+		t.Fatalf("parseGeminiResponse error: %v", err)
+	}
+	second, err := parseGeminiResponse(readFixture(t, "gemini_response_functioncall.json"))
+	if err != nil {
+		t.Fatalf("parseGeminiResponse error: %v", err)
+	}
+	if first.ToolCalls[0].ID == second.ToolCalls[0].ID {
+		t.Fatalf("synthetic id %q repeated across turns", first.ToolCalls[0].ID)
+	}
+}
+
+func TestBuildGeminiRequest_MapsRolesToolsAndSystem(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "gemini_call_1", Name: "exec", Arguments: map[string]interface{}{"command": "ls"}},
+			{ID: "call_b", Name: "read_file", Arguments: map[string]interface{}{"path": "a.txt"}},
+		}},
+		{Role: "tool", ToolCallID: "gemini_call_1", Content: "a.txt"},
+		{Role: "tool", ToolCallID: "call_b", Content: "hello"},
+		{Role: "assistant", Content: "Found a.txt."},
+	}
+	tools := []ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        "exec",
+			Description: "Run a command",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"command":     map[string]interface{}{"type": "string"},
+					"description": map[string]interface{}{"type": "string", "maxLength": 80},
+				},
+				"required": []string{"command"},
+			},
+		},
+	}}
+
+	req := buildGeminiRequest(messages, tools, map[string]interface{}{"max_tokens": 512, "temperature": 0.2})
+
+	if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "You are helpful." {
+		t.Fatalf("unexpected systemInstruction: %+v", req.SystemInstruction)
+	}
+	if len(req.Contents) != 4 {
+		t.Fatalf("expected 4 contents, got %d: %+v", len(req.Contents), req.Contents)
+	}
+	if req.Contents[0].Role != "user" || req.Contents[1].Role != "model" || req.Contents[2].Role != "user" || req.Contents[3].Role != "model" {
+		t.Fatalf("unexpected roles: %+v", req.Contents)
+	}
+
+	calls := req.Contents[1].Parts
+	if len(calls) != 2 || calls[0].FunctionCall == nil || calls[0].FunctionCall.Name != "exec" {
+		t.Fatalf("unexpected function calls: %+v", calls)
+	}
+	if calls[0].FunctionCall.ID != "" || calls[1].FunctionCall.ID != "call_b" {
+		t.Fatalf("expected synthetic id dropped and real id kept, got %q and %q", calls[0].FunctionCall.ID, calls[1].FunctionCall.ID)
+	}
+
+	responses := req.Contents[2].Parts
+	if len(responses) != 2 {
+		t.Fatalf("expected both tool results in one turn, got %d parts", len(responses))
+	}
+	if responses[0].FunctionResponse.Name != "exec" || responses[1].FunctionResponse.Name != "read_file" {
+		t.Fatalf("function responses not matched to call names: %+v", responses)
+	}
+	if responses[0].FunctionResponse.Response["content"] != "a.txt" {
+		t.Fatalf("unexpected function response: %+v", responses[0].FunctionResponse.Response)
+	}
+
+	decl := req.Tools[0].FunctionDeclarations[0]
+	if _, ok := decl.Parameters["additionalProperties"]; ok {
+		t.Fatalf("unsupported schema keyword not stripped: %+v", decl.Parameters)
+	}
+	descProp := decl.Parameters["properties"].(map[string]interface{})["description"].(map[string]interface{})
+	if _, ok := descProp["maxLength"]; ok {
+		t.Fatalf("nested unsupported schema keyword not stripped: %+v", descProp)
+	}
+	if req.GenerationConfig["maxOutputTokens"] != 512 || req.GenerationConfig["temperature"] != 0.2 {
+		t.Fatalf("unexpected generationConfig: %+v", req.GenerationConfig)
+	}
+}
+
+func TestGeminiProviderChat_UsesNativeEndpointAndRetries(t *testing.T) {
+	fixture := readFixture(t, "gemini_response_functioncall.json")
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.0-flash:generateContent" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if key := r.Header.Get("x-goog-api-key"); key != "gem-key" {
+			t.Errorf("x-goog-api-key = %q", key)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("request is not JSON: %v", err)
+		}
+		if _, ok := req["contents"]; !ok {
+			t.Errorf("request missing contents: %s", body)
+		}
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"message":"overloaded"}}`))
+			return
+		}
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider("gem-key", server.URL)
+	p.transport.retryBaseWait = time.Millisecond
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini/gemini-2.0-flash", nil)
+	if err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 1 retry, got %d calls", calls.Load())
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(resp.ToolCalls))
+	}
+}
+
+func TestCreateProvider_UsesNativeGeminiProvider(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "gemini-2.0-flash"
+	cfg.Providers.Gemini.APIKey = "gem-key"
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	gp, ok := p.(*GeminiProvider)
+	if !ok {
+		t.Fatalf("expected GeminiProvider, got %T", p)
+	}
+	if gp.apiBase != defaultGeminiAPIBase {
+		t.Fatalf("apiBase = %q, want default", gp.apiBase)
+	}
+}
+
+func TestCreateProvider_GeminiOpenAICompatibleBaseUsesHTTPProvider(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "gemini-2.0-flash"
+	cfg.Providers.Gemini.APIKey = "gem-key"
+	cfg.Providers.Gemini.APIBase = "https://generativelanguage.googleapis.com/v1beta/openai/"
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if _, ok := p.(*HTTPProvider); !ok {
+		t.Fatalf("expected HTTPProvider for OpenAI-compatible base, got %T", p)
+	}
+}
@@ -10,11 +10,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"math/rand"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +34,12 @@ const (
 	defaultRetryMaxWait  = 60 * time.Second // cap on backoff duration
 	defaultRetryJitter   = 0.2              // +/-20% jitter for non-Retry-After waits
 	defaultHTTPTimeout   = 2 * time.Minute  // safety net when caller didn't set a deadline
+
+	// defaultRetryAfterJitter is the most added on top of a Retry-After hint, so
+	// clients throttled together don't all retry in the same instant.
+	defaultRetryAfterJitter = 250 * time.Millisecond
+	// minRetryAfterWait is used for "Retry-After: 0" (retry immediately).
+	minRetryAfterWait = 50 * time.Millisecond
 )
 
 type HTTPProvider struct {
@@ -44,6 +52,23 @@ type HTTPProvider struct {
 	retryJitter   float64
 	randFloat     func() float64
 	routing       map[string]interface{}
+	headers       map[string]string
+	retryStatus   map[int]bool // retried in addition to isRetryableHTTPError
+
+	// retryContent matches stub replies that are retried like empty ones.
+	retryContent []*regexp.Regexp
+
+	// retryAfterJitter is the upper bound of extra wait added to Retry-After
+	// hints. It only ever lengthens the wait the server asked for.
+	retryAfterJitter time.Duration
+	breaker          *circuitBreaker // nil = disabled
+
+	// requestTimeout bounds each HTTP attempt on its own; retry waits are
+	// not counted against it. 0 = no per-attempt limit.
+	requestTimeout time.Duration
+
+	// recorder, when set, saves every call's request and outcome.
+	recorder *Recorder
 }
 
 type chatCompletionMessage struct {
@@ -54,9 +79,18 @@ type chatCompletionMessage struct {
 }
 
 type chatCompletionContentPart struct {
-	Type     string                  `json:"type"`
-	Text     string                  `json:"text,omitempty"`
-	ImageURL *chatCompletionImageURL `json:"image_url,omitempty"`
+	Type         string                  `json:"type"`
+	Text         string                  `json:"text,omitempty"`
+	ImageURL     *chatCompletionImageURL `json:"image_url,omitempty"`
+	CacheControl map[string]string       `json:"cache_control,omitempty"`
+}
+
+// chatCompletionTool is the wire form of a tool definition. It only differs
+// from ToolDefinition by the optional Anthropic cache breakpoint.
+type chatCompletionTool struct {
+	Type         string                 `json:"type"`
+	Function     ToolFunctionDefinition `json:"function"`
+	CacheControl map[string]string      `json:"cache_control,omitempty"`
 }
 
 type chatCompletionImageURL struct {
@@ -83,6 +117,8 @@ func NewHTTPProvider(apiKey, apiBase string) *HTTPProvider {
 		retryMaxWait:  defaultRetryMaxWait,
 		retryJitter:   defaultRetryJitter,
 		randFloat:     rand.Float64,
+
+		retryAfterJitter: defaultRetryAfterJitter,
 		// NOTE: We rely on request contexts (ChatWithTimeout) for per-call deadlines.
 		// http.Client.Timeout is a hard cap and can conflict with longer contexts.
 		httpClient: &http.Client{},
@@ -95,6 +131,160 @@ func (p *HTTPProvider) SetRouting(routing map[string]interface{}) {
 	p.routing = routing
 }
 
+// SetHeaders adds headers sent on every request. They are applied after the
+// defaults, so they can also replace Authorization for custom gateways.
+func (p *HTTPProvider) SetHeaders(headers map[string]string) {
+	p.headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		if strings.TrimSpace(k) == "" {
+			continue
+		}
+		p.headers[k] = v
+	}
+	if len(p.headers) > 0 {
+		logger.DebugCF("provider", "Custom request headers configured", map[string]interface{}{
+			"api_base": p.apiBase,
+			"headers":  redactHeaders(p.headers),
+		})
+	}
+}
+
+// SetRetryPolicy overrides the retry defaults. A negative maxRetries and
+// non-positive waits keep the current values; extraStatus codes are retried
+// in addition to 429 and 5xx.
+func (p *HTTPProvider) SetRetryPolicy(maxRetries int, baseWait, maxWait time.Duration, extraStatus []int) {
+	if maxRetries >= 0 {
+		p.maxRetries = maxRetries
+	}
+	if baseWait > 0 {
+		p.retryBaseWait = baseWait
+	}
+	if maxWait > 0 {
+		p.retryMaxWait = maxWait
+	}
+	p.retryStatus = make(map[int]bool, len(extraStatus))
+	for _, code := range extraStatus {
+		p.retryStatus[code] = true
+	}
+}
+
+// SetRetryContentPatterns makes replies without tool calls whose trimmed
+// content matches any of patterns retryable, for providers that answer a
+// transient failure with a canned stub. On error the patterns are left
+// unchanged.
+func (p *HTTPProvider) SetRetryContentPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid retry content pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	p.retryContent = compiled
+	return nil
+}
+
+// SetRetryAfterJitter sets the most extra wait added to Retry-After hints.
+// Zero disables it; a negative value keeps the current setting.
+func (p *HTTPProvider) SetRetryAfterJitter(jitter time.Duration) {
+	if jitter >= 0 {
+		p.retryAfterJitter = jitter
+	}
+}
+
+// SetCircuitBreaker makes the provider fail fast for cooldown after threshold
+// consecutive failed requests within window. threshold <= 0 disables it.
+func (p *HTTPProvider) SetCircuitBreaker(threshold int, window, cooldown time.Duration) {
+	p.breaker = newCircuitBreaker(p.apiBase, threshold, window, cooldown)
+}
+
+// SetRequestTimeout limits how long a single request may take before it is
+// abandoned and retried. timeout <= 0 disables the limit.
+func (p *HTTPProvider) SetRequestTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	p.requestTimeout = timeout
+}
+
+// RequestTimeout returns the per-request limit set by SetRequestTimeout.
+func (p *HTTPProvider) RequestTimeout() time.Duration {
+	return p.requestTimeout
+}
+
+// SetRecorder saves the request and final response or error of every call
+// through r. nil disables recording.
+func (p *HTTPProvider) SetRecorder(r *Recorder) {
+	p.recorder = r
+}
+
+func (p *HTTPProvider) isRetryableStatus(statusCode int, body []byte) bool {
+	return isRetryableHTTPError(statusCode, body) || p.retryStatus[statusCode]
+}
+
+func (p *HTTPProvider) applyHeaders(req *http.Request) {
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+}
+
+var sensitiveHeaderPattern = regexp.MustCompile(`(?i)auth|key|token|secret|cookie|signature|password`)
+
+// redactHeaders masks values of credential-like headers for logging.
+func redactHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if sensitiveHeaderPattern.MatchString(k) {
+			v = "[REDACTED]"
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// applyProviderRetries applies a provider's retry and circuit breaker
+// settings from config.
+func applyProviderRetries(p *HTTPProvider, pc config.ProviderConfig) {
+	maxRetries := -1
+	if pc.MaxRetries != nil {
+		maxRetries = *pc.MaxRetries
+	}
+	p.SetRetryPolicy(maxRetries,
+		time.Duration(pc.RetryBaseWaitMS)*time.Millisecond,
+		time.Duration(pc.RetryMaxWaitMS)*time.Millisecond,
+		pc.RetryStatusCodes)
+	if err := p.SetRetryContentPatterns(pc.RetryContentPatterns); err != nil {
+		logger.WarnCF("provider", "Ignoring retry content patterns", map[string]interface{}{
+			"api_base": p.apiBase,
+			"error":    err.Error(),
+		})
+	}
+	if pc.RetryAfterJitterMS != nil {
+		p.SetRetryAfterJitter(time.Duration(*pc.RetryAfterJitterMS) * time.Millisecond)
+	}
+	p.SetRequestTimeout(time.Duration(pc.RequestTimeoutSeconds) * time.Second)
+	p.SetCircuitBreaker(pc.CircuitBreakerThreshold,
+		time.Duration(pc.CircuitBreakerWindowSeconds)*time.Second,
+		time.Duration(pc.CircuitBreakerCooldownSeconds)*time.Second)
+}
+
+// providerHeaders merges a provider's extra_headers with the OpenRouter app
+// attribution headers (HTTP-Referer, X-Title) when configured.
+func providerHeaders(pc config.ProviderConfig) map[string]string {
+	headers := make(map[string]string, len(pc.ExtraHeaders)+2)
+	if referer := strings.TrimSpace(pc.Referer); referer != "" {
+		headers["HTTP-Referer"] = referer
+	}
+	if title := strings.TrimSpace(pc.Title); title != "" {
+		headers["X-Title"] = title
+	}
+	for k, v := range pc.ExtraHeaders {
+		headers[k] = v
+	}
+	return headers
+}
+
 func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
 	if p.apiBase == "" {
 		return nil, fmt.Errorf("API base not configured")
@@ -102,8 +292,8 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 
 	// Ensure there's always some deadline so we don't hang forever if callers pass
 	// context.Background() directly. In normal agent operation, ChatWithTimeout
-	// sets a per-call deadline already.
-	if _, ok := ctx.Deadline(); !ok {
+	// sets a per-call deadline already, and a request timeout bounds each attempt.
+	if _, ok := ctx.Deadline(); !ok && p.requestTimeout <= 0 {
 		callCtx, cancel := context.WithTimeout(ctx, defaultHTTPTimeout)
 		defer cancel()
 		ctx = callCtx
@@ -122,6 +312,27 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 		requestBody["tool_choice"] = "auto"
 	}
 
+	// Anthropic-backed endpoints (directly or via OpenRouter) accept
+	// cache_control breakpoints on content parts and tools. The system prompt
+	// and tool list are identical across iterations, so marking them lets the
+	// API cache the stable prefix. Other models ignore the cache options.
+	if isClaudeModel(model) {
+		cacheControl, err := parseAnthropicCacheControl(options)
+		if err != nil {
+			return nil, err
+		}
+		if cacheControl != nil {
+			marker := map[string]string{"type": string(cacheControl.Type)}
+			if cacheControl.TTL != "" {
+				marker["ttl"] = string(cacheControl.TTL)
+			}
+			requestBody["messages"] = applySystemCacheBreakpoint(wireMessages, marker)
+			if len(tools) > 0 {
+				requestBody["tools"] = applyToolsCacheBreakpoint(tools, marker)
+			}
+		}
+	}
+
 	if maxTokens, ok := options["max_tokens"].(int); ok {
 		lowerModel := strings.ToLower(model)
 		if strings.Contains(lowerModel, "glm") || strings.Contains(lowerModel, "o1") {
@@ -135,6 +346,10 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 		requestBody["temperature"] = temperature
 	}
 
+	if seed, ok := options["seed"].(int); ok {
+		requestBody["seed"] = seed
+	}
+
 	if len(p.routing) > 0 {
 		requestBody["provider"] = p.routing
 	}
@@ -144,6 +359,42 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	return p.sendWithRetry(ctx, jsonData, p.doRequest, p.parseResponse)
+}
+
+// sendWithRetry posts jsonData via send and parses the body with parse,
+// retrying transport failures, retryable HTTP statuses and empty responses
+// with backoff. Native-format providers (e.g. Gemini) reuse it with their own
+// send/parse functions. While the circuit breaker is open it fails fast.
+func (p *HTTPProvider) sendWithRetry(
+	ctx context.Context,
+	jsonData []byte,
+	send func(ctx context.Context, jsonData []byte) (*http.Response, error),
+	parse func(body []byte) (*LLMResponse, error),
+) (*LLMResponse, error) {
+	if err := p.breaker.allow(); err != nil {
+		return nil, err
+	}
+	var parsedBody []byte
+	if p.recorder != nil {
+		parseBody := parse
+		parse = func(body []byte) (*LLMResponse, error) {
+			parsedBody = body
+			return parseBody(body)
+		}
+	}
+	resp, err := p.sendAttempts(ctx, jsonData, send, parse)
+	p.breaker.record(ctx, err)
+	p.recorder.record(p.apiBase, jsonData, parsedBody, err)
+	return resp, err
+}
+
+func (p *HTTPProvider) sendAttempts(
+	ctx context.Context,
+	jsonData []byte,
+	send func(ctx context.Context, jsonData []byte) (*http.Response, error),
+	parse func(body []byte) (*LLMResponse, error),
+) (*LLMResponse, error) {
 	var lastErr error
 	var retryAfterHint time.Duration
 	var hasRetryAfterHint bool
@@ -156,6 +407,17 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 			wait := p.computeRetryWait(attempt, retryAfterHint, hasRetryAfterHint)
 			hasRetryAfterHint = false
 
+			if budget := RetryBudgetFromContext(ctx); !budget.Allow(wait) {
+				used, waited := budget.Used()
+				logger.WarnCF("provider", "Retry budget exhausted, not retrying LLM request",
+					map[string]interface{}{
+						"retries_used": used,
+						"waited":       waited.String(),
+						"last_error":   fmt.Sprintf("%v", lastErr),
+					})
+				return nil, fmt.Errorf("LLM request failed (retry budget exhausted after %d attempts): %w", attempt, lastErr)
+			}
+
 			logger.WarnCF("provider", fmt.Sprintf("Retrying LLM request (attempt %d/%d)", attempt+1, p.maxRetries+1),
 				map[string]interface{}{
 					"wait":        wait.String(),
@@ -170,20 +432,34 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 			}
 		}
 
-		resp, err := p.doRequest(ctx, jsonData)
+		attemptCtx, cancelAttempt := ctx, context.CancelFunc(func() {})
+		if p.requestTimeout > 0 {
+			attemptCtx, cancelAttempt = context.WithTimeout(ctx, p.requestTimeout)
+		}
+		resp, err := send(attemptCtx, jsonData)
 		if err != nil {
-			lastErr = err
-			hasRetryAfterHint = false
+			timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+			cancelAttempt()
 			// Context cancellation is not retryable
 			if ctx.Err() != nil {
 				return nil, fmt.Errorf("failed to send request: %w", err)
 			}
+			if timedOut {
+				err = fmt.Errorf("request timed out after %s: %w", p.requestTimeout, err)
+			}
+			lastErr = &ProviderError{Kind: ProviderErrorTransient, Err: err}
+			hasRetryAfterHint = false
 			continue
 		}
 
 		retryAfter, hasRetryAfter := parseRetryAfterHeader(resp.Header.Get("Retry-After"))
 		statusCode, body, err := p.readResponse(resp)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancelAttempt()
 		if err != nil {
+			if timedOut {
+				err = &ProviderError{Kind: ProviderErrorTransient, Err: fmt.Errorf("request timed out after %s: %w", p.requestTimeout, err)}
+			}
 			lastErr = err
 			hasRetryAfterHint = false
 			continue
@@ -191,8 +467,12 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 
 		// Non-OK status: retry on retryable HTTP errors, fail immediately otherwise.
 		if statusCode != http.StatusOK {
-			lastErr = fmt.Errorf("API error (HTTP %d): %s", statusCode, utils.Truncate(string(body), 500))
-			if isRetryableHTTPError(statusCode, body) {
+			lastErr = &ProviderError{
+				Kind:       ClassifyHTTPError(statusCode, body),
+				StatusCode: statusCode,
+				Err:        fmt.Errorf("API error (HTTP %d): %s", statusCode, utils.Truncate(string(body), 500)),
+			}
+			if p.isRetryableStatus(statusCode, body) {
 				retryAfterHint = retryAfter
 				hasRetryAfterHint = hasRetryAfter
 				continue // retryable
@@ -209,16 +489,22 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 				"body":       utils.Truncate(string(body), 2000),
 			})
 
-		llmResp, err := p.parseResponse(body)
+		llmResp, err := parse(body)
 		if err != nil {
 			lastErr = err
 			hasRetryAfterHint = false
 			continue
 		}
 
+		// A content-policy refusal is not a glitch: the same request would be
+		// refused again, so report it instead of retrying.
+		if err := refusalError(llmResp); err != nil {
+			return nil, err
+		}
+
 		// Check for empty/error responses that warrant a retry
 		if p.shouldRetry(llmResp) {
-			lastErr = fmt.Errorf("empty or error response from LLM (finish_reason=%s)", llmResp.FinishReason)
+			lastErr = fmt.Errorf("empty, error or stub response from LLM (finish_reason=%s)", llmResp.FinishReason)
 			hasRetryAfterHint = false
 			continue
 		}
@@ -251,7 +537,7 @@ func toChatCompletionMessages(messages []Message) []chatCompletionMessage {
 				imageData, err := inlineImageDataFromPart(part)
 				if err != nil {
 					logger.WarnCF("provider", "Skipping inline image part for OpenAI-compatible tool output", map[string]interface{}{
-						"path":  strings.TrimSpace(part.Path),
+						"path":  describeImagePart(part),
 						"error": err.Error(),
 					})
 					continue
@@ -259,7 +545,7 @@ func toChatCompletionMessages(messages []Message) []chatCompletionMessage {
 				contentParts = append(contentParts, chatCompletionContentPart{
 					Type: "image_url",
 					ImageURL: &chatCompletionImageURL{
-						URL: imageData.DataURL,
+						URL: imageData.transportURL(),
 					},
 				})
 			}
@@ -280,7 +566,7 @@ func toChatCompletionMessages(messages []Message) []chatCompletionMessage {
 				imageData, err := inlineImageDataFromPart(part)
 				if err != nil {
 					logger.WarnCF("provider", "Skipping inline image part for OpenAI-compatible request", map[string]interface{}{
-						"path":  strings.TrimSpace(part.Path),
+						"path":  describeImagePart(part),
 						"error": err.Error(),
 					})
 					continue
@@ -289,7 +575,7 @@ func toChatCompletionMessages(messages []Message) []chatCompletionMessage {
 				contentParts = append(contentParts, chatCompletionContentPart{
 					Type: "image_url",
 					ImageURL: &chatCompletionImageURL{
-						URL: imageData.DataURL,
+						URL: imageData.transportURL(),
 					},
 				})
 			}
@@ -336,17 +622,58 @@ func toChatCompletionMessages(messages []Message) []chatCompletionMessage {
 	return out
 }
 
+func isClaudeModel(model string) bool {
+	return strings.Contains(strings.ToLower(model), "claude")
+}
+
+// applySystemCacheBreakpoint rewrites the last system message into a single
+// text part carrying the cache_control marker.
+func applySystemCacheBreakpoint(messages []chatCompletionMessage, marker map[string]string) []chatCompletionMessage {
+	last := -1
+	for i, msg := range messages {
+		if msg.Role != "system" {
+			continue
+		}
+		if text, ok := msg.Content.(string); ok && strings.TrimSpace(text) != "" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return messages
+	}
+
+	out := append([]chatCompletionMessage(nil), messages...)
+	out[last].Content = []chatCompletionContentPart{{
+		Type:         "text",
+		Text:         out[last].Content.(string),
+		CacheControl: marker,
+	}}
+	return out
+}
+
+// applyToolsCacheBreakpoint marks the last tool definition, which caches the
+// whole tool block up to and including it.
+func applyToolsCacheBreakpoint(tools []ToolDefinition, marker map[string]string) []chatCompletionTool {
+	out := make([]chatCompletionTool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, chatCompletionTool{Type: tool.Type, Function: tool.Function})
+	}
+	out[len(out)-1].CacheControl = marker
+	return out
+}
+
 func (p *HTTPProvider) computeRetryWait(attempt int, retryAfterHint time.Duration, hasRetryAfterHint bool) time.Duration {
 	wait := p.retryBaseWait * time.Duration(1<<(attempt-1)) // exponential: 1s, 2s, 4s, 8s, 16s
 	if wait > p.retryMaxWait {
 		wait = p.retryMaxWait
 	}
 
+	rf := p.randFloat
+	if rf == nil {
+		rf = rand.Float64
+	}
+
 	if !hasRetryAfterHint && p.retryJitter > 0 {
-		rf := p.randFloat
-		if rf == nil {
-			rf = rand.Float64
-		}
 		factor := 1 + (rf()*2-1)*p.retryJitter
 		if factor < 0 {
 			factor = 0
@@ -371,6 +698,14 @@ func (p *HTTPProvider) computeRetryWait(attempt int, retryAfterHint time.Duratio
 		if retryAfter > wait {
 			wait = retryAfter
 		}
+		// Jitter is only ever added, never subtracted, so the server's hint
+		// is still honoured.
+		if p.retryAfterJitter > 0 {
+			wait += time.Duration(rf() * float64(p.retryAfterJitter))
+			if wait > p.retryMaxWait {
+				wait = p.retryMaxWait
+			}
+		}
 	}
 
 	return wait
@@ -403,25 +738,41 @@ func isRetryableHTTPError(statusCode int, body []byte) bool {
 	return false
 }
 
+// retryAfterSecondsPattern matches delta-seconds, optionally fractional. It
+// rules out forms ParseFloat would also accept, such as "1e3" or "Inf".
+var retryAfterSecondsPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
 func parseRetryAfterHeader(header string) (time.Duration, bool) {
 	header = strings.TrimSpace(header)
 	if header == "" {
 		return 0, false
 	}
 
-	// Delta-seconds form.
-	if secs, err := strconv.Atoi(header); err == nil {
+	// Delta-seconds form. Some gateways send fractional seconds ("1.5").
+	// Zero means "retry now"; a tiny wait still avoids a hot retry loop.
+	if retryAfterSecondsPattern.MatchString(header) {
+		secs, err := strconv.ParseFloat(header, 64)
+		if err != nil {
+			return 0, false
+		}
 		if secs <= 0 {
-			return 0, true
+			return minRetryAfterWait, true
+		}
+		if secs >= float64(math.MaxInt64/int64(time.Second)) {
+			return time.Duration(math.MaxInt64), true
+		}
+		d := time.Duration(secs * float64(time.Second))
+		if d < minRetryAfterWait {
+			d = minRetryAfterWait
 		}
-		return time.Duration(secs) * time.Second, true
+		return d, true
 	}
 
 	// HTTP-date form.
 	if t, err := http.ParseTime(header); err == nil {
 		d := time.Until(t)
-		if d < 0 {
-			d = 0
+		if d < minRetryAfterWait {
+			d = minRetryAfterWait
 		}
 		return d, true
 	}
@@ -440,6 +791,7 @@ func (p *HTTPProvider) doRequest(ctx context.Context, jsonData []byte) (*http.Re
 	if p.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
+	p.applyHeaders(req)
 
 	return p.httpClient.Do(req)
 }
@@ -469,6 +821,16 @@ func (p *HTTPProvider) shouldRetry(resp *LLMResponse) bool {
 	if resp.Content == "" && len(resp.ToolCalls) == 0 {
 		return true
 	}
+
+	// Stub replies an operator marked as known glitches.
+	if len(resp.ToolCalls) == 0 {
+		content := strings.TrimSpace(resp.Content)
+		for _, re := range p.retryContent {
+			if re.MatchString(content) {
+				return true
+			}
+		}
+	}
 	return false
 }
 
@@ -476,13 +838,19 @@ func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
 	var apiResponse struct {
 		Choices []struct {
 			Message struct {
-				Content   string `json:"content"`
-				ToolCalls []struct {
+				Content string `json:"content"`
+				// OpenRouter uses "reasoning"; DeepSeek and vLLM use "reasoning_content".
+				Reasoning        string `json:"reasoning"`
+				ReasoningContent string `json:"reasoning_content"`
+				Refusal          string `json:"refusal"`
+				ToolCalls        []struct {
 					ID       string `json:"id"`
 					Type     string `json:"type"`
 					Function *struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
+						Name string `json:"name"`
+						// Usually a JSON-encoded string, but some providers
+						// and proxies send the arguments object itself.
+						Arguments json.RawMessage `json:"arguments"`
 					} `json:"function"`
 				} `json:"tool_calls"`
 			} `json:"message"`
@@ -510,7 +878,17 @@ func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
 
 	choice := apiResponse.Choices[0]
 
-	if choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+	content, inlineReasoning := splitReasoning(choice.Message.Content)
+	reasoning := joinReasoning(choice.Message.Reasoning, choice.Message.ReasoningContent, inlineReasoning)
+	if reasoning != "" {
+		logger.DebugCF("provider", "LLM reasoning",
+			map[string]interface{}{
+				"reasoning_chars": len(reasoning),
+				"preview":         utils.Truncate(reasoning, 500),
+			})
+	}
+
+	if content == "" && len(choice.Message.ToolCalls) == 0 && choice.Message.Refusal == "" {
 		logger.WarnCF("provider", "LLM returned empty content with no tool calls",
 			map[string]interface{}{
 				"finish_reason": choice.FinishReason,
@@ -522,29 +900,15 @@ func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
 	for _, tc := range choice.Message.ToolCalls {
 		arguments := make(map[string]interface{})
 		name := ""
+		rawArgs := ""
 
-		// Handle OpenAI format with nested function object
-		if tc.Type == "function" && tc.Function != nil {
-			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					arguments["raw"] = tc.Function.Arguments
-				}
-			}
-		} else if tc.Function != nil {
-			// Legacy format without type field
+		// OpenAI format has type "function"; the legacy format omits it.
+		// Both nest name and arguments in a function object.
+		if tc.Function != nil {
 			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					arguments["raw"] = tc.Function.Arguments
-				}
-			}
+			arguments, rawArgs = decodeToolCallArguments(tc.Function.Arguments)
 		}
 
-		rawArgs := ""
-		if tc.Function != nil {
-			rawArgs = tc.Function.Arguments
-		}
 		toolCalls = append(toolCalls, ToolCall{
 			ID:          tc.ID,
 			Type:        "function",
@@ -559,13 +923,44 @@ func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
 	}
 
 	return &LLMResponse{
-		Content:      choice.Message.Content,
+		Content:      content,
 		ToolCalls:    toolCalls,
 		FinishReason: choice.FinishReason,
 		Usage:        usageInfoFromMap(apiResponse.Usage, "openai-compatible"),
+		Reasoning:    reasoning,
+		Refusal:      choice.Message.Refusal,
 	}, nil
 }
 
+// decodeToolCallArguments parses function-call arguments sent either as a
+// JSON-encoded string (the OpenAI format) or as a JSON object. It returns the
+// parsed map and the arguments as a JSON string for replaying the call in
+// history. Arguments that do not parse end up under "raw".
+func decodeToolCallArguments(raw json.RawMessage) (map[string]interface{}, string) {
+	arguments := make(map[string]interface{})
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return arguments, ""
+	}
+
+	encoded := string(trimmed)
+	if trimmed[0] == '"' {
+		if err := json.Unmarshal(trimmed, &encoded); err != nil {
+			arguments["raw"] = string(trimmed)
+			return arguments, string(trimmed)
+		}
+		if strings.TrimSpace(encoded) == "" {
+			return arguments, encoded
+		}
+	}
+	if err := json.Unmarshal([]byte(encoded), &arguments); err != nil {
+		arguments = map[string]interface{}{"raw": encoded}
+	} else if arguments == nil { // "null" inside the string
+		arguments = make(map[string]interface{})
+	}
+	return arguments, encoded
+}
+
 func logHTTPProviderCacheUsage(body []byte) {
 	var payload struct {
 		Model string                 `json:"model"`
@@ -808,7 +1203,9 @@ func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
 
-func createClaudeAuthProvider() (LLMProvider, error) {
+// createClaudeAuthProvider builds the OAuth/token Anthropic client with pc's
+// circuit breaker settings.
+func createClaudeAuthProvider(pc config.ProviderConfig) (LLMProvider, error) {
 	cred, err := auth.GetCredential("anthropic")
 	if err != nil {
 		return nil, fmt.Errorf("loading auth credentials: %w", err)
@@ -816,10 +1213,16 @@ func createClaudeAuthProvider() (LLMProvider, error) {
 	if cred == nil {
 		return nil, fmt.Errorf("no credentials for anthropic. Run: picoclaw auth login --provider anthropic")
 	}
-	return NewClaudeProviderWithTokenSource(cred.AccessToken, createClaudeTokenSource()), nil
+	p := NewClaudeProviderWithTokenSource(cred.AccessToken, createClaudeTokenSource())
+	p.SetCircuitBreaker(pc.CircuitBreakerThreshold,
+		time.Duration(pc.CircuitBreakerWindowSeconds)*time.Second,
+		time.Duration(pc.CircuitBreakerCooldownSeconds)*time.Second)
+	return p, nil
 }
 
-func createCodexAuthProvider() (LLMProvider, error) {
+// createCodexAuthProvider builds the OAuth/token Codex client with pc's
+// circuit breaker settings.
+func createCodexAuthProvider(pc config.ProviderConfig) (LLMProvider, error) {
 	cred, err := auth.GetCredential("openai")
 	if err != nil {
 		return nil, fmt.Errorf("loading auth credentials: %w", err)
@@ -827,7 +1230,11 @@ func createCodexAuthProvider() (LLMProvider, error) {
 	if cred == nil {
 		return nil, fmt.Errorf("no credentials for openai. Run: picoclaw auth login --provider openai")
 	}
-	return NewCodexProviderWithTokenSource(cred.AccessToken, cred.AccountID, createCodexTokenSource()), nil
+	p := NewCodexProviderWithTokenSource(cred.AccessToken, cred.AccountID, createCodexTokenSource())
+	p.SetCircuitBreaker(pc.CircuitBreakerThreshold,
+		time.Duration(pc.CircuitBreakerWindowSeconds)*time.Second,
+		time.Duration(pc.CircuitBreakerCooldownSeconds)*time.Second)
+	return p, nil
 }
 
 func CreateProvider(cfg *config.Config) (LLMProvider, error) {
@@ -835,7 +1242,14 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 	if primaryModel == "" {
 		return nil, fmt.Errorf("agents.defaults.model must not be empty")
 	}
+	return CreateProviderWithFallbacks(cfg, primaryModel)
+}
 
+// CreateProviderWithFallbacks builds the provider serving primaryModel,
+// falling back to agents.defaults.fallback_models like the default provider.
+// It fails when no configured provider matches primaryModel.
+func CreateProviderWithFallbacks(cfg *config.Config, primaryModel string) (LLMProvider, error) {
+	primaryModel = strings.TrimSpace(primaryModel)
 	primaryProvider, err := createProviderForModel(cfg, primaryModel)
 	if err != nil {
 		return nil, err
@@ -871,93 +1285,70 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 			"count":           len(candidates),
 		})
 
-	return newFallbackProvider(primaryModel, candidates), nil
+	fallback := newFallbackProvider(primaryModel, candidates)
+	fallback.fallbackOnRefusal = cfg.Agents.Defaults.FallbackOnRefusal
+	return fallback, nil
 }
 
-func createProviderForModel(cfg *config.Config, model string) (LLMProvider, error) {
-	model = strings.TrimSpace(model)
-	if model == "" {
-		return nil, fmt.Errorf("model must not be empty")
+// providerRecorder returns the recorder for logging.provider_recording_dir,
+// redacting the API key and credential-like headers, or nil when unset.
+func providerRecorder(cfg *config.Config, apiKey string, headers map[string]string) *Recorder {
+	dir := cfg.ProviderRecordingPath()
+	if dir == "" {
+		return nil
 	}
-
-	var apiKey, apiBase string
-	var routing map[string]interface{}
-
-	lowerModel := strings.ToLower(model)
-
-	switch {
-	case strings.HasPrefix(model, "openrouter/") || strings.HasPrefix(model, "anthropic/") || strings.HasPrefix(model, "openai/") || strings.HasPrefix(model, "meta-llama/") || strings.HasPrefix(model, "deepseek/") || strings.HasPrefix(model, "google/"):
-		apiKey = cfg.Providers.OpenRouter.APIKey
-		if cfg.Providers.OpenRouter.APIBase != "" {
-			apiBase = cfg.Providers.OpenRouter.APIBase
-		} else {
-			apiBase = "https://openrouter.ai/api/v1"
-		}
-		routing = cfg.Providers.OpenRouter.Routing
-
-	case (strings.Contains(lowerModel, "claude") || strings.HasPrefix(model, "anthropic/")) && (cfg.Providers.Anthropic.APIKey != "" || cfg.Providers.Anthropic.AuthMethod != ""):
-		if cfg.Providers.Anthropic.AuthMethod == "oauth" || cfg.Providers.Anthropic.AuthMethod == "token" {
-			return createClaudeAuthProvider()
-		}
-		apiKey = cfg.Providers.Anthropic.APIKey
-		apiBase = cfg.Providers.Anthropic.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.anthropic.com/v1"
+	secrets := []string{apiKey}
+	for k, v := range headers {
+		if sensitiveHeaderPattern.MatchString(k) {
+			secrets = append(secrets, v)
 		}
+	}
+	return NewRecorder(dir, secrets...)
+}
 
-	case (strings.Contains(lowerModel, "gpt") || strings.HasPrefix(model, "openai/")) && (cfg.Providers.OpenAI.APIKey != "" || cfg.Providers.OpenAI.AuthMethod != ""):
-		if cfg.Providers.OpenAI.AuthMethod == "oauth" || cfg.Providers.OpenAI.AuthMethod == "token" {
-			return createCodexAuthProvider()
-		}
-		apiKey = cfg.Providers.OpenAI.APIKey
-		apiBase = cfg.Providers.OpenAI.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.openai.com/v1"
-		}
+// CreateProviderForModel builds the provider serving model, without the
+// fallback chain. It fails when no configured provider matches the model.
+func CreateProviderForModel(cfg *config.Config, model string) (LLMProvider, error) {
+	return createProviderForModel(cfg, model)
+}
 
-	case (strings.Contains(lowerModel, "gemini") || strings.HasPrefix(model, "google/")) && cfg.Providers.Gemini.APIKey != "":
-		apiKey = cfg.Providers.Gemini.APIKey
-		apiBase = cfg.Providers.Gemini.APIBase
-		if apiBase == "" {
-			apiBase = "https://generativelanguage.googleapis.com/v1beta"
-		}
+func createProviderForModel(cfg *config.Config, model string) (LLMProvider, error) {
+	model = strings.TrimSpace(model)
+	route, err := cfg.ResolveModel(model)
+	if err != nil {
+		return nil, err
+	}
+	providerCfg, _ := cfg.ProviderByName(route.Provider)
+	apiKey := providerCfg.APIKey
+	apiBase := route.APIBase
+	headers := providerHeaders(providerCfg)
+	var routing map[string]interface{}
 
-	case (strings.Contains(lowerModel, "glm") || strings.Contains(lowerModel, "zhipu") || strings.Contains(lowerModel, "zai")) && cfg.Providers.Zhipu.APIKey != "":
-		apiKey = cfg.Providers.Zhipu.APIKey
-		apiBase = cfg.Providers.Zhipu.APIBase
-		if apiBase == "" {
-			apiBase = "https://open.bigmodel.cn/api/paas/v4"
-		}
+	switch route.Provider {
+	case config.ProviderOpenRouter:
+		routing = providerCfg.Routing
 
-	case (strings.Contains(lowerModel, "groq") || strings.HasPrefix(model, "groq/")) && cfg.Providers.Groq.APIKey != "":
-		apiKey = cfg.Providers.Groq.APIKey
-		apiBase = cfg.Providers.Groq.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.groq.com/openai/v1"
+	case config.ProviderAnthropic:
+		if providerCfg.AuthMethod == "oauth" || providerCfg.AuthMethod == "token" {
+			return createClaudeAuthProvider(providerCfg)
 		}
 
-	case (strings.Contains(lowerModel, "glm-5") || strings.HasPrefix(lowerModel, "zai-org/")) && cfg.Providers.Modal.APIKey != "":
-		apiKey = cfg.Providers.Modal.APIKey
-		apiBase = cfg.Providers.Modal.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.us-west-2.modal.direct/v1"
+	case config.ProviderOpenAI:
+		if providerCfg.AuthMethod == "oauth" || providerCfg.AuthMethod == "token" {
+			return createCodexAuthProvider(providerCfg)
 		}
 
-	case cfg.Providers.VLLM.APIBase != "":
-		apiKey = cfg.Providers.VLLM.APIKey
-		apiBase = cfg.Providers.VLLM.APIBase
-
-	default:
-		if cfg.Providers.OpenRouter.APIKey != "" {
-			apiKey = cfg.Providers.OpenRouter.APIKey
-			if cfg.Providers.OpenRouter.APIBase != "" {
-				apiBase = cfg.Providers.OpenRouter.APIBase
-			} else {
-				apiBase = "https://openrouter.ai/api/v1"
+	case config.ProviderGemini:
+		// The OpenAI-compatible Gemini endpoint (".../openai") keeps using
+		// HTTPProvider; everything else speaks the native format.
+		if !strings.HasSuffix(strings.TrimRight(apiBase, "/"), "/openai") {
+			gp := NewGeminiProvider(apiKey, apiBase)
+			if len(headers) > 0 {
+				gp.transport.SetHeaders(headers)
 			}
-			routing = cfg.Providers.OpenRouter.Routing
-		} else {
-			return nil, fmt.Errorf("no API key configured for model: %s", model)
+			applyProviderRetries(gp.transport, providerCfg)
+			gp.transport.SetRecorder(providerRecorder(cfg, apiKey, headers))
+			return gp, nil
 		}
 	}
 
@@ -973,5 +1364,10 @@ func createProviderForModel(cfg *config.Config, model string) (LLMProvider, erro
 	if len(routing) > 0 {
 		p.SetRouting(routing)
 	}
+	if len(headers) > 0 {
+		p.SetHeaders(headers)
+	}
+	applyProviderRetries(p, providerCfg)
+	p.SetRecorder(providerRecorder(cfg, apiKey, headers))
 	return p, nil
 }
@@ -1,8 +1,10 @@
 package providers
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -91,6 +93,68 @@ func TestParseResponse_Contract_MalformedToolArgs(t *testing.T) {
 	}
 }
 
+func TestParseResponse_Contract_ObjectAndStringArgsParseTheSame(t *testing.T) {
+	p := NewHTTPProvider("test-key", "https://example.com")
+
+	var parsed []ToolCall
+	for _, fixture := range []string{"response_toolcalls_string_args_nested.json", "response_toolcalls_object_args.json"} {
+		resp, err := p.parseResponse(readFixture(t, fixture))
+		if err != nil {
+			t.Fatalf("%s: parseResponse error: %v", fixture, err)
+		}
+		if len(resp.ToolCalls) != 1 {
+			t.Fatalf("%s: expected 1 tool call, got %d", fixture, len(resp.ToolCalls))
+		}
+		tc := resp.ToolCalls[0]
+		if _, ok := tc.Arguments["raw"]; ok {
+			t.Fatalf("%s: arguments fell back to raw: %+v", fixture, tc.Arguments)
+		}
+		// The string form is replayed to the provider in history, so it must
+		// be the JSON encoding of the same arguments.
+		var replayed map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &replayed); err != nil || !reflect.DeepEqual(replayed, tc.Arguments) {
+			t.Fatalf("%s: Function.Arguments %q does not match parsed args (%v)", fixture, tc.Function.Arguments, err)
+		}
+		parsed = append(parsed, tc)
+	}
+
+	if !reflect.DeepEqual(parsed[0].Arguments, parsed[1].Arguments) {
+		t.Fatalf("string-encoded args %+v != object args %+v", parsed[0].Arguments, parsed[1].Arguments)
+	}
+	if parsed[1].Name != "write_file" || parsed[1].Arguments["content"] != "- buy milk\n- call mom" || parsed[1].Arguments["append"] != true {
+		t.Fatalf("unexpected object args: %+v", parsed[1].Arguments)
+	}
+}
+
+func TestDecodeToolCallArguments_EdgeCases(t *testing.T) {
+	cases := []struct {
+		raw     string
+		wantLen int
+		wantRaw bool
+	}{
+		{``, 0, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`"null"`, 0, false},
+		{`{}`, 0, false},
+		{`"{\"a\":1}"`, 1, false},
+		{`{"a":1}`, 1, false},
+		{`"{broken"`, 1, true},
+		{`[1,2]`, 1, true},
+		{`42`, 1, true},
+	}
+	for _, tc := range cases {
+		args, _ := decodeToolCallArguments(json.RawMessage(tc.raw))
+		if args == nil {
+			t.Fatalf("%s: nil map", tc.raw)
+		}
+		_, hasRaw := args["raw"]
+		if len(args) != tc.wantLen || hasRaw != tc.wantRaw {
+			t.Errorf("%s: args = %+v", tc.raw, args)
+		}
+	}
+}
+
 func TestParseResponse_Contract_ExtractsToolCallDescription(t *testing.T) {
 	p := NewHTTPProvider("test-key", "https://example.com")
 	body := []byte(`{
@@ -130,6 +194,7 @@ func FuzzHTTPProviderParseResponse_NoPanic(f *testing.F) {
 	f.Add(string(readFixtureForFuzz("response_toolcalls_openai.json")))
 	f.Add(string(readFixtureForFuzz("response_toolcalls_legacy.json")))
 	f.Add(string(readFixtureForFuzz("response_toolcalls_malformed_args.json")))
+	f.Add(string(readFixtureForFuzz("response_toolcalls_object_args.json")))
 	f.Add(`{"choices":[]}`)
 	f.Add(`{}`)
 
@@ -147,3 +212,41 @@ func readFixtureForFuzz(name string) []byte {
 	}
 	return b
 }
+
+func TestParseResponse_Contract_ReasoningField(t *testing.T) {
+	p := NewHTTPProvider("test-key", "https://example.com")
+
+	resp, err := p.parseResponse(readFixture(t, "response_reasoning_field.json"))
+	if err != nil {
+		t.Fatalf("parseResponse error: %v", err)
+	}
+	if resp.Content != "The answer is 42." {
+		t.Fatalf("Content = %q, want only the answer", resp.Content)
+	}
+	if resp.Reasoning != "The user wants the meaning of life. Douglas Adams says 42." {
+		t.Fatalf("Reasoning = %q", resp.Reasoning)
+	}
+
+	resp, err = p.parseResponse(readFixture(t, "response_reasoning_content_field.json"))
+	if err != nil {
+		t.Fatalf("parseResponse error: %v", err)
+	}
+	if resp.Content != "Paris." || resp.Reasoning != "France's capital city is Paris." {
+		t.Fatalf("reasoning_content not separated: content=%q reasoning=%q", resp.Content, resp.Reasoning)
+	}
+}
+
+func TestParseResponse_Contract_InlineThinkTags(t *testing.T) {
+	p := NewHTTPProvider("test-key", "https://example.com")
+
+	resp, err := p.parseResponse(readFixture(t, "response_reasoning_inline.json"))
+	if err != nil {
+		t.Fatalf("parseResponse error: %v", err)
+	}
+	if resp.Content != "2 + 2 = 4." {
+		t.Fatalf("Content = %q, want think block stripped", resp.Content)
+	}
+	if resp.Reasoning != "The user asked for 2+2. That is 4." {
+		t.Fatalf("Reasoning = %q", resp.Reasoning)
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -149,6 +150,7 @@ func newTestProvider(apiKey, apiBase string) *HTTPProvider {
 	p.retryBaseWait = 1 * time.Millisecond
 	p.retryMaxWait = 10 * time.Millisecond
 	p.retryJitter = 0
+	p.retryAfterJitter = 0
 	return p
 }
 
@@ -260,6 +262,93 @@ func TestChat_RetryOnErrorFinishReason_WithContent(t *testing.T) {
 	}
 }
 
+// TestChat_RetryOnConfiguredStubContent verifies that a reply matching a
+// retry content pattern is retried, while other short answers are returned.
+func TestChat_RetryOnConfiguredStubContent(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if n < 2 {
+			fmt.Fprint(w, validResponse("I'm sorry, I cannot help with that right now."))
+		} else {
+			fmt.Fprint(w, validResponse("No."))
+		}
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	if err := p.SetRetryContentPatterns([]string{`(?i)^I'm sorry, I cannot help with that right now\.?$`}); err != nil {
+		t.Fatalf("SetRetryContentPatterns: %v", err)
+	}
+	resp, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if resp.Content != "No." {
+		t.Fatalf("expected content 'No.', got: %q", resp.Content)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected the stub to be retried once (2 calls), got: %d", calls.Load())
+	}
+
+	if err := p.SetRetryContentPatterns([]string{`(bad`}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+}
+
+// TestChat_ContentFilterIsRefusedNotAnswered verifies that a 200 response
+// stopped by the content filter surfaces as a refusal error, without retries,
+// instead of being returned as the model's answer.
+func TestChat_ContentFilterIsRefusedNotAnswered(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"choices": [{
+				"message": {"content": "I can", "tool_calls": []},
+				"finish_reason": "content_filter"
+			}]
+		}`)
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	resp, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if err == nil {
+		t.Fatalf("expected a refusal error, got answer %+v", resp)
+	}
+	if kind := ProviderErrorKindOf(err); kind != ProviderErrorRefused {
+		t.Fatalf("error kind = %q, want %q (err: %v)", kind, ProviderErrorRefused, err)
+	}
+	if !strings.Contains(err.Error(), "content_filter") {
+		t.Fatalf("error should name the finish reason, got: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 call (no retry), got: %d", calls.Load())
+	}
+}
+
+func TestChat_RefusalFieldIsRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"choices": [{
+				"message": {"content": null, "refusal": "I can't help with that."},
+				"finish_reason": "stop"
+			}]
+		}`)
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	_, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if ProviderErrorKindOf(err) != ProviderErrorRefused || !strings.Contains(err.Error(), "I can't help with that.") {
+		t.Fatalf("expected refusal error carrying the provider's text, got: %v", err)
+	}
+}
+
 func TestNewHTTPProvider_DefaultClientTimeoutIsZero(t *testing.T) {
 	p := NewHTTPProvider("test-key", "https://example.com")
 	if p.httpClient == nil {
@@ -533,6 +622,53 @@ func TestChat_NewlinePaddedResponse(t *testing.T) {
 	}
 }
 
+// TestChat_CustomHeadersIncludedInRequest verifies SetHeaders values reach
+// the wire and may override the default Authorization header.
+func TestChat_CustomHeadersIncludedInRequest(t *testing.T) {
+	var captured http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	p.SetHeaders(map[string]string{
+		"HTTP-Referer":  "https://example.com/app",
+		"X-Title":       "PicoClaw",
+		"Authorization": "Token gateway-secret",
+	})
+
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got := captured.Get("HTTP-Referer"); got != "https://example.com/app" {
+		t.Fatalf("HTTP-Referer = %q", got)
+	}
+	if got := captured.Get("X-Title"); got != "PicoClaw" {
+		t.Fatalf("X-Title = %q", got)
+	}
+	if got := captured.Get("Authorization"); got != "Token gateway-secret" {
+		t.Fatalf("Authorization = %q, want custom override", got)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	got := redactHeaders(map[string]string{
+		"Authorization": "Bearer abc",
+		"X-Api-Key":     "k",
+		"X-Title":       "PicoClaw",
+	})
+	if got["Authorization"] != "[REDACTED]" || got["X-Api-Key"] != "[REDACTED]" {
+		t.Fatalf("sensitive headers not redacted: %v", got)
+	}
+	if got["X-Title"] != "PicoClaw" {
+		t.Fatalf("non-sensitive header changed: %v", got)
+	}
+}
+
 // TestChat_ProviderRoutingIncludedInRequest verifies that when routing is set,
 // it appears as the "provider" object in the request body sent to the API.
 func TestChat_ProviderRoutingIncludedInRequest(t *testing.T) {
@@ -600,6 +736,120 @@ func TestChat_ProviderRoutingOmittedWhenEmpty(t *testing.T) {
 	}
 }
 
+func TestChat_SeedIncludedOnlyWhenConfigured(t *testing.T) {
+	var capturedBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		capturedBody = nil
+		json.Unmarshal(body, &capturedBody)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+
+	seed := 42
+	opts := ChatOptions{MaxTokens: 100, Temperature: 0, Seed: &seed}.ToMap()
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got, ok := capturedBody["seed"].(float64); !ok || got != 42 {
+		t.Fatalf("seed = %#v, want 42", capturedBody["seed"])
+	}
+	if got, ok := capturedBody["temperature"].(float64); !ok || got != 0 {
+		t.Fatalf("temperature = %#v, want per-call override 0", capturedBody["temperature"])
+	}
+
+	if _, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, ok := capturedBody["seed"]; ok {
+		t.Fatal("expected no 'seed' field in request body when seed is not configured")
+	}
+}
+
+func TestChat_AnthropicCacheMarksSystemAndToolsForClaude(t *testing.T) {
+	var capturedBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &capturedBody)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	messages := []Message{
+		{Role: "system", Content: "You are picoclaw."},
+		{Role: "user", Content: "hello"},
+	}
+	tools := []ToolDefinition{
+		{Type: "function", Function: ToolFunctionDefinition{Name: "read_file", Parameters: map[string]interface{}{"type": "object"}}},
+		{Type: "function", Function: ToolFunctionDefinition{Name: "exec", Parameters: map[string]interface{}{"type": "object"}}},
+	}
+	opts := map[string]interface{}{"anthropic_cache": true}
+
+	if _, err := p.Chat(context.Background(), messages, tools, "anthropic/claude-sonnet-4.5", opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	wireMessages := capturedBody["messages"].([]interface{})
+	system := wireMessages[0].(map[string]interface{})
+	parts, ok := system["content"].([]interface{})
+	if !ok || len(parts) != 1 {
+		t.Fatalf("expected system content to be a single part, got: %#v", system["content"])
+	}
+	part := parts[0].(map[string]interface{})
+	if part["text"] != "You are picoclaw." {
+		t.Fatalf("system text = %v", part["text"])
+	}
+	cc, ok := part["cache_control"].(map[string]interface{})
+	if !ok || cc["type"] != "ephemeral" {
+		t.Fatalf("expected ephemeral cache_control on system part, got: %#v", part["cache_control"])
+	}
+
+	user := wireMessages[1].(map[string]interface{})
+	if user["content"] != "hello" {
+		t.Fatalf("user message should be unchanged, got: %#v", user["content"])
+	}
+
+	wireTools := capturedBody["tools"].([]interface{})
+	if _, ok := wireTools[0].(map[string]interface{})["cache_control"]; ok {
+		t.Fatal("expected only the last tool to carry cache_control")
+	}
+	lastCC, ok := wireTools[1].(map[string]interface{})["cache_control"].(map[string]interface{})
+	if !ok || lastCC["type"] != "ephemeral" {
+		t.Fatalf("expected ephemeral cache_control on last tool, got: %#v", wireTools[1])
+	}
+}
+
+func TestChat_AnthropicCacheIgnoredForNonClaudeModel(t *testing.T) {
+	var rawBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rawBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	messages := []Message{
+		{Role: "system", Content: "You are picoclaw."},
+		{Role: "user", Content: "hello"},
+	}
+	// A TTL only Claude understands must not break other models.
+	opts := map[string]interface{}{"anthropic_cache": true, "anthropic_cache_ttl": "2h"}
+
+	if _, err := p.Chat(context.Background(), messages, nil, "gpt-4o", opts); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if strings.Contains(rawBody, "cache_control") {
+		t.Fatalf("did not expect cache_control for non-Claude model, body: %s", rawBody)
+	}
+}
+
 func TestChat_CanonicalizesLegacyAssistantToolCallsInRequest(t *testing.T) {
 	var capturedBody map[string]interface{}
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -750,6 +1000,54 @@ func TestChat_EncodesInlineImagePartsForUserMessage(t *testing.T) {
 	}
 }
 
+func TestChat_EncodesURLAndBase64ImagePartsForUserMessage(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n0000"))
+
+	var capturedBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &capturedBody)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	messages := []Message{
+		{
+			Role:    "user",
+			Content: "Compare these",
+			Parts: []MessagePart{
+				{Type: MessagePartTypeImage, URL: "https://example.com/a.png"},
+				{Type: MessagePartTypeImage, Data: encoded, MediaType: "image/png"},
+			},
+		},
+	}
+
+	if _, err := p.Chat(context.Background(), messages, nil, "gpt-4o", newTestOptions()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rawMessages, _ := capturedBody["messages"].([]interface{})
+	if len(rawMessages) != 1 {
+		t.Fatalf("unexpected messages payload: %#v", capturedBody["messages"])
+	}
+	user, _ := rawMessages[0].(map[string]interface{})
+	content, ok := user["content"].([]interface{})
+	if !ok || len(content) != 3 {
+		t.Fatalf("user.content should be a 3-part array, got: %#v", user["content"])
+	}
+
+	wantURLs := []string{"https://example.com/a.png", "data:image/png;base64," + encoded}
+	for i, want := range wantURLs {
+		part, _ := content[i+1].(map[string]interface{})
+		imageURL, _ := part["image_url"].(map[string]interface{})
+		if part["type"] != "image_url" || imageURL["url"] != want {
+			t.Fatalf("content[%d] = %#v, want image_url %q", i+1, part, want)
+		}
+	}
+}
+
 func TestChat_ToolResultWithImagePartsAddsSyntheticUserMessage(t *testing.T) {
 	tmpDir := t.TempDir()
 	imagePath := filepath.Join(tmpDir, "input.png")
@@ -862,15 +1160,83 @@ func TestComputeRetryWait_AppliesJitterWhenNoRetryAfter(t *testing.T) {
 	}
 }
 
-func TestComputeRetryWait_DoesNotJitterRetryAfterHint(t *testing.T) {
+func TestComputeRetryWait_RetryAfterJitterNeverShortensHint(t *testing.T) {
 	p := newTestProvider("test-key", "https://example.com")
 	p.retryBaseWait = 100 * time.Millisecond
 	p.retryMaxWait = 5 * time.Second
 	p.retryJitter = 0.9
-	p.randFloat = func() float64 { return 0.0 } // would reduce wait if jitter were applied
+	p.retryAfterJitter = 100 * time.Millisecond
+	p.randFloat = func() float64 { return 0.0 } // would reduce wait if backoff jitter were applied
 
 	wait := p.computeRetryWait(1, 400*time.Millisecond, true)
 	if wait != 400*time.Millisecond {
 		t.Fatalf("wait = %v, want 400ms", wait)
 	}
 }
+
+func TestComputeRetryWait_AddsJitterToRetryAfterHint(t *testing.T) {
+	p := newTestProvider("test-key", "https://example.com")
+	p.retryBaseWait = 100 * time.Millisecond
+	p.retryMaxWait = 5 * time.Second
+	p.retryAfterJitter = 200 * time.Millisecond
+	p.randFloat = func() float64 { return 0.5 }
+
+	wait := p.computeRetryWait(1, 400*time.Millisecond, true)
+	if wait != 500*time.Millisecond {
+		t.Fatalf("wait = %v, want 500ms (400ms hint + 100ms jitter)", wait)
+	}
+
+	p.retryMaxWait = 450 * time.Millisecond
+	if wait := p.computeRetryWait(1, 400*time.Millisecond, true); wait != 450*time.Millisecond {
+		t.Fatalf("wait = %v, want jittered wait capped at 450ms", wait)
+	}
+
+	p.retryMaxWait = 5 * time.Second
+	p.SetRetryAfterJitter(0)
+	if wait := p.computeRetryWait(1, 400*time.Millisecond, true); wait != 400*time.Millisecond {
+		t.Fatalf("wait = %v, want 400ms with Retry-After jitter disabled", wait)
+	}
+}
+
+func TestParseRetryAfterHeader_FractionalSeconds(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1.5":  1500 * time.Millisecond,
+		"0.25": 250 * time.Millisecond,
+		"2.0":  2 * time.Second,
+	}
+	for header, want := range cases {
+		d, ok := parseRetryAfterHeader(header)
+		if !ok || d != want {
+			t.Fatalf("parseRetryAfterHeader(%q) = %v, %v; want %v, true", header, d, ok, want)
+		}
+	}
+	for _, header := range []string{"1e3", "Inf", "NaN", "1.", ".5", "1.5s"} {
+		if d, ok := parseRetryAfterHeader(header); ok {
+			t.Fatalf("parseRetryAfterHeader(%q) = %v, true; want ok=false", header, d)
+		}
+	}
+}
+
+func TestParseRetryAfterHeader_ZeroIsTinyWait(t *testing.T) {
+	for _, header := range []string{"0", "0.0", "-1", "0.001"} {
+		d, ok := parseRetryAfterHeader(header)
+		if !ok {
+			t.Fatalf("parseRetryAfterHeader(%q): expected ok=true", header)
+		}
+		if d != minRetryAfterWait {
+			t.Fatalf("parseRetryAfterHeader(%q) = %v, want %v", header, d, minRetryAfterWait)
+		}
+	}
+
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfterHeader(past); !ok || d != minRetryAfterWait {
+		t.Fatalf("past HTTP-date = %v, %v; want %v, true", d, ok, minRetryAfterWait)
+	}
+}
+
+func TestParseRetryAfterHeader_HugeValueDoesNotOverflow(t *testing.T) {
+	d, ok := parseRetryAfterHeader("99999999999999999999")
+	if !ok || d <= 0 {
+		t.Fatalf("parseRetryAfterHeader(huge) = %v, %v; want a large positive duration", d, ok)
+	}
+}
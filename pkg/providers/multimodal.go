@@ -10,6 +10,7 @@ import (
 	"image/jpeg"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -34,6 +35,31 @@ type inlineImageData struct {
 	MediaType  string
 	Base64Data string
 	DataURL    string
+	// URL is set instead of the base64 fields for remote http(s) images.
+	URL string
+}
+
+// transportURL returns the value for OpenAI-style image_url fields.
+func (d inlineImageData) transportURL() string {
+	if d.DataURL != "" {
+		return d.DataURL
+	}
+	return d.URL
+}
+
+// describeImagePart identifies a part in log fields without dumping base64.
+func describeImagePart(part MessagePart) string {
+	switch {
+	case strings.TrimSpace(part.Path) != "":
+		return strings.TrimSpace(part.Path)
+	case strings.HasPrefix(strings.ToLower(strings.TrimSpace(part.URL)), "data:"):
+		return "data URL"
+	case strings.TrimSpace(part.URL) != "":
+		return strings.TrimSpace(part.URL)
+	case part.Data != "":
+		return "base64 data"
+	}
+	return ""
 }
 
 func SupportsInlineVisionTransport(provider LLMProvider, model string) bool {
@@ -50,7 +76,7 @@ func providerSupportsInlineVision(provider LLMProvider, model string) bool {
 	}
 
 	switch p := provider.(type) {
-	case *HTTPProvider, *ClaudeProvider, *CodexProvider:
+	case *HTTPProvider, *ClaudeProvider, *CodexProvider, *GeminiProvider:
 		return true
 	case *fallbackProvider:
 		ordered := p.orderedCandidates(model)
@@ -97,14 +123,55 @@ func inlineImageDataFromPart(part MessagePart) (inlineImageData, error) {
 		return inlineImageData{}, fmt.Errorf("unsupported message part type %q", part.Type)
 	}
 
+	if data := strings.TrimSpace(part.Data); data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return inlineImageData{}, fmt.Errorf("decode base64 image part: %w", err)
+		}
+		return inlineImageDataFromBytes("base64 image part", decoded, part.MediaType)
+	}
+
+	if rawURL := strings.TrimSpace(part.URL); rawURL != "" {
+		return inlineImageDataFromURL(rawURL, part.MediaType)
+	}
+
 	path := strings.TrimSpace(part.Path)
 	if path == "" {
-		return inlineImageData{}, fmt.Errorf("image part path is empty")
+		return inlineImageData{}, fmt.Errorf("image part has no path, url, or data")
 	}
 
 	return loadInlineImageData(path, part.MediaType)
 }
 
+// inlineImageDataFromURL decodes data: URLs into inline bytes; http(s) URLs
+// are passed through for providers that fetch remote images themselves.
+func inlineImageDataFromURL(rawURL string, mediaTypeHint string) (inlineImageData, error) {
+	if strings.HasPrefix(strings.ToLower(rawURL), "data:") {
+		meta, payload, ok := strings.Cut(rawURL[len("data:"):], ",")
+		if !ok || !strings.HasSuffix(strings.ToLower(meta), ";base64") {
+			return inlineImageData{}, fmt.Errorf("image data URL must be base64-encoded")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return inlineImageData{}, fmt.Errorf("decode image data URL: %w", err)
+		}
+		if strings.TrimSpace(mediaTypeHint) == "" {
+			mediaTypeHint = meta[:len(meta)-len(";base64")]
+		}
+		return inlineImageDataFromBytes("image data URL", decoded, mediaTypeHint)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return inlineImageData{}, fmt.Errorf("unsupported image URL %q (expected http, https, or data)", rawURL)
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(mediaTypeHint))
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType = detectInlineImageMediaType(u.Path, nil, "")
+	}
+	return inlineImageData{MediaType: mediaType, URL: rawURL}, nil
+}
+
 func loadInlineImageData(path string, mediaTypeHint string) (inlineImageData, error) {
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -126,9 +193,18 @@ func loadInlineImageData(path string, mediaTypeHint string) (inlineImageData, er
 	if err != nil {
 		return inlineImageData{}, fmt.Errorf("read image %q: %w", path, err)
 	}
+	return inlineImageDataFromBytes(path, data, mediaTypeHint)
+}
+
+// inlineImageDataFromBytes validates and, when needed, shrinks raw image bytes.
+// path is used for media type detection and error messages only.
+func inlineImageDataFromBytes(path string, data []byte, mediaTypeHint string) (inlineImageData, error) {
 	if len(data) == 0 {
 		return inlineImageData{}, fmt.Errorf("image %q is empty", path)
 	}
+	if len(data) > maxInlineImageBytes {
+		return inlineImageData{}, fmt.Errorf("image %q exceeds inline max size of %d bytes", path, maxInlineImageBytes)
+	}
 
 	mediaType := detectInlineImageMediaType(path, data, mediaTypeHint)
 	if !strings.HasPrefix(mediaType, "image/") {
@@ -115,3 +115,44 @@ func minInt(a, b int) int {
 	}
 	return b
 }
+
+func TestInlineImageDataFromPart_Sources(t *testing.T) {
+	pngHeader := []byte("\x89PNG\r\n\x1a\n0000")
+	encoded := base64.StdEncoding.EncodeToString(pngHeader)
+
+	fromData, err := inlineImageDataFromPart(MessagePart{Type: MessagePartTypeImage, Data: encoded})
+	if err != nil {
+		t.Fatalf("base64 part: %v", err)
+	}
+	if fromData.transportURL() != "data:image/png;base64,"+encoded {
+		t.Fatalf("base64 part transport URL = %q", fromData.transportURL())
+	}
+
+	fromDataURL, err := inlineImageDataFromPart(MessagePart{URL: "data:image/png;base64," + encoded})
+	if err != nil {
+		t.Fatalf("data URL part: %v", err)
+	}
+	if fromDataURL.Base64Data != encoded || fromDataURL.MediaType != "image/png" {
+		t.Fatalf("data URL part = %+v", fromDataURL)
+	}
+
+	remote, err := inlineImageDataFromPart(MessagePart{URL: "https://example.com/cat.jpg"})
+	if err != nil {
+		t.Fatalf("remote part: %v", err)
+	}
+	if remote.Base64Data != "" || remote.transportURL() != "https://example.com/cat.jpg" || remote.MediaType != "image/jpeg" {
+		t.Fatalf("remote part = %+v", remote)
+	}
+
+	for _, bad := range []MessagePart{
+		{},
+		{Data: "not base64!"},
+		{URL: "ftp://example.com/cat.png"},
+		{URL: "data:image/png,raw"},
+		{Data: base64.StdEncoding.EncodeToString([]byte("plain text"))},
+	} {
+		if _, err := inlineImageDataFromPart(bad); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
@@ -10,6 +10,9 @@ type ChatOptions struct {
 	Temperature       float64
 	AnthropicCache    bool
 	AnthropicCacheTTL string
+	// Seed requests deterministic sampling when set; providers without seed
+	// support ignore it.
+	Seed *int
 }
 
 // ToMap converts ChatOptions to provider request options.
@@ -26,5 +29,8 @@ func (o ChatOptions) ToMap() map[string]interface{} {
 	if ttl := strings.TrimSpace(o.AnthropicCacheTTL); ttl != "" {
 		opts["anthropic_cache_ttl"] = ttl
 	}
+	if o.Seed != nil {
+		opts["seed"] = *o.Seed
+	}
 	return opts
 }
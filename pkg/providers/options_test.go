@@ -47,3 +47,15 @@ func TestChatOptions_ToMap_OmitsEmptyAnthropicCacheTTL(t *testing.T) {
 		t.Fatal("expected anthropic_cache_ttl to be omitted when empty")
 	}
 }
+
+func TestChatOptions_ToMap_Seed(t *testing.T) {
+	if _, ok := (ChatOptions{Temperature: 0.7}).ToMap()["seed"]; ok {
+		t.Fatal("expected seed to be omitted when unset")
+	}
+
+	seed := 0
+	opts := ChatOptions{Temperature: 0.7, Seed: &seed}.ToMap()
+	if got, ok := opts["seed"].(int); !ok || got != 0 {
+		t.Fatalf("seed = %#v, want 0", opts["seed"])
+	}
+}
@@ -0,0 +1,36 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// pingMaxTokens keeps the ping reply short; some models refuse a budget of 1.
+const pingMaxTokens = 16
+
+// Ping checks that provider is reachable and accepts its credentials by
+// sending a minimal chat request for model (a few output tokens, at most one
+// retry). In a fallback chain the primary candidate is checked, so a broken
+// primary is not hidden by a working fallback. A content-policy refusal still
+// proves the provider works and counts as success.
+func Ping(ctx context.Context, provider LLMProvider, model string) error {
+	if fp, ok := provider.(*fallbackProvider); ok {
+		ordered := fp.orderedCandidates(model)
+		if len(ordered) == 0 {
+			return errors.New("no providers configured")
+		}
+		return Ping(ctx, ordered[0].provider, ordered[0].model)
+	}
+
+	ctx = WithRetryBudget(ctx, NewRetryBudget(1, 2*time.Second))
+	_, err := provider.Chat(ctx,
+		[]Message{{Role: "user", Content: "Reply with OK."}},
+		nil, model,
+		map[string]interface{}{"max_tokens": pingMaxTokens, "temperature": 0.0},
+	)
+	if err != nil && ProviderErrorKindOf(err) != ProviderErrorRefused {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type optionsRecordingProvider struct {
+	scriptedProvider
+	options map[string]interface{}
+}
+
+func (p *optionsRecordingProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	p.options = options
+	return p.scriptedProvider.Chat(ctx, messages, tools, model, options)
+}
+
+func TestPing_SendsMinimalRequest(t *testing.T) {
+	p := &optionsRecordingProvider{}
+	if err := Ping(context.Background(), p, "test-model"); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if p.options["max_tokens"] != pingMaxTokens || len(p.calls) != 1 || p.calls[0] != "test-model" {
+		t.Fatalf("unexpected ping request: calls=%v options=%v", p.calls, p.options)
+	}
+}
+
+func TestPing_ReportsFailuresButNotRefusals(t *testing.T) {
+	authErr := &ProviderError{Kind: ProviderErrorAuth, StatusCode: 401, Err: errors.New("API error (HTTP 401): invalid key")}
+	p := &scriptedProvider{results: []scriptedResult{{err: authErr}}}
+	if err := Ping(context.Background(), p, "test-model"); ProviderErrorKindOf(err) != ProviderErrorAuth {
+		t.Fatalf("Ping() error = %v, want the auth failure", err)
+	}
+
+	refused := &scriptedProvider{results: []scriptedResult{{err: &ProviderError{Kind: ProviderErrorRefused, Err: errors.New("refused")}}}}
+	if err := Ping(context.Background(), refused, "test-model"); err != nil {
+		t.Fatalf("a refusal proves the provider works, got %v", err)
+	}
+}
+
+func TestPing_ChecksPrimaryOfFallbackChain(t *testing.T) {
+	primary := &scriptedProvider{results: []scriptedResult{{err: errors.New("provider error: 503 service unavailable")}}}
+	backup := &scriptedProvider{}
+	p := newFallbackProvider("primary-model", []fallbackCandidate{
+		{model: "primary-model", provider: primary},
+		{model: "backup-model", provider: backup},
+	})
+
+	if err := Ping(context.Background(), p, "primary-model"); err == nil {
+		t.Fatal("a failing primary should fail the ping even with a working fallback")
+	}
+	if len(backup.calls) != 0 {
+		t.Fatalf("fallback should not be pinged, calls = %v", backup.calls)
+	}
+}
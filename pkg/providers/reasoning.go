@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// thinkBlockPattern matches inline reasoning blocks some models (DeepSeek R1,
+// QwQ and other reasoning models served through OpenAI-compatible APIs) emit
+// ahead of the answer.
+var thinkBlockPattern = regexp.MustCompile(`(?is)<think(?:ing)?>(.*?)</think(?:ing)?>`)
+
+// splitReasoning separates inline <think>...</think> blocks from content.
+// It also handles a missing opening tag (the template already opened it) and
+// an unterminated block (the response was cut off mid-thought), which both
+// leave only reasoning text on one side of the tag.
+func splitReasoning(content string) (answer, reasoning string) {
+	lower := strings.ToLower(content)
+	if !strings.Contains(lower, "<think") && !strings.Contains(lower, "</think") {
+		return content, ""
+	}
+
+	var parts []string
+	answer = thinkBlockPattern.ReplaceAllStringFunc(content, func(block string) string {
+		if m := thinkBlockPattern.FindStringSubmatch(block); len(m) == 2 {
+			if r := strings.TrimSpace(m[1]); r != "" {
+				parts = append(parts, r)
+			}
+		}
+		return ""
+	})
+
+	lower = strings.ToLower(answer)
+	if idx := strings.Index(lower, "</think"); idx >= 0 {
+		if r := strings.TrimSpace(answer[:idx]); r != "" {
+			parts = append(parts, r)
+		}
+		answer = answer[idx:]
+		if end := strings.Index(answer, ">"); end >= 0 {
+			answer = answer[end+1:]
+		} else {
+			answer = ""
+		}
+	} else if idx := strings.Index(lower, "<think"); idx >= 0 {
+		rest := answer[idx:]
+		if end := strings.Index(rest, ">"); end >= 0 {
+			if r := strings.TrimSpace(rest[end+1:]); r != "" {
+				parts = append(parts, r)
+			}
+		}
+		answer = answer[:idx]
+	}
+
+	return strings.TrimSpace(answer), strings.Join(parts, "\n\n")
+}
+
+// joinReasoning concatenates non-empty reasoning fragments.
+func joinReasoning(fragments ...string) string {
+	parts := make([]string, 0, len(fragments))
+	for _, f := range fragments {
+		if f = strings.TrimSpace(f); f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,29 @@
+package providers
+
+import "testing"
+
+func TestSplitReasoning(t *testing.T) {
+	cases := []struct {
+		name      string
+		in        string
+		answer    string
+		reasoning string
+	}{
+		{"no tags", "plain answer", "plain answer", ""},
+		{"leading block", "<think>plan</think>\nanswer", "answer", "plan"},
+		{"thinking tag", "<thinking>plan</thinking>answer", "answer", "plan"},
+		{"case insensitive", "<THINK>plan</THINK> answer", "answer", "plan"},
+		{"multiple blocks", "<think>a</think>one <think>b</think>two", "one two", "a\n\nb"},
+		{"missing open tag", "plan text</think>\n\nanswer", "answer", "plan text"},
+		{"unterminated", "answer so far <think>still thinking", "answer so far", "still thinking"},
+		{"empty block", "<think>\n</think>answer", "answer", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			answer, reasoning := splitReasoning(tc.in)
+			if answer != tc.answer || reasoning != tc.reasoning {
+				t.Fatalf("splitReasoning(%q) = (%q, %q), want (%q, %q)", tc.in, answer, reasoning, tc.answer, tc.reasoning)
+			}
+		})
+	}
+}
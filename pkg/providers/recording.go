@@ -0,0 +1,249 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Recording is one Chat call as written by a Recorder: the request body sent
+// and either the response body that was parsed or the error returned.
+type Recording struct {
+	Time       time.Time         `json:"time"`
+	APIBase    string            `json:"api_base,omitempty"`
+	Request    json.RawMessage   `json:"request"`
+	Response   json.RawMessage   `json:"response,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ErrorKind  ProviderErrorKind `json:"error_kind,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+}
+
+// recordingSeq orders recordings written in the same instant, also across
+// recorders sharing a directory.
+var recordingSeq atomic.Uint64
+
+// Recorder writes each Chat call of an HTTPProvider to its own timestamped
+// JSON file, for debugging and for replay with ReplayProvider. Retries are
+// not recorded separately: a file holds what Chat finally returned.
+// Credentials are redacted before anything is written.
+type Recorder struct {
+	dir     string
+	secrets []string
+	now     func() time.Time
+}
+
+// NewRecorder records into dir, created on first use. secrets (API keys,
+// header values) are replaced wherever they appear in a recording.
+func NewRecorder(dir string, secrets ...string) *Recorder {
+	r := &Recorder{dir: dir, now: time.Now}
+	for _, s := range secrets {
+		// Short values would redact unrelated text.
+		if s = strings.TrimSpace(s); len(s) >= 8 {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	return r
+}
+
+// record writes one call. Failures are logged and never affect the call.
+func (r *Recorder) record(apiBase string, request, response []byte, callErr error) {
+	if r == nil {
+		return
+	}
+	rec := Recording{
+		Time:    r.now().UTC(),
+		APIBase: apiBase,
+		Request: r.redactJSON(request),
+	}
+	if callErr != nil {
+		rec.Error = r.redactText(callErr.Error())
+		var providerErr *ProviderError
+		if errors.As(callErr, &providerErr) {
+			rec.ErrorKind = providerErr.Kind
+			rec.StatusCode = providerErr.StatusCode
+		}
+	} else {
+		rec.Response = r.redactJSON(response)
+	}
+
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(r.dir, 0700)
+	}
+	if err == nil {
+		name := fmt.Sprintf("%s-%06d.json", rec.Time.Format("20060102T150405.000000000Z"), recordingSeq.Add(1))
+		err = os.WriteFile(filepath.Join(r.dir, name), data, 0600)
+	}
+	if err != nil {
+		logger.WarnCF("provider", "Failed to write provider recording", map[string]interface{}{
+			"dir":   r.dir,
+			"error": err.Error(),
+		})
+	}
+}
+
+// sensitiveRecordingKeyPattern matches JSON fields whose values are dropped
+// from recordings whatever they contain.
+var sensitiveRecordingKeyPattern = regexp.MustCompile(`(?i)^(api[_-]?key|authorization|password|passwd|secret|client[_-]?secret|(access|auth|refresh)[_-]?token)$`)
+
+// secretTextPatterns catch credentials pasted into message text.
+var secretTextPatterns = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`(?i)(bearer\s+)[\w\-.~+/]{16,}=*`), "${1}[REDACTED]"},
+	{regexp.MustCompile(`\bsk-[\w\-]{16,}`), "[REDACTED]"},
+	{regexp.MustCompile(`(?i)\b(api[_-]?key|apikey|access[_-]?token|auth[_-]?token|secret|password|passwd)(\s*[=:]\s*)[\w\-.~+/]{8,}`), "${1}${2}[REDACTED]"},
+}
+
+func (r *Recorder) redactText(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	for _, sp := range secretTextPatterns {
+		s = sp.pattern.ReplaceAllString(s, sp.replace)
+	}
+	return s
+}
+
+// redactJSON redacts a JSON body field by field, keeping numbers exact. A
+// body that is not JSON is stored as a redacted JSON string.
+func (r *Recorder) redactJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err == nil {
+		if out, err := json.Marshal(r.redactValue(v)); err == nil {
+			return out
+		}
+	}
+	out, _ := json.Marshal(r.redactText(string(body)))
+	return out
+}
+
+func (r *Recorder) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if _, isString := val.(string); isString && sensitiveRecordingKeyPattern.MatchString(k) {
+				v[k] = "[REDACTED]"
+				continue
+			}
+			v[k] = r.redactValue(val)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = r.redactValue(v[i])
+		}
+		return v
+	case string:
+		return r.redactText(v)
+	default:
+		return v
+	}
+}
+
+// ReplayProvider serves the calls in a Recorder directory, one per Chat in
+// file order, for deterministic offline runs and regression fixtures.
+// Requests are not compared with the recorded ones.
+type ReplayProvider struct {
+	mu         sync.Mutex
+	recordings []Recording
+	next       int
+}
+
+// NewReplayProvider loads every recording in dir.
+func NewReplayProvider(dir string) (*ReplayProvider, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	p := &ReplayProvider{}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("invalid recording %s: %w", filepath.Base(name), err)
+		}
+		p.recordings = append(p.recordings, rec)
+	}
+	if len(p.recordings) == 0 {
+		return nil, fmt.Errorf("no recordings in %s", dir)
+	}
+	return p, nil
+}
+
+// Chat returns the next recorded response, or its recorded error.
+func (p *ReplayProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.next >= len(p.recordings) {
+		n := len(p.recordings)
+		p.mu.Unlock()
+		return nil, fmt.Errorf("replay: all %d recorded responses were served", n)
+	}
+	rec := p.recordings[p.next]
+	p.next++
+	p.mu.Unlock()
+
+	if rec.Error != "" {
+		kind := rec.ErrorKind
+		if kind == "" {
+			kind = ProviderErrorUnknown
+		}
+		return nil, &ProviderError{Kind: kind, StatusCode: rec.StatusCode, Err: errors.New(rec.Error)}
+	}
+	if isGeminiResponseBody(rec.Response) {
+		return parseGeminiResponse(rec.Response)
+	}
+	return (&HTTPProvider{}).parseResponse(rec.Response)
+}
+
+// Remaining reports how many recorded responses have not been served.
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.recordings) - p.next
+}
+
+// GetDefaultModel returns the model of the first recorded request.
+func (p *ReplayProvider) GetDefaultModel() string {
+	var request struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(p.recordings[0].Request, &request)
+	return request.Model
+}
+
+// isGeminiResponseBody tells native Gemini bodies from OpenAI-compatible ones.
+func isGeminiResponseBody(body []byte) bool {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return false
+	}
+	_, hasCandidates := fields["candidates"]
+	_, hasChoices := fields["choices"]
+	return hasCandidates && !hasChoices
+}
@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const recordedToolCallResponse = `{
+	"choices": [{
+		"message": {
+			"content": "Checking the weather.",
+			"reasoning_content": "The user wants the forecast.",
+			"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Oslo\",\"days\":3}"}}]
+		},
+		"finish_reason": "tool_calls"
+	}],
+	"usage": {"prompt_tokens": 1234, "completion_tokens": 56, "total_tokens": 1290}
+}`
+
+func TestRecorder_ReplayReproducesResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, recordedToolCallResponse)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	p := newTestProvider("sk-test-key-0123456789abcdef", srv.URL)
+	p.SetRecorder(NewRecorder(dir, "sk-test-key-0123456789abcdef"))
+
+	messages := []Message{{Role: "user", Content: "weather in Oslo? my key is sk-test-key-0123456789abcdef, password=hunter2hunter2"}}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "weather", Parameters: map[string]interface{}{"type": "object"}}}}
+	want, err := p.Chat(context.Background(), messages, tools, "test-model", newTestOptions())
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one recording, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if strings.Contains(string(data), "sk-test-key") || strings.Contains(string(data), "hunter2") {
+		t.Fatalf("recording leaks a secret:\n%s", data)
+	}
+	if !strings.Contains(string(data), "weather in Oslo") {
+		t.Fatalf("recording lacks the request:\n%s", data)
+	}
+
+	replay, err := NewReplayProvider(dir)
+	if err != nil {
+		t.Fatalf("NewReplayProvider: %v", err)
+	}
+	if got := replay.GetDefaultModel(); got != "test-model" {
+		t.Fatalf("GetDefaultModel = %q, want test-model", got)
+	}
+	got, err := replay.Chat(context.Background(), nil, nil, "", nil)
+	if err != nil {
+		t.Fatalf("replay Chat: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed response differs:\n got %+v\nwant %+v", got, want)
+	}
+	if _, err := replay.Chat(context.Background(), nil, nil, "", nil); err == nil {
+		t.Fatal("expected an error once the recordings are used up")
+	}
+}
+
+func TestRecorder_ReplayReproducesError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"message": "context too long"}}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	p := newTestProvider("test-key", srv.URL)
+	p.SetRecorder(NewRecorder(dir))
+	_, wantErr := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if wantErr == nil {
+		t.Fatal("expected the provider call to fail")
+	}
+
+	replay, err := NewReplayProvider(dir)
+	if err != nil {
+		t.Fatalf("NewReplayProvider: %v", err)
+	}
+	_, gotErr := replay.Chat(context.Background(), nil, nil, "", nil)
+	var providerErr *ProviderError
+	if !errors.As(gotErr, &providerErr) || providerErr.Kind != ProviderErrorBadRequest || providerErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("replayed error = %#v, want a bad_request ProviderError", gotErr)
+	}
+	if gotErr.Error() != wantErr.Error() {
+		t.Fatalf("replayed error %q, want %q", gotErr, wantErr)
+	}
+}
+
+func TestNewReplayProvider_EmptyDir(t *testing.T) {
+	if _, err := NewReplayProvider(t.TempDir()); err == nil {
+		t.Fatal("expected an error for a directory without recordings")
+	}
+}
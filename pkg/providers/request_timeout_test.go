@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// slowThenFastServer stalls the first slowCalls requests until the client
+// gives up, then answers immediately.
+func slowThenFastServer(t *testing.T, slowCalls int32, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= slowCalls {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("fast answer"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestChat_RequestTimeoutCancelsSlowAttemptAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := slowThenFastServer(t, 1, &calls)
+
+	p := newTestProvider("test-key", srv.URL)
+	p.SetRequestTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got: %v", err)
+	}
+	if resp.Content != "fast answer" {
+		t.Fatalf("content = %q", resp.Content)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("slow attempt was not cancelled (took %v)", elapsed)
+	}
+}
+
+func TestChat_RequestTimeoutFailsWhenEveryAttemptIsSlow(t *testing.T) {
+	var calls atomic.Int32
+	srv := slowThenFastServer(t, 100, &calls)
+
+	p := newTestProvider("test-key", srv.URL)
+	p.SetRetryPolicy(1, 0, 0, nil)
+	p.SetRequestTimeout(30 * time.Millisecond)
+
+	_, err := p.Chat(context.Background(), newTestMessages(), nil, "test-model", newTestOptions())
+	if err == nil || !strings.Contains(err.Error(), "request timed out after 30ms") {
+		t.Fatalf("expected request timeout error, got %v", err)
+	}
+	if ProviderErrorKindOf(err) != ProviderErrorTransient {
+		t.Fatalf("kind = %q, want transient", ProviderErrorKindOf(err))
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2 (1 + max_retries=1)", calls.Load())
+	}
+}
+
+func TestChatWithTimeout_ProviderRequestTimeoutReplacesCallTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// Slower than the agent-wide timeout, faster than the provider's.
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("slow but fine"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	p.SetRequestTimeout(5 * time.Second)
+	// The agent always passes its provider wrapped for usage tracking.
+	wrapped := NewUsageTrackingProvider(p, t.TempDir())
+
+	resp, err := ChatWithTimeout(context.Background(), 20*time.Millisecond, wrapped, newTestMessages(), nil, "test-model", newTestOptions())
+	if err != nil {
+		t.Fatalf("ChatWithTimeout() error = %v", err)
+	}
+	if resp.Content != "slow but fine" || calls.Load() != 1 {
+		t.Fatalf("resp = %+v, calls = %d", resp, calls.Load())
+	}
+}
+
+func TestProviderRequestTimeout_FallbackNeedsEveryCandidate(t *testing.T) {
+	withTimeout := NewHTTPProvider("k", "http://a")
+	withTimeout.SetRequestTimeout(time.Minute)
+	longer := NewHTTPProvider("k", "http://b")
+	longer.SetRequestTimeout(3 * time.Minute)
+
+	fp := newFallbackProvider("a", []fallbackCandidate{
+		{model: "a", provider: withTimeout},
+		{model: "b", provider: longer},
+	})
+	if got := providerRequestTimeout(fp, "a"); got != 3*time.Minute {
+		t.Fatalf("timeout = %v, want the longest candidate's 3m", got)
+	}
+
+	fp = newFallbackProvider("a", []fallbackCandidate{
+		{model: "a", provider: withTimeout},
+		{model: "b", provider: NewHTTPProvider("k", "http://c")},
+	})
+	if got := providerRequestTimeout(fp, "a"); got != 0 {
+		t.Fatalf("timeout = %v, want 0 when a candidate has none", got)
+	}
+}
+
+func TestCreateProvider_AppliesRequestTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Model = "glm-4.7"
+	cfg.Providers.Zhipu.APIKey = "key"
+	cfg.Providers.Zhipu.RequestTimeoutSeconds = 300
+
+	p, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if got := p.(*HTTPProvider).RequestTimeout(); got != 5*time.Minute {
+		t.Fatalf("RequestTimeout() = %v, want 5m", got)
+	}
+}
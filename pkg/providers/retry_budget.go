@@ -0,0 +1,80 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RetryBudget caps retries across every provider call that shares it,
+// typically all LLM calls of one agent turn. Without it each Chat call retries
+// independently, so a stuck provider multiplies its backoff by the number of
+// iterations. A zero limit means that dimension is unbounded.
+type RetryBudget struct {
+	MaxRetries int
+	MaxWait    time.Duration
+
+	mu      sync.Mutex
+	retries int
+	waited  time.Duration
+}
+
+// NewRetryBudget returns a budget allowing maxRetries retries and maxWait of
+// cumulative backoff. It returns nil when both limits are unset.
+func NewRetryBudget(maxRetries int, maxWait time.Duration) *RetryBudget {
+	if maxRetries <= 0 && maxWait <= 0 {
+		return nil
+	}
+	return &RetryBudget{MaxRetries: maxRetries, MaxWait: maxWait}
+}
+
+// Allow reserves one retry that will wait for the given duration. It returns
+// false, reserving nothing, once the budget is exhausted. A nil budget always
+// allows.
+func (b *RetryBudget) Allow(wait time.Duration) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.MaxRetries > 0 && b.retries >= b.MaxRetries {
+		return false
+	}
+	if b.MaxWait > 0 && b.waited+wait > b.MaxWait {
+		return false
+	}
+	b.retries++
+	b.waited += wait
+	return true
+}
+
+// Used reports the retries and cumulative wait consumed so far.
+func (b *RetryBudget) Used() (int, time.Duration) {
+	if b == nil {
+		return 0, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.retries, b.waited
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudget attaches a shared retry budget to ctx. Providers consult it
+// before each retry.
+func WithRetryBudget(ctx context.Context, budget *RetryBudget) context.Context {
+	if budget == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// RetryBudgetFromContext returns the budget attached to ctx, or nil.
+func RetryBudgetFromContext(ctx context.Context) *RetryBudget {
+	if ctx == nil {
+		return nil
+	}
+	budget, _ := ctx.Value(retryBudgetKey{}).(*RetryBudget)
+	return budget
+}
@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget_AllowRespectsLimits(t *testing.T) {
+	b := NewRetryBudget(2, 0)
+	if !b.Allow(time.Second) || !b.Allow(time.Second) {
+		t.Fatal("expected first two retries to be allowed")
+	}
+	if b.Allow(0) {
+		t.Fatal("expected third retry to be rejected")
+	}
+
+	w := NewRetryBudget(0, 3*time.Second)
+	if !w.Allow(2 * time.Second) {
+		t.Fatal("expected retry within wait budget")
+	}
+	if w.Allow(2 * time.Second) {
+		t.Fatal("expected retry exceeding wait budget to be rejected")
+	}
+	if used, waited := w.Used(); used != 1 || waited != 2*time.Second {
+		t.Fatalf("Used() = (%d, %s), want (1, 2s)", used, waited)
+	}
+}
+
+func TestRetryBudget_NilAllowsEverything(t *testing.T) {
+	if NewRetryBudget(0, 0) != nil {
+		t.Fatal("expected nil budget when no limits are set")
+	}
+	var b *RetryBudget
+	if !b.Allow(time.Hour) {
+		t.Fatal("nil budget should always allow")
+	}
+	if RetryBudgetFromContext(context.Background()) != nil {
+		t.Fatal("expected no budget on a bare context")
+	}
+}
+
+// Three iterations each hit one 429 before succeeding. With a shared budget of
+// two retries, the third iteration must fail fast instead of retrying.
+func TestChat_SharedRetryBudgetAcrossIterations(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n%2 == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error": "rate limited"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, validResponse("ok"))
+	}))
+	defer srv.Close()
+
+	p := newTestProvider("test-key", srv.URL)
+	budget := NewRetryBudget(2, 0)
+	ctx := WithRetryBudget(context.Background(), budget)
+
+	for i := 1; i <= 2; i++ {
+		if _, err := p.Chat(ctx, newTestMessages(), nil, "test-model", newTestOptions()); err != nil {
+			t.Fatalf("iteration %d: expected success, got: %v", i, err)
+		}
+	}
+
+	_, err := p.Chat(ctx, newTestMessages(), nil, "test-model", newTestOptions())
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Fatalf("iteration 3: expected retry budget error, got: %v", err)
+	}
+	if got := calls.Load(); got != 5 {
+		t.Fatalf("expected 5 HTTP calls (2 per successful iteration + 1), got %d", got)
+	}
+	if used, _ := budget.Used(); used != 2 {
+		t.Fatalf("expected 2 retries consumed, got %d", used)
+	}
+}
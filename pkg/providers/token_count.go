@@ -0,0 +1,117 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// ErrTokenCountUnsupported is returned by CountTokens when the provider has
+// no token-counting endpoint for the model.
+var ErrTokenCountUnsupported = errors.New("provider cannot count tokens")
+
+// TokenCounter is implemented by providers that can count the prompt tokens
+// of a message list exactly, without running the model.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, messages []Message, model string) (int, error)
+}
+
+// CountTokens counts messages with provider's token-counting endpoint. In a
+// fallback chain the candidate serving model is asked; wrappers are looked
+// through. It returns ErrTokenCountUnsupported when no endpoint is
+// available; callers then fall back to an estimate.
+func CountTokens(ctx context.Context, provider LLMProvider, messages []Message, model string) (int, error) {
+	switch p := provider.(type) {
+	case *fallbackProvider:
+		ordered := p.orderedCandidates(model)
+		if len(ordered) == 0 {
+			return 0, ErrTokenCountUnsupported
+		}
+		return CountTokens(ctx, ordered[0].provider, messages, ordered[0].model)
+	case TokenCounter:
+		return p.CountTokens(ctx, messages, model)
+	case ProviderWrapper:
+		return CountTokens(ctx, p.Unwrap(), messages, model)
+	}
+	return 0, ErrTokenCountUnsupported
+}
+
+// CountTokens uses Anthropic's count_tokens endpoint.
+func (p *ClaudeProvider) CountTokens(ctx context.Context, messages []Message, model string) (int, error) {
+	var opts []option.RequestOption
+	tok := strings.TrimSpace(p.token)
+	if p.tokenSource != nil {
+		refreshed, err := p.tokenSource()
+		if err != nil {
+			return 0, fmt.Errorf("refreshing token: %w", err)
+		}
+		tok = strings.TrimSpace(refreshed)
+	}
+	if tok != "" {
+		opts = append(opts, option.WithAuthToken(tok))
+		if beta := oauthAnthropicBetaHeader(tok); beta != "" {
+			opts = append(opts, option.WithHeader("anthropic-beta", beta))
+		}
+	}
+	return countClaudeTokens(ctx, p.client, messages, model, tok, opts...)
+}
+
+// CountTokens uses Anthropic's count_tokens endpoint when the provider talks
+// to api.anthropic.com. OpenAI-compatible APIs have no equivalent, so every
+// other base returns ErrTokenCountUnsupported.
+func (p *HTTPProvider) CountTokens(ctx context.Context, messages []Message, model string) (int, error) {
+	base, ok := anthropicAPIRoot(p.apiBase)
+	if !ok || strings.TrimSpace(p.apiKey) == "" {
+		return 0, ErrTokenCountUnsupported
+	}
+	opts := []option.RequestOption{
+		option.WithBaseURL(base),
+		option.WithAPIKey(p.apiKey),
+		option.WithHTTPClient(p.httpClient),
+	}
+	for k, v := range p.headers {
+		opts = append(opts, option.WithHeader(k, v))
+	}
+	client := anthropic.NewClient(opts...)
+	model = strings.TrimPrefix(strings.TrimSpace(model), "anthropic/")
+	return countClaudeTokens(ctx, &client, messages, model, "")
+}
+
+// anthropicAPIRoot returns the SDK base URL for an Anthropic API base such
+// as "https://api.anthropic.com/v1".
+func anthropicAPIRoot(apiBase string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(apiBase))
+	if err != nil || !strings.EqualFold(u.Hostname(), "api.anthropic.com") {
+		return "", false
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/v1") + "/"
+	return u.String(), true
+}
+
+func countClaudeTokens(ctx context.Context, client *anthropic.Client, messages []Message, model, token string, opts ...option.RequestOption) (int, error) {
+	params, err := buildClaudeParams(messages, nil, model, nil)
+	if err != nil {
+		return 0, err
+	}
+	ensureClaudeCodeOAuthSystemPrefix(&params, token)
+
+	resp, err := client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{
+		Messages: params.Messages,
+		Model:    params.Model,
+		System:   anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System},
+	}, opts...)
+	if err != nil {
+		status := 0
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) {
+			status = apiErr.StatusCode
+		}
+		return 0, newStatusProviderError(fmt.Errorf("claude count_tokens: %w", err), status)
+	}
+	return int(resp.InputTokens), nil
+}
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClaudeProvider_CountTokens(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"input_tokens": 1234}`))
+	}))
+	defer server.Close()
+
+	provider := NewClaudeProvider("test-token")
+	provider.client = createAnthropicTestClient(server.URL, "test-token")
+
+	n, err := CountTokens(t.Context(), provider, []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "Hello"},
+	}, "claude-sonnet-4-5-20250929")
+	if err != nil {
+		t.Fatalf("CountTokens() error: %v", err)
+	}
+	if n != 1234 {
+		t.Fatalf("CountTokens() = %d, want 1234", n)
+	}
+	if got["model"] != "claude-sonnet-4-5-20250929" || got["system"] == nil {
+		t.Fatalf("unexpected request body %v", got)
+	}
+	if msgs, _ := got["messages"].([]interface{}); len(msgs) != 1 {
+		t.Fatalf("expected the system prompt outside messages, got %v", got["messages"])
+	}
+	if _, ok := got["max_tokens"]; ok {
+		t.Fatalf("count_tokens request must not carry max_tokens: %v", got)
+	}
+}
+
+func TestCountTokens_UnsupportedProviders(t *testing.T) {
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := CountTokens(context.Background(), NewHTTPProvider("key", "https://api.openai.com/v1"), msgs, "gpt-4o"); !errors.Is(err, ErrTokenCountUnsupported) {
+		t.Fatalf("OpenAI base: err = %v, want ErrTokenCountUnsupported", err)
+	}
+	if _, err := CountTokens(context.Background(), &scriptedProvider{}, msgs, "m"); !errors.Is(err, ErrTokenCountUnsupported) {
+		t.Fatalf("plain provider: err = %v, want ErrTokenCountUnsupported", err)
+	}
+
+	chain := newFallbackProvider("gpt-4o", []fallbackCandidate{
+		{model: "gpt-4o", provider: NewHTTPProvider("key", "https://api.openai.com/v1")},
+	})
+	if _, err := CountTokens(context.Background(), chain, msgs, "gpt-4o"); !errors.Is(err, ErrTokenCountUnsupported) {
+		t.Fatalf("fallback chain: err = %v, want ErrTokenCountUnsupported", err)
+	}
+}
+
+func TestCountTokens_LooksThroughUsageTracking(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"input_tokens": 42}`))
+	}))
+	defer server.Close()
+
+	provider := NewClaudeProvider("test-token")
+	provider.client = createAnthropicTestClient(server.URL, "test-token")
+	wrapped := NewUsageTrackingProvider(provider, t.TempDir())
+
+	n, err := CountTokens(t.Context(), wrapped, []Message{{Role: "user", Content: "hi"}}, "claude-sonnet-4-5-20250929")
+	if err != nil || n != 42 {
+		t.Fatalf("CountTokens() = %d, %v; want 42 from the wrapped provider", n, err)
+	}
+}
@@ -94,6 +94,10 @@ func (p *usageTrackingProvider) GetDefaultModel() string {
 	return p.inner.GetDefaultModel()
 }
 
+func (p *usageTrackingProvider) Unwrap() LLMProvider {
+	return p.inner
+}
+
 func (p *usageTrackingProvider) append(rec TokenUsageRecord) error {
 	data, err := json.Marshal(rec)
 	if err != nil {
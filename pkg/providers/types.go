@@ -1,6 +1,10 @@
 package providers
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
 
 type ToolCall struct {
 	ID          string                 `json:"id"`
@@ -21,6 +25,16 @@ type LLMResponse struct {
 	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
 	FinishReason string     `json:"finish_reason"`
 	Usage        *UsageInfo `json:"usage,omitempty"`
+	// Reasoning holds the model's thinking output, kept out of Content so it
+	// is never shown to users or stored in the session history.
+	Reasoning string `json:"reasoning,omitempty"`
+	// Refusal is the provider's explanation when the model declined on
+	// content-policy grounds (OpenAI's message.refusal).
+	Refusal string `json:"refusal,omitempty"`
+	// Model is the model that served the request when a provider may
+	// substitute another one, e.g. a fallback model. Empty means the
+	// requested model.
+	Model string `json:"model,omitempty"`
 }
 
 type UsageInfo struct {
@@ -51,9 +65,13 @@ const (
 	MessagePartTypeImage MessagePartType = "image"
 )
 
+// MessagePart is a non-text attachment. Exactly one source should be set:
+// Path (local file), URL (http(s) or data: URL), or Data (base64 bytes).
 type MessagePart struct {
 	Type      MessagePartType `json:"type,omitempty"`
 	Path      string          `json:"path,omitempty"`
+	URL       string          `json:"url,omitempty"`
+	Data      string          `json:"data,omitempty"`
 	MediaType string          `json:"media_type,omitempty"`
 }
 
@@ -62,6 +80,12 @@ type LLMProvider interface {
 	GetDefaultModel() string
 }
 
+// ProviderWrapper is implemented by providers that decorate another one
+// (usage tracking, for example), so capability checks can look through them.
+type ProviderWrapper interface {
+	Unwrap() LLMProvider
+}
+
 // AssistantMessageFromResponse builds a Message suitable for appending to the
 // conversation history from an LLM response that contains tool calls.
 // The returned message has Role "assistant" and carries the response's tool
@@ -87,6 +111,26 @@ func ToolResultMessage(toolCallID, result string) Message {
 	}
 }
 
+// StructuredToolResultMessage builds a "tool" role message whose content is
+// the result text followed by the structured data encoded as JSON, so the
+// model can act on exact fields. With no structured data it is equivalent to
+// ToolResultMessage.
+func StructuredToolResultMessage(toolCallID, text string, structured map[string]interface{}) Message {
+	if len(structured) == 0 {
+		return ToolResultMessage(toolCallID, text)
+	}
+	data, err := json.Marshal(structured)
+	if err != nil {
+		return ToolResultMessage(toolCallID, text)
+	}
+
+	content := string(data)
+	if text = strings.TrimSpace(text); text != "" {
+		content = text + "\n\n" + content
+	}
+	return ToolResultMessage(toolCallID, content)
+}
+
 type ToolDefinition struct {
 	Type     string                 `json:"type"`
 	Function ToolFunctionDefinition `json:"function"`
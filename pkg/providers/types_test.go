@@ -89,3 +89,30 @@ func TestCanonicalizeToolCalls_PullsDescriptionFromArguments(t *testing.T) {
 		t.Fatalf("Description = %q, want %q", tc.Description, "Check git status")
 	}
 }
+
+func TestStructuredToolResultMessage_AppendsJSON(t *testing.T) {
+	msg := StructuredToolResultMessage("call_1", "Found 1 memory.", map[string]interface{}{
+		"memories": []interface{}{map[string]interface{}{"id": 7, "category": "fact"}},
+	})
+	if msg.Role != "tool" || msg.ToolCallID != "call_1" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	want := "Found 1 memory.\n\n" + `{"memories":[{"category":"fact","id":7}]}`
+	if msg.Content != want {
+		t.Fatalf("Content = %q, want %q", msg.Content, want)
+	}
+}
+
+func TestStructuredToolResultMessage_NilStructuredIsPlainText(t *testing.T) {
+	msg := StructuredToolResultMessage("call_1", "plain", nil)
+	if msg.Content != "plain" {
+		t.Fatalf("Content = %q, want plain", msg.Content)
+	}
+}
+
+func TestStructuredToolResultMessage_EmptyTextIsJSONOnly(t *testing.T) {
+	msg := StructuredToolResultMessage("call_1", "  ", map[string]interface{}{"ok": true})
+	if msg.Content != `{"ok":true}` {
+		t.Fatalf("Content = %q", msg.Content)
+	}
+}
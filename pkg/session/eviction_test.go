@@ -0,0 +1,128 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestEviction_MaxInMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	sm.SetEviction(2, 0)
+
+	// Unsaved messages must survive eviction.
+	sm.AddMessage("a", "user", "hello from a")
+	sm.AddFullMessage("a", providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "read_file", Arguments: map[string]interface{}{"path": "x.txt"}}},
+	})
+	sm.AddFullMessage("a", providers.Message{Role: "tool", Content: "file body", ToolCallID: "tc1"})
+	sm.SetSummary("a", "a summary")
+	sm.SetModel("a", "gpt-test")
+	sm.AddMessage("b", "user", "hello from b")
+
+	// Touch a so that b is the least recently used when c arrives.
+	sm.GetHistory("a")
+	sm.AddMessage("c", "user", "hello from c")
+
+	if sm.InMemory("b") {
+		t.Fatal("expected b, the least recently used session, to be evicted")
+	}
+	if !sm.InMemory("a") || !sm.InMemory("c") {
+		t.Fatal("expected a and c to stay in memory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.json")); err != nil {
+		t.Fatalf("expected b to be flushed to disk before eviction: %v", err)
+	}
+
+	// Evict a too, then check it reloads intact.
+	sm.GetHistory("b")
+	if sm.InMemory("a") {
+		t.Fatal("expected a to be evicted")
+	}
+	history := sm.GetHistory("a")
+	if len(history) != 3 || history[0].Content != "hello from a" || history[2].ToolCallID != "tc1" {
+		t.Fatalf("reloaded history = %+v", history)
+	}
+	if got := history[1].ToolCalls[0].Arguments["path"]; got != "x.txt" {
+		t.Fatalf("reloaded tool call arguments = %v", history[1].ToolCalls[0].Arguments)
+	}
+	if sm.GetSummary("a") != "a summary" || sm.GetModel("a") != "gpt-test" {
+		t.Fatalf("reloaded summary/model = %q/%q", sm.GetSummary("a"), sm.GetModel("a"))
+	}
+	if got := sm.GetHistory("b"); len(got) != 1 || got[0].Content != "hello from b" {
+		t.Fatalf("reloaded b history = %+v", got)
+	}
+}
+
+func TestEviction_WritesGoToReloadedSession(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	sm.SetEviction(1, 0)
+
+	sm.AddMessage("a", "user", "one")
+	sm.AddMessage("b", "user", "other chat")
+	sm.AddMessage("a", "assistant", "two")
+
+	if got := sm.GetHistory("a"); len(got) != 2 || got[0].Content != "one" || got[1].Content != "two" {
+		t.Fatalf("history after reload = %+v", got)
+	}
+}
+
+func TestEviction_IdleTTL(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sm.now = func() time.Time { return now }
+	sm.SetEviction(0, 10*time.Minute)
+
+	sm.AddMessage("idle", "user", "old")
+	now = now.Add(5 * time.Minute)
+	sm.AddMessage("active", "user", "recent")
+
+	now = now.Add(6 * time.Minute)
+	sm.GetHistory("active")
+	if sm.InMemory("idle") {
+		t.Fatal("expected the idle session to be evicted")
+	}
+	if !sm.InMemory("active") {
+		t.Fatal("expected the active session to stay in memory")
+	}
+	if got := sm.GetHistory("idle"); len(got) != 1 || got[0].Content != "old" {
+		t.Fatalf("reloaded idle history = %+v", got)
+	}
+}
+
+func TestEviction_KeepsSessionWhenFlushFails(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	sm.SetEviction(1, 0)
+
+	orig := writeSessionFile
+	writeSessionFile = func(string, []byte, os.FileMode) error { return errors.New("disk full") }
+	defer func() { writeSessionFile = orig }()
+
+	sm.AddMessage("a", "user", "unsaved")
+	sm.AddMessage("b", "user", "other")
+
+	if !sm.InMemory("a") {
+		t.Fatal("expected a session that could not be saved to stay in memory")
+	}
+	if got := sm.GetHistory("a"); len(got) != 1 || got[0].Content != "unsaved" {
+		t.Fatalf("history = %+v", got)
+	}
+}
+
+func TestEviction_DisabledWithoutStorage(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.SetEviction(1, time.Nanosecond)
+
+	sm.AddMessage("a", "user", "kept")
+	sm.AddMessage("b", "user", "kept too")
+
+	if !sm.InMemory("a") || !sm.InMemory("b") {
+		t.Fatal("expected no eviction without storage")
+	}
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// ExportVersion is the schema version written by Export. Import rejects
+// documents with a newer version so future format changes can add migrations.
+const ExportVersion = 1
+
+// SessionExport is the portable JSON document produced by Export.
+// Messages are stored in full (tool calls and tool results included), unlike
+// transcripts which are truncated for debugging.
+type SessionExport struct {
+	Version        int                 `json:"version"`
+	Key            string              `json:"key"`
+	Summary        string              `json:"summary,omitempty"`
+	SummaryHistory []SummaryEntry      `json:"summary_history,omitempty"`
+	Model          string              `json:"model,omitempty"`
+	ExecDryRun     bool                `json:"exec_dry_run,omitempty"`
+	Created        time.Time           `json:"created"`
+	Updated        time.Time           `json:"updated"`
+	ExportedAt     time.Time           `json:"exported_at"`
+	Messages       []providers.Message `json:"messages"`
+}
+
+// Export serializes a session into a self-contained JSON document.
+func (sm *SessionManager) Export(sessionKey string) ([]byte, error) {
+	sm.mu.Lock()
+	session, ok := sm.getLocked(sessionKey)
+	if !ok {
+		sm.mu.Unlock()
+		return nil, fmt.Errorf("session %q not found", sessionKey)
+	}
+	doc := SessionExport{
+		Version:        ExportVersion,
+		Key:            session.Key,
+		Summary:        session.Summary,
+		SummaryHistory: append([]SummaryEntry(nil), session.SummaryHistory...),
+		Model:          session.Model,
+		ExecDryRun:     session.ExecDryRun,
+		Created:        session.Created,
+		Updated:        session.Updated,
+		ExportedAt:     time.Now(),
+		Messages:       append([]providers.Message{}, session.Messages...),
+	}
+	sm.mu.Unlock()
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Import recreates a session from a document produced by Export, under the
+// key stored in the document. It returns the key the session was stored as.
+func (sm *SessionManager) Import(data []byte) (string, error) {
+	return sm.ImportAs(data, "")
+}
+
+// ImportAs is like Import but stores the session under key when non-empty.
+// An existing session with the same key is replaced.
+func (sm *SessionManager) ImportAs(data []byte, key string) (string, error) {
+	var doc SessionExport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("invalid session export: %w", err)
+	}
+	if doc.Version <= 0 {
+		return "", fmt.Errorf("invalid session export: missing version")
+	}
+	if doc.Version > ExportVersion {
+		return "", fmt.Errorf("unsupported session export version %d (max %d)", doc.Version, ExportVersion)
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		key = strings.TrimSpace(doc.Key)
+	}
+	if key == "" {
+		return "", fmt.Errorf("invalid session export: missing key")
+	}
+	// The key names the session file, and the document may come from
+	// anywhere: keep it inside the sessions directory.
+	if strings.ContainsAny(key, `/\`) || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid session key %q: must not contain path separators or \"..\"", key)
+	}
+
+	now := time.Now()
+	session := &Session{
+		Key:            key,
+		Messages:       doc.Messages,
+		Summary:        doc.Summary,
+		SummaryHistory: doc.SummaryHistory,
+		Model:          doc.Model,
+		ExecDryRun:     doc.ExecDryRun,
+		Created:        doc.Created,
+		Updated:        doc.Updated,
+	}
+	if session.Messages == nil {
+		session.Messages = []providers.Message{}
+	}
+	if session.Created.IsZero() {
+		session.Created = now
+	}
+	if session.Updated.IsZero() {
+		session.Updated = now
+	}
+
+	sm.mu.Lock()
+	sm.addLocked(session)
+	sm.mu.Unlock()
+
+	if err := sm.Save(session); err != nil {
+		return key, fmt.Errorf("save imported session: %w", err)
+	}
+	return key, nil
+}
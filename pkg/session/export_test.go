@@ -0,0 +1,184 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestExportImport_RoundTripPreservesToolCalls(t *testing.T) {
+	src := NewSessionManager("")
+	src.AddMessage("telegram:1", "user", "list files")
+	src.AddFullMessage("telegram:1", providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: &providers.FunctionCall{Name: "exec", Arguments: `{"command":"ls"}`},
+		}},
+	})
+	src.AddFullMessage("telegram:1", providers.ToolResultMessage("call_1", "a.txt\nb.txt"))
+	src.AddMessage("telegram:1", "assistant", "Two files.")
+	src.SetSummary("telegram:1", "earlier chat")
+
+	data, err := src.Export("telegram:1")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var doc SessionExport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if doc.Version != ExportVersion {
+		t.Fatalf("version = %d, want %d", doc.Version, ExportVersion)
+	}
+
+	dst := NewSessionManager(t.TempDir())
+	key, err := dst.Import(data)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if key != "telegram:1" {
+		t.Fatalf("key = %q, want telegram:1", key)
+	}
+
+	history := dst.GetHistory(key)
+	if len(history) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(history))
+	}
+	if len(history[1].ToolCalls) != 1 || history[1].ToolCalls[0].Function == nil ||
+		history[1].ToolCalls[0].Function.Arguments != `{"command":"ls"}` {
+		t.Fatalf("tool call not preserved: %+v", history[1].ToolCalls)
+	}
+	if history[2].Role != "tool" || history[2].ToolCallID != "call_1" {
+		t.Fatalf("tool result not preserved: %+v", history[2])
+	}
+	if got := dst.GetSummary(key); got != "earlier chat" {
+		t.Fatalf("summary = %q", got)
+	}
+}
+
+func TestImportAs_UsesProvidedKey(t *testing.T) {
+	src := NewSessionManager("")
+	src.AddMessage("a", "user", "hello")
+	data, err := src.Export("a")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := NewSessionManager("")
+	key, err := dst.ImportAs(data, "b")
+	if err != nil {
+		t.Fatalf("ImportAs: %v", err)
+	}
+	if key != "b" {
+		t.Fatalf("key = %q, want b", key)
+	}
+	if len(dst.GetHistory("b")) != 1 {
+		t.Fatalf("expected imported history under b")
+	}
+	if len(dst.GetHistory("a")) != 0 {
+		t.Fatalf("did not expect history under original key")
+	}
+}
+
+func TestExportImport_PreservesExecDryRun(t *testing.T) {
+	src := NewSessionManager("")
+	src.AddMessage("a", "user", "hello")
+	src.SetExecDryRun("a", true)
+	data, err := src.Export("a")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := NewSessionManager("")
+	key, err := dst.ImportAs(data, "b")
+	if err != nil {
+		t.Fatalf("ImportAs: %v", err)
+	}
+	if !dst.GetExecDryRun(key) {
+		t.Fatal("dry-run lost on import")
+	}
+}
+
+func TestExportImport_PreservesModelOverride(t *testing.T) {
+	src := NewSessionManager("")
+	src.AddMessage("a", "user", "hello")
+	src.SetModel("a", "claude-sonnet-4")
+	data, err := src.Export("a")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := NewSessionManager("")
+	key, err := dst.Import(data)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if got := dst.GetModel(key); got != "claude-sonnet-4" {
+		t.Fatalf("model = %q, want claude-sonnet-4", got)
+	}
+}
+
+func TestExportImport_PreservesSummaryHistory(t *testing.T) {
+	src := NewSessionManager("")
+	for i := 0; i < 6; i++ {
+		src.AddMessage("a", "user", "hello")
+	}
+	if err := src.Compact("a", "first summary", 2); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	data, err := src.Export("a")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := NewSessionManager("")
+	key, err := dst.Import(data)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	log := dst.GetSummaryHistory(key)
+	if len(log) != 1 || log[0].Summary != "first summary" || log[0].Messages != 6 {
+		t.Fatalf("summary history = %+v, want the compaction entry", log)
+	}
+}
+
+func TestExport_UnknownSession(t *testing.T) {
+	sm := NewSessionManager("")
+	if _, err := sm.Export("missing"); err == nil {
+		t.Fatal("expected error for unknown session")
+	}
+}
+
+func TestImport_RejectsNewerVersion(t *testing.T) {
+	sm := NewSessionManager("")
+	_, err := sm.Import([]byte(`{"version": 99, "key": "k", "messages": []}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Fatalf("expected unsupported version error, got %v", err)
+	}
+}
+
+func TestImport_RejectsTraversalKey(t *testing.T) {
+	root := t.TempDir()
+	storage := filepath.Join(root, "sessions")
+	sm := NewSessionManager(storage)
+
+	for _, key := range []string{"../../x", `..\x`, "a/b"} {
+		doc, _ := json.Marshal(map[string]interface{}{"version": 1, "key": key, "messages": []interface{}{}})
+		if _, err := sm.Import(doc); err == nil || !strings.Contains(err.Error(), "invalid session key") {
+			t.Fatalf("Import(key=%q) err = %v, want invalid session key", key, err)
+		}
+		if _, err := sm.ImportAs([]byte(`{"version": 1, "key": "ok", "messages": []}`), key); err == nil {
+			t.Fatalf("ImportAs(%q) succeeded, want invalid session key", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "x.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside the sessions dir, stat err = %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package session
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,12 +14,38 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// MaxSummaryHistory caps the compaction log kept per session.
+const MaxSummaryHistory = 20
+
 type Session struct {
 	Key      string              `json:"key"`
 	Messages []providers.Message `json:"messages"`
 	Summary  string              `json:"summary,omitempty"`
-	Created  time.Time           `json:"created"`
-	Updated  time.Time           `json:"updated"`
+	// SummaryHistory is the compaction log: every summary written to the
+	// session, oldest first, capped at MaxSummaryHistory entries.
+	SummaryHistory []SummaryEntry `json:"summary_history,omitempty"`
+	// Model overrides the agent's default model for this session (empty =
+	// default). Set by the /model chat command.
+	Model string `json:"model,omitempty"`
+	// ExecDryRun makes exec describe commands instead of running them in
+	// this session. Set by the /dryrun chat command.
+	ExecDryRun bool      `json:"exec_dry_run,omitempty"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+
+	// lastUsed and useSeq order sessions for eviction; dirty marks changes
+	// not yet written to storage. All are guarded by SessionManager.mu.
+	lastUsed time.Time
+	useSeq   uint64
+	dirty    bool
+}
+
+// SummaryEntry records one compaction: the summary that was written and how
+// many messages the session held right before it was truncated.
+type SummaryEntry struct {
+	Summary  string    `json:"summary"`
+	Messages int       `json:"messages"`
+	Created  time.Time `json:"created"`
 }
 
 type SessionManager struct {
@@ -27,12 +55,24 @@ type SessionManager struct {
 	// transcripts is the directory where append-only JSONL transcripts are stored.
 	// It may be empty to disable transcript persistence.
 	transcripts string
+
+	// maxInMemory and idleTTL bound the sessions kept in memory (0 = no
+	// bound); see SetEviction.
+	maxInMemory int
+	idleTTL     time.Duration
+	lastSweep   time.Time
+	useSeq      uint64
+	now         func() time.Time
 }
 
+// evictionSweepInterval is how often idle sessions are looked for.
+const evictionSweepInterval = time.Minute
+
 func NewSessionManager(storage string) *SessionManager {
 	sm := &SessionManager{
 		sessions: make(map[string]*Session),
 		storage:  storage,
+		now:      time.Now,
 	}
 
 	if storage != "" {
@@ -47,36 +87,144 @@ func NewSessionManager(storage string) *SessionManager {
 	return sm
 }
 
-func (sm *SessionManager) GetOrCreate(key string) *Session {
+// SetEviction bounds the sessions kept in memory. Past maxInMemory, the
+// least recently used sessions are evicted; sessions unused for idleTTL are
+// evicted too. Unsaved changes are written to storage first, and evicted
+// sessions are reloaded from storage when next used. Zero disables either
+// bound. Without storage nothing is evicted.
+func (sm *SessionManager) SetEviction(maxInMemory int, idleTTL time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.maxInMemory = maxInMemory
+	sm.idleTTL = idleTTL
+	sm.lastSweep = time.Time{}
+	sm.evictLocked()
+}
+
+// InMemory reports whether the session is currently held in memory.
+func (sm *SessionManager) InMemory(key string) bool {
 	sm.mu.RLock()
-	session, ok := sm.sessions[key]
-	sm.mu.RUnlock()
+	defer sm.mu.RUnlock()
 
-	if ok {
-		return session
-	}
+	_, ok := sm.sessions[key]
+	return ok
+}
 
+func (sm *SessionManager) GetOrCreate(key string) *Session {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	// Re-check under write lock to avoid duplicate create/overwrite when
-	// multiple goroutines race on first access.
-	if session, ok = sm.sessions[key]; ok {
+	return sm.getOrCreateLocked(key)
+}
+
+// getLocked returns the session for key, reloading it from storage if it
+// was evicted, and marks it used. Callers hold sm.mu for writing.
+func (sm *SessionManager) getLocked(key string) (*Session, bool) {
+	session, ok := sm.sessions[key]
+	if !ok {
+		if session, ok = sm.loadSessionLocked(key); !ok {
+			return nil, false
+		}
+		sm.sessions[key] = session
+	}
+	sm.touchLocked(session)
+	return session, true
+}
+
+// getOrCreateLocked is getLocked, creating an empty session when key is
+// neither in memory nor in storage.
+func (sm *SessionManager) getOrCreateLocked(key string) *Session {
+	if session, ok := sm.getLocked(key); ok {
 		return session
 	}
 
 	now := time.Now()
-	session = &Session{
+	session := &Session{
 		Key:      key,
 		Messages: []providers.Message{},
 		Created:  now,
 		Updated:  now,
 	}
-	sm.sessions[key] = session
-
+	sm.addLocked(session)
 	return session
 }
 
+// addLocked stores session in memory, replacing any session with its key.
+func (sm *SessionManager) addLocked(session *Session) {
+	sm.sessions[session.Key] = session
+	session.dirty = true
+	sm.touchLocked(session)
+}
+
+func (sm *SessionManager) touchLocked(session *Session) {
+	sm.useSeq++
+	session.useSeq = sm.useSeq
+	session.lastUsed = sm.now()
+	sm.evictLocked()
+}
+
+// evictLocked drops sessions idle for longer than idleTTL (checked at most
+// once per evictionSweepInterval) and then the least recently used ones
+// until at most maxInMemory remain. Sessions whose unsaved changes cannot
+// be written are kept.
+func (sm *SessionManager) evictLocked() {
+	if sm.storage == "" || (sm.maxInMemory <= 0 && sm.idleTTL <= 0) {
+		return
+	}
+
+	now := sm.now()
+	if sm.idleTTL > 0 && now.Sub(sm.lastSweep) >= evictionSweepInterval {
+		sm.lastSweep = now
+		for _, session := range sm.sessions {
+			if now.Sub(session.lastUsed) > sm.idleTTL {
+				sm.evictSessionLocked(session)
+			}
+		}
+	}
+
+	if sm.maxInMemory <= 0 || len(sm.sessions) <= sm.maxInMemory {
+		return
+	}
+	lru := make([]*Session, 0, len(sm.sessions))
+	for _, session := range sm.sessions {
+		lru = append(lru, session)
+	}
+	sort.Slice(lru, func(i, j int) bool {
+		if lru[i].useSeq != lru[j].useSeq {
+			return lru[i].useSeq < lru[j].useSeq
+		}
+		return lru[i].lastUsed.Before(lru[j].lastUsed)
+	})
+	for _, session := range lru {
+		if len(sm.sessions) <= sm.maxInMemory {
+			break
+		}
+		sm.evictSessionLocked(session)
+	}
+}
+
+func (sm *SessionManager) evictSessionLocked(session *Session) {
+	if session.dirty {
+		if err := sm.saveLocked(session); err != nil {
+			return
+		}
+	}
+	delete(sm.sessions, session.Key)
+}
+
+// loadSessionLocked reads the session for key from storage.
+func (sm *SessionManager) loadSessionLocked(key string) (*Session, bool) {
+	if sm.storage == "" || key == "" {
+		return nil, false
+	}
+	session, err := readSessionFile(filepath.Join(sm.storage, key+".json"))
+	if err != nil || session.Key != key {
+		return nil, false
+	}
+	return session, true
+}
+
 func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
 	sm.AddFullMessage(sessionKey, providers.Message{
 		Role:    role,
@@ -90,18 +238,10 @@ func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Messag
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[sessionKey]
-	if !ok {
-		session = &Session{
-			Key:      sessionKey,
-			Messages: []providers.Message{},
-			Created:  time.Now(),
-		}
-		sm.sessions[sessionKey] = session
-	}
-
+	session := sm.getOrCreateLocked(sessionKey)
 	session.Messages = append(session.Messages, msg)
 	session.Updated = time.Now()
+	session.dirty = true
 
 	// Best-effort: append to the transcript log. Never fail the main flow.
 	sm.appendTranscriptLocked(sessionKey, msg)
@@ -153,10 +293,10 @@ func (sm *SessionManager) appendTranscriptLocked(sessionKey string, msg provider
 }
 
 func (sm *SessionManager) GetHistory(key string) []providers.Message {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
+	session, ok := sm.getLocked(key)
 	if !ok {
 		return []providers.Message{}
 	}
@@ -167,10 +307,10 @@ func (sm *SessionManager) GetHistory(key string) []providers.Message {
 }
 
 func (sm *SessionManager) GetSummary(key string) string {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
+	session, ok := sm.getLocked(key)
 	if !ok {
 		return ""
 	}
@@ -181,22 +321,129 @@ func (sm *SessionManager) SetSummary(key string, summary string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if ok {
-		session.Summary = summary
-		session.Updated = time.Now()
+	if session, ok := sm.getLocked(key); ok {
+		setSummaryLocked(session, summary)
 	}
 }
 
-func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
+func setSummaryLocked(session *Session, summary string) {
+	now := time.Now()
+	session.Summary = summary
+	session.Updated = now
+	session.dirty = true
+	if strings.TrimSpace(summary) != "" {
+		session.SummaryHistory = append(session.SummaryHistory, SummaryEntry{
+			Summary:  summary,
+			Messages: len(session.Messages),
+			Created:  now,
+		})
+		if n := len(session.SummaryHistory); n > MaxSummaryHistory {
+			session.SummaryHistory = append([]SummaryEntry(nil), session.SummaryHistory[n-MaxSummaryHistory:]...)
+		}
+	}
+}
+
+// Compact replaces everything but the last keepLast messages with summary and
+// persists the result. The summary, the truncation and the write happen under
+// one lock, and the write is atomic, so neither readers nor the file on disk
+// ever see a new summary next to the old history or the other way round.
+func (sm *SessionManager) Compact(key, summary string, keepLast int) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
+	session, ok := sm.getLocked(key)
+	if !ok {
+		return nil
+	}
+	setSummaryLocked(session, summary)
+	truncateHistoryLocked(session, keepLast)
+	return sm.saveLocked(session)
+}
+
+// GetSummaryHistory returns a copy of the session's compaction log, oldest
+// first. Use it to find which compaction dropped a detail from the summary.
+func (sm *SessionManager) GetSummaryHistory(key string) []SummaryEntry {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.getLocked(key)
+	if !ok {
+		return nil
+	}
+	return append([]SummaryEntry(nil), session.SummaryHistory...)
+}
+
+// GetModel returns the session's model override, or "" for the default.
+func (sm *SessionManager) GetModel(key string) string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.getLocked(key)
+	if !ok {
+		return ""
+	}
+	return session.Model
+}
+
+// SetModel sets the session's model override, creating the session if
+// needed. An empty model restores the default.
+func (sm *SessionManager) SetModel(key, model string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session := sm.getOrCreateLocked(key)
+	session.Model = strings.TrimSpace(model)
+	session.Updated = time.Now()
+	session.dirty = true
+}
+
+// GetExecDryRun reports whether exec dry-run is on for the session.
+func (sm *SessionManager) GetExecDryRun(key string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.getLocked(key)
+	return ok && session.ExecDryRun
+}
+
+// SetExecDryRun turns exec dry-run on or off for the session, creating the
+// session if needed.
+func (sm *SessionManager) SetExecDryRun(key string, dryRun bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session := sm.getOrCreateLocked(key)
+	session.ExecDryRun = dryRun
+	session.Updated = time.Now()
+	session.dirty = true
+}
+
+// Reset clears the session's history and summary. The model override and
+// compaction log are kept.
+func (sm *SessionManager) Reset(key string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.getLocked(key)
 	if !ok {
 		return
 	}
+	session.Messages = []providers.Message{}
+	session.Summary = ""
+	session.Updated = time.Now()
+	session.dirty = true
+}
+
+func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, ok := sm.getLocked(key); ok {
+		truncateHistoryLocked(session, keepLast)
+	}
+}
 
+func truncateHistoryLocked(session *Session, keepLast int) {
 	if len(session.Messages) <= keepLast {
 		return
 	}
@@ -205,13 +452,33 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sanitized, _ := providers.SanitizeToolTranscript(truncated)
 	session.Messages = sanitized
 	session.Updated = time.Now()
+	session.dirty = true
+}
+
+// SafeKeepCount returns how many trailing messages to keep so the kept tail
+// holds at least keepLast messages and never starts on a tool result. The
+// boundary walks backward past tool results to the assistant message that
+// issued the calls, keeping each tool-call/result group intact.
+func SafeKeepCount(messages []providers.Message, keepLast int) int {
+	if keepLast <= 0 {
+		return 0
+	}
+	if keepLast >= len(messages) {
+		return len(messages)
+	}
+
+	start := len(messages) - keepLast
+	for start > 0 && messages[start].Role == "tool" {
+		start--
+	}
+	return len(messages) - start
 }
 
 func (sm *SessionManager) TrimHistoryTo(key string, length int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
+	session, ok := sm.getLocked(key)
 	if !ok {
 		return
 	}
@@ -225,38 +492,121 @@ func (sm *SessionManager) TrimHistoryTo(key string, length int) {
 	trimmed := append([]providers.Message(nil), session.Messages[:length]...)
 	session.Messages = trimmed
 	session.Updated = time.Now()
+	session.dirty = true
 }
 
 func (sm *SessionManager) ReplaceHistory(key string, messages []providers.Message) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
-		now := time.Now()
-		session = &Session{
-			Key:      key,
-			Messages: []providers.Message{},
-			Created:  now,
-			Updated:  now,
-		}
-		sm.sessions[key] = session
-	}
-
+	session := sm.getOrCreateLocked(key)
 	history := append([]providers.Message(nil), messages...)
 	sanitized, _ := providers.SanitizeToolTranscript(history)
 	session.Messages = sanitized
 	session.Updated = time.Now()
+	session.dirty = true
 }
 
-func (sm *SessionManager) Save(session *Session) error {
-	if sm.storage == "" {
-		return nil
+// Fork copies the messages and summary of srcKey into a new session newKey
+// and persists it. The copy is deep, so later changes to either session never
+// show up in the other. newKey must not already exist.
+func (sm *SessionManager) Fork(srcKey, newKey string) error {
+	if strings.TrimSpace(newKey) == "" {
+		return fmt.Errorf("fork session key is required")
+	}
+	if srcKey == newKey {
+		return fmt.Errorf("fork session key must differ from source")
+	}
+
+	sm.mu.Lock()
+	src, ok := sm.getLocked(srcKey)
+	if !ok {
+		sm.mu.Unlock()
+		return fmt.Errorf("session %q not found", srcKey)
+	}
+	if _, exists := sm.getLocked(newKey); exists {
+		sm.mu.Unlock()
+		return fmt.Errorf("session %q already exists", newKey)
+	}
+
+	now := time.Now()
+	fork := &Session{
+		Key:      newKey,
+		Messages: cloneMessages(src.Messages),
+		Summary:  src.Summary,
+		Created:  now,
+		Updated:  now,
+	}
+	fork.SummaryHistory = append([]SummaryEntry(nil), src.SummaryHistory...)
+	fork.Model = src.Model
+	fork.ExecDryRun = src.ExecDryRun
+	sm.addLocked(fork)
+	sm.mu.Unlock()
+
+	return sm.Save(fork)
+}
+
+func cloneMessages(messages []providers.Message) []providers.Message {
+	out := make([]providers.Message, len(messages))
+	for i, msg := range messages {
+		out[i] = msg
+		if msg.Parts != nil {
+			out[i].Parts = append([]providers.MessagePart(nil), msg.Parts...)
+		}
+		if msg.ToolCalls != nil {
+			calls := make([]providers.ToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				calls[j] = tc
+				if tc.Function != nil {
+					fn := *tc.Function
+					calls[j].Function = &fn
+				}
+				if tc.Arguments != nil {
+					calls[j].Arguments = cloneValue(tc.Arguments).(map[string]interface{})
+				}
+			}
+			out[i].ToolCalls = calls
+		}
+	}
+	return out
+}
+
+// cloneValue deep-copies the JSON-shaped values found in tool arguments.
+func cloneValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = cloneValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = cloneValue(item)
+		}
+		return items
+	default:
+		return val
 	}
+}
 
+func (sm *SessionManager) Save(session *Session) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	return sm.saveLocked(session)
+}
+
+// writeSessionFile persists a session file. Tests swap it to simulate a
+// crash mid-write.
+var writeSessionFile = utils.AtomicWriteFile
+
+func (sm *SessionManager) saveLocked(session *Session) error {
+	if sm.storage == "" {
+		return nil
+	}
+
 	sessionPath := filepath.Join(sm.storage, session.Key+".json")
 
 	data, err := json.MarshalIndent(session, "", "  ")
@@ -264,7 +614,11 @@ func (sm *SessionManager) Save(session *Session) error {
 		return err
 	}
 
-	return utils.AtomicWriteFile(sessionPath, data, 0644)
+	if err := writeSessionFile(sessionPath, data, 0644); err != nil {
+		return err
+	}
+	session.dirty = false
+	return nil
 }
 
 func (sm *SessionManager) loadSessions() error {
@@ -282,19 +636,26 @@ func (sm *SessionManager) loadSessions() error {
 			continue
 		}
 
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
+		session, err := readSessionFile(filepath.Join(sm.storage, file.Name()))
 		if err != nil {
 			continue
 		}
+		session.lastUsed = session.Updated
+		sm.sessions[session.Key] = session
+	}
 
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
+	return nil
+}
 
-		sm.sessions[session.Key] = &session
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
 }
@@ -1,6 +1,11 @@
 package session
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -149,6 +154,89 @@ func TestSetSummary_NonexistentKey(t *testing.T) {
 	sm.SetSummary("nonexistent", "some summary")
 }
 
+func TestSummaryHistory_RecordsEachCompactionInOrder(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+
+	// Two summarize cycles: write the summary, then truncate and save.
+	for i, summary := range []string{"first compaction", "second compaction"} {
+		for j := 0; j < 4; j++ {
+			sm.AddMessage("chat", "user", "msg")
+		}
+		sm.SetSummary("chat", summary)
+		sm.TruncateHistory("chat", 1)
+		if err := sm.Save(sm.GetOrCreate("chat")); err != nil {
+			t.Fatalf("Save cycle %d: %v", i, err)
+		}
+	}
+
+	reloaded := NewSessionManager(dir)
+	history := reloaded.GetSummaryHistory("chat")
+	if len(history) != 2 {
+		t.Fatalf("expected 2 summary log entries, got %d", len(history))
+	}
+	if history[0].Summary != "first compaction" || history[1].Summary != "second compaction" {
+		t.Fatalf("unexpected summary order: %q, %q", history[0].Summary, history[1].Summary)
+	}
+	if history[0].Messages != 4 || history[1].Messages != 5 {
+		t.Fatalf("expected pre-compaction message counts 4 and 5, got %d and %d", history[0].Messages, history[1].Messages)
+	}
+	if history[1].Created.Before(history[0].Created) {
+		t.Fatal("summary log timestamps out of order")
+	}
+	if got := reloaded.GetSummary("chat"); got != "second compaction" {
+		t.Fatalf("current summary = %q", got)
+	}
+}
+
+func TestSummaryHistory_CappedAndCopied(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.GetOrCreate("key")
+	for i := 0; i < MaxSummaryHistory+5; i++ {
+		sm.SetSummary("key", fmt.Sprintf("summary %d", i))
+	}
+	sm.SetSummary("key", "")
+
+	history := sm.GetSummaryHistory("key")
+	if len(history) != MaxSummaryHistory {
+		t.Fatalf("expected %d entries, got %d", MaxSummaryHistory, len(history))
+	}
+	if history[0].Summary != "summary 5" || history[len(history)-1].Summary != fmt.Sprintf("summary %d", MaxSummaryHistory+4) {
+		t.Fatalf("expected oldest entries dropped, got first=%q last=%q", history[0].Summary, history[len(history)-1].Summary)
+	}
+
+	history[0].Summary = "mutated"
+	if sm.GetSummaryHistory("key")[0].Summary == "mutated" {
+		t.Fatal("GetSummaryHistory should return a copy")
+	}
+	if sm.GetSummaryHistory("missing") != nil {
+		t.Fatal("expected nil history for unknown session")
+	}
+}
+
+func TestSetModel_PersistsAndSurvivesReset(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	sm.AddMessage("chat", "user", "hello")
+	sm.SetSummary("chat", "greeting")
+	sm.SetModel("chat", " gpt-4o ")
+	sm.Reset("chat")
+	if err := sm.Save(sm.GetOrCreate("chat")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded := NewSessionManager(dir)
+	if got := reloaded.GetModel("chat"); got != "gpt-4o" {
+		t.Fatalf("model = %q, want gpt-4o", got)
+	}
+	if len(reloaded.GetHistory("chat")) != 0 || reloaded.GetSummary("chat") != "" {
+		t.Fatal("expected Reset to clear history and summary")
+	}
+	if len(reloaded.GetSummaryHistory("chat")) != 1 {
+		t.Fatal("expected Reset to keep the compaction log")
+	}
+}
+
 func TestTruncateHistory(t *testing.T) {
 	sm := NewSessionManager("")
 	for i := 0; i < 10; i++ {
@@ -248,6 +336,71 @@ func TestSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestFork_IsIndependentDeepCopy(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	sm.AddMessage("src", "user", "list files")
+	sm.AddFullMessage("src", providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      "exec",
+			Function:  &providers.FunctionCall{Name: "exec", Arguments: `{"command":"ls"}`},
+			Arguments: map[string]interface{}{"command": "ls", "env": map[string]interface{}{"A": "1"}},
+		}},
+	})
+	sm.SetSummary("src", "earlier context")
+
+	if err := sm.Fork("src", "fork"); err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+
+	sm.AddMessage("fork", "user", "try another direction")
+	sm.SetSummary("fork", "fork summary")
+	forked := sm.GetOrCreate("fork")
+	forked.Messages[1].ToolCalls[0].Arguments["env"].(map[string]interface{})["A"] = "changed"
+	forked.Messages[1].ToolCalls[0].Function.Arguments = "{}"
+	if err := sm.Save(forked); err != nil {
+		t.Fatalf("Save fork failed: %v", err)
+	}
+
+	src := sm.GetHistory("src")
+	if len(src) != 2 {
+		t.Fatalf("source history changed: %d messages", len(src))
+	}
+	if sm.GetSummary("src") != "earlier context" {
+		t.Fatalf("source summary changed: %q", sm.GetSummary("src"))
+	}
+	tc := src[1].ToolCalls[0]
+	if tc.Arguments["env"].(map[string]interface{})["A"] != "1" || tc.Function.Arguments != `{"command":"ls"}` {
+		t.Fatalf("source tool call mutated through fork: %+v", tc)
+	}
+
+	reloaded := NewSessionManager(dir)
+	if got := reloaded.GetHistory("fork"); len(got) != 3 {
+		t.Fatalf("expected persisted fork with 3 messages, got %d", len(got))
+	}
+}
+
+func TestFork_Errors(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("src", "user", "hi")
+	sm.AddMessage("taken", "user", "hi")
+
+	if err := sm.Fork("missing", "new"); err == nil {
+		t.Fatal("expected error for missing source")
+	}
+	if err := sm.Fork("src", "taken"); err == nil {
+		t.Fatal("expected error when target exists")
+	}
+	if err := sm.Fork("src", "src"); err == nil {
+		t.Fatal("expected error when target equals source")
+	}
+	if err := sm.Fork("src", " "); err == nil {
+		t.Fatal("expected error for empty target key")
+	}
+}
+
 func TestSave_NoStorage(t *testing.T) {
 	sm := NewSessionManager("")
 	sm.AddMessage("key", "user", "hello")
@@ -322,3 +475,145 @@ func TestGetOrCreate_ConcurrentSameKey_SingleInstance(t *testing.T) {
 		}
 	}
 }
+
+func TestSafeKeepCount(t *testing.T) {
+	toolCall := providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "c1", Name: "exec"}, {ID: "c2", Name: "exec"}},
+	}
+	msgs := []providers.Message{
+		{Role: "user", Content: "u1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "u2"},
+		toolCall,
+		{Role: "tool", Content: "r1", ToolCallID: "c1"},
+		{Role: "tool", Content: "r2", ToolCallID: "c2"},
+		{Role: "assistant", Content: "done"},
+	}
+
+	tests := []struct {
+		name     string
+		messages []providers.Message
+		keepLast int
+		want     int
+	}{
+		{"boundary on plain message", msgs, 1, 1},
+		{"boundary on assistant with tool calls", msgs, 4, 4},
+		{"boundary on first tool result", msgs, 3, 4},
+		{"boundary on second tool result", msgs, 2, 4},
+		{"last assistant has tool calls", msgs[:6], 2, 3},
+		{"keep more than exists", msgs, 10, len(msgs)},
+		{"zero keep", msgs, 0, 0},
+		{"all tool results walk to start", []providers.Message{{Role: "tool"}, {Role: "tool"}}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeKeepCount(tt.messages, tt.keepLast); got != tt.want {
+				t.Fatalf("SafeKeepCount(%d) = %d, want %d", tt.keepLast, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompact_SummaryAndTruncationPersistTogether(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	for i := 0; i < 6; i++ {
+		sm.AddMessage("chat-1", "user", fmt.Sprintf("msg-%d", i))
+	}
+	if err := sm.Save(sm.GetOrCreate("chat-1")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Every snapshot written while compacting, including ones from a
+	// concurrent Save, must be either fully before or fully after.
+	var mu sync.Mutex
+	var snapshots [][]byte
+	orig := writeSessionFile
+	writeSessionFile = func(path string, data []byte, perm os.FileMode) error {
+		mu.Lock()
+		snapshots = append(snapshots, append([]byte(nil), data...))
+		mu.Unlock()
+		return orig(path, data, perm)
+	}
+	defer func() { writeSessionFile = orig }()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = sm.Save(sm.GetOrCreate("chat-1"))
+			}
+		}
+	}()
+	if err := sm.Compact("chat-1", "six messages", 2); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	close(stop)
+	wg.Wait()
+
+	for i, data := range snapshots {
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		if (s.Summary != "") != (len(s.Messages) == 2) {
+			t.Fatalf("snapshot %d is half-compacted: summary=%q, %d messages", i, s.Summary, len(s.Messages))
+		}
+	}
+
+	reloaded := NewSessionManager(dir)
+	if got := reloaded.GetSummary("chat-1"); got != "six messages" {
+		t.Errorf("summary after reload = %q", got)
+	}
+	history := reloaded.GetHistory("chat-1")
+	if len(history) != 2 || history[0].Content != "msg-4" {
+		t.Errorf("history after reload = %+v", history)
+	}
+	if entries := reloaded.GetSummaryHistory("chat-1"); len(entries) != 1 || entries[0].Messages != 6 {
+		t.Errorf("summary history after reload = %+v", entries)
+	}
+}
+
+func TestCompact_CrashDuringWriteKeepsPreviousSession(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	for i := 0; i < 4; i++ {
+		sm.AddMessage("chat-1", "user", fmt.Sprintf("msg-%d", i))
+	}
+	if err := sm.Save(sm.GetOrCreate("chat-1")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Simulate the process dying mid-write: half the new file lands in a
+	// temp file and the rename never happens.
+	orig := writeSessionFile
+	writeSessionFile = func(path string, data []byte, perm os.FileMode) error {
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+		if err != nil {
+			return err
+		}
+		tmp.Write(data[:len(data)/2])
+		tmp.Close()
+		return errors.New("crashed")
+	}
+	defer func() { writeSessionFile = orig }()
+
+	if err := sm.Compact("chat-1", "four messages", 1); err == nil {
+		t.Fatal("expected the simulated crash to surface as an error")
+	}
+
+	reloaded := NewSessionManager(dir)
+	if got := reloaded.GetSummary("chat-1"); got != "" {
+		t.Errorf("summary after crash = %q, want none", got)
+	}
+	if history := reloaded.GetHistory("chat-1"); len(history) != 4 {
+		t.Errorf("expected the 4 original messages after crash, got %d", len(history))
+	}
+}
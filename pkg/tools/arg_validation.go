@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -40,12 +42,39 @@ var toolSpecificArgAliases = map[string]map[string]string{
 	"memory_store": {
 		"text": "content",
 	},
+	"memory_update": {
+		"text": "content",
+	},
 	"subagent_report": {
 		"text": "content",
 	},
 }
 
-func normalizeAndValidateToolArgs(tool Tool, args map[string]interface{}) (map[string]interface{}, error) {
+// InvalidArgumentsError reports every way a tool call's arguments break the
+// tool's declared schema: missing required fields, wrong types and values
+// outside an enum. Fields the schema does not declare are not errors.
+type InvalidArgumentsError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *InvalidArgumentsError) Error() string {
+	return fmt.Sprintf("Invalid arguments for %s: %s. Supply correct parameters before retrying.",
+		e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// Structured is the machine-readable tool result for the model.
+func (e *InvalidArgumentsError) Structured() map[string]interface{} {
+	return map[string]interface{}{
+		"error":    "invalid_arguments",
+		"tool":     e.Tool,
+		"problems": e.Problems,
+	}
+}
+
+// normalizeToolArgs applies argument aliases and type coercion, and returns
+// the schema problems that remain.
+func normalizeToolArgs(tool Tool, args map[string]interface{}) (map[string]interface{}, []string) {
 	schema := tool.Parameters()
 	properties := extractSchemaProperties(schema)
 	required := extractSchemaRequired(schema)
@@ -54,19 +83,17 @@ func normalizeAndValidateToolArgs(tool Tool, args map[string]interface{}) (map[s
 	applyConfiguredAliases(tool.Name(), normalized)
 	applyPropertyNameAliases(properties, normalized)
 
-	if err := coerceArgsToSchemaTypes(properties, normalized); err != nil {
-		return nil, err
-	}
-
+	var problems []string
 	if missing := findMissingRequired(required, normalized); len(missing) > 0 {
 		noun := "parameter"
 		if len(missing) > 1 {
 			noun = "parameters"
 		}
-		return nil, fmt.Errorf("Missing required %s: %s. Supply correct parameters before retrying.", noun, strings.Join(missing, ", "))
+		problems = append(problems, fmt.Sprintf("Missing required %s: %s", noun, strings.Join(missing, ", ")))
 	}
+	problems = append(problems, checkArgsAgainstSchema(properties, normalized)...)
 
-	return normalized, nil
+	return normalized, problems
 }
 
 func copyArgs(args map[string]interface{}) map[string]interface{} {
@@ -235,38 +262,113 @@ func camelToSnake(s string) string {
 	return strings.ToLower(b.String())
 }
 
-func coerceArgsToSchemaTypes(properties map[string]interface{}, args map[string]interface{}) error {
+// checkArgsAgainstSchema converts declared arguments to their schema type
+// where that is lossless and checks them against their enum, returning one
+// problem per argument that does not fit.
+func checkArgsAgainstSchema(properties map[string]interface{}, args map[string]interface{}) []string {
 	if len(properties) == 0 || len(args) == 0 {
 		return nil
 	}
 
-	for key, value := range args {
-		propertyRaw, ok := properties[key]
-		if !ok {
-			continue
-		}
-		property, ok := propertyRaw.(map[string]interface{})
+	var problems []string
+	for _, key := range sortedArgKeys(args) {
+		property, ok := properties[key].(map[string]interface{})
 		if !ok {
 			continue
 		}
 		typeName, _ := property["type"].(string)
 		typeName = strings.TrimSpace(typeName)
-		if typeName == "" {
-			continue
+		if typeName != "" {
+			coerced, changed, err := coerceArgValue(args[key], typeName)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("Invalid parameter '%s': expected %s", key, typeName))
+				continue
+			}
+			if changed {
+				args[key] = coerced
+			}
 		}
+		if problem := checkArgEnum(key, property, args); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
 
-		coerced, changed, err := coerceArgValue(value, typeName)
-		if err != nil {
-			return fmt.Errorf("Invalid parameter '%s': expected %s. Supply correct parameters before retrying.", key, typeName)
+	return problems
+}
+
+// checkArgEnum checks one argument (or its array items) against the
+// property's enum. A case-insensitive match is rewritten to the declared
+// spelling.
+func checkArgEnum(key string, property map[string]interface{}, args map[string]interface{}) string {
+	if s, ok := args[key].(string); ok && strings.TrimSpace(s) == "" {
+		return "" // an empty optional value means "use the default"
+	}
+	if allowed := schemaEnum(property); len(allowed) > 0 {
+		value, ok := matchEnum(args[key], allowed)
+		if !ok {
+			return fmt.Sprintf("Invalid parameter '%s': must be one of %s (got %v)", key, strings.Join(allowed, ", "), args[key])
 		}
-		if changed {
-			args[key] = coerced
+		args[key] = value
+		return ""
+	}
+	items, _ := property["items"].(map[string]interface{})
+	list, isList := args[key].([]interface{})
+	allowed := schemaEnum(items)
+	if !isList || len(allowed) == 0 {
+		return ""
+	}
+	matched := make([]interface{}, len(list))
+	for i, item := range list {
+		value, ok := matchEnum(item, allowed)
+		if !ok {
+			return fmt.Sprintf("Invalid parameter '%s': item %v must be one of %s", key, item, strings.Join(allowed, ", "))
 		}
+		matched[i] = value
 	}
+	args[key] = matched
+	return ""
+}
 
+// schemaEnum returns a property's enum values as strings, or nil.
+func schemaEnum(property map[string]interface{}) []string {
+	switch enum := property["enum"].(type) {
+	case []string:
+		return enum
+	case []interface{}:
+		out := make([]string, 0, len(enum))
+		for _, v := range enum {
+			out = append(out, fmt.Sprint(v))
+		}
+		return out
+	}
 	return nil
 }
 
+// matchEnum returns the declared enum value matching value. Strings match
+// case-insensitively; other values match on their printed form.
+func matchEnum(value interface{}, allowed []string) (interface{}, bool) {
+	s, isString := value.(string)
+	for _, a := range allowed {
+		if isString {
+			if strings.EqualFold(strings.TrimSpace(s), a) {
+				return a, true
+			}
+		} else if fmt.Sprint(value) == a {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func sortedArgKeys(args map[string]interface{}) []string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func coerceArgValue(value interface{}, typeName string) (interface{}, bool, error) {
 	switch typeName {
 	case "string":
@@ -335,6 +437,19 @@ func coerceArgValue(value interface{}, typeName string) (interface{}, bool, erro
 		default:
 			return nil, false, fmt.Errorf("unsupported boolean coercion")
 		}
+	case "object":
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return v, false, nil
+		case string:
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(v), &obj); err != nil || obj == nil {
+				return nil, false, fmt.Errorf("invalid object string")
+			}
+			return obj, true, nil
+		default:
+			return nil, false, fmt.Errorf("unsupported object coercion")
+		}
 	case "array":
 		switch v := value.(type) {
 		case []interface{}:
@@ -2,10 +2,13 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 type coercionCaptureTool struct {
@@ -66,7 +69,7 @@ func TestToolRegistry_NormalizesMessageAliases(t *testing.T) {
 	gotChannel := ""
 	gotChatID := ""
 	gotContent := ""
-	messageTool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	messageTool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		gotChannel = channel
 		gotChatID = chatID
 		gotContent = content
@@ -160,3 +163,123 @@ func TestToolRegistry_NormalizesReadFileAliases(t *testing.T) {
 		t.Fatalf("result = %q, want line 2", result)
 	}
 }
+
+type enumProbeTool struct {
+	lastArgs map[string]interface{}
+}
+
+func (t *enumProbeTool) Name() string        { return "enum_probe" }
+func (t *enumProbeTool) Description() string { return "test tool for schema validation" }
+func (t *enumProbeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{"type": "string"},
+			"mode":  map[string]interface{}{"type": "string", "enum": []string{"prefix", "exact"}},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string", "enum": []interface{}{"a", "b"}},
+			},
+			"filter": map[string]interface{}{"type": "object"},
+		},
+		"required": []string{"query", "mode"},
+	}
+}
+
+func (t *enumProbeTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	t.lastArgs = args
+	return "ok", nil
+}
+
+func TestToolRegistry_ValidCallPassesThroughWithExtraFields(t *testing.T) {
+	registry := NewToolRegistry()
+	probe := &enumProbeTool{}
+	registry.Register(probe)
+
+	result, err := registry.ExecuteWithContext(context.Background(), "enum_probe", map[string]interface{}{
+		"query":  "dark mode",
+		"mode":   "Exact",
+		"tags":   []interface{}{"a", "B"},
+		"filter": `{"category":"note"}`,
+		"extra":  "ignored by the schema",
+	}, "", "")
+	if err != nil || result != "ok" {
+		t.Fatalf("ExecuteWithContext() = %q, %v; want ok", result, err)
+	}
+	if probe.lastArgs["mode"] != "exact" {
+		t.Fatalf("mode = %#v, want the declared enum spelling", probe.lastArgs["mode"])
+	}
+	if tags, _ := probe.lastArgs["tags"].([]interface{}); len(tags) != 2 || tags[1] != "b" {
+		t.Fatalf("tags = %#v, want [a b]", probe.lastArgs["tags"])
+	}
+	if filter, _ := probe.lastArgs["filter"].(map[string]interface{}); filter["category"] != "note" {
+		t.Fatalf("filter = %#v, want decoded object", probe.lastArgs["filter"])
+	}
+	if probe.lastArgs["extra"] != "ignored by the schema" {
+		t.Fatal("expected undeclared fields to be passed through")
+	}
+}
+
+func TestToolRegistry_InvalidArgumentsListsEveryProblem(t *testing.T) {
+	registry := NewToolRegistry()
+	probe := &enumProbeTool{}
+	registry.Register(probe)
+
+	_, err := registry.ExecuteWithContext(context.Background(), "enum_probe", map[string]interface{}{
+		"mode":   "fuzzy",
+		"tags":   []interface{}{"c"},
+		"filter": 3,
+	}, "", "")
+	var argErr *InvalidArgumentsError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected InvalidArgumentsError, got %v", err)
+	}
+	for _, want := range []string{
+		"Invalid arguments for enum_probe",
+		"Missing required parameter: query",
+		"Invalid parameter 'mode': must be one of prefix, exact (got fuzzy)",
+		"Invalid parameter 'tags': item c must be one of a, b",
+		"Invalid parameter 'filter': expected object",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if probe.lastArgs != nil {
+		t.Fatal("tool ran despite invalid arguments")
+	}
+}
+
+func TestExecuteToolCalls_InvalidArgumentsReturnStructuredResult(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&enumProbeTool{})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "enum_probe", Arguments: map[string]interface{}{"mode": "prefix"}},
+	}, ExecuteToolCallsOptions{MaxParallel: 1})
+
+	content := results[0].Content
+	if !strings.HasPrefix(content, "Error: Invalid arguments for enum_probe: Missing required parameter: query") {
+		t.Fatalf("unexpected content:\n%s", content)
+	}
+	if !strings.Contains(content, `"error":"invalid_arguments"`) || !strings.Contains(content, `"problems":["Missing required parameter: query"]`) {
+		t.Fatalf("expected structured invalid_arguments payload:\n%s", content)
+	}
+}
+
+func TestToolRegistry_ArgValidationDisabledPassesThrough(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.SetArgValidation(false)
+	probe := &enumProbeTool{}
+	registry.Register(probe)
+
+	result, err := registry.ExecuteWithContext(context.Background(), "enum_probe", map[string]interface{}{
+		"mode": "fuzzy",
+	}, "", "")
+	if err != nil || result != "ok" {
+		t.Fatalf("ExecuteWithContext() = %q, %v; want the tool to run", result, err)
+	}
+	if probe.lastArgs["mode"] != "fuzzy" {
+		t.Fatalf("mode = %#v, want the original value", probe.lastArgs["mode"])
+	}
+}
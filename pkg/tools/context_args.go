@@ -5,6 +5,7 @@ const (
 	execContextChatIDKey  = "__context_chat_id"
 	execContextTraceIDKey = "__context_trace_id"
 	execContextSessionKey = "__context_session_key"
+	execContextMessageID  = "__context_message_id"
 )
 
 func withExecutionContext(args map[string]interface{}, channel, chatID, traceID string) map[string]interface{} {
@@ -58,3 +59,10 @@ func getExecutionSessionKey(args map[string]interface{}) string {
 	sessionKey, _ := args[execContextSessionKey].(string)
 	return sessionKey
 }
+
+// getExecutionMessageID returns the ID of the inbound message the turn is
+// answering, or "" when there is none (cron, heartbeat, CLI).
+func getExecutionMessageID(args map[string]interface{}) string {
+	messageID, _ := args[execContextMessageID].(string)
+	return messageID
+}
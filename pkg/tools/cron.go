@@ -11,9 +11,10 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-// JobExecutor is the interface for executing cron jobs through the agent
+// JobExecutor is the interface for executing cron jobs through the agent.
+// systemContext is added to the system prompt of that turn only.
 type JobExecutor interface {
-	ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error)
+	ProcessDirectWithSystemContext(ctx context.Context, content, systemContext, sessionKey, channel, chatID string) (string, error)
 }
 
 // CronTool provides scheduling capabilities for the agent
@@ -44,7 +45,7 @@ func (t *CronTool) Name() string {
 
 // Description returns the tool description
 func (t *CronTool) Description() string {
-	return "Schedule reminders and tasks. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Reminder delivery is processed by the agent, and user-visible output must be sent via the message tool. By default, cron jobs target the most recently active chat (last channel/chat used). To pin delivery to a specific channel/chat, set both 'channel' and 'chat_id'."
+	return "Schedule reminders and tasks. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Reminder delivery is processed by the agent, and user-visible output must be sent via the message tool. By default, cron jobs target the most recently active chat (last channel/chat used). To pin delivery to a specific channel/chat, set both 'channel' and 'chat_id'. For a plain one-time reminder text in the current chat, the remind tool is simpler."
 }
 
 // Parameters returns the tool parameters schema
@@ -54,8 +55,8 @@ func (t *CronTool) Parameters() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"action": map[string]interface{}{
 				"type":        "string",
-				"enum":        []string{"add", "list", "remove", "enable", "disable"},
-				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task.",
+				"enum":        []string{"add", "list", "remove", "enable", "disable", "run_now", "pause_all", "resume_all"},
+				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task. 'run_now' executes a job immediately (e.g. to test it) without changing its schedule. 'pause_all'/'resume_all' halt or restart every job without deleting them.",
 			},
 			"message": map[string]interface{}{
 				"type":        "string",
@@ -75,7 +76,7 @@ func (t *CronTool) Parameters() map[string]interface{} {
 			},
 			"job_id": map[string]interface{}{
 				"type":        "string",
-				"description": "Job ID (for remove/enable/disable)",
+				"description": "Job ID (for remove/enable/disable/run_now)",
 			},
 			"deliver": map[string]interface{}{
 				"type":        "boolean",
@@ -89,6 +90,14 @@ func (t *CronTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Optional: target chat/user ID override for the job",
 			},
+			"session_key": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: run the job in the current chat's session (e.g. to continue this conversation thread) instead of its own cron session. Only the current session key is accepted, and the job is pinned to the current chat.",
+			},
+			"prompt_prefix": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional: instructions or persona added to the system prompt on every run",
+			},
 		},
 		"required": []string{"action"},
 	}
@@ -112,6 +121,12 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		return t.enableJob(args, true)
 	case "disable":
 		return t.enableJob(args, false)
+	case "run_now":
+		return t.runJobNow(ctx, args)
+	case "pause_all":
+		return t.setPaused(true)
+	case "resume_all":
+		return t.setPaused(false)
 	default:
 		return "", fmt.Errorf("unknown action: %s", action)
 	}
@@ -182,14 +197,31 @@ func (t *CronTool) addJob(args map[string]interface{}) (string, error) {
 	// Truncate message for job name (max 30 chars)
 	messagePreview := utils.Truncate(message, 30)
 
-	job, err := t.cronService.AddJob(
-		messagePreview,
-		schedule,
-		message,
-		deliver,
-		channel,
-		chatID,
-	)
+	// A job may only continue the session it was created from, and then
+	// runs and delivers in that session's chat.
+	sessionKey, _ := args["session_key"].(string)
+	sessionKey = strings.TrimSpace(sessionKey)
+	if sessionKey != "" {
+		if sessionKey != getExecutionSessionKey(args) {
+			return "Error: session_key must be the current chat's session key", nil
+		}
+		if channel == "" || chatID == "" {
+			channel, chatID = getExecutionContext(args)
+		}
+		if !sessionKeyBelongsToChat(sessionKey, channel, chatID) {
+			return "Error: session_key does not belong to the job's channel and chat_id", nil
+		}
+	}
+	promptPrefix, _ := args["prompt_prefix"].(string)
+
+	job, err := t.cronService.AddAgentJob(messagePreview, schedule, cron.CronPayload{
+		Message:      message,
+		Deliver:      deliver,
+		Channel:      channel,
+		To:           chatID,
+		SessionKey:   sessionKey,
+		PromptPrefix: promptPrefix,
+	})
 	if err != nil {
 		return fmt.Sprintf("Error adding job: %v", err), nil
 	}
@@ -197,6 +229,35 @@ func (t *CronTool) addJob(args map[string]interface{}) (string, error) {
 	return fmt.Sprintf("Created job '%s' (id: %s)", job.Name, job.ID), nil
 }
 
+// sessionKeyBelongsToChat reports whether sessionKey is the session of the
+// chat, or a sender's own session within it ("channel:chat:sender").
+func sessionKeyBelongsToChat(sessionKey, channel, chatID string) bool {
+	if channel == "" || chatID == "" {
+		return false
+	}
+	chatKey := channel + ":" + chatID
+	return sessionKey == chatKey || strings.HasPrefix(sessionKey, chatKey+":")
+}
+
+// deliverMessageJob sends a message job's text to the chat it was created in.
+// Its fixed text needs no agent turn.
+func (t *CronTool) deliverMessageJob(job *cron.CronJob) string {
+	channel := strings.TrimSpace(job.Payload.Channel)
+	chatID := strings.TrimSpace(job.Payload.To)
+	if channel == "" || chatID == "" {
+		return "Error: message job has no target chat"
+	}
+	if t.msgBus == nil {
+		return "Error: message bus not configured"
+	}
+	t.msgBus.PublishOutbound(bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: job.Payload.Message,
+	})
+	return "ok"
+}
+
 func (t *CronTool) resolveLastTarget() (string, string) {
 	if t.lastTargetPath == "" {
 		return "", ""
@@ -216,6 +277,9 @@ func (t *CronTool) listJobs() (string, error) {
 	}
 
 	result := "Scheduled jobs:\n"
+	if t.cronService.IsPaused() {
+		result = "Scheduled jobs (all paused; use resume_all to restart):\n"
+	}
 	for _, j := range jobs {
 		var scheduleInfo string
 		if j.Schedule.Kind == "every" && j.Schedule.EveryMS != nil {
@@ -263,8 +327,50 @@ func (t *CronTool) enableJob(args map[string]interface{}, enable bool) (string,
 	return fmt.Sprintf("Job '%s' %s", job.Name, status), nil
 }
 
-// ExecuteJob executes a cron job through the agent
+// runJobNow executes a job immediately. The stored job is left untouched: its
+// next run stays scheduled and one-time jobs are not deleted.
+func (t *CronTool) runJobNow(ctx context.Context, args map[string]interface{}) (string, error) {
+	jobID, ok := args["job_id"].(string)
+	if !ok || jobID == "" {
+		return "Error: job_id is required for run_now", nil
+	}
+
+	job := t.cronService.GetJob(jobID)
+	if job == nil {
+		return fmt.Sprintf("Job %s not found", jobID), nil
+	}
+	// The current turn holds this session until it ends.
+	if sessionKey := strings.TrimSpace(job.Payload.SessionKey); sessionKey != "" && sessionKey == getExecutionSessionKey(args) {
+		return fmt.Sprintf("Error: job '%s' runs in this session, so it cannot run during this turn; it will run on schedule", job.Name), nil
+	}
+
+	result := t.ExecuteJob(ctx, job)
+	if strings.HasPrefix(result, "Error") {
+		return fmt.Sprintf("Job '%s' failed: %s", job.Name, result), nil
+	}
+	return fmt.Sprintf("Ran job '%s' now (result: %s). Its schedule is unchanged.", job.Name, result), nil
+}
+
+func (t *CronTool) setPaused(paused bool) (string, error) {
+	if paused {
+		if err := t.cronService.PauseAll(); err != nil {
+			return fmt.Sprintf("Error pausing jobs: %v", err), nil
+		}
+		return "All scheduled jobs paused", nil
+	}
+	if err := t.cronService.ResumeAll(); err != nil {
+		return fmt.Sprintf("Error resuming jobs: %v", err), nil
+	}
+	return "All scheduled jobs resumed", nil
+}
+
+// ExecuteJob executes a cron job through the agent. Message jobs (reminders)
+// are sent to their chat directly instead.
 func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
+	if job.Payload.Kind == cron.PayloadKindMessage {
+		return t.deliverMessageJob(job)
+	}
+
 	// Get channel/chatID from job payload
 	channel := strings.TrimSpace(job.Payload.Channel)
 	chatID := strings.TrimSpace(job.Payload.To)
@@ -290,12 +396,20 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 		return "Error: executor not configured"
 	}
 
-	sessionKey := fmt.Sprintf("cron-%s", job.ID)
+	// A job's own session key must belong to the chat it is pinned to, so
+	// it can never read another chat's history and deliver it elsewhere.
+	sessionKey := strings.TrimSpace(job.Payload.SessionKey)
+	if sessionKey == "" {
+		sessionKey = fmt.Sprintf("cron-%s", job.ID)
+	} else if !sessionKeyBelongsToChat(sessionKey, strings.TrimSpace(job.Payload.Channel), strings.TrimSpace(job.Payload.To)) {
+		return fmt.Sprintf("Error: session %s does not belong to the job's chat", sessionKey)
+	}
 
 	// Call agent with the job's message
-	response, err := t.executor.ProcessDirectWithChannel(
+	response, err := t.executor.ProcessDirectWithSystemContext(
 		ctx,
 		job.Payload.Message,
+		strings.TrimSpace(job.Payload.PromptPrefix),
 		sessionKey,
 		channel,
 		chatID,
@@ -13,18 +13,20 @@ import (
 )
 
 type mockExecutor struct {
-	lastContent string
-	lastSession string
-	lastChannel string
-	lastChatID  string
-	response    string
-	err         error
-	callCount   int
+	lastContent       string
+	lastSystemContext string
+	lastSession       string
+	lastChannel       string
+	lastChatID        string
+	response          string
+	err               error
+	callCount         int
 }
 
-func (m *mockExecutor) ProcessDirectWithChannel(ctx context.Context, content, sessionKey, channel, chatID string) (string, error) {
+func (m *mockExecutor) ProcessDirectWithSystemContext(ctx context.Context, content, systemContext, sessionKey, channel, chatID string) (string, error) {
 	m.callCount++
 	m.lastContent = content
+	m.lastSystemContext = systemContext
 	m.lastSession = sessionKey
 	m.lastChannel = channel
 	m.lastChatID = chatID
@@ -203,6 +205,42 @@ func TestCronTool_RemoveAndEnableDisableJobs(t *testing.T) {
 	}
 }
 
+func TestCronTool_PauseAndResumeAll(t *testing.T) {
+	tool, service, _, _ := newCronToolWithService(t)
+
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{
+		"action":        "add",
+		"message":       "recurring",
+		"every_seconds": float64(120),
+	}); err != nil {
+		t.Fatalf("unexpected error adding job")
+	}
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{"action": "pause_all"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "paused") || !service.IsPaused() {
+		t.Fatalf("expected service paused, got %q", result)
+	}
+	if !service.ListJobs(true)[0].Enabled {
+		t.Fatal("pause_all must not disable individual jobs")
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"action": "list"})
+	if !strings.Contains(result, "all paused") {
+		t.Fatalf("expected list to show paused state, got %q", result)
+	}
+
+	result, err = tool.Execute(context.Background(), map[string]interface{}{"action": "resume_all"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "resumed") || service.IsPaused() {
+		t.Fatalf("expected service resumed, got %q", result)
+	}
+}
+
 func TestCronTool_ExecuteJobLegacyDeliverTrueProcessesThroughAgent(t *testing.T) {
 	tool, _, executor, msgBus := newCronToolWithService(t)
 
@@ -327,6 +365,142 @@ func TestCronTool_ExecuteJobProcessThroughAgent(t *testing.T) {
 	}
 }
 
+func TestCronTool_ExecuteJobUsesConfiguredSessionAndPromptPrefix(t *testing.T) {
+	tool, _, executor, _ := newCronToolWithService(t)
+
+	job := &cron.CronJob{
+		ID: "standup",
+		Payload: cron.CronPayload{
+			Kind:         cron.PayloadKindAgentTurn,
+			Message:      "Continue our standup thread.",
+			Channel:      "telegram",
+			To:           "42",
+			SessionKey:   "telegram:42",
+			PromptPrefix: "You are the team's standup facilitator.",
+		},
+	}
+
+	if got := tool.ExecuteJob(context.Background(), job); got != "ok" {
+		t.Fatalf("expected ok, got %q", got)
+	}
+	if executor.lastSession != "telegram:42" {
+		t.Fatalf("session key = %q, want telegram:42", executor.lastSession)
+	}
+	if executor.lastContent != "Continue our standup thread." {
+		t.Fatalf("content = %q, want the job message only", executor.lastContent)
+	}
+	if executor.lastSystemContext != "You are the team's standup facilitator." {
+		t.Fatalf("system context = %q, want the prompt prefix", executor.lastSystemContext)
+	}
+}
+
+func TestCronTool_ExecuteJobRejectsSessionOfAnotherChat(t *testing.T) {
+	tool, _, executor, _ := newCronToolWithService(t)
+
+	for _, payload := range []cron.CronPayload{
+		{Kind: cron.PayloadKindAgentTurn, Message: "peek", Channel: "telegram", To: "1", SessionKey: "telegram:2"},
+		{Kind: cron.PayloadKindAgentTurn, Message: "peek", Channel: "telegram", To: "1", SessionKey: "telegram:10"},
+		{Kind: cron.PayloadKindAgentTurn, Message: "peek", SessionKey: "telegram:2"},
+	} {
+		got := tool.ExecuteJob(context.Background(), &cron.CronJob{ID: "leak", Payload: payload})
+		if !strings.HasPrefix(got, "Error") {
+			t.Fatalf("payload %+v: expected error, got %q", payload, got)
+		}
+	}
+	if executor.callCount != 0 {
+		t.Fatalf("executor called %d times for foreign sessions", executor.callCount)
+	}
+
+	got := tool.ExecuteJob(context.Background(), &cron.CronJob{ID: "own", Payload: cron.CronPayload{
+		Kind: cron.PayloadKindAgentTurn, Message: "standup", Channel: "telegram", To: "-100", SessionKey: "telegram:-100:alice",
+	}})
+	if got != "ok" || executor.lastSession != "telegram:-100:alice" {
+		t.Fatalf("sender session in its own group: got %q, session %q", got, executor.lastSession)
+	}
+}
+
+func TestCronTool_AddJobStoresSessionAndPromptPrefix(t *testing.T) {
+	tool, service, executor, _ := newCronToolWithService(t)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"action":                "add",
+		"message":               "Continue our standup thread.",
+		"cron_expr":             "0 9 * * *",
+		"session_key":           "telegram:42",
+		"prompt_prefix":         "You are the team's standup facilitator.",
+		"__context_channel":     "telegram",
+		"__context_chat_id":     "42",
+		"__context_session_key": "telegram:42",
+	})
+	if err != nil || !strings.Contains(result, "Created job") {
+		t.Fatalf("add failed: %q, %v", result, err)
+	}
+
+	jobs := service.ListJobs(true)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	if jobs[0].Payload.SessionKey != "telegram:42" || jobs[0].Payload.PromptPrefix == "" {
+		t.Fatalf("unexpected payload %+v", jobs[0].Payload)
+	}
+
+	if got := tool.ExecuteJob(context.Background(), &jobs[0]); got != "ok" {
+		t.Fatalf("expected ok, got %q", got)
+	}
+	if executor.lastSession != "telegram:42" {
+		t.Fatalf("session key = %q, want telegram:42", executor.lastSession)
+	}
+	if executor.lastChannel != "telegram" || executor.lastChatID != "42" {
+		t.Fatalf("job not pinned to the session's chat: %s:%s", executor.lastChannel, executor.lastChatID)
+	}
+	if executor.lastSystemContext != "You are the team's standup facilitator." {
+		t.Fatalf("prompt prefix not applied: %q", executor.lastSystemContext)
+	}
+}
+
+func TestCronTool_AddJobRejectsOtherSessionKey(t *testing.T) {
+	tool, service, _, _ := newCronToolWithService(t)
+
+	for _, extra := range []map[string]interface{}{
+		{"__context_channel": "telegram", "__context_chat_id": "1", "__context_session_key": "telegram:1"},
+		{"__context_channel": "telegram", "__context_chat_id": "1", "__context_session_key": "telegram:1", "channel": "telegram", "chat_id": "1"},
+		{},
+	} {
+		args := map[string]interface{}{
+			"action":      "add",
+			"message":     "summarize the other chat",
+			"cron_expr":   "0 9 * * *",
+			"session_key": "telegram:2",
+		}
+		for k, v := range extra {
+			args[k] = v
+		}
+		result, err := tool.Execute(context.Background(), args)
+		if err != nil || !strings.HasPrefix(result, "Error") {
+			t.Fatalf("args %v: expected error result, got %q, %v", extra, result, err)
+		}
+	}
+
+	// Its own session, but pinned to a different chat.
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{
+		"action":                "add",
+		"message":               "standup",
+		"cron_expr":             "0 9 * * *",
+		"session_key":           "telegram:1",
+		"channel":               "telegram",
+		"chat_id":               "2",
+		"__context_channel":     "telegram",
+		"__context_chat_id":     "1",
+		"__context_session_key": "telegram:1",
+	})
+	if !strings.HasPrefix(result, "Error") {
+		t.Fatalf("expected error for a session outside the pinned chat, got %q", result)
+	}
+	if jobs := service.ListJobs(true); len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %d", len(jobs))
+	}
+}
+
 func TestCronTool_ExecuteJobProcessThroughAgentDefaultsToLastActiveTarget(t *testing.T) {
 	tool, _, executor, _ := newCronToolWithService(t)
 
@@ -462,3 +636,86 @@ func TestCronTool_ExecuteJobDeliverFalseWithoutExecutor_DoesNotPanic(t *testing.
 		t.Fatal("ExecuteJob should not panic when executor is nil")
 	}
 }
+
+func TestCronTool_RunNowExecutesJobWithoutChangingSchedule(t *testing.T) {
+	tool, service, executor, _ := newCronToolWithService(t)
+
+	for _, args := range []map[string]interface{}{
+		{"action": "add", "message": "daily report", "cron_expr": "0 9 * * *", "channel": "telegram", "chat_id": "42"},
+		{"action": "add", "message": "one-time reminder", "at_seconds": float64(3600)},
+	} {
+		if _, err := tool.Execute(context.Background(), args); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	before := service.ListJobs(true)
+	if len(before) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(before))
+	}
+
+	for i, job := range before {
+		result, err := tool.Execute(context.Background(), map[string]interface{}{
+			"action": "run_now",
+			"job_id": job.ID,
+		})
+		if err != nil {
+			t.Fatalf("run_now: %v", err)
+		}
+		if !strings.Contains(result, "Ran job") {
+			t.Fatalf("unexpected result: %q", result)
+		}
+		if executor.callCount != i+1 || executor.lastContent != job.Payload.Message || executor.lastSession != "cron-"+job.ID {
+			t.Fatalf("executor not called with job payload: %+v", executor)
+		}
+	}
+	if executor.lastChannel != "cli" || executor.lastChatID != "direct" {
+		t.Fatalf("unpinned job should use default target, got %s:%s", executor.lastChannel, executor.lastChatID)
+	}
+
+	after := service.ListJobs(true)
+	if len(after) != 2 {
+		t.Fatalf("run_now must not delete one-time jobs, got %d jobs", len(after))
+	}
+	for i := range before {
+		if !after[i].Enabled || *after[i].State.NextRunAtMS != *before[i].State.NextRunAtMS || after[i].State.LastRunAtMS != nil {
+			t.Fatalf("job %s schedule changed: before %+v after %+v", before[i].ID, before[i].State, after[i].State)
+		}
+	}
+}
+
+func TestCronTool_RunNowUnknownJob(t *testing.T) {
+	tool, _, executor, _ := newCronToolWithService(t)
+
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "run_now",
+		"job_id": "missing",
+	})
+	if !strings.Contains(result, "not found") || executor.callCount != 0 {
+		t.Fatalf("expected not found without execution, got %q (calls=%d)", result, executor.callCount)
+	}
+}
+
+func TestCronTool_RunNowRefusesJobInCallersSession(t *testing.T) {
+	tool, service, executor, _ := newCronToolWithService(t)
+
+	job, err := service.AddAgentJob("standup", cron.CronSchedule{Kind: "cron", Expr: "0 9 * * *"}, cron.CronPayload{
+		Message:    "standup",
+		Channel:    "telegram",
+		To:         "42",
+		SessionKey: "telegram:42",
+	})
+	if err != nil {
+		t.Fatalf("AddAgentJob: %v", err)
+	}
+
+	// The calling turn holds telegram:42, so running the job now would wait
+	// for itself.
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{
+		"action":                "run_now",
+		"job_id":                job.ID,
+		"__context_session_key": "telegram:42",
+	})
+	if !strings.Contains(result, "cannot run during this turn") || executor.callCount != 0 {
+		t.Fatalf("expected refusal without execution, got %q (calls=%d)", result, executor.callCount)
+	}
+}
@@ -13,6 +13,7 @@ type EditFileTool struct {
 	name                string
 	allowedDir          string // Optional directory restriction for security
 	restrictToWorkspace bool
+	isolation           WorkspaceIsolation
 }
 
 // NewEditFileTool creates a new EditFileTool with optional directory restriction.
@@ -36,6 +37,12 @@ func (t *EditFileTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetWorkspaceIsolation scopes each call to the caller's channel or chat
+// directory under the workspace.
+func (t *EditFileTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
 func (t *EditFileTool) Name() string {
 	if t != nil && t.name != "" {
 		return t.name
@@ -74,6 +81,12 @@ func (t *EditFileTool) Parameters() map[string]interface{} {
 	}
 }
 
+// ExclusivityGroup serializes calls on the same file. Edits to one path
+// in a batch apply in call order, each on the result of the previous.
+func (t *EditFileTool) ExclusivityGroup(args map[string]interface{}) string {
+	return fileExclusivityGroup(t.allowedDir, t.isolation, args)
+}
+
 func (t *EditFileTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
@@ -90,7 +103,11 @@ func (t *EditFileTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("new_text is required")
 	}
 
-	resolvedPath, err := resolvePathWithOptionalRootMode(path, t.allowedDir, "workspace", t.restrictToWorkspace)
+	root, err := scopedWorkspace(t.allowedDir, t.isolation, args)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := resolvePathWithOptionalRootMode(path, root, "workspace", t.restrictToWorkspace)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// ExclusiveTool is an optional interface for side-effecting tools. Calls in
+// one batch that report the same non-empty group run one at a time in call
+// order; calls in other groups, or in none, still run in parallel.
+type ExclusiveTool interface {
+	Tool
+	ExclusivityGroup(args map[string]interface{}) string
+}
+
+// exclusivityGroup returns the group of a call made from channel/chatID, or
+// "" when it may run alongside anything.
+func (r *ToolRegistry) exclusivityGroup(call providers.ToolCall, channel, chatID string) string {
+	tool, ok := r.Get(call.Name)
+	if !ok {
+		return ""
+	}
+	exclusive, ok := tool.(ExclusiveTool)
+	if !ok {
+		return ""
+	}
+	return exclusive.ExclusivityGroup(withExecutionContext(call.Arguments, channel, chatID, ""))
+}
+
+// fileExclusivityGroup groups file-modifying calls by the file they resolve
+// to, so that write_file, edit_file and patch_file on one path serialize.
+// Calls whose path does not resolve get no group; they fail on their own.
+func fileExclusivityGroup(allowedDir string, isolation WorkspaceIsolation, args map[string]interface{}) string {
+	path, _ := args["path"].(string)
+	root, err := scopedWorkspace(allowedDir, isolation, args)
+	if err != nil {
+		return ""
+	}
+	resolved, err := resolvePathWithOptionalRootMode(path, root, "workspace", false)
+	if err != nil {
+		return ""
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+	return "file:" + resolved
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultExecConfirmTTL is how long an exec confirmation token stays valid.
+const DefaultExecConfirmTTL = 2 * time.Minute
+
+// guardConfirmRequired is returned by guardCommand for a command that is not
+// blocked but matches a confirm pattern; Execute turns it into a token
+// round-trip instead of an error.
+const guardConfirmRequired = "confirmation required"
+
+// execConfirmations holds the outstanding confirmation tokens of one exec
+// tool. A token is bound to the exact command and working directory it was
+// issued for and can be used once.
+type execConfirmations struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pending map[string]pendingConfirmation
+	now     func() time.Time
+}
+
+type pendingConfirmation struct {
+	command string
+	expires time.Time
+}
+
+// issue returns a fresh token for command and how long it is valid,
+// dropping expired tokens.
+func (c *execConfirmations) issue(command string) (string, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	if c.pending == nil {
+		c.pending = make(map[string]pendingConfirmation)
+	}
+	for token, p := range c.pending {
+		if !now.Before(p.expires) {
+			delete(c.pending, token)
+		}
+	}
+
+	token := fmt.Sprintf("%d", now.UnixNano())
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err == nil {
+		token = hex.EncodeToString(buf)
+	}
+	ttl := c.ttlOrDefault()
+	c.pending[token] = pendingConfirmation{command: command, expires: now.Add(ttl)}
+	return token, ttl
+}
+
+// redeem consumes token if it was issued for command and has not expired.
+func (c *execConfirmations) redeem(token, command string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[token]
+	if !ok {
+		return false
+	}
+	delete(c.pending, token)
+	return p.command == command && c.clock().Before(p.expires)
+}
+
+func (c *execConfirmations) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *execConfirmations) ttlOrDefault() time.Duration {
+	if c.ttl > 0 {
+		return c.ttl
+	}
+	return DefaultExecConfirmTTL
+}
+
+// SetConfirmPatterns makes commands matching any of patterns wait for a
+// confirmation token instead of running. Denies and the allowlist still
+// apply first. On error the patterns are left unchanged.
+func (t *ExecTool) SetConfirmPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid confirm pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	t.confirmPatterns = compiled
+	return nil
+}
+
+// SetConfirmTTL sets how long confirmation tokens stay valid (<= 0 uses
+// DefaultExecConfirmTTL).
+func (t *ExecTool) SetConfirmTTL(ttl time.Duration) {
+	t.confirmations.mu.Lock()
+	defer t.confirmations.mu.Unlock()
+	t.confirmations.ttl = ttl
+}
+
+func (t *ExecTool) needsConfirmation(lower string) bool {
+	for _, pattern := range t.confirmPatterns {
+		if pattern.MatchString(lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmCommand runs the token round-trip for a command that needs
+// confirmation. It returns "" when token confirms the command, and
+// otherwise the tool result to send back, carrying a new token.
+func (t *ExecTool) confirmCommand(command, cwd, token string) string {
+	key := cwd + "\x00" + command
+	if token != "" && t.confirmations.redeem(token, key) {
+		return ""
+	}
+
+	prefix := ""
+	if token != "" {
+		prefix = "Error: confirm_token is invalid, expired, or was issued for a different command. "
+	}
+	newToken, ttl := t.confirmations.issue(key)
+	return fmt.Sprintf("%sConfirmation required: this command matches an exec confirm pattern and was not run. "+
+		"Ask the user to approve it, then call %s again with the same command and working directory and confirm_token %q within %s.",
+		prefix, t.Name(), newToken, ttl)
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var confirmTokenPattern = regexp.MustCompile(`confirm_token "([0-9a-f]+)"`)
+
+func newConfirmExecTool(t *testing.T) (*ExecTool, *time.Time) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+	if err := tool.SetConfirmPatterns([]string{`^echo\s+push\b`}); err != nil {
+		t.Fatalf("SetConfirmPatterns: %v", err)
+	}
+	tool.SetConfirmTTL(time.Minute)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tool.confirmations.now = func() time.Time { return now }
+	return tool, &now
+}
+
+func confirmTokenFrom(t *testing.T, result string) string {
+	t.Helper()
+	m := confirmTokenPattern.FindStringSubmatch(result)
+	if m == nil {
+		t.Fatalf("expected a confirmation token in %q", result)
+	}
+	return m[1]
+}
+
+func TestExecTool_ConfirmPatternRequiresToken(t *testing.T) {
+	tool, _ := newConfirmExecTool(t)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{"command": "echo push done"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(result, "Confirmation required") || strings.Contains(result, "push done\n") {
+		t.Fatalf("expected confirmation request without running, got %q", result)
+	}
+	confirmTokenFrom(t, result)
+
+	// Unrelated commands still run straight away.
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"command": "echo hello"})
+	if !strings.Contains(result, "hello") {
+		t.Fatalf("expected unrelated command to run, got %q", result)
+	}
+}
+
+func TestExecTool_ConfirmTokenRunsCommandOnce(t *testing.T) {
+	tool, _ := newConfirmExecTool(t)
+
+	first, _ := tool.Execute(context.Background(), map[string]interface{}{"command": "echo push done"})
+	token := confirmTokenFrom(t, first)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command":       "echo push done",
+		"confirm_token": token,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "push done") || strings.Contains(result, "Confirmation required") {
+		t.Fatalf("expected confirmed command to run, got %q", result)
+	}
+
+	// Tokens are single use.
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{
+		"command":       "echo push done",
+		"confirm_token": token,
+	})
+	if !strings.Contains(result, "confirm_token is invalid") {
+		t.Fatalf("expected reused token to be rejected, got %q", result)
+	}
+}
+
+func TestExecTool_ConfirmTokenRejectedWhenExpiredOrForOtherCommand(t *testing.T) {
+	tool, now := newConfirmExecTool(t)
+
+	first, _ := tool.Execute(context.Background(), map[string]interface{}{"command": "echo push done"})
+	token := confirmTokenFrom(t, first)
+
+	*now = now.Add(2 * time.Minute)
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{
+		"command":       "echo push done",
+		"confirm_token": token,
+	})
+	if !strings.HasPrefix(result, "Error: confirm_token is invalid, expired") {
+		t.Fatalf("expected expired token to be rejected, got %q", result)
+	}
+	fresh := confirmTokenFrom(t, result)
+	if fresh == token {
+		t.Fatal("expected a new token after rejection")
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{
+		"command":       "echo push elsewhere",
+		"confirm_token": fresh,
+	})
+	if !strings.Contains(result, "confirm_token is invalid") {
+		t.Fatalf("expected token for another command to be rejected, got %q", result)
+	}
+}
+
+func TestExecTool_DenyPatternWinsOverConfirm(t *testing.T) {
+	tool, _ := newConfirmExecTool(t)
+	if err := tool.SetConfirmPatterns([]string{`\brm\b`}); err != nil {
+		t.Fatalf("SetConfirmPatterns: %v", err)
+	}
+
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{"command": "rm -rf build"})
+	if !strings.Contains(result, "Command blocked by safety guard") {
+		t.Fatalf("expected deny to win over confirm, got %q", result)
+	}
+}
+
+func TestRegisterCoreTools_ConfigConfirmPatterns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	registry := NewToolRegistry()
+	if err := RegisterCoreTools(registry, t.TempDir(), WebSearchToolConfig{}, CoreToolsOptions{
+		ExecConfirmPatterns: []string{`^git\s+push\b.*--force`},
+	}); err != nil {
+		t.Fatalf("RegisterCoreTools: %v", err)
+	}
+
+	result, _ := registry.Execute(context.Background(), "exec", map[string]interface{}{"command": "git push --force origin main"})
+	if !strings.Contains(result, "Confirmation required") {
+		t.Fatalf("expected confirm pattern to hold force push, got %q", result)
+	}
+
+	registry = NewToolRegistry()
+	err := RegisterCoreTools(registry, t.TempDir(), WebSearchToolConfig{}, CoreToolsOptions{
+		ExecConfirmPatterns: []string{`(bad`},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid confirm pattern") {
+		t.Fatalf("expected invalid confirm pattern error, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// execDryRunNote ends every dry-run result.
+const execDryRunNote = "[dry-run: not executed]"
+
+type execDryRunContextKey struct{}
+
+// WithExecDryRun marks ctx so that exec tools describe the commands they
+// would run instead of running them.
+func WithExecDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, execDryRunContextKey{}, true)
+}
+
+// ExecDryRunFromContext reports whether ctx was marked with WithExecDryRun.
+func ExecDryRunFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(execDryRunContextKey{}).(bool)
+	return v
+}
+
+// SetDryRun makes every call describe its command instead of running it.
+func (t *ExecTool) SetDryRun(dryRun bool) {
+	t.dryRun = dryRun
+}
+
+// describeExecDryRun is the result of a dry-run call: the command as it
+// passed the guard, where and how it would run.
+func describeExecDryRun(command, cwd string, env map[string]string, timeout time.Duration, confirm bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Would run: %s\n", command)
+	fmt.Fprintf(&sb, "Working directory: %s\n", cwd)
+	if len(env) > 0 {
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("Environment:\n")
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "  %s=%s\n", k, env[k])
+		}
+	}
+	if timeout > 0 {
+		fmt.Fprintf(&sb, "Timeout: %v\n", timeout)
+	}
+	if confirm {
+		sb.WriteString("The user would be asked to confirm this command first.\n")
+	}
+	sb.WriteString(execDryRunNote)
+	return sb.String()
+}
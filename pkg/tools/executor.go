@@ -3,9 +3,9 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -13,23 +13,61 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// DefaultMaxToolTimeout caps how far a TimeoutHint may raise a tool's
+// timeout above the batch default.
+const DefaultMaxToolTimeout = 10 * time.Minute
+
+// ToolWithTimeoutHint is implemented by tools that legitimately need longer
+// than the shared tool timeout (network fetches, shell commands).
+type ToolWithTimeoutHint interface {
+	Tool
+	TimeoutHint() time.Duration
+}
+
 type ExecuteToolCallsOptions struct {
 	Channel     string
 	ChatID      string
 	SessionKey  string
 	TraceID     string
 	Timeout     time.Duration
-	MaxParallel int // <=0 means unlimited within this batch
+	MaxTimeout  time.Duration // cap for per-tool hints; <=0 means DefaultMaxToolTimeout
+	MaxParallel int           // <=0 means unlimited within this batch
 
 	LogComponent string // default: "tool"
 	Iteration    int
 
 	OnToolStart    func(started, total, index int, call providers.ToolCall)
 	OnToolComplete func(completed, total, index int, call providers.ToolCall, result providers.Message)
+	// OnToolFinished runs on the tool's own goroutine right after it returns
+	// (or panics), so it may be called concurrently. err is the execution
+	// error, if any; tools that report failure in their content leave it nil.
+	OnToolFinished func(index int, call providers.ToolCall, result providers.Message, err error, duration time.Duration)
+	// OnToolProgress receives progress lines a tool reports through the
+	// ProgressReporter in its context. Like OnToolFinished it may be called
+	// concurrently; tools only get a reporter when it is set.
+	OnToolProgress func(index int, call providers.ToolCall, message string)
+	// SummarizeOver is the result size in bytes above which a successful
+	// result is passed to SummarizeResult before the result limit applies.
+	// 0 disables summarizing.
+	SummarizeOver int
+	// SummarizeResult replaces an oversized result with a shorter one. It runs
+	// on the tool's goroutine, so it may be called concurrently.
+	SummarizeResult func(ctx context.Context, call providers.ToolCall, content string) string
 }
 
 // ExecuteToolCalls executes a batch of tool calls with optional per-tool timeout
-// and bounded parallelism. Results are returned in original call order.
+// and bounded parallelism.
+//
+// Ordering guarantees:
+//   - Results are always returned in original call order, regardless of
+//     completion order.
+//   - Tools start in call order: a call never starts before an earlier call
+//     has started.
+//   - With MaxParallel == 1 execution is strictly sequential; each call starts
+//     only after the previous one has finished.
+//   - Calls in the same exclusivity group (see ExclusiveTool) run one at a
+//     time in call order. A call waiting for its group keeps its slot, so
+//     it still counts against MaxParallel.
 func (r *ToolRegistry) ExecuteToolCalls(
 	ctx context.Context,
 	toolCalls []providers.ToolCall,
@@ -53,18 +91,69 @@ func (r *ToolRegistry) ExecuteToolCalls(
 	results := make([]providers.Message, n)
 	sem := make(chan struct{}, parallelLimit)
 	doneCh := make(chan int, n)
-	var startedCount atomic.Int32
+	started := 0
+
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		completed := 0
+		for range n {
+			idx := <-doneCh
+			completed++
+			if opts.OnToolComplete != nil {
+				opts.OnToolComplete(completed, n, idx, toolCalls[idx], results[idx])
+			}
+		}
+	}()
+
+	// lastInGroup holds the done channel of the latest call started in each
+	// exclusivity group; the next call of the group waits for it.
+	lastInGroup := make(map[string]chan struct{})
 
+	// Slots are acquired here, in call order, rather than inside each
+	// goroutine, so the scheduler cannot reorder starts under a cap.
 	var wg sync.WaitGroup
 	for i, tc := range toolCalls {
+		acquired := false
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+				acquired = true
+			case <-ctx.Done():
+			}
+		}
+		if !acquired {
+			results[i] = providers.ToolResultMessage(tc.ID, fmt.Sprintf("Error: %v", ctx.Err()))
+			doneCh <- i
+			continue
+		}
+
+		started++
+		if opts.OnToolStart != nil {
+			opts.OnToolStart(started, n, i, tc)
+		}
+
+		var prev, finished chan struct{}
+		if group := r.exclusivityGroup(tc, opts.Channel, opts.ChatID); group != "" {
+			prev = lastInGroup[group]
+			finished = make(chan struct{})
+			lastInGroup[group] = finished
+		}
+
 		wg.Add(1)
 		go func(idx int, tc providers.ToolCall) {
-			acquired := false
+			if prev != nil {
+				<-prev
+			}
+			startedAt := time.Now()
+			var execErr error
 			defer func() {
-				if acquired {
-					<-sem
+				if finished != nil {
+					close(finished)
 				}
+				<-sem
 				if rec := recover(); rec != nil {
+					execErr = fmt.Errorf("tool %s panicked: %v", tc.Name, rec)
 					result := fmt.Sprintf("Error: tool %s panicked: %v", tc.Name, rec)
 					logger.ErrorCF(component, "Recovered panic in tool execution",
 						map[string]interface{}{
@@ -75,24 +164,15 @@ func (r *ToolRegistry) ExecuteToolCalls(
 						})
 					results[idx] = providers.ToolResultMessage(tc.ID, result)
 				}
+				if opts.OnToolFinished != nil {
+					opts.OnToolFinished(idx, tc, results[idx], execErr, time.Since(startedAt))
+				}
 				doneCh <- idx
 				wg.Done()
 			}()
 
-			select {
-			case sem <- struct{}{}:
-				acquired = true
-				if opts.OnToolStart != nil {
-					started := int(startedCount.Add(1))
-					opts.OnToolStart(started, n, idx, tc)
-				}
-			case <-ctx.Done():
-				results[idx] = providers.ToolResultMessage(tc.ID, fmt.Sprintf("Error: %v", ctx.Err()))
-				return
-			}
-
-			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
+			argsJSON, _ := json.Marshal(redactExecEnvArgs(tc.Arguments))
+			argsPreview := utils.Truncate(string(argsJSON), r.previewLimit())
 			logger.InfoCF(component, fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
 				map[string]interface{}{
 					"tool":      tc.Name,
@@ -101,38 +181,92 @@ func (r *ToolRegistry) ExecuteToolCalls(
 				})
 
 			toolCtx := WithTraceID(ctx, opts.TraceID)
+			if opts.OnToolProgress != nil {
+				toolCtx = WithProgressReporter(toolCtx, func(message string) {
+					opts.OnToolProgress(idx, tc, message)
+				})
+			}
 			cancel := func() {}
-			if opts.Timeout > 0 {
-				toolCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			if timeout := r.toolTimeout(tc.Name, opts.Timeout, opts.MaxTimeout); timeout > 0 {
+				toolCtx, cancel = context.WithTimeout(toolCtx, timeout)
 			}
 			execArgs := withExecutionSessionKey(tc.Arguments, opts.SessionKey)
 			toolResult, err := r.ExecuteResultWithContext(toolCtx, tc.Name, execArgs, opts.Channel, opts.ChatID)
 			cancel()
+			execErr = err
 			if err != nil {
 				toolResult.Content = fmt.Sprintf("Error: %v", err)
+				toolResult.Structured = nil
+				var argErr *InvalidArgumentsError
+				var unknownErr *UnknownToolError
+				if errors.As(err, &argErr) {
+					toolResult.Structured = argErr.Structured()
+				} else if errors.As(err, &unknownErr) {
+					toolResult.Structured = unknownErr.Structured()
+				}
+			}
+
+			if err == nil && opts.SummarizeResult != nil && opts.SummarizeOver > 0 && len(toolResult.Content) > opts.SummarizeOver {
+				logger.InfoCF(component, "Summarizing oversized tool result",
+					map[string]interface{}{
+						"tool":           tc.Name,
+						"bytes":          len(toolResult.Content),
+						"summarize_over": opts.SummarizeOver,
+						"trace_id":       opts.TraceID,
+					})
+				toolResult.Content = opts.SummarizeResult(ctx, tc, toolResult.Content)
 			}
 
-			msg := providers.ToolResultMessage(tc.ID, toolResult.Content)
+			if limit := r.resultLimit(tc.Name); limit > 0 && len(toolResult.Content) > limit {
+				logger.InfoCF(component, "Tool result truncated",
+					map[string]interface{}{
+						"tool":      tc.Name,
+						"bytes":     len(toolResult.Content),
+						"max_bytes": limit,
+						"trace_id":  opts.TraceID,
+					})
+				toolResult.Content = utils.TruncateMiddle(toolResult.Content, limit)
+			}
+
+			msg := providers.StructuredToolResultMessage(tc.ID, toolResult.Content, toolResult.Structured)
 			msg.Parts = toolResult.Parts
 			results[idx] = msg
 		}(i, tc)
 	}
 
-	progressDone := make(chan struct{})
-	go func() {
-		defer close(progressDone)
-		completed := 0
-		for range n {
-			idx := <-doneCh
-			completed++
-			if opts.OnToolComplete != nil {
-				opts.OnToolComplete(completed, n, idx, toolCalls[idx], results[idx])
-			}
-		}
-	}()
-
 	wg.Wait()
 	<-progressDone
 
 	return results
 }
+
+// toolTimeout returns the timeout for one call: the batch default, raised to
+// the tool's TimeoutHint when that is larger, but never above maxTimeout.
+// A non-positive base means no timeout, and hints do not change that.
+func (r *ToolRegistry) toolTimeout(name string, base, maxTimeout time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	tool, ok := r.Get(name)
+	if !ok {
+		return base
+	}
+	hinted, ok := tool.(ToolWithTimeoutHint)
+	if !ok {
+		return base
+	}
+	hint := hinted.TimeoutHint()
+	if hint <= base {
+		return base
+	}
+	if maxTimeout <= 0 {
+		maxTimeout = DefaultMaxToolTimeout
+	}
+	if hint > maxTimeout {
+		hint = maxTimeout
+	}
+	if hint < base {
+		return base
+	}
+	return hint
+}
@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -102,6 +104,223 @@ func TestExecuteToolCalls_TimeoutProducesError(t *testing.T) {
 	}
 }
 
+func TestExecuteToolCalls_OversizedResultKeepsHeadAndTail(t *testing.T) {
+	big := "HEAD " + strings.Repeat("x", 10000) + " TAIL"
+	registry := NewToolRegistry()
+	registry.Register(&execTestTool{name: "read_file", result: big})
+	registry.Register(&execTestTool{name: "exec", result: big})
+	registry.Register(&execTestTool{name: "small", result: "short"})
+	registry.SetResultLimit(100, map[string]int{"exec": 0})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "read_file", Arguments: map[string]interface{}{}},
+		{ID: "tc2", Name: "exec", Arguments: map[string]interface{}{}},
+		{ID: "tc3", Name: "small", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{})
+
+	got := results[0].Content
+	if !strings.HasPrefix(got, "HEAD ") || !strings.HasSuffix(got, " TAIL") {
+		t.Fatalf("truncated result lost its head or tail: %q", got)
+	}
+	if !strings.Contains(got, "[... 9910 bytes omitted ...]") {
+		t.Fatalf("expected elision marker, got %q", got)
+	}
+	if len(got) > 200 {
+		t.Fatalf("result not reduced: %d bytes", len(got))
+	}
+	if results[1].Content != big {
+		t.Fatal("per-tool 0 should disable the cap for exec")
+	}
+	if results[2].Content != "short" {
+		t.Fatalf("small result changed: %q", results[2].Content)
+	}
+}
+
+func TestExecuteToolCalls_SummarizesBeforeResultLimit(t *testing.T) {
+	big := strings.Repeat("x", 10000)
+	registry := NewToolRegistry()
+	registry.Register(&execTestTool{name: "big", result: big})
+	registry.Register(&execTestTool{name: "small", result: "short"})
+	registry.SetResultLimit(100, nil)
+
+	var summarized []string
+	var mu sync.Mutex
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "big", Arguments: map[string]interface{}{}},
+		{ID: "tc2", Name: "small", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{
+		SummarizeOver: 1000,
+		SummarizeResult: func(_ context.Context, call providers.ToolCall, content string) string {
+			mu.Lock()
+			summarized = append(summarized, call.ID)
+			mu.Unlock()
+			return fmt.Sprintf("summary of %d bytes", len(content))
+		},
+	})
+
+	if results[0].Content != "summary of 10000 bytes" {
+		t.Fatalf("expected summary to replace the result, got %q", results[0].Content)
+	}
+	if results[1].Content != "short" {
+		t.Fatalf("small result changed: %q", results[1].Content)
+	}
+	if len(summarized) != 1 || summarized[0] != "tc1" {
+		t.Fatalf("expected only tc1 summarized, got %v", summarized)
+	}
+}
+
+// orderedWriteTool records when each call starts and ends. Calls on one path
+// share an exclusivity group. The first write waits for readStarted, so it
+// only finishes if a read runs alongside it.
+type orderedWriteTool struct {
+	mu          sync.Mutex
+	events      []string
+	readStarted chan struct{}
+}
+
+func (t *orderedWriteTool) Name() string        { return "write" }
+func (t *orderedWriteTool) Description() string { return "ordered write tool" }
+func (t *orderedWriteTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *orderedWriteTool) ExclusivityGroup(args map[string]interface{}) string {
+	path, _ := args["path"].(string)
+	return "file:" + path
+}
+func (t *orderedWriteTool) record(event string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+func (t *orderedWriteTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	id, _ := args["id"].(string)
+	t.record(id + " start")
+	if id == "w1" {
+		select {
+		case <-t.readStarted:
+		case <-time.After(2 * time.Second):
+			t.record("read did not run concurrently")
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	t.record(id + " end")
+	return id, nil
+}
+
+type signalReadTool struct {
+	started chan struct{}
+}
+
+func (t *signalReadTool) Name() string        { return "read" }
+func (t *signalReadTool) Description() string { return "signal read tool" }
+func (t *signalReadTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *signalReadTool) Execute(_ context.Context, _ map[string]interface{}) (string, error) {
+	close(t.started)
+	return "contents", nil
+}
+
+func TestExecuteToolCalls_SerializesExclusiveGroupInCallOrder(t *testing.T) {
+	readStarted := make(chan struct{})
+	writer := &orderedWriteTool{readStarted: readStarted}
+	registry := NewToolRegistry()
+	registry.Register(writer)
+	registry.Register(&signalReadTool{started: readStarted})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "write", Arguments: map[string]interface{}{"path": "notes.txt", "id": "w1"}},
+		{ID: "tc2", Name: "write", Arguments: map[string]interface{}{"path": "notes.txt", "id": "w2"}},
+		{ID: "tc3", Name: "read", Arguments: map[string]interface{}{"path": "notes.txt"}},
+	}, ExecuteToolCallsOptions{})
+
+	if results[0].Content != "w1" || results[1].Content != "w2" || results[2].Content != "contents" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	want := []string{"w1 start", "w1 end", "w2 start", "w2 end"}
+	if fmt.Sprint(writer.events) != fmt.Sprint(want) {
+		t.Fatalf("write events = %v, want %v", writer.events, want)
+	}
+}
+
+func TestFileToolsShareExclusivityGroupByPath(t *testing.T) {
+	dir := t.TempDir()
+	write := NewWriteFileTool(dir)
+	edit := NewEditFileTool(dir)
+	patch := NewPatchFileTool(dir)
+
+	a := write.ExclusivityGroup(map[string]interface{}{"path": "a.txt"})
+	if a == "" {
+		t.Fatal("expected write_file to report a group")
+	}
+	if got := edit.ExclusivityGroup(map[string]interface{}{"path": "./a.txt"}); got != a {
+		t.Fatalf("edit_file group = %q, want %q", got, a)
+	}
+	if got := patch.ExclusivityGroup(map[string]interface{}{"path": dir + "/sub/../a.txt"}); got != a {
+		t.Fatalf("patch_file group = %q, want %q", got, a)
+	}
+	if got := write.ExclusivityGroup(map[string]interface{}{"path": "b.txt"}); got == a {
+		t.Fatal("different files should not share a group")
+	}
+}
+
+// hintedTool is an execTestTool that declares a preferred timeout.
+type hintedTool struct {
+	execTestTool
+	hint time.Duration
+}
+
+func (t *hintedTool) TimeoutHint() time.Duration { return t.hint }
+
+func TestExecuteToolCalls_TimeoutHintExtendsDefault(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&hintedTool{
+		execTestTool: execTestTool{name: "slow_but_legit", delay: 150 * time.Millisecond, result: "ok"},
+		hint:         time.Second,
+	})
+	registry.Register(&execTestTool{name: "slow", delay: 150 * time.Millisecond, result: "ok"})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "slow_but_legit", Arguments: map[string]interface{}{}},
+		{ID: "tc2", Name: "slow", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{Timeout: 50 * time.Millisecond})
+
+	if results[0].Content != "ok" {
+		t.Fatalf("hinted tool should complete under its longer hint, got %q", results[0].Content)
+	}
+	if results[1].Content == "ok" {
+		t.Fatalf("unhinted tool should still hit the global timeout, got %q", results[1].Content)
+	}
+}
+
+func TestToolTimeout_HintBounds(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&hintedTool{execTestTool: execTestTool{name: "long"}, hint: time.Hour})
+	registry.Register(&hintedTool{execTestTool: execTestTool{name: "short"}, hint: time.Second})
+	registry.Register(&execTestTool{name: "plain"})
+
+	tests := []struct {
+		name      string
+		tool      string
+		base, max time.Duration
+		want      time.Duration
+	}{
+		{"capped by max", "long", time.Minute, 5 * time.Minute, 5 * time.Minute},
+		{"capped by default max", "long", time.Minute, 0, DefaultMaxToolTimeout},
+		{"hint below default ignored", "short", time.Minute, 0, time.Minute},
+		{"no hint", "plain", time.Minute, 0, time.Minute},
+		{"no timeout stays unlimited", "long", 0, 0, 0},
+		{"max below default keeps default", "long", time.Minute, time.Second, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.toolTimeout(tt.tool, tt.base, tt.max); got != tt.want {
+				t.Fatalf("toolTimeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestExecuteToolCalls_RespectsMaxParallel(t *testing.T) {
 	registry := NewToolRegistry()
 	inFlight := &atomic.Int32{}
@@ -134,6 +353,90 @@ func TestExecuteToolCalls_RespectsMaxParallel(t *testing.T) {
 	}
 }
 
+// orderRecordingTool appends start/end events to a shared log.
+type orderRecordingTool struct {
+	name  string
+	delay time.Duration
+	mu    *sync.Mutex
+	log   *[]string
+}
+
+func (t *orderRecordingTool) Name() string        { return t.name }
+func (t *orderRecordingTool) Description() string { return "order recording tool" }
+func (t *orderRecordingTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *orderRecordingTool) Execute(_ context.Context, _ map[string]interface{}) (string, error) {
+	t.record("start:" + t.name)
+	time.Sleep(t.delay)
+	t.record("end:" + t.name)
+	return t.name + "_ok", nil
+}
+func (t *orderRecordingTool) record(event string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*t.log = append(*t.log, event)
+}
+
+func TestExecuteToolCalls_MaxParallelOneIsStrictlySequential(t *testing.T) {
+	for run := 0; run < 5; run++ {
+		registry := NewToolRegistry()
+		var mu sync.Mutex
+		var log []string
+		// Decreasing delays: under any concurrency the later tools would finish first.
+		registry.Register(&orderRecordingTool{name: "a", delay: 30 * time.Millisecond, mu: &mu, log: &log})
+		registry.Register(&orderRecordingTool{name: "b", delay: 15 * time.Millisecond, mu: &mu, log: &log})
+		registry.Register(&orderRecordingTool{name: "c", delay: 1 * time.Millisecond, mu: &mu, log: &log})
+
+		results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+			{ID: "tc1", Name: "a", Arguments: map[string]interface{}{}},
+			{ID: "tc2", Name: "b", Arguments: map[string]interface{}{}},
+			{ID: "tc3", Name: "c", Arguments: map[string]interface{}{}},
+		}, ExecuteToolCallsOptions{MaxParallel: 1})
+
+		want := []string{"start:a", "end:a", "start:b", "end:b", "start:c", "end:c"}
+		if strings.Join(log, ",") != strings.Join(want, ",") {
+			t.Fatalf("run %d: execution order = %v, want %v", run, log, want)
+		}
+		for i, name := range []string{"a", "b", "c"} {
+			if results[i].Content != name+"_ok" || results[i].ToolCallID != fmt.Sprintf("tc%d", i+1) {
+				t.Fatalf("run %d: results[%d] = %+v", run, i, results[i])
+			}
+		}
+	}
+}
+
+func TestExecuteToolCalls_StartsInCallOrderUnderCap(t *testing.T) {
+	registry := NewToolRegistry()
+	var mu sync.Mutex
+	var log []string
+	toolCalls := make([]providers.ToolCall, 0, 6)
+	for i := 1; i <= 6; i++ {
+		name := fmt.Sprintf("t%d", i)
+		registry.Register(&orderRecordingTool{name: name, delay: 5 * time.Millisecond, mu: &mu, log: &log})
+		toolCalls = append(toolCalls, providers.ToolCall{ID: "id_" + name, Name: name, Arguments: map[string]interface{}{}})
+	}
+
+	var startOrder []int
+	results := registry.ExecuteToolCalls(context.Background(), toolCalls, ExecuteToolCallsOptions{
+		MaxParallel: 2,
+		OnToolStart: func(_, _, index int, _ providers.ToolCall) {
+			startOrder = append(startOrder, index)
+		},
+	})
+
+	for i, idx := range startOrder {
+		if idx != i {
+			t.Fatalf("start order = %v, want call order", startOrder)
+		}
+	}
+	for i, res := range results {
+		if res.ToolCallID != toolCalls[i].ID {
+			t.Fatalf("results out of call order at %d: %q", i, res.ToolCallID)
+		}
+	}
+}
+
 func TestExecuteToolCalls_PanicRecovered(t *testing.T) {
 	registry := NewToolRegistry()
 	registry.Register(&execTestTool{name: "panic_tool", panicOn: true})
@@ -191,6 +494,48 @@ func TestExecuteToolCalls_AttachesRichToolParts(t *testing.T) {
 	}
 }
 
+type structuredResultTool struct {
+	err error
+}
+
+func (t *structuredResultTool) Name() string        { return "structured" }
+func (t *structuredResultTool) Description() string { return "structured result tool" }
+func (t *structuredResultTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *structuredResultTool) Execute(_ context.Context, _ map[string]interface{}) (string, error) {
+	return "legacy", nil
+}
+func (t *structuredResultTool) ExecuteResult(_ context.Context, _ map[string]interface{}) (ToolResult, error) {
+	return ToolResult{Content: "summary", Structured: map[string]interface{}{"count": 2}}, t.err
+}
+
+func TestExecuteToolCalls_SerializesStructuredResult(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&structuredResultTool{})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "structured", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{})
+
+	if want := "summary\n\n{\"count\":2}"; results[0].Content != want {
+		t.Fatalf("Content = %q, want %q", results[0].Content, want)
+	}
+}
+
+func TestExecuteToolCalls_DropsStructuredResultOnError(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&structuredResultTool{err: fmt.Errorf("boom")})
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "structured", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{})
+
+	if results[0].Content != "Error: boom" {
+		t.Fatalf("Content = %q, want Error: boom", results[0].Content)
+	}
+}
+
 func TestExecuteToolCalls_CallsOnToolStart(t *testing.T) {
 	registry := NewToolRegistry()
 	registry.Register(&execTestTool{name: "slow", delay: 20 * time.Millisecond, result: "ok"})
@@ -227,3 +572,47 @@ func TestExecuteToolCalls_CallsOnToolStart(t *testing.T) {
 		t.Fatalf("OnToolStart calls = %d, want 1", starts.Load())
 	}
 }
+
+func TestExecuteToolCalls_ForwardsToolProgress(t *testing.T) {
+	registry := NewToolRegistry()
+	registry.Register(&progressReportingTool{})
+
+	var mu sync.Mutex
+	var got []string
+	registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "reporter", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{
+		OnToolProgress: func(index int, call providers.ToolCall, message string) {
+			mu.Lock()
+			got = append(got, fmt.Sprintf("%d:%s:%s", index, call.Name, message))
+			mu.Unlock()
+		},
+	})
+
+	if len(got) != 1 || got[0] != "0:reporter:halfway" {
+		t.Fatalf("progress = %v", got)
+	}
+
+	// Without a listener tools see no reporter.
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc2", Name: "reporter", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{})
+	if results[0].Content != "no reporter" {
+		t.Fatalf("expected no reporter without OnToolProgress, got %q", results[0].Content)
+	}
+}
+
+type progressReportingTool struct{}
+
+func (t *progressReportingTool) Name() string        { return "reporter" }
+func (t *progressReportingTool) Description() string { return "reports progress" }
+func (t *progressReportingTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *progressReportingTool) Execute(ctx context.Context, _ map[string]interface{}) (string, error) {
+	if ProgressReporterFromContext(ctx) == nil {
+		return "no reporter", nil
+	}
+	ReportProgress(ctx, "halfway")
+	return "done", nil
+}
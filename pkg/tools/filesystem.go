@@ -24,6 +24,7 @@ type ReadFileTool struct {
 	name                string
 	allowedDir          string
 	restrictToWorkspace bool
+	isolation           WorkspaceIsolation
 }
 
 func NewReadFileTool(allowedDir string) *ReadFileTool {
@@ -38,6 +39,12 @@ func (t *ReadFileTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetWorkspaceIsolation scopes each call to the caller's channel or chat
+// directory under the workspace.
+func (t *ReadFileTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
 func (t *ReadFileTool) Name() string {
 	if t != nil && t.name != "" {
 		return t.name
@@ -130,7 +137,11 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("path is required")
 	}
 
-	resolvedPath, err := resolvePathWithOptionalRootMode(path, t.allowedDir, "workspace", t.restrictToWorkspace)
+	root, err := scopedWorkspace(t.allowedDir, t.isolation, args)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := resolvePathWithOptionalRootMode(path, root, "workspace", t.restrictToWorkspace)
 	if err != nil {
 		return "", err
 	}
@@ -191,6 +202,7 @@ type WriteFileTool struct {
 	name                string
 	allowedDir          string
 	restrictToWorkspace bool
+	isolation           WorkspaceIsolation
 }
 
 func NewWriteFileTool(allowedDir string) *WriteFileTool {
@@ -205,6 +217,12 @@ func (t *WriteFileTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetWorkspaceIsolation scopes each call to the caller's channel or chat
+// directory under the workspace.
+func (t *WriteFileTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
 func (t *WriteFileTool) Name() string {
 	if t != nil && t.name != "" {
 		return t.name
@@ -243,6 +261,12 @@ func (t *WriteFileTool) Parameters() map[string]interface{} {
 	}
 }
 
+// ExclusivityGroup serializes calls on the same file. Writes to one path
+// in a batch apply in call order, each on the result of the previous.
+func (t *WriteFileTool) ExclusivityGroup(args map[string]interface{}) string {
+	return fileExclusivityGroup(t.allowedDir, t.isolation, args)
+}
+
 func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	path, ok := args["path"].(string)
 	if !ok {
@@ -257,7 +281,11 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 		return "", fmt.Errorf("content too large (max %d bytes)", filesystemWriteFileMaxBytes)
 	}
 
-	resolvedPath, err := resolvePathWithOptionalRootMode(path, t.allowedDir, "workspace", t.restrictToWorkspace)
+	root, err := scopedWorkspace(t.allowedDir, t.isolation, args)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := resolvePathWithOptionalRootMode(path, root, "workspace", t.restrictToWorkspace)
 	if err != nil {
 		return "", err
 	}
@@ -293,6 +321,7 @@ type ListDirTool struct {
 	name                string
 	allowedDir          string
 	restrictToWorkspace bool
+	isolation           WorkspaceIsolation
 }
 
 func NewListDirTool(allowedDir string) *ListDirTool {
@@ -307,6 +336,12 @@ func (t *ListDirTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetWorkspaceIsolation scopes each call to the caller's channel or chat
+// directory under the workspace.
+func (t *ListDirTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
 func (t *ListDirTool) Name() string {
 	if t != nil && t.name != "" {
 		return t.name
@@ -351,7 +386,11 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{})
 		path = "."
 	}
 
-	resolvedPath, err := resolvePathWithOptionalRootMode(path, t.allowedDir, "workspace", t.restrictToWorkspace)
+	root, err := scopedWorkspace(t.allowedDir, t.isolation, args)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := resolvePathWithOptionalRootMode(path, root, "workspace", t.restrictToWorkspace)
 	if err != nil {
 		return "", err
 	}
@@ -2,7 +2,10 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/memory"
@@ -41,15 +44,94 @@ func (t *MemorySearchTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Filter by category: preference, fact, event, note, general",
 			},
+			"mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"prefix", "exact", "phrase"},
+				"description": "How words match: prefix (default, 'run' finds 'running'), exact (whole words only) or phrase (the words together in order)",
+			},
 		},
 		"required": []string{"query"},
 	}
 }
 
 func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	results, failure, err := t.search(args)
+	if err != nil || failure != "" {
+		return failure, err
+	}
+
+	if len(results) == 0 {
+		return "No memories found matching the query.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d memories:\n", len(results)))
+	for _, m := range results {
+		date := m.CreatedAt.Format("2006-01-02")
+		sb.WriteString(fmt.Sprintf("[#%d] (%s, %s) %s", m.ID, m.Category, date, m.Content))
+		if origin := memoryOrigin(m); origin != "" {
+			sb.WriteString(" [" + origin + "]")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// ExecuteResult returns the matches as structured entries so the agent can
+// reference exact ids and categories (e.g. for memory_update).
+func (t *MemorySearchTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (ToolResult, error) {
+	results, failure, err := t.search(args)
+	if err != nil || failure != "" {
+		return ToolResult{Content: failure}, err
+	}
+
+	if len(results) == 0 {
+		return ToolResult{Content: "No memories found matching the query."}, nil
+	}
+
+	entries := make([]interface{}, 0, len(results))
+	for _, m := range results {
+		entry := map[string]interface{}{
+			"id":       m.ID,
+			"category": m.Category,
+			"date":     m.CreatedAt.Format("2006-01-02"),
+			"content":  m.Content,
+		}
+		if origin := memoryOrigin(m); origin != "" {
+			entry["origin"] = origin
+		}
+		entries = append(entries, entry)
+	}
+	return ToolResult{
+		Content:    fmt.Sprintf("Found %d memories.", len(results)),
+		Structured: map[string]interface{}{"memories": entries},
+	}, nil
+}
+
+// memoryOrigin describes where a memory came from, e.g. "from telegram chat
+// on 2026-02-20", using the provenance stored in its metadata. Memories
+// without a recorded session return "".
+func memoryOrigin(m memory.Memory) string {
+	sessionKey := m.Metadata["session_key"]
+	if sessionKey == "" {
+		return ""
+	}
+	origin := "from session " + sessionKey
+	if channel := m.Metadata["channel"]; channel != "" {
+		origin = "from " + channel + " chat"
+	}
+	if date := m.Metadata["date"]; date != "" {
+		origin += " on " + date
+	}
+	return origin
+}
+
+// search runs the query described by args. A non-empty failure string is a
+// user-facing error message to return as the tool result.
+func (t *MemorySearchTool) search(args map[string]interface{}) ([]memory.Memory, string, error) {
 	query, ok := args["query"].(string)
 	if !ok || strings.TrimSpace(query) == "" {
-		return "", fmt.Errorf("query is required")
+		return nil, "", fmt.Errorf("query is required")
 	}
 
 	limit := 5
@@ -62,22 +144,17 @@ func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]interfac
 		category = c
 	}
 
-	results, err := t.store.Search(query, limit, category)
+	modeName, _ := args["mode"].(string)
+	mode, err := memory.ParseSearchMode(modeName)
 	if err != nil {
-		return fmt.Sprintf("Search error: %v", err), nil
-	}
-
-	if len(results) == 0 {
-		return "No memories found matching the query.", nil
+		return nil, fmt.Sprintf("Error: %v", err), nil
 	}
 
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Found %d memories:\n", len(results)))
-	for _, m := range results {
-		date := m.CreatedAt.Format("2006-01-02")
-		sb.WriteString(fmt.Sprintf("[#%d] (%s, %s) %s\n", m.ID, m.Category, date, m.Content))
+	results, err := t.store.SearchWithMode(query, limit, category, mode)
+	if err != nil {
+		return nil, fmt.Sprintf("Search error: %v", err), nil
 	}
-	return sb.String(), nil
+	return results, "", nil
 }
 
 // MemoryStoreTool saves new memories to the database with markdown write-through.
@@ -105,9 +182,11 @@ func (t *MemoryStoreTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "The memory content to store",
 			},
+			// No enum: an unknown or miscased category is normalized in
+			// Execute rather than rejected, so the memory is not lost.
 			"category": map[string]interface{}{
 				"type":        "string",
-				"description": "Category: preference, fact, event, note (default: general). Preferences/notes go to MEMORY.md, facts/events go to daily logs.",
+				"description": fmt.Sprintf("Category: %s (default: general). By default preferences/notes go to MEMORY.md, facts/events go to daily logs.", strings.Join(t.store.Categories(), ", ")),
 			},
 		},
 		"required": []string{"content"},
@@ -120,15 +199,280 @@ func (t *MemoryStoreTool) Execute(ctx context.Context, args map[string]interface
 		return "", fmt.Errorf("content is required")
 	}
 
-	category := "general"
-	if c, ok := args["category"].(string); ok && c != "" {
-		category = c
-	}
+	requested, _ := args["category"].(string)
+	category, valid := t.store.NormalizeCategory(requested)
 
 	id, err := t.store.Store(content, category, "chat", nil)
+	if errors.Is(err, memory.ErrMemoryUnavailable) {
+		return memoryUnavailableResult, nil
+	}
 	if err != nil {
 		return fmt.Sprintf("Failed to store memory: %v", err), nil
 	}
 
+	if !valid {
+		return fmt.Sprintf("Memory stored (id=%d, category=%s; unknown category %q, valid: %s)",
+			id, category, requested, strings.Join(t.store.Categories(), ", ")), nil
+	}
 	return fmt.Sprintf("Memory stored (id=%d, category=%s)", id, category), nil
 }
+
+// memoryUnavailableResult is returned by write tools while the memory
+// database is read-only or the disk is full.
+const memoryUnavailableResult = "Memory temporarily unavailable: nothing was saved. Searching existing memories still works; carry on without storing this."
+
+// MemoryUpdateTool corrects an existing memory in place.
+type MemoryUpdateTool struct {
+	store *memory.MemoryStore
+}
+
+func NewMemoryUpdateTool(store *memory.MemoryStore) *MemoryUpdateTool {
+	return &MemoryUpdateTool{store: store}
+}
+
+func (t *MemoryUpdateTool) Name() string {
+	return "memory_update"
+}
+
+func (t *MemoryUpdateTool) Description() string {
+	return "Update an existing memory by id (as shown by memory_search). Use this to correct stale facts instead of storing a contradicting new memory."
+}
+
+func (t *MemoryUpdateTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{
+				"type":        "number",
+				"description": "The memory id to update",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "The corrected memory content",
+			},
+			"category": map[string]interface{}{
+				"type":        "string",
+				"description": fmt.Sprintf("Optional new category: %s (default: unchanged)", strings.Join(t.store.Categories(), ", ")),
+			},
+		},
+		"required": []string{"id", "content"},
+	}
+}
+
+func (t *MemoryUpdateTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	rawID, ok := args["id"].(float64)
+	if !ok || rawID <= 0 {
+		return "", fmt.Errorf("id is required")
+	}
+	id := int64(rawID)
+
+	content, ok := args["content"].(string)
+	if !ok || strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("content is required")
+	}
+
+	category := ""
+	if c, ok := args["category"].(string); ok {
+		category = strings.TrimSpace(c)
+	}
+
+	err := t.store.Update(id, content, category)
+	if errors.Is(err, memory.ErrMemoryUnavailable) {
+		return memoryUnavailableResult, nil
+	}
+	if err != nil {
+		return fmt.Sprintf("Failed to update memory: %v", err), nil
+	}
+
+	return fmt.Sprintf("Memory updated (id=%d)", id), nil
+}
+
+// MemoryStatsTool reports how many memories are stored, per category.
+type MemoryStatsTool struct {
+	store *memory.MemoryStore
+}
+
+func NewMemoryStatsTool(store *memory.MemoryStore) *MemoryStatsTool {
+	return &MemoryStatsTool{store: store}
+}
+
+func (t *MemoryStatsTool) Name() string {
+	return "memory_stats"
+}
+
+func (t *MemoryStatsTool) Description() string {
+	return "Show how many memories are stored in total and per category. Use this before memory_list to see what is known."
+}
+
+func (t *MemoryStatsTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func (t *MemoryStatsTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	stats, err := t.store.Stats()
+	if err != nil {
+		return fmt.Sprintf("Failed to read memory stats: %v", err), nil
+	}
+	if stats.Total == 0 {
+		return "No memories stored.", nil
+	}
+
+	categories := make([]string, 0, len(stats.ByCategory))
+	for category := range stats.ByCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d memories stored:\n", stats.Total))
+	for _, category := range categories {
+		sb.WriteString(fmt.Sprintf("- %s: %d\n", category, stats.ByCategory[category]))
+	}
+	return sb.String(), nil
+}
+
+// MemoryListTool browses the most recent memories, optionally by category.
+type MemoryListTool struct {
+	store *memory.MemoryStore
+}
+
+func NewMemoryListTool(store *memory.MemoryStore) *MemoryListTool {
+	return &MemoryListTool{store: store}
+}
+
+func (t *MemoryListTool) Name() string {
+	return "memory_list"
+}
+
+func (t *MemoryListTool) Description() string {
+	return "List the most recent stored memories, newest first, optionally filtered by category. Use this to answer what you remember about the user instead of guessing."
+}
+
+func (t *MemoryListTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"category": map[string]interface{}{
+				"type":        "string",
+				"enum":        t.store.Categories(),
+				"description": fmt.Sprintf("Only list this category: %s (default: all)", strings.Join(t.store.Categories(), ", ")),
+			},
+			"limit": map[string]interface{}{
+				"type":        "number",
+				"description": "Maximum number of memories (default 10, max 50)",
+			},
+		},
+	}
+}
+
+func (t *MemoryListTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	limit := 10
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	category := ""
+	if requested, ok := args["category"].(string); ok && strings.TrimSpace(requested) != "" {
+		normalized, valid := t.store.NormalizeCategory(requested)
+		if !valid {
+			return fmt.Sprintf("Error: unknown category %q (valid: %s)", requested, strings.Join(t.store.Categories(), ", ")), nil
+		}
+		category = normalized
+	}
+
+	results, err := t.store.List(category, limit)
+	if err != nil {
+		return fmt.Sprintf("Failed to list memories: %v", err), nil
+	}
+	if len(results) == 0 {
+		if category != "" {
+			return fmt.Sprintf("No memories stored in category %s.", category), nil
+		}
+		return "No memories stored.", nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d most recent memories:\n", len(results)))
+	for _, m := range results {
+		date := m.CreatedAt.Format("2006-01-02")
+		sb.WriteString(fmt.Sprintf("[#%d] (%s, %s) %s", m.ID, m.Category, date, m.Content))
+		if origin := memoryOrigin(m); origin != "" {
+			sb.WriteString(" [" + origin + "]")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// DefaultMemoryExportPath is where memory_export writes when no path is
+// given, relative to the workspace.
+const DefaultMemoryExportPath = "memory-snapshot.md"
+
+// MemoryExportTool writes all memories to one markdown file in the
+// workspace, for review or sharing.
+type MemoryExportTool struct {
+	store     *memory.MemoryStore
+	workspace string
+	isolation WorkspaceIsolation
+}
+
+func NewMemoryExportTool(store *memory.MemoryStore, workspace string) *MemoryExportTool {
+	return &MemoryExportTool{store: store, workspace: workspace}
+}
+
+// SetWorkspaceIsolation writes each export under the caller's channel or
+// chat directory, where its file tools can reach it.
+func (t *MemoryExportTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
+func (t *MemoryExportTool) Name() string {
+	return "memory_export"
+}
+
+func (t *MemoryExportTool) Description() string {
+	return "Export all stored memories to a single markdown file in the workspace, grouped by category with dates. Use this when the user wants to review or share what is remembered."
+}
+
+func (t *MemoryExportTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": fmt.Sprintf("Output file relative to the workspace (default %s)", DefaultMemoryExportPath),
+			},
+		},
+	}
+}
+
+func (t *MemoryExportTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		path = DefaultMemoryExportPath
+	}
+	root, err := scopedWorkspace(t.workspace, t.isolation, args)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	resolved, err := resolvePathWithOptionalRoot(path, root, "workspace")
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	// The memory directory holds the write-through files that get
+	// reindexed; a snapshot there could overwrite or duplicate them.
+	memoryDir, _ := filepath.Abs(filepath.Join(t.workspace, "memory"))
+	if rel, err := filepath.Rel(memoryDir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "Error: export path must be outside the memory directory", nil
+	}
+	if err := t.store.ExportSnapshot(resolved); err != nil {
+		return fmt.Sprintf("Failed to export memories: %v", err), nil
+	}
+	return fmt.Sprintf("Exported memories to %s", resolved), nil
+}
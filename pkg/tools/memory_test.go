@@ -50,6 +50,88 @@ func TestMemorySearchTool_Execute(t *testing.T) {
 	}
 }
 
+func TestMemorySearchTool_Mode(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+	store.Store("room has darkness", "note", "chat", nil)
+
+	tool := NewMemorySearchTool(store)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{"query": "dark", "mode": "exact"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "Found 1 memories") || strings.Contains(result, "darkness") {
+		t.Errorf("exact mode should only match the whole word, got:\n%s", result)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"query": "dark", "mode": "fuzzy"})
+	if !strings.Contains(result, "unknown search mode") {
+		t.Errorf("expected unknown mode error, got:\n%s", result)
+	}
+}
+
+func TestMemorySearchTool_ShowsProvenance(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "summarization", map[string]string{
+		"session_key": "telegram:42",
+		"channel":     "telegram",
+		"chat_id":     "42",
+		"date":        "2026-02-20",
+	})
+	store.Store("user likes dark chocolate", "preference", "chat", nil)
+
+	tool := NewMemorySearchTool(store)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{"query": "dark"})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "dark mode [from telegram chat on 2026-02-20]") {
+		t.Errorf("expected provenance for extracted memory, got:\n%s", result)
+	}
+	if !strings.Contains(result, "dark chocolate\n") {
+		t.Errorf("expected memory without provenance to have no origin, got:\n%s", result)
+	}
+}
+
+func TestMemorySearchTool_ExecuteResultIsStructured(t *testing.T) {
+	store := newTestMemoryStore(t)
+	id, _ := store.Store("user prefers dark mode", "preference", "chat", nil)
+
+	tool := NewMemorySearchTool(store)
+	result, err := tool.ExecuteResult(context.Background(), map[string]interface{}{
+		"query": "dark mode",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteResult failed: %v", err)
+	}
+	entries, ok := result.Structured["memories"].([]interface{})
+	if !ok || len(entries) != 1 {
+		t.Fatalf("expected 1 structured entry, got %+v", result.Structured)
+	}
+	entry := entries[0].(map[string]interface{})
+	if entry["id"] != id || entry["category"] != "preference" || entry["content"] != "user prefers dark mode" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestMemorySearchTool_ExecuteResultNoMatchesHasNoStructured(t *testing.T) {
+	store := newTestMemoryStore(t)
+	tool := NewMemorySearchTool(store)
+
+	result, err := tool.ExecuteResult(context.Background(), map[string]interface{}{
+		"query": "nonexistent",
+	})
+	if err != nil {
+		t.Fatalf("ExecuteResult failed: %v", err)
+	}
+	if result.Structured != nil {
+		t.Fatalf("expected nil structured, got %+v", result.Structured)
+	}
+	if !strings.Contains(result.Content, "No memories found") {
+		t.Fatalf("unexpected content %q", result.Content)
+	}
+}
+
 func TestMemorySearchTool_WithCategory(t *testing.T) {
 	store := newTestMemoryStore(t)
 	store.Store("user prefers Go", "preference", "chat", nil)
@@ -148,6 +230,64 @@ func TestMemoryStoreTool_DefaultCategory(t *testing.T) {
 	}
 }
 
+func TestMemoryStoreTool_UnknownCategoryNormalized(t *testing.T) {
+	store := newTestMemoryStore(t)
+	tool := NewMemoryStoreTool(store)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"content":  "typo category",
+		"category": "prefernce",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "category=general") || !strings.Contains(result, `unknown category "prefernce"`) {
+		t.Errorf("expected normalization notice, got:\n%s", result)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{
+		"content":  "likes vim",
+		"category": "Preference",
+	})
+	if !strings.Contains(result, "category=preference)") {
+		t.Errorf("expected case-insensitive category, got:\n%s", result)
+	}
+}
+
+func TestMemoryStoreTool_ReadOnlyDatabaseReturnsSoftResult(t *testing.T) {
+	workspace := t.TempDir()
+	dbPath := filepath.Join(workspace, "memory", "memory.db")
+	writable, err := memory.NewMemoryStore(dbPath, workspace)
+	if err != nil {
+		t.Fatalf("NewMemoryStore failed: %v", err)
+	}
+	writable.Store("user prefers dark mode", "preference", "chat", nil)
+	writable.Close()
+
+	// Reopen read-only, as if the file lost write permission.
+	store, err := memory.NewMemoryStore("file:"+dbPath+"?mode=ro", workspace)
+	if err != nil {
+		t.Fatalf("NewMemoryStore (read-only) failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	result, err := NewMemoryStoreTool(store).Execute(context.Background(), map[string]interface{}{
+		"content":  "user works at Sipeed",
+		"category": "fact",
+	})
+	if err != nil {
+		t.Fatalf("expected a soft result, got error %v", err)
+	}
+	if result != memoryUnavailableResult {
+		t.Fatalf("result = %q, want the memory-unavailable message", result)
+	}
+
+	result, err = NewMemorySearchTool(store).Execute(context.Background(), map[string]interface{}{"query": "dark"})
+	if err != nil || !strings.Contains(result, "dark mode") {
+		t.Fatalf("search while read-only = %q, %v; want the stored memory", result, err)
+	}
+}
+
 func TestMemoryStoreTool_MissingContent(t *testing.T) {
 	store := newTestMemoryStore(t)
 	tool := NewMemoryStoreTool(store)
@@ -158,6 +298,35 @@ func TestMemoryStoreTool_MissingContent(t *testing.T) {
 	}
 }
 
+func TestMemoryStoreTool_AcceptsConfiguredCategory(t *testing.T) {
+	workspace := filepath.Join(t.TempDir(), "workspace")
+	store, err := memory.NewMemoryStoreWithOptions(filepath.Join(workspace, "memory", "memory.db"), workspace, memory.StoreOptions{
+		MarkdownTargets: map[string]string{"project": "PROJECT.md"},
+	})
+	if err != nil {
+		t.Fatalf("NewMemoryStoreWithOptions failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	tool := NewMemoryStoreTool(store)
+
+	props := tool.Parameters()["properties"].(map[string]interface{})
+	desc := props["category"].(map[string]interface{})["description"].(string)
+	if !strings.Contains(desc, "project") {
+		t.Errorf("expected project in the category description, got %q", desc)
+	}
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"content":  "launch is on Friday",
+		"category": "project",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "category=project") || strings.Contains(result, "unknown category") {
+		t.Errorf("unexpected result: %s", result)
+	}
+}
+
 func TestMemoryStoreTool_Parameters(t *testing.T) {
 	tool := NewMemoryStoreTool(nil)
 	params := tool.Parameters()
@@ -173,3 +342,198 @@ func TestMemoryStoreTool_Parameters(t *testing.T) {
 		t.Error("expected 'category' parameter")
 	}
 }
+
+// --- MemoryUpdateTool ---
+
+func TestMemoryUpdateTool_Name(t *testing.T) {
+	tool := NewMemoryUpdateTool(nil)
+	if tool.Name() != "memory_update" {
+		t.Errorf("expected name 'memory_update', got %q", tool.Name())
+	}
+}
+
+func TestMemoryUpdateTool_Execute(t *testing.T) {
+	store := newTestMemoryStore(t)
+	id, _ := store.Store("user lives in Tokyo", "fact", "chat", nil)
+
+	tool := NewMemoryUpdateTool(store)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"id":      float64(id),
+		"content": "user lives in Osaka",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "updated") {
+		t.Errorf("expected confirmation, got:\n%s", result)
+	}
+
+	mem, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if mem.Content != "user lives in Osaka" {
+		t.Errorf("expected updated content, got %q", mem.Content)
+	}
+}
+
+func TestMemoryUpdateTool_UnknownID(t *testing.T) {
+	store := newTestMemoryStore(t)
+	tool := NewMemoryUpdateTool(store)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"id":      float64(42),
+		"content": "anything",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "Failed to update memory") {
+		t.Errorf("expected failure message, got:\n%s", result)
+	}
+}
+
+func TestMemoryUpdateTool_MissingArgs(t *testing.T) {
+	tool := NewMemoryUpdateTool(newTestMemoryStore(t))
+
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"content": "x"}); err == nil {
+		t.Error("expected error for missing id")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]interface{}{"id": float64(1)}); err == nil {
+		t.Error("expected error for missing content")
+	}
+}
+
+// --- MemoryStatsTool / MemoryListTool ---
+
+func TestMemoryStatsTool_CountsPerCategory(t *testing.T) {
+	store := newTestMemoryStore(t)
+	tool := NewMemoryStatsTool(store)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if result != "No memories stored." {
+		t.Fatalf("empty store result = %q", result)
+	}
+
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+	store.Store("user prefers tea", "preference", "chat", nil)
+	store.Store("user works at Sipeed", "fact", "chat", nil)
+	store.Store("shipped v1 today", "event", "chat", nil)
+
+	result, err = tool.Execute(context.Background(), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	for _, want := range []string{"4 memories stored", "- event: 1", "- fact: 1", "- preference: 2"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result, got:\n%s", want, result)
+		}
+	}
+	if strings.Index(result, "event") > strings.Index(result, "preference") {
+		t.Errorf("expected categories in sorted order, got:\n%s", result)
+	}
+}
+
+func TestMemoryListTool_FiltersByCategory(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+	store.Store("user works at Sipeed", "fact", "chat", nil)
+	store.Store("user prefers tea", "preference", "chat", nil)
+
+	tool := NewMemoryListTool(store)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"category": "Preference",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "dark mode") || !strings.Contains(result, "tea") {
+		t.Errorf("expected both preferences, got:\n%s", result)
+	}
+	if strings.Contains(result, "Sipeed") {
+		t.Errorf("expected facts to be filtered out, got:\n%s", result)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"limit": float64(1)})
+	if !strings.Contains(result, "1 most recent memories") {
+		t.Errorf("expected limit to apply, got:\n%s", result)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"category": "event"})
+	if result != "No memories stored in category event." {
+		t.Errorf("empty category result = %q", result)
+	}
+}
+
+func TestMemoryListTool_RejectsUnknownCategory(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+
+	result, err := NewMemoryListTool(store).Execute(context.Background(), map[string]interface{}{
+		"category": "secrets",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.HasPrefix(result, "Error: unknown category") {
+		t.Errorf("expected unknown category error, got %q", result)
+	}
+}
+
+// --- MemoryExportTool ---
+
+func TestMemoryExportTool_WritesSnapshotInWorkspace(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+	store.Store("user works at Sipeed", "fact", "chat", nil)
+	workspace := t.TempDir()
+	tool := NewMemoryExportTool(store, workspace)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "Exported memories") {
+		t.Fatalf("unexpected result %q", result)
+	}
+	data, err := os.ReadFile(filepath.Join(workspace, DefaultMemoryExportPath))
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	if !strings.Contains(string(data), "## preference") || !strings.Contains(string(data), "## fact") {
+		t.Fatalf("unexpected snapshot:\n%s", data)
+	}
+}
+
+func TestMemoryExportTool_WritesIntoIsolatedWorkspace(t *testing.T) {
+	store := newTestMemoryStore(t)
+	store.Store("user prefers dark mode", "preference", "chat", nil)
+	workspace := t.TempDir()
+	tool := NewMemoryExportTool(store, workspace)
+	tool.SetWorkspaceIsolation(WorkspacePerChat)
+
+	if result, _ := tool.Execute(context.Background(), withExecutionContext(map[string]interface{}{}, "telegram", "42", "")); !strings.Contains(result, "Exported memories") {
+		t.Fatalf("unexpected result %q", result)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "telegram", "42", DefaultMemoryExportPath)); err != nil {
+		t.Fatalf("snapshot not written to the chat's workspace: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, DefaultMemoryExportPath)); !os.IsNotExist(err) {
+		t.Fatalf("snapshot written to the shared workspace: %v", err)
+	}
+}
+
+func TestMemoryExportTool_RejectsPathsOutsideWorkspaceOrInMemoryDir(t *testing.T) {
+	store := newTestMemoryStore(t)
+	tool := NewMemoryExportTool(store, t.TempDir())
+
+	for _, path := range []string{"../escape.md", "memory/MEMORY.md"} {
+		result, _ := tool.Execute(context.Background(), map[string]interface{}{"path": path})
+		if !strings.HasPrefix(result, "Error:") {
+			t.Fatalf("path %q: expected error, got %q", path, result)
+		}
+	}
+}
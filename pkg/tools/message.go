@@ -8,7 +8,9 @@ import (
 	"sync"
 )
 
-type SendCallback func(channel, chatID, content string, media []string) error
+// SendCallback delivers a message. replyTo is the ID of the inbound message
+// it answers when it goes to the chat the turn came from, else "".
+type SendCallback func(channel, chatID, content string, media []string, replyTo string) error
 
 type MessageTool struct {
 	mu                       sync.RWMutex
@@ -190,7 +192,12 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]interface{})
 		return "Error: message content or media is required", nil
 	}
 
-	if err := callback(channel, chatID, content, media); err != nil {
+	replyTo := ""
+	if channel == ctxChannel && chatID == ctxChatID {
+		replyTo = getExecutionMessageID(args)
+	}
+
+	if err := callback(channel, chatID, content, media, replyTo); err != nil {
 		return fmt.Sprintf("Error sending message: %v", err), nil
 	}
 
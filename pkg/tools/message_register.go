@@ -24,7 +24,7 @@ func RegisterMessageTool(registry *ToolRegistry, msgBus *bus.MessageBus, workspa
 	tool.SetWorkspaceRoot(workspace)
 	tool.SetForceContextTarget(opts.ForceContextTarget)
 	tool.SetRestrictMediaToWorkspace(opts.RestrictMediaToWorkspace)
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		if msgBus == nil {
 			return errors.New("message bus not configured")
 		}
@@ -33,6 +33,8 @@ func RegisterMessageTool(registry *ToolRegistry, msgBus *bus.MessageBus, workspa
 			ChatID:  chatID,
 			Content: content,
 			Media:   media,
+			ReplyTo: replyTo,
+			Final:   true,
 		})
 		return nil
 	})
@@ -41,6 +41,9 @@ func TestRegisterMessageTool_PublishesOutbound(t *testing.T) {
 	if out.Content != "hi" {
 		t.Fatalf("content=%q, want %q", out.Content, "hi")
 	}
+	if !out.Final {
+		t.Fatal("expected the agent's reply to be marked final")
+	}
 }
 
 func TestRegisterMessageTool_ForceContextTarget_IgnoresOverride(t *testing.T) {
@@ -106,3 +109,40 @@ func TestRegisterMessageTool_RestrictMediaToWorkspace_BlocksOutsideAbsolutePath(
 		t.Fatal("expected no outbound message")
 	}
 }
+
+func TestRegisterMessageTool_RepliesCarryInboundMessageID(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	registry := NewToolRegistry()
+	RegisterMessageTool(registry, msgBus, t.TempDir(), MessageToolOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := registry.ExecuteWithContext(context.Background(), "message", map[string]interface{}{
+		"content":              "answer",
+		"__context_message_id": "42",
+	}, "telegram", "chat1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ReplyTo != "42" {
+		t.Fatalf("reply to the turn's chat: ReplyTo = %q, want 42", out.ReplyTo)
+	}
+
+	// A message to another chat answers nothing there.
+	_, err = registry.ExecuteWithContext(context.Background(), "message", map[string]interface{}{
+		"content":              "fyi",
+		"chat_id":              "chat2",
+		"__context_message_id": "42",
+	}, "telegram", "chat1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, ok = msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "chat2" || out.ReplyTo != "" {
+		t.Fatalf("message to another chat: %+v, want no ReplyTo", out)
+	}
+}
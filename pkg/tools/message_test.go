@@ -55,7 +55,7 @@ func TestMessageTool_Execute_UsesExplicitChannelChat(t *testing.T) {
 	var gotChannel, gotChatID, gotContent string
 	var gotMedia []string
 
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		gotChannel = channel
 		gotChatID = chatID
 		gotContent = content
@@ -94,7 +94,7 @@ func TestMessageTool_Execute_WithMedia(t *testing.T) {
 
 	var gotMedia []string
 
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		gotMedia = media
 		return nil
 	})
@@ -139,7 +139,7 @@ func TestMessageTool_Execute_ResolvesRelativeMediaAgainstWorkspaceRoot(t *testin
 	tool.SetWorkspaceRoot(root)
 
 	var gotMedia []string
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		gotMedia = media
 		return nil
 	})
@@ -168,7 +168,7 @@ func TestMessageTool_Execute_BlocksRelativeMediaOutsideWorkspace(t *testing.T) {
 	tool.SetWorkspaceRoot(root)
 
 	called := false
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		called = true
 		return nil
 	})
@@ -192,7 +192,7 @@ func TestMessageTool_Execute_BlocksRelativeMediaOutsideWorkspace(t *testing.T) {
 
 func TestMessageTool_Execute_NoContent(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return nil
 	})
 
@@ -220,7 +220,7 @@ func TestMessageTool_Execute_NoCallback(t *testing.T) {
 
 func TestMessageTool_Execute_NoChannel(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return nil
 	})
 
@@ -237,7 +237,7 @@ func TestMessageTool_Execute_NoChannel(t *testing.T) {
 
 func TestMessageTool_Execute_CallbackError(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		return fmt.Errorf("network error")
 	})
 
@@ -257,7 +257,7 @@ func TestMessageTool_Execute_CallbackError(t *testing.T) {
 func TestMessageTool_Execute_RejectsEmptyPayload(t *testing.T) {
 	tool := NewMessageTool()
 	called := false
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		called = true
 		return nil
 	})
@@ -281,7 +281,7 @@ func TestMessageTool_Execute_RejectsEmptyPayload(t *testing.T) {
 func TestMessageTool_Execute_AllowsMediaOnlyPayload(t *testing.T) {
 	tool := NewMessageTool()
 	called := false
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		called = true
 		if len(media) != 1 || media[0] != "/tmp/image.png" {
 			t.Fatalf("media = %v, want [/tmp/image.png]", media)
@@ -309,7 +309,7 @@ func TestMessageTool_ExecuteWithRegistryContext(t *testing.T) {
 	registry.Register(tool)
 
 	var gotChannel, gotChatID string
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		gotChannel = channel
 		gotChatID = chatID
 		return nil
@@ -333,7 +333,7 @@ func TestMessageTool_ConcurrentExecuteWithDifferentContext_NoCrossTalk(t *testin
 	registry.Register(tool)
 
 	var mismatches atomic.Int32
-	tool.SetSendCallback(func(channel, chatID, content string, media []string) error {
+	tool.SetSendCallback(func(channel, chatID, content string, media []string, replyTo string) error {
 		if content != chatID {
 			mismatches.Add(1)
 		}
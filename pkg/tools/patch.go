@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// PatchFileTool applies a unified diff or search/replace blocks to one file.
+// Every hunk must match exactly one place; otherwise nothing is written.
+type PatchFileTool struct {
+	allowedDir          string
+	restrictToWorkspace bool
+	isolation           WorkspaceIsolation
+}
+
+// NewPatchFileTool creates a PatchFileTool limited to allowedDir.
+func NewPatchFileTool(allowedDir string) *PatchFileTool {
+	return &PatchFileTool{
+		allowedDir:          allowedDir,
+		restrictToWorkspace: true,
+	}
+}
+
+func (t *PatchFileTool) SetRestrictToWorkspace(restrict bool) {
+	t.restrictToWorkspace = restrict
+}
+
+// SetWorkspaceIsolation scopes each call to the caller's channel or chat
+// directory under the workspace.
+func (t *PatchFileTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
+func (t *PatchFileTool) Name() string {
+	return "patch_file"
+}
+
+func (t *PatchFileTool) Description() string {
+	return "Apply a patch to one file: a unified diff (@@ hunks with ' ', '-', '+' lines) or search/replace blocks (<<<<<<< SEARCH / ======= / >>>>>>> REPLACE). Each hunk's context must match exactly one place in the file, or nothing is changed. Prefer this over write_file for edits to large files."
+}
+
+func (t *PatchFileTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "The file path to patch",
+			},
+			"patch": map[string]interface{}{
+				"type":        "string",
+				"description": "Unified diff hunks or SEARCH/REPLACE blocks for this file",
+			},
+		},
+		"required": []string{"path", "patch"},
+	}
+}
+
+// patchHunk replaces the lines in oldLines with newLines.
+type patchHunk struct {
+	oldLines []string
+	newLines []string
+}
+
+// ExclusivityGroup serializes calls on the same file. Patches to one path
+// in a batch apply in call order, each on the result of the previous.
+func (t *PatchFileTool) ExclusivityGroup(args map[string]interface{}) string {
+	return fileExclusivityGroup(t.allowedDir, t.isolation, args)
+}
+
+func (t *PatchFileTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	patch, ok := args["patch"].(string)
+	if !ok || strings.TrimSpace(patch) == "" {
+		return "", fmt.Errorf("patch is required")
+	}
+
+	root, err := scopedWorkspace(t.allowedDir, t.isolation, args)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := resolvePathWithOptionalRootMode(path, root, "workspace", t.restrictToWorkspace)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(resolvedPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	content, err := os.ReadFile(resolvedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	hunks, err := parsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+
+	updated, applied, err := applyPatchHunks(string(content), hunks)
+	if err != nil {
+		return "", fmt.Errorf("%w (no changes were written to %s)", err, path)
+	}
+
+	if err := utils.AtomicWriteFile(resolvedPath, []byte(updated), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Applied %d hunk(s) to %s:\n", len(applied), path))
+	for _, line := range applied {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// parsePatch reads either search/replace blocks or unified diff hunks.
+func parsePatch(patch string) ([]patchHunk, error) {
+	patch = strings.ReplaceAll(patch, "\r\n", "\n")
+	var hunks []patchHunk
+	var err error
+	if strings.Contains(patch, "<<<<<<< SEARCH") {
+		hunks, err = parseSearchReplaceBlocks(patch)
+	} else {
+		hunks, err = parseUnifiedDiff(patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("patch contains no hunks: use @@ hunks or SEARCH/REPLACE blocks")
+	}
+	for i, h := range hunks {
+		if len(h.oldLines) == 0 {
+			return nil, fmt.Errorf("hunk %d has no context or removed lines to anchor it", i+1)
+		}
+	}
+	return hunks, nil
+}
+
+func parseSearchReplaceBlocks(patch string) ([]patchHunk, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+	var hunks []patchHunk
+	var cur patchHunk
+	state := outside
+	for _, line := range strings.Split(patch, "\n") {
+		marker := strings.TrimSpace(line)
+		switch {
+		case marker == "<<<<<<< SEARCH":
+			if state != outside {
+				return nil, fmt.Errorf("block %d: unexpected SEARCH marker before REPLACE marker", len(hunks)+1)
+			}
+			cur = patchHunk{}
+			state = inSearch
+		case marker == "=======" && state == inSearch:
+			state = inReplace
+		case marker == ">>>>>>> REPLACE":
+			if state != inReplace {
+				return nil, fmt.Errorf("block %d: REPLACE marker without a matching SEARCH and ======= marker", len(hunks)+1)
+			}
+			hunks = append(hunks, cur)
+			state = outside
+		case state == inSearch:
+			cur.oldLines = append(cur.oldLines, line)
+		case state == inReplace:
+			cur.newLines = append(cur.newLines, line)
+		}
+	}
+	if state != outside {
+		return nil, fmt.Errorf("block %d is not closed with >>>>>>> REPLACE", len(hunks)+1)
+	}
+	return hunks, nil
+}
+
+func parseUnifiedDiff(patch string) ([]patchHunk, error) {
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	var hunks []patchHunk
+	var cur *patchHunk
+	files := 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// File headers: "--- a/x" immediately followed by "+++ b/x".
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			files++
+			if files > 1 {
+				return nil, fmt.Errorf("patch touches more than one file; send one patch per file")
+			}
+			i++
+			cur = nil
+			continue
+		}
+		if strings.HasPrefix(line, "@@") {
+			hunks = append(hunks, patchHunk{})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+		if cur == nil {
+			if strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index ") || strings.TrimSpace(line) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("unexpected line before the first @@ hunk header: %q", line)
+		}
+		switch {
+		case line == "":
+			// Blank context lines often lose their leading space.
+			cur.oldLines = append(cur.oldLines, "")
+			cur.newLines = append(cur.newLines, "")
+		case line[0] == ' ':
+			cur.oldLines = append(cur.oldLines, line[1:])
+			cur.newLines = append(cur.newLines, line[1:])
+		case line[0] == '-':
+			cur.oldLines = append(cur.oldLines, line[1:])
+		case line[0] == '+':
+			cur.newLines = append(cur.newLines, line[1:])
+		case line[0] == '\\':
+			// "\ No newline at end of file"
+		default:
+			return nil, fmt.Errorf("hunk %d: line %q must start with ' ', '-' or '+'", len(hunks), line)
+		}
+	}
+	return hunks, nil
+}
+
+// applyPatchHunks applies hunks in order to content and returns the result
+// plus a one-line description per applied hunk. It fails on the first hunk
+// that matches nowhere or in more than one place.
+func applyPatchHunks(content string, hunks []patchHunk) (string, []string, error) {
+	crlf := strings.Contains(content, "\r\n")
+	if crlf {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	applied := make([]string, 0, len(hunks))
+	for i, h := range hunks {
+		matches := findLineSequence(lines, h.oldLines)
+		switch len(matches) {
+		case 0:
+			return "", nil, fmt.Errorf("hunk %d of %d did not match the file; expected these lines:\n%s",
+				i+1, len(hunks), strings.Join(h.oldLines, "\n"))
+		case 1:
+		default:
+			at := make([]string, 0, len(matches))
+			for _, m := range matches {
+				at = append(at, fmt.Sprintf("%d", m+1))
+			}
+			return "", nil, fmt.Errorf("hunk %d of %d is ambiguous: it matches at lines %s; add more context lines",
+				i+1, len(hunks), strings.Join(at, ", "))
+		}
+
+		start := matches[0]
+		next := make([]string, 0, len(lines)-len(h.oldLines)+len(h.newLines))
+		next = append(next, lines[:start]...)
+		next = append(next, h.newLines...)
+		next = append(next, lines[start+len(h.oldLines):]...)
+		lines = next
+
+		applied = append(applied, fmt.Sprintf("- hunk %d at line %d: -%d +%d lines",
+			i+1, start+1, len(h.oldLines), len(h.newLines)))
+	}
+
+	out := strings.Join(lines, "\n")
+	if trailingNewline {
+		out += "\n"
+	}
+	if crlf {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	return out, applied, nil
+}
+
+// findLineSequence returns every index where want appears in lines.
+func findLineSequence(lines, want []string) []int {
+	var matches []int
+	for i := 0; i+len(want) <= len(lines); i++ {
+		found := true
+		for j := range want {
+			if lines[i+j] != want[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patchTestFile = `package main
+
+func a() {
+	return 1
+}
+
+func b() {
+	return 1
+}
+`
+
+func writePatchTestFile(t *testing.T) (string, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "main.go")
+	if err := os.WriteFile(path, []byte(patchTestFile), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	return workspace, path
+}
+
+func TestPatchFileTool_AppliesUnifiedDiff(t *testing.T) {
+	workspace, path := writePatchTestFile(t)
+
+	result, err := NewPatchFileTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"path": "main.go",
+		"patch": `--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ func a() {
+-	return 1
++	return 2
+ }
+@@ -7,3 +7,4 @@
+ func b() {
++	// b stays at one
+ 	return 1
+`,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "Applied 2 hunk(s)") || !strings.Contains(result, "hunk 1 at line 3: -3 +3 lines") {
+		t.Fatalf("unexpected result:\n%s", result)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := strings.Replace(patchTestFile, "func a() {\n\treturn 1", "func a() {\n\treturn 2", 1)
+	want = strings.Replace(want, "func b() {\n", "func b() {\n\t// b stays at one\n", 1)
+	if string(data) != want {
+		t.Fatalf("content = %q, want %q", data, want)
+	}
+}
+
+func TestPatchFileTool_AppliesSearchReplaceBlocks(t *testing.T) {
+	workspace, path := writePatchTestFile(t)
+
+	_, err := NewPatchFileTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"path": "main.go",
+		"patch": `<<<<<<< SEARCH
+func b() {
+	return 1
+=======
+func b() {
+	return 3
+>>>>>>> REPLACE
+`,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "func b() {\n\treturn 3\n}") || !strings.Contains(string(data), "func a() {\n\treturn 1\n}") {
+		t.Fatalf("unexpected content:\n%s", data)
+	}
+}
+
+func TestPatchFileTool_ContextMismatchWritesNothing(t *testing.T) {
+	workspace, path := writePatchTestFile(t)
+
+	_, err := NewPatchFileTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"path": "main.go",
+		"patch": `@@ -1,3 +1,3 @@
+ func a() {
+-	return 1
++	return 2
+@@ -7,3 +7,3 @@
+ func c() {
+-	return 1
++	return 2
+`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "hunk 2 of 2 did not match") {
+		t.Fatalf("expected hunk 2 mismatch error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "func c() {") || !strings.Contains(err.Error(), "no changes were written") {
+		t.Fatalf("expected error to show the expected lines, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != patchTestFile {
+		t.Fatalf("file changed despite failed hunk:\n%s", data)
+	}
+}
+
+func TestPatchFileTool_RejectsAmbiguousHunk(t *testing.T) {
+	workspace, path := writePatchTestFile(t)
+
+	_, err := NewPatchFileTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"path": "main.go",
+		"patch": `@@ -1,2 +1,2 @@
+-	return 1
++	return 2
+ }
+`,
+	})
+	if err == nil || !strings.Contains(err.Error(), "ambiguous: it matches at lines 4, 8") {
+		t.Fatalf("expected ambiguity error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != patchTestFile {
+		t.Fatalf("file changed despite ambiguous hunk:\n%s", data)
+	}
+}
+
+func TestPatchFileTool_RejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "workspace")
+	if err := os.MkdirAll(workspace, 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	outside := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(outside, []byte("token=1\n"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	tool := NewPatchFileTool(workspace)
+	for _, path := range []string{"../secret.txt", outside} {
+		_, err := tool.Execute(context.Background(), map[string]interface{}{
+			"path":  path,
+			"patch": "@@ -1 +1 @@\n-token=1\n+token=2\n",
+		})
+		if err == nil || !strings.Contains(err.Error(), "outside workspace") {
+			t.Fatalf("path %q: expected outside-workspace error, got %v", path, err)
+		}
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "token=1\n" {
+		t.Fatalf("file outside workspace was modified: %q", data)
+	}
+}
+
+func TestPatchFileTool_PreservesCRLF(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "notes.txt")
+	if err := os.WriteFile(path, []byte("one\r\ntwo\r\nthree\r\n"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	_, err := NewPatchFileTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"path":  "notes.txt",
+		"patch": "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n",
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\r\n2\r\nthree\r\n" {
+		t.Fatalf("content = %q", data)
+	}
+}
+
+func TestParsePatch_RejectsMalformedPatches(t *testing.T) {
+	cases := map[string]string{
+		"no hunks":      "just some text",
+		"two files":     "--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n",
+		"pure insert":   "@@ -0,0 +1 @@\n+new line\n",
+		"unclosed":      "<<<<<<< SEARCH\nold\n=======\nnew\n",
+		"bad hunk line": "@@ -1 +1 @@\n-a\n*b\n",
+	}
+	for name, patch := range cases {
+		if _, err := parsePatch(patch); err == nil {
+			t.Errorf("%s: expected parse error", name)
+		}
+	}
+}
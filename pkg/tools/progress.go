@@ -0,0 +1,35 @@
+package tools
+
+import "context"
+
+// ProgressReporter receives short, user-facing progress lines from a running
+// tool ("still running after 30s..."). It may be called from any goroutine
+// and must not block.
+type ProgressReporter func(message string)
+
+type progressContextKey struct{}
+
+// WithProgressReporter attaches reporter to ctx for the tool being run.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	if reporter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressContextKey{}, reporter)
+}
+
+// ProgressReporterFromContext returns the reporter attached to ctx, or nil
+// when nobody is listening.
+func ProgressReporterFromContext(ctx context.Context) ProgressReporter {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(progressContextKey{}).(ProgressReporter)
+	return r
+}
+
+// ReportProgress sends message to the reporter in ctx, if any.
+func ReportProgress(ctx context.Context, message string) {
+	if r := ProgressReporterFromContext(ctx); r != nil {
+		r(message)
+	}
+}
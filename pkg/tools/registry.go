@@ -10,15 +10,31 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ToolRegistry struct {
 	tools  map[string]Tool
 	policy ToolExecutionPolicy
 	unsafe *UnsafeToolGate
-	mu     sync.RWMutex
+	cache  *toolResultCache
+	// lenientArgs passes arguments that fail schema validation through to
+	// the tool (with a warning) instead of rejecting the call.
+	lenientArgs bool
+	// previewChars caps tool arguments and results quoted in log lines
+	// (<=0 = DefaultToolPreviewChars).
+	previewChars int
+	// resultMaxBytes caps tool result text fed back to the model (0 = no
+	// cap); resultMaxBytesByTool overrides it per tool.
+	resultMaxBytes       int
+	resultMaxBytesByTool map[string]int
+	mu                   sync.RWMutex
 }
 
+// DefaultToolPreviewChars is the log preview length for tool arguments and
+// results when none is configured.
+const DefaultToolPreviewChars = 200
+
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
 		tools: make(map[string]Tool),
@@ -41,6 +57,63 @@ func (r *ToolRegistry) SetUnsafeToolGate(gate *UnsafeToolGate) {
 	r.unsafe = gate
 }
 
+// SetArgValidation controls whether calls whose arguments break the tool's
+// schema (missing required fields, wrong types, values outside an enum) are
+// rejected with an InvalidArgumentsError. It is on by default; when off,
+// aliases and type coercion still apply but problems are only logged.
+func (r *ToolRegistry) SetArgValidation(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lenientArgs = !enabled
+}
+
+// SetPreviewChars sets how many characters of tool arguments and results are
+// quoted in log lines before they are cut with a truncation marker.
+func (r *ToolRegistry) SetPreviewChars(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.previewChars = n
+}
+
+func (r *ToolRegistry) previewLimit() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.previewChars <= 0 {
+		return DefaultToolPreviewChars
+	}
+	return r.previewChars
+}
+
+// SetResultLimit caps the tool result text returned to the model by
+// ExecuteToolCalls: longer results keep their first and last bytes around an
+// elision marker (see utils.TruncateMiddle). perTool overrides maxBytes for
+// individual tools; 0 means no cap.
+func (r *ToolRegistry) SetResultLimit(maxBytes int, perTool map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resultMaxBytes = maxBytes
+	r.resultMaxBytesByTool = perTool
+}
+
+func (r *ToolRegistry) resultLimit(name string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if n, ok := r.resultMaxBytesByTool[name]; ok {
+		return n
+	}
+	return r.resultMaxBytes
+}
+
+// EnableResultCache turns on result caching for tools implementing
+// CacheableTool. Entries expire after ttl and the least recently used are
+// evicted beyond maxEntries (<=0 = DefaultResultCacheEntries). ttl <= 0
+// disables the cache.
+func (r *ToolRegistry) EnableResultCache(maxEntries int, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = newToolResultCache(maxEntries, ttl)
+}
+
 func (r *ToolRegistry) Register(tool Tool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -72,17 +145,20 @@ func (r *ToolRegistry) ExecuteResultWithContext(ctx context.Context, name string
 	logger.InfoCF("tool", "Tool execution started",
 		map[string]interface{}{
 			"tool":     name,
-			"args":     args,
+			"args":     redactExecEnvArgs(args),
 			"trace_id": traceID,
 		})
 
 	tool, ok := r.Get(name)
 	if !ok {
-		logger.ErrorCF("tool", "Tool not found",
+		err := r.unknownToolError(name)
+		logger.WarnCF("tool", "Tool not found",
 			map[string]interface{}{
-				"tool": name,
+				"tool":       name,
+				"suggestion": err.Suggestion,
+				"trace_id":   traceID,
 			})
-		return ToolResult{}, fmt.Errorf("tool '%s' not found", name)
+		return ToolResult{}, err
 	}
 
 	if err := r.checkPolicy(name); err != nil {
@@ -105,21 +181,56 @@ func (r *ToolRegistry) ExecuteResultWithContext(ctx context.Context, name string
 		return ToolResult{}, err
 	}
 
-	normalizedArgs, err := normalizeAndValidateToolArgs(tool, args)
-	if err != nil {
+	r.mu.RLock()
+	lenientArgs := r.lenientArgs
+	r.mu.RUnlock()
+	normalizedArgs, problems := normalizeToolArgs(tool, args)
+	if len(problems) > 0 {
+		err := &InvalidArgumentsError{Tool: name, Problems: problems}
 		logger.WarnCF("tool", "Tool argument validation failed",
 			map[string]interface{}{
 				"tool":     name,
 				"error":    err.Error(),
+				"enforced": !lenientArgs,
 				"trace_id": traceID,
 			})
-		return ToolResult{}, err
+		if !lenientArgs {
+			return ToolResult{}, err
+		}
 	}
 
 	execArgs := withExecutionContext(normalizedArgs, channel, chatID, traceID)
 
+	r.mu.RLock()
+	cache := r.cache
+	r.mu.RUnlock()
+	sessionKey := getExecutionSessionKey(args)
+	cacheKey, cacheable := "", false
+	var cacheGeneration uint64
+	if cache != nil {
+		if isCacheableTool(tool) {
+			cacheKey, cacheable = resultCacheKey(name, sessionKey, normalizedArgs)
+			cacheGeneration = cache.currentGeneration()
+		} else {
+			cache.invalidateSession(sessionKey)
+			defer cache.invalidateSession(sessionKey)
+		}
+	}
+	if cacheable {
+		if cached, ok := cache.get(cacheKey); ok {
+			logger.InfoCF("tool", "Tool result served from cache",
+				map[string]interface{}{
+					"tool":          name,
+					"result_length": len(cached.Content),
+					"trace_id":      traceID,
+				})
+			return cached, nil
+		}
+	}
+
 	start := time.Now()
 	var result ToolResult
+	var err error
 	if richTool, ok := tool.(ToolWithResult); ok {
 		result, err = richTool.ExecuteResult(ctx, execArgs)
 	} else {
@@ -127,6 +238,10 @@ func (r *ToolRegistry) ExecuteResultWithContext(ctx context.Context, name string
 	}
 	duration := time.Since(start)
 
+	if cacheable && err == nil && !strings.HasPrefix(result.Content, "Error") {
+		cache.put(cacheKey, sessionKey, cacheGeneration, result)
+	}
+
 	if err != nil {
 		logger.ErrorCF("tool", "Tool execution failed",
 			map[string]interface{}{
@@ -144,6 +259,13 @@ func (r *ToolRegistry) ExecuteResultWithContext(ctx context.Context, name string
 				"parts_count":   len(result.Parts),
 				"trace_id":      traceID,
 			})
+		// Results can quote file contents or secrets, so they stay at debug.
+		logger.DebugCF("tool", "Tool result preview",
+			map[string]interface{}{
+				"tool":     name,
+				"preview":  utils.Truncate(result.Content, r.previewLimit()),
+				"trace_id": traceID,
+			})
 	}
 
 	return result, err
@@ -205,24 +327,72 @@ func (r *ToolRegistry) Count() int {
 	return len(r.tools)
 }
 
-// RegisterCoreTools registers the standard set of tools shared between the
-// main agent and subagents: filesystem ops, exec, edit, web search, and web fetch.
+// CoreToolsOptions configures RegisterCoreTools.
 type CoreToolsOptions struct {
 	DisableSafeguards bool
+	// ExecMaxOutputBytes caps captured exec output (0 = DefaultExecMaxOutputBytes).
+	ExecMaxOutputBytes int
+	// ExecDenyPatterns extend the built-in exec denies; ExecAllowPatterns, when
+	// non-empty, restrict exec to matching commands.
+	ExecDenyPatterns  []string
+	ExecAllowPatterns []string
+	// ExecConfirmPatterns make matching commands wait for a confirmation
+	// token valid for ExecConfirmTTL (0 = DefaultExecConfirmTTL).
+	ExecConfirmPatterns []string
+	ExecConfirmTTL      time.Duration
+	// ExecDryRun makes exec tools describe commands instead of running them.
+	ExecDryRun bool
+	// WorkspaceIsolation gives file and exec tools a per-channel or per-chat
+	// root under the workspace, resolved from each call's execution context.
+	WorkspaceIsolation WorkspaceIsolation
 }
 
-func RegisterCoreTools(r *ToolRegistry, workspace string, webSearchCfg WebSearchToolConfig, opts CoreToolsOptions) {
+// RegisterCoreTools registers the standard set of tools shared between the
+// main agent and subagents: filesystem ops, exec, edit, web search, and web fetch.
+// It fails without registering anything if an exec pattern does not compile.
+func RegisterCoreTools(r *ToolRegistry, workspace string, webSearchCfg WebSearchToolConfig, opts CoreToolsOptions) error {
+	// Safe exec is workspace-scoped; unsafe exec requires explicit user approval.
+	execTool := NewExecTool(workspace)
+	execTool.SetRestrictToWorkspace(!opts.DisableSafeguards)
+	unsafeExecTool := NewUnsafeExecTool(workspace)
+	for _, tool := range []*ExecTool{execTool, unsafeExecTool} {
+		tool.SetDisableGuards(opts.DisableSafeguards)
+		tool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
+		tool.SetMaxOutputBytes(opts.ExecMaxOutputBytes)
+		if err := tool.AddDenyPatterns(opts.ExecDenyPatterns); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+		if len(opts.ExecAllowPatterns) > 0 {
+			if err := tool.SetAllowPatterns(opts.ExecAllowPatterns); err != nil {
+				return fmt.Errorf("exec: %w", err)
+			}
+		}
+		if err := tool.SetConfirmPatterns(opts.ExecConfirmPatterns); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+		tool.SetConfirmTTL(opts.ExecConfirmTTL)
+		tool.SetDryRun(opts.ExecDryRun)
+	}
+
 	// Safe (workspace-scoped) filesystem tools.
 	readTool := NewReadFileTool(workspace)
 	writeTool := NewWriteFileTool(workspace)
 	listTool := NewListDirTool(workspace)
 	editTool := NewEditFileTool(workspace)
+	patchTool := NewPatchFileTool(workspace)
+
+	readTool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
+	writeTool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
+	listTool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
+	editTool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
+	patchTool.SetWorkspaceIsolation(opts.WorkspaceIsolation)
 
 	if opts.DisableSafeguards {
 		readTool.SetRestrictToWorkspace(false)
 		writeTool.SetRestrictToWorkspace(false)
 		listTool.SetRestrictToWorkspace(false)
 		editTool.SetRestrictToWorkspace(false)
+		patchTool.SetRestrictToWorkspace(false)
 	}
 
 	r.Register(readTool)
@@ -233,19 +403,15 @@ func RegisterCoreTools(r *ToolRegistry, workspace string, webSearchCfg WebSearch
 	r.Register(NewUnsafeWriteFileTool())
 	r.Register(NewUnsafeListDirTool())
 	r.Register(NewSessionHistoryTool(workspace))
-	// Safe exec is workspace-scoped.
-	execTool := NewExecTool(workspace)
-	execTool.SetRestrictToWorkspace(!opts.DisableSafeguards)
-	execTool.SetDisableGuards(opts.DisableSafeguards)
 	r.Register(execTool)
-	// Unsafe exec (requires explicit user approval).
-	unsafeExecTool := NewUnsafeExecTool(workspace)
-	unsafeExecTool.SetDisableGuards(opts.DisableSafeguards)
 	r.Register(unsafeExecTool)
 	r.Register(editTool)
 	r.Register(NewUnsafeEditFileTool())
+	r.Register(patchTool)
 	r.Register(NewWebFetchTool(50000))
 	r.Register(NewWebSearchTool(webSearchCfg))
+	r.Register(NewSkillsTool(newDefaultSkillsLoader(workspace)))
+	return nil
 }
 
 // GetSummaries returns human-readable summaries of all registered tools.
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// RemindTool schedules a one-time message to the current chat. It is a thin
+// wrapper over a cron "at" job: the text is sent verbatim when due, without
+// an agent turn, so it suits plain "remind me in an hour" requests. Anything
+// that needs the agent to act when the time comes belongs in the cron tool.
+type RemindTool struct {
+	cronService *cron.CronService
+	now         func() time.Time
+}
+
+func NewRemindTool(cronService *cron.CronService) *RemindTool {
+	return &RemindTool{cronService: cronService, now: time.Now}
+}
+
+func (t *RemindTool) Name() string {
+	return "remind"
+}
+
+func (t *RemindTool) Description() string {
+	return "Send the user a reminder message in this chat at a later time. Give either 'in' (relative, e.g. '2 hours', '30m', '1 day 4 hours'), 'at' (absolute local time, e.g. '18:30', 'tomorrow 09:00', '2026-03-01 09:00'), or 'after_seconds'. The message is delivered exactly as written, so phrase it for the user (e.g. 'Reminder: call the dentist'). Use the cron tool instead for recurring jobs or when the agent must do work at that time."
+}
+
+func (t *RemindTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "Text sent to the user when the reminder is due",
+			},
+			"in": map[string]interface{}{
+				"type":        "string",
+				"description": "Delay from now, e.g. '2 hours', 'in 45 minutes', '1h30m'",
+			},
+			"at": map[string]interface{}{
+				"type":        "string",
+				"description": "Absolute local time: 'HH:MM' (next occurrence), 'tomorrow HH:MM', 'YYYY-MM-DD HH:MM' or RFC 3339",
+			},
+			"after_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Delay from now in seconds",
+			},
+		},
+		"required": []string{"message"},
+	}
+}
+
+func (t *RemindTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	message, _ := args["message"].(string)
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "Error: message is required", nil
+	}
+
+	channel, chatID := getExecutionContext(args)
+	if channel == "" || chatID == "" {
+		return "Error: no chat to deliver the reminder to; use the cron tool with channel and chat_id instead", nil
+	}
+
+	now := t.now()
+	var due time.Time
+	var err error
+	inText, _ := args["in"].(string)
+	atText, _ := args["at"].(string)
+	afterSeconds, hasAfter := args["after_seconds"].(float64)
+	switch {
+	case hasAfter:
+		if afterSeconds <= 0 {
+			return "Error: after_seconds must be positive", nil
+		}
+		due = now.Add(time.Duration(afterSeconds) * time.Second)
+	case strings.TrimSpace(inText) != "":
+		var d time.Duration
+		d, err = parseReminderDelay(inText)
+		due = now.Add(d)
+	case strings.TrimSpace(atText) != "":
+		due, err = parseReminderTime(atText, now)
+	default:
+		return "Error: one of in, at or after_seconds is required", nil
+	}
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	if !due.After(now) {
+		return fmt.Sprintf("Error: %s is in the past", due.Format("2006-01-02 15:04 MST")), nil
+	}
+
+	atMS := due.UnixMilli()
+	job, err := t.cronService.AddMessageJob(
+		utils.Truncate(message, 30),
+		cron.CronSchedule{Kind: "at", AtMS: &atMS},
+		message,
+		channel,
+		chatID,
+	)
+	if err != nil {
+		return fmt.Sprintf("Error adding reminder: %v", err), nil
+	}
+	return fmt.Sprintf("Reminder set for %s (in %s, id: %s)", due.Format("2006-01-02 15:04 MST"), formatReminderDelay(due.Sub(now)), job.ID), nil
+}
+
+var reminderDelayRe = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(weeks?|w|days?|d|hours?|hrs?|hr|h|minutes?|mins?|m|seconds?|secs?|s)`)
+
+var reminderUnits = map[string]time.Duration{
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+}
+
+// parseReminderDelay parses relative delays such as "in 2 hours",
+// "1 day and 3h" or "90m". Every word must be part of an amount.
+func parseReminderDelay(text string) (time.Duration, error) {
+	s := strings.ToLower(strings.TrimSpace(text))
+	s = strings.TrimPrefix(s, "in ")
+	s = strings.ReplaceAll(s, "an hour", "1 hour")
+	s = strings.ReplaceAll(s, "a day", "1 day")
+
+	matches := reminderDelayRe.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("cannot parse delay %q; use e.g. '2 hours' or '45m'", text)
+	}
+	var total time.Duration
+	rest := s
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		n, err := strconv.ParseFloat(s[m[2]:m[3]], 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse delay %q", text)
+		}
+		total += time.Duration(n * float64(reminderUnits[s[m[4]:m[5]]]))
+		rest = rest[:m[0]] + " " + rest[m[1]:]
+	}
+	if leftover := strings.NewReplacer(",", " ", " and ", " ").Replace(" " + rest + " "); strings.TrimSpace(leftover) != "" {
+		return 0, fmt.Errorf("cannot parse delay %q; use e.g. '2 hours' or '45m'", text)
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("delay %q must be positive", text)
+	}
+	return total, nil
+}
+
+var reminderDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+}
+
+// parseReminderTime parses an absolute time in now's location. A bare clock
+// time means its next occurrence, so "09:00" in the evening is tomorrow.
+func parseReminderTime(text string, now time.Time) (time.Time, error) {
+	s := strings.TrimSpace(text)
+	for _, layout := range reminderDateLayouts {
+		if due, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return due, nil
+		}
+	}
+
+	lower := strings.ToLower(s)
+	day := now
+	tomorrow := strings.HasPrefix(lower, "tomorrow")
+	if tomorrow {
+		day = now.AddDate(0, 0, 1)
+		lower = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(lower, "tomorrow"), " at"))
+	}
+	lower = strings.TrimSpace(strings.TrimPrefix(lower, "at "))
+	clock, err := time.Parse("15:04", lower)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse time %q; use 'HH:MM', 'tomorrow HH:MM' or 'YYYY-MM-DD HH:MM'", text)
+	}
+	due := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !tomorrow && !due.After(now) {
+		due = due.AddDate(0, 0, 1)
+	}
+	return due, nil
+}
+
+func formatReminderDelay(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Minute {
+		d = d.Round(time.Minute)
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+func TestRemindTool_RelativeReminderCreatesDirectAtJob(t *testing.T) {
+	cronTool, service, executor, msgBus := newCronToolWithService(t)
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tool := NewRemindTool(service)
+	tool.now = func() time.Time { return now }
+	registry := NewToolRegistry()
+	registry.Register(tool)
+
+	result, err := registry.ExecuteWithContext(context.Background(), "remind", map[string]interface{}{
+		"message": "Reminder: stretch",
+		"in":      "in 2 hours",
+	}, "telegram", "chat-9")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "Reminder set for 2026-03-01 12:00 UTC (in 2h") {
+		t.Fatalf("unexpected result %q", result)
+	}
+
+	jobs := service.ListJobs(true)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	job := jobs[0]
+	if job.Schedule.Kind != "at" || job.Schedule.AtMS == nil || *job.Schedule.AtMS != now.Add(2*time.Hour).UnixMilli() {
+		t.Fatalf("unexpected schedule %+v", job.Schedule)
+	}
+	if !job.DeleteAfterRun {
+		t.Fatal("one-time reminder should be deleted after it runs")
+	}
+	want := cron.CronPayload{Kind: cron.PayloadKindMessage, Message: "Reminder: stretch", Deliver: true, Channel: "telegram", To: "chat-9"}
+	if job.Payload != want {
+		t.Fatalf("payload = %+v, want %+v", job.Payload, want)
+	}
+
+	// When due, the text goes straight to the chat without an agent turn.
+	if got := cronTool.ExecuteJob(context.Background(), &job); got != "ok" {
+		t.Fatalf("ExecuteJob = %q", got)
+	}
+	if executor.callCount != 0 {
+		t.Fatalf("reminder ran an agent turn (%d calls)", executor.callCount)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "chat-9" || out.Content != "Reminder: stretch" {
+		t.Fatalf("outbound = %+v, %v", out, ok)
+	}
+}
+
+func TestRemindTool_RequiresChatContext(t *testing.T) {
+	_, service, _, _ := newCronToolWithService(t)
+
+	result, err := NewRemindTool(service).Execute(context.Background(), map[string]interface{}{
+		"message":       "ping",
+		"after_seconds": float64(60),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "no chat to deliver") {
+		t.Fatalf("unexpected result %q", result)
+	}
+	if jobs := service.ListJobs(true); len(jobs) != 0 {
+		t.Fatalf("expected no jobs, got %d", len(jobs))
+	}
+}
+
+func TestParseReminderDelay(t *testing.T) {
+	cases := map[string]time.Duration{
+		"in 2 hours":           2 * time.Hour,
+		"45m":                  45 * time.Minute,
+		"1h30m":                90 * time.Minute,
+		"1 day and 3 hours":    27 * time.Hour,
+		"in an hour":           time.Hour,
+		"1.5 hours":            90 * time.Minute,
+		"2 weeks, 1 day":       15 * 24 * time.Hour,
+		"in 90 seconds":        90 * time.Second,
+		"in 10 mins":           10 * time.Minute,
+		"3 hrs 15 minutes":     3*time.Hour + 15*time.Minute,
+		"In 5 Minutes":         5 * time.Minute,
+		"2 days 4 hours 5 min": 52*time.Hour + 5*time.Minute,
+	}
+	for in, want := range cases {
+		got, err := parseReminderDelay(in)
+		if err != nil || got != want {
+			t.Errorf("parseReminderDelay(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"tomorrow", "2 months", "soon-ish", "0 minutes", "2 hours 30"} {
+		if _, err := parseReminderDelay(in); err == nil {
+			t.Errorf("parseReminderDelay(%q): expected error", in)
+		}
+	}
+}
+
+func TestParseReminderTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"21:15":                time.Date(2026, 3, 1, 21, 15, 0, 0, time.UTC),
+		"09:00":                time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+		"at 22:00":             time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC),
+		"tomorrow 21:15":       time.Date(2026, 3, 2, 21, 15, 0, 0, time.UTC),
+		"Tomorrow at 07:30":    time.Date(2026, 3, 2, 7, 30, 0, 0, time.UTC),
+		"2026-04-01 08:00":     time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC),
+		"2026-04-01T08:00":     time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC),
+		"2026-04-01T08:00:00Z": time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC),
+	}
+	for in, want := range cases {
+		got, err := parseReminderTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseReminderTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseReminderTime("next tuesday", now); err == nil {
+		t.Error("expected error for unsupported phrase")
+	}
+}
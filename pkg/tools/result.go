@@ -10,9 +10,12 @@ import (
 // Content is always safe to return as plain text.
 // Parts may include runtime-only multimodal attachments (e.g., images) that
 // certain providers can send inline to multimodal models.
+// Structured, when non-nil, is machine-readable data appended to the tool
+// result message as JSON.
 type ToolResult struct {
-	Content string
-	Parts   []providers.MessagePart
+	Content    string
+	Parts      []providers.MessagePart
+	Structured map[string]interface{}
 }
 
 // ToolWithResult is an optional extension interface.
@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"container/list"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResultCacheEntries bounds the result cache when no size is given.
+const DefaultResultCacheEntries = 256
+
+// CacheableTool is an optional interface for idempotent, read-only tools whose
+// results may be reused for identical calls within a session.
+type CacheableTool interface {
+	Tool
+	Cacheable() bool
+}
+
+func isCacheableTool(tool Tool) bool {
+	c, ok := tool.(CacheableTool)
+	return ok && c.Cacheable()
+}
+
+// toolResultCache is an LRU of successful tool results with per-entry TTL,
+// scoped by session so one chat never sees another chat's cached output.
+type toolResultCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+	// generation counts invalidations. A result computed while one happened
+	// may predate the mutation, so put drops it.
+	generation uint64
+}
+
+type cachedToolResult struct {
+	key        string
+	sessionKey string
+	result     ToolResult
+	expires    time.Time
+}
+
+func newToolResultCache(maxEntries int, ttl time.Duration) *toolResultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultResultCacheEntries
+	}
+	return &toolResultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// resultCacheKey canonicalizes args (JSON object keys are sorted) and drops
+// execution-context and cosmetic keys that do not affect the result.
+func resultCacheKey(name, sessionKey string, args map[string]interface{}) (string, bool) {
+	canonical := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if strings.HasPrefix(k, "__context_") || k == toolCallDescriptionParameter {
+			continue
+		}
+		canonical[k] = v
+	}
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", false
+	}
+	return name + "\x00" + sessionKey + "\x00" + string(data), true
+}
+
+func (c *toolResultCache) get(key string) (ToolResult, bool) {
+	if c == nil {
+		return ToolResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return ToolResult{}, false
+	}
+	entry := el.Value.(*cachedToolResult)
+	if !c.now().Before(entry.expires) {
+		c.removeLocked(el)
+		return ToolResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+// currentGeneration is taken before running a cacheable tool and passed to
+// put with its result.
+func (c *toolResultCache) currentGeneration() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put stores result unless the cache was invalidated since generation was
+// taken, i.e. while the tool was running.
+func (c *toolResultCache) put(key, sessionKey string, generation uint64, result ToolResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cachedToolResult)
+		entry.result = result
+		entry.expires = expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cachedToolResult{
+		key:        key,
+		sessionKey: sessionKey,
+		result:     result,
+		expires:    expires,
+	})
+	for c.order.Len() > c.maxEntries {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+// invalidateSession drops every cached result for sessionKey. It runs before
+// and after a non-cacheable (potentially mutating) tool so later reads see its
+// effects, and no read running alongside it caches what it saw before.
+func (c *toolResultCache) invalidateSession(sessionKey string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*cachedToolResult).sessionKey == sessionKey {
+			c.removeLocked(el)
+		}
+		el = next
+	}
+}
+
+func (c *toolResultCache) removeLocked(el *list.Element) {
+	entry := c.order.Remove(el).(*cachedToolResult)
+	delete(c.entries, entry.key)
+}
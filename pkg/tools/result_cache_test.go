@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type countingTool struct {
+	name      string
+	cacheable bool
+	calls     int
+}
+
+func (t *countingTool) Name() string        { return t.name }
+func (t *countingTool) Description() string { return "counting tool" }
+func (t *countingTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"q": map[string]interface{}{"type": "string"},
+			"n": map[string]interface{}{"type": "integer"},
+		},
+	}
+}
+func (t *countingTool) Execute(_ context.Context, args map[string]interface{}) (string, error) {
+	t.calls++
+	return fmt.Sprintf("result %d for %v", t.calls, args["q"]), nil
+}
+
+type cacheableCountingTool struct{ countingTool }
+
+func (t *cacheableCountingTool) Cacheable() bool { return t.cacheable }
+
+func execInSession(t *testing.T, r *ToolRegistry, name, session string, args map[string]interface{}) string {
+	t.Helper()
+	out, err := r.Execute(context.Background(), name, withExecutionSessionKey(args, session))
+	if err != nil {
+		t.Fatalf("Execute(%s): %v", name, err)
+	}
+	return out
+}
+
+func TestToolRegistry_ResultCache_ReusesIdenticalCalls(t *testing.T) {
+	r := NewToolRegistry()
+	tool := &cacheableCountingTool{countingTool{name: "search", cacheable: true}}
+	r.Register(tool)
+	r.EnableResultCache(10, time.Minute)
+
+	first := execInSession(t, r, "search", "s1", map[string]interface{}{"q": "go", "n": 3})
+	second := execInSession(t, r, "search", "s1", map[string]interface{}{"n": 3, "q": "go", "description": "again"})
+	if tool.calls != 1 || first != second {
+		t.Fatalf("expected cached result, calls=%d first=%q second=%q", tool.calls, first, second)
+	}
+
+	execInSession(t, r, "search", "s1", map[string]interface{}{"q": "rust", "n": 3})
+	execInSession(t, r, "search", "s2", map[string]interface{}{"q": "go", "n": 3})
+	if tool.calls != 3 {
+		t.Fatalf("different args or session should miss the cache, calls=%d", tool.calls)
+	}
+}
+
+func TestToolRegistry_ResultCache_SkipsNonCacheableTools(t *testing.T) {
+	r := NewToolRegistry()
+	plain := &countingTool{name: "exec"}
+	optedOut := &cacheableCountingTool{countingTool{name: "fetch", cacheable: false}}
+	r.Register(plain)
+	r.Register(optedOut)
+	r.EnableResultCache(10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		execInSession(t, r, "exec", "s1", map[string]interface{}{"q": "ls"})
+		execInSession(t, r, "fetch", "s1", map[string]interface{}{"q": "x"})
+	}
+	if plain.calls != 2 || optedOut.calls != 2 {
+		t.Fatalf("non-cacheable tools must always run, exec=%d fetch=%d", plain.calls, optedOut.calls)
+	}
+}
+
+func TestToolRegistry_ResultCache_MutatingToolInvalidatesSession(t *testing.T) {
+	r := NewToolRegistry()
+	search := &cacheableCountingTool{countingTool{name: "search", cacheable: true}}
+	r.Register(search)
+	r.Register(&countingTool{name: "write"})
+	r.EnableResultCache(10, time.Minute)
+
+	args := map[string]interface{}{"q": "notes"}
+	execInSession(t, r, "search", "s1", args)
+	execInSession(t, r, "search", "s2", args)
+	execInSession(t, r, "write", "s1", map[string]interface{}{"q": "new note"})
+	execInSession(t, r, "search", "s1", args)
+	execInSession(t, r, "search", "s2", args)
+	if search.calls != 3 {
+		t.Fatalf("expected only s1 to be invalidated, calls=%d", search.calls)
+	}
+}
+
+func TestToolResultCache_DropsResultComputedAcrossInvalidation(t *testing.T) {
+	c := newToolResultCache(10, time.Minute)
+
+	// A read starts, a write in the same session runs, then the read ends.
+	gen := c.currentGeneration()
+	c.invalidateSession("s1")
+	c.put("k", "s1", gen, ToolResult{Content: "stale"})
+	if _, ok := c.get("k"); ok {
+		t.Fatal("expected a result that predates the write not to be cached")
+	}
+
+	c.put("k", "s1", c.currentGeneration(), ToolResult{Content: "fresh"})
+	if got, ok := c.get("k"); !ok || got.Content != "fresh" {
+		t.Fatalf("expected fresh result cached, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestMemorySearchTool_NotCached(t *testing.T) {
+	// Memory is shared by every session, so a per-session cache would go
+	// stale when another session stores a memory.
+	if isCacheableTool(NewMemorySearchTool(nil)) {
+		t.Fatal("memory_search must not be cacheable")
+	}
+}
+
+func TestToolResultCache_TTLAndLRU(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newToolResultCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("a", "s", 0, ToolResult{Content: "A"})
+	c.put("b", "s", 0, ToolResult{Content: "B"})
+	c.get("a") // a is now most recent
+	c.put("c", "s", 0, ToolResult{Content: "C"})
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if got, ok := c.get("a"); !ok || got.Content != "A" {
+		t.Fatalf("expected a to survive eviction, got %+v ok=%v", got, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("expected entry to expire after ttl")
+	}
+	if newToolResultCache(10, 0) != nil {
+		t.Fatal("expected zero ttl to disable the cache")
+	}
+}
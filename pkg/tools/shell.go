@@ -6,25 +6,41 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultExecMaxOutputBytes bounds how much command output exec keeps in
+// memory; anything beyond it is discarded while the command keeps running.
+const DefaultExecMaxOutputBytes = 1 << 20
+
 type ExecTool struct {
 	name                string
 	workingDir          string
 	timeout             time.Duration
+	maxOutputBytes      int
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
+	confirmPatterns     []*regexp.Regexp
+	confirmations       execConfirmations
 	restrictToWorkspace bool
 	disableGuards       bool
+	dryRun              bool // describe commands instead of running them
+	isolation           WorkspaceIsolation
+	progressInterval    time.Duration // how often a long command reports it is still running
 }
 
+// execProgressInterval is how often a running command reports progress.
+const execProgressInterval = 30 * time.Second
+
 func NewExecTool(workingDir string) *ExecTool {
 	denyPatterns := []*regexp.Regexp{
 		regexp.MustCompile(`\brm\s+-[rf]{1,2}\b`),
@@ -41,10 +57,12 @@ func NewExecTool(workingDir string) *ExecTool {
 		name:                "",
 		workingDir:          workingDir,
 		timeout:             60 * time.Second,
+		maxOutputBytes:      DefaultExecMaxOutputBytes,
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: false,
 		disableGuards:       false,
+		progressInterval:    execProgressInterval,
 	}
 }
 
@@ -86,10 +104,25 @@ func (t *ExecTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Optional working directory for the command",
 			},
+			"cwd": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional working directory relative to the workspace (takes precedence over working_dir)",
+			},
+			"env": map[string]interface{}{
+				"type":        "object",
+				"description": "Optional environment variables (string values) merged over the process environment. PATH, LD_*, DYLD_*, BASH_ENV, ENV and other shell startup variables cannot be set.",
+				"additionalProperties": map[string]interface{}{
+					"type": "string",
+				},
+			},
 			"timeout_seconds": map[string]interface{}{
 				"type":        "number",
 				"description": "Optional per-command timeout in seconds (must be > 0). Overrides the default timeout for this call.",
 			},
+			"confirm_token": map[string]interface{}{
+				"type":        "string",
+				"description": "Token from a 'Confirmation required' result. Only pass it after the user has approved that exact command.",
+			},
 		},
 		"required": []string{"command"},
 	}
@@ -101,13 +134,29 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		return "", fmt.Errorf("command is required")
 	}
 
-	cwd := t.workingDir
+	root, err := scopedWorkspace(t.workingDir, t.isolation, args)
+	if err != nil {
+		return fmt.Sprintf("Error: %s", err.Error()), nil
+	}
+
+	cwd := root
 	if wd, ok := args["working_dir"].(string); ok && strings.TrimSpace(wd) != "" {
 		cwd = wd
 	}
+	if wd, ok := args["cwd"].(string); ok && strings.TrimSpace(wd) != "" {
+		cwd = strings.TrimSpace(wd)
+		if !filepath.IsAbs(cwd) && root != "" {
+			cwd = filepath.Join(root, cwd)
+		}
+	}
+
+	env, err := parseExecEnv(args["env"])
+	if err != nil {
+		return fmt.Sprintf("Error: %s", err.Error()), nil
+	}
 
 	if t.restrictToWorkspace {
-		resolvedCwd, err := resolvePathWithOptionalRoot(cwd, t.workingDir, "workspace")
+		resolvedCwd, err := resolvePathWithOptionalRoot(cwd, root, "workspace")
 		if err != nil {
 			return fmt.Sprintf("Error: %s", err.Error()), nil
 		}
@@ -121,9 +170,31 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		}
 	}
 
+	dryRun := t.dryRun || ExecDryRunFromContext(ctx)
+	confirm := false
 	if !t.disableGuards {
-		if guardError := t.guardCommand(command, cwd); guardError != "" {
-			return fmt.Sprintf("Error: %s", guardError), nil
+		// Injected variables can smuggle a blocked command past the guard
+		// (e.g. env {"X": "rm -rf /"} with command "$X"), so also check
+		// the command as the shell would see it.
+		checked := []string{command}
+		if expanded := expandExecEnv(command, env); expanded != command {
+			checked = append(checked, expanded)
+		}
+		for _, c := range checked {
+			switch guardError := t.guardCommand(c, cwd, root); guardError {
+			case "":
+			case guardConfirmRequired:
+				confirm = true
+			default:
+				return fmt.Sprintf("Error: %s", guardError), nil
+			}
+		}
+		// A dry run never runs the command, so it needs no confirmation.
+		if confirm && !dryRun {
+			token, _ := args["confirm_token"].(string)
+			if result := t.confirmCommand(strings.Join(checked, "\x00"), cwd, strings.TrimSpace(token)); result != "" {
+				return result, nil
+			}
 		}
 	}
 
@@ -131,6 +202,9 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (st
 	if err != nil {
 		return "", err
 	}
+	if dryRun {
+		return describeExecDryRun(command, cwd, env, effectiveTimeout, confirm), nil
+	}
 
 	cmdCtx := ctx
 	cancel := func() {}
@@ -144,16 +218,18 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (st
 	if cwd != "" {
 		cmd.Dir = cwd
 	}
+	if len(env) > 0 {
+		cmd.Env = mergeExecEnv(os.Environ(), env)
+	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	capture := newExecOutputCapture(t.maxOutputBytes)
+	cmd.Stdout = capture.stream(execStdout)
+	cmd.Stderr = capture.stream(execStderr)
 
+	stopProgress := t.reportWhileRunning(cmdCtx)
 	err = runCommandWithContext(cmdCtx, cmd)
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		output += "\nSTDERR:\n" + stderr.String()
-	}
+	stopProgress()
+	output := capture.String()
 
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || cmdCtx.Err() == context.DeadlineExceeded {
@@ -172,12 +248,60 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (st
 	maxLen := 10000
 	if len(output) > maxLen {
 		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+		// Keep the capture marker visible: output past the byte cap was
+		// never collected, so the char count above understates it.
+		if note := capture.truncationNote(); note != "" {
+			output += "\n" + note
+		}
 	}
 
 	return output, nil
 }
 
-func (t *ExecTool) guardCommand(command, cwd string) string {
+// reportWhileRunning reports "still running" to the progress reporter in ctx
+// every progressInterval until the returned stop function is called.
+func (t *ExecTool) reportWhileRunning(ctx context.Context) func() {
+	report := ProgressReporterFromContext(ctx)
+	if report == nil || t.progressInterval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		startedAt := time.Now()
+		ticker := time.NewTicker(t.progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report(fmt.Sprintf("still running after %s...", time.Since(startedAt).Round(time.Second)))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// guardCommand returns why command may not run, guardConfirmRequired if it
+// may only run once confirmed, or "" if it may run.
+func (t *ExecTool) guardCommand(command, cwd, root string) string {
+	if reason := t.blockReason(command, cwd, root); reason != "" {
+		return reason
+	}
+	if t.needsConfirmation(strings.ToLower(strings.TrimSpace(command))) {
+		return guardConfirmRequired
+	}
+	return ""
+}
+
+func (t *ExecTool) blockReason(command, cwd, root string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
 
@@ -205,7 +329,7 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	}
 
 	if t.restrictToWorkspace {
-		workspaceRoot := strings.TrimSpace(t.workingDir)
+		workspaceRoot := strings.TrimSpace(root)
 		if workspaceRoot == "" {
 			workspaceRoot = cwd
 		}
@@ -309,6 +433,128 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// execEnvDenied lists variables the env argument may not set: each one
+// changes which program runs or injects code into it, which would let a
+// caller slip past the command guard.
+var execEnvDenied = map[string]bool{
+	"PATH":           true,
+	"BASH_ENV":       true,
+	"ENV":            true,
+	"IFS":            true,
+	"SHELLOPTS":      true,
+	"BASHOPTS":       true,
+	"PS4":            true,
+	"PROMPT_COMMAND": true,
+}
+
+// execEnvDeniedPrefixes covers the dynamic loader variables (LD_PRELOAD,
+// LD_LIBRARY_PATH, DYLD_INSERT_LIBRARIES, ...).
+var execEnvDeniedPrefixes = []string{"LD_", "DYLD_"}
+
+// isExecEnvDenied reports whether name may not be set through the env
+// argument. The check ignores case because Windows does.
+func isExecEnvDenied(name string) bool {
+	upper := strings.ToUpper(name)
+	if execEnvDenied[upper] {
+		return true
+	}
+	for _, prefix := range execEnvDeniedPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExecEnv validates the optional env argument. Values must be strings
+// (numbers and booleans are stringified), names must be non-empty and
+// free of '=' and NUL, and names from execEnvDenied are rejected.
+func parseExecEnv(raw interface{}) (map[string]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of string values")
+	}
+	env := make(map[string]string, len(m))
+	for k, v := range m {
+		if strings.TrimSpace(k) == "" || strings.ContainsAny(k, "=\x00") {
+			return nil, fmt.Errorf("invalid env variable name %q", k)
+		}
+		if isExecEnvDenied(k) {
+			return nil, fmt.Errorf("env variable %q may not be overridden", k)
+		}
+		switch val := v.(type) {
+		case string:
+			env[k] = val
+		case float64, bool, json.Number:
+			env[k] = fmt.Sprint(val)
+		default:
+			return nil, fmt.Errorf("env variable %q must be a string", k)
+		}
+	}
+	return env, nil
+}
+
+// mergeExecEnv overlays env on base. Overridden entries are dropped from
+// base so each name appears once; added names are sorted for determinism.
+func mergeExecEnv(base []string, env map[string]string) []string {
+	merged := make([]string, 0, len(base)+len(env))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, overridden := env[name]; overridden {
+			continue
+		}
+		merged = append(merged, kv)
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, name+"="+env[name])
+	}
+	return merged
+}
+
+// expandExecEnv substitutes $NAME and ${NAME} references to injected
+// variables, leaving every other reference untouched.
+func expandExecEnv(command string, env map[string]string) string {
+	if len(env) == 0 {
+		return command
+	}
+	return os.Expand(command, func(name string) string {
+		if v, ok := env[name]; ok {
+			return v
+		}
+		if name == "$" {
+			return "$$"
+		}
+		return "${" + name + "}"
+	})
+}
+
+// redactExecEnvArgs returns args with env values masked, for logging.
+// Other tools' args are returned unchanged.
+func redactExecEnvArgs(args map[string]interface{}) map[string]interface{} {
+	env, ok := args["env"].(map[string]interface{})
+	if !ok || len(env) == 0 {
+		return args
+	}
+	masked := make(map[string]interface{}, len(env))
+	for k := range env {
+		masked[k] = "[REDACTED]"
+	}
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+	out["env"] = masked
+	return out
+}
+
 func looksLikeChatSlashCommand(command string) bool {
 	parts := strings.Fields(strings.TrimSpace(command))
 	if len(parts) == 0 {
@@ -328,6 +574,24 @@ func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
 
+// TimeoutHint lets the command run for its own configured timeout, plus a
+// short grace so the tool reports the timeout instead of being cancelled.
+func (t *ExecTool) TimeoutHint() time.Duration {
+	if t.timeout <= 0 {
+		return 0
+	}
+	return t.timeout + 5*time.Second
+}
+
+// SetMaxOutputBytes caps captured stdout+stderr. Zero or negative restores
+// the default cap.
+func (t *ExecTool) SetMaxOutputBytes(n int) {
+	if n <= 0 {
+		n = DefaultExecMaxOutputBytes
+	}
+	t.maxOutputBytes = n
+}
+
 func (t *ExecTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
@@ -336,6 +600,28 @@ func (t *ExecTool) SetDisableGuards(disable bool) {
 	t.disableGuards = disable
 }
 
+// SetWorkspaceIsolation runs each command in the caller's channel or chat
+// directory under the workspace; the workspace guard uses that directory as
+// its root.
+func (t *ExecTool) SetWorkspaceIsolation(mode WorkspaceIsolation) {
+	t.isolation = mode
+}
+
+// AddDenyPatterns compiles patterns and appends them to the built-in
+// dangerous-command denies. On error no pattern is added.
+func (t *ExecTool) AddDenyPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid deny pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	t.denyPatterns = append(t.denyPatterns, compiled...)
+	return nil
+}
+
 func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	t.allowPatterns = make([]*regexp.Regexp, 0, len(patterns))
 	for _, p := range patterns {
@@ -435,3 +721,97 @@ func runCommandWithContext(ctx context.Context, cmd *exec.Cmd) error {
 		}
 	}
 }
+
+const (
+	execStdout = "stdout"
+	execStderr = "stderr"
+)
+
+// execOutputCapture collects stdout and stderr as the command writes them, so
+// the two streams stay interleaved in arrival order. Stream switches are
+// marked with STDERR:/STDOUT: headers. Once limit bytes are held, further
+// output is drained and discarded so the command never blocks on a full pipe.
+type execOutputCapture struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	last      string
+	truncated bool
+}
+
+func newExecOutputCapture(limit int) *execOutputCapture {
+	return &execOutputCapture{limit: limit}
+}
+
+func (c *execOutputCapture) stream(name string) io.Writer {
+	return execStreamWriter{capture: c, name: name}
+}
+
+type execStreamWriter struct {
+	capture *execOutputCapture
+	name    string
+}
+
+func (w execStreamWriter) Write(p []byte) (int, error) {
+	w.capture.write(w.name, p)
+	return len(p), nil
+}
+
+func (c *execOutputCapture) write(stream string, p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.truncated || len(p) == 0 {
+		return
+	}
+
+	if stream != c.last {
+		header := ""
+		if stream == execStderr {
+			header = "STDERR:\n"
+		} else if c.last == execStderr {
+			header = "STDOUT:\n"
+		}
+		if header != "" {
+			if c.buf.Len() > 0 && !bytes.HasSuffix(c.buf.Bytes(), []byte("\n")) {
+				c.buf.WriteByte('\n')
+			}
+			c.buf.WriteString(header)
+		}
+		c.last = stream
+	}
+
+	if c.limit > 0 && c.buf.Len()+len(p) > c.limit {
+		if room := c.limit - c.buf.Len(); room > 0 {
+			c.buf.Write(p[:room])
+		}
+		c.truncated = true
+		return
+	}
+	c.buf.Write(p)
+}
+
+func (c *execOutputCapture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := c.buf.String()
+	if c.truncated {
+		out += "\n" + c.truncationNoteLocked()
+	}
+	return out
+}
+
+// truncationNote is the marker appended when the byte cap was hit, or "".
+func (c *execOutputCapture) truncationNote() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.truncated {
+		return ""
+	}
+	return c.truncationNoteLocked()
+}
+
+func (c *execOutputCapture) truncationNoteLocked() string {
+	return fmt.Sprintf("[output truncated at %d bytes]", c.limit)
+}
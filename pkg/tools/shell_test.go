@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -38,7 +39,7 @@ func TestGuardCommand_DenyPatterns(t *testing.T) {
 
 	for _, tt := range blocked {
 		t.Run("blocked/"+tt.name, func(t *testing.T) {
-			result := tool.guardCommand(tt.command, t.TempDir())
+			result := tool.guardCommand(tt.command, t.TempDir(), tool.workingDir)
 			if result == "" {
 				t.Errorf("expected command %q to be blocked, but it was allowed", tt.command)
 			}
@@ -76,7 +77,7 @@ func TestGuardCommand_SafeCommands(t *testing.T) {
 
 	for _, tt := range allowed {
 		t.Run("allowed/"+tt.name, func(t *testing.T) {
-			result := tool.guardCommand(tt.command, t.TempDir())
+			result := tool.guardCommand(tt.command, t.TempDir(), tool.workingDir)
 			if result != "" {
 				t.Errorf("expected command %q to be allowed, but got: %s", tt.command, result)
 			}
@@ -95,7 +96,7 @@ func TestGuardCommand_BlocksChatSlashCommands(t *testing.T) {
 
 	for _, cmd := range blocked {
 		t.Run(cmd, func(t *testing.T) {
-			result := tool.guardCommand(cmd, t.TempDir())
+			result := tool.guardCommand(cmd, t.TempDir(), tool.workingDir)
 			if result == "" {
 				t.Fatalf("expected command %q to be blocked", cmd)
 			}
@@ -114,21 +115,21 @@ func TestGuardCommand_AllowPatterns(t *testing.T) {
 	}
 
 	t.Run("allowed by allowlist", func(t *testing.T) {
-		result := tool.guardCommand("git status", t.TempDir())
+		result := tool.guardCommand("git status", t.TempDir(), tool.workingDir)
 		if result != "" {
 			t.Errorf("expected 'git status' to be allowed, got: %s", result)
 		}
 	})
 
 	t.Run("allowed by allowlist go", func(t *testing.T) {
-		result := tool.guardCommand("go test ./...", t.TempDir())
+		result := tool.guardCommand("go test ./...", t.TempDir(), tool.workingDir)
 		if result != "" {
 			t.Errorf("expected 'go test' to be allowed, got: %s", result)
 		}
 	})
 
 	t.Run("blocked by allowlist", func(t *testing.T) {
-		result := tool.guardCommand("ls -la", t.TempDir())
+		result := tool.guardCommand("ls -la", t.TempDir(), tool.workingDir)
 		if result == "" {
 			t.Error("expected 'ls -la' to be blocked by allowlist")
 		}
@@ -140,7 +141,7 @@ func TestGuardCommand_AllowPatterns(t *testing.T) {
 	t.Run("deny takes precedence over allow", func(t *testing.T) {
 		// Even if "go" is allowed, a dangerous pattern should still be blocked
 		// (deny is checked first)
-		result := tool.guardCommand("rm -rf /", t.TempDir())
+		result := tool.guardCommand("rm -rf /", t.TempDir(), tool.workingDir)
 		if result == "" {
 			t.Error("expected dangerous command to be blocked even with allowlist")
 		}
@@ -153,21 +154,21 @@ func TestGuardCommand_RestrictToWorkspace(t *testing.T) {
 	tool.SetRestrictToWorkspace(true)
 
 	t.Run("path traversal with ..", func(t *testing.T) {
-		result := tool.guardCommand("cat ../../../etc/passwd", dir)
+		result := tool.guardCommand("cat ../../../etc/passwd", dir, dir)
 		if result == "" {
 			t.Error("expected path traversal to be blocked")
 		}
 	})
 
 	t.Run("path traversal with backslash", func(t *testing.T) {
-		result := tool.guardCommand(`cat ..\..\windows\system32\config`, dir)
+		result := tool.guardCommand(`cat ..\..\windows\system32\config`, dir, dir)
 		if result == "" {
 			t.Error("expected backslash path traversal to be blocked")
 		}
 	})
 
 	t.Run("command within workspace", func(t *testing.T) {
-		result := tool.guardCommand("cat file.txt", dir)
+		result := tool.guardCommand("cat file.txt", dir, dir)
 		if result != "" {
 			t.Errorf("expected workspace-local command to be allowed, got: %s", result)
 		}
@@ -175,7 +176,7 @@ func TestGuardCommand_RestrictToWorkspace(t *testing.T) {
 
 	t.Run("relative path args with slashes allowed", func(t *testing.T) {
 		cmd := "bash skills/comfyui/bin/generate-image.sh --workflow workflows/alice.json test generated/test.png"
-		result := tool.guardCommand(cmd, dir)
+		result := tool.guardCommand(cmd, dir, dir)
 		if result != "" {
 			t.Fatalf("expected relative path args to be allowed, got: %s", result)
 		}
@@ -184,7 +185,7 @@ func TestGuardCommand_RestrictToWorkspace(t *testing.T) {
 	t.Run("absolute path outside workspace blocked", func(t *testing.T) {
 		outside := t.TempDir()
 		outsideFile := filepath.Join(outside, "file.txt")
-		result := tool.guardCommand(fmt.Sprintf("cat %q", outsideFile), dir)
+		result := tool.guardCommand(fmt.Sprintf("cat %q", outsideFile), dir, dir)
 		if result == "" {
 			t.Fatalf("expected absolute path outside workspace to be blocked")
 		}
@@ -195,7 +196,7 @@ func TestGuardCommand_RestrictToWorkspace(t *testing.T) {
 
 	t.Run("working_dir outside workspace", func(t *testing.T) {
 		outside := t.TempDir()
-		result := tool.guardCommand("ls -la", outside)
+		result := tool.guardCommand("ls -la", outside, dir)
 		if result == "" {
 			t.Fatalf("expected command to be blocked for cwd outside workspace")
 		}
@@ -338,6 +339,190 @@ func TestExecTool_Execute_RestrictToWorkspaceWorkingDir(t *testing.T) {
 	})
 }
 
+func TestExecTool_Execute_InjectsEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command": "echo \"value=$PICOCLAW_TEST_VAR\"",
+		"env":     map[string]interface{}{"PICOCLAW_TEST_VAR": "injected"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "value=injected") {
+		t.Fatalf("expected injected env var in output, got %q", result)
+	}
+
+	t.Run("overrides process environment", func(t *testing.T) {
+		t.Setenv("PICOCLAW_TEST_VAR", "original")
+		result, _ := tool.Execute(context.Background(), map[string]interface{}{
+			"command": "echo \"value=$PICOCLAW_TEST_VAR\"",
+			"env":     map[string]interface{}{"PICOCLAW_TEST_VAR": "override"},
+		})
+		if !strings.Contains(result, "value=override") {
+			t.Fatalf("expected override, got %q", result)
+		}
+	})
+
+	t.Run("invalid env rejected", func(t *testing.T) {
+		result, _ := tool.Execute(context.Background(), map[string]interface{}{
+			"command": "echo hi",
+			"env":     map[string]interface{}{"BAD=NAME": "x"},
+		})
+		if !strings.Contains(result, "Error:") {
+			t.Fatalf("expected Error: result, got %q", result)
+		}
+	})
+
+	t.Run("loader and shell startup variables rejected", func(t *testing.T) {
+		for _, name := range []string{"PATH", "path", "LD_PRELOAD", "LD_LIBRARY_PATH", "DYLD_INSERT_LIBRARIES", "BASH_ENV", "ENV", "IFS"} {
+			result, _ := tool.Execute(context.Background(), map[string]interface{}{
+				"command": "echo hi",
+				"env":     map[string]interface{}{name: "/tmp/evil"},
+			})
+			if !strings.Contains(result, "Error:") || !strings.Contains(result, "may not be overridden") {
+				t.Fatalf("expected %s to be rejected, got %q", name, result)
+			}
+		}
+	})
+
+	t.Run("guard sees expanded command", func(t *testing.T) {
+		result, _ := tool.Execute(context.Background(), map[string]interface{}{
+			"command": "$CMD",
+			"env":     map[string]interface{}{"CMD": "rm -rf /"},
+		})
+		if !strings.Contains(result, "Command blocked by safety guard") {
+			t.Fatalf("expected guard to block expanded command, got %q", result)
+		}
+	})
+}
+
+func TestExecTool_Execute_CwdRelativeToWorkspace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "skills", "demo"), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "skills", "demo", "marker.txt"), []byte("found"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	tool := NewExecTool(dir)
+	tool.SetRestrictToWorkspace(true)
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command": "cat marker.txt",
+		"cwd":     "skills/demo",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "found") {
+		t.Fatalf("expected command to run in subdirectory, got %q", result)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{
+		"command": "pwd",
+		"cwd":     "../..",
+	})
+	if !strings.Contains(result, "outside workspace") {
+		t.Fatalf("expected cwd escape to be blocked, got %q", result)
+	}
+}
+
+func TestExecTool_Execute_CapsCapturedOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+	tool.SetMaxOutputBytes(1000)
+
+	start := time.Now()
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command": "head -c 200000 /dev/zero | tr '\\0' x; echo; echo done >&2",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(result, "[output truncated at 1000 bytes]") {
+		t.Fatalf("expected truncation marker, got %q", result)
+	}
+	if n := strings.Count(result, "x"); n > 1000 {
+		t.Fatalf("captured %d bytes of output, cap is 1000", n)
+	}
+	if strings.Contains(result, "done") {
+		t.Fatalf("output after the cap should be discarded, got %q", result[len(result)-80:])
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("command over the cap took too long to finish")
+	}
+}
+
+func TestExecTool_Execute_RunawayOutputStillTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+	tool.SetMaxOutputBytes(4096)
+
+	done := make(chan string, 1)
+	go func() {
+		result, _ := tool.Execute(context.Background(), map[string]interface{}{
+			"command":         "yes",
+			"timeout_seconds": 0.5,
+		})
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if !strings.Contains(result, "timed out") {
+			t.Fatalf("expected timeout, got %q", result)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("exec with runaway output did not return")
+	}
+}
+
+func TestExecTool_Execute_InterleavesStdoutAndStderr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command": "echo out1; sleep 0.1; echo err1 >&2; sleep 0.1; echo out2",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "out1\nSTDERR:\nerr1\nSTDOUT:\nout2\n"
+	if result != want {
+		t.Fatalf("result = %q, want %q", result, want)
+	}
+}
+
+func TestRedactExecEnvArgs(t *testing.T) {
+	args := map[string]interface{}{
+		"command": "deploy",
+		"env":     map[string]interface{}{"API_KEY": "sk-secret"},
+	}
+	redacted := redactExecEnvArgs(args)
+	if redacted["env"].(map[string]interface{})["API_KEY"] != "[REDACTED]" {
+		t.Fatalf("env value not redacted: %+v", redacted)
+	}
+	if args["env"].(map[string]interface{})["API_KEY"] != "sk-secret" {
+		t.Fatal("original args must not be modified")
+	}
+	if redacted["command"] != "deploy" {
+		t.Fatalf("other args changed: %+v", redacted)
+	}
+}
+
 func TestExecTool_DisableGuards_AllowsPreviouslyBlockedCommands(t *testing.T) {
 	tool := NewExecTool(t.TempDir())
 	tool.SetDisableGuards(true)
@@ -363,3 +548,179 @@ func TestSetAllowPatterns_InvalidRegex(t *testing.T) {
 		t.Error("expected error for invalid regex pattern")
 	}
 }
+
+func TestRegisterCoreTools_ConfigDenyPatternBlocksCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	registry := NewToolRegistry()
+	err := RegisterCoreTools(registry, t.TempDir(), WebSearchToolConfig{}, CoreToolsOptions{
+		ExecDenyPatterns: []string{`\bcurl\b`},
+	})
+	if err != nil {
+		t.Fatalf("RegisterCoreTools: %v", err)
+	}
+
+	result, _ := registry.Execute(context.Background(), "exec", map[string]interface{}{"command": "curl --version"})
+	if !strings.Contains(result, "Command blocked by safety guard") {
+		t.Fatalf("expected config deny pattern to block curl, got %q", result)
+	}
+
+	// Built-in denies stay active alongside configured ones.
+	result, _ = registry.Execute(context.Background(), "exec", map[string]interface{}{"command": "rm -rf build"})
+	if !strings.Contains(result, "Command blocked by safety guard") {
+		t.Fatalf("expected built-in deny to remain active, got %q", result)
+	}
+
+	result, _ = registry.Execute(context.Background(), "exec", map[string]interface{}{"command": "echo allowed"})
+	if !strings.Contains(result, "allowed") || strings.Contains(result, "blocked") {
+		t.Fatalf("expected unrelated command to run, got %q", result)
+	}
+}
+
+func TestRegisterCoreTools_ConfigAllowPatterns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	registry := NewToolRegistry()
+	if err := RegisterCoreTools(registry, t.TempDir(), WebSearchToolConfig{}, CoreToolsOptions{
+		ExecAllowPatterns: []string{`^echo\s`},
+	}); err != nil {
+		t.Fatalf("RegisterCoreTools: %v", err)
+	}
+
+	result, _ := registry.Execute(context.Background(), "exec", map[string]interface{}{"command": "ls"})
+	if !strings.Contains(result, "not in allowlist") {
+		t.Fatalf("expected allowlist to block ls, got %q", result)
+	}
+}
+
+func TestRegisterCoreTools_InvalidPatternErrors(t *testing.T) {
+	for _, opts := range []CoreToolsOptions{
+		{ExecDenyPatterns: []string{`[unclosed`}},
+		{ExecAllowPatterns: []string{`(bad`}},
+	} {
+		registry := NewToolRegistry()
+		err := RegisterCoreTools(registry, t.TempDir(), WebSearchToolConfig{}, opts)
+		if err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Fatalf("expected invalid pattern error for %+v, got %v", opts, err)
+		}
+		if registry.Count() != 0 {
+			t.Fatalf("expected no tools registered after a setup error, got %d", registry.Count())
+		}
+	}
+}
+
+func TestExecTool_ReportsProgressWhileRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	tool := NewExecTool(t.TempDir())
+	tool.progressInterval = 20 * time.Millisecond
+
+	var mu sync.Mutex
+	var reports []string
+	ctx := WithProgressReporter(context.Background(), func(message string) {
+		mu.Lock()
+		reports = append(reports, message)
+		mu.Unlock()
+	})
+	if _, err := tool.Execute(ctx, map[string]interface{}{"command": "sleep 0.15"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	got := append([]string(nil), reports...)
+	mu.Unlock()
+	if len(got) == 0 || !strings.HasPrefix(got[0], "still running after ") {
+		t.Fatalf("expected still-running reports, got %v", got)
+	}
+
+	// Nothing is reported after the command returns.
+	time.Sleep(60 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != len(got) {
+		t.Fatalf("expected no reports after completion, got %v", reports[len(got):])
+	}
+}
+
+func TestExecTool_DryRunDescribesWithoutRunning(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewExecTool(workspace)
+	tool.SetRestrictToWorkspace(true)
+	tool.SetDryRun(true)
+
+	marker := filepath.Join(workspace, "sub", "ran.txt")
+	os.MkdirAll(filepath.Dir(marker), 0755)
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"command":         "touch ran.txt",
+		"cwd":             "sub",
+		"env":             map[string]interface{}{"MODE": "test"},
+		"timeout_seconds": float64(5),
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	for _, want := range []string{
+		"Would run: touch ran.txt",
+		"Working directory: " + filepath.Join(workspace, "sub"),
+		"MODE=test",
+		"Timeout: 5s",
+		execDryRunNote,
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("result missing %q:\n%s", want, result)
+		}
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("dry-run executed the command (stat err: %v)", err)
+	}
+
+	// The guard still applies.
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"command": "rm -rf /"})
+	if !strings.HasPrefix(result, "Error:") || strings.Contains(result, execDryRunNote) {
+		t.Fatalf("expected a blocked command to be refused in dry-run, got %q", result)
+	}
+}
+
+func TestExecTool_DryRunFromContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	workspace := t.TempDir()
+	tool := NewExecTool(workspace)
+	marker := filepath.Join(workspace, "ran.txt")
+	args := map[string]interface{}{"command": "touch " + marker}
+
+	result, err := tool.Execute(WithExecDryRun(context.Background()), args)
+	if err != nil || !strings.HasSuffix(result, execDryRunNote) {
+		t.Fatalf("dry-run result = %q, %v", result, err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("dry-run context executed the command")
+	}
+
+	// Without the flag the same call runs.
+	if _, err := tool.Execute(context.Background(), args); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected the command to run normally: %v", err)
+	}
+}
+
+func TestExecTool_DryRunSkipsConfirmation(t *testing.T) {
+	tool := NewExecTool(t.TempDir())
+	if err := tool.SetConfirmPatterns([]string{`\bgit\s+push\b`}); err != nil {
+		t.Fatalf("SetConfirmPatterns: %v", err)
+	}
+
+	result, err := tool.Execute(WithExecDryRun(context.Background()), map[string]interface{}{"command": "git push origin main"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(result, "asked to confirm") || strings.Contains(result, "confirm_token") {
+		t.Fatalf("expected a dry-run description noting confirmation, got %q", result)
+	}
+}
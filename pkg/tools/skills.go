@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// SkillsTool lets the agent discover installed skills and read their
+// instructions without guessing SKILL.md paths.
+type SkillsTool struct {
+	loader *skills.SkillsLoader
+}
+
+func NewSkillsTool(loader *skills.SkillsLoader) *SkillsTool {
+	return &SkillsTool{loader: loader}
+}
+
+// newDefaultSkillsLoader returns a loader with the same lookup order as the
+// main agent: workspace > global (~/.picoclaw/skills) > builtin (./skills).
+func newDefaultSkillsLoader(workspace string) *skills.SkillsLoader {
+	wd, _ := os.Getwd()
+	globalSkillsDir := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		globalSkillsDir = filepath.Join(home, ".picoclaw", "skills")
+	}
+	return skills.NewSkillsLoader(workspace, globalSkillsDir, filepath.Join(wd, "skills"))
+}
+
+func (t *SkillsTool) Name() string {
+	return "skills"
+}
+
+func (t *SkillsTool) Description() string {
+	return "List available skills or read a skill's SKILL.md instructions. Use action=list to see skill names and descriptions, then action=read with a name to load its instructions."
+}
+
+func (t *SkillsTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "read"},
+				"description": "list: show installed skills; read: return a skill's instructions",
+			},
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "Skill name (required for read)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *SkillsTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	return result.Content, err
+}
+
+// ExecuteResult returns the skill list as structured entries in addition to
+// the text summary.
+func (t *SkillsTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (ToolResult, error) {
+	if t.loader == nil {
+		return ToolResult{Content: "Error: skills are not available"}, nil
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		return t.list(), nil
+	case "read":
+		name, _ := args["name"].(string)
+		return ToolResult{Content: t.read(strings.TrimSpace(name))}, nil
+	default:
+		return ToolResult{Content: fmt.Sprintf("Error: unknown action %q (expected list or read)", action)}, nil
+	}
+}
+
+func (t *SkillsTool) list() ToolResult {
+	all := t.loader.ListSkills()
+	if len(all) == 0 {
+		return ToolResult{Content: "No skills installed."}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d skills:\n", len(all)))
+	entries := make([]interface{}, 0, len(all))
+	for _, s := range all {
+		desc := firstLine(s.Description)
+		if desc == "" {
+			desc = "(no description)"
+		}
+		sb.WriteString(fmt.Sprintf("- %s: %s (%s)\n", s.Name, desc, s.Source))
+		entries = append(entries, map[string]interface{}{
+			"name":        s.Name,
+			"description": firstLine(s.Description),
+			"source":      s.Source,
+		})
+	}
+	return ToolResult{
+		Content:    sb.String(),
+		Structured: map[string]interface{}{"skills": entries},
+	}
+}
+
+func (t *SkillsTool) read(name string) string {
+	if name == "" {
+		return "Error: name is required for action=read"
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Sprintf("Error: invalid skill name %q", name)
+	}
+
+	content, ok := t.loader.LoadSkill(name)
+	if !ok {
+		return fmt.Sprintf("Error: skill %q not found (use action=list to see available skills)", name)
+	}
+
+	// LoadSkill resolves the same precedence as ListSkills; report where the
+	// winning copy lives so the agent can reference bundled files.
+	header := fmt.Sprintf("Skill: %s\n", name)
+	for _, s := range t.loader.ListSkills() {
+		if s.Name == name {
+			header = fmt.Sprintf("Skill: %s (%s)\nPath: %s\n", name, s.Source, s.Path)
+			break
+		}
+	}
+	return header + "\n" + strings.TrimSpace(content)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+func writeTestSkill(t *testing.T, root, name, content string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir skill: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0644); err != nil {
+		t.Fatalf("write skill: %v", err)
+	}
+}
+
+func newTestSkillsTool(t *testing.T) (*SkillsTool, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	global := t.TempDir()
+	writeTestSkill(t, filepath.Join(workspace, "skills"), "weather", "---\nname: weather\ndescription: Look up forecasts\n---\n\n# Weather\n\nUse wttr.in.\n")
+	writeTestSkill(t, filepath.Join(workspace, "skills"), "github", "---\nname: github\ndescription: Work with the gh CLI\n---\n\n# GitHub\n\nRun gh.\n")
+	// Shadowed by the workspace copy.
+	writeTestSkill(t, global, "weather", "---\nname: weather\ndescription: Global weather\n---\n\nGlobal copy.\n")
+	return NewSkillsTool(skills.NewSkillsLoader(workspace, global, "")), workspace
+}
+
+func TestSkillsTool_List(t *testing.T) {
+	tool, _ := newTestSkillsTool(t)
+
+	result, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"action": "list"})
+	if err != nil {
+		t.Fatalf("ExecuteResult: %v", err)
+	}
+	for _, want := range []string{"- weather: Look up forecasts (workspace)", "- github: Work with the gh CLI (workspace)"} {
+		if !strings.Contains(result.Content, want) {
+			t.Fatalf("list missing %q:\n%s", want, result.Content)
+		}
+	}
+	if strings.Contains(result.Content, "Global weather") {
+		t.Fatalf("shadowed global skill should not be listed:\n%s", result.Content)
+	}
+
+	if entries, _ := result.Structured["skills"].([]interface{}); len(entries) != 2 {
+		t.Fatalf("expected 2 structured skills, got %d", len(entries))
+	}
+}
+
+func TestSkillsTool_Read(t *testing.T) {
+	tool, workspace := newTestSkillsTool(t)
+
+	out, err := tool.Execute(context.Background(), map[string]interface{}{"action": "read", "name": "weather"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, "Use wttr.in.") || strings.Contains(out, "Global copy.") {
+		t.Fatalf("expected workspace skill content, got:\n%s", out)
+	}
+	if !strings.Contains(out, "(workspace)") || !strings.Contains(out, filepath.Join(workspace, "skills", "weather", "SKILL.md")) {
+		t.Fatalf("expected source and path header, got:\n%s", out)
+	}
+}
+
+func TestSkillsTool_Errors(t *testing.T) {
+	tool, _ := newTestSkillsTool(t)
+
+	cases := []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "read"}, "name is required"},
+		{map[string]interface{}{"action": "read", "name": "missing"}, "not found"},
+		{map[string]interface{}{"action": "read", "name": "../weather"}, "invalid skill name"},
+		{map[string]interface{}{"action": "delete"}, "unknown action"},
+	}
+	for _, tc := range cases {
+		out, err := tool.Execute(context.Background(), tc.args)
+		if err != nil {
+			t.Fatalf("Execute(%v) error: %v", tc.args, err)
+		}
+		if !strings.HasPrefix(out, "Error:") || !strings.Contains(out, tc.want) {
+			t.Fatalf("Execute(%v) = %q, want error containing %q", tc.args, out, tc.want)
+		}
+	}
+}
+
+func TestSkillsTool_ListEmpty(t *testing.T) {
+	tool := NewSkillsTool(skills.NewSkillsLoader(t.TempDir(), "", ""))
+	out, _ := tool.Execute(context.Background(), map[string]interface{}{"action": "list"})
+	if out != "No skills installed." {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
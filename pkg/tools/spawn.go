@@ -61,6 +61,10 @@ func parseIntArg(args map[string]interface{}, key string) (int, bool) {
 
 type SpawnTool struct {
 	manager *SubagentManager
+	depth   int // nesting depth of the caller; 0 for the main agent
+	// parent holds the calling subagent's options; its tool filter caps the
+	// tools any child may be given.
+	parent *SpawnOptions
 }
 
 func NewSpawnTool(manager *SubagentManager) *SpawnTool {
@@ -69,6 +73,19 @@ func NewSpawnTool(manager *SubagentManager) *SpawnTool {
 	}
 }
 
+// newNestedSpawnTool returns a spawn tool for a subagent spawned with parent.
+func newNestedSpawnTool(manager *SubagentManager, parent SpawnOptions) *SpawnTool {
+	depth := parent.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	return &SpawnTool{
+		manager: manager,
+		depth:   depth,
+		parent:  &parent,
+	}
+}
+
 func (t *SpawnTool) Name() string {
 	return "spawn"
 }
@@ -118,6 +135,16 @@ func (t *SpawnTool) Parameters() map[string]interface{} {
 				"type":        "integer",
 				"description": "Optional tool execution timeout in seconds for the subagent (default: 60)",
 			},
+			"tools": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional allowlist of tool names for the subagent (e.g. ['read_file','web_search']). Default: all tools",
+			},
+			"deny_tools": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional tool names the subagent must not get (e.g. ['exec'])",
+			},
 		},
 	}
 }
@@ -166,7 +193,7 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (s
 			}
 		}
 
-		opts := SpawnOptions{}
+		opts := SpawnOptions{Depth: t.depth + 1}
 		if model, ok := args["model"].(string); ok && strings.TrimSpace(model) != "" {
 			opts.Model = strings.TrimSpace(model)
 		}
@@ -203,6 +230,11 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (s
 			}
 			opts.ToolTimeoutSeconds = toolTimeout
 		}
+		opts.Tools = parseStringArray(args["tools"])
+		opts.DenyTools = parseStringArray(args["deny_tools"])
+		if t.parent != nil {
+			opts = opts.narrowedBy(*t.parent)
+		}
 
 		mgr := t.manager
 		if mgr == nil {
@@ -211,6 +243,9 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (s
 
 		taskID, err := mgr.Spawn(ctx, task, label, originChannel, originChatID, originSessionKey, parentTraceID, opts)
 		if err != nil {
+			if errors.Is(err, ErrSubagentDepthLimit) {
+				return fmt.Sprintf("Error: cannot spawn subagent: %v. Complete this task yourself instead of delegating.", err), nil
+			}
 			return "", fmt.Errorf("failed to spawn subagent: %w", err)
 		}
 		if label != "" {
@@ -291,7 +326,7 @@ func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (s
 			if includeCompleted {
 				return "No subagent tasks.", nil
 			}
-			return "No running subagent tasks.", nil
+			return "No running or queued subagent tasks.", nil
 		}
 
 		return strings.Join(lines, "\n\n"), nil
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,24 +12,69 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/events"
 	"github.com/sipeed/picoclaw/pkg/llmloop"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
-	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 var (
 	ErrSubagentTaskNotFound = errors.New("subagent task not found")
 	ErrSubagentNotRunning   = errors.New("subagent task is not running")
+	ErrSubagentDepthLimit   = errors.New("subagent nesting depth limit reached")
 )
 
+// DefaultSubagentMaxDepth only allows the main agent to spawn subagents.
+const DefaultSubagentMaxDepth = 1
+
 type SpawnOptions struct {
 	Model              string
 	MaxIterations      int
 	LLMTimeoutSeconds  int
 	ToolTimeoutSeconds int
+	// Depth is the nesting level of the new task: 1 for tasks spawned by the
+	// main agent, 2 for tasks spawned by those, and so on. 0 means 1.
+	Depth int
+	// Tools, when non-empty, limits the subagent to the named tools; DenyTools
+	// removes tools from whatever remains. subagent_report is always kept so
+	// the task can still report back. Empty means the full set.
+	Tools     []string
+	DenyTools []string
+}
+
+// allowsTool reports whether a subagent spawned with these options gets the
+// named tool.
+func (o SpawnOptions) allowsTool(name string) bool {
+	if name == "subagent_report" {
+		return true
+	}
+	return NewToolExecutionPolicy(true, o.Tools, o.DenyTools).check(name) == nil
+}
+
+// narrowedBy returns o restricted to what parent allows, so a nested subagent
+// never gets a tool its parent was denied.
+func (o SpawnOptions) narrowedBy(parent SpawnOptions) SpawnOptions {
+	if len(parent.Tools) > 0 {
+		if len(o.Tools) == 0 {
+			o.Tools = append([]string(nil), parent.Tools...)
+		} else {
+			allowed := make([]string, 0, len(o.Tools))
+			for _, name := range o.Tools {
+				if parent.allowsTool(name) {
+					allowed = append(allowed, name)
+				}
+			}
+			// Nothing in common must not fall back to "everything".
+			if len(allowed) == 0 {
+				allowed = []string{"subagent_report"}
+			}
+			o.Tools = allowed
+		}
+	}
+	o.DenyTools = append(append([]string(nil), o.DenyTools...), parent.DenyTools...)
+	return o
 }
 
 type SubagentTask struct {
@@ -56,6 +100,8 @@ type SubagentManager struct {
 	model             string
 	chatOptions       providers.ChatOptions
 	messageBudget     providers.MessageBudget
+	contextWindows    map[string]int // model -> context window overrides
+	contextWindow     int            // fallback window for unknown models (0 = keep messageBudget)
 	maxStoredTasks    int
 	completedTTL      time.Duration
 	llmTimeout        time.Duration
@@ -67,6 +113,15 @@ type SubagentManager struct {
 	nextID            int
 	unsafeGate        *UnsafeToolGate
 	disableSafeguards bool
+	coreTools         CoreToolsOptions
+	maxDepth          int
+	loopThreshold     int
+	lenientArgs       bool
+	resultMaxBytes    int
+	resultMaxByTool   map[string]int
+	events            *events.Bus       // nil = no lifecycle events
+	slots             chan struct{}     // one entry per running task; nil = no limit
+	toolAudit         SubagentToolAudit // nil = subagent tool calls are not audited
 }
 
 func toolCallSignature(toolCalls []providers.ToolCall) string {
@@ -116,6 +171,7 @@ func NewSubagentManager(provider providers.LLMProvider, model string, workspace
 		bus:              bus,
 		workspace:        workspace,
 		nextID:           1,
+		maxDepth:         DefaultSubagentMaxDepth,
 	}
 }
 
@@ -143,6 +199,15 @@ func (sm *SubagentManager) ConfigureMessageBudget(budget providers.MessageBudget
 	sm.messageBudget = budget
 }
 
+// ConfigureContextWindows sizes the message budget of each task from its
+// model's context window when no explicit budget is configured.
+func (sm *SubagentManager) ConfigureContextWindows(overrides map[string]int, fallback int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.contextWindows = overrides
+	sm.contextWindow = fallback
+}
+
 func (sm *SubagentManager) ConfigureUnsafeToolGate(gate *UnsafeToolGate) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -155,6 +220,50 @@ func (sm *SubagentManager) ConfigureDisableToolSafeguards(disable bool) {
 	sm.disableSafeguards = disable
 }
 
+// ConfigureCoreTools sets the exec options (output cap, command patterns)
+// subagent tool registries are built with. DisableSafeguards is taken from
+// ConfigureDisableToolSafeguards.
+func (sm *SubagentManager) ConfigureCoreTools(opts CoreToolsOptions) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.coreTools = opts
+}
+
+// ConfigureToolLoopThreshold sets how often an identical tool call may
+// repeat before the subagent is told it is looping (see llmloop.RunOptions).
+func (sm *SubagentManager) ConfigureToolLoopThreshold(threshold int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.loopThreshold = threshold
+}
+
+// ConfigureArgValidation sets whether subagent tool calls with arguments
+// that break the tool's schema are rejected (see ToolRegistry.SetArgValidation).
+func (sm *SubagentManager) ConfigureArgValidation(enabled bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.lenientArgs = !enabled
+}
+
+// ConfigureToolResultLimit caps subagent tool results fed back to the model
+// (see ToolRegistry.SetResultLimit).
+func (sm *SubagentManager) ConfigureToolResultLimit(maxBytes int, perTool map[string]int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resultMaxBytes = maxBytes
+	sm.resultMaxByTool = perTool
+}
+
+// ConfigureMaxDepth sets how deep subagents may nest. With maxDepth > 1,
+// subagents get their own spawn tool, which refuses past the limit.
+func (sm *SubagentManager) ConfigureMaxDepth(maxDepth int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if maxDepth > 0 {
+		sm.maxDepth = maxDepth
+	}
+}
+
 func (sm *SubagentManager) ConfigureRetention(maxStoredTasks int, completedTTL time.Duration) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -175,9 +284,37 @@ func (sm *SubagentManager) ConfigureCache(anthropicCache bool, anthropicCacheTTL
 	sm.chatOptions.AnthropicCacheTTL = strings.TrimSpace(anthropicCacheTTL)
 }
 
+// ConfigureConcurrency caps how many tasks run at once; tasks spawned past
+// the cap are queued and start in spawn order as slots free. 0 removes the
+// cap. Tasks already running keep the slot they hold.
+func (sm *SubagentManager) ConfigureConcurrency(maxConcurrent int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if maxConcurrent > 0 {
+		sm.slots = make(chan struct{}, maxConcurrent)
+	} else {
+		sm.slots = nil
+	}
+}
+
+// ConfigureEvents publishes subagent_spawned and subagent_completed events to
+// b. nil disables them.
+func (sm *SubagentManager) ConfigureEvents(b *events.Bus) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.events = b
+}
+
 func (sm *SubagentManager) Spawn(ctx context.Context, task, label, originChannel, originChatID, originSessionKey, parentTraceID string, opts SpawnOptions) (string, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+
+	if opts.Depth <= 0 {
+		opts.Depth = 1
+	}
+	if opts.Depth > sm.maxDepth {
+		return "", fmt.Errorf("%w (max %d)", ErrSubagentDepthLimit, sm.maxDepth)
+	}
 	sm.cleanupLocked(time.Now())
 
 	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
@@ -191,7 +328,7 @@ func (sm *SubagentManager) Spawn(ctx context.Context, task, label, originChannel
 		OriginChatID:     originChatID,
 		OriginSessionKey: strings.TrimSpace(originSessionKey),
 		ParentTraceID:    parentTraceID,
-		Status:           "running",
+		Status:           sm.initialStatusLocked(),
 		Created:          time.Now().UnixMilli(),
 		Finished:         0,
 		Options:          opts,
@@ -207,6 +344,18 @@ func (sm *SubagentManager) Spawn(ctx context.Context, task, label, originChannel
 	taskCtx, cancel := context.WithCancel(baseCtx)
 	sm.cancels[taskID] = cancel
 
+	sm.events.Publish(events.Event{
+		Type:       events.SubagentSpawned,
+		SessionKey: subagentTask.OriginSessionKey,
+		TraceID:    parentTraceID,
+		Data: map[string]interface{}{
+			"task_id": taskID,
+			"label":   label,
+			"model":   opts.Model,
+			"depth":   opts.Depth,
+		},
+	})
+
 	go sm.runTask(taskCtx, taskID)
 
 	logger.InfoCF("subagent", "Spawned subagent",
@@ -219,6 +368,9 @@ func (sm *SubagentManager) Spawn(ctx context.Context, task, label, originChannel
 			"task_preview":   utils.Truncate(task, 120),
 			"model":          opts.Model,
 			"max_iterations": opts.MaxIterations,
+			"depth":          opts.Depth,
+			"tools":          opts.Tools,
+			"deny_tools":     opts.DenyTools,
 		})
 
 	return taskID, nil
@@ -232,7 +384,7 @@ func (sm *SubagentManager) Cancel(taskID string) error {
 	if !ok {
 		return ErrSubagentTaskNotFound
 	}
-	if task.Status != "running" {
+	if task.Status != "running" && task.Status != "queued" {
 		return ErrSubagentNotRunning
 	}
 	cancel, ok := sm.cancels[taskID]
@@ -245,7 +397,80 @@ func (sm *SubagentManager) Cancel(taskID string) error {
 	return nil
 }
 
+// initialStatusLocked is "queued" when every slot is taken, else "running".
+func (sm *SubagentManager) initialStatusLocked() string {
+	if sm.slots != nil && len(sm.slots) >= cap(sm.slots) {
+		return "queued"
+	}
+	return "running"
+}
+
+// acquireSlot waits until the task may run under the concurrency limit and
+// marks it running. The returned func frees the slot.
+func (sm *SubagentManager) acquireSlot(ctx context.Context, taskID string) (func(), error) {
+	sm.mu.Lock()
+	slots := sm.slots
+	if task, ok := sm.tasks[taskID]; ok && slots != nil && task.Status == "running" && len(slots) >= cap(slots) {
+		task.Status = "queued"
+	}
+	sm.mu.Unlock()
+
+	release := func() {}
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+			release = func() { <-slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		release()
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	if task, ok := sm.tasks[taskID]; ok && task.Status == "queued" {
+		task.Status = "running"
+		logger.InfoCF("subagent", "Starting queued subagent", map[string]interface{}{
+			"task_id":  task.ID,
+			"label":    task.Label,
+			"trace_id": task.ParentTraceID,
+		})
+	}
+	sm.mu.Unlock()
+	return release, nil
+}
+
+// SubagentToolAudit records a finished tool call of task taskID. exec carries
+// the channel, chat, session and trace the call ran under. It may be called
+// concurrently.
+type SubagentToolAudit func(taskID string, exec ExecuteToolCallsOptions, call providers.ToolCall, result providers.Message, err error, duration time.Duration)
+
+// ConfigureToolAudit reports every tool call subagents make to audit. nil
+// disables it.
+func (sm *SubagentManager) ConfigureToolAudit(audit SubagentToolAudit) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.toolAudit = audit
+}
+
 func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
+	release, err := sm.acquireSlot(ctx, taskID)
+	if err != nil {
+		sm.mu.RLock()
+		task, ok := sm.tasks[taskID]
+		if !ok {
+			sm.mu.RUnlock()
+			return
+		}
+		initial := cloneSubagentTask(*task)
+		sm.mu.RUnlock()
+		sm.finishTask(taskID, initial, "cancelled", "Cancelled", 0)
+		return
+	}
+	defer release()
+
 	sm.mu.RLock()
 	task, ok := sm.tasks[taskID]
 	if !ok {
@@ -257,11 +482,19 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 	model := sm.model
 	chatOptions := sm.chatOptions
 	messageBudget := sm.messageBudget
+	contextWindows := sm.contextWindows
+	contextWindow := sm.contextWindow
 	llmTimeout := sm.llmTimeout
 	toolTimeout := sm.toolTimeout
 	maxParallelTools := sm.maxParallelTools
 	unsafeGate := sm.unsafeGate
 	disableSafeguards := sm.disableSafeguards
+	coreToolsOpts := sm.coreTools
+	maxDepth := sm.maxDepth
+	loopThreshold := sm.loopThreshold
+	lenientArgs := sm.lenientArgs
+	resultMaxBytes, resultMaxByTool := sm.resultMaxBytes, sm.resultMaxByTool
+	toolAudit := sm.toolAudit
 	sm.mu.RUnlock()
 
 	if initial.Options.Model != "" {
@@ -280,13 +513,27 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 	if model == "" {
 		model = sm.provider.GetDefaultModel()
 	}
+	if !messageBudget.Enabled() && contextWindow > 0 {
+		messageBudget = providers.BudgetFromContextWindow(providers.ContextWindowFor(model, contextWindows, contextWindow))
+	}
 
-	// Build a subagent-only tool registry.
+	// Build a subagent-only tool registry. Tools are collected first and then
+	// copied over through the task's tool filter.
 	registry := NewToolRegistry()
+	registry.SetArgValidation(!lenientArgs)
+	registry.SetResultLimit(resultMaxBytes, resultMaxByTool)
 	if !disableSafeguards {
 		registry.SetUnsafeToolGate(unsafeGate)
 	}
-	RegisterCoreTools(registry, sm.workspace, WebSearchToolConfig{MaxResults: 5}, CoreToolsOptions{DisableSafeguards: disableSafeguards}) // web search will self-report if key missing
+	available := NewToolRegistry()
+	coreToolsOpts.DisableSafeguards = disableSafeguards
+	// web search will self-report if key missing
+	if err := RegisterCoreTools(available, sm.workspace, WebSearchToolConfig{MaxResults: 5}, coreToolsOpts); err != nil {
+		logger.ErrorCF("subagent", "Core tools disabled: invalid exec pattern", map[string]interface{}{
+			"task_id": initial.ID,
+			"error":   err.Error(),
+		})
+	}
 
 	// Allow subagents to message the originating chat. This is required for
 	// streaming workflows (e.g. sending generated images as they finish).
@@ -296,8 +543,31 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 		msgOpts.ForceContextTarget = true
 		msgOpts.RestrictMediaToWorkspace = true
 	}
-	RegisterMessageTool(registry, sm.bus, sm.workspace, msgOpts)
-	registry.Register(NewSubagentReportTool(sm.bus, initial.ID, initial.Label, initial.OriginChannel, initial.OriginChatID))
+	RegisterMessageTool(available, sm.bus, sm.workspace, msgOpts)
+	available.Register(NewSubagentReportTool(sm.bus, initial.ID, initial.Label, initial.OriginChannel, initial.OriginChatID))
+
+	// Nested spawns inherit this task's execution context, so reports from
+	// grandchildren still route to the originating chat. Tasks at the limit
+	// keep the tool so a spawn attempt gets a clear refusal.
+	if maxDepth > DefaultSubagentMaxDepth {
+		available.Register(newNestedSpawnTool(sm, initial.Options))
+	}
+
+	for _, name := range available.List() {
+		if !initial.Options.allowsTool(name) {
+			continue
+		}
+		tool, _ := available.Get(name)
+		registry.Register(tool)
+	}
+	for _, name := range initial.Options.Tools {
+		if _, ok := available.Get(strings.ToLower(strings.TrimSpace(name))); !ok {
+			logger.WarnCF("subagent", "Requested tool does not exist", map[string]interface{}{
+				"task_id": initial.ID,
+				"tool":    name,
+			})
+		}
+	}
 
 	systemPrompt := sm.buildSubagentSystemPrompt(registry)
 	messages := []providers.Message{
@@ -316,6 +586,7 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 		ChatOptions:   chatOptions.ToMap(),
 		MessageBudget: messageBudget,
 		Messages:      messages,
+		LoopThreshold: loopThreshold,
 		BuildToolDefs: func(iteration int, _ []providers.Message) []providers.ToolDefinition {
 			return registry.GetProviderDefinitions()
 		},
@@ -334,7 +605,7 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 				}
 			}
 
-			results := registry.ExecuteToolCalls(ctx, toolCalls, ExecuteToolCallsOptions{
+			execOpts := ExecuteToolCallsOptions{
 				Channel:      execChannel,
 				ChatID:       execChatID,
 				SessionKey:   sessionKey,
@@ -354,7 +625,14 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 							"total":     total,
 						})
 				},
-			})
+			}
+			if toolAudit != nil {
+				auditOpts := execOpts
+				execOpts.OnToolFinished = func(_ int, call providers.ToolCall, result providers.Message, err error, duration time.Duration) {
+					toolAudit(initial.ID, auditOpts, call, result, err, duration)
+				}
+			}
+			results := registry.ExecuteToolCalls(ctx, toolCalls, execOpts)
 
 			signature := toolCallSignature(toolCalls)
 			if len(toolCalls) == 1 && len(results) == 1 && isMissingRequiredToolError(results[0]) {
@@ -450,11 +728,25 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 				}
 				logger.DebugCF("subagent", "Tool result", fields)
 			},
+			LoopDetected: func(iteration int, toolName string, count int, stopped bool) {
+				logger.WarnCF("subagent", "Detected repeated tool call loop",
+					map[string]interface{}{
+						"task_id":      initial.ID,
+						"trace_id":     initial.ParentTraceID,
+						"iteration":    iteration,
+						"tool":         toolName,
+						"repeat_count": count,
+						"stopped":      stopped,
+					})
+			},
 		},
 	})
 
 	status := "completed"
 	result := loopRes.FinalContent
+	if loopRes.LoopStopped && strings.TrimSpace(result) == "" {
+		result = "Stopped early: the task kept repeating the same tool call without making progress."
+	}
 	if finalErr != nil {
 		if errors.Is(finalErr, context.Canceled) {
 			status = "cancelled"
@@ -464,9 +756,14 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 			result = fmt.Sprintf("Error: %v", finalErr)
 		}
 	}
+	sm.finishTask(taskID, initial, status, result, loopRes.Iterations)
+}
 
+// finishTask records the task's terminal status, publishes its completion
+// event and reports the result back to the originating chat.
+func (sm *SubagentManager) finishTask(taskID string, initial SubagentTask, status, result string, iterations int) {
 	sm.mu.Lock()
-	task, ok = sm.tasks[taskID]
+	task, ok := sm.tasks[taskID]
 	if ok {
 		task.Status = status
 		task.Result = result
@@ -477,8 +774,23 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 	if ok {
 		initial = cloneSubagentTask(*task)
 	}
+	eventBus := sm.events
 	sm.mu.Unlock()
 
+	eventBus.Publish(events.Event{
+		Type:       events.SubagentCompleted,
+		SessionKey: initial.OriginSessionKey,
+		TraceID:    initial.ParentTraceID,
+		Data: map[string]interface{}{
+			"task_id":      initial.ID,
+			"label":        initial.Label,
+			"status":       status,
+			"iterations":   iterations,
+			"result_chars": len(result),
+			"duration_ms":  initial.Finished - initial.Created,
+		},
+	})
+
 	switch status {
 	case "failed":
 		logger.ErrorCF("subagent", "Subagent failed",
@@ -532,9 +844,10 @@ func (sm *SubagentManager) runTask(ctx context.Context, taskID string) {
 			ChatID:  routing.EncodeSystemRoute(initial.OriginChannel, initial.OriginChatID),
 			Content: announceContent,
 			Metadata: map[string]string{
-				"subagent_event":   event,
-				"subagent_task_id": initial.ID,
-				"trace_id":         initial.ParentTraceID,
+				"subagent_event":     event,
+				"subagent_task_id":   initial.ID,
+				"trace_id":           initial.ParentTraceID,
+				"origin_session_key": initial.OriginSessionKey,
 			},
 		})
 	}
@@ -556,15 +869,10 @@ func (sm *SubagentManager) buildSubagentSystemPrompt(registry *ToolRegistry) str
 	}
 
 	// Skills summary (same loader behavior as main agent: workspace > global > builtin)
-	wd, _ := os.Getwd()
-	globalSkillsDir := ""
-	if home, err := os.UserHomeDir(); err == nil {
-		globalSkillsDir = filepath.Join(home, ".picoclaw", "skills")
-	}
-	loader := skills.NewSkillsLoader(sm.workspace, globalSkillsDir, filepath.Join(wd, "skills"))
+	loader := newDefaultSkillsLoader(sm.workspace)
 	skillsSummary := loader.BuildSkillsSummary()
 	if skillsSummary != "" {
-		skillsSummary = "## Skills\n\nThe following skills extend your capabilities. To use a skill, read its SKILL.md file using the read_file tool.\n\n" + skillsSummary
+		skillsSummary = "## Skills\n\nThe following skills extend your capabilities. To use a skill, load its instructions with the `skills` tool (action=read).\n\n" + skillsSummary
 	}
 
 	workspacePath, _ := filepath.Abs(filepath.Join(sm.workspace))
@@ -107,6 +107,9 @@ func (t *SubagentReportTool) Execute(_ context.Context, args map[string]interfac
 		if t.label != "" {
 			md["subagent_label"] = t.label
 		}
+		if sessionKey := strings.TrimSpace(getExecutionSessionKey(args)); sessionKey != "" {
+			md["origin_session_key"] = sessionKey
+		}
 		chatID := routing.EncodeSystemRoute(t.originChannel, t.originChatID)
 		t.bus.PublishInbound(bus.InboundMessage{
 			Channel:  "system",
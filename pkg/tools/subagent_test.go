@@ -5,12 +5,14 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/events"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -591,3 +593,387 @@ func TestBuildSubagentSystemPrompt_IncludesSessionHistoryGuidance(t *testing.T)
 		t.Fatalf("expected prompt to mention session_history guidance, got:\n%s", prompt)
 	}
 }
+
+// nestingProvider makes every subagent spawn one child ("<task>/child") and
+// records the spawn tool result it got back.
+type nestingProvider struct {
+	mu          sync.Mutex
+	spawnResult map[string]string
+	offered     map[string]bool
+}
+
+func (p *nestingProvider) Chat(_ context.Context, messages []providers.Message, tools []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	task := ""
+	toolResult := ""
+	hasToolResult := false
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			task = m.Content
+		case "tool":
+			toolResult = m.Content
+			hasToolResult = true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if hasToolResult {
+		p.spawnResult[task] = toolResult
+		return &providers.LLMResponse{Content: "done"}, nil
+	}
+	for _, def := range tools {
+		if def.Function.Name == "spawn" {
+			p.offered[task] = true
+		}
+	}
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+		ID:        "tc-" + task,
+		Name:      "spawn",
+		Arguments: map[string]interface{}{"task": task + "/child"},
+	}}}, nil
+}
+
+func (p *nestingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestSubagentManager_NestedSpawnStopsAtMaxDepth(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	prov := &nestingProvider{spawnResult: map[string]string{}, offered: map[string]bool{}}
+	sm := NewSubagentManager(prov, "test-model", t.TempDir(), msgBus)
+	sm.ConfigureMaxDepth(2)
+
+	if _, err := sm.Spawn(context.Background(), "root", "", "telegram", "chat1", "telegram:chat1", "", SpawnOptions{}); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	completed := map[string]bool{}
+	for len(completed) < 2 {
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatalf("timed out waiting for completions, got %v", completed)
+		}
+		if msg.Metadata["subagent_event"] != "complete" {
+			continue
+		}
+		if msg.Channel != "system" || msg.ChatID != "telegram:chat1" {
+			t.Fatalf("completion routed to %s/%s, want system/telegram:chat1", msg.Channel, msg.ChatID)
+		}
+		completed[msg.Metadata["subagent_task_id"]] = true
+	}
+
+	tasks := sm.ListTasks()
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks (depth 1 and 2), got %d", len(tasks))
+	}
+	depths := map[string]int{}
+	for _, task := range tasks {
+		depths[task.Task] = task.Options.Depth
+	}
+	if depths["root"] != 1 || depths["root/child"] != 2 {
+		t.Fatalf("unexpected task depths: %v", depths)
+	}
+
+	prov.mu.Lock()
+	defer prov.mu.Unlock()
+	if !prov.offered["root"] {
+		t.Fatal("depth-1 subagent should be offered the spawn tool")
+	}
+	if !strings.Contains(prov.spawnResult["root"], "Spawned subagent") {
+		t.Fatalf("depth-1 spawn result = %q", prov.spawnResult["root"])
+	}
+	if got := prov.spawnResult["root/child"]; !strings.HasPrefix(got, "Error:") || !strings.Contains(got, "depth limit") {
+		t.Fatalf("depth-2 spawn should be refused, got %q", prov.spawnResult["root/child"])
+	}
+}
+
+func TestSpawnTool_RefusesPastMaxDepth(t *testing.T) {
+	sm := NewSubagentManager(&doneProvider{}, "test-model", t.TempDir(), nil)
+	sm.ConfigureMaxDepth(2)
+
+	out, err := newNestedSpawnTool(sm, SpawnOptions{Depth: 2}).Execute(context.Background(), map[string]interface{}{"task": "deeper"})
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if !strings.HasPrefix(out, "Error:") || !strings.Contains(out, "depth limit") {
+		t.Fatalf("expected depth limit error, got %q", out)
+	}
+	if len(sm.ListTasks()) != 0 {
+		t.Fatal("no task should be created past the depth limit")
+	}
+}
+
+type toolNamesProvider struct {
+	called chan []string
+}
+
+func (p *toolNamesProvider) Chat(_ context.Context, _ []providers.Message, tools []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Function.Name)
+	}
+	sort.Strings(names)
+	select {
+	case p.called <- names:
+	default:
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *toolNamesProvider) GetDefaultModel() string { return "test-model" }
+
+func spawnAndCollectToolNames(t *testing.T, opts SpawnOptions) []string {
+	t.Helper()
+	prov := &toolNamesProvider{called: make(chan []string, 1)}
+	sm := NewSubagentManager(prov, "test-model", t.TempDir(), nil)
+	if _, err := sm.Spawn(context.Background(), "research", "", "telegram", "chat1", "telegram:chat1", "", opts); err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	select {
+	case names := <-prov.called:
+		return names
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for subagent LLM call")
+		return nil
+	}
+}
+
+func TestSubagentManager_ToolAllowlistLimitsRegistry(t *testing.T) {
+	names := spawnAndCollectToolNames(t, SpawnOptions{Tools: []string{"read_file", "web_search"}})
+
+	// subagent_report is kept so the task can still deliver its result.
+	want := []string{"read_file", "subagent_report", "web_search"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("subagent tools = %v, want %v", names, want)
+	}
+}
+
+func TestSubagentManager_ToolDenylistRemovesTools(t *testing.T) {
+	full := spawnAndCollectToolNames(t, SpawnOptions{})
+	names := spawnAndCollectToolNames(t, SpawnOptions{DenyTools: []string{"exec", "unsafe_exec", "subagent_report"}})
+
+	if len(names) != len(full)-2 {
+		t.Fatalf("expected exactly exec and unsafe_exec removed, got %v (full %v)", names, full)
+	}
+	for _, name := range names {
+		if name == "exec" || name == "unsafe_exec" {
+			t.Fatalf("denied tool %q still offered: %v", name, names)
+		}
+	}
+}
+
+func TestSpawnOptions_NarrowedByParent(t *testing.T) {
+	parent := SpawnOptions{Tools: []string{"read_file", "web_search", "spawn"}, DenyTools: []string{"exec"}}
+
+	child := SpawnOptions{}.narrowedBy(parent)
+	if !child.allowsTool("read_file") || child.allowsTool("write_file") || child.allowsTool("exec") {
+		t.Fatalf("child without filter should inherit the parent's: %+v", child)
+	}
+
+	child = SpawnOptions{Tools: []string{"read_file", "exec", "write_file"}}.narrowedBy(parent)
+	if !child.allowsTool("read_file") || child.allowsTool("exec") || child.allowsTool("write_file") {
+		t.Fatalf("child must not widen the parent's tools: %+v", child)
+	}
+
+	child = SpawnOptions{Tools: []string{"write_file"}}.narrowedBy(parent)
+	if child.allowsTool("write_file") || child.allowsTool("read_file") || !child.allowsTool("subagent_report") {
+		t.Fatalf("disjoint allowlists should leave only subagent_report: %+v", child)
+	}
+}
+
+func TestSubagentManager_PublishesSpawnAndCompletionEvents(t *testing.T) {
+	eventBus := events.NewBus()
+	sub, unsubscribe := eventBus.Subscribe(8)
+	defer unsubscribe()
+
+	sm := NewSubagentManager(&doneProvider{}, "test-model", t.TempDir(), nil)
+	sm.ConfigureEvents(eventBus)
+	taskID, err := sm.Spawn(context.Background(), "work", "job", "telegram", "chat1", "telegram:chat1", "trace-1", SpawnOptions{})
+	if err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+
+	for _, want := range []string{events.SubagentSpawned, events.SubagentCompleted} {
+		select {
+		case e := <-sub:
+			if e.Type != want || e.SessionKey != "telegram:chat1" || e.TraceID != "trace-1" || e.Data["task_id"] != taskID {
+				t.Fatalf("got %+v, want %s for %s", e, want, taskID)
+			}
+			if want == events.SubagentCompleted && e.Data["status"] != "completed" {
+				t.Fatalf("completion status = %v", e.Data["status"])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestSubagentManager_ReportsToolCallsToAudit(t *testing.T) {
+	prov := &scriptedProvider{responses: []*providers.LLMResponse{
+		{ToolCalls: []providers.ToolCall{{ID: "tc1", Name: "list_dir", Arguments: map[string]interface{}{"path": "."}}}},
+		{Content: "done"},
+	}}
+	type audited struct {
+		taskID string
+		exec   ExecuteToolCallsOptions
+		call   providers.ToolCall
+	}
+	got := make(chan audited, 4)
+
+	sm := NewSubagentManager(prov, "test-model", t.TempDir(), nil)
+	sm.ConfigureToolAudit(func(taskID string, exec ExecuteToolCallsOptions, call providers.ToolCall, _ providers.Message, _ error, _ time.Duration) {
+		got <- audited{taskID, exec, call}
+	})
+	taskID, err := sm.Spawn(context.Background(), "look", "job", "telegram", "chat1", "telegram:chat1", "trace-1", SpawnOptions{})
+	if err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+
+	select {
+	case a := <-got:
+		if a.taskID != taskID || a.call.Name != "list_dir" {
+			t.Fatalf("audited %q/%q, want %q/list_dir", a.taskID, a.call.Name, taskID)
+		}
+		if a.exec.Channel != "telegram" || a.exec.ChatID != "chat1" || a.exec.SessionKey != "telegram:chat1" || a.exec.TraceID != "trace-1" {
+			t.Fatalf("audit context = %q %q %q %q", a.exec.Channel, a.exec.ChatID, a.exec.SessionKey, a.exec.TraceID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the audited tool call")
+	}
+}
+
+// gatedProvider holds every call until release is signalled and records how
+// many calls were in flight at once.
+type gatedProvider struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	release     chan struct{}
+}
+
+func (p *gatedProvider) Chat(ctx context.Context, _ []providers.Message, _ []providers.ToolDefinition, _ string, _ map[string]interface{}) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxInFlight {
+		p.maxInFlight = p.inFlight
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+	select {
+	case <-p.release:
+		return &providers.LLMResponse{Content: "done"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *gatedProvider) GetDefaultModel() string { return "test-model" }
+
+func (p *gatedProvider) counts() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight, p.maxInFlight
+}
+
+func subagentStatusCounts(sm *SubagentManager) map[string]int {
+	counts := map[string]int{}
+	for _, task := range sm.ListTasks() {
+		counts[task.Status]++
+	}
+	return counts
+}
+
+func waitForSubagentStatus(t *testing.T, sm *SubagentManager, want map[string]int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := subagentStatusCounts(sm)
+		match := len(got) == len(want)
+		for status, n := range want {
+			if got[status] != n {
+				match = false
+			}
+		}
+		if match {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task statuses = %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubagentManager_ConcurrencyLimitQueuesExtraTasks(t *testing.T) {
+	prov := &gatedProvider{release: make(chan struct{})}
+	sm := NewSubagentManager(prov, "test-model", t.TempDir(), nil)
+	sm.ConfigureConcurrency(2)
+
+	for i := 0; i < 4; i++ {
+		if _, err := sm.Spawn(context.Background(), "work", "", "telegram", "chat1", "telegram:chat1", "", SpawnOptions{}); err != nil {
+			t.Fatalf("Spawn() error: %v", err)
+		}
+	}
+	waitForSubagentStatus(t, sm, map[string]int{"running": 2, "queued": 2})
+	time.Sleep(50 * time.Millisecond)
+	if inFlight, _ := prov.counts(); inFlight != 2 {
+		t.Fatalf("in-flight provider calls = %d, want 2", inFlight)
+	}
+
+	out, err := NewSpawnTool(sm).Execute(context.Background(), map[string]interface{}{"action": "list"})
+	if err != nil {
+		t.Fatalf("list error: %v", err)
+	}
+	if strings.Count(out, "Status: queued") != 2 {
+		t.Fatalf("expected list to show 2 queued tasks, got %q", out)
+	}
+
+	// Finishing one task starts one queued task.
+	prov.release <- struct{}{}
+	waitForSubagentStatus(t, sm, map[string]int{"completed": 1, "running": 2, "queued": 1})
+
+	for i := 0; i < 3; i++ {
+		prov.release <- struct{}{}
+	}
+	waitForSubagentStatus(t, sm, map[string]int{"completed": 4})
+	if _, maxInFlight := prov.counts(); maxInFlight != 2 {
+		t.Fatalf("max in-flight provider calls = %d, want 2", maxInFlight)
+	}
+}
+
+func TestSubagentManager_CancelQueuedTask(t *testing.T) {
+	prov := &gatedProvider{release: make(chan struct{})}
+	sm := NewSubagentManager(prov, "test-model", t.TempDir(), nil)
+	sm.ConfigureConcurrency(1)
+
+	first, err := sm.Spawn(context.Background(), "work", "", "telegram", "chat1", "telegram:chat1", "", SpawnOptions{})
+	if err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	queued, err := sm.Spawn(context.Background(), "work", "", "telegram", "chat1", "telegram:chat1", "", SpawnOptions{})
+	if err != nil {
+		t.Fatalf("Spawn() error: %v", err)
+	}
+	waitForSubagentStatus(t, sm, map[string]int{"running": 1, "queued": 1})
+
+	if err := sm.Cancel(queued); err != nil {
+		t.Fatalf("Cancel() error: %v", err)
+	}
+	waitForSubagentStatus(t, sm, map[string]int{"running": 1, "cancelled": 1})
+	if task, _ := sm.GetTask(first); task.Status != "running" {
+		t.Fatalf("first task status = %q, want running", task.Status)
+	}
+
+	prov.release <- struct{}{}
+	waitForSubagentStatus(t, sm, map[string]int{"completed": 1, "cancelled": 1})
+	if _, maxInFlight := prov.counts(); maxInFlight != 1 {
+		t.Fatalf("max in-flight provider calls = %d, want 1", maxInFlight)
+	}
+}
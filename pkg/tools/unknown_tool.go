@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownToolError is returned for a call to a tool that is not registered,
+// usually a name the model made up. Its message lists the tools that can be
+// called so the model can correct itself instead of giving up.
+type UnknownToolError struct {
+	Tool      string
+	Available []string
+	// Suggestion is the available tool the name most likely meant, or "".
+	Suggestion string
+}
+
+func (e *UnknownToolError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tool '%s' does not exist.", e.Tool)
+	if e.Suggestion != "" {
+		fmt.Fprintf(&sb, " Did you mean '%s'?", e.Suggestion)
+	}
+	if len(e.Available) == 0 {
+		sb.WriteString(" No tools are available.")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, " Available tools: [%s]. Call one of these by its exact name.", strings.Join(e.Available, ", "))
+	return sb.String()
+}
+
+// Structured is the machine-readable tool result for the model.
+func (e *UnknownToolError) Structured() map[string]interface{} {
+	out := map[string]interface{}{
+		"error":           "unknown_tool",
+		"tool":            e.Tool,
+		"available_tools": e.Available,
+	}
+	if e.Suggestion != "" {
+		out["suggestion"] = e.Suggestion
+	}
+	return out
+}
+
+// unknownToolError lists the tools the policy lets the model call.
+func (r *ToolRegistry) unknownToolError(name string) *UnknownToolError {
+	available := make([]string, 0, r.Count())
+	for _, tool := range r.List() {
+		if r.checkPolicy(tool) == nil {
+			available = append(available, tool)
+		}
+	}
+	return &UnknownToolError{
+		Tool:       name,
+		Available:  available,
+		Suggestion: closestToolName(name, available),
+	}
+}
+
+// closestToolName returns the tool name meant by a near miss: a different
+// case or separator ("readFile", "read-file"), a namespace prefix
+// ("functions.read_file") or a small typo. "" when nothing is close.
+func closestToolName(name string, available []string) string {
+	normalize := func(s string) string {
+		if i := strings.LastIndexAny(s, ".:/"); i >= 0 {
+			s = s[i+1:]
+		}
+		return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(s))
+	}
+	want := normalize(name)
+	if want == "" {
+		return ""
+	}
+	best, bestDist := "", 3 // at most two edits
+	for _, candidate := range available {
+		got := normalize(candidate)
+		if got == want {
+			return candidate
+		}
+		if d := editDistance(want, got); d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b in bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
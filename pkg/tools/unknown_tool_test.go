@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newUnknownToolTestRegistry() *ToolRegistry {
+	registry := NewToolRegistry()
+	for _, name := range []string{"read_file", "write_file", "exec"} {
+		registry.Register(&execTestTool{name: name, result: "ok"})
+	}
+	registry.SetExecutionPolicy(NewToolExecutionPolicy(true, nil, []string{"exec"}))
+	return registry
+}
+
+func TestToolRegistry_UnknownToolListsAvailableTools(t *testing.T) {
+	registry := newUnknownToolTestRegistry()
+
+	_, err := registry.ExecuteWithContext(context.Background(), "search_files", nil, "", "")
+	var unknownErr *UnknownToolError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("expected an UnknownToolError, got %v", err)
+	}
+	want := "Tool 'search_files' does not exist. Available tools: [read_file, write_file]. Call one of these by its exact name."
+	if err.Error() != want {
+		t.Fatalf("error = %q\nwant    %q", err.Error(), want)
+	}
+}
+
+func TestToolRegistry_UnknownToolSuggestsNearMiss(t *testing.T) {
+	registry := newUnknownToolTestRegistry()
+
+	for _, name := range []string{"readFile", "functions.read_file", "read_fiel", "Read-File"} {
+		_, err := registry.ExecuteWithContext(context.Background(), name, nil, "", "")
+		var unknownErr *UnknownToolError
+		if !errors.As(err, &unknownErr) || unknownErr.Suggestion != "read_file" {
+			t.Fatalf("%s: expected suggestion read_file, got %v", name, err)
+		}
+		if !strings.Contains(err.Error(), "Did you mean 'read_file'?") {
+			t.Fatalf("%s: message lacks the suggestion: %q", name, err)
+		}
+	}
+
+	// Denied tools are neither listed nor suggested.
+	_, err := registry.ExecuteWithContext(context.Background(), "exce", nil, "", "")
+	var unknownErr *UnknownToolError
+	if !errors.As(err, &unknownErr) || unknownErr.Suggestion != "" {
+		t.Fatalf("expected no suggestion for a denied tool, got %v", err)
+	}
+}
+
+func TestExecuteToolCalls_UnknownToolReturnsGuidance(t *testing.T) {
+	registry := newUnknownToolTestRegistry()
+
+	results := registry.ExecuteToolCalls(context.Background(), []providers.ToolCall{
+		{ID: "tc1", Name: "browse_web", Arguments: map[string]interface{}{}},
+	}, ExecuteToolCallsOptions{MaxParallel: 1})
+
+	content := results[0].Content
+	if !strings.HasPrefix(content, "Error: Tool 'browse_web' does not exist. Available tools: [read_file, write_file].") {
+		t.Fatalf("unexpected content:\n%s", content)
+	}
+	if !strings.Contains(content, `"error":"unknown_tool"`) || !strings.Contains(content, `"available_tools":["read_file","write_file"]`) {
+		t.Fatalf("expected structured unknown_tool payload:\n%s", content)
+	}
+	if strings.Contains(content, "not found") {
+		t.Fatalf("expected guidance instead of a generic error:\n%s", content)
+	}
+}
@@ -77,6 +77,11 @@ func (t *WebSearchTool) Name() string {
 	return "web_search"
 }
 
+// Cacheable reports that identical calls may reuse a cached result.
+func (t *WebSearchTool) Cacheable() bool {
+	return true
+}
+
 func (t *WebSearchTool) Description() string {
 	return "Search the web for current information. Supports web and image results, with optional safe search mode. Returns titles, URLs, and snippets from search results."
 }
@@ -848,6 +853,11 @@ func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
+// Cacheable reports that identical calls may reuse a cached result.
+func (t *WebFetchTool) Cacheable() bool {
+	return true
+}
+
 func (t *WebFetchTool) Description() string {
 	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
 }
@@ -870,6 +880,11 @@ func (t *WebFetchTool) Parameters() map[string]interface{} {
 	}
 }
 
+// TimeoutHint covers the 60s HTTP client timeout plus content extraction.
+func (t *WebFetchTool) TimeoutHint() time.Duration {
+	return 90 * time.Second
+}
+
 func (t *WebFetchTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	urlStr, ok := args["url"].(string)
 	if !ok {
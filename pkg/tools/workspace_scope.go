@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// WorkspaceIsolation selects whether workspace-scoped tools share one root or
+// get a private subdirectory per channel or per chat.
+type WorkspaceIsolation string
+
+const (
+	WorkspaceShared     WorkspaceIsolation = ""        // every chat uses the workspace itself
+	WorkspacePerChannel WorkspaceIsolation = "channel" // <workspace>/<channel>
+	WorkspacePerChat    WorkspaceIsolation = "chat"    // <workspace>/<channel>/<chat_id>
+)
+
+// ParseWorkspaceIsolation maps a config value to a mode. "" and "none" mean
+// shared.
+func ParseWorkspaceIsolation(value string) (WorkspaceIsolation, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "none":
+		return WorkspaceShared, nil
+	case "channel":
+		return WorkspacePerChannel, nil
+	case "chat":
+		return WorkspacePerChat, nil
+	default:
+		return WorkspaceShared, fmt.Errorf("unknown workspace isolation %q (want none, channel or chat)", value)
+	}
+}
+
+var unsafeWorkspaceSegmentChars = regexp.MustCompile(`[^A-Za-z0-9._@+-]`)
+
+// workspaceSegment turns a channel or chat ID into a single safe directory
+// name, so IDs can never traverse out of the workspace.
+func workspaceSegment(id string) string {
+	segment := unsafeWorkspaceSegmentChars.ReplaceAllString(strings.TrimSpace(id), "_")
+	if strings.Trim(segment, ".") == "" {
+		segment = strings.ReplaceAll(segment, ".", "_")
+	}
+	return segment
+}
+
+// scopedWorkspace returns the root a tool call operates in: base itself when
+// shared, otherwise the caller's channel or chat directory under base,
+// created on demand. The execution context comes from args (see
+// withExecutionContext); isolated calls without one are refused rather than
+// falling back to the shared root.
+func scopedWorkspace(base string, mode WorkspaceIsolation, args map[string]interface{}) (string, error) {
+	channel, chatID := getExecutionContext(args)
+	return ScopedWorkspace(base, mode, channel, chatID)
+}
+
+// ScopedWorkspace is the root used by a call from channel and chatID, for
+// callers outside the tools that need to write where those tools can read.
+func ScopedWorkspace(base string, mode WorkspaceIsolation, channel, chatID string) (string, error) {
+	if mode == WorkspaceShared || strings.TrimSpace(base) == "" {
+		return base, nil
+	}
+
+	channel = workspaceSegment(channel)
+	chatID = workspaceSegment(chatID)
+	if channel == "" || (mode == WorkspacePerChat && chatID == "") {
+		return "", fmt.Errorf("workspace isolation is enabled but the call has no chat context")
+	}
+
+	root := filepath.Join(base, channel)
+	if mode == WorkspacePerChat {
+		root = filepath.Join(root, chatID)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create workspace %s: %w", root, err)
+	}
+	return root, nil
+}
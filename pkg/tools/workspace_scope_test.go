@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newIsolatedRegistry(t *testing.T, mode WorkspaceIsolation) (*ToolRegistry, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	registry := NewToolRegistry()
+	if err := RegisterCoreTools(registry, workspace, WebSearchToolConfig{}, CoreToolsOptions{WorkspaceIsolation: mode}); err != nil {
+		t.Fatalf("RegisterCoreTools: %v", err)
+	}
+	return registry, workspace
+}
+
+func TestWorkspaceIsolation_ChatsDoNotOverwriteEachOther(t *testing.T) {
+	registry, workspace := newIsolatedRegistry(t, WorkspacePerChat)
+	ctx := context.Background()
+
+	for _, chat := range []string{"alice", "bob"} {
+		result, err := registry.ExecuteWithContext(ctx, "write_file", map[string]interface{}{
+			"path":    "notes.txt",
+			"content": "notes from " + chat,
+		}, "telegram", chat)
+		if err != nil {
+			t.Fatalf("write_file for %s: %v (%s)", chat, err, result)
+		}
+	}
+
+	for _, chat := range []string{"alice", "bob"} {
+		data, err := os.ReadFile(filepath.Join(workspace, "telegram", chat, "notes.txt"))
+		if err != nil {
+			t.Fatalf("expected %s's file in its own directory: %v", chat, err)
+		}
+		if string(data) != "notes from "+chat {
+			t.Fatalf("%s's notes = %q", chat, data)
+		}
+
+		got, err := registry.ExecuteWithContext(ctx, "read_file", map[string]interface{}{"path": "notes.txt"}, "telegram", chat)
+		if err != nil || !strings.Contains(got, "notes from "+chat) {
+			t.Fatalf("read_file for %s = %q, %v", chat, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "notes.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written to the shared root, stat err = %v", err)
+	}
+
+	// The workspace guard uses the chat's directory as root.
+	if _, err := registry.ExecuteWithContext(ctx, "read_file", map[string]interface{}{"path": "../bob/notes.txt"}, "telegram", "alice"); err == nil {
+		t.Fatal("expected reading another chat's file to be refused")
+	}
+}
+
+func TestWorkspaceIsolation_ExecRunsInChatDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	registry, workspace := newIsolatedRegistry(t, WorkspacePerChat)
+	ctx := context.Background()
+
+	out, err := registry.ExecuteWithContext(ctx, "exec", map[string]interface{}{"command": "pwd"}, "discord", "room-1")
+	if err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(workspace, "discord", "room-1"))
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(out)); got != want {
+		t.Fatalf("exec ran in %q, want %q", strings.TrimSpace(out), want)
+	}
+
+	out, _ = registry.ExecuteWithContext(ctx, "exec", map[string]interface{}{"command": "ls", "cwd": ".."}, "discord", "room-1")
+	if !strings.Contains(out, "outside workspace") {
+		t.Fatalf("expected cwd outside the chat directory to be blocked, got %q", out)
+	}
+}
+
+func TestWorkspaceIsolation_RequiresChatContextAndSanitizesIDs(t *testing.T) {
+	registry, workspace := newIsolatedRegistry(t, WorkspacePerChat)
+	ctx := context.Background()
+
+	if _, err := registry.ExecuteWithContext(ctx, "list_dir", map[string]interface{}{"path": "."}, "", ""); err == nil {
+		t.Fatal("expected isolated call without chat context to be refused")
+	}
+
+	if _, err := registry.ExecuteWithContext(ctx, "write_file", map[string]interface{}{
+		"path":    "x.txt",
+		"content": "x",
+	}, "cli", "../.."); err != nil {
+		t.Fatalf("write_file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "cli", ".._..", "x.txt")); err != nil {
+		t.Fatalf("expected traversal chat ID to be sanitized into one directory: %v", err)
+	}
+	if got := workspaceSegment(".."); got != "__" {
+		t.Fatalf("workspaceSegment(\"..\") = %q, want %q", got, "__")
+	}
+}
+
+func TestWorkspaceIsolation_PerChannelAndShared(t *testing.T) {
+	registry, workspace := newIsolatedRegistry(t, WorkspacePerChannel)
+	if _, err := registry.ExecuteWithContext(context.Background(), "write_file", map[string]interface{}{
+		"path":    "a.txt",
+		"content": "a",
+	}, "slack", "C123"); err != nil {
+		t.Fatalf("write_file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "slack", "a.txt")); err != nil {
+		t.Fatalf("expected per-channel directory: %v", err)
+	}
+
+	shared, sharedWorkspace := newIsolatedRegistry(t, WorkspaceShared)
+	if _, err := shared.ExecuteWithContext(context.Background(), "write_file", map[string]interface{}{
+		"path":    "a.txt",
+		"content": "a",
+	}, "slack", "C123"); err != nil {
+		t.Fatalf("write_file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sharedWorkspace, "a.txt")); err != nil {
+		t.Fatalf("expected shared mode to write to the workspace root: %v", err)
+	}
+}
+
+func TestParseWorkspaceIsolation(t *testing.T) {
+	for in, want := range map[string]WorkspaceIsolation{"": WorkspaceShared, "none": WorkspaceShared, "Chat": WorkspacePerChat, "channel": WorkspacePerChannel} {
+		if got, err := ParseWorkspaceIsolation(in); err != nil || got != want {
+			t.Fatalf("ParseWorkspaceIsolation(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseWorkspaceIsolation("sender"); err == nil {
+		t.Fatal("expected unknown mode to be rejected")
+	}
+}
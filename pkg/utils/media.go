@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +18,27 @@ import (
 
 const DefaultDownloadedMediaRetention = 30 * time.Minute
 
+var mediaDir atomic.Value // string
+
+// SetMediaDir sets the directory DownloadFile saves into. An empty dir
+// restores DefaultMediaDir.
+func SetMediaDir(dir string) {
+	mediaDir.Store(strings.TrimSpace(dir))
+}
+
+// MediaDir returns the directory DownloadFile saves into.
+func MediaDir() string {
+	if dir, _ := mediaDir.Load().(string); dir != "" {
+		return dir
+	}
+	return DefaultMediaDir()
+}
+
+// DefaultMediaDir is the download directory used until SetMediaDir is called.
+func DefaultMediaDir() string {
+	return filepath.Join(os.TempDir(), "picoclaw_media")
+}
+
 // IsAudioFile checks if a file is an audio file based on its filename extension and content type.
 func IsAudioFile(filename, contentType string) bool {
 	audioExtensions := []string{".mp3", ".wav", ".ogg", ".m4a", ".flac", ".aac", ".wma"}
@@ -53,11 +78,70 @@ type DownloadOptions struct {
 	Timeout      time.Duration
 	ExtraHeaders map[string]string
 	LoggerPrefix string
+	// MaxBytes aborts the download once the file exceeds this size
+	// (0 = unlimited). A larger Content-Length is rejected up front.
+	MaxBytes int64
+	// AllowedTypes limits downloads to these MIME types ("image/png",
+	// "image/*") and extensions (".pdf"). Empty allows everything.
+	AllowedTypes []string
+	// ContentType is the file's MIME type when already known (e.g. from
+	// message metadata). It is checked against AllowedTypes before the
+	// request; otherwise the response Content-Type is used.
+	ContentType string
+}
+
+var (
+	// ErrFileTooLarge is returned when a download exceeds DownloadOptions.MaxBytes.
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrFileTypeNotAllowed is returned when a file matches none of
+	// DownloadOptions.AllowedTypes.
+	ErrFileTypeNotAllowed = errors.New("file type not allowed")
+)
+
+// MediaTypeAllowed reports whether a file passes an allowed-types list of
+// MIME types, MIME wildcards ("audio/*") and extensions (".ogg"). An empty
+// list allows everything.
+func MediaTypeAllowed(filename, contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	mediaType := ""
+	if contentType != "" {
+		if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+			mediaType = parsed
+		}
+	}
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "."):
+			if ext == entry {
+				return true
+			}
+		case strings.HasSuffix(entry, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(entry, "*")) {
+				return true
+			}
+		case mediaType == entry:
+			return true
+		}
+	}
+	return false
 }
 
-// DownloadFile downloads a file from URL to a local temp directory.
+// DownloadFile downloads a file from URL to MediaDir.
 // Returns the local file path or empty string on error.
 func DownloadFile(url, filename string, opts DownloadOptions) string {
+	localPath, _ := DownloadFileChecked(url, filename, opts)
+	return localPath
+}
+
+// DownloadFileChecked is DownloadFile with the failure reason. Files over
+// opts.MaxBytes fail with ErrFileTooLarge and files outside
+// opts.AllowedTypes with ErrFileTypeNotAllowed; no local file is left behind.
+func DownloadFileChecked(url, filename string, opts DownloadOptions) (string, error) {
 	// Set defaults
 	if opts.Timeout == 0 {
 		opts.Timeout = 60 * time.Second
@@ -66,12 +150,12 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 		opts.LoggerPrefix = "utils"
 	}
 
-	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	mediaDir := MediaDir()
 	if err := os.MkdirAll(mediaDir, 0700); err != nil {
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to create media directory", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", fmt.Errorf("failed to create media directory: %w", err)
 	}
 
 	// Generate unique filename with UUID prefix to prevent conflicts.
@@ -82,13 +166,18 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 	}
 	localPath := filepath.Join(mediaDir, uuid.New().String()[:8]+"_"+safeName)
 
+	if opts.ContentType != "" && !MediaTypeAllowed(safeName, opts.ContentType, opts.AllowedTypes) {
+		logRejectedDownload(opts, safeName, ErrFileTypeNotAllowed, opts.ContentType)
+		return "", ErrFileTypeNotAllowed
+	}
+
 	// Create HTTP request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to create download request", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", fmt.Errorf("failed to create download request: %w", err)
 	}
 
 	// Add extra headers (e.g., Authorization for Slack)
@@ -103,7 +192,7 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 			"error": err.Error(),
 			"url":   url,
 		})
-		return ""
+		return "", fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -112,7 +201,16 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 			"status": resp.StatusCode,
 			"url":    url,
 		})
-		return ""
+		return "", fmt.Errorf("file download returned status %d", resp.StatusCode)
+	}
+
+	if opts.ContentType == "" && !MediaTypeAllowed(safeName, resp.Header.Get("Content-Type"), opts.AllowedTypes) {
+		logRejectedDownload(opts, safeName, ErrFileTypeNotAllowed, resp.Header.Get("Content-Type"))
+		return "", ErrFileTypeNotAllowed
+	}
+	if opts.MaxBytes > 0 && resp.ContentLength > opts.MaxBytes {
+		logRejectedDownload(opts, safeName, ErrFileTooLarge, fmt.Sprintf("%d bytes", resp.ContentLength))
+		return "", ErrFileTooLarge
 	}
 
 	out, err := os.Create(localPath)
@@ -120,24 +218,44 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to create local file", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", fmt.Errorf("failed to create local file: %w", err)
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, resp.Body); err != nil {
+	var body io.Reader = resp.Body
+	if opts.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, opts.MaxBytes+1)
+	}
+	written, err := io.Copy(out, body)
+	if err != nil {
 		out.Close()
 		os.Remove(localPath)
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to write file", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return ""
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if opts.MaxBytes > 0 && written > opts.MaxBytes {
+		out.Close()
+		os.Remove(localPath)
+		logRejectedDownload(opts, safeName, ErrFileTooLarge, fmt.Sprintf("over %d bytes", opts.MaxBytes))
+		return "", ErrFileTooLarge
 	}
 
 	logger.DebugCF(opts.LoggerPrefix, "File downloaded successfully", map[string]interface{}{
 		"path": localPath,
 	})
 
-	return localPath
+	return localPath, nil
+}
+
+// logRejectedDownload logs by file name; download URLs may embed tokens.
+func logRejectedDownload(opts DownloadOptions, name string, reason error, detail string) {
+	logger.WarnCF(opts.LoggerPrefix, "Download rejected", map[string]interface{}{
+		"reason": reason.Error(),
+		"detail": detail,
+		"file":   name,
+	})
 }
 
 // ScheduleFileCleanup removes a file after a delay. It is best-effort and
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// MediaSweepInterval is how often StartMediaSweeper scans the media directory.
+const MediaSweepInterval = 10 * time.Minute
+
+// downloadedFileRe matches the names DownloadFile gives its files: the first
+// eight hex digits of a UUID, an underscore, then the sanitized filename.
+var downloadedFileRe = regexp.MustCompile(`^[0-9a-f]{8}_.`)
+
+// SweepMediaDir deletes files DownloadFile saved directly in dir that were
+// last modified before now-maxAge, and returns how many it removed. Files
+// named otherwise and subdirectories are left alone, so a media dir shared
+// with other files is safe. A missing dir is not an error.
+func SweepMediaDir(dir string, maxAge time.Duration, now time.Time) (int, error) {
+	return sweepDir(dir, maxAge, now, downloadedFileRe.MatchString)
+}
+
+// SweepStaleFiles is SweepMediaDir for a directory owned by the caller: it
+// deletes every stale regular file, whatever its name.
+func SweepStaleFiles(dir string, maxAge time.Duration, now time.Time) (int, error) {
+	return sweepDir(dir, maxAge, now, nil)
+}
+
+// sweepDir deletes the stale regular files in dir whose names match (all of
+// them when match is nil).
+func sweepDir(dir string, maxAge time.Duration, now time.Time, match func(name string) bool) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	cutoff := now.Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || (match != nil && !match(entry.Name())) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			if !os.IsNotExist(err) {
+				logger.DebugCF("media", "Failed to sweep media file", map[string]interface{}{
+					"file":  entry.Name(),
+					"error": err.Error(),
+				})
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartMediaSweeper sweeps dir once right away (clearing files orphaned by
+// a crash) and then every interval until ctx is done. maxAge <= 0 disables
+// it.
+func StartMediaSweeper(ctx context.Context, dir string, maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = MediaSweepInterval
+	}
+	sweep := func() {
+		removed, err := SweepMediaDir(dir, maxAge, time.Now())
+		if err != nil {
+			logger.WarnCF("media", "Media sweep failed", map[string]interface{}{
+				"dir":   dir,
+				"error": err.Error(),
+			})
+			return
+		}
+		if removed > 0 {
+			logger.InfoCF("media", "Removed stale media files", map[string]interface{}{
+				"dir":     dir,
+				"removed": removed,
+			})
+		}
+	}
+
+	go func() {
+		sweep()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSweepMediaDir_RemovesOnlyStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	oldPath := filepath.Join(dir, "0a1b2c3d_voice.ogg")
+	recentPath := filepath.Join(dir, "4e5f6a7b_photo.jpg")
+	// Not named like a download: someone else's file in a shared dir.
+	foreignPath := filepath.Join(dir, "notes.txt")
+	for _, p := range []string{oldPath, recentPath, foreignPath} {
+		if err := os.WriteFile(p, []byte("data"), 0600); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	for _, p := range []string{oldPath, foreignPath} {
+		if err := os.Chtimes(p, now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "keep"), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	removed, err := SweepMediaDir(dir, time.Hour, now)
+	if err != nil {
+		t.Fatalf("SweepMediaDir: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed = %d, want 1", removed)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatalf("expected old file to be removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(recentPath); err != nil {
+		t.Fatalf("expected recent file to stay: %v", err)
+	}
+	if _, err := os.Stat(foreignPath); err != nil {
+		t.Fatalf("expected file not saved by DownloadFile to stay: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "keep")); err != nil {
+		t.Fatalf("expected subdirectory to stay: %v", err)
+	}
+
+	if removed, err := SweepMediaDir(filepath.Join(dir, "missing"), time.Hour, now); err != nil || removed != 0 {
+		t.Fatalf("missing dir: removed=%d err=%v", removed, err)
+	}
+}
+
+func TestSweepStaleFiles_RemovesAnyStaleFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	oldPath := filepath.Join(dir, "exec-tc1.txt")
+	if err := os.WriteFile(oldPath, []byte("data"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(oldPath, now.Add(-2*time.Hour), now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	if removed, err := SweepStaleFiles(dir, time.Hour, now); err != nil || removed != 1 {
+		t.Fatalf("removed=%d err=%v, want 1 file removed", removed, err)
+	}
+}
+
+func TestStartMediaSweeper_SweepsOnStart(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "0a1b2c3d_orphan.bin")
+	if err := os.WriteFile(oldPath, []byte("data"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(oldPath, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartMediaSweeper(ctx, dir, time.Minute, time.Hour)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected orphaned file to be swept on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDownloadFile_UsesConfiguredMediaDir(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("bytes"))
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "media")
+	SetMediaDir(dir)
+	defer SetMediaDir("")
+
+	localPath := DownloadFile(srv.URL+"/a.txt", "a.txt", DownloadOptions{LoggerPrefix: "test"})
+	if filepath.Dir(localPath) != dir {
+		t.Fatalf("expected download in %s, got %q", dir, localPath)
+	}
+	SetMediaDir("")
+	if MediaDir() != DefaultMediaDir() {
+		t.Fatalf("expected empty dir to restore default, got %q", MediaDir())
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -60,3 +61,56 @@ func TestDownloadFile_PreservesComplexExtensions(t *testing.T) {
 		t.Fatalf("expected sanitized filename, got %q", filepath.Base(localPath))
 	}
 }
+
+func TestDownloadFileChecked_AbortsPastMaxBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush() // stream without Content-Length
+		_, _ = w.Write(make([]byte, 4096))
+	}))
+	defer srv.Close()
+
+	localPath, err := DownloadFileChecked(srv.URL+"/big.bin", "big.bin", DownloadOptions{LoggerPrefix: "test", MaxBytes: 1024})
+	if !errors.Is(err, ErrFileTooLarge) || localPath != "" {
+		t.Fatalf("DownloadFileChecked = %q, %v; want ErrFileTooLarge", localPath, err)
+	}
+
+	localPath, err = DownloadFileChecked(srv.URL+"/big.bin", "big.bin", DownloadOptions{LoggerPrefix: "test", MaxBytes: 4096})
+	if err != nil {
+		t.Fatalf("file at the cap should download: %v", err)
+	}
+	os.Remove(localPath)
+}
+
+func TestDownloadFileChecked_RejectsLargeContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 2048))
+	}))
+	defer srv.Close()
+
+	_, err := DownloadFileChecked(srv.URL+"/a.bin", "a.bin", DownloadOptions{LoggerPrefix: "test", MaxBytes: 100})
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("err = %v, want ErrFileTooLarge", err)
+	}
+}
+
+func TestMediaTypeAllowed(t *testing.T) {
+	allowed := []string{"image/*", "application/pdf", ".TXT"}
+	cases := []struct {
+		filename, contentType string
+		want                  bool
+	}{
+		{"a.jpg", "image/jpeg", true},
+		{"a.bin", "application/pdf; charset=binary", true},
+		{"notes.txt", "application/octet-stream", true},
+		{"a.exe", "application/octet-stream", false},
+		{"a.ogg", "", false},
+	}
+	for _, tc := range cases {
+		if got := MediaTypeAllowed(tc.filename, tc.contentType, allowed); got != tc.want {
+			t.Errorf("MediaTypeAllowed(%q, %q) = %v, want %v", tc.filename, tc.contentType, got, tc.want)
+		}
+	}
+	if !MediaTypeAllowed("a.exe", "", nil) {
+		t.Error("empty allow list should allow everything")
+	}
+}
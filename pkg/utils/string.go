@@ -1,16 +1,41 @@
 package utils
 
-// Truncate returns a truncated version of s with at most maxLen runes.
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Truncate returns s cut to at most limit runes. A cut string ends with a
+// marker counting what was dropped, e.g. "hello…(+12 more chars)", so a
+// reader (or the model) can tell content is missing and ask for the rest.
 // Handles multi-byte Unicode characters properly.
-// If the string is truncated, "..." is appended to indicate truncation.
-func Truncate(s string, maxLen int) string {
+func Truncate(s string, limit int) string {
 	runes := []rune(s)
-	if len(runes) <= maxLen {
+	if len(runes) <= limit {
 		return s
 	}
-	// Reserve 3 chars for "..."
-	if maxLen <= 3 {
-		return string(runes[:maxLen])
+	if limit < 0 {
+		limit = 0
+	}
+	return fmt.Sprintf("%s…(+%d more chars)", string(runes[:limit]), len(runes)-limit)
+}
+
+// TruncateMiddle cuts s to about maxBytes by keeping its head and tail and
+// replacing the middle with a marker counting the omitted bytes, e.g.
+// "start\n\n[... 1024 bytes omitted ...]\n\nend". Both ends are kept because
+// long outputs tend to carry headers at the start and errors at the end.
+// Cuts fall on rune boundaries; maxBytes <= 0 leaves s unchanged.
+func TruncateMiddle(s string, maxBytes int) string {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s
+	}
+	head := maxBytes / 2
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	tail := len(s) - (maxBytes - head)
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
 	}
-	return string(runes[:maxLen-3]) + "..."
+	return fmt.Sprintf("%s\n\n[... %d bytes omitted ...]\n\n%s", s[:head], tail-head, s[tail:])
 }
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate_AppendsCountMarker(t *testing.T) {
+	cases := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"hello world", 5, "hello…(+6 more chars)"},
+		{"héllo wörld", 4, "héll…(+7 more chars)"},
+		{"日本語のテキスト", 3, "日本語…(+5 more chars)"},
+		{"abc", 0, "…(+3 more chars)"},
+	}
+	for _, tc := range cases {
+		if got := Truncate(tc.in, tc.limit); got != tc.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tc.in, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestTruncate_LeavesShortStringsUnchanged(t *testing.T) {
+	for _, s := range []string{"", "short", "exactly10!", "日本語"} {
+		if got := Truncate(s, 10); got != s {
+			t.Errorf("Truncate(%q, 10) = %q, want unchanged", s, got)
+		}
+	}
+}
+
+func TestTruncateMiddle_KeepsHeadAndTail(t *testing.T) {
+	in := "HEADER\n" + strings.Repeat("x", 1000) + "\nERROR: boom"
+	got := TruncateMiddle(in, 40)
+	want := "HEADER\n" + strings.Repeat("x", 13) + "\n\n[... 979 bytes omitted ...]\n\n" + strings.Repeat("x", 8) + "\nERROR: boom"
+	if got != want {
+		t.Fatalf("TruncateMiddle() = %q, want %q", got, want)
+	}
+
+	for _, s := range []string{"", "short"} {
+		if got := TruncateMiddle(s, 10); got != s {
+			t.Errorf("TruncateMiddle(%q, 10) = %q, want unchanged", s, got)
+		}
+	}
+	if got := TruncateMiddle(in, 0); got != in {
+		t.Error("maxBytes 0 should leave the string unchanged")
+	}
+}
+
+func TestTruncateMiddle_CutsOnRuneBoundaries(t *testing.T) {
+	got := TruncateMiddle(strings.Repeat("日本語", 100), 20)
+	if !utf8.ValidString(got) {
+		t.Fatalf("TruncateMiddle produced invalid UTF-8: %q", got)
+	}
+	if !strings.HasPrefix(got, "日本語") || !strings.HasSuffix(got, "本語") {
+		t.Fatalf("unexpected ends: %q", got)
+	}
+}
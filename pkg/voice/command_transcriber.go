@@ -0,0 +1,76 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// CommandFilePlaceholder is replaced with the audio file path in a
+// CommandTranscriber's arguments.
+const CommandFilePlaceholder = "{file}"
+
+// CommandTranscriber runs a local program, e.g. whisper.cpp's whisper-cli,
+// and uses its standard output as the transcript. Nothing leaves the host.
+type CommandTranscriber struct {
+	command []string
+}
+
+// NewCommandTranscriber runs command (program and arguments) per audio
+// file. Arguments containing {file} get the file path substituted; without
+// any, the path is appended as the last argument.
+func NewCommandTranscriber(command []string) *CommandTranscriber {
+	logger.DebugCF("voice", "Creating command transcriber", map[string]interface{}{"command": command})
+	return &CommandTranscriber{command: append([]string(nil), command...)}
+}
+
+func (t *CommandTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	if !t.IsAvailable() {
+		return nil, fmt.Errorf("transcription command not configured")
+	}
+	args := make([]string, 0, len(t.command))
+	substituted := false
+	for _, arg := range t.command[1:] {
+		if strings.Contains(arg, CommandFilePlaceholder) {
+			arg = strings.ReplaceAll(arg, CommandFilePlaceholder, audioFilePath)
+			substituted = true
+		}
+		args = append(args, arg)
+	}
+	if !substituted {
+		args = append(args, audioFilePath)
+	}
+
+	logger.InfoCF("voice", "Starting transcription", map[string]interface{}{"audio_file": audioFilePath, "program": t.command[0]})
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command[0], args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("transcription command: %w", ctx.Err())
+		}
+		logger.ErrorCF("voice", "Transcription command failed", map[string]interface{}{
+			"error":  err.Error(),
+			"stderr": utils.Truncate(stderr.String(), 500),
+		})
+		return nil, fmt.Errorf("transcription command failed: %w", err)
+	}
+
+	text := strings.Join(strings.Fields(stdout.String()), " ")
+	logger.InfoCF("voice", "Transcription completed successfully", map[string]interface{}{
+		"text_length":           len(text),
+		"transcription_preview": utils.Truncate(text, 50),
+	})
+	return &TranscriptionResponse{Text: text}, nil
+}
+
+func (t *CommandTranscriber) IsAvailable() bool {
+	return t != nil && len(t.command) > 0 && strings.TrimSpace(t.command[0]) != ""
+}
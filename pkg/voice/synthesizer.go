@@ -0,0 +1,140 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Synthesizer turns reply text into an audio file suitable for a voice note.
+type Synthesizer interface {
+	// Synthesize writes the speech for text to a local .ogg (opus) file and
+	// returns its path. The caller owns the file and should remove it.
+	Synthesize(ctx context.Context, text string) (string, error)
+	IsAvailable() bool
+}
+
+// OpenAISynthesizer calls an OpenAI-compatible /audio/speech endpoint.
+type OpenAISynthesizer struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+type speechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+func NewOpenAISynthesizer(apiKey, apiBase, model, voice string, timeout time.Duration) *OpenAISynthesizer {
+	logger.DebugCF("voice", "Creating OpenAI synthesizer", map[string]interface{}{"has_api_key": apiKey != "", "model": model})
+
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		apiBase = "https://api.openai.com/v1"
+	}
+	if strings.TrimSpace(model) == "" {
+		model = "tts-1"
+	}
+	if strings.TrimSpace(voice) == "" {
+		voice = "alloy"
+	}
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &OpenAISynthesizer{
+		apiKey:  apiKey,
+		apiBase: apiBase,
+		model:   model,
+		voice:   voice,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("no text to synthesize")
+	}
+
+	payload, err := json.Marshal(speechRequest{
+		Model:          s.model,
+		Input:          text,
+		Voice:          s.voice,
+		ResponseFormat: "opus",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := s.apiBase + "/audio/speech"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	logger.DebugCF("voice", "Sending speech request", map[string]interface{}{
+		"url":         url,
+		"text_length": len(text),
+	})
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		logger.ErrorCF("voice", "Failed to send speech request", map[string]interface{}{"error": err})
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		logger.ErrorCF("voice", "Speech API error", map[string]interface{}{
+			"status_code": resp.StatusCode,
+			"response":    string(body),
+		})
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	out, err := os.CreateTemp("", "picoclaw-tts-*.ogg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create audio file: %w", err)
+	}
+	written, copyErr := io.Copy(out, resp.Body)
+	closeErr := out.Close()
+	if copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("failed to write audio file: %w", copyErr)
+	}
+	if written == 0 {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("speech API returned empty audio")
+	}
+
+	logger.InfoCF("voice", "Speech synthesized", map[string]interface{}{
+		"path":       out.Name(),
+		"size_bytes": written,
+	})
+	return out.Name(), nil
+}
+
+func (s *OpenAISynthesizer) IsAvailable() bool {
+	return s != nil && s.apiKey != ""
+}
@@ -0,0 +1,66 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestOpenAISynthesizer_WritesOpusFile(t *testing.T) {
+	var got speechRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/speech" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer key" {
+			t.Errorf("unexpected auth header %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Write([]byte("OggS-audio"))
+	}))
+	defer server.Close()
+
+	s := NewOpenAISynthesizer("key", server.URL+"/", "", "", 0)
+	path, err := s.Synthesize(context.Background(), "  hello there ")
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	defer os.Remove(path)
+
+	if got.Input != "hello there" || got.ResponseFormat != "opus" || got.Model != "tts-1" || got.Voice != "alloy" {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audio: %v", err)
+	}
+	if string(data) != "OggS-audio" {
+		t.Fatalf("audio = %q", data)
+	}
+}
+
+func TestOpenAISynthesizer_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad voice", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	s := NewOpenAISynthesizer("key", server.URL, "tts-1", "nova", 0)
+	if _, err := s.Synthesize(context.Background(), "hi"); err == nil {
+		t.Fatal("expected error for non-200 response")
+	}
+}
+
+func TestOpenAISynthesizer_IsAvailable(t *testing.T) {
+	if NewOpenAISynthesizer("", "", "", "", 0).IsAvailable() {
+		t.Fatal("expected unavailable without api key")
+	}
+	if !NewOpenAISynthesizer("k", "", "", "", 0).IsAvailable() {
+		t.Fatal("expected available with api key")
+	}
+}
@@ -10,18 +10,36 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-type GroqTranscriber struct {
+// Transcriber turns a local audio file into text. Channels hold one and
+// skip transcription when it reports itself unavailable.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error)
+	IsAvailable() bool
+}
+
+// APITranscriber calls an OpenAI-compatible /audio/transcriptions endpoint
+// (OpenAI, Groq, or a local whisper server speaking the same API).
+type APITranscriber struct {
+	name       string // for logs, e.g. "Groq"
+	keyless    bool   // a self-hosted endpoint may not need an API key
 	apiKey     string
 	apiBase    string
+	model      string
 	httpClient *http.Client
 }
 
+// GroqTranscriber transcribes with Groq's hosted whisper-large-v3.
+type GroqTranscriber struct {
+	*APITranscriber
+}
+
 type TranscriptionResponse struct {
 	Text     string  `json:"text"`
 	Language string  `json:"language,omitempty"`
@@ -30,18 +48,38 @@ type TranscriptionResponse struct {
 
 func NewGroqTranscriber(apiKey string) *GroqTranscriber {
 	logger.DebugCF("voice", "Creating Groq transcriber", map[string]interface{}{"has_api_key": apiKey != ""})
+	return &GroqTranscriber{newAPITranscriber("Groq", apiKey, "https://api.groq.com/openai/v1", "whisper-large-v3")}
+}
+
+// NewOpenAITranscriber transcribes through an OpenAI-compatible endpoint.
+// An empty apiBase uses OpenAI and an empty model "whisper-1".
+func NewOpenAITranscriber(apiKey, apiBase, model string) *APITranscriber {
+	logger.DebugCF("voice", "Creating OpenAI transcriber", map[string]interface{}{"has_api_key": apiKey != "", "model": model})
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		apiBase = "https://api.openai.com/v1"
+	}
+	if strings.TrimSpace(model) == "" {
+		model = "whisper-1"
+	}
+	t := newAPITranscriber("OpenAI", apiKey, apiBase, model)
+	t.keyless = !strings.Contains(apiBase, "api.openai.com")
+	return t
+}
 
-	apiBase := "https://api.groq.com/openai/v1"
-	return &GroqTranscriber{
+func newAPITranscriber(name, apiKey, apiBase, model string) *APITranscriber {
+	return &APITranscriber{
+		name:    name,
 		apiKey:  apiKey,
 		apiBase: apiBase,
+		model:   model,
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 	}
 }
 
-func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+func (t *APITranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
 	logger.InfoCF("voice", "Starting transcription", map[string]interface{}{"audio_file": audioFilePath})
 
 	audioFile, err := os.Open(audioFilePath)
@@ -79,7 +117,7 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 
 	logger.DebugCF("voice", "File copied to request", map[string]interface{}{"bytes_copied": copied})
 
-	if err := writer.WriteField("model", "whisper-large-v3"); err != nil {
+	if err := writer.WriteField("model", t.model); err != nil {
 		logger.ErrorCF("voice", "Failed to write model field", map[string]interface{}{"error": err})
 		return nil, fmt.Errorf("failed to write model field: %w", err)
 	}
@@ -102,9 +140,11 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
 
-	logger.DebugCF("voice", "Sending transcription request to Groq API", map[string]interface{}{
+	logger.DebugCF("voice", "Sending transcription request to "+t.name+" API", map[string]interface{}{
 		"url":                url,
 		"request_size_bytes": requestBody.Len(),
 		"file_size_bytes":    fileInfo.Size(),
@@ -131,7 +171,7 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
-	logger.DebugCF("voice", "Received response from Groq API", map[string]interface{}{
+	logger.DebugCF("voice", "Received response from "+t.name+" API", map[string]interface{}{
 		"status_code":         resp.StatusCode,
 		"response_size_bytes": len(body),
 	})
@@ -152,8 +192,8 @@ func (t *GroqTranscriber) Transcribe(ctx context.Context, audioFilePath string)
 	return &result, nil
 }
 
-func (t *GroqTranscriber) IsAvailable() bool {
-	available := t.apiKey != ""
+func (t *APITranscriber) IsAvailable() bool {
+	available := t != nil && (t.apiKey != "" || t.keyless)
 	logger.DebugCF("voice", "Checking transcriber availability", map[string]interface{}{"available": available})
 	return available
 }
@@ -0,0 +1,106 @@
+package voice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeTestAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "note.ogg")
+	if err := os.WriteFile(path, []byte("OggS-audio"), 0644); err != nil {
+		t.Fatalf("write audio: %v", err)
+	}
+	return path
+}
+
+func TestOpenAITranscriber_SendsModelAndKey(t *testing.T) {
+	var model, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		model = r.FormValue("model")
+		w.Write([]byte(`{"text":"hello there"}`))
+	}))
+	defer server.Close()
+
+	tr := NewOpenAITranscriber("key", server.URL+"/", "")
+	result, err := tr.Transcribe(context.Background(), writeTestAudio(t))
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Text != "hello there" {
+		t.Fatalf("unexpected text %q", result.Text)
+	}
+	if model != "whisper-1" || auth != "Bearer key" {
+		t.Fatalf("unexpected request: model=%q auth=%q", model, auth)
+	}
+}
+
+func TestOpenAITranscriber_SelfHostedNeedsNoKey(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"text":"local"}`))
+	}))
+	defer server.Close()
+
+	tr := NewOpenAITranscriber("", server.URL, "base.en")
+	if !tr.IsAvailable() {
+		t.Fatal("expected self-hosted endpoint to be available without a key")
+	}
+	if _, err := tr.Transcribe(context.Background(), writeTestAudio(t)); err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if auth != "" {
+		t.Fatalf("expected no Authorization header, got %q", auth)
+	}
+
+	if NewOpenAITranscriber("", "", "").IsAvailable() {
+		t.Fatal("expected OpenAI without a key to be unavailable")
+	}
+	if NewGroqTranscriber("").IsAvailable() {
+		t.Fatal("expected Groq without a key to be unavailable")
+	}
+}
+
+func TestCommandTranscriber_SubstitutesFileAndReadsStdout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	audio := writeTestAudio(t)
+	tr := NewCommandTranscriber([]string{"sh", "-c", `printf '  got\n%s  \n' "$1"`, "sh", "{file}"})
+
+	result, err := tr.Transcribe(context.Background(), audio)
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Text != "got "+audio {
+		t.Fatalf("unexpected text %q", result.Text)
+	}
+}
+
+func TestCommandTranscriber_FailuresAndAvailability(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+	if NewCommandTranscriber(nil).IsAvailable() {
+		t.Fatal("expected empty command to be unavailable")
+	}
+	if _, err := NewCommandTranscriber([]string{"sh", "-c", "exit 3"}).Transcribe(context.Background(), writeTestAudio(t)); err == nil {
+		t.Fatal("expected failing command to return an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewCommandTranscriber([]string{"sh", "-c", "sleep 5"}).Transcribe(ctx, writeTestAudio(t)); err == nil {
+		t.Fatal("expected cancelled context to stop the command")
+	}
+}
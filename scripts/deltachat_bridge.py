@@ -373,6 +373,11 @@ class DeltaChatBridge:
             "chat": str(snapshot.chat_id),
             "content": snapshot.get("text") or "",
         }
+        try:
+            chat_type = snapshot.chat.get_basic_snapshot().get("chat_type")
+            payload["is_group"] = chat_type in (120, "Group")
+        except Exception:
+            pass
 
         content_preview = str(payload.get("content") or "")
         lowered_preview = content_preview.lower()